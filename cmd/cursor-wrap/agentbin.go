@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/process"
+)
+
+// checkAgentBinary logs which agent binary is about to serve a turn and
+// warns when it isn't the one that served the previous turn: cursor-agent
+// updates itself, and a new version may resume the session differently.
+// It returns the info to compare the next turn against; prev is kept if
+// the binary can't be read, since the start error will say why. A
+// --remote agent's binary is on the other host and isn't checked.
+func checkAgentBinary(cfg Config, prev *process.BinaryInfo, log *logger.LogSession) *process.BinaryInfo {
+	if cfg.Process.Remote != "" {
+		return nil
+	}
+	info, err := process.StatBinary(cfg.Process.AgentBin, cfg.HashAgentBin)
+	if err != nil {
+		log.Warn("could not inspect agent binary", "agent_bin", cfg.Process.AgentBin, "error", err)
+		return prev
+	}
+	log.Info("agent binary", append(binaryAttrs("", info), logger.Kind(logger.KindTurnStats))...)
+	if prev != nil && info.Changed(*prev) {
+		log.Warn("agent binary changed since the last turn", append(binaryAttrs("", info), binaryAttrs("previous_", *prev)...)...)
+		if !cfg.Print {
+			fmt.Fprintf(os.Stderr, "! cursor-agent changed since the last turn (%s); the resumed session may behave differently\n", info.Path)
+		}
+	}
+	return &info
+}
+
+// binaryAttrs renders info as log attributes, each key prefixed.
+func binaryAttrs(prefix string, info process.BinaryInfo) []any {
+	attrs := []any{
+		prefix + "path", info.Path,
+		prefix + "size", info.Size,
+		prefix + "mtime", info.ModTime,
+	}
+	if info.SHA256 != "" {
+		attrs = append(attrs, prefix+"sha256", info.SHA256)
+	}
+	return attrs
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// keptValues are the fields whose string values an anonymizer leaves
+// alone: what events are, not what they say. The monitor and the
+// formatters switch on these.
+var keptValues = map[string]bool{
+	"type":            true,
+	"subtype":         true,
+	"role":            true,
+	"model":           true,
+	"apiKeySource":    true,
+	"permissionMode":  true,
+	"timeoutBehavior": true,
+	"signal":          true,
+}
+
+var (
+	uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	// wordPattern matches UUIDs, then runs of the characters that make up
+	// words, identifiers and paths.
+	wordPattern = regexp.MustCompile(uuidPattern.String() + `|[\w.@+~/-]+`)
+)
+
+// anonymizer rewrites events so they can be shared: every string is
+// replaced but for the kept fields, while the JSON's shape, its keys,
+// numbers and booleans are left as they were. The rewriting is
+// deterministic, a keyed hash of what is replaced, so the same session
+// id, call id, path or word comes out the same wherever it appears and
+// cross-references between events survive:
+//
+//   - a UUID becomes another UUID;
+//   - a path keeps its slashes and each segment its extension, so
+//     /home/me/app/main.go becomes /xxxx/xx/xxx/xxxx.go;
+//   - in any other word, letters become other letters of the same case,
+//     and digits and punctuation are kept.
+//
+// Lengths are kept throughout, so a turn's sizes are too.
+type anonymizer struct {
+	key []byte
+}
+
+func newAnonymizer(salt string) *anonymizer {
+	return &anonymizer{key: []byte(salt)}
+}
+
+// Event returns raw, one event's JSON, anonymized.
+func (a *anonymizer) Event(raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := a.value(dec, "", &buf); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("trailing data after the event")
+	}
+	return buf.Bytes(), nil
+}
+
+// value copies the next JSON value from dec to buf, anonymizing its
+// strings. key is the field the value is in, or the array's field.
+func (a *anonymizer) value(dec *json.Decoder, key string, buf *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		closing := byte('}')
+		if t == '[' {
+			closing = ']'
+		}
+		buf.WriteByte(byte(t))
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			elemKey := key
+			if t == '{' {
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				elemKey = k.(string)
+				writeJSONString(buf, elemKey)
+				buf.WriteByte(':')
+			}
+			if err := a.value(dec, elemKey, buf); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		buf.WriteByte(closing)
+	case string:
+		if !keptValues[key] {
+			t = a.Text(t)
+		}
+		writeJSONString(buf, t)
+	case json.Number:
+		buf.WriteString(t.String())
+	case bool:
+		fmt.Fprint(buf, t)
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+// Text returns s anonymized.
+func (a *anonymizer) Text(s string) string {
+	return wordPattern.ReplaceAllStringFunc(s, func(w string) string {
+		if uuidPattern.MatchString(w) && len(w) == 36 {
+			return a.uuid(w)
+		}
+		segments := strings.Split(w, "/")
+		for i, seg := range segments {
+			segments[i] = a.segment(seg)
+		}
+		return strings.Join(segments, "/")
+	})
+}
+
+// uuid returns the UUID standing in for id.
+func (a *anonymizer) uuid(id string) string {
+	h := hex.EncodeToString(a.sum(strings.ToLower(id), 0)[:16])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// segment returns a word, or a segment of a path, anonymized. A short
+// extension is kept.
+func (a *anonymizer) segment(seg string) string {
+	if seg == "" || seg == "." || seg == ".." || seg == "~" {
+		return seg
+	}
+	name, ext := seg, ""
+	if dot := strings.LastIndexByte(seg, '.'); dot > 0 && len(seg)-dot <= 5 && isAlnum(seg[dot+1:]) {
+		name, ext = seg[:dot], seg[dot:]
+	}
+	out := []rune(name)
+	var stream []byte
+	for i, r := range out {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for len(stream) <= i {
+			stream = append(stream, a.sum(name, len(stream)/sha256.Size)...)
+		}
+		letter := 'a' + rune(stream[i]%26)
+		if unicode.IsUpper(r) {
+			letter = unicode.ToUpper(letter)
+		}
+		out[i] = letter
+	}
+	return string(out) + ext
+}
+
+// sum is the keyed hash of s, the n'th block of it for strings longer
+// than one.
+func (a *anonymizer) sum(s string, n int) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	_ = binary.Write(mac, binary.BigEndian, uint32(n)) // a hash.Hash never fails to write
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+func isAlnum(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeJSONString writes s as a JSON string, without escaping HTML
+// characters, as the agent doesn't.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // encoding a string can't fail
+	buf.Truncate(buf.Len() - 1)
+}
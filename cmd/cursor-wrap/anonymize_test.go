@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizer_Text(t *testing.T) {
+	a := newAnonymizer("")
+	tests := []struct {
+		in   string
+		keep []string // substrings that must survive
+		drop []string // substrings that must not
+	}{
+		{"/home/alice/acme/billing/main.go", []string{".go", "/"}, []string{"alice", "acme", "billing", "main"}},
+		{"go test ./internal/... -run TestInvoice", []string{"./", "/...", " -", " "}, []string{"internal", "Invoice"}},
+		{"d43015b9-0707-43f4-b2df-0bcea7891654", nil, []string{"d43015b9"}},
+		{"exit 42 after 3 retries", []string{"42", " 3 "}, []string{"exit", "retries"}},
+	}
+	for _, tt := range tests {
+		got := a.Text(tt.in)
+		if len(got) != len(tt.in) {
+			t.Errorf("Text(%q) = %q: length %d, want %d", tt.in, got, len(got), len(tt.in))
+		}
+		for _, s := range tt.keep {
+			if !strings.Contains(got, s) {
+				t.Errorf("Text(%q) = %q, lost %q", tt.in, got, s)
+			}
+		}
+		for _, s := range tt.drop {
+			if strings.Contains(got, s) {
+				t.Errorf("Text(%q) = %q, kept %q", tt.in, got, s)
+			}
+		}
+		if again := a.Text(tt.in); again != got {
+			t.Errorf("Text(%q) not deterministic: %q, then %q", tt.in, got, again)
+		}
+	}
+
+	// A UUID comes out a UUID, the same one inside a longer id.
+	id := a.Text("5bf03e32-be64-48d4-a5ed-c4a0a939b88c")
+	if !uuidPattern.MatchString(id) {
+		t.Errorf("UUID became %q", id)
+	}
+	if got := a.Text("5bf03e32-be64-48d4-a5ed-c4a0a939b88c-0-otyz"); !strings.HasPrefix(got, id+"-0-") {
+		t.Errorf("model call id = %q, want it to start with %q", got, id)
+	}
+	// A path segment maps the same in a path and on its own.
+	if got, seg := a.Text("/srv/acme/app"), a.Text("acme"); !strings.Contains(got, "/"+seg+"/") {
+		t.Errorf("/srv/acme/app = %q, acme = %q", got, seg)
+	}
+	// Case survives; the salt changes everything.
+	if got := a.Text("Invoice"); got[0] < 'A' || got[0] > 'Z' || got[1:] != strings.ToLower(got[1:]) {
+		t.Errorf("Invoice = %q, want it capitalized", got)
+	}
+	if newAnonymizer("s3cret").Text("acme") == a.Text("acme") {
+		t.Error("salt didn't change the hash")
+	}
+}
+
+func TestAnonymizer_Event(t *testing.T) {
+	a := newAnonymizer("")
+	raw := `{"type":"tool_call","subtype":"started","call_id":"call_9x","tool_call":{"shellToolCall":{"args":{"command":"cat <secret.txt","timeout":10000,"isBackground":false,"simpleCommands":["cat"]}}},"session_id":"d43015b9-0707-43f4-b2df-0bcea7891654","timestamp_ms":1770823845357,"extra":null}`
+	got, err := a.Event([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys in order, and numbers, booleans, nulls and kept fields as they were.
+	for _, s := range []string{
+		`{"type":"tool_call","subtype":"started","call_id":"`,
+		`","tool_call":{"shellToolCall":{"args":{"command":"`,
+		`","timeout":10000,"isBackground":false,"simpleCommands":["`,
+		`"timestamp_ms":1770823845357,"extra":null}`,
+	} {
+		if !strings.Contains(string(got), s) {
+			t.Errorf("event = %s, want it to contain %s", got, s)
+		}
+	}
+	var ev struct {
+		CallID   string `json:"call_id"`
+		ToolCall struct {
+			Shell struct {
+				Args struct {
+					Command        string   `json:"command"`
+					SimpleCommands []string `json:"simpleCommands"`
+				} `json:"args"`
+			} `json:"shellToolCall"`
+		} `json:"tool_call"`
+	}
+	if err := json.Unmarshal(got, &ev); err != nil {
+		t.Fatalf("anonymized event isn't JSON: %v\n%s", err, got)
+	}
+	args := ev.ToolCall.Shell.Args
+	if args.Command != a.Text("cat <secret.txt") || args.SimpleCommands[0] != a.Text("cat") || ev.CallID != a.Text("call_9x") {
+		t.Errorf("event = %s", got)
+	}
+	if strings.Contains(string(got), "secret") || strings.Contains(string(got), "d43015b9") {
+		t.Errorf("event = %s, still holds the original", got)
+	}
+
+	if _, err := a.Event([]byte(`{"type":"result"`)); err == nil {
+		t.Error("truncated event: no error")
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"cursor-wrap/internal/format"
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/monitor"
+	"cursor-wrap/internal/process"
+)
+
+// --batch-session values.
+const (
+	batchSessionFresh  = "fresh"  // each prompt starts a session of its own
+	batchSessionShared = "shared" // each prompt resumes the one before's
+)
+
+// ErrBatchFailed is returned by a --batch run in which a prompt failed,
+// without --fail-fast.
+var ErrBatchFailed = errors.New("batch prompts failed")
+
+// batchPrompt is one prompt of a --batch file.
+type batchPrompt struct {
+	Line int // in the file, from 1
+	Text string
+}
+
+// readBatch reads a --batch file: one prompt per line, or, in a .jsonl
+// file, one JSON string or {"prompt": "..."} object per line, for prompts
+// of more than one line. Blank lines, and in a plain file lines starting
+// with #, are skipped.
+func readBatch(path string) ([]batchPrompt, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("--batch: %w", err)
+	}
+	defer func() { _ = f.Close() }() // read-only
+	jsonl := strings.EqualFold(filepath.Ext(path), ".jsonl")
+
+	var prompts []batchPrompt
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || (!jsonl && strings.HasPrefix(line, "#")) {
+			continue
+		}
+		text := line
+		if jsonl {
+			if text, err = jsonPrompt([]byte(line)); err != nil {
+				return nil, fmt.Errorf("--batch: %s:%d: %w", path, n, err)
+			}
+		}
+		prompts = append(prompts, batchPrompt{Line: n, Text: text})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("--batch: %s: %w", path, err)
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("--batch: %s has no prompts", path)
+	}
+	return prompts, nil
+}
+
+// jsonPrompt returns the prompt a .jsonl batch line holds.
+func jsonPrompt(line []byte) (string, error) {
+	var text string
+	if line[0] == '{' {
+		var obj struct {
+			Prompt *string `json:"prompt"`
+		}
+		if err := json.Unmarshal(line, &obj); err != nil {
+			return "", err
+		}
+		if obj.Prompt == nil {
+			return "", errors.New(`no "prompt" field`)
+		}
+		text = *obj.Prompt
+	} else if err := json.Unmarshal(line, &text); err != nil {
+		return "", fmt.Errorf("want a JSON string or object: %w", err)
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("empty prompt")
+	}
+	return text, nil
+}
+
+// runBatch runs each of prompts as a turn of its own, as -p runs its one
+// prompt: a hang, a turn timeout, a fatal stderr line, too much output or
+// an agent that exits without a result (after any --retry-on-abnormal-exit)
+// fails the prompt, and the batch goes on to the next one unless --fail-fast. Any
+// other error, such as the agent failing to start, stops the batch. Each
+// prompt's outcome goes into summary.
+func runBatch(ctx context.Context, cfg Config, prompts []batchPrompt, fmtr format.Formatter, log *logger.LogSession, summary *sessionSummary) error {
+	log.Info("batch started", "file", cfg.Batch, "prompts", len(prompts), "session", cfg.BatchSession, "fail_fast", cfg.FailFast)
+	var durations *monitor.CommandDurations
+	if cfg.EstimateFactor > 0 {
+		durations = monitor.NewCommandDurations(cfg.EstimateFactor)
+	}
+	tracer := newTracer(cfg, log)
+	var agentBin *process.BinaryInfo
+	var sessions []string // every session the batch ran in, in order
+	resumeID := cfg.Process.SessionID
+	turn, failed := 0, 0
+	for i, bp := range prompts {
+		if cfg.BatchSession == batchSessionFresh {
+			resumeID = ""
+		}
+		if err := fmtr.WriteBatchHeader(i+1, len(prompts), bp.Text); err != nil {
+			log.Warn("writing batch header failed", "error", err)
+		}
+		entry := batchPromptSummary{Index: i + 1, Line: bp.Line, PromptBytes: len(bp.Text)}
+		started := time.Now()
+		var result TurnResult
+		for attempt := 0; ; attempt++ {
+			turn++
+			turnLog := log.WithTurn(turn)
+			turnLog.Info("batch prompt", "index", i+1, "line", bp.Line, "attempt", attempt+1)
+			procCfg := cfg.Process.Clone()
+			procCfg.Prompt = bp.Text
+			procCfg.SessionID = resumeID
+			if cfg.Replay == "" {
+				agentBin = checkAgentBinary(cfg, agentBin, turnLog)
+			}
+			trace := startTurnTrace(tracer, turn, procCfg)
+			result = runTurn(ctx, turn, procCfg, fmtr, turnLog, cfg, durations, trace)
+			trace.End(result)
+			flushTrace(tracer, turnLog)
+			summary.addTurn(turn, result)
+			entry.Turns = append(entry.Turns, turn)
+
+			if id := result.SessionID; id != "" && !slices.Contains(sessions, id) {
+				turnLog.Info("session started", "session_id", id)
+				if len(sessions) == 0 {
+					log.SetSessionID(id)
+				} else {
+					log.AddSessionID(id)
+				}
+				sessions = append(sessions, id)
+			}
+			resumeID = cmp.Or(result.SessionID, resumeID)
+
+			if errors.Is(result.Err, ErrAbnormalExit) && resumeID != "" && attempt < cfg.RetryOnAbnormalExit {
+				wait := startBackoff(abnormalExitRetryDelay, attempt)
+				turnLog.Warn("agent exited abnormally, resuming turn",
+					"error", result.Err,
+					"attempt", attempt+1,
+					"max_attempts", cfg.RetryOnAbnormalExit,
+					"retry_in_ms", wait.Milliseconds(),
+				)
+				if !sleepCtx(ctx, wait) {
+					return result.Err
+				}
+				continue
+			}
+			break
+		}
+		entry.SessionID = result.SessionID
+		entry.ResultSubtype = result.ResultSubtype
+		entry.WallTimeMS = time.Since(started).Milliseconds()
+		entry.Status = batchSucceeded
+
+		if err := result.Err; err != nil {
+			switch {
+			case errors.Is(err, ErrHangDetected):
+				if err := fmtr.WriteHangIndicator(result.Reason, result.KilledAt); err != nil {
+					log.Warn("writing hang indicator failed", "error", err)
+				}
+			case errors.Is(err, ErrFatalStderr), errors.Is(err, ErrOutputLimit), errors.Is(err, ErrAbnormalExit), errors.Is(err, ErrTurnTimeout):
+			default:
+				return err // not the prompt's failure: the batch can't go on
+			}
+			failed++
+			entry.Status, entry.Error = batchFailed, err.Error()
+			log.Warn("batch prompt failed", "index", i+1, "line", bp.Line, "error", err)
+		}
+		summary.Batch = append(summary.Batch, entry)
+
+		if result.Err != nil && cfg.FailFast {
+			for j, skipped := range prompts[i+1:] {
+				summary.Batch = append(summary.Batch, batchPromptSummary{
+					Index: i + j + 2, Line: skipped.Line, PromptBytes: len(skipped.Text), Status: batchSkipped,
+				})
+			}
+			log.Error("batch stopped by --fail-fast", "index", i+1, "skipped", len(prompts)-i-1)
+			return fmt.Errorf("batch prompt %d (line %d): %w", i+1, bp.Line, result.Err)
+		}
+	}
+	log.Info("batch finished", "prompts", len(prompts), "succeeded", len(prompts)-failed, "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrBatchFailed, failed, len(prompts))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadBatch(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	got, err := readBatch(write("prompts.txt", "# nightly review\nReview auth\n\n  Review billing  \n"))
+	want := []batchPrompt{{Line: 2, Text: "Review auth"}, {Line: 4, Text: "Review billing"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("plain: %+v, %v; want %+v", got, err, want)
+	}
+
+	got, err = readBatch(write("prompts.jsonl", `"Review auth"`+"\n\n"+`{"prompt":"# Plan\n\nRefactor the parser.","id":7}`+"\n"))
+	want = []batchPrompt{{Line: 1, Text: "Review auth"}, {Line: 3, Text: "# Plan\n\nRefactor the parser."}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("jsonl: %+v, %v; want %+v", got, err, want)
+	}
+
+	for _, tt := range []struct {
+		name, content, want string
+	}{
+		{"empty.txt", "# only a comment\n\n", "has no prompts"},
+		{"bare.jsonl", "Review auth\n", "bare.jsonl:1: want a JSON string or object"},
+		{"noprompt.jsonl", `"ok"` + "\n" + `{"text":"Review auth"}`, `noprompt.jsonl:2: no "prompt" field`},
+		{"blank.jsonl", `{"prompt":"  "}`, "empty prompt"},
+	} {
+		if _, err := readBatch(write(tt.name, tt.content)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	if _, err := readBatch(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"cursor-wrap/internal/logger"
+)
+
+// Interactive slash commands, typed in place of a prompt. None of them is
+// sent to the agent; a prompt that starts with "/" is typed with "//".
+const (
+	exitCommand    = "/exit"
+	sessionCommand = "/session"
+	retryCommand   = "/retry"
+	resumeCommand  = "/resume"
+	helpCommand    = "/help"
+)
+
+// commandsHelp is printed for /help and for a command that isn't one.
+const commandsHelp = `commands:
+  /exit           quit
+  /session        show the session id and the session log
+  /retry          send the last prompt again
+  /resume <id>    resume session <id> from the next turn
+  /flags <flags>  change cursor-agent's flags for the next turn only
+  //...           send a prompt that starts with "/"
+`
+
+// errExitCommand is returned for /exit: the user quit, as with Ctrl+D.
+var errExitCommand = errors.New("exit requested")
+
+// commandState is the interactive session as the slash commands see and
+// change it.
+type commandState struct {
+	SessionID  string                 // resumed by the next turn; empty before the first
+	LastPrompt string                 // the last prompt typed, for /retry
+	next       func() (string, error) // reads the next line typed
+	out        io.Writer
+	log        *logger.LogSession
+}
+
+// parseCommand splits a slash command line into its name and arguments.
+// ok is false if line is a prompt, including a "//" escaped one.
+func parseCommand(line string) (name string, args []string, ok bool) {
+	if !strings.HasPrefix(line, "/") || strings.HasPrefix(line, "//") {
+		return "", nil, false
+	}
+	fields := strings.Fields(line)
+	return fields[0], fields[1:], true
+}
+
+// takeCommands handles the slash commands typed before a prompt,
+// starting with line and reading further lines with next, and returns the
+// prompt with the /flags overrides for its turn. A later /flags line
+// replaces an earlier one; a malformed command is reported and ignored.
+// It returns errExitCommand for /exit.
+func (st *commandState) takeCommands(line string) (string, turnOverrides, error) {
+	var ov turnOverrides
+	for {
+		name, args, ok := parseCommand(line)
+		if !ok {
+			if strings.HasPrefix(line, "//") {
+				line = line[1:]
+			}
+			st.LastPrompt = line
+			return line, ov, nil
+		}
+		switch name {
+		case exitCommand:
+			st.log.Info("interactive command", "command", name)
+			return "", turnOverrides{}, errExitCommand
+		case retryCommand:
+			if st.LastPrompt == "" {
+				fmt.Fprintf(st.out, "✗ %s: no prompt to send again yet\n", retryCommand)
+				break
+			}
+			st.log.Info("interactive command", "command", name, "prompt_bytes", len(st.LastPrompt))
+			return st.LastPrompt, ov, nil
+		case sessionCommand:
+			st.log.Info("interactive command", "command", name)
+			fmt.Fprintf(st.out, "session: %s\nlog: %s\n", cmp.Or(st.SessionID, "(none yet)"), cmp.Or(st.log.FilePath(), "(none)"))
+		case resumeCommand:
+			if len(args) != 1 {
+				fmt.Fprintf(st.out, "✗ usage: %s <session-id>\n", resumeCommand)
+				break
+			}
+			st.log.Info("interactive command", "command", name, "session_id", args[0], "previous_session_id", st.SessionID)
+			st.SessionID = args[0]
+			st.log.AddSessionID(st.SessionID)
+			fmt.Fprintf(st.out, "resuming session %s from the next turn\n", st.SessionID)
+		case flagsCommand:
+			parsed, _, err := parseFlagsCommand(line)
+			if err != nil {
+				fmt.Fprintf(st.out, "✗ %v\n", err)
+				break
+			}
+			ov = parsed
+			st.log.Info("turn overrides set", "model", ov.Model, "workspace", ov.Workspace, "extra_flags", ov.ExtraFlags)
+			fmt.Fprintln(st.out, "flags set for the next turn")
+		case helpCommand:
+			fmt.Fprint(st.out, commandsHelp)
+		default:
+			st.log.Info("unknown interactive command", "command", name)
+			fmt.Fprintf(st.out, "✗ unknown command %s\n%s", name, commandsHelp)
+		}
+		var err error
+		if line, err = st.next(); err != nil {
+			return "", turnOverrides{}, err
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		name string
+		args []string
+		ok   bool
+	}{
+		{"/exit", "/exit", []string{}, true},
+		{"/resume  sess-1 ", "/resume", []string{"sess-1"}, true},
+		{"/flags --model a", "/flags", []string{"--model", "a"}, true},
+		{"/nope", "/nope", []string{}, true},
+		{"fix the bug", "", nil, false},
+		{"//etc is a path", "", nil, false},
+		{"what is /exit for?", "", nil, false},
+	}
+	for _, tt := range tests {
+		name, args, ok := parseCommand(tt.line)
+		if name != tt.name || !slices.Equal(args, tt.args) || ok != tt.ok {
+			t.Errorf("parseCommand(%q) = %q, %q, %v; want %q, %q, %v", tt.line, name, args, ok, tt.name, tt.args, tt.ok)
+		}
+	}
+}
+
+// takeCommands runs st.takeCommands over the lines of input, the first
+// being the line already read, returning what it printed.
+func takeCommands(t *testing.T, st *commandState, input string) (string, turnOverrides, string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	st.out = &out
+	first, rest, _ := strings.Cut(input, "\n")
+	r := bufio.NewReader(strings.NewReader(rest))
+	st.next = func() (string, error) { return readPrompt(r) }
+	prompt, ov, err := st.takeCommands(first)
+	return prompt, ov, out.String(), err
+}
+
+func TestTakeCommands_Flags(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+	st := &commandState{log: log}
+
+	prompt, ov, _, err := takeCommands(t, st, "/flags --verbose\n/flags --model\n/flags --model a\n/flags --model b\nthe prompt\n")
+	if err != nil {
+		t.Fatalf("takeCommands: %v", err)
+	}
+	if prompt != "the prompt" {
+		t.Errorf("prompt = %q, want %q", prompt, "the prompt")
+	}
+	// The last valid /flags line wins.
+	if ov.Model != "b" || len(ov.ExtraFlags) != 0 {
+		t.Errorf("overrides = %+v, want model b only", ov)
+	}
+
+	prompt, ov, _, err = takeCommands(t, st, "next")
+	if err != nil || prompt != "next" || ov.Model != "" {
+		t.Errorf("plain prompt: got %q, %+v, %v", prompt, ov, err)
+	}
+}
+
+func TestTakeCommands(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+	st := &commandState{log: log}
+
+	// Nothing to retry before the first prompt; a "//" prompt is sent
+	// with one slash.
+	prompt, _, out, err := takeCommands(t, st, "/retry\n//etc/hosts looks wrong\n")
+	if err != nil || prompt != "/etc/hosts looks wrong" || !strings.Contains(out, "no prompt to send again") {
+		t.Errorf("got %q, %v, output %q", prompt, err, out)
+	}
+	prompt, _, _, err = takeCommands(t, st, "/retry\n")
+	if err != nil || prompt != "/etc/hosts looks wrong" {
+		t.Errorf("/retry = %q, %v; want the last prompt", prompt, err)
+	}
+
+	prompt, _, out, err = takeCommands(t, st, "/session\n/resume\n/resume sess-2\n/session\n/frobnicate\ngo on\n")
+	if err != nil || prompt != "go on" {
+		t.Fatalf("got %q, %v", prompt, err)
+	}
+	if st.SessionID != "sess-2" {
+		t.Errorf("SessionID = %q after /resume sess-2", st.SessionID)
+	}
+	for _, want := range []string{
+		"session: (none yet)\nlog: " + strings.Replace(log.FilePath(), "sess-2", "unknown", 1),
+		"✗ usage: /resume <session-id>",
+		"resuming session sess-2",
+		"session: sess-2\nlog: " + log.FilePath(),
+		"✗ unknown command /frobnicate\ncommands:",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(log.FilePath(), "sess-2") {
+		t.Errorf("log %s not named after the resumed session", log.FilePath())
+	}
+
+	if _, _, _, err = takeCommands(t, st, "/exit\nnot read\n"); !errors.Is(err, errExitCommand) {
+		t.Errorf("/exit: err = %v", err)
+	}
+	if _, _, _, err = takeCommands(t, st, "/session\n"); !errors.Is(err, io.EOF) {
+		t.Errorf("commands then EOF: err = %v", err)
+	}
+
+	data, err := os.ReadFile(log.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"command":"/retry"`, `"command":"/resume","session_id":"sess-2"`, `"msg":"unknown interactive command"`, `"command":"/exit"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("log lacks %s", want)
+		}
+	}
+}
@@ -2,12 +2,18 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"cursor-wrap/internal/logger"
@@ -19,23 +25,160 @@ type Config struct {
 	// Mode
 	Print        bool   // -p: non-interactive, single prompt
 	OutputFormat string // "stream-json" or "text"
+	// WrapperEvents passes the wrapper's own events, such as the agent's
+	// non-JSON lines, through stream-json output.
+	WrapperEvents bool
+	// StreamToolOutput shows the output running tools stream in text
+	// output.
+	StreamToolOutput bool
+	// ShowThinking shows the agent's thinking in text output.
+	ShowThinking bool
+	// Detach re-runs the wrapper in its own session with output going to
+	// files, and returns at once (see detach).
+	Detach bool
+	// DetachPidFile holds a detached wrapper's own pid while it runs.
+	// Empty unless this is the detached child.
+	DetachPidFile string
 
 	// Hang detection
 	IdleTimeout  time.Duration
 	ToolGrace    time.Duration
 	TickInterval time.Duration
+	// MaxThinking caps a single thinking phase; 0 disables the cap.
+	MaxThinking time.Duration
+	// TurnTimeout caps a turn's wall-clock time however active the agent
+	// is, independently of hang detection; 0 disables it.
+	TurnTimeout time.Duration
+	// NonJSONLiveness counts the agent's non-JSON stdout lines as signs
+	// of life.
+	NonJSONLiveness bool
+	status          chan os.Signal // SIGUSR1, caught by main; nil in tests
+	reload          *reloader      // SIGHUP, caught by main; nil in tests
+	// EstimateFactor, when positive, bounds a repeated shell command by
+	// this multiple of its longest earlier run in the session.
+	EstimateFactor float64
+
+	// FatalStderrPatterns abort the turn early when any of them matches
+	// a line on the agent's stderr, instead of waiting out the idle timeout.
+	FatalStderrPatterns []*regexp.Regexp
+
+	// MaxOutputBytes caps the bytes read from the agent's stdout in one
+	// turn; an agent that writes more is killed. 0 disables the cap.
+	MaxOutputBytes int64
+
+	// HangWebhook is a URL notified of each hang, and with
+	// WebhookAbnormalExit of each agent that exits without a result
+	// (see webhookNotifier). Empty disables it.
+	HangWebhook         string
+	WebhookAbnormalExit bool
+	webhook             *webhookNotifier // HangWebhook, started by run
+
+	// MaxEventBytes caps a single event from the agent: a larger one is
+	// replaced by a wrapper/truncated_event event, its start kept in the
+	// log. 0 disables the cap.
+	MaxEventBytes int64
+
+	// EventBuffer is how many events the channel from the stdout reader
+	// to the turn's event loop holds; past it, events are staged in the
+	// reader (see events.Reader).
+	EventBuffer int
+
+	// HangDumpEvents is how many of a turn's last events are shown on
+	// stderr when it hangs; 0 disables the dump.
+	HangDumpEvents int
+
+	// Agent startup
+	SkipPreflight   bool          // skip the --version/status check before the first turn
+	StartRetries    int           // retries after a transient spawn failure
+	StartRetryDelay time.Duration // initial backoff, doubled per retry
+	PidFile         string        // holds the running agent's pid; rewritten each turn
+	HashAgentBin    bool          // add a sha256 to the per-turn agent binary record
+	// PromptFileThreshold is the prompt size in bytes above which the
+	// prompt is passed as an @file reference instead of on stdin; 0
+	// always uses stdin.
+	PromptFileThreshold int64
+	prompts             *promptFiles // written by runTurn, removed by run
 
 	// Logging
 	Log logger.LogConfig
+	// OTelEndpoint is the OTLP/HTTP endpoint turns are exported to as
+	// trace spans; empty falls back to OTEL_EXPORTER_OTLP_* (and, when
+	// those are unset too, nothing is exported).
+	OTelEndpoint string
+	// LogRetainDays and LogRetainCount prune old session logs at startup:
+	// those older than the given days, or beyond the newest count. 0
+	// disables either limit.
+	LogRetainDays  int
+	LogRetainCount int
+	// SummaryFile receives a JSON summary of the session at exit; empty
+	// puts it next to the session log.
+	SummaryFile string
+	// ReportFD and ReportFile receive the run report at exit (see
+	// writeReport); -1 and empty disable them.
+	ReportFD   int
+	ReportFile string
+	report     *os.File // ReportFD, opened by main
+	// LogDirMode is where the session log goes without --log-dir:
+	// logDirHome or logDirWorkspace (see resolveLogDir).
+	LogDirMode string
+	// LogPrompt is how much of each prompt the turn_start record keeps:
+	// "full", "hash" or "none".
+	LogPrompt string
+	// AgentStderrFile receives a verbatim copy of the agent's stderr,
+	// appended across turns. Empty disables the copy.
+	AgentStderrFile string
+	// Record receives a verbatim copy of the agent's stdout, every turn
+	// in order, for use as a test fixture. Empty disables the capture.
+	Record string
+	record *recorder // Record, opened by run
+	// Replay plays a recording (a --record capture or a session log)
+	// through the turn in place of a live agent. Empty runs the agent.
+	Replay string
+	// ReplaySpeed scales the pauses a session log's recv_ts give between
+	// replayed events; 0 replays without pauses.
+	ReplaySpeed float64
+	replay      *replaySource // Replay, opened by run
 
 	// Process
 	Process process.Config
 
+	// ConfigFiles are the config files that filled in flags, most
+	// specific first, and ConfigEnv the CURSOR_WRAP_* variables that did
+	// (see applyConfig).
+	ConfigFiles []string
+	ConfigEnv   []string
+
 	// Prompt input
 	PositionalPrompt string        // trailing arg, if any
+	PromptFile       string        // holds the first prompt when there is no trailing arg
 	PromptAfterHang  string        // automatic prompt after hang detection
 	MaxHangRetries   int           // max consecutive auto-retries after hang
 	PromptReader     *bufio.Reader // wraps os.Stdin
+	// HistoryFile keeps the prompts typed at a terminal in interactive
+	// mode, for the line editor to recall; empty with --no-history.
+	HistoryFile string
+	editor      *promptEditor // set by run when stdin is a terminal
+
+	// Batch is a file of prompts run one turn each, as -p runs one
+	// (see runBatch); BatchSession is whether they share a session,
+	// batchSessionFresh or batchSessionShared, and FailFast stops the
+	// batch at the first prompt that fails.
+	Batch        string
+	BatchSession string
+	FailFast     bool
+
+	// RetryOnAbnormalExit is how many times in a row a turn whose agent
+	// exited without a result is re-run with --resume.
+	RetryOnAbnormalExit int
+
+	// ResumeLast resumes the ResumeLast-th most recent session logged in
+	// Log.Dir (1 the latest), found by main before run (see resumeLast);
+	// 0 doesn't.
+	ResumeLast int
+
+	// flags holds every flag's value as parsed, with agentFlagsKey for
+	// the flags after "--", for a reload to tell what changed.
+	flags map[string]string
 }
 
 // parseFlags uses the stdlib flag package to parse CLI flags and trailing
@@ -43,41 +186,190 @@ type Config struct {
 // for pass-through to cursor-agent. The last non-flag argument (if any)
 // is treated as the positional prompt. Mode-dependent defaults (output
 // format, console log level) are applied after flag parsing based on
-// whether -p was set.
+// whether -p was set. It exits on --help, --version and bad flags.
 func parseFlags(args []string) Config {
-	fs := flag.NewFlagSet("cursor-wrap", flag.ExitOnError)
+	cfg, err := loadFlags(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.Is(err, errShowVersion):
+		fmt.Println(readBuildInfo())
+		os.Exit(0)
+	case errors.Is(err, errBadFlags):
+		os.Exit(1) // Parse has printed the error and usage
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "cursor-wrap: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+var (
+	// errShowVersion is loadFlags' error for --version.
+	errShowVersion = errors.New("version requested")
+	// errBadFlags wraps the flag package's parse errors, which it has
+	// already printed with the usage.
+	errBadFlags = errors.New("bad flags")
+)
+
+// loadFlags is parseFlags returning its errors rather than exiting, so a
+// reload (see reloader) can parse the same arguments again.
+func loadFlags(args []string) (Config, error) {
+	// Errors exit with code 1, as invalid settings do, rather than
+	// ExitOnError's 2, which is a hang's.
+	fs := flag.NewFlagSet("cursor-wrap", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: cursor-wrap [flags] [-- cursor-agent-flags...] [prompt]")
+		fs.PrintDefaults()
+		fmt.Fprint(fs.Output(), exitCodesHelp)
+	}
 
 	// Mode flags — register both -p and --print pointing to the same variable.
 	var printMode bool
 	fs.BoolVar(&printMode, "p", false, "Non-interactive mode: single prompt, exit after")
 	fs.BoolVar(&printMode, "print", false, "Non-interactive mode: single prompt, exit after")
 	outputFormat := fs.String("output-format", "", "Output format: stream-json | text")
+	wrapperEvents := fs.Bool("wrapper-events", false, "With stream-json, also output the wrapper's own events, such as wrapper_nonjson for lines cursor-agent writes that aren't JSON")
+	streamToolOutput := fs.Bool("stream-tool-output", false, "With text output, show the output running tools stream under their ⏳ lines")
+	showThinking := fs.Bool("show-thinking", false, "With text output, show the agent's thinking, dimmed, as each phase ends")
+	detach := fs.Bool("detach", false, "Run -p in the background, detached from the terminal; output goes to files next to the session log")
 
 	// Hang detection flags
 	idleTimeout := fs.Duration("idle-timeout", 60*time.Second, "Max silence with no open tool calls")
 	toolGrace := fs.Duration("tool-grace", 30*time.Second, "Extra time beyond a tool's declared timeout")
 	tickInterval := fs.Duration("tick-interval", 5*time.Second, "How often to check for hangs")
+	maxThinking := fs.Duration("max-thinking-duration", 0, "Max length of a single thinking phase before it counts as a hang (0 disables)")
+	turnTimeout := fs.Duration("turn-timeout", 0, "Max wall-clock time for a turn, however active the agent is, before it is killed (0 disables)")
+	nonJSONLiveness := fs.Bool("nonjson-liveness", false, "Count lines cursor-agent writes to stdout that aren't JSON as activity for hang detection")
+	estimateFactor := fs.Float64("estimate-factor", 0, "Deadline for a repeated shell command as a multiple of its longest earlier run (0 disables)")
+	var fatalPatterns regexpList
+	fs.Var(&fatalPatterns, "fatal-stderr-pattern", "Regexp on agent stderr that aborts the turn (repeatable; replaces the defaults, empty disables)")
+	maxOutputBytes := byteSize(256 << 20)
+	fs.Var(&maxOutputBytes, "max-output-bytes", "Max bytes read from cursor-agent's stdout in one turn before it is killed, e.g. 256M (0 disables)")
+	maxEventBytes := byteSize(64 << 20)
+	fs.Var(&maxEventBytes, "max-event-bytes", "Max size of a single cursor-agent event; a larger one is replaced by a short notice, its start kept in the session log, e.g. 16M (0 disables)")
+	eventBuffer := fs.Int("event-buffer", 64, "Events queued between reading cursor-agent's stdout and handling them")
+	hangDumpEvents := fs.Int("hang-dump-events", 10, "Number of the turn's last events to print to stderr when a hang is detected (0 disables)")
 
 	// Logging flags
 	logDir := fs.String("log-dir", "", "Directory for session log files")
+	logDirMode := fs.String("log-dir-mode", logDirHome, "Where session logs go without --log-dir: home (~/.cursor-wrap/logs) | workspace (.cursor-wrap/logs in --workspace, or the current directory)")
+	tag := fs.String("tag", "", "Label for the session, e.g. a runner name: recorded in every log record, the log file's name and the summary")
+	noLogFile := fs.Bool("no-log-file", false, "Don't write a session log; log to the console (and --log-syslog) only")
 	logLevel := fs.String("log-level", "", "Console log level: debug|info|warn|error")
+	fileLevel := fileLevelFlag{level: slog.LevelDebug, name: "debug"}
+	fs.Var(&fileLevel, "file-log-level", "Session log level: debug|info|warn|error, or no-raw-events for debug without the agent's raw events")
+	consoleLogFormat := fs.String("console-log-format", "text", "Console log format: text | json")
+	logTimeFormat := fs.String("log-time-format", logger.TimeMillis, "How JSON log records write time and recv_ts: millis (Unix milliseconds) | rfc3339")
+	var logMaxSize byteSize
+	fs.Var(&logMaxSize, "log-max-size", "Size at which the session log continues in a numbered part, e.g. 100M (0 disables rotation)")
+	otelEndpoint := fs.String("otel-endpoint", "", "OTLP/HTTP endpoint to export turns and tool calls to as trace spans, e.g. http://localhost:4318 (default: $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	var redactPatterns regexpList
+	fs.Var(&redactPatterns, "redact-pattern", "Regexp masked as [REDACTED:custom] in logged raw events, on top of the built-in token patterns (repeatable)")
+	logSync := syncModeFlag(logger.SyncCritical)
+	fs.Var(&logSync, "log-sync", "Which session log records are fsynced: always | critical (raw events and info and above) | never")
+	var logAsync asyncModeFlag
+	fs.Var(&logAsync, "log-async", "Write the session log from a background goroutine: off | block (wait when the queue is full) | drop (drop and count records when it is full)")
+	logQueueSize := fs.Int("log-queue-size", logger.DefaultQueueSize, "Records the --log-async queue holds")
+	logAsyncRawEvents := fs.Bool("log-async-raw-events", false, "Queue raw events too with --log-async, instead of writing them before they are processed")
+	splitLogs := fs.Bool("split-logs", false, "Write raw events and agent stderr to a …-events.jsonl file and every other record to a …-decisions.jsonl file")
+	newLogOnResume := fs.Bool("new-log-on-resume", false, "With --resume, start a new session log instead of appending to the session's existing one")
+	logSyslog := fs.Bool("log-syslog", false, "Also send wrapper decisions (info and above, no raw events) to syslog/journald")
+	logHTTPEndpoint := fs.String("log-http-endpoint", "", "Also POST session log records (no raw events) to this collector URL, in gzipped NDJSON batches")
+	logHTTPRawEvents := fs.Bool("log-http-raw-events", false, "Ship raw events too with --log-http-endpoint")
+	logHTTPMaxBuffer := byteSize(logger.DefaultHTTPBufferBytes)
+	fs.Var(&logHTTPMaxBuffer, "log-http-max-buffer", "Max bytes of records held for a slow or unreachable --log-http-endpoint before they are dropped, e.g. 8M")
+	logRetainDays := fs.Int("log-retain-days", 0, "Delete session logs older than this many days at startup (0 keeps them)")
+	logRetainCount := fs.Int("log-retain-count", 0, "Keep only this many of the newest session logs, counting the current one (0 keeps all)")
+	reportFD := fs.Int("report-fd", -1, "Inherited file descriptor to write a one-line JSON report of the run to at exit, for a program driving the wrapper")
+	reportFile := fs.String("report-file", "", "File to write a one-line JSON report of the run to at exit")
+	summaryFile := fs.String("summary-file", "", "File to write a JSON summary of the session to at exit (default: next to the session log, as .summary.json)")
+	logPrompt := fs.String("log-prompt", logPromptFull, "How much of each prompt the session log records: full (secrets masked) | hash (SHA-256) | none")
+	agentStderrFile := fs.String("agent-stderr-file", "", "File to append cursor-agent's stderr to verbatim (created on first output)")
+	record := fs.String("record", "", "File to capture cursor-agent's raw stdout to, verbatim, e.g. for a test fixture")
+	replayFile := fs.String("replay", "", "Play a --record capture or session log through the wrapper instead of running cursor-agent")
+	replaySpeed := fs.Float64("replay-speed", 1, "With --replay of a session log, multiple of the recorded pace to replay at (0: no pauses)")
 
 	// Prompt flags
+	batch := fs.String("batch", "", "File of prompts to run one after the other, a turn each, as -p runs one: a prompt per line, or per JSON line in a .jsonl file (implies -p)")
+	batchSession := fs.String("batch-session", batchSessionFresh, "With --batch, whether each prompt starts a session of its own or all resume one: fresh | shared")
+	failFast := fs.Bool("fail-fast", false, "With --batch, stop at the first prompt that fails instead of going on to the next")
+	promptFile := fs.String("prompt-file", "", "File holding the first prompt, read in full (the positional prompt, if any, wins; trailing whitespace is trimmed)")
+	historyFile := fs.String("history-file", "", "File prompts typed at a terminal are kept in, for the up arrow to recall in later sessions (default ~/.cursor-wrap/history)")
+	noHistory := fs.Bool("no-history", false, "Don't read or write the prompt history file; the up arrow recalls this session's prompts only")
 	promptAfterHang := fs.String("prompt-after-hang", "", "Prompt to send automatically after hang detection (interactive mode only)")
-	maxHangRetries := fs.Int("max-hang-retries", 3, "Max consecutive auto-retries after hang detection")
+	hangWebhook := fs.String("hang-webhook", "", "URL to POST a JSON notification to when a hang is detected, e.g. a Slack or Teams incoming webhook")
+	webhookAbnormalExit := fs.Bool("webhook-abnormal-exit", false, "Also notify --hang-webhook when cursor-agent exits without a result")
+	maxHangRetries := fs.Int("max-hang-retries", 3, "Max consecutive --prompt-after-hang retries after hang detection before giving up (0: give up at the first hang)")
+	retryOnAbnormalExit := fs.Int("retry-on-abnormal-exit", 0, "Times to re-run a turn with --resume when cursor-agent exits without a result")
 
 	// Process flags
-	agentBin := fs.String("agent-bin", "", "Path to cursor-agent binary")
+	agentBin := fs.String("agent-bin", "", "Path to cursor-agent binary (on the --remote host, if set)")
+	remote := fs.String("remote", "", "Run cursor-agent on user@host over ssh; paths and --env refer to that host")
+	sshBin := fs.String("ssh-bin", process.DefaultSSHBin, "ssh client used for --remote")
 	model := fs.String("model", "", "Model to pass to cursor-agent")
 	workspace := fs.String("workspace", "", "Workspace directory for cursor-agent")
+	cwd := fs.String("cwd", "", "Working directory for the cursor-agent process (default: --workspace if set)")
 	force := fs.Bool("force", true, "Pass --force to cursor-agent")
 	resume := fs.String("resume", "", "Session ID to resume from a previous session")
+	var resumeLast nthFlag
+	fs.Var(&resumeLast, "resume-last", "Resume the most recent session with a log in the log directory (with --tag, tagged so); --resume-last=N picks the Nth most recent")
+	var env envList
+	fs.Var(&env, "env", "KEY=VALUE to set in cursor-agent's environment (repeatable; later values win)")
+	fs.Var(envFileFlag{&env}, "env-file", "Dotenv file of variables to set in cursor-agent's environment (repeatable)")
+	var promptFileThreshold byteSize // off until @file delivery is checked against a real agent
+	fs.Var(&promptFileThreshold, "prompt-file-threshold", "Prompt size above which the prompt is passed to cursor-agent as an @file reference instead of on stdin, e.g. 512K; the file goes in the workspace's .cursor-wrap/prompts (0 = always stdin)")
+	promptWriteTimeout := fs.Duration("prompt-write-timeout", 30*time.Second, "Max time for cursor-agent to read the prompt from stdin before it is killed (0 waits indefinitely)")
+	noPreflight := fs.Bool("no-preflight", false, "Skip checking the agent binary and login before the first turn")
+	hashAgentBin := fs.Bool("hash-agent-bin", false, "Checksum the cursor-agent binary each turn to spot in-place updates (reads the whole file)")
+	startRetries := fs.Int("start-retries", 2, "Retries after a transient failure to spawn cursor-agent (e.g. EAGAIN)")
+	startRetryDelay := fs.Duration("start-retry-delay", 500*time.Millisecond, "Initial backoff between start retries; doubles each retry")
+	var limitMem byteSize
+	fs.Var(&limitMem, "limit-mem", "Memory limit for cursor-agent's data and heap (RLIMIT_DATA), e.g. 4G (0 = no limit; Linux only)")
+	limitCPU := fs.Duration("limit-cpu", 0, "CPU-time limit for cursor-agent (0 = no limit; Linux only)")
+	limitNofile := fs.Int64("limit-nofile", 0, "Open file descriptor limit for cursor-agent (0 = no limit; Linux only)")
+	nice := fs.Int("nice", 0, "Nice value for cursor-agent and the tools it runs (0 = inherit)")
+	var ioClass ioClassFlag
+	fs.Var(&ioClass, "ionice-class", "I/O scheduling class for cursor-agent: best-effort | idle (Linux only; default inherit)")
+	pidFile := fs.String("pidfile", "", "File to hold the running cursor-agent's pid (rewritten each turn, removed at exit)")
+	noPdeathsig := fs.Bool("no-pdeathsig", false, "Leave cursor-agent running if cursor-wrap dies (by default it gets SIGTERM; Linux only)")
+	killSignal := killSignalFlag(syscall.SIGTERM)
+	fs.Var(&killSignal, "kill-signal", "First signal sent to stop cursor-agent before SIGKILL: term | int (int lets it flush a final result)")
+	killGrace := fs.Duration("kill-grace", process.DefaultKillGrace, "Time between --kill-signal and SIGKILL when stopping cursor-agent (0 = SIGKILL immediately)")
+
+	// Config file flags
+	configFile := fs.String("config", "", "Config file to read instead of ~/.config/cursor-wrap/config.toml and the workspace's .cursor-wrap.toml")
+	noConfig := fs.Bool("no-config", false, "Don't read any config file")
+
+	var showVersion bool
+	fs.BoolVar(&showVersion, "version", false, "Print the version and build information, and exit")
+	fs.BoolVar(&showVersion, "V", false, "Print the version and build information, and exit")
 
 	// Split args at "--" separator before parsing. Everything after "--"
 	// goes to cursor-agent as ExtraFlags.
 	wrapperArgs, extraFlags := splitAtSeparator(args)
 
-	fs.Parse(wrapperArgs)
+	if err := fs.Parse(wrapperArgs); errors.Is(err, flag.ErrHelp) {
+		return Config{}, err
+	} else if err != nil {
+		return Config{}, fmt.Errorf("%w: %w", errBadFlags, err)
+	}
+
+	if showVersion {
+		return Config{}, errShowVersion
+	}
+
+	// CURSOR_WRAP_* variables, then config files, fill in what the
+	// command line doesn't set.
+	sources, err := applyConfig(fs, *configFile, *noConfig)
+	if err != nil {
+		return Config{}, err
+	}
+	if extraFlags == nil {
+		extraFlags = sources.agentFlags
+	}
+	flags := flagValues(fs)
+	flags[agentFlagsKey] = strings.Join(extraFlags, " ")
 
 	// Remaining args after flag parsing: the positional prompt.
 	remaining := fs.Args()
@@ -86,8 +378,12 @@ func parseFlags(args []string) Config {
 		positionalPrompt = strings.Join(remaining, " ")
 	}
 
-	// Resolve agent-bin default.
+	// Resolve agent-bin default. A remote agent is found on the remote
+	// PATH, so there is nothing to look up locally.
 	agentBinResolved := *agentBin
+	if agentBinResolved == "" && *remote != "" {
+		agentBinResolved = "cursor-agent"
+	}
 	if agentBinResolved == "" {
 		if p, err := exec.LookPath("cursor-agent"); err == nil {
 			agentBinResolved = p
@@ -96,16 +392,15 @@ func parseFlags(args []string) Config {
 		}
 	}
 
-	// Resolve log-dir default.
-	logDirResolved := *logDir
-	if logDirResolved == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			home = "."
-		}
-		logDirResolved = filepath.Join(home, ".cursor-wrap", "logs")
+	logDirResolved := resolveLogDir(*logDir, *logDirMode, *workspace, *remote)
+	historyResolved := ""
+	if !*noHistory {
+		historyResolved = resolveHistoryFile(*historyFile)
 	}
 
+	// A batch runs unattended, each prompt as -p would.
+	printMode = printMode || *batch != ""
+
 	// Apply mode-dependent defaults.
 	resolvedOutputFormat := *outputFormat
 	if resolvedOutputFormat == "" {
@@ -125,30 +420,132 @@ func parseFlags(args []string) Config {
 		}
 	}
 
+	// The agent resolves some tool paths against its own cwd rather than
+	// --workspace, so run it in the workspace unless told otherwise.
+	resolvedCwd := *cwd
+	if resolvedCwd == "" {
+		resolvedCwd = *workspace
+	}
+
+	// RLIMIT_CPU has whole-second granularity; round up.
+	cpuSeconds := int64((*limitCPU + time.Second - 1) / time.Second)
+
+	redactRules := logger.DefaultRedactRules()
+	for _, re := range redactPatterns.patterns {
+		redactRules = append(redactRules, logger.RedactRule{Name: "custom", Pattern: re})
+	}
+
+	resolvedFatalPatterns := defaultFatalStderrPatterns()
+	if fatalPatterns.set {
+		resolvedFatalPatterns = fatalPatterns.patterns
+	}
+
 	return Config{
-		Print:        printMode,
-		OutputFormat: resolvedOutputFormat,
-		IdleTimeout:  *idleTimeout,
-		ToolGrace:    *toolGrace,
-		TickInterval: *tickInterval,
+		Print:               printMode,
+		OutputFormat:        resolvedOutputFormat,
+		WrapperEvents:       *wrapperEvents,
+		StreamToolOutput:    *streamToolOutput,
+		ShowThinking:        *showThinking,
+		Detach:              *detach,
+		IdleTimeout:         *idleTimeout,
+		ToolGrace:           *toolGrace,
+		TickInterval:        *tickInterval,
+		MaxThinking:         *maxThinking,
+		TurnTimeout:         *turnTimeout,
+		NonJSONLiveness:     *nonJSONLiveness,
+		EstimateFactor:      *estimateFactor,
+		FatalStderrPatterns: resolvedFatalPatterns,
+		HangWebhook:         *hangWebhook,
+		WebhookAbnormalExit: *webhookAbnormalExit,
+		MaxOutputBytes:      int64(maxOutputBytes),
+		MaxEventBytes:       int64(maxEventBytes),
+		EventBuffer:         *eventBuffer,
+		HangDumpEvents:      *hangDumpEvents,
+		SkipPreflight:       *noPreflight,
+		StartRetries:        *startRetries,
+		StartRetryDelay:     *startRetryDelay,
+		PidFile:             *pidFile,
+		HashAgentBin:        *hashAgentBin,
+		RetryOnAbnormalExit: *retryOnAbnormalExit,
+		Batch:               *batch,
+		BatchSession:        *batchSession,
+		FailFast:            *failFast,
+		ResumeLast:          int(resumeLast),
+		PromptFileThreshold: int64(promptFileThreshold),
+		AgentStderrFile:     *agentStderrFile,
+		Record:              *record,
+		Replay:              *replayFile,
+		ReplaySpeed:         *replaySpeed,
+		SummaryFile:         *summaryFile,
+		ReportFD:            *reportFD,
+		ReportFile:          *reportFile,
+		LogPrompt:           *logPrompt,
+		LogDirMode:          *logDirMode,
+		OTelEndpoint:        *otelEndpoint,
+		LogRetainDays:       *logRetainDays,
+		LogRetainCount:      *logRetainCount,
 		Log: logger.LogConfig{
-			Dir:          logDirResolved,
-			ConsoleLevel: resolvedConsoleLevel,
-			FileLevel:    slog.LevelDebug,
+			WrapperVersion:  wrapperVersion(),
+			Dir:             logDirResolved,
+			NoFile:          *noLogFile,
+			Tag:             *tag,
+			ConsoleLevel:    resolvedConsoleLevel,
+			ConsoleFormat:   *consoleLogFormat,
+			TimeFormat:      *logTimeFormat,
+			FileLevel:       fileLevel.level,
+			NoRawEvents:     fileLevel.noRawEvents,
+			MaxSize:         int64(logMaxSize),
+			Redact:          redactRules,
+			Sync:            logger.SyncMode(logSync),
+			Async:           logger.AsyncMode(logAsync),
+			QueueSize:       *logQueueSize,
+			AsyncRawEvents:  *logAsyncRawEvents,
+			SplitLogs:       *splitLogs,
+			Syslog:          *logSyslog,
+			HTTPEndpoint:    *logHTTPEndpoint,
+			HTTPRawEvents:   *logHTTPRawEvents,
+			HTTPMaxBuffer:   int(logHTTPMaxBuffer),
+			ResumeSessionID: *resume,
+			NewLogOnResume:  *newLogOnResume,
 		},
 		Process: process.Config{
-			AgentBin:   agentBinResolved,
-			Model:      *model,
-			Workspace:  *workspace,
-			ExtraFlags: extraFlags,
-			Force:      *force,
-			SessionID:  *resume,
+			AgentBin:           agentBinResolved,
+			Model:              *model,
+			Workspace:          *workspace,
+			Cwd:                resolvedCwd,
+			ExtraFlags:         extraFlags,
+			Force:              *force,
+			SessionID:          *resume,
+			Remote:             *remote,
+			SSHBin:             *sshBin,
+			KillSignal:         syscall.Signal(killSignal),
+			PromptWriteTimeout: *promptWriteTimeout,
+			Nice:               *nice,
+			IOClass:            process.IOClass(ioClass),
+			KillGrace:          *killGrace,
+			NoPdeathsig:        *noPdeathsig,
+			MaxCPUSeconds:      cpuSeconds,
+			MaxMemoryBytes:     int64(limitMem),
+			MaxOpenFiles:       *limitNofile,
+			Env:                env.entries,
 		},
+		ConfigFiles:      sources.files,
+		ConfigEnv:        sources.env,
 		PositionalPrompt: positionalPrompt,
+		PromptFile:       *promptFile,
 		PromptAfterHang:  *promptAfterHang,
 		MaxHangRetries:   *maxHangRetries,
 		PromptReader:     bufio.NewReader(os.Stdin),
-	}
+		HistoryFile:      historyResolved,
+		flags:            flags,
+	}, nil
+}
+
+// flagValues returns the value of every flag in fs, as it prints it.
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) { values[f.Name] = f.Value.String() })
+	return values
 }
 
 // splitAtSeparator splits args at the first "--" separator.
@@ -162,6 +559,292 @@ func splitAtSeparator(args []string) (before, after []string) {
 	return args, nil
 }
 
+// defaultFatalStderrPatterns covers the stderr messages cursor-agent prints
+// right before idling indefinitely.
+func defaultFatalStderrPatterns() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile(`(?i)not authenticated`),
+		regexp.MustCompile(`(?i)rate limit exceeded`),
+	}
+}
+
+// regexpList is a repeatable flag.Value that compiles each occurrence.
+// Empty values are accepted but ignored, so a lone empty flag disables
+// the defaults.
+type regexpList struct {
+	patterns []*regexp.Regexp
+	set      bool
+}
+
+func (l *regexpList) String() string {
+	if l == nil {
+		return ""
+	}
+	parts := make([]string, len(l.patterns))
+	for i, re := range l.patterns {
+		parts[i] = re.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (l *regexpList) Set(s string) error {
+	l.set = true
+	if s == "" {
+		return nil
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return err
+	}
+	l.patterns = append(l.patterns, re)
+	return nil
+}
+
+// byteSize is a flag.Value for sizes such as 512M or 4G. Suffixes K, M,
+// G and T (optionally followed by B) are binary multiples.
+type byteSize int64
+
+func (b *byteSize) String() string {
+	if b == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(s string) error {
+	n, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+// parseByteSize parses a byte count with an optional binary suffix.
+func parseByteSize(s string) (int64, error) {
+	num := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	shift := 0
+	if num != "" {
+		switch num[len(num)-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		case 'T':
+			shift = 40
+		}
+	}
+	if shift > 0 {
+		num = num[:len(num)-1]
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("size %q overflows", s)
+	}
+	return n << shift, nil
+}
+
+// nthFlag is a flag.Value for flags such as --resume-last that pick an
+// nth item: given bare it is 1, given =N it is N (≥ 1), and unset 0.
+type nthFlag int
+
+func (n *nthFlag) String() string {
+	if n == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*n))
+}
+
+func (n *nthFlag) Set(s string) error {
+	switch s {
+	case "true":
+		*n = 1
+	case "false":
+		*n = 0
+	default:
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 {
+			return fmt.Errorf("invalid count %q: want a number from 1", s)
+		}
+		*n = nthFlag(v)
+	}
+	return nil
+}
+
+// IsBoolFlag lets the flag be given bare.
+func (n *nthFlag) IsBoolFlag() bool { return true }
+
+// killSignalFlag is the flag.Value for --kill-signal. It accepts the
+// signal's short name, with or without the SIG prefix.
+type killSignalFlag syscall.Signal
+
+func (k *killSignalFlag) String() string {
+	if k == nil || *k == 0 {
+		return "term"
+	}
+	return strings.ToLower(strings.TrimPrefix(process.SignalName(syscall.Signal(*k)), "SIG"))
+}
+
+func (k *killSignalFlag) Set(s string) error {
+	switch strings.TrimPrefix(strings.ToUpper(s), "SIG") {
+	case "TERM":
+		*k = killSignalFlag(syscall.SIGTERM)
+	case "INT":
+		*k = killSignalFlag(syscall.SIGINT)
+	default:
+		return fmt.Errorf("unsupported kill signal %q: want term or int", s)
+	}
+	return nil
+}
+
+// ioClassFlag is the flag.Value for --ionice-class.
+type ioClassFlag process.IOClass
+
+func (c *ioClassFlag) String() string {
+	if c == nil {
+		return ""
+	}
+	return process.IOClass(*c).String()
+}
+
+func (c *ioClassFlag) Set(s string) error {
+	class, err := process.ParseIOClass(s)
+	if err != nil {
+		return err
+	}
+	*c = ioClassFlag(class)
+	return nil
+}
+
+// syncModeFlag is the flag.Value for --log-sync.
+type syncModeFlag logger.SyncMode
+
+func (m *syncModeFlag) String() string {
+	if m == nil {
+		return ""
+	}
+	return logger.SyncMode(*m).String()
+}
+
+func (m *syncModeFlag) Set(s string) error {
+	mode, err := logger.ParseSyncMode(s)
+	if err != nil {
+		return err
+	}
+	*m = syncModeFlag(mode)
+	return nil
+}
+
+// asyncModeFlag is the flag.Value for --log-async.
+type asyncModeFlag logger.AsyncMode
+
+func (m *asyncModeFlag) String() string {
+	if m == nil {
+		return ""
+	}
+	return logger.AsyncMode(*m).String()
+}
+
+func (m *asyncModeFlag) Set(s string) error {
+	mode, err := logger.ParseAsyncMode(s)
+	if err != nil {
+		return err
+	}
+	*m = asyncModeFlag(mode)
+	return nil
+}
+
+// fileLevelNoRaw is the --file-log-level value that keeps every record
+// but raw events.
+const fileLevelNoRaw = "no-raw-events"
+
+// fileLevelFlag is the flag.Value for --file-log-level: a level as for
+// --log-level, or fileLevelNoRaw.
+type fileLevelFlag struct {
+	name        string
+	level       slog.Level
+	noRawEvents bool
+}
+
+func (f *fileLevelFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.name
+}
+
+func (f *fileLevelFlag) Set(s string) error {
+	switch strings.ToLower(s) {
+	case fileLevelNoRaw:
+		*f = fileLevelFlag{name: s, level: slog.LevelDebug, noRawEvents: true}
+	case "debug", "info", "warn", "warning", "error":
+		*f = fileLevelFlag{name: s, level: parseLogLevel(s)}
+	default:
+		return fmt.Errorf("unsupported file log level %q: want debug, info, warn, error or %s", s, fileLevelNoRaw)
+	}
+	return nil
+}
+
+// checkDir returns an error unless path names an existing directory.
+func checkDir(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s: not a directory", path)
+	}
+	return nil
+}
+
+// --log-dir-mode values.
+const (
+	logDirHome      = "home"
+	logDirWorkspace = "workspace"
+)
+
+// resolveLogDir returns the session log directory: --log-dir if given,
+// else .cursor-wrap/logs in the home directory or, in workspace mode, in
+// the workspace, so logs travel with the checkout. Without --workspace
+// the agent works in the current directory, so that is the workspace. A
+// --remote workspace is on another machine: logs then stay in home.
+func resolveLogDir(logDir, mode, workspace, remote string) string {
+	if logDir != "" {
+		return logDir
+	}
+	if mode == logDirWorkspace && remote == "" {
+		root := workspace
+		if root == "" {
+			root = "."
+		}
+		if abs, err := filepath.Abs(root); err == nil {
+			return filepath.Join(abs, ".cursor-wrap", "logs")
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".cursor-wrap", "logs")
+}
+
+// resolveHistoryFile returns --history-file, else ~/.cursor-wrap/history.
+func resolveHistoryFile(historyFile string) string {
+	if historyFile != "" {
+		return historyFile
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".cursor-wrap", "history")
+}
+
 // parseLogLevel maps a log level string to slog.Level.
 // Returns slog.LevelInfo for unrecognized values.
 func parseLogLevel(s string) slog.Level {
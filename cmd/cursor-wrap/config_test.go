@@ -2,8 +2,16 @@ package main
 
 import (
 	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/process"
 )
 
 func TestParseFlags_DefaultsPrintMode(t *testing.T) {
@@ -222,6 +230,62 @@ func TestParseFlags_LogDirDefault(t *testing.T) {
 	}
 }
 
+func TestParseFlags_LogDirMode(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	homeLogs := filepath.Join(home, ".cursor-wrap", "logs")
+	ws := t.TempDir()
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"default is home", nil, homeLogs},
+		{"home", []string{"--log-dir-mode", "home", "--workspace", ws}, homeLogs},
+		{"workspace", []string{"--log-dir-mode", "workspace", "--workspace", ws}, filepath.Join(ws, ".cursor-wrap", "logs")},
+		{"relative workspace", []string{"--log-dir-mode", "workspace", "--workspace", "testdata"}, filepath.Join(cwd, "testdata", ".cursor-wrap", "logs")},
+		{"workspace without --workspace", []string{"--log-dir-mode", "workspace"}, filepath.Join(cwd, ".cursor-wrap", "logs")},
+		{"workspace with a remote agent", []string{"--log-dir-mode", "workspace", "--workspace", ws, "--remote", "devbox"}, homeLogs},
+		{"explicit log dir wins", []string{"--log-dir-mode", "workspace", "--workspace", ws, "--log-dir", "/tmp/testlogs"}, "/tmp/testlogs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseFlags(tt.args)
+			if cfg.Log.Dir != tt.want {
+				t.Errorf("Log.Dir = %q, want %q", cfg.Log.Dir, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitignoreHint(t *testing.T) {
+	ws := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dir, workspace, want string
+	}{
+		{filepath.Join(ws, ".cursor-wrap", "logs"), ws, "/.cursor-wrap/"},
+		{filepath.Join(cwd, ".cursor-wrap", "logs"), "", "/.cursor-wrap/"},
+		{"build/logs", "", "/build/"},
+		{filepath.Join(t.TempDir(), "logs"), ws, ""},
+		{ws, ws, ""},
+	}
+	for _, tt := range tests {
+		if got := gitignoreHint(tt.dir, tt.workspace); got != tt.want {
+			t.Errorf("gitignoreHint(%q, %q) = %q, want %q", tt.dir, tt.workspace, got, tt.want)
+		}
+	}
+}
+
 func TestParseFlags_PromptAfterHang(t *testing.T) {
 	cfg := parseFlags([]string{"--prompt-after-hang", "continue", "hello"})
 	if cfg.PromptAfterHang != "continue" {
@@ -250,6 +314,296 @@ func TestParseFlags_MaxHangRetries_Default(t *testing.T) {
 	}
 }
 
+func TestParseFlags_MaxThinkingDuration(t *testing.T) {
+	cfg := parseFlags([]string{"--max-thinking-duration", "10m", "hello"})
+	if cfg.MaxThinking != 10*time.Minute {
+		t.Errorf("MaxThinking = %v, want %v", cfg.MaxThinking, 10*time.Minute)
+	}
+	if cfg := parseFlags([]string{}); cfg.MaxThinking != 0 {
+		t.Errorf("default MaxThinking = %v, want 0", cfg.MaxThinking)
+	}
+}
+
+func TestParseFlags_EstimateFactor(t *testing.T) {
+	cfg := parseFlags([]string{"--estimate-factor", "2.5", "hello"})
+	if cfg.EstimateFactor != 2.5 {
+		t.Errorf("EstimateFactor = %v, want %v", cfg.EstimateFactor, 2.5)
+	}
+}
+
+func TestParseFlags_EstimateFactor_DefaultDisabled(t *testing.T) {
+	cfg := parseFlags([]string{})
+	if cfg.EstimateFactor != 0 {
+		t.Errorf("EstimateFactor = %v, want 0", cfg.EstimateFactor)
+	}
+}
+
+func TestParseFlags_KillGrace(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want time.Duration
+	}{
+		{"default", nil, process.DefaultKillGrace},
+		{"explicit", []string{"--kill-grace", "30s"}, 30 * time.Second},
+		{"immediate", []string{"--kill-grace", "0"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseFlags(tt.args)
+			if cfg.Process.KillGrace != tt.want {
+				t.Errorf("Process.KillGrace = %v, want %v", cfg.Process.KillGrace, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFlags_PromptWriteTimeout(t *testing.T) {
+	if got := parseFlags(nil).Process.PromptWriteTimeout; got != 30*time.Second {
+		t.Errorf("default Process.PromptWriteTimeout = %v, want 30s", got)
+	}
+	if got := parseFlags([]string{"--prompt-write-timeout", "0"}).Process.PromptWriteTimeout; got != 0 {
+		t.Errorf("Process.PromptWriteTimeout = %v, want 0", got)
+	}
+}
+
+func TestParseFlags_Priority(t *testing.T) {
+	cfg := parseFlags(nil)
+	if cfg.Process.Nice != 0 || cfg.Process.IOClass != process.IOClassNone {
+		t.Errorf("default priority = nice %d, %v; want inherited", cfg.Process.Nice, cfg.Process.IOClass)
+	}
+
+	cfg = parseFlags([]string{"--nice", "10", "--ionice-class", "idle"})
+	if cfg.Process.Nice != 10 {
+		t.Errorf("Process.Nice = %d, want 10", cfg.Process.Nice)
+	}
+	if cfg.Process.IOClass != process.IOClassIdle {
+		t.Errorf("Process.IOClass = %v, want idle", cfg.Process.IOClass)
+	}
+}
+
+func TestIOClassFlag_Unsupported(t *testing.T) {
+	var c ioClassFlag
+	if err := c.Set("realtime"); err == nil {
+		t.Fatal("expected error for realtime class")
+	}
+}
+
+func TestParseFlags_LogSync(t *testing.T) {
+	if cfg := parseFlags(nil); cfg.Log.Sync != logger.SyncCritical {
+		t.Errorf("default Log.Sync = %v, want critical", cfg.Log.Sync)
+	}
+	if cfg := parseFlags([]string{"--log-sync", "never"}); cfg.Log.Sync != logger.SyncNever {
+		t.Errorf("Log.Sync = %v, want never", cfg.Log.Sync)
+	}
+	var m syncModeFlag
+	if err := m.Set("sometimes"); err == nil {
+		t.Fatal("expected error for an unknown sync mode")
+	}
+}
+
+func TestParseFlags_LogAsync(t *testing.T) {
+	if cfg := parseFlags(nil); cfg.Log.Async != logger.AsyncOff || cfg.Log.QueueSize != logger.DefaultQueueSize {
+		t.Errorf("default Log.Async = %v, QueueSize = %d", cfg.Log.Async, cfg.Log.QueueSize)
+	}
+	cfg := parseFlags([]string{"--log-async", "drop", "--log-queue-size", "64", "--log-async-raw-events"})
+	if cfg.Log.Async != logger.AsyncDrop || cfg.Log.QueueSize != 64 || !cfg.Log.AsyncRawEvents {
+		t.Errorf("Log = %+v", cfg.Log)
+	}
+	var m asyncModeFlag
+	if err := m.Set("sometimes"); err == nil {
+		t.Fatal("expected error for an unknown async mode")
+	}
+}
+
+func TestParseFlags_FileLogLevel(t *testing.T) {
+	tests := []struct {
+		args      []string
+		wantLevel slog.Level
+		wantNoRaw bool
+	}{
+		{nil, slog.LevelDebug, false},
+		{[]string{"--file-log-level", "info"}, slog.LevelInfo, false},
+		{[]string{"--file-log-level", "WARN"}, slog.LevelWarn, false},
+		{[]string{"--file-log-level", "no-raw-events"}, slog.LevelDebug, true},
+		// Independent of the console level.
+		{[]string{"--log-level", "debug", "--file-log-level", "error"}, slog.LevelError, false},
+	}
+	for _, tt := range tests {
+		cfg := parseFlags(tt.args)
+		if cfg.Log.FileLevel != tt.wantLevel || cfg.Log.NoRawEvents != tt.wantNoRaw {
+			t.Errorf("%v: FileLevel = %v, NoRawEvents = %v; want %v, %v", tt.args, cfg.Log.FileLevel, cfg.Log.NoRawEvents, tt.wantLevel, tt.wantNoRaw)
+		}
+	}
+	if cfg := parseFlags([]string{"--file-log-level", "error"}); cfg.Log.ConsoleLevel != slog.LevelWarn {
+		t.Errorf("ConsoleLevel = %v, want the default warn", cfg.Log.ConsoleLevel)
+	}
+	var f fileLevelFlag
+	if err := f.Set("trace"); err == nil {
+		t.Fatal("expected error for an unknown file log level")
+	}
+}
+
+func TestParseFlags_Remote(t *testing.T) {
+	cfg := parseFlags([]string{"--remote", "me@gpu-box"})
+	if cfg.Process.Remote != "me@gpu-box" {
+		t.Errorf("Process.Remote = %q, want me@gpu-box", cfg.Process.Remote)
+	}
+	if cfg.Process.SSHBin != process.DefaultSSHBin {
+		t.Errorf("Process.SSHBin = %q, want %q", cfg.Process.SSHBin, process.DefaultSSHBin)
+	}
+	// The agent is looked up on the remote PATH, not resolved locally.
+	if cfg.Process.AgentBin != "cursor-agent" {
+		t.Errorf("Process.AgentBin = %q, want cursor-agent", cfg.Process.AgentBin)
+	}
+}
+
+func TestParseFlags_KillSignal(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want syscall.Signal
+	}{
+		{"default", nil, syscall.SIGTERM},
+		{"int", []string{"--kill-signal", "int"}, syscall.SIGINT},
+		{"SIGINT", []string{"--kill-signal", "SIGINT"}, syscall.SIGINT},
+		{"term", []string{"--kill-signal", "term"}, syscall.SIGTERM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseFlags(tt.args)
+			if cfg.Process.KillSignal != tt.want {
+				t.Errorf("Process.KillSignal = %v, want %v", cfg.Process.KillSignal, tt.want)
+			}
+		})
+	}
+}
+
+func TestKillSignalFlag_Unsupported(t *testing.T) {
+	var k killSignalFlag
+	if err := k.Set("kill"); err == nil {
+		t.Fatal("expected error for unsupported signal")
+	}
+}
+
+func TestParseFlags_EnvAndEnvFileInOrder(t *testing.T) {
+	path := writeEnvFile(t, "A=file\nB=file\n")
+	cfg := parseFlags([]string{"--env", "A=flag", "--env-file", path, "--env", "B=flag"})
+	want := []string{"A=flag", "A=file", "B=file", "B=flag"}
+	if !slices.Equal(cfg.Process.Env, want) {
+		t.Errorf("Process.Env = %q, want %q", cfg.Process.Env, want)
+	}
+}
+
+func TestParseFlags_EnvDefaultEmpty(t *testing.T) {
+	if cfg := parseFlags([]string{}); cfg.Process.Env != nil {
+		t.Errorf("Process.Env = %q, want nil", cfg.Process.Env)
+	}
+}
+
+func TestParseFlags_Cwd(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"unset", nil, ""},
+		{"explicit", []string{"--cwd", "/srv/agent"}, "/srv/agent"},
+		{"defaults to workspace", []string{"--workspace", "/home/user/project"}, "/home/user/project"},
+		{"explicit beats workspace", []string{"--workspace", "/home/user/project", "--cwd", "/srv/agent"}, "/srv/agent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseFlags(tt.args)
+			if cfg.Process.Cwd != tt.want {
+				t.Errorf("Process.Cwd = %q, want %q", cfg.Process.Cwd, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"directory", dir, ""},
+		{"missing", filepath.Join(dir, "nope"), "no such file or directory"},
+		{"file", file, "not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDir(tt.path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkDir: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("checkDir error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFlags_ResourceLimits(t *testing.T) {
+	cfg := parseFlags([]string{"--limit-mem", "4G", "--limit-cpu", "90500ms", "--limit-nofile", "256"})
+	if cfg.Process.MaxMemoryBytes != 4<<30 {
+		t.Errorf("MaxMemoryBytes = %d, want %d", cfg.Process.MaxMemoryBytes, int64(4<<30))
+	}
+	if cfg.Process.MaxCPUSeconds != 91 {
+		t.Errorf("MaxCPUSeconds = %d, want 91 (rounded up)", cfg.Process.MaxCPUSeconds)
+	}
+	if cfg.Process.MaxOpenFiles != 256 {
+		t.Errorf("MaxOpenFiles = %d, want 256", cfg.Process.MaxOpenFiles)
+	}
+
+	cfg = parseFlags([]string{})
+	if cfg.Process.MaxMemoryBytes != 0 || cfg.Process.MaxCPUSeconds != 0 || cfg.Process.MaxOpenFiles != 0 {
+		t.Errorf("expected no limits by default, got %+v", cfg.Process)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"512K", 512 << 10, false},
+		{"512m", 512 << 20, false},
+		{"4G", 4 << 30, false},
+		{"4GB", 4 << 30, false},
+		{"1T", 1 << 40, false},
+		{"", 0, true},
+		{"G", 0, true},
+		{"-1G", 0, true},
+		{"1.5G", 0, true},
+		{"9999999T", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseByteSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseByteSize(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseByteSize(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseFlags_ResumeFlag(t *testing.T) {
 	cfg := parseFlags([]string{"-p", "--resume", "sess-abc-123", "hello"})
 	if cfg.Process.SessionID != "sess-abc-123" {
@@ -264,6 +618,40 @@ func TestParseFlags_ResumeFlag_Empty(t *testing.T) {
 	}
 }
 
+func TestParseFlags_FatalStderrPatterns_Default(t *testing.T) {
+	cfg := parseFlags([]string{})
+	if len(cfg.FatalStderrPatterns) != len(defaultFatalStderrPatterns()) {
+		t.Fatalf("got %d patterns, want the defaults", len(cfg.FatalStderrPatterns))
+	}
+}
+
+func TestParseFlags_FatalStderrPatterns_Repeatable(t *testing.T) {
+	cfg := parseFlags([]string{
+		"--fatal-stderr-pattern", "quota",
+		"--fatal-stderr-pattern", "^panic:",
+	})
+	if len(cfg.FatalStderrPatterns) != 2 {
+		t.Fatalf("got %d patterns, want 2", len(cfg.FatalStderrPatterns))
+	}
+	if cfg.FatalStderrPatterns[1].String() != "^panic:" {
+		t.Errorf("pattern[1] = %q, want %q", cfg.FatalStderrPatterns[1], "^panic:")
+	}
+}
+
+func TestParseFlags_FatalStderrPatterns_EmptyDisables(t *testing.T) {
+	cfg := parseFlags([]string{"--fatal-stderr-pattern", ""})
+	if len(cfg.FatalStderrPatterns) != 0 {
+		t.Errorf("got %d patterns, want none", len(cfg.FatalStderrPatterns))
+	}
+}
+
+func TestRegexpList_InvalidPattern(t *testing.T) {
+	var l regexpList
+	if err := l.Set("(unclosed"); err == nil {
+		t.Fatal("expected error for invalid regexp")
+	}
+}
+
 // --- splitAtSeparator tests ---
 
 func TestSplitAtSeparator_NoSeparator(t *testing.T) {
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// envPrefix starts the environment variables that stand in for flags:
+// CURSOR_WRAP_IDLE_TIMEOUT for --idle-timeout, and so on for every flag.
+const envPrefix = "CURSOR_WRAP_"
+
+// envName returns the environment variable for the flag name.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// envLayer returns the settings the environment makes for fs's flags. An
+// empty variable is ignored, as if unset. A repeatable flag takes one
+// value, and CURSOR_WRAP_AGENT_FLAGS the flags after "--", split at
+// spaces. -p is CURSOR_WRAP_PRINT; CURSOR_WRAP_CONFIG and
+// CURSOR_WRAP_NO_CONFIG are read by applyConfig before the files are,
+// and --version has no variable.
+func envLayer(fs *flag.FlagSet) configLayer {
+	var layer configLayer
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "p" || commandLineOnly[f.Name] {
+			return
+		}
+		if v := os.Getenv(envName(f.Name)); v != "" {
+			layer.settings = append(layer.settings, setting{name: f.Name, values: []string{v}})
+		}
+	})
+	if v := os.Getenv(envName(agentFlagsKey)); v != "" {
+		layer.settings = append(layer.settings, setting{name: agentFlagsKey, values: strings.Fields(v)})
+	}
+	return layer
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Config files hold flag settings, one per line, in a subset of TOML:
+//
+//	# ~/.config/cursor-wrap/config.toml
+//	idle-timeout = "90s"
+//	model = "gpt-5"
+//	stream-tool-output = true
+//	env = ["GOFLAGS=-mod=mod", "CI=1"]
+//	agent-flags = ["--approve-mcps"]
+//
+// A key is a flag's name (underscores may stand for its dashes), or
+// agent-flags for the flags after "--"; a value is a string, a number, a
+// boolean, or for repeatable flags an array of them. Durations and sizes
+// are strings. Tables aren't supported.
+const (
+	configFileName          = "config.toml"       // in the user's config dir, under cursor-wrap/
+	workspaceConfigFileName = ".cursor-wrap.toml" // in the workspace root
+	agentFlagsKey           = "agent-flags"
+)
+
+// userConfigDir is os.UserConfigDir, a variable so tests can move it.
+var userConfigDir = os.UserConfigDir
+
+// listFlags are the repeatable flags, which take an array in a config
+// file.
+var listFlags = map[string]bool{
+	"env":                  true,
+	"env-file":             true,
+	"redact-pattern":       true,
+	"fatal-stderr-pattern": true,
+	agentFlagsKey:          true,
+}
+
+// workspaceAllowed are the only settings a workspace's config file can
+// make: how hangs are detected, how output is shown and which model runs,
+// never the programs run or their environment, the host, the files
+// written or where prompts and output are sent or kept. Anyone who can
+// commit to a repository could otherwise choose those on checking it out.
+var workspaceAllowed = map[string]bool{
+	"output-format":          true,
+	"wrapper-events":         true,
+	"stream-tool-output":     true,
+	"show-thinking":          true,
+	"idle-timeout":           true,
+	"tool-grace":             true,
+	"tick-interval":          true,
+	"max-thinking-duration":  true,
+	"turn-timeout":           true,
+	"nonjson-liveness":       true,
+	"estimate-factor":        true,
+	"fatal-stderr-pattern":   true,
+	"max-output-bytes":       true,
+	"max-event-bytes":        true,
+	"event-buffer":           true,
+	"hang-dump-events":       true,
+	"log-level":              true,
+	"console-log-format":     true,
+	"log-time-format":        true,
+	"redact-pattern":         true,
+	"max-hang-retries":       true,
+	"retry-on-abnormal-exit": true,
+	"model":                  true,
+	"prompt-file-threshold":  true,
+	"prompt-write-timeout":   true,
+	"start-retries":          true,
+	"start-retry-delay":      true,
+	"kill-signal":            true,
+	"kill-grace":             true,
+}
+
+// commandLineOnly are the flags config files and the environment can't
+// set: they choose what is read, or don't run the wrapper at all.
+var commandLineOnly = map[string]bool{
+	"config":    true,
+	"no-config": true,
+	"version":   true,
+	"V":         true,
+}
+
+// setting is one key of a config file.
+type setting struct {
+	name   string   // the flag's name, or agentFlagsKey
+	values []string // as the flag would be given them, in order
+	line   int      // in a file; 0 in the environment
+}
+
+// configLayer is the settings from one source. Layers fill in, in order,
+// the flags not given on the command line or by an earlier layer.
+type configLayer struct {
+	source    string // the file, for errors and the log; "" for the environment
+	settings  []setting
+	workspace bool // limited to workspaceAllowed
+}
+
+// where names the place s was set, for errors: the file and line, or the
+// environment variable.
+func (l configLayer) where(s setting) string {
+	if l.source == "" {
+		return envName(s.name)
+	}
+	return fmt.Sprintf("%s:%d", l.source, s.line)
+}
+
+// configSources are where the settings applyConfig made came from.
+type configSources struct {
+	files      []string // config files read, most specific first
+	env        []string // CURSOR_WRAP_* variables used
+	agentFlags []string // the flags after "--" they give, if any
+}
+
+// applyConfig fills in the flags fs wasn't given, from the CURSOR_WRAP_*
+// environment variables and then the config files, unless noConfig.
+// --config and --no-config can themselves be set in the environment.
+func applyConfig(fs *flag.FlagSet, explicit string, noConfig bool) (configSources, error) {
+	var (
+		src   configSources
+		given = givenFlags(fs)
+		err   error
+	)
+	env := envLayer(fs)
+	if explicit == "" {
+		explicit = os.Getenv(envName("config"))
+	}
+	if !given["no-config"] {
+		if v := os.Getenv(envName("no-config")); v != "" {
+			if noConfig, err = strconv.ParseBool(v); err != nil {
+				return src, fmt.Errorf("%s: invalid value %q: %w", envName("no-config"), v, err)
+			}
+		}
+	}
+	layers := []configLayer{env}
+	if noConfig {
+		if explicit != "" {
+			return src, errors.New("--config and --no-config can't both be given")
+		}
+	} else {
+		files, err := loadConfigLayers(fs, given, env, explicit)
+		if err != nil {
+			return src, err
+		}
+		layers = append(layers, files...)
+		for _, layer := range files {
+			src.files = append(src.files, layer.source)
+		}
+	}
+	if src.agentFlags, err = applyConfigLayers(fs, given, layers); err != nil {
+		return src, err
+	}
+	for _, s := range env.settings {
+		src.env = append(src.env, envName(s.name))
+	}
+	return src, nil
+}
+
+// loadConfigLayers reads the config files parseFlags layers under the
+// command line and env, most specific first: the file --config names, if
+// given; otherwise the workspace's .cursor-wrap.toml and then the user's
+// config.toml, whichever exist. The workspace is the one --workspace
+// names, on the command line, in the environment or in the user's file,
+// or else the current directory; with --remote it is on another host and
+// its file isn't read.
+func loadConfigLayers(fs *flag.FlagSet, given map[string]bool, env configLayer, explicit string) ([]configLayer, error) {
+	if explicit != "" {
+		layer, err := readConfigLayer(explicit)
+		if err != nil {
+			return nil, err
+		}
+		return []configLayer{layer}, nil
+	}
+	var layers []configLayer
+	var user configLayer
+	if dir, err := userConfigDir(); err == nil {
+		user, err = readConfigLayer(filepath.Join(dir, "cursor-wrap", configFileName))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			layers = append(layers, user)
+		}
+	}
+	// The command line's value of a flag, or else env's or the user
+	// file's.
+	value := func(name string) string {
+		if given[name] {
+			return fs.Lookup(name).Value.String()
+		}
+		return cmp.Or(env.value(name), user.value(name))
+	}
+	if value("remote") != "" {
+		return layers, nil
+	}
+	workspace, err := readConfigLayer(filepath.Join(value("workspace"), workspaceConfigFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return layers, nil
+	case err != nil:
+		return nil, err
+	}
+	workspace.workspace = true
+	return append([]configLayer{workspace}, layers...), nil
+}
+
+func readConfigLayer(path string) (configLayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return configLayer{}, err
+	}
+	settings, err := parseConfigFile(string(data))
+	if err != nil {
+		return configLayer{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return configLayer{source: path, settings: settings}, nil
+}
+
+// value returns the last value l sets the flag name to, or "".
+func (l configLayer) value(name string) string {
+	for _, s := range l.settings {
+		if s.name == name && len(s.values) > 0 {
+			return s.values[len(s.values)-1]
+		}
+	}
+	return ""
+}
+
+// applyConfigLayers sets the flags in fs that given doesn't name from
+// layers, the first to set a flag winning, and adds those it sets to
+// given. It returns the agent flags of the first layer with any.
+func applyConfigLayers(fs *flag.FlagSet, given map[string]bool, layers []configLayer) (agentFlags []string, err error) {
+	for _, layer := range layers {
+		var set []string
+		for _, s := range layer.settings {
+			if err := checkSetting(fs, layer, s); err != nil {
+				return nil, err
+			}
+			if s.name == agentFlagsKey {
+				if agentFlags == nil {
+					agentFlags = s.values
+				}
+				continue
+			}
+			if given[s.name] {
+				continue
+			}
+			for _, v := range s.values {
+				if err := fs.Set(s.name, v); err != nil {
+					return nil, fmt.Errorf("%s: invalid value %q for %s: %w", layer.where(s), v, s.name, err)
+				}
+			}
+			set = append(set, s.name)
+		}
+		for _, name := range set {
+			given[name] = true
+		}
+	}
+	return agentFlags, nil
+}
+
+// checkSetting reports a setting layer can't make.
+func checkSetting(fs *flag.FlagSet, layer configLayer, s setting) error {
+	fail := func(format string, args ...any) error {
+		return fmt.Errorf("%s: %s", layer.where(s), fmt.Sprintf(format, args...))
+	}
+	switch {
+	case commandLineOnly[s.name]:
+		return fail("%s can't be set in a config file", s.name)
+	case s.name != agentFlagsKey && fs.Lookup(s.name) == nil:
+		return fail("unknown setting %q", s.name)
+	case len(s.values) != 1 && !listFlags[s.name]:
+		return fail("%s takes one value, not an array", s.name)
+	case layer.workspace && !workspaceAllowed[s.name]:
+		return fail("%s can't be set in a workspace's config file; set it in your own, or on the command line", s.name)
+	}
+	return nil
+}
+
+// givenFlags returns the names of the flags fs was given, with -p and
+// --print counting as each other.
+func givenFlags(fs *flag.FlagSet) map[string]bool {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if given["p"] || given["print"] {
+		given["p"], given["print"] = true, true
+	}
+	return given
+}
+
+var bareKeyRE = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseConfigFile parses a config file's settings.
+func parseConfigFile(data string) ([]setting, error) {
+	var settings []setting
+	seen := make(map[string]bool)
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables aren't supported; set flags at the top level", lineNo)
+		}
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if k, err := strconv.Unquote(key); err == nil {
+			key = k
+		} else if !bareKeyRE.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid key %q", lineNo, key)
+		}
+		key = strings.ReplaceAll(key, "_", "-")
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: %s is set twice", lineNo, key)
+		}
+		seen[key] = true
+
+		value := strings.TrimSpace(rest)
+		var values []string
+		var err error
+		if strings.HasPrefix(value, "[") {
+			// An array may run over several lines.
+			for !arrayClosed(value) && i+1 < len(lines) {
+				i++
+				value += "\n" + stripComment(lines[i])
+			}
+			values, err = parseArray(value)
+		} else {
+			var v string
+			v, err = parseScalar(value)
+			values = []string{v}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+		}
+		settings = append(settings, setting{name: key, values: values, line: lineNo})
+	}
+	return settings, nil
+}
+
+// stripComment cuts line at a # outside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// arrayClosed reports whether the brackets of s, outside strings, balance.
+func arrayClosed(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '[':
+			depth++
+		case quote == 0 && c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// parseScalar parses a whole value that isn't an array.
+func parseScalar(s string) (string, error) {
+	v, rest, err := scanValue(s)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return "", fmt.Errorf("unexpected %q after the value", rest)
+	}
+	return v, nil
+}
+
+// parseArray parses an array of scalars.
+func parseArray(s string) ([]string, error) {
+	rest := strings.TrimSpace(strings.TrimPrefix(s, "["))
+	values := []string{}
+	for {
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, "]") {
+			if tail := strings.TrimSpace(rest[1:]); tail != "" {
+				return nil, fmt.Errorf("unexpected %q after the array", tail)
+			}
+			return values, nil
+		}
+		if rest == "" {
+			return nil, errors.New("unterminated array")
+		}
+		if strings.HasPrefix(rest, "[") {
+			return nil, errors.New("nested arrays aren't supported")
+		}
+		v, after, err := scanValue(rest)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		rest = strings.TrimSpace(after)
+		if r, ok := strings.CutPrefix(rest, ","); ok {
+			rest = r
+		} else if rest == "" {
+			return nil, errors.New("unterminated array")
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, fmt.Errorf("expected , or ] at %q", rest)
+		}
+	}
+}
+
+// bareValueRE matches the values that aren't quoted: booleans and
+// numbers.
+var bareValueRE = regexp.MustCompile(`^(true|false|[+-]?[0-9][0-9_]*(\.[0-9_]+)?([eE][+-]?[0-9]+)?)$`)
+
+// scanValue reads the value s starts with, as the text a flag takes, and
+// returns what follows it.
+func scanValue(s string) (value, rest string, err error) {
+	switch {
+	case s == "":
+		return "", "", errors.New("missing value")
+	case s[0] == '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+				}
+				return v, s[i+1:], nil
+			}
+		}
+		return "", "", errors.New("unterminated string")
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	end := strings.IndexAny(s, ",] \t\n")
+	if end < 0 {
+		end = len(s)
+	}
+	word := s[:end]
+	if !bareValueRE.MatchString(word) {
+		return "", "", fmt.Errorf("%q: strings, durations and sizes must be quoted", word)
+	}
+	return strings.ReplaceAll(word, "_", ""), s[end:], nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	settings, err := parseConfigFile(`# comment
+idle-timeout = "90s"  # trailing comment
+model = 'gpt-5 # not a comment'
+stream_tool_output = true
+max-hang-retries = 1_000
+"estimate-factor" = 1.5
+env = [
+  "A=1", # first
+  'B=#2',
+]
+agent-flags = []
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []setting{
+		{name: "idle-timeout", values: []string{"90s"}, line: 2},
+		{name: "model", values: []string{"gpt-5 # not a comment"}, line: 3},
+		{name: "stream-tool-output", values: []string{"true"}, line: 4},
+		{name: "max-hang-retries", values: []string{"1000"}, line: 5},
+		{name: "estimate-factor", values: []string{"1.5"}, line: 6},
+		{name: "env", values: []string{"A=1", "B=#2"}, line: 7},
+		{name: "agent-flags", values: []string{}, line: 11},
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("settings = %+v\nwant %+v", settings, want)
+	}
+}
+
+func TestParseConfigFile_Errors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"[wrapper]\nmodel = \"x\"", "line 1: tables aren't supported"},
+		{"model", "line 1: expected key = value"},
+		{"model.name = \"x\"", "invalid key"},
+		{"model = \"a\"\nmodel = \"b\"", "line 2: model is set twice"},
+		{"idle-timeout = 90s", "must be quoted"},
+		{"model = \"gpt", "unterminated string"},
+		{"model = \"a\" \"b\"", "after the value"},
+		{"env = [\"A=1\"", "unterminated array"},
+		{"env = [\"A=1\" \"B=2\"]", "expected , or ]"},
+		{"env = [[\"A=1\"]]", "nested arrays"},
+		{"\n\nmodel =", "line 3: model: missing value"},
+	}
+	for _, tt := range tests {
+		_, err := parseConfigFile(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseConfigFile(%q) = %v, want an error containing %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+// useConfigDir points the user's config dir at a temporary one, holding
+// config as its cursor-wrap config file unless config is "".
+func useConfigDir(t *testing.T, config string) string {
+	t.Helper()
+	dir := t.TempDir()
+	saved := userConfigDir
+	userConfigDir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { userConfigDir = saved })
+	if config != "" {
+		writeConfigFile(t, filepath.Join(dir, "cursor-wrap", configFileName), config)
+	}
+	return filepath.Join(dir, "cursor-wrap", configFileName)
+}
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseFlags_ConfigFilePrecedence(t *testing.T) {
+	userFile := useConfigDir(t, `idle-timeout = "10s"
+tool-grace = "11s"
+tick-interval = "12s"
+model = "user-model"
+`)
+	workspace := t.TempDir()
+	workspaceFile := filepath.Join(workspace, workspaceConfigFileName)
+	writeConfigFile(t, workspaceFile, `tool-grace = "21s"
+tick-interval = "22s"
+`)
+
+	cfg := parseFlags([]string{"--workspace", workspace, "--tick-interval", "32s"})
+	if cfg.IdleTimeout != 10*time.Second || cfg.ToolGrace != 21*time.Second || cfg.TickInterval != 32*time.Second {
+		t.Errorf("idle %v, grace %v, tick %v; want the user file's, the workspace's, the command line's",
+			cfg.IdleTimeout, cfg.ToolGrace, cfg.TickInterval)
+	}
+	if cfg.Process.Model != "user-model" || cfg.MaxHangRetries != 3 {
+		t.Errorf("model %q, max hang retries %d", cfg.Process.Model, cfg.MaxHangRetries)
+	}
+	if want := []string{workspaceFile, userFile}; !slices.Equal(cfg.ConfigFiles, want) {
+		t.Errorf("ConfigFiles = %q, want %q", cfg.ConfigFiles, want)
+	}
+
+	// --no-config reads neither; --config reads only the file it names.
+	cfg = parseFlags([]string{"--workspace", workspace, "--no-config"})
+	if cfg.IdleTimeout != 60*time.Second || cfg.ToolGrace != 30*time.Second || cfg.ConfigFiles != nil {
+		t.Errorf("--no-config: idle %v, grace %v, files %q", cfg.IdleTimeout, cfg.ToolGrace, cfg.ConfigFiles)
+	}
+	explicit := filepath.Join(t.TempDir(), "ci.toml")
+	writeConfigFile(t, explicit, `idle-timeout = "40s"`)
+	cfg = parseFlags([]string{"--workspace", workspace, "--config", explicit})
+	if cfg.IdleTimeout != 40*time.Second || cfg.ToolGrace != 30*time.Second || !slices.Equal(cfg.ConfigFiles, []string{explicit}) {
+		t.Errorf("--config: idle %v, grace %v, files %q", cfg.IdleTimeout, cfg.ToolGrace, cfg.ConfigFiles)
+	}
+}
+
+func TestParseFlags_ConfigFilePrintMode(t *testing.T) {
+	useConfigDir(t, "print = true\n")
+	cfg := parseFlags([]string{})
+	if !cfg.Print || cfg.OutputFormat != "stream-json" {
+		t.Errorf("Print %v, OutputFormat %q; want -p and its defaults", cfg.Print, cfg.OutputFormat)
+	}
+	if cfg := parseFlags([]string{"-p=false"}); cfg.Print {
+		t.Error("-p=false on the command line didn't beat the file's print")
+	}
+}
+
+func TestParseFlags_ConfigFileLists(t *testing.T) {
+	useConfigDir(t, `env = ["A=1", "B=2"]
+agent-flags = ["--approve-mcps"]
+`)
+	cfg := parseFlags([]string{})
+	if !slices.Equal(cfg.Process.Env, []string{"A=1", "B=2"}) || !slices.Equal(cfg.Process.ExtraFlags, []string{"--approve-mcps"}) {
+		t.Errorf("env %q, extra flags %q", cfg.Process.Env, cfg.Process.ExtraFlags)
+	}
+	// The command line's replace them rather than add to them.
+	cfg = parseFlags([]string{"--env", "C=3", "--", "--browser"})
+	if !slices.Equal(cfg.Process.Env, []string{"C=3"}) || !slices.Equal(cfg.Process.ExtraFlags, []string{"--browser"}) {
+		t.Errorf("env %q, extra flags %q", cfg.Process.Env, cfg.Process.ExtraFlags)
+	}
+}
+
+func TestParseFlags_ConfigFileRemoteSkipsWorkspace(t *testing.T) {
+	useConfigDir(t, `remote = "me@build"`)
+	workspace := t.TempDir()
+	writeConfigFile(t, filepath.Join(workspace, workspaceConfigFileName), `model = "workspace-model"`)
+	if cfg := parseFlags([]string{"--workspace", workspace}); cfg.Process.Model != "" || cfg.Process.Remote != "me@build" {
+		t.Errorf("model %q, remote %q; want the workspace file unread", cfg.Process.Model, cfg.Process.Remote)
+	}
+}
+
+func TestApplyConfig_FileErrors(t *testing.T) {
+	newFlags := func() *flag.FlagSet {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.String("model", "", "")
+		fs.String("agent-bin", "", "")
+		fs.String("workspace", "", "")
+		fs.String("remote", "", "")
+		fs.String("hang-webhook", "", "")
+		fs.String("history-file", "", "")
+		fs.String("env", "", "")
+		fs.String("log-http-endpoint", "", "")
+		fs.String("pidfile", "", "")
+		fs.Duration("idle-timeout", 0, "")
+		return fs
+	}
+	useConfigDir(t, "")
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		file      string
+		workspace bool // as the workspace's file rather than --config
+		want      string
+	}{
+		{"unknown", "modle = \"x\"", false, `:1: unknown setting "modle"`},
+		{"bad value", "\nidle-timeout = \"soon\"", false, `:2: invalid value "soon" for idle-timeout`},
+		{"array for a single value", `model = ["a", "b"]`, false, "model takes one value"},
+		{"config in a file", `config = "other.toml"`, false, "config can't be set in a config file"},
+		{"workspace picks the binary", `agent-bin = "/tmp/evil"`, true, "agent-bin can't be set in a workspace's config file"},
+		{"workspace sends prompts away", `hang-webhook = "https://evil.example/hook"`, true, "hang-webhook can't be set in a workspace's config file"},
+		{"workspace keeps prompts", `history-file = "/tmp/prompts"`, true, "history-file can't be set in a workspace's config file"},
+		{"workspace picks the host", `remote = "me@evil"`, true, "remote can't be set"},
+		{"workspace sets the agent's environment", `env = ["NODE_OPTIONS=--require ./x.js"]`, true, "env can't be set in a workspace's config file"},
+		{"workspace passes agent flags", `agent-flags = ["--approve-mcps"]`, true, "agent-flags can't be set in a workspace's config file"},
+		{"workspace ships the log away", `log-http-endpoint = "https://evil.example/logs"`, true, "log-http-endpoint can't be set in a workspace's config file"},
+		{"workspace overwrites a file", `pidfile = "/home/me/.bashrc"`, true, "pidfile can't be set in a workspace's config file"},
+		{"syntax", `model = x`, false, "must be quoted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newFlags()
+			path := filepath.Join(dir, "explicit.toml")
+			explicit := path
+			if tt.workspace {
+				path = filepath.Join(dir, workspaceConfigFileName)
+				explicit = ""
+				if err := fs.Parse([]string{"--workspace", dir}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeConfigFile(t, path, tt.file)
+			_, err := applyConfig(fs, explicit, false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), path) {
+				t.Errorf("err = %v, want %s and %q", err, path, tt.want)
+			}
+			_ = os.Remove(path) // the next case writes its own
+		})
+	}
+
+	if _, err := applyConfig(newFlags(), filepath.Join(dir, "missing.toml"), false); err == nil {
+		t.Error("missing --config file: no error")
+	}
+	if _, err := applyConfig(newFlags(), "x.toml", true); err == nil {
+		t.Error("--config with --no-config: no error")
+	}
+}
+
+func TestParseFlags_EnvOverrides(t *testing.T) {
+	useConfigDir(t, `idle-timeout = "10s"
+tool-grace = "11s"
+model = "user-model"
+`)
+	t.Setenv("CURSOR_WRAP_IDLE_TIMEOUT", "20s")
+	t.Setenv("CURSOR_WRAP_TOOL_GRACE", "21s")
+	t.Setenv("CURSOR_WRAP_OUTPUT_FORMAT", "text")
+	t.Setenv("CURSOR_WRAP_LOG_DIR", "/var/log/cw")
+	t.Setenv("CURSOR_WRAP_AGENT_BIN", "/opt/cursor/agent")
+	t.Setenv("CURSOR_WRAP_PRINT", "true")
+	t.Setenv("CURSOR_WRAP_ENV", "CI=1")
+	t.Setenv("CURSOR_WRAP_AGENT_FLAGS", "--approve-mcps  --browser")
+	t.Setenv("CURSOR_WRAP_MODEL", "") // empty: as if unset
+
+	cfg := parseFlags([]string{"--tool-grace", "31s"})
+	if cfg.IdleTimeout != 20*time.Second || cfg.ToolGrace != 31*time.Second {
+		t.Errorf("idle %v, grace %v; want env's over the file's, the command line's over env's", cfg.IdleTimeout, cfg.ToolGrace)
+	}
+	// -p from env still gets -p's defaults, but for the output format set.
+	if !cfg.Print || cfg.OutputFormat != "text" || cfg.Log.Dir != "/var/log/cw" || cfg.Process.AgentBin != "/opt/cursor/agent" {
+		t.Errorf("print %v, output format %q, log dir %q, agent bin %q", cfg.Print, cfg.OutputFormat, cfg.Log.Dir, cfg.Process.AgentBin)
+	}
+	if cfg.Process.Model != "user-model" {
+		t.Errorf("model = %q, want the file's", cfg.Process.Model)
+	}
+	if !slices.Equal(cfg.Process.Env, []string{"CI=1"}) || !slices.Equal(cfg.Process.ExtraFlags, []string{"--approve-mcps", "--browser"}) {
+		t.Errorf("env %q, extra flags %q", cfg.Process.Env, cfg.Process.ExtraFlags)
+	}
+	want := []string{
+		"CURSOR_WRAP_AGENT_BIN", "CURSOR_WRAP_ENV", "CURSOR_WRAP_IDLE_TIMEOUT", "CURSOR_WRAP_LOG_DIR",
+		"CURSOR_WRAP_OUTPUT_FORMAT", "CURSOR_WRAP_PRINT", "CURSOR_WRAP_TOOL_GRACE", "CURSOR_WRAP_AGENT_FLAGS",
+	}
+	if !slices.Equal(cfg.ConfigEnv, want) {
+		t.Errorf("ConfigEnv = %q\nwant %q", cfg.ConfigEnv, want)
+	}
+}
+
+func TestParseFlags_EnvConfigFile(t *testing.T) {
+	useConfigDir(t, `idle-timeout = "10s"`)
+	explicit := filepath.Join(t.TempDir(), "ci.toml")
+	writeConfigFile(t, explicit, `tool-grace = "40s"`)
+	t.Setenv("CURSOR_WRAP_CONFIG", explicit)
+	cfg := parseFlags([]string{})
+	if cfg.IdleTimeout != 60*time.Second || cfg.ToolGrace != 40*time.Second {
+		t.Errorf("CURSOR_WRAP_CONFIG: idle %v, grace %v; want only its file read", cfg.IdleTimeout, cfg.ToolGrace)
+	}
+
+	t.Setenv("CURSOR_WRAP_CONFIG", "")
+	t.Setenv("CURSOR_WRAP_NO_CONFIG", "1")
+	t.Setenv("CURSOR_WRAP_TICK_INTERVAL", "9s")
+	cfg = parseFlags([]string{})
+	if cfg.IdleTimeout != 60*time.Second || cfg.TickInterval != 9*time.Second || cfg.ConfigFiles != nil {
+		t.Errorf("CURSOR_WRAP_NO_CONFIG: idle %v, tick %v, files %q; want env but no file", cfg.IdleTimeout, cfg.TickInterval, cfg.ConfigFiles)
+	}
+}
+
+func TestApplyConfig_EnvErrors(t *testing.T) {
+	useConfigDir(t, "")
+	tests := []struct {
+		name, value string
+		want        string
+	}{
+		{"CURSOR_WRAP_IDLE_TIMEOUT", "soon", `CURSOR_WRAP_IDLE_TIMEOUT: invalid value "soon" for idle-timeout`},
+		{"CURSOR_WRAP_NO_CONFIG", "maybe", `CURSOR_WRAP_NO_CONFIG: invalid value "maybe"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.Duration("idle-timeout", 0, "")
+			if _, err := applyConfig(fs, "", false); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/monitor"
+)
+
+// maxHangDebounceWait bounds how long a hang verdict is held back while
+// waiting for events that may be in flight.
+const maxHangDebounceWait = 200 * time.Millisecond
+
+// debounceResult is the outcome of re-checking a hang verdict.
+type debounceResult struct {
+	Verdict     monitor.Verdict
+	Reason      monitor.Reason
+	Drained     int  // events absorbed before the re-check
+	StreamEnded bool // eventCh closed while draining; caller must handle stream end
+}
+
+// hangDebounceWait derives the debounce window from the tick interval so
+// that fast ticks (as in tests) don't get a window longer than the tick.
+func hangDebounceWait(tick time.Duration) time.Duration {
+	return min(tick/2, maxHangDebounceWait)
+}
+
+// debounceHang re-evaluates a hang verdict after absorbing events that were
+// already queued (or arrive within wait) when the tick fired. Without this,
+// an event written just as the tick fires is judged against a stale
+// LastEventAt and a live agent gets killed.
+func debounceHang(mon *monitor.Monitor, eventCh <-chan events.AnnotatedEvent, wait time.Duration, handle func(events.AnnotatedEvent)) debounceResult {
+	var res debounceResult
+
+	// Absorb everything already buffered before starting the timer, so a
+	// zero wait still sees queued events.
+	for {
+		select {
+		case ev, ok := <-eventCh:
+			if !ok {
+				res.StreamEnded = true
+				return res
+			}
+			handle(ev)
+			res.Drained++
+			continue
+		default:
+		}
+		break
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+	waitLoop:
+		for {
+			select {
+			case ev, ok := <-eventCh:
+				if !ok {
+					res.StreamEnded = true
+					return res
+				}
+				handle(ev)
+				res.Drained++
+			case <-timer.C:
+				break waitLoop
+			}
+		}
+	}
+
+	res.Verdict, res.Reason = mon.CheckTimeout(mon.Now())
+	return res
+}
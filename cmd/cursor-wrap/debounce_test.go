@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/monitor"
+)
+
+// fixedClock is a monitor.Clock pinned to a settable instant.
+type fixedClock struct{ now time.Time }
+
+func (c *fixedClock) Now() time.Time { return c.now }
+
+var debounceT0 = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func thinkingEvent(recv time.Time) events.AnnotatedEvent {
+	return events.AnnotatedEvent{
+		RecvTime: recv,
+		Raw:      []byte(`{"type":"thinking","subtype":"delta","text":"x"}`),
+		Parsed:   events.RawEvent{Type: "thinking", Subtype: "delta"},
+	}
+}
+
+// hungMonitor returns a monitor whose idle timeout has already elapsed.
+func hungMonitor(t *testing.T) (*monitor.Monitor, *fixedClock) {
+	t.Helper()
+	clk := &fixedClock{now: debounceT0}
+	mon := monitor.NewMonitor(time.Second, time.Second, monitor.WithClock(clk))
+	clk.now = debounceT0.Add(2 * time.Second)
+	if v, _ := mon.CheckTimeout(clk.Now()); v != monitor.VerdictHang {
+		t.Fatalf("precondition: verdict = %v, want Hang", v)
+	}
+	return mon, clk
+}
+
+func TestDebounceHang_QueuedEventClearsVerdict(t *testing.T) {
+	mon, clk := hungMonitor(t)
+	eventCh := make(chan events.AnnotatedEvent, 4)
+	eventCh <- thinkingEvent(clk.Now())
+
+	var handled int
+	res := debounceHang(mon, eventCh, 0, func(ev events.AnnotatedEvent) {
+		handled++
+		mon.ProcessEvent(ev)
+	})
+
+	if res.Verdict != monitor.VerdictOK {
+		t.Errorf("Verdict = %v, want OK", res.Verdict)
+	}
+	if res.Drained != 1 || handled != 1 {
+		t.Errorf("Drained = %d, handled = %d, want 1 and 1", res.Drained, handled)
+	}
+}
+
+func TestDebounceHang_NoEventsConfirmsHang(t *testing.T) {
+	mon, _ := hungMonitor(t)
+	eventCh := make(chan events.AnnotatedEvent, 4)
+
+	res := debounceHang(mon, eventCh, time.Millisecond, func(events.AnnotatedEvent) {
+		t.Fatal("handle called with no events queued")
+	})
+
+	if res.Verdict != monitor.VerdictHang {
+		t.Errorf("Verdict = %v, want Hang", res.Verdict)
+	}
+	if res.Drained != 0 || res.StreamEnded {
+		t.Errorf("Drained = %d, StreamEnded = %v, want 0 and false", res.Drained, res.StreamEnded)
+	}
+}
+
+func TestDebounceHang_EventWithinWaitWindow(t *testing.T) {
+	mon, clk := hungMonitor(t)
+	eventCh := make(chan events.AnnotatedEvent) // unbuffered: arrives during the wait
+
+	go func() { eventCh <- thinkingEvent(clk.Now()) }()
+
+	res := debounceHang(mon, eventCh, time.Minute, func(ev events.AnnotatedEvent) {
+		mon.ProcessEvent(ev)
+		// Shorten the test: closing ends the wait via StreamEnded.
+		close(eventCh)
+	})
+
+	if !res.StreamEnded {
+		t.Fatal("expected StreamEnded after channel close")
+	}
+	if res.Drained != 1 {
+		t.Errorf("Drained = %d, want 1", res.Drained)
+	}
+}
+
+func TestDebounceHang_ClosedChannelReportsStreamEnd(t *testing.T) {
+	mon, _ := hungMonitor(t)
+	eventCh := make(chan events.AnnotatedEvent)
+	close(eventCh)
+
+	res := debounceHang(mon, eventCh, time.Minute, func(events.AnnotatedEvent) {})
+	if !res.StreamEnded {
+		t.Error("expected StreamEnded for a closed channel")
+	}
+}
+
+func TestDebounceHang_StreamCutShortByReaderError(t *testing.T) {
+	// The output limit is hit while a hang verdict is being debounced:
+	// the stream's end must be reported as the limit, not as the agent
+	// closing its output.
+	mon, clk := hungMonitor(t)
+	out := `{"type":"thinking","subtype":"delta","text":"x"}` + "\n" + strings.Repeat("y", 4096)
+	eventCh := make(chan events.AnnotatedEvent, 4)
+	errCh := make(chan error, 1)
+	go events.Reader(context.Background(), newOutputBudget(strings.NewReader(out), 1024), eventCh, errCh)
+
+	res := debounceHang(mon, eventCh, time.Minute, func(ev events.AnnotatedEvent) {
+		ev.RecvTime = clk.Now()
+		mon.ProcessEvent(ev)
+	})
+	if !res.StreamEnded {
+		t.Fatal("expected StreamEnded")
+	}
+	if err := readerErrAtEnd(errCh); !errors.Is(err, ErrOutputLimit) {
+		t.Errorf("readerErrAtEnd = %v, want ErrOutputLimit", err)
+	}
+	if err := readerErrAtEnd(errCh); err != nil {
+		t.Errorf("readerErrAtEnd again = %v, want nil", err)
+	}
+}
+
+func TestHangDebounceWait(t *testing.T) {
+	if got := hangDebounceWait(5 * time.Second); got != maxHangDebounceWait {
+		t.Errorf("hangDebounceWait(5s) = %v, want %v", got, maxHangDebounceWait)
+	}
+	if got := hangDebounceWait(100 * time.Millisecond); got != 50*time.Millisecond {
+		t.Errorf("hangDebounceWait(100ms) = %v, want 50ms", got)
+	}
+}
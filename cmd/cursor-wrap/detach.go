@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"cursor-wrap/internal/logger"
+)
+
+// detachedEnv marks the re-executed wrapper of a --detach run. It holds
+// the parent's start time in Unix milliseconds, which names the child's
+// session log so the parent can print its path before exiting.
+const detachedEnv = "CURSOR_WRAP_DETACHED"
+
+// detachedFiles are the files a detached wrapper writes next to its
+// session log.
+type detachedFiles struct {
+	Log, Stdout, Stderr, Pid string
+}
+
+func newDetachedFiles(log logger.LogConfig, started time.Time) detachedFiles {
+	dir := log.Dir
+	logName := logger.FileName(started, log.Tag)
+	base := filepath.Join(dir, strings.TrimSuffix(logName, "-unknown.jsonl"))
+	if log.SplitLogs {
+		_, logName = logger.SplitFileNames(started, log.Tag)
+	}
+	return detachedFiles{
+		Log:    filepath.Join(dir, logName),
+		Stdout: base + ".stdout",
+		Stderr: base + ".stderr",
+		Pid:    base + ".pid",
+	}
+}
+
+// detachedChild reports whether this process is the re-executed wrapper
+// of a --detach run and, if so, when the parent started it. The marker
+// is removed from the environment so the agent (and anything it runs)
+// doesn't inherit it.
+func detachedChild() (time.Time, bool) {
+	v, ok := os.LookupEnv(detachedEnv)
+	if !ok {
+		return time.Time{}, false
+	}
+	_ = os.Unsetenv(detachedEnv) // only fails for an invalid key
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Now(), true
+	}
+	return time.UnixMilli(ms), true
+}
+
+// detach re-runs the wrapper with the same arguments in a new session, so
+// closing the terminal (or losing the ssh connection) doesn't take it and
+// the agent down. The child's stdout and stderr go to files next to its
+// session log, where it also keeps a pidfile while it runs. A prompt
+// piped on stdin is handed to the child through an unlinked temp file.
+// The paths are printed to stdout for the caller to follow.
+func detach(cfg Config) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("finding own executable: %w", err)
+	}
+	if err := os.MkdirAll(cfg.Log.Dir, 0o755); err != nil {
+		return fmt.Errorf("log directory: %w", err)
+	}
+	started := time.Now()
+	files := newDetachedFiles(cfg.Log, started)
+	logNote := "renamed to include the session id once known"
+	if path, ok := logger.ResumedLog(cfg.Log); ok {
+		files.Log, logNote = path, "the resumed session's log, appended to"
+	}
+
+	var stdin *os.File
+	if cfg.PositionalPrompt == "" {
+		prompt, err := firstPrompt(cfg)
+		if err != nil {
+			return fmt.Errorf("reading prompt: %w", err)
+		}
+		if stdin, err = promptStdin(cfg.Log.Dir, prompt); err != nil {
+			return err
+		}
+		defer stdin.Close()
+	}
+
+	stdout, err := os.OpenFile(files.Stdout, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer stdout.Close()
+	stderr, err := os.OpenFile(files.Stderr, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer stderr.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), detachedEnv+"="+strconv.FormatInt(started.UnixMilli(), 10))
+	cmd.Stdin = stdin // nil reads from /dev/null
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting detached wrapper: %w", err)
+	}
+	pid := cmd.Process.Pid
+	_ = cmd.Process.Release() // nothing to release on Unix
+
+	fmt.Printf("cursor-wrap: detached as pid %d\n", pid)
+	fmt.Printf("  log:    %s (%s)\n", files.Log, logNote)
+	fmt.Printf("  stdout: %s\n", files.Stdout)
+	fmt.Printf("  stderr: %s\n", files.Stderr)
+	fmt.Printf("  pid:    %s (removed when it exits)\n", files.Pid)
+	fmt.Printf("follow with: tail -f %s\n", files.Stdout)
+	return nil
+}
+
+// promptStdin returns a read-only file holding prompt, already unlinked
+// so nothing is left behind once the child has read it.
+func promptStdin(dir, prompt string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, ".cursor-wrap-prompt-*")
+	if err != nil {
+		return nil, fmt.Errorf("prompt file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.WriteString(f, prompt); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("prompt file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("prompt file: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envKeyRE matches a portable environment variable name.
+var envKeyRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// envList collects KEY=VALUE entries for the agent's environment from
+// --env and --env-file, in command-line order so later values win.
+type envList struct {
+	entries []string
+}
+
+func (l *envList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(l.entries, " ")
+}
+
+// Set implements flag.Value for --env.
+func (l *envList) Set(s string) error {
+	entry, err := parseEnvEntry(s)
+	if err != nil {
+		return err
+	}
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// envFileFlag is the flag.Value for --env-file. It reads the file as soon
+// as the flag is parsed so a malformed file fails before anything starts.
+type envFileFlag struct {
+	list *envList
+}
+
+func (f envFileFlag) String() string { return "" }
+
+func (f envFileFlag) Set(path string) error {
+	entries, err := readEnvFile(path)
+	if err != nil {
+		return err
+	}
+	f.list.entries = append(f.list.entries, entries...)
+	return nil
+}
+
+// parseEnvEntry validates a single KEY=VALUE entry.
+func parseEnvEntry(s string) (string, error) {
+	key, _, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", fmt.Errorf("env entry %q: expected KEY=VALUE", s)
+	}
+	if !envKeyRE.MatchString(key) {
+		return "", fmt.Errorf("env entry %q: invalid variable name %q", s, key)
+	}
+	return s, nil
+}
+
+// readEnvFile parses a dotenv-style file: KEY=VALUE per line, blank lines
+// and # comments ignored, an optional "export " prefix, and values
+// optionally wrapped in matching single or double quotes.
+func readEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("env file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("env file %s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if !envKeyRE.MatchString(key) {
+			return nil, fmt.Errorf("env file %s:%d: invalid variable name %q", path, lineNo, key)
+		}
+		entries = append(entries, key+"="+unquoteEnvValue(strings.TrimSpace(value)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("env file %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// unquoteEnvValue strips one pair of matching surrounding quotes.
+func unquoteEnvValue(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvEntry(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"CURSOR_API_KEY=abc", false},
+		{"EMPTY=", false},
+		{"WITH_EQUALS=a=b", false},
+		{"_x1=y", false},
+		{"NOEQUALS", true},
+		{"=value", true},
+		{"1BAD=x", true},
+		{"BAD-NAME=x", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseEnvEntry(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEnvEntry(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if err == nil && got != tt.in {
+				t.Errorf("parseEnvEntry(%q) = %q", tt.in, got)
+			}
+		})
+	}
+}
+
+func writeEnvFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.env")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("writing env file: %v", err)
+	}
+	return path
+}
+
+func TestReadEnvFile(t *testing.T) {
+	path := writeEnvFile(t, `# proxy settings
+HTTP_PROXY=http://proxy:3128
+
+export CURSOR_API_KEY="key with spaces"
+SINGLE='quoted'
+  PADDED = value  
+MISMATCHED="open
+`)
+	got, err := readEnvFile(path)
+	if err != nil {
+		t.Fatalf("readEnvFile: %v", err)
+	}
+	want := []string{
+		"HTTP_PROXY=http://proxy:3128",
+		"CURSOR_API_KEY=key with spaces",
+		"SINGLE=quoted",
+		"PADDED=value",
+		`MISMATCHED="open`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("readEnvFile =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestReadEnvFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"missing equals", "GOOD=1\nBAD\n", ":2: expected KEY=VALUE"},
+		{"invalid name", "BAD-NAME=1\n", `:1: invalid variable name "BAD-NAME"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readEnvFile(writeEnvFile(t, tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadEnvFile_Missing(t *testing.T) {
+	if _, err := readEnvFile(filepath.Join(t.TempDir(), "nope.env")); err == nil {
+		t.Fatal("expected error for missing env file")
+	}
+}
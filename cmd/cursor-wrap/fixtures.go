@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"cursor-wrap/internal/events"
+)
+
+// fixturesConfig holds the options of the fixtures subcommand.
+type fixturesConfig struct {
+	Files []string // session log parts, oldest first
+	Out   string   // the directory to write the fixture to
+	Salt  string   // keys the anonymizer's hash
+}
+
+// parseFixturesFlags parses the arguments after "fixtures". Flags may
+// come before or after the log files.
+func parseFixturesFlags(args []string, stderr io.Writer) (fixturesConfig, error) {
+	fs := flag.NewFlagSet("cursor-wrap fixtures", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursor-wrap fixtures --out <dir> [flags] <logfile>...")
+		fs.PrintDefaults()
+	}
+	out := fs.String("out", "", "Directory to write the fixture to (created if need be)")
+	salt := fs.String("salt", "", "Key for the anonymizing hash; without one, short values can be guessed back by hashing candidates")
+
+	var cfg fixturesConfig
+	for {
+		if err := fs.Parse(args); err != nil {
+			return cfg, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		cfg.Files = append(cfg.Files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	cfg.Out = *out
+	cfg.Salt = *salt
+
+	var errs []error
+	if len(cfg.Files) == 0 {
+		errs = append(errs, errors.New("no log file given"))
+	}
+	if cfg.Out == "" {
+		errs = append(errs, errors.New("no --out directory given"))
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// runFixtures implements "cursor-wrap fixtures": it turns the raw events
+// of a session log into an anonymized test fixture (see anonymizer). It
+// returns the process exit code.
+func runFixtures(args []string, stdout, stderr io.Writer) int {
+	cfg, err := parseFixturesFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "cursor-wrap fixtures: %v\n", err)
+		return 1
+	}
+	n, err := writeFixtures(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "cursor-wrap fixtures: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "wrote %d events to %s\n", n, cfg.Out)
+	return 0
+}
+
+// writeFixtures writes the anonymized raw events of cfg's log to
+// cfg.Out: all of them, as the agent wrote them, to full_session.jsonl,
+// and each JSON event to a file of its own, numbered in order and named
+// after its type and subtype (007_tool_call_started.json). Lines the
+// agent wrote that weren't JSON are in full_session.jsonl only. It
+// returns the number of events written.
+func writeFixtures(cfg fixturesConfig) (int, error) {
+	anon := newAnonymizer(cfg.Salt)
+	type fixture struct {
+		name string // the file of its own; "" for a non-JSON line
+		line []byte
+	}
+	var fixtures []fixture
+	for _, path := range cfg.Files {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		err = eachLogLine(f, func(line []byte) error {
+			var rec logRecord
+			if json.Unmarshal(line, &rec) != nil || rec.Msg != "raw_event" {
+				return nil // a record cut short by a crash, or not an event
+			}
+			var parsed events.WrapperNonJSON
+			if json.Unmarshal(rec.Raw, &parsed) != nil {
+				return nil
+			}
+			if parsed.Type == events.TypeWrapperNonJSON || parsed.Type == events.TypePartialLine {
+				fixtures = append(fixtures, fixture{line: []byte(anon.Text(parsed.Text))})
+				return nil
+			}
+			ev, err := anon.Event(rec.Raw)
+			if err != nil {
+				return fmt.Errorf("anonymizing event %d: %w", len(fixtures)+1, err)
+			}
+			name := parsed.Type
+			var sub struct {
+				Subtype string `json:"subtype"`
+			}
+			if json.Unmarshal(rec.Raw, &sub) == nil && sub.Subtype != "" {
+				name += "_" + sub.Subtype
+			}
+			fixtures = append(fixtures, fixture{name: name, line: ev})
+			return nil
+		})
+		_ = f.Close() // read-only
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if len(fixtures) == 0 {
+		return 0, errors.New("no raw events found in the log (they are logged at --file-log-level debug)")
+	}
+
+	if err := os.MkdirAll(cfg.Out, 0o755); err != nil {
+		return 0, err
+	}
+	var session bytes.Buffer
+	width := len(strconv.Itoa(len(fixtures)))
+	for i, fx := range fixtures {
+		session.Write(fx.line)
+		session.WriteByte('\n')
+		if fx.name == "" {
+			continue
+		}
+		name := fmt.Sprintf("%0*d_%s.json", width, i+1, fx.name)
+		if err := os.WriteFile(filepath.Join(cfg.Out, name), fx.line, 0o644); err != nil {
+			return 0, err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(cfg.Out, "full_session.jsonl"), session.Bytes(), 0o644); err != nil {
+		return 0, err
+	}
+	return len(fixtures), nil
+}
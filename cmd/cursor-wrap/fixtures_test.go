@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/monitor"
+)
+
+func TestParseFixturesFlags(t *testing.T) {
+	cfg, err := parseFixturesFlags([]string{"a.jsonl", "--out", "fx", "b.jsonl", "--salt", "s"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Files, []string{"a.jsonl", "b.jsonl"}) || cfg.Out != "fx" || cfg.Salt != "s" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if _, err := parseFixturesFlags(nil, &bytes.Buffer{}); err == nil ||
+		!strings.Contains(err.Error(), "no log file") || !strings.Contains(err.Error(), "--out") {
+		t.Errorf("err = %v, want both problems", err)
+	}
+}
+
+func TestFixtures_AnonymizedSessionStaysConsistent(t *testing.T) {
+	session, err := os.ReadFile(filepath.Join("..", "..", "internal", "events", "testdata", "full_session.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session = append(session, []byte("T: Named models unavailable for /home/user/project\n")...)
+	original := readEvents(t, session)
+
+	// A session log of the events, as the wrapper writes it, with a
+	// record that isn't an event and one cut short.
+	var log bytes.Buffer
+	log.WriteString(`{"time":1000,"level":"INFO","msg":"agent started","turn":1}` + "\n")
+	for _, ev := range original {
+		fmt.Fprintf(&log, `{"time":1000,"level":"DEBUG","msg":"raw_event","turn":1,"recv_ts":1000,"raw":%s}`+"\n", ev.Raw)
+	}
+	log.WriteString(`{"time":1000,"level":"DEBUG","msg":"raw_eve`)
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "session.jsonl")
+	if err := os.WriteFile(logPath, log.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "fixture")
+	var stdout, stderr bytes.Buffer
+	if code := runFixtures([]string{"--out", out, logPath}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "wrote 10 events") {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+
+	full, err := os.ReadFile(filepath.Join(out, "full_session.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"d43015b9", "/home/user/project", "sleep", "Planning", "5bf03e32"} {
+		if bytes.Contains(full, []byte(s)) {
+			t.Errorf("fixture still holds %q", s)
+		}
+	}
+	anonymized := readEvents(t, full)
+	if len(anonymized) != len(original) {
+		t.Fatalf("fixture has %d events, want %d", len(anonymized), len(original))
+	}
+
+	// The same events, and the monitor sees the same session.
+	before := monitor.NewMonitor(time.Minute, time.Minute)
+	after := monitor.NewMonitor(time.Minute, time.Minute)
+	for i := range original {
+		if o, a := original[i].Parsed, anonymized[i].Parsed; o.Type != a.Type || o.Subtype != a.Subtype {
+			t.Errorf("event %d: %s/%s, want %s/%s", i+1, a.Type, a.Subtype, o.Type, o.Subtype)
+		}
+		if vb, va := before.ProcessEvent(original[i]), after.ProcessEvent(anonymized[i]); vb != va {
+			t.Errorf("event %d: verdict %v, want %v", i+1, va, vb)
+		}
+		if got, want := len(after.OpenCallIDs()), len(before.OpenCallIDs()); got != want {
+			t.Errorf("event %d: %d open calls, want %d", i+1, got, want)
+		}
+	}
+	if !after.SessionDone() || after.SessionID() == "" || after.SessionID() == before.SessionID() {
+		t.Errorf("anonymized session: done %v, id %q", after.SessionDone(), after.SessionID())
+	}
+	var result events.Result
+	if err := json.Unmarshal(anonymized[8].Raw, &result); err != nil || result.SessionID != after.SessionID() {
+		t.Errorf("result session_id = %q, want %q (%v)", result.SessionID, after.SessionID(), err)
+	}
+
+	// Each JSON event in a file of its own; the non-JSON line isn't.
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{
+		"01_system_init.json", "02_user.json", "03_thinking_delta.json", "04_thinking_completed.json",
+		"05_assistant.json", "06_tool_call_started.json", "07_tool_call_completed.json", "08_assistant.json",
+		"09_result_success.json", "full_session.jsonl",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("files = %v\nwant %v", names, want)
+	}
+	started, err := os.ReadFile(filepath.Join(out, "06_tool_call_started.json"))
+	if err != nil || !bytes.Equal(started, anonymized[5].Raw) {
+		t.Errorf("06_tool_call_started.json = %s (%v), want %s", started, err, anonymized[5].Raw)
+	}
+}
+
+// readEvents passes stream through an events.Reader.
+func readEvents(t *testing.T, stream []byte) []events.AnnotatedEvent {
+	t.Helper()
+	out := make(chan events.AnnotatedEvent, 64)
+	events.Reader(context.Background(), bytes.NewReader(stream), out, make(chan error, 1))
+	var got []events.AnnotatedEvent
+	for ev := range out {
+		got = append(got, ev)
+	}
+	return got
+}
@@ -3,15 +3,28 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
+
+	"cursor-wrap/internal/logger"
 )
 
 // wrapperBin and fakeAgentBin are set by TestMain after building.
@@ -27,6 +40,15 @@ func TestMain(m *testing.M) {
 		panic(err)
 	}
 
+	// Keep the tests, and the wrappers they run, off the user's own
+	// config file and CURSOR_WRAP_* settings.
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "config"))
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, envPrefix) {
+			os.Unsetenv(name)
+		}
+	}
+
 	wrapperBin = filepath.Join(tmpDir, "cursor-wrap")
 	cmd := exec.Command("go", "build", "-o", wrapperBin, ".")
 	cmd.Dir = "."
@@ -135,6 +157,123 @@ func TestIntegration_IdleHangDetection(t *testing.T) {
 	if !strings.Contains(logContent, "hang detected") {
 		t.Error("expected 'hang detected' in log file (AC #7)")
 	}
+	if !strings.Contains(logContent, `"msg":"verdict_history"`) || !strings.Contains(logContent, `"verdict":"Hang"`) {
+		t.Error("expected verdict_history record ending in a hang in log file")
+	}
+
+	// The events leading up to the hang are dumped for the job output.
+	if !strings.Contains(stderr.String(), "events before the hang:") || !strings.Contains(stderr.String(), "system/init") {
+		t.Errorf("expected a dump of the last events on stderr, got:\n%s", stderr.String())
+	}
+	if !strings.Contains(logContent, `"msg":"recent_events"`) {
+		t.Error("expected a recent_events record in log file")
+	}
+}
+
+// --- Integration test: Endless thinking phase ---
+
+func TestIntegration_MaxThinkingDuration(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "1s",
+		"--tool-grace", "1s",
+		"--tick-interval", "500ms",
+		"--max-thinking-duration", "2s",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=endless_thinking")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+	}
+	if exitErr.ExitCode() != 2 {
+		t.Fatalf("expected exit code 2, got %d\nstderr: %s", exitErr.ExitCode(), stderr.String())
+	}
+	if elapsed := time.Since(start); elapsed > 15*time.Second {
+		t.Errorf("thinking cap took %v to fire", elapsed)
+	}
+
+	logContent := readLogFile(t, logDir)
+	if !strings.Contains(logContent, `"thinking_ms"`) {
+		t.Error("expected thinking_ms in hang log record")
+	}
+}
+
+func TestIntegration_TurnTimeout(t *testing.T) {
+	turnTimeout := func(t *testing.T, scenario, stdin string, args ...string) (exit int, stderr, logContent string) {
+		t.Helper()
+		logDir := t.TempDir()
+		cmd := exec.Command(wrapperBin, append([]string{
+			"--agent-bin", fakeAgentBin,
+			"--tool-grace", "1s",
+			"--tick-interval", "200ms",
+			"--kill-grace", "500ms",
+			"--log-dir", logDir,
+			"--output-format", "stream-json",
+		}, args...)...)
+		cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO="+scenario)
+		cmd.Stdin = strings.NewReader(stdin)
+		var errBuf bytes.Buffer
+		cmd.Stderr = &errBuf
+		start := time.Now()
+		_ = cmd.Run()
+		if elapsed := time.Since(start); elapsed > 15*time.Second {
+			t.Errorf("took %v", elapsed)
+		}
+		return cmd.ProcessState.ExitCode(), errBuf.String(), readLogFile(t, logDir)
+	}
+
+	t.Run("fires while the agent looks busy", func(t *testing.T) {
+		// slow_normal thinks for 30s: well within --idle-timeout's
+		// reach of a hang verdict, never within the turn's.
+		exit, stderr, logContent := turnTimeout(t, "slow_normal", "", "-p", "--idle-timeout", "1m", "--turn-timeout", "1s", "test prompt")
+		if exit != 7 {
+			t.Fatalf("exit %d, want 7\nstderr: %s", exit, stderr)
+		}
+		for _, want := range []string{`"msg":"turn_timeout"`, `"timeout_ms":1000`, `"verdict":"OK"`, `"last_event_type":"thinking/delta"`} {
+			if !strings.Contains(logContent, want) {
+				t.Errorf("log lacks %s", want)
+			}
+		}
+		if strings.Contains(logContent, `"msg":"hang detected"`) {
+			t.Error("a turn timeout was logged as a hang")
+		}
+	})
+
+	t.Run("a hang first wins", func(t *testing.T) {
+		exit, stderr, logContent := turnTimeout(t, "idle_hang", "", "-p", "--idle-timeout", "1s", "--turn-timeout", "1m", "test prompt")
+		if exit != 2 {
+			t.Fatalf("exit %d, want 2\nstderr: %s", exit, stderr)
+		}
+		if strings.Contains(logContent, `"msg":"turn_timeout"`) {
+			t.Error("turn_timeout logged for a hang")
+		}
+	})
+
+	t.Run("interactive goes on to the next prompt", func(t *testing.T) {
+		exit, stderr, logContent := turnTimeout(t, "slow_normal", "first\nsecond\n", "--idle-timeout", "1m", "--turn-timeout", "1s")
+		if exit != 0 {
+			t.Fatalf("exit %d, want 0 at end of input\nstderr: %s", exit, stderr)
+		}
+		if n := strings.Count(stderr, "turn ran longer than --turn-timeout 1s"); n != 2 {
+			t.Errorf("%d timeouts reported, want 2\nstderr: %s", n, stderr)
+		}
+		if n := strings.Count(logContent, `"msg":"turn_timeout"`); n != 2 {
+			t.Errorf("%d turn_timeout records, want 2", n)
+		}
+	})
 }
 
 // --- Integration test: Tool-timeout hang (AC #3) ---
@@ -172,6 +311,46 @@ func TestIntegration_ToolTimeoutHang(t *testing.T) {
 	if exitErr.ExitCode() != 2 {
 		t.Fatalf("expected exit code 2, got %d\nstderr: %s", exitErr.ExitCode(), stderr.String())
 	}
+
+	// The overdue call is reported exactly once, however many ticks see it.
+	logContent := readLogFile(t, logDir)
+	if n := strings.Count(logContent, `"msg":"tool_overdue"`); n != 1 {
+		t.Errorf("expected 1 tool_overdue record, got %d", n)
+	}
+	if !strings.Contains(logContent, `"command":"sleep 999"`) {
+		t.Error("expected overdue command in tool_overdue record")
+	}
+}
+
+// --- Integration test: event at the idle deadline is debounced ---
+
+func TestIntegration_EventAtDeadlineIsNotAHang(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "1s",
+		"--tool-grace", "1s",
+		"--tick-interval", "250ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	// The agent's next event lands within a few ms of the idle deadline,
+	// inside the debounce window.
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=event_at_deadline", "FAKE_AGENT_DELAY=1s")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper should not declare a hang: %v\nstderr: %s", err, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"type":"result"`) {
+		t.Errorf("expected result event in output:\n%s", stdout.String())
+	}
 }
 
 // --- Integration test: Transparent proxy stream-json (AC #8) ---
@@ -310,6 +489,275 @@ func TestIntegration_MultiTurn(t *testing.T) {
 	if !strings.Contains(logContent, "test-session-id") {
 		t.Errorf("expected test-session-id in log file\nlog:\n%s", logContent)
 	}
+
+	// Each turn's records carry its number: the raw events, the agent's
+	// stderr and its exit all say which turn they belong to.
+	perTurn := map[float64]map[string]int{}
+	lastSeq := map[float64]float64{} // each turn numbers its events from 1
+	for _, line := range nonEmptyLines(logContent) {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line: %v\n%s", err, line)
+		}
+		turn, ok := rec["turn"].(float64)
+		if !ok {
+			continue
+		}
+		if perTurn[turn] == nil {
+			perTurn[turn] = map[string]int{}
+		}
+		msg, _ := rec["msg"].(string)
+		perTurn[turn][msg]++
+		if msg == "raw_event" {
+			if seq, _ := rec["seq"].(float64); seq != lastSeq[turn]+1 {
+				t.Errorf("turn %v raw_event seq %v after %v: %s", turn, rec["seq"], lastSeq[turn], line)
+			}
+			lastSeq[turn]++
+		}
+		if msg == "stderr" && strings.Contains(rec["line"].(string), "--resume") && turn != 2 {
+			t.Errorf("turn %v record mentions --resume; only turn 2 resumes: %s", turn, line)
+		}
+		if msg == "turn_start" {
+			// Each turn says what it was asked and how the agent was run.
+			wantPrompt := map[float64]string{1: "first prompt", 2: "second prompt"}[turn]
+			resumed := turn == 2
+			args := fmt.Sprint(rec["agent_args"])
+			if rec["prompt"] != wantPrompt || rec["resume"] != resumed || strings.Contains(args, "--resume test-session-id") != resumed {
+				t.Errorf("turn %v: turn_start = %s", turn, line)
+			}
+			if resumed && rec["resume_session_id"] != "test-session-id" {
+				t.Errorf("turn 2 resume_session_id = %v", rec["resume_session_id"])
+			}
+		}
+	}
+	for _, turn := range []float64{1, 2} {
+		for _, msg := range []string{"turn_start", "raw_event", "agent started", "cursor-agent exited", "event queue"} {
+			if perTurn[turn][msg] == 0 {
+				t.Errorf("no %q record with turn=%v; per-turn records: %v", msg, turn, perTurn)
+			}
+		}
+	}
+}
+
+func TestIntegration_FlagsCommandAppliesToOneTurn(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"--model", "session-model",
+		"--", "--session-flag",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=multi_turn")
+	cmd.Stdin = strings.NewReader("first prompt\n/flags --model turn-model --turn-flag\nsecond prompt\nthird prompt\n")
+	cmd.Stdout = io.Discard
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr:\n%s", err, stderr.String())
+	}
+
+	// The fake agent reports its argv, which the log records per turn.
+	logContent := readLogFile(t, logDir)
+	re := regexp.MustCompile(`fake-agent args: ([^"]*)`)
+	matches := re.FindAllStringSubmatch(logContent, -1)
+	if len(matches) != 3 {
+		t.Fatalf("expected 3 turns of args, got %d", len(matches))
+	}
+	for i, want := range []string{"--model session-model --session-flag", "--model turn-model --session-flag --turn-flag", "--model session-model --session-flag"} {
+		if got := matches[i][1]; !strings.HasSuffix(got, want) {
+			t.Errorf("turn %d args = %q, want suffix %q", i+1, got, want)
+		}
+	}
+	if strings.Contains(logContent, "fake-agent prompt: /flags") {
+		t.Error("the /flags line was sent to the agent as a prompt")
+	}
+}
+
+func TestIntegration_SlashCommands(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=multi_turn")
+	cmd.Stdin = strings.NewReader("first prompt\n/retry\n/session\n/nope\n/resume other-session\nthird prompt\n/exit\nnever sent\n")
+	cmd.Stdout = io.Discard
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr:\n%s", err, stderr.String())
+	}
+
+	logContent := readLogFile(t, logDir)
+	prompts := regexp.MustCompile(`fake-agent prompt: ([^"]*)`).FindAllStringSubmatch(logContent, -1)
+	args := regexp.MustCompile(`fake-agent args: ([^"]*)`).FindAllStringSubmatch(logContent, -1)
+	if len(prompts) != 3 || len(args) != 3 {
+		t.Fatalf("got %d prompts and %d args, want 3 turns\nstderr:\n%s", len(prompts), len(args), stderr.String())
+	}
+	// /retry sent the first prompt again; /exit quit before "never sent".
+	for i, want := range []string{"first prompt", "first prompt", "third prompt"} {
+		if got := strings.TrimSuffix(prompts[i][1], `\n`); got != want {
+			t.Errorf("turn %d prompt = %q, want %q", i+1, got, want)
+		}
+	}
+	if !strings.Contains(args[1][1], "--resume test-session-id") || !strings.Contains(args[2][1], "--resume other-session") {
+		t.Errorf("turns resumed %q and %q; want the agent's session, then the /resume one", args[1][1], args[2][1])
+	}
+	for _, want := range []string{"session: test-session-id\nlog: ", "✗ unknown command /nope", "resuming session other-session"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr lacks %q:\n%s", want, stderr.String())
+		}
+	}
+	for _, want := range []string{`"command":"/retry"`, `"command":"/session"`, `"command":"/exit"`} {
+		if !strings.Contains(logContent, want) {
+			t.Errorf("log lacks the %s decision", want)
+		}
+	}
+}
+
+func TestIntegration_AgentBinaryChangedBetweenTurns(t *testing.T) {
+	logDir := t.TempDir()
+	binDir := t.TempDir()
+	agent := filepath.Join(binDir, "cursor-agent")
+	orig, err := os.ReadFile(fakeAgentBin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(agent, orig, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", agent,
+		"--idle-timeout", "5s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"--hash-agent-bin",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=multi_turn")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = io.WriteString(stdin, "first prompt\n")
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), `"type":"result"`) {
+			break // first turn done
+		}
+	}
+
+	// Update the binary the way an installer does: write the new version
+	// alongside and rename it into place.
+	update := filepath.Join(binDir, "cursor-agent.new")
+	if err := os.WriteFile(update, append(orig, 0), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(update, agent); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = io.WriteString(stdin, "second prompt\n")
+	_ = stdin.Close()
+	_, _ = io.Copy(io.Discard, stdout)
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr:\n%s", err, stderr.String())
+	}
+
+	if !strings.Contains(stderr.String(), "cursor-agent changed since the last turn") {
+		t.Errorf("expected an interactive notice, stderr:\n%s", stderr.String())
+	}
+	var records, changed int
+	for _, line := range strings.Split(readLogFile(t, logDir), "\n") {
+		var rec map[string]any
+		if json.Unmarshal([]byte(line), &rec) != nil {
+			continue
+		}
+		switch rec["msg"] {
+		case "agent binary":
+			records++
+			if s, _ := rec["sha256"].(string); len(s) != 64 {
+				t.Errorf("agent binary record without sha256: %v", rec)
+			}
+		case "agent binary changed since the last turn":
+			changed++
+			if rec["size"] != rec["previous_size"].(float64)+1 {
+				t.Errorf("size %v, previous_size %v: want one byte larger", rec["size"], rec["previous_size"])
+			}
+		}
+	}
+	if records != 2 || changed != 1 {
+		t.Errorf("got %d agent binary records and %d change warnings, want 2 and 1", records, changed)
+	}
+}
+
+func TestIntegration_PidfileLifecycle(t *testing.T) {
+	logDir := t.TempDir()
+	pidfile := filepath.Join(t.TempDir(), "agent.pid")
+	// A leftover from a crashed run warns but doesn't stop the session.
+	if err := os.WriteFile(pidfile, []byte("999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--pidfile", pidfile,
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=multi_turn", "FAKE_AGENT_PIDFILE="+pidfile)
+	cmd.Stdin = strings.NewReader("first prompt\nsecond prompt\n")
+	cmd.Stdout = io.Discard
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr:\n%s", err, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "stale pidfile") {
+		t.Errorf("expected stale pidfile warning, stderr:\n%s", stderr.String())
+	}
+
+	// Each turn's agent saw its own pid in the pidfile.
+	re := regexp.MustCompile(`fake-agent pid: (\d+) pidfile: (\d*)`)
+	matches := re.FindAllStringSubmatch(readLogFile(t, logDir), -1)
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 pid reports, got %d", len(matches))
+	}
+	for i, m := range matches {
+		if m[1] != m[2] {
+			t.Errorf("turn %d: agent pid %s, pidfile %q", i+1, m[1], m[2])
+		}
+	}
+	if matches[0][1] == matches[1][1] {
+		t.Errorf("expected a new pid per turn, both were %s", matches[0][1])
+	}
+
+	if _, err := os.Stat(pidfile); !os.IsNotExist(err) {
+		t.Errorf("expected pidfile removed at exit, stat err = %v", err)
+	}
 }
 
 // --- Integration test: Hang recovery in interactive mode (AC #12) ---
@@ -354,14 +802,11 @@ func TestIntegration_HangRecoveryInteractive(t *testing.T) {
 	}
 }
 
-// --- Integration test: Hang recovery with --prompt-after-hang ---
+// --- Integration test: stderr tail in hang report ---
 
-func TestIntegration_HangRecoveryWithPromptAfterHang(t *testing.T) {
+func TestIntegration_HangReportIncludesStderrTail(t *testing.T) {
 	logDir := t.TempDir()
 
-	// Only provide one prompt via stdin — the second prompt comes from --prompt-after-hang.
-	stdinContent := "hang prompt\n"
-
 	cmd := exec.Command(wrapperBin,
 		"--agent-bin", fakeAgentBin,
 		"--idle-timeout", "1s",
@@ -369,61 +814,350 @@ func TestIntegration_HangRecoveryWithPromptAfterHang(t *testing.T) {
 		"--tick-interval", "500ms",
 		"--log-dir", logDir,
 		"--output-format", "stream-json",
-		"--prompt-after-hang", "continue",
 	)
-	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=hang_then_normal")
-	cmd.Stdin = strings.NewReader(stdinContent)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=stderr_then_hang")
+	cmd.Stdin = strings.NewReader("hang prompt\n")
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
-	if err != nil {
-		t.Fatalf("wrapper should exit 0 after auto-recovery: %v\nstderr: %s",
-			err, stderr.String())
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
 	}
 
-	output := stdout.String()
-
-	// Should contain a hang indicator from the first turn.
-	if !strings.Contains(output, "hang_detected") {
-		t.Errorf("expected hang_detected event in output:\n%s", output)
+	var indicator struct {
+		Subtype    string   `json:"subtype"`
+		StderrTail []string `json:"stderr_tail"`
 	}
-
-	// Should contain a result event from the second (auto-recovered) turn.
-	if !strings.Contains(output, `"type":"result"`) {
-		t.Errorf("expected result event from second turn in output:\n%s", output)
+	for _, line := range nonEmptyLines(stdout.String()) {
+		if strings.Contains(line, "hang_detected") {
+			if err := json.Unmarshal([]byte(line), &indicator); err != nil {
+				t.Fatalf("invalid hang indicator JSON: %v\n%s", err, line)
+			}
+		}
+	}
+	if indicator.Subtype != "hang_detected" {
+		t.Fatalf("no hang_detected indicator in output:\n%s", stdout.String())
+	}
+	if !strings.Contains(strings.Join(indicator.StderrTail, "\n"), "upstream connection reset") {
+		t.Errorf("stderr_tail = %v, want the agent's connection reset line", indicator.StderrTail)
 	}
 
-	// Verify the auto-prompt was logged.
 	logContent := readLogFile(t, logDir)
-	if !strings.Contains(logContent, "using prompt-after-hang") {
-		t.Errorf("expected 'using prompt-after-hang' in log file\nlog:\n%s", logContent)
+	if !strings.Contains(logContent, `"stderr_tail_`) {
+		t.Errorf("expected stderr_tail attrs on hang record in log\nlog:\n%s", logContent)
 	}
 }
 
-// --- Integration test: Log file output (AC #6, #7) ---
+// --- Integration test: fatal stderr pattern aborts early ---
 
-func TestIntegration_LogFileOutput(t *testing.T) {
+func TestIntegration_FatalStderrAbortsEarly(t *testing.T) {
 	logDir := t.TempDir()
 
 	cmd := exec.Command(wrapperBin,
 		"-p",
 		"--agent-bin", fakeAgentBin,
-		"--idle-timeout", "5s",
+		"--idle-timeout", "30s",
 		"--tool-grace", "1s",
 		"--tick-interval", "500ms",
 		"--log-dir", logDir,
 		"--output-format", "stream-json",
 		"test prompt",
 	)
-	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=fatal_stderr")
+
+	var stderr bytes.Buffer
 	cmd.Stdout = io.Discard
-	cmd.Stderr = io.Discard
+	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
-		t.Fatalf("wrapper exited with error: %v", err)
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start)
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+	}
+	if exitErr.ExitCode() != 5 {
+		t.Fatalf("expected exit code 5, got %d\nstderr: %s", exitErr.ExitCode(), stderr.String())
+	}
+	// Well under the 30s idle timeout; the kill grace is the upper bound.
+	if elapsed > 10*time.Second {
+		t.Errorf("wrapper took %v, expected an early abort", elapsed)
+	}
+
+	logContent := readLogFile(t, logDir)
+	if !strings.Contains(logContent, "fatal stderr pattern matched") {
+		t.Errorf("expected fatal stderr decision in log\nlog:\n%s", logContent)
+	}
+}
+
+func TestIntegration_FatalStderrInteractivePrintsLine(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "30s",
+		"--tool-grace", "1s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "text",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=fatal_stderr")
+	cmd.Stdin = strings.NewReader("test prompt\n")
+
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("interactive mode should recover and exit 0 on EOF: %v\nstderr: %s", err, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "not authenticated") {
+		t.Errorf("expected matched line on stderr, got:\n%s", stderr.String())
+	}
+}
+
+func TestIntegration_MaxOutputBytes(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "30s",
+		"--tick-interval", "500ms",
+		"--kill-grace", "500ms",
+		"--max-output-bytes", "64K",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=huge_tool_result")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed := time.Since(start)
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+	}
+	if exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1, got %d\nstderr: %s", exitErr.ExitCode(), stderr.String())
+	}
+	if elapsed > 10*time.Second {
+		t.Errorf("wrapper took %v, expected an early abort", elapsed)
+	}
+	if !strings.Contains(stderr.String(), "output limit exceeded") {
+		t.Errorf("expected the output limit error on stderr, got:\n%s", stderr.String())
+	}
+	// The events before the huge one are still forwarded.
+	if !strings.Contains(stdout.String(), `"subtype":"started"`) {
+		t.Errorf("expected tool_call/started on stdout, got:\n%s", stdout.String())
+	}
+
+	logContent := readLogFile(t, logDir)
+	var limitRec map[string]any
+	for _, line := range strings.Split(logContent, "\n") {
+		var rec map[string]any
+		if json.Unmarshal([]byte(line), &rec) == nil && rec["msg"] == "output limit exceeded" {
+			limitRec = rec
+			break
+		}
+	}
+	if limitRec == nil {
+		t.Fatalf("expected output limit record in log\nlog:\n%s", logContent)
+	}
+	if limitRec["bytes_read"] != float64(64<<10) {
+		t.Errorf("bytes_read = %v, want %d", limitRec["bytes_read"], 64<<10)
+	}
+	if limitRec["in_flight_type"] != "tool_call" {
+		t.Errorf("in_flight_type = %v, want tool_call", limitRec["in_flight_type"])
+	}
+	if len(logContent) > 1<<20 {
+		t.Errorf("log is %d bytes; the huge event should not have been logged", len(logContent))
+	}
+}
+
+// --- Integration test: Hang recovery with --prompt-after-hang ---
+
+func TestIntegration_HangRecoveryWithPromptAfterHang(t *testing.T) {
+	logDir := t.TempDir()
+
+	// Only provide one prompt via stdin — the second prompt comes from --prompt-after-hang.
+	stdinContent := "hang prompt\n"
+
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "1s",
+		"--tool-grace", "1s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"--prompt-after-hang", "continue",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=hang_then_normal")
+	cmd.Stdin = strings.NewReader(stdinContent)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		t.Fatalf("wrapper should exit 0 after auto-recovery: %v\nstderr: %s",
+			err, stderr.String())
+	}
+
+	output := stdout.String()
+
+	// Should contain a hang indicator from the first turn.
+	if !strings.Contains(output, "hang_detected") {
+		t.Errorf("expected hang_detected event in output:\n%s", output)
+	}
+
+	// Should contain a result event from the second (auto-recovered) turn.
+	if !strings.Contains(output, `"type":"result"`) {
+		t.Errorf("expected result event from second turn in output:\n%s", output)
+	}
+
+	// Verify the auto-prompt was logged.
+	logContent := readLogFile(t, logDir)
+	if !strings.Contains(logContent, "using prompt-after-hang") {
+		t.Errorf("expected 'using prompt-after-hang' in log file\nlog:\n%s", logContent)
+	}
+}
+
+func TestIntegration_HangWebhook(t *testing.T) {
+	notify := func(t *testing.T, scenario string, args ...string) (exit int, payload map[string]any) {
+		t.Helper()
+		got := make(chan map[string]any, 4)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var p map[string]any
+			if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+				t.Errorf("decoding payload: %v", err)
+			}
+			got <- p
+		}))
+		defer srv.Close()
+		cmd := exec.Command(wrapperBin, append([]string{
+			"-p",
+			"--agent-bin", fakeAgentBin,
+			"--idle-timeout", "1s",
+			"--tool-grace", "1s",
+			"--tick-interval", "200ms",
+			"--kill-grace", "500ms",
+			"--log-dir", t.TempDir(),
+			"--hang-webhook", srv.URL + "/hooks/T0/secret",
+		}, append(args, "review the diff")...)...)
+		cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO="+scenario)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		_ = cmd.Run()
+		// Sent before the wrapper exits.
+		select {
+		case payload = <-got:
+		default:
+			t.Fatalf("no notification\nstderr: %s", stderr.String())
+		}
+		if len(got) != 0 {
+			t.Errorf("more than one notification: %v", <-got)
+		}
+		return cmd.ProcessState.ExitCode(), payload
+	}
+
+	t.Run("hang", func(t *testing.T) {
+		exit, p := notify(t, "idle_hang")
+		if exit != 2 {
+			t.Errorf("exit %d, want 2", exit)
+		}
+		reason, _ := p["reason"].(map[string]any)
+		if p["event"] != "hang" || p["session_id"] != "test-session-id" || p["prompt"] != "review the diff" ||
+			reason == nil || reason["idle_silence_ms"].(float64) < 1000 || !strings.HasSuffix(p["log_file"].(string), ".jsonl") {
+			t.Errorf("payload = %v", p)
+		}
+	})
+
+	t.Run("abnormal exit", func(t *testing.T) {
+		exit, p := notify(t, "crash_then_resume", "--webhook-abnormal-exit")
+		if exit != 3 {
+			t.Errorf("exit %d, want 3", exit)
+		}
+		if p["event"] != "abnormal_exit" || p["session_id"] != "test-session-id" || p["reason"] != nil ||
+			!strings.Contains(p["error"].(string), "abnormal exit") {
+			t.Errorf("payload = %v", p)
+		}
+	})
+}
+
+func TestIntegration_MaxHangRetries(t *testing.T) {
+	logDir := t.TempDir()
+
+	// The agent hangs every time, so the one retry allowed hangs too.
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "1s",
+		"--tool-grace", "1s",
+		"--tick-interval", "200ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"--prompt-after-hang", "continue",
+		"--max-hang-retries", "1",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=idle_hang")
+	cmd.Stdin = strings.NewReader("hang prompt\nnever sent\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+		t.Fatalf("want exit code 2 after giving up on the hang, got %v\nstderr: %s", err, stderr.String())
+	}
+	if n := strings.Count(stdout.String(), "hang_detected"); n != 2 {
+		t.Errorf("%d hangs in the output, want 2 (the turn and its one retry):\n%s", n, stdout.String())
+	}
+
+	logContent := readLogFile(t, logDir)
+	if n := strings.Count(logContent, `"msg":"using prompt-after-hang"`); n != 1 {
+		t.Errorf("%d retries logged, want 1\nlog:\n%s", n, logContent)
+	}
+	if !strings.Contains(logContent, `"msg":"max hang retries exceeded","schema_version"`) ||
+		!strings.Contains(logContent, `"retries":1,"max_retries":1`) {
+		t.Errorf("giving up, and the limit, not logged\nlog:\n%s", logContent)
+	}
+	if strings.Contains(logContent, "never sent") {
+		t.Error("the next prompt was read after giving up")
+	}
+}
+
+// --- Integration test: Log file output (AC #6, #7) ---
+
+func TestIntegration_LogFileOutput(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--tool-grace", "1s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v", err)
 	}
 
 	// Find the log file.
@@ -461,6 +1195,21 @@ func TestIntegration_LogFileOutput(t *testing.T) {
 			json.Unmarshal(msgRaw, &msg)
 		}
 
+		// Every record carries the schema contract (see internal/logger/types.go).
+		var kind string
+		json.Unmarshal(record["kind"], &kind)
+		if string(record["schema_version"]) != strconv.Itoa(logger.SchemaVersion) || len(record["wrapper_version"]) <= 2 {
+			t.Errorf("record missing schema_version/wrapper_version: %s", line)
+		}
+		switch kind {
+		case logger.KindRawEvent, logger.KindAgentStderr, logger.KindVerdict, logger.KindTurnStats, logger.KindConfig, logger.KindDecision:
+		default:
+			t.Errorf("record kind = %q: %s", kind, line)
+		}
+		if (msg == "raw_event") != (kind == logger.KindRawEvent) {
+			t.Errorf("raw_event record kind mismatch: %s", line)
+		}
+
 		if msg == "raw_event" {
 			rawEventCount++
 			// Verify recv_ts is present.
@@ -475,6 +1224,29 @@ func TestIntegration_LogFileOutput(t *testing.T) {
 			if !json.Valid(rawField) {
 				t.Errorf("raw field is not valid JSON: %s", rawField)
 			}
+			// The monitor's reaction is on the same record.
+			var ev struct{ Type, Subtype string }
+			var verdict string
+			var openCalls int
+			var openCallIDs []string
+			json.Unmarshal(rawField, &ev)
+			json.Unmarshal(record["verdict"], &verdict)
+			json.Unmarshal(record["open_calls"], &openCalls)
+			json.Unmarshal(record["open_call_ids"], &openCallIDs)
+			switch ev.Type + "/" + ev.Subtype {
+			case "tool_call/started":
+				if verdict != "Waiting" || openCalls != 1 || len(openCallIDs) != 1 {
+					t.Errorf("tool_call/started: verdict = %q, open_calls = %d, open_call_ids = %v; want Waiting, 1 and its id", verdict, openCalls, openCallIDs)
+				}
+			case "tool_call/completed":
+				if verdict != "OK" || openCalls != 0 || openCallIDs != nil {
+					t.Errorf("tool_call/completed: verdict = %q, open_calls = %d, open_call_ids = %v; want OK and none", verdict, openCalls, openCallIDs)
+				}
+			default:
+				if verdict == "" {
+					t.Errorf("raw_event record missing verdict: %s", line)
+				}
+			}
 		}
 	}
 
@@ -560,91 +1332,2010 @@ func TestIntegration_SignalHandling(t *testing.T) {
 	// for the child and cleaned up. This is sufficient.
 }
 
-// --- Integration test: --resume on initial invocation ---
+// --- Integration test: SIGUSR1 prints a status report ---
 
-func TestIntegration_ResumeOnFirstTurn(t *testing.T) {
+func TestIntegration_StatusOnSIGUSR1(t *testing.T) {
 	logDir := t.TempDir()
+	pidfile := filepath.Join(t.TempDir(), "agent.pid")
 
 	cmd := exec.Command(wrapperBin,
 		"-p",
 		"--agent-bin", fakeAgentBin,
-		"--idle-timeout", "5s",
-		"--tool-grace", "1s",
-		"--tick-interval", "500ms",
+		"--idle-timeout", "30s",
+		"--tick-interval", "250ms",
+		"--pidfile", pidfile,
 		"--log-dir", logDir,
 		"--output-format", "stream-json",
-		"--resume", "sess-pre-seeded-456",
-		"continue where we left off",
+		"test prompt",
 	)
-	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=slow_normal")
+	cmd.Stdout = io.Discard
+	stderrPipe, err := cmd.StderrPipe()
 	if err != nil {
-		t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+		t.Fatal(err)
 	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start wrapper: %v", err)
+	}
+	lines := make(chan string, 100)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(stderrPipe)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+	waitForPidfile(t, pidfile)
+	time.Sleep(500 * time.Millisecond)
 
-	// Verify --resume was passed to cursor-agent on the first (and only) turn
-	// by checking the log file where fake-agent's stderr (args) are captured.
-	logContent := readLogFile(t, logDir)
-	if !strings.Contains(logContent, "--resume") {
-		t.Errorf("expected --resume in log file for first turn\nlog:\n%s", logContent)
+	if err := cmd.Process.Signal(syscall.SIGUSR1); err != nil {
+		t.Fatalf("failed to send SIGUSR1: %v", err)
 	}
-	if !strings.Contains(logContent, "sess-pre-seeded-456") {
-		t.Errorf("expected sess-pre-seeded-456 in log file\nlog:\n%s", logContent)
+	// The report is written at once: it ends at the first quiet spell.
+	var report []string
+	timeout := time.After(5 * time.Second)
+collect:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("wrapper stderr closed before a status report; got %q", report)
+			}
+			if strings.HasPrefix(line, "cursor-wrap status:") || (len(report) > 0 && strings.HasPrefix(line, "  ")) {
+				report = append(report, line)
+				timeout = time.After(200 * time.Millisecond)
+			}
+		case <-timeout:
+			if len(report) > 0 {
+				break collect
+			}
+			t.Fatal("no status report within 5s of SIGUSR1")
+		}
+	}
+	// The wrapper carries on after reporting.
+	if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
+		t.Fatalf("failed to send SIGINT: %v", err)
+	}
+	go func() {
+		for range lines {
+		}
+	}()
+	_ = cmd.Wait()
+
+	text := strings.Join(report, "\n")
+	for _, want := range []string{
+		"turn 1, session test-session-id,",
+		"last event ",
+		"verdict OK",
+		"events: ",
+		"system 1",
+		"agent stderr:",
+		"fake-agent",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("report lacks %q:\n%s", want, text)
+		}
+	}
+	logContent := readLogFile(t, logDir)
+	if !strings.Contains(logContent, `"msg":"status"`) || !strings.Contains(logContent, `"event_counts":{`) {
+		t.Errorf("expected a status record in the log\nlog:\n%s", logContent)
 	}
 }
 
-// --- Integration test: -p flag behavior (AC #14) ---
+// --- Integration test: SIGHUP reloads the timeouts ---
 
-func TestIntegration_PrintModeSingleTurn(t *testing.T) {
+func TestIntegration_ReloadOnSIGHUP(t *testing.T) {
 	logDir := t.TempDir()
+	pidfile := filepath.Join(t.TempDir(), "agent.pid")
+	configFile := filepath.Join(t.TempDir(), "cursor-wrap.toml")
+	writeConfigFile(t, configFile, `idle-timeout = "30s"
+tick-interval = "250ms"
+output-format = "stream-json"
+`)
 
 	cmd := exec.Command(wrapperBin,
 		"-p",
 		"--agent-bin", fakeAgentBin,
-		"--idle-timeout", "5s",
-		"--tool-grace", "1s",
-		"--tick-interval", "500ms",
+		"--config", configFile,
+		"--kill-grace", "500ms",
+		"--pidfile", pidfile,
 		"--log-dir", logDir,
-		"--output-format", "stream-json",
 		"test prompt",
 	)
-	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
-
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = io.Discard
+	// slow_normal goes quiet after its first events, well within 30s.
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=slow_normal")
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start wrapper: %v", err)
+	}
+	waitForPidfile(t, pidfile)
+	time.Sleep(500 * time.Millisecond)
 
-	if err := cmd.Run(); err != nil {
-		t.Fatalf("wrapper exited with error: %v", err)
+	writeConfigFile(t, configFile, `idle-timeout = "1s"
+tick-interval = "250ms"
+output-format = "text"
+`)
+	reloaded := time.Now()
+	if err := cmd.Process.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to send SIGHUP: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(10 * time.Second):
+		_ = cmd.Process.Kill()
+		<-done
+		t.Fatalf("no hang within 10s of reloading a 1s idle timeout\nstderr: %s", stderr.String())
 	}
 
-	// With -p, only one turn should execute. Only one result event expected.
-	resultCount := strings.Count(stdout.String(), `"type":"result"`)
-	if resultCount != 1 {
-		t.Fatalf("expected exactly 1 result event with -p, got %d", resultCount)
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 2 {
+		t.Fatalf("want exit code 2 from the reloaded idle timeout, got %v\nstderr: %s", err, stderr.String())
+	}
+	if elapsed := time.Since(reloaded); elapsed > 5*time.Second {
+		t.Errorf("hang declared %v after the reload; want it on a tick soon after the new 1s timeout", elapsed)
+	}
+	logContent := readLogFile(t, logDir)
+	for _, want := range []string{
+		`"setting":"idle-timeout","old":"30s","new":"1s"`,
+		`"msg":"config reload ignored a setting that can't change while running"`,
+		`"setting":"output-format","old":"stream-json","new":"text"`,
+		`"msg":"hang detected"`,
+	} {
+		if !strings.Contains(logContent, want) {
+			t.Errorf("log lacks %s\nlog:\n%s", want, logContent)
+		}
+	}
+}
+
+// --- Integration test: Ctrl+Z suspends the agent too ---
+
+func TestIntegration_SuspendAndResume(t *testing.T) {
+	logDir := t.TempDir()
+	pidfile := filepath.Join(t.TempDir(), "agent.pid")
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "3s",
+		"--tick-interval", "250ms",
+		"--kill-grace", "500ms",
+		"--pidfile", pidfile,
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	// slow_normal goes quiet after its first events.
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=slow_normal")
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start wrapper: %v", err)
+	}
+	agentPID := waitForPidfile(t, pidfile)
+	time.Sleep(500 * time.Millisecond)
+
+	if err := cmd.Process.Signal(syscall.SIGTSTP); err != nil {
+		t.Fatalf("failed to send SIGTSTP: %v", err)
+	}
+	waitForState(t, cmd.Process.Pid, 'T')
+	waitForState(t, agentPID, 'T')
+
+	// Suspended for longer than the idle timeout.
+	time.Sleep(4 * time.Second)
+	if err := cmd.Process.Signal(syscall.SIGCONT); err != nil {
+		t.Fatalf("failed to send SIGCONT: %v", err)
+	}
+	resumed := time.Now()
+	err := cmd.Wait()
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+	}
+	if exitErr.ExitCode() != 2 {
+		t.Fatalf("expected exit code 2 from the eventual idle hang, got %d\nstderr: %s", exitErr.ExitCode(), stderr.String())
+	}
+	// Had the suspension counted as silence, the hang would have been
+	// declared on the first tick after SIGCONT.
+	if elapsed := time.Since(resumed); elapsed < 1500*time.Millisecond {
+		t.Errorf("hang declared %v after resuming; the suspension was counted as idle time", elapsed)
+	}
+
+	logContent := readLogFile(t, logDir)
+	if !strings.Contains(logContent, `"msg":"suspended"`) {
+		t.Errorf("expected suspended record in log\nlog:\n%s", logContent)
+	}
+	re := regexp.MustCompile(`"msg":"resumed","schema_version":1,"wrapper_version":"[^"]*","host":"[^"]*","wrapper_pid":\d+,"user":"[^"]*","turn":1,"kind":"decision","suspended_ms":(\d+)`)
+	m := re.FindStringSubmatch(logContent)
+	if m == nil {
+		t.Fatalf("expected resumed record in log\nlog:\n%s", logContent)
+	}
+	if ms, _ := strconv.Atoi(m[1]); ms < 4000 {
+		t.Errorf("suspended_ms = %d, want at least 4000", ms)
+	}
+}
+
+// --- Integration test: --resume on initial invocation ---
+
+func TestIntegration_ResumeOnFirstTurn(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--tool-grace", "1s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"--resume", "sess-pre-seeded-456",
+		"continue where we left off",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+	}
+
+	// Verify --resume was passed to cursor-agent on the first (and only) turn
+	// by checking the log file where fake-agent's stderr (args) are captured.
+	logContent := readLogFile(t, logDir)
+	if !strings.Contains(logContent, "--resume") {
+		t.Errorf("expected --resume in log file for first turn\nlog:\n%s", logContent)
+	}
+	if !strings.Contains(logContent, "sess-pre-seeded-456") {
+		t.Errorf("expected sess-pre-seeded-456 in log file\nlog:\n%s", logContent)
+	}
+}
+
+// --- Integration test: --resume-last picks up the previous run's session ---
+
+func TestIntegration_ResumeLast(t *testing.T) {
+	logDir := t.TempDir()
+	run := func(args ...string) (string, int) {
+		t.Helper()
+		cmd := exec.Command(wrapperBin, append([]string{
+			"-p",
+			"--agent-bin", fakeAgentBin,
+			"--idle-timeout", "5s",
+			"--tick-interval", "500ms",
+			"--log-dir", logDir,
+		}, args...)...)
+		cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		_ = cmd.Run() // the exit code says how it went
+		return stderr.String(), cmd.ProcessState.ExitCode()
+	}
+
+	stderr, code := run("--resume-last", "go on")
+	if code != 1 || !strings.Contains(stderr, "--resume-last: no earlier session has a log in "+logDir) {
+		t.Fatalf("with no earlier session: exit %d, stderr:\n%s", code, stderr)
+	}
+
+	if stderr, code := run("first prompt"); code != 0 {
+		t.Fatalf("first run: exit %d\nstderr: %s", code, stderr)
+	}
+	stderr, code = run("--resume-last", "go on")
+	if code != 0 {
+		t.Fatalf("second run: exit %d\nstderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "cursor-wrap: resuming session test-session-id") {
+		t.Errorf("stderr doesn't say which session was picked:\n%s", stderr)
+	}
+	// Resumed as --resume would: the agent got --resume and the session's
+	// log was appended to.
+	logs, _ := filepath.Glob(filepath.Join(logDir, "cursor-wrap-*.jsonl"))
+	if len(logs) != 1 {
+		t.Fatalf("logs = %v, want the first run's only", logs)
+	}
+	logContent := readLogFile(t, logDir)
+	if !strings.Contains(logContent, "--resume test-session-id") {
+		t.Errorf("agent not resumed with test-session-id\nlog:\n%s", logContent)
+	}
+
+	stderr, code = run("--resume-last=2", "go on")
+	if code != 1 || !strings.Contains(stderr, "--resume-last=2: only 1 earlier sessions") {
+		t.Errorf("--resume-last=2 with one session: exit %d, stderr:\n%s", code, stderr)
+	}
+	stderr, code = run("--resume", "sess-1", "--resume-last", "go on")
+	if code != 1 || !strings.Contains(stderr, "--resume and --resume-last both pick") {
+		t.Errorf("--resume with --resume-last: exit %d, stderr:\n%s", code, stderr)
+	}
+}
+
+// --- Integration test: -p flag behavior (AC #14) ---
+
+func TestIntegration_PrintModeSingleTurn(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--tool-grace", "1s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = io.Discard
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v", err)
+	}
+
+	// With -p, only one turn should execute. Only one result event expected.
+	resultCount := strings.Count(stdout.String(), `"type":"result"`)
+	if resultCount != 1 {
+		t.Fatalf("expected exactly 1 result event with -p, got %d", resultCount)
+	}
+}
+
+// --- Integration test: missing --cwd fails at startup ---
+
+func TestIntegration_MissingCwdFailsAtStartup(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "does-not-exist")
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--cwd", missing,
+		"--log-dir", t.TempDir(),
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+	}
+	if exitErr.ExitCode() != 4 {
+		t.Fatalf("expected exit code 4 (startup failure), got %d", exitErr.ExitCode())
+	}
+	if !strings.Contains(stderr.String(), "agent working directory") || !strings.Contains(stderr.String(), missing) {
+		t.Errorf("expected clear cwd error on stderr, got %q", stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected no agent output, got %q", stdout.String())
+	}
+}
+
+func TestIntegration_InvalidConfigFailsBeforeSpawn(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "-1s",
+		"--output-format", "xml",
+		"--log-dir", logDir,
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+	}
+	if exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1, got %d", exitErr.ExitCode())
+	}
+	// Both problems are reported at once.
+	for _, want := range []string{"--idle-timeout must be positive", `--output-format "xml"`} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr missing %q:\n%s", want, stderr.String())
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected no agent output, got %q", stdout.String())
+	}
+	if entries, _ := os.ReadDir(logDir); len(entries) != 0 {
+		t.Errorf("expected no session log, found %d entries", len(entries))
+	}
+}
+
+func TestIntegration_AgentStderrFile(t *testing.T) {
+	logDir := t.TempDir()
+	stderrFile := filepath.Join(t.TempDir(), "agent-stderr.txt")
+	// Escapes and a multi-line prompt must survive untouched.
+	prompt := "line one\n\tline two \x1b[31mred\x1b[0m"
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--log-dir", logDir,
+		"--agent-stderr-file", stderrFile,
+		prompt,
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v", err)
+	}
+
+	data, err := os.ReadFile(stderrFile)
+	if err != nil {
+		t.Fatalf("reading agent stderr file: %v", err)
+	}
+	if want := "fake-agent prompt: " + prompt + "\n"; !strings.HasSuffix(string(data), want) {
+		t.Errorf("agent stderr file = %q, want suffix %q", data, want)
+	}
+	if !strings.HasPrefix(string(data), "fake-agent args: ") {
+		t.Errorf("agent stderr file = %q, want args echo first", data)
+	}
+	// The session log keeps its per-line debug records too.
+	if !strings.Contains(readLogFile(t, logDir), "fake-agent args") {
+		t.Error("expected stderr lines in the session log as well")
+	}
+}
+
+func TestIntegration_RetryOnAbnormalExit(t *testing.T) {
+	tests := []struct {
+		name     string
+		retries  string
+		wantCode int
+	}{
+		{"disabled", "0", 3},
+		{"resumes after crash", "1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logDir := t.TempDir()
+			cmd := exec.Command(wrapperBin,
+				"-p",
+				"--agent-bin", fakeAgentBin,
+				"--retry-on-abnormal-exit", tt.retries,
+				"--log-dir", logDir,
+				"--output-format", "stream-json",
+				"test prompt",
+			)
+			cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=crash_then_resume")
+			var stdout bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = io.Discard
+
+			err := cmd.Run()
+			code := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("running wrapper: %v", err)
+			}
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d", code, tt.wantCode)
+			}
+			if tt.wantCode != 0 {
+				return
+			}
+
+			if !strings.Contains(stdout.String(), `"type":"result"`) {
+				t.Errorf("expected the resumed turn's result on stdout:\n%s", stdout.String())
+			}
+			logContent := readLogFile(t, logDir)
+			if !strings.Contains(logContent, "agent exited abnormally, resuming turn") {
+				t.Error("expected the retry to be logged")
+			}
+			// The retry resumed the session with the original prompt.
+			if !strings.Contains(logContent, "--resume test-session-id") || strings.Count(logContent, "fake-agent prompt: test prompt") != 2 {
+				t.Errorf("expected a resumed retry with the same prompt\nlog:\n%s", logContent)
+			}
+		})
+	}
+}
+
+func TestIntegration_Remote(t *testing.T) {
+	logDir := t.TempDir()
+	// A local stand-in for ssh: drop the options and host, then run the
+	// remote command string with a local shell.
+	ssh := filepath.Join(t.TempDir(), "ssh")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -o) shift 2 ;;
+    --) shift; break ;;
+    *) break ;;
+  esac
+done
+echo "fake-ssh host: $1" >&2
+exec /bin/sh -c "$2"
+`
+	if err := os.WriteFile(ssh, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--remote", "user@gpu-box",
+		"--ssh-bin", ssh,
+		"--agent-bin", fakeAgentBin,
+		"--env", "FAKE_AGENT_SCENARIO=normal",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"it's a prompt",
+	)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = io.Discard
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v", err)
+	}
+	if !strings.Contains(stdout.String(), `"type":"result"`) {
+		t.Errorf("expected the remote agent's result on stdout:\n%s", stdout.String())
+	}
+	logContent := readLogFile(t, logDir)
+	for _, want := range []string{"fake-ssh host: user@gpu-box", "fake-agent prompt: it's a prompt", `"agent_version":"2026.01.01-fake"`} {
+		if !strings.Contains(logContent, want) {
+			t.Errorf("log missing %q", want)
+		}
+	}
+}
+
+func TestIntegration_PromptFile_AgentReadsReference(t *testing.T) {
+	tests := []struct {
+		scenario string
+		wantCode int
+	}{
+		{"normal", 0},
+		{"idle_hang", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scenario, func(t *testing.T) {
+			logDir := t.TempDir()
+			ws := t.TempDir()
+			prompt := strings.Repeat("a large prompt ", 200)
+			cmd := exec.Command(wrapperBin,
+				"-p",
+				"--agent-bin", fakeAgentBin,
+				"--workspace", ws,
+				"--idle-timeout", "1s",
+				"--tick-interval", "500ms",
+				"--kill-grace", "500ms",
+				"--prompt-file-threshold", "1K",
+				"--log-dir", logDir,
+				prompt,
+			)
+			cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO="+tt.scenario)
+			cmd.Stdout = io.Discard
+			cmd.Stderr = io.Discard
+
+			err := cmd.Run()
+			code := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("running wrapper: %v", err)
+			}
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d", code, tt.wantCode)
+			}
+
+			// The agent got a reference to a file in the workspace on
+			// stdin, not the prompt, and found the whole prompt in it.
+			logContent := readLogFile(t, logDir)
+			dir := filepath.Join(ws, ".cursor-wrap", "prompts")
+			if !strings.Contains(logContent, `"prompt_delivery":"file"`) || !strings.Contains(logContent, "fake-agent prompt: The full prompt is in @"+dir) {
+				t.Errorf("expected the prompt to be delivered by file\nlog:\n%s", logContent)
+			}
+			if strings.Contains(logContent, "fake-agent prompt: a large prompt") {
+				t.Error("the prompt itself reached the agent's stdin")
+			}
+			want := fmt.Sprintf(": %d bytes, sha256 %x, err <nil>", len(prompt), sha256.Sum256([]byte(prompt)))
+			if !strings.Contains(logContent, "fake-agent read @"+dir) || !strings.Contains(logContent, want) {
+				t.Errorf("expected the agent to read the prompt from the file (%s)\nlog:\n%s", want, logContent)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("expected prompt file removed, found %d entries", len(entries))
+			}
+		})
+	}
+}
+
+// --- Integration test: kill signal ---
+
+func TestIntegration_KillSignalCapturesFinalResult(t *testing.T) {
+	tests := []struct {
+		signal     string
+		wantResult bool
+	}{
+		{"term", false},
+		{"int", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.signal, func(t *testing.T) {
+			logDir := t.TempDir()
+			cmd := exec.Command(wrapperBin,
+				"-p",
+				"--agent-bin", fakeAgentBin,
+				"--idle-timeout", "1s",
+				"--tick-interval", "500ms",
+				"--kill-signal", tt.signal,
+				"--kill-grace", "500ms",
+				"--log-dir", logDir,
+				"--output-format", "stream-json",
+				"test prompt",
+			)
+			cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=hang_flush_on_sigint")
+
+			var stdout bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = io.Discard
+
+			err := cmd.Run()
+			exitErr, ok := err.(*exec.ExitError)
+			if !ok {
+				t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+			}
+			// The turn still counts as a hang either way.
+			if exitErr.ExitCode() != 2 {
+				t.Fatalf("expected exit code 2, got %d", exitErr.ExitCode())
+			}
+
+			gotResult := strings.Contains(stdout.String(), `"result":"cancelled"`)
+			if gotResult != tt.wantResult {
+				t.Errorf("final result forwarded = %v, want %v\nstdout:\n%s", gotResult, tt.wantResult, stdout.String())
+			}
+			if tt.wantResult && !strings.Contains(readLogFile(t, logDir), "events drained during kill") {
+				t.Error("expected drained events to be logged")
+			}
+		})
+	}
+}
+
+// --- Integration test: agent outliving the wrapper ---
+
+func TestIntegration_AgentLingersAfterClosingStdout(t *testing.T) {
+	logDir := t.TempDir()
+	agent := filepath.Join(t.TempDir(), "cursor-agent")
+	script := `#!/bin/sh
+cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}'
+echo '{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"session_id":"test-session-id","request_id":"req_1"}'
+exec 1>&-
+exec sleep 60
+`
+	if err := os.WriteFile(agent, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", agent,
+		"--no-preflight",
+		"--idle-timeout", "30s",
+		"--tick-interval", "500ms",
+		"--kill-grace", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("a turn with a result should succeed: %v\nstderr:\n%s", err, stderr.String())
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("wrapper took %v to reap the lingering agent", elapsed)
+	}
+	logContent := readLogFile(t, logDir)
+	if !strings.Contains(logContent, "did not exit in time") || !strings.Contains(logContent, `"killed_by_wrapper":"still running after its output ended"`) {
+		t.Errorf("expected forced reap records in log\nlog:\n%s", logContent)
+	}
+}
+
+func TestIntegration_GrandchildHoldingOutputDoesNotStrandTurn(t *testing.T) {
+	logDir := t.TempDir()
+	dir := t.TempDir()
+	agent := filepath.Join(dir, "cursor-agent")
+	childPid := filepath.Join(dir, "child.pid")
+	// The background sleep inherits stdout and stderr and keeps them open
+	// long after the agent itself is killed for hanging.
+	script := `#!/bin/sh
+cat >/dev/null
+echo '{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}'
+sleep 60 &
+echo $! >` + childPid + `
+exec sleep 60
+`
+	if err := os.WriteFile(agent, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if data, err := os.ReadFile(childPid); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+				_ = syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+	})
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", agent,
+		"--no-preflight",
+		"--idle-timeout", "1s",
+		"--tick-interval", "250ms",
+		"--kill-grace", "500ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(15 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatalf("wrapper stranded on the grandchild's pipes\nstderr:\n%s", stderr.String())
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 2 {
+		t.Fatalf("expected hang exit code 2, got %v\nstderr:\n%s", err, stderr.String())
+	}
+	if elapsed := time.Since(start); elapsed > 8*time.Second {
+		t.Errorf("turn took %v to end", elapsed)
+	}
+	if logContent := readLogFile(t, logDir); strings.Contains(logContent, "read error") {
+		t.Errorf("closing the pipes should not be reported as a read error\nlog:\n%s", logContent)
+	}
+}
+
+func TestIntegration_AgentDiesWithWrapper(t *testing.T) {
+	tests := []struct {
+		name      string
+		extra     []string
+		wantAlive bool
+	}{
+		{"pdeathsig by default", nil, false},
+		{"no-pdeathsig", []string{"--no-pdeathsig"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pidfile := filepath.Join(t.TempDir(), "agent.pid")
+			args := append([]string{
+				"-p",
+				"--agent-bin", fakeAgentBin,
+				"--idle-timeout", "30s",
+				"--log-dir", t.TempDir(),
+				"--pidfile", pidfile,
+			}, tt.extra...)
+			cmd := exec.Command(wrapperBin, append(args, "test prompt")...)
+			cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=idle_hang")
+			cmd.Stdout = io.Discard
+			cmd.Stderr = io.Discard
+			if err := cmd.Start(); err != nil {
+				t.Fatalf("failed to start wrapper: %v", err)
+			}
+
+			pid := waitForPidfile(t, pidfile)
+			t.Cleanup(func() { _ = syscall.Kill(pid, syscall.SIGKILL) })
+
+			// SIGKILL gives the wrapper no chance to clean up.
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+
+			deadline := time.Now().Add(3 * time.Second)
+			for processRunning(pid) && time.Now().Before(deadline) {
+				time.Sleep(50 * time.Millisecond)
+			}
+			if got := processRunning(pid); got != tt.wantAlive {
+				t.Errorf("agent running after wrapper SIGKILL = %v, want %v", got, tt.wantAlive)
+			}
+		})
+	}
+}
+
+// waitForPidfile polls until the wrapper has written the agent's pid.
+func TestIntegration_Detach(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p", "--detach",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=event_at_deadline", "FAKE_AGENT_DELAY=1s")
+	cmd.Stdin = strings.NewReader("detached prompt\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr:\n%s", err, stderr.String())
+	}
+	// The agent alone takes a second; the parent must not wait for it.
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("parent took %v to return, expected it to return immediately", elapsed)
+	}
+
+	paths := map[string]string{}
+	for _, key := range []string{"stdout", "stderr", "pid"} {
+		m := regexp.MustCompile(`(?m)^  ` + key + `: +(\S+)`).FindStringSubmatch(stdout.String())
+		if m == nil {
+			t.Fatalf("parent output missing %s path:\n%s", key, stdout.String())
+		}
+		paths[key] = m[1]
+	}
+	pid := waitForPidfile(t, paths["pid"])
+
+	deadline := time.Now().Add(10 * time.Second)
+	for processRunning(pid) && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if processRunning(pid) {
+		t.Fatalf("detached wrapper %d still running", pid)
+	}
+
+	out, err := os.ReadFile(paths["stdout"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"type":"result"`) {
+		t.Errorf("detached stdout missing result event:\n%s", out)
+	}
+	if _, err := os.Stat(paths["pid"]); !os.IsNotExist(err) {
+		t.Errorf("expected detached pidfile removed at exit, stat err = %v", err)
+	}
+	if log := readLogFile(t, logDir); !strings.Contains(log, "fake-agent prompt: detached prompt") {
+		t.Errorf("session log missing the piped prompt:\n%s", log)
+	}
+}
+
+func waitForPidfile(t *testing.T, path string) int {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if pid, err := readPidfile(path); err == nil {
+			return pid
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("pidfile %s not written", path)
+	return 0
+}
+
+// processRunning reports whether pid is alive and not a zombie awaiting
+// reaping by its new parent.
+func processRunning(pid int) bool {
+	st := procState(pid)
+	return st != 0 && st != 'Z'
+}
+
+// procState returns pid's state letter from /proc (e.g. 'S', 'T', 'Z'),
+// or 0 if the process doesn't exist.
+func procState(pid int) byte {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0
+	}
+	// The state follows the parenthesised command name.
+	stat := string(data)
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 || i+2 >= len(stat) {
+		return 0
+	}
+	return stat[i+2]
+}
+
+// waitForState polls until pid is in state st.
+func waitForState(t *testing.T, pid int, st byte) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if procState(pid) == st {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("pid %d state %q, want %q", pid, procState(pid), st)
+}
+
+// --- Integration test: preflight ---
+
+func TestIntegration_PreflightFailures(t *testing.T) {
+	dir := t.TempDir()
+	notExec := filepath.Join(dir, "cursor-agent")
+	if err := os.WriteFile(notExec, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		agentBin string
+		env      []string
+		want     string
+	}{
+		{"binary not found", filepath.Join(dir, "missing"), nil, "cursor-agent not found at"},
+		{"binary not executable", notExec, nil, "is not executable"},
+		{"not authenticated", fakeAgentBin, []string{"FAKE_AGENT_AUTH=none"}, "cursor-agent is not authenticated: run 'cursor-agent login'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(wrapperBin,
+				"-p",
+				"--agent-bin", tt.agentBin,
+				"--log-dir", t.TempDir(),
+				"test prompt",
+			)
+			cmd.Env = append(append(os.Environ(), "FAKE_AGENT_SCENARIO=normal"), tt.env...)
+
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+
+			err := cmd.Run()
+			exitErr, ok := err.(*exec.ExitError)
+			if !ok {
+				t.Fatalf("expected *exec.ExitError, got %T: %v", err, err)
+			}
+			if exitErr.ExitCode() != 4 {
+				t.Errorf("exit %d, want 4 (startup failure)", exitErr.ExitCode())
+			}
+			if !strings.Contains(stderr.String(), tt.want) {
+				t.Errorf("stderr = %q, want containing %q", stderr.String(), tt.want)
+			}
+			if stdout.Len() != 0 {
+				t.Errorf("expected no turn to run, got stdout %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestIntegration_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario string
+		args     []string
+		want     int
+	}{
+		{"no result", "no_result", nil, 3},
+		{"agent fails to start", "normal", []string{"--no-preflight", "--agent-bin", filepath.Join(t.TempDir(), "missing")}, 4},
+		{"unknown flag", "normal", []string{"--idle-timeuot", "1s"}, 1},
+		{"bad flag value", "normal", []string{"--idle-timeout", "soon"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-p", "--agent-bin", fakeAgentBin, "--log-dir", t.TempDir()}, tt.args...)
+			cmd := exec.Command(wrapperBin, append(args, "test prompt")...)
+			cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO="+tt.scenario)
+			var stderr bytes.Buffer
+			cmd.Stdout = io.Discard
+			cmd.Stderr = &stderr
+			_ = cmd.Run()
+			if got := cmd.ProcessState.ExitCode(); got != tt.want {
+				t.Errorf("exit %d, want %d\nstderr: %s", got, tt.want, stderr.String())
+			}
+		})
+	}
+
+	t.Run("help lists them", func(t *testing.T) {
+		cmd := exec.Command(wrapperBin, "--help")
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("--help: %v", err)
+		}
+		for _, want := range []string{"-turn-timeout", "Exit codes:", "3  cursor-agent exited without a result", "4  cursor-agent couldn't be started"} {
+			if !strings.Contains(stderr.String(), want) {
+				t.Errorf("--help lacks %q:\n%s", want, stderr.String())
+			}
+		}
+	})
+}
+
+func TestIntegration_PreflightVersionFollowsWrapperStart(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--agent-bin", fakeAgentBin,
+		"--log-dir", logDir,
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v", err)
+	}
+
+	lines := nonEmptyLines(readLogFile(t, logDir))
+	if len(lines) < 2 {
+		t.Fatalf("want at least 2 records, got %d", len(lines))
+	}
+	var first, second map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("invalid first record: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("invalid second record: %v", err)
+	}
+	if first["msg"] != "wrapper_start" {
+		t.Errorf("first record = %v, want wrapper_start", first)
+	}
+	if second["msg"] != "preflight ok" || second["agent_version"] != "2026.01.01-fake" {
+		t.Errorf("second record = %v, want preflight ok with agent_version", second)
+	}
+}
+
+func TestIntegration_NoPreflightSkipsCheck(t *testing.T) {
+	logDir := t.TempDir()
+
+	cmd := exec.Command(wrapperBin,
+		"-p",
+		"--no-preflight",
+		"--agent-bin", fakeAgentBin,
+		"--log-dir", logDir,
+		"test prompt",
+	)
+	// A logged-out agent would fail preflight; the turn itself succeeds.
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal", "FAKE_AGENT_AUTH=none")
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v", err)
+	}
+	if strings.Contains(readLogFile(t, logDir), "preflight ok") {
+		t.Error("expected no preflight record with --no-preflight")
 	}
 }
 
 // --- Helpers ---
 
-// normalScenarioLines returns the expected JSONL lines from the "normal" fake agent scenario.
-// Must match exactly what fakeagent outputs for FAKE_AGENT_SCENARIO=normal.
-func normalScenarioLines() []string {
-	return []string{
-		`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`,
-		`{"type":"user","message":{"content":[{"type":"text","text":"test prompt"}]}}`,
-		`{"type":"thinking","subtype":"delta","text":"Let me think about this."}`,
-		`{"type":"thinking","subtype":"completed"}`,
-		`{"type":"assistant","model_call_id":"mc_1","message":{"content":[{"type":"text","text":"Here is my response."}]}}`,
-		`{"type":"tool_call","subtype":"started","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1000,"tool_call":{"shellToolCall":{"args":{"command":"echo test","timeout":120000}}}}`,
-		`{"type":"tool_call","subtype":"completed","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1100,"tool_call":{"shellToolCall":{"args":{"command":"echo test","timeout":120000},"result":{"success":{"exitCode":0,"stdout":"test\n","stderr":"","executionTime":100}}}}}`,
-		`{"type":"assistant","message":{"content":[{"type":"text","text":"Final answer."}]}}`,
-		`{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"session_id":"test-session-id","request_id":"req_1"}`,
+// normalScenarioLines returns the expected JSONL lines from the "normal" fake agent scenario.
+// Must match exactly what fakeagent outputs for FAKE_AGENT_SCENARIO=normal.
+func normalScenarioLines() []string {
+	return []string{
+		`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`,
+		`{"type":"user","message":{"content":[{"type":"text","text":"test prompt"}]}}`,
+		`{"type":"thinking","subtype":"delta","text":"Let me think about this."}`,
+		`{"type":"thinking","subtype":"completed"}`,
+		`{"type":"assistant","model_call_id":"mc_1","message":{"content":[{"type":"text","text":"Here is my response."}]}}`,
+		`{"type":"tool_call","subtype":"started","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1000,"tool_call":{"shellToolCall":{"args":{"command":"echo test","timeout":120000}}}}`,
+		`{"type":"tool_call","subtype":"completed","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1100,"tool_call":{"shellToolCall":{"args":{"command":"echo test","timeout":120000},"result":{"success":{"exitCode":0,"stdout":"test\n","stderr":"","executionTime":100}}}}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Final answer."}]}}`,
+		`{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"session_id":"test-session-id","request_id":"req_1","model":"test-model","usage":{"inputTokens":1200,"outputTokens":80,"cacheReadTokens":1000,"cacheWriteTokens":0}}`,
+	}
+}
+
+func TestIntegration_OTelExport(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, r.URL.Path+" "+string(body))
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--log-dir", t.TempDir(),
+		"--otel-endpoint", collector.URL,
+	)
+	cmd.Env = append(os.Environ(),
+		"FAKE_AGENT_SCENARIO=multi_turn",
+		"TRACEPARENT=00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	)
+	cmd.Stdin = strings.NewReader("first prompt\nsecond prompt\n")
+	cmd.Stdout = io.Discard
+	cmd.Stderr = io.Discard
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// One export per turn, each a turn span in the inherited trace.
+	if len(bodies) != 2 {
+		t.Fatalf("collector got %d requests, want 2:\n%s", len(bodies), strings.Join(bodies, "\n"))
+	}
+	for i, b := range bodies {
+		for _, want := range []string{
+			"/v1/traces ",
+			`"name":"cursor-agent turn"`,
+			`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`,
+			`"parentSpanId":"00f067aa0ba902b7"`,
+			fmt.Sprintf(`{"key":"turn","value":{"intValue":"%d"}}`, i+1),
+		} {
+			if !strings.Contains(b, want) {
+				t.Errorf("export %d missing %s:\n%s", i+1, want, b)
+			}
+		}
+	}
+}
+
+func TestIntegration_ReplayMatchesLiveOutput(t *testing.T) {
+	for _, format := range []string{"text", "stream-json"} {
+		t.Run(format, func(t *testing.T) {
+			logDir := t.TempDir()
+			cmd := exec.Command(wrapperBin,
+				"-p",
+				"--agent-bin", fakeAgentBin,
+				"--idle-timeout", "5s",
+				"--log-dir", logDir,
+				"--output-format", format,
+				"test prompt",
+			)
+			cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=with_tool")
+			var live bytes.Buffer
+			cmd.Stdout = &live
+			cmd.Stderr = io.Discard
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("wrapper exited with error: %v", err)
+			}
+
+			logs, _ := filepath.Glob(filepath.Join(logDir, "cursor-wrap-*.jsonl"))
+			if len(logs) != 1 {
+				t.Fatalf("want one session log, got %v", logs)
+			}
+			replay := exec.Command(wrapperBin, "replay", logs[0], "--output-format", format)
+			var replayed, stderr bytes.Buffer
+			replay.Stdout = &replayed
+			replay.Stderr = &stderr
+			if err := replay.Run(); err != nil {
+				t.Fatalf("replay failed: %v\n%s", err, stderr.String())
+			}
+			if replayed.String() != live.String() {
+				t.Errorf("replay differs from live output\nlive:\n%s\nreplay:\n%s", live.String(), replayed.String())
+			}
+		})
+	}
+}
+
+func TestIntegration_SummaryFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		scenario  string
+		args      []string
+		stdin     string
+		wantExit  int
+		wantTurns []string // result_subtype per turn
+		wantHangs int
+	}{
+		{name: "normal", scenario: "normal", args: []string{"-p", "test prompt"}, wantTurns: []string{"success"}},
+		{name: "hang", scenario: "idle_hang", args: []string{"-p", "test prompt"}, wantExit: 2, wantTurns: []string{""}, wantHangs: 1},
+		{name: "multi-turn", scenario: "multi_turn", stdin: "first\nsecond\n", wantTurns: []string{"success", "success"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logDir := t.TempDir()
+			args := append([]string{
+				"--agent-bin", fakeAgentBin,
+				"--idle-timeout", "1s",
+				"--tick-interval", "500ms",
+				"--log-dir", logDir,
+			}, tt.args...)
+			cmd := exec.Command(wrapperBin, args...)
+			cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO="+tt.scenario)
+			cmd.Stdin = strings.NewReader(tt.stdin)
+			cmd.Stdout = io.Discard
+			cmd.Stderr = io.Discard
+			_ = cmd.Run()
+			if got := cmd.ProcessState.ExitCode(); got != tt.wantExit {
+				t.Fatalf("exit code = %d, want %d", got, tt.wantExit)
+			}
+
+			paths, _ := filepath.Glob(filepath.Join(logDir, "*.summary.json"))
+			if len(paths) != 1 {
+				t.Fatalf("want one summary file, got %v", paths)
+			}
+			data, err := os.ReadFile(paths[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var summary sessionSummary
+			if err := json.Unmarshal(data, &summary); err != nil {
+				t.Fatalf("summary is not valid JSON: %v\n%s", err, data)
+			}
+
+			if summary.ExitCode != tt.wantExit || summary.HangCount != tt.wantHangs || len(summary.Hangs) != tt.wantHangs {
+				t.Errorf("exit_code %d, hang_count %d (%d hangs); want %d, %d\n%s", summary.ExitCode, summary.HangCount, len(summary.Hangs), tt.wantExit, tt.wantHangs, data)
+			}
+			if summary.TurnsAttempted != len(tt.wantTurns) || len(summary.Turns) != len(tt.wantTurns) {
+				t.Fatalf("turns_attempted %d, want %d\n%s", summary.TurnsAttempted, len(tt.wantTurns), data)
+			}
+			for i, want := range tt.wantTurns {
+				if got := summary.Turns[i]; got.Turn != i+1 || got.ResultSubtype != want {
+					t.Errorf("turn %d = %+v, want result_subtype %q", i+1, got, want)
+				}
+			}
+			if tt.scenario == "normal" {
+				got := summary.Turns[0]
+				if got.Model != "test-model" || got.Usage == nil || got.Usage.InputTokens != 1200 || got.Usage.OutputTokens != 80 {
+					t.Errorf("turn 1 model %q, usage %+v; want the result event's\n%s", got.Model, got.Usage, data)
+				}
+			}
+			if summary.TurnsSucceeded != len(tt.wantTurns)-tt.wantHangs {
+				t.Errorf("turns_succeeded = %d\n%s", summary.TurnsSucceeded, data)
+			}
+			if tt.wantHangs > 0 && !strings.Contains(summary.Hangs[0].Reason, "idle") {
+				t.Errorf("hang reason = %q", summary.Hangs[0].Reason)
+			}
+			if summary.SessionID == "" || summary.WallTimeMS <= 0 {
+				t.Errorf("session_id %q, wall_time_ms %d", summary.SessionID, summary.WallTimeMS)
+			}
+			if _, err := os.Stat(summary.LogFile); err != nil {
+				t.Errorf("log_file: %v", err)
+			}
+		})
+	}
+}
+
+func TestIntegration_ReportFD(t *testing.T) {
+	type report struct {
+		Outcome        string `json:"outcome"`
+		ExitCode       int    `json:"exit_code"`
+		Error          string `json:"error"`
+		SessionID      string `json:"session_id"`
+		TurnsAttempted int    `json:"turns_attempted"`
+		HangCount      int    `json:"hang_count"`
+		Hangs          []struct {
+			Reason string `json:"reason"`
+		} `json:"hangs"`
+		WallTimeMS int64  `json:"wall_time_ms"`
+		LogFile    string `json:"log_file"`
+	}
+	// runReporting runs the wrapper with a pipe as its fd 3, calling
+	// signal once it has started, and returns the one line read from the
+	// pipe: the pipe hits EOF only once no process holds it open, the
+	// agent included.
+	runReporting := func(t *testing.T, scenario string, signal func(*exec.Cmd), args ...string) (exit int, r report) {
+		t.Helper()
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pr.Close()
+		cmd := exec.Command(wrapperBin, append([]string{
+			"--agent-bin", fakeAgentBin,
+			"--idle-timeout", "1s",
+			"--tick-interval", "200ms",
+			"--kill-grace", "500ms",
+			"--log-dir", t.TempDir(),
+			"--report-fd", "3",
+		}, args...)...)
+		cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO="+scenario)
+		cmd.ExtraFiles = []*os.File{pw}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		pw.Close()
+		if signal != nil {
+			signal(cmd)
+		}
+		read := make(chan []byte, 1)
+		go func() {
+			data, _ := io.ReadAll(pr)
+			read <- data
+		}()
+		var data []byte
+		select {
+		case data = <-read:
+		case <-time.After(15 * time.Second):
+			_ = cmd.Process.Kill()
+			t.Fatalf("report pipe never closed\nstderr: %s", stderr.String())
+		}
+		_ = cmd.Wait()
+		if n := bytes.Count(data, []byte("\n")); n != 1 {
+			t.Fatalf("want one line of report, got %d:\n%s\nstderr: %s", n, data, stderr.String())
+		}
+		if err := json.Unmarshal(data, &r); err != nil {
+			t.Fatalf("report: %v\n%s", err, data)
+		}
+		return cmd.ProcessState.ExitCode(), r
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		exit, r := runReporting(t, "normal", nil, "-p", "test prompt")
+		if exit != 0 || r.Outcome != "ok" || r.ExitCode != 0 || r.SessionID != "test-session-id" || r.TurnsAttempted != 1 || r.WallTimeMS <= 0 {
+			t.Errorf("exit %d, report %+v", exit, r)
+		}
+		if _, err := os.Stat(r.LogFile); err != nil {
+			t.Errorf("log_file: %v", err)
+		}
+	})
+
+	t.Run("hang", func(t *testing.T) {
+		exit, r := runReporting(t, "idle_hang", nil, "-p", "test prompt")
+		if exit != 2 || r.Outcome != "hang" || r.ExitCode != 2 || r.HangCount != 1 || len(r.Hangs) != 1 || !strings.Contains(r.Hangs[0].Reason, "idle") {
+			t.Errorf("exit %d, report %+v", exit, r)
+		}
+	})
+
+	t.Run("terminated", func(t *testing.T) {
+		exit, r := runReporting(t, "slow_normal", func(cmd *exec.Cmd) {
+			time.Sleep(700 * time.Millisecond)
+			_ = cmd.Process.Signal(syscall.SIGTERM)
+		}, "-p", "--idle-timeout", "30s", "test prompt")
+		if exit != 1 || r.Outcome != "interrupted" || r.ExitCode != 1 || r.SessionID != "test-session-id" {
+			t.Errorf("exit %d, report %+v", exit, r)
+		}
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		exit, r := runReporting(t, "normal", nil, "-p", "--output-format", "xml", "test prompt")
+		if exit != 1 || r.Outcome != "error" || !strings.Contains(r.Error, "--output-format") {
+			t.Errorf("exit %d, report %+v", exit, r)
+		}
+	})
+
+	t.Run("report file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.json")
+		cmd := exec.Command(wrapperBin, "--agent-bin", fakeAgentBin, "--log-dir", t.TempDir(), "--report-file", path, "-p", "test prompt")
+		cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=no_result")
+		_ = cmd.Run()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r report
+		if err := json.Unmarshal(data, &r); err != nil || r.Outcome != "abnormal_exit" || r.ExitCode != 3 {
+			t.Errorf("report %s (%v)", data, err)
+		}
+	})
+}
+
+func TestIntegration_NoLogFile(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "logs")
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--log-dir", logDir,
+		"--no-log-file",
+		"--log-level", "debug",
+		"-p", "test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper failed: %v\nstderr: %s", err, stderr.String())
+	}
+
+	if stdout.Len() == 0 {
+		t.Error("no output forwarded")
+	}
+	// The session is renamed to its session_id and raw events are logged
+	// as usual; they just have no file to go to.
+	if !strings.Contains(stderr.String(), "session started") || !strings.Contains(stderr.String(), "raw_event") {
+		t.Errorf("console missing session records:\n%s", stderr.String())
+	}
+	if strings.Contains(stderr.String(), "level=WARN") {
+		t.Errorf("unexpected warning:\n%s", stderr.String())
+	}
+	if _, err := os.Stat(logDir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("log directory was created (stat: %v)", err)
+	}
+}
+
+func TestIntegration_WrapperStartRecord(t *testing.T) {
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--log-dir", logDir,
+		"--idle-timeout", "42s",
+		"--env", "API_TOKEN=s3cret",
+		"-p", "test prompt",
+		"--", "--api-key", "sk-123",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("wrapper failed: %v\n%s", err, out)
+	}
+
+	first, _, _ := strings.Cut(readLogFile(t, logDir), "\n")
+	if strings.Contains(first, "s3cret") || strings.Contains(first, "sk-123") {
+		t.Errorf("secret from the command line was logged: %s", first)
+	}
+	var rec struct {
+		Msg            string   `json:"msg"`
+		Kind           string   `json:"kind"`
+		WrapperVersion string   `json:"wrapper_version"`
+		Argv           []string `json:"argv"`
+		Config         struct {
+			Print         bool     `json:"print"`
+			OutputFormat  string   `json:"output_format"`
+			IdleTimeoutMS int64    `json:"idle_timeout_ms"`
+			AgentBin      string   `json:"agent_bin"`
+			ExtraFlags    []string `json:"extra_flags"`
+			Env           []string `json:"env"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal([]byte(first), &rec); err != nil {
+		t.Fatalf("first record: %v\n%s", err, first)
+	}
+	if rec.Msg != "wrapper_start" || rec.Kind != logger.KindConfig || rec.WrapperVersion == "" {
+		t.Fatalf("first record = %s", first)
+	}
+	if len(rec.Argv) == 0 || rec.Argv[len(rec.Argv)-1] != "[REDACTED]" {
+		t.Errorf("argv = %q", rec.Argv)
+	}
+	c := rec.Config
+	if !c.Print || c.OutputFormat != "stream-json" || c.IdleTimeoutMS != 42000 || c.AgentBin != fakeAgentBin {
+		t.Errorf("config = %+v", c)
+	}
+	if !reflect.DeepEqual(c.ExtraFlags, []string{"--api-key", "[REDACTED]"}) || !reflect.DeepEqual(c.Env, []string{"API_TOKEN"}) {
+		t.Errorf("extra_flags = %q, env = %q", c.ExtraFlags, c.Env)
+	}
+}
+
+func TestIntegration_NewSessionAfterHang(t *testing.T) {
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "1s",
+		"--tick-interval", "500ms",
+		"--log-dir", logDir,
+		"--prompt-after-hang", "continue",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=new_session_after_hang")
+	cmd.Stdin = strings.NewReader("first\nsecond\n")
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper failed: %v\nstderr: %s", err, stderr.String())
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(logDir, "cursor-wrap-*.jsonl"))
+	if len(logs) != 1 || !strings.HasSuffix(logs[0], "-test-session-id+test-session-id-2.jsonl") {
+		t.Fatalf("session logs = %v, want one named after both sessions", logs)
+	}
+	data, err := os.ReadFile(logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	if !strings.Contains(log, `"msg":"agent started a new session"`) {
+		t.Error("the session change was not logged")
+	}
+	// The turn after the hang retry resumes the new session.
+	if !strings.Contains(log, "--resume test-session-id-2") {
+		t.Errorf("third turn did not resume the new session:\n%s", log)
+	}
+
+	summaries, _ := filepath.Glob(filepath.Join(logDir, "*.summary.json"))
+	if len(summaries) != 1 {
+		t.Fatalf("summaries = %v", summaries)
+	}
+	data, err = os.ReadFile(summaries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary sessionSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"test-session-id", "test-session-id-2"}; !reflect.DeepEqual(summary.SessionIDs, want) || summary.SessionID != want[0] {
+		t.Errorf("summary session_id = %q, session_ids = %q", summary.SessionID, summary.SessionIDs)
+	}
+}
+
+func TestIntegration_Tag(t *testing.T) {
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--log-dir", logDir,
+		"--tag", "runner-3",
+		"-p", "test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("wrapper failed: %v\n%s", err, out)
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(logDir, "cursor-wrap-*-runner-3-test-session-id.jsonl"))
+	if len(logs) != 1 {
+		entries, _ := os.ReadDir(logDir)
+		t.Fatalf("no session log named after the tag: %v", entries)
+	}
+	data, err := os.ReadFile(logs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range nonEmptyLines(string(data)) {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec["tag"] != "runner-3" || rec["host"] == nil || rec["wrapper_pid"] == nil || rec["user"] == nil {
+			t.Errorf("record without origin fields: %s", line)
+		}
+	}
+
+	data, err = os.ReadFile(strings.TrimSuffix(logs[0], ".jsonl") + ".summary.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary sessionSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Tag != "runner-3" {
+		t.Errorf("summary tag = %q", summary.Tag)
+	}
+}
+
+func TestIntegration_NonJSONLinesSurfaced(t *testing.T) {
+	const notice = "T: Named models unavailable on free plan"
+	tests := []struct {
+		name     string
+		args     []string
+		wantLine string // "" means the notice must not be on stdout
+	}{
+		{"text", []string{"--output-format", "text"}, notice + "\n"},
+		{"stream-json", []string{"--output-format", "stream-json"}, ""},
+		{"stream-json with wrapper events", []string{"--output-format", "stream-json", "--wrapper-events"}, `{"type":"wrapper_nonjson","text":"` + notice + `"}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logDir := t.TempDir()
+			args := append([]string{"-p", "--agent-bin", fakeAgentBin, "--log-dir", logDir}, tt.args...)
+			cmd := exec.Command(wrapperBin, append(args, "test prompt")...)
+			cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=nonjson_notice")
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+			}
+
+			out := stdout.String()
+			if tt.wantLine == "" && strings.Contains(out, notice) {
+				t.Errorf("stdout has the notice:\n%s", out)
+			}
+			if tt.wantLine != "" && !strings.HasPrefix(out, tt.wantLine) {
+				t.Errorf("stdout does not start with %q:\n%s", tt.wantLine, out)
+			}
+			if log := readLogFile(t, logDir); !strings.Contains(log, `"raw":{"type":"wrapper_nonjson","text":"`+notice+`"}`) {
+				t.Errorf("session log has no wrapper_nonjson raw event:\n%s", log)
+			}
+		})
+	}
+}
+
+func TestIntegration_LongLine(t *testing.T) {
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin, "-p", "--agent-bin", fakeAgentBin, "--log-dir", logDir, "--output-format", "stream-json", "test prompt")
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=long_line")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+	}
+
+	var types []string
+	for line := range strings.Lines(stdout.String()) {
+		var ev struct{ Type, Subtype string }
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("stdout line of %d bytes is not JSON: %v", len(line), err)
+		}
+		types = append(types, ev.Type+"/"+ev.Subtype)
+	}
+	want := []string{"system/init", "tool_call/started", "tool_call/completed", "assistant/", "result/success"}
+	if !slices.Equal(types, want) {
+		t.Errorf("stdout events = %v, want %v", types, want)
+	}
+	if !strings.Contains(stdout.String(), strings.Repeat("x", 5<<20)) {
+		t.Error("the 5 MB tool result was not passed through intact")
+	}
+}
+
+func TestIntegration_StreamingToolOutput(t *testing.T) {
+	// The tool runs past its 1s timeout plus grace, but its output keeps
+	// coming: it isn't a hang, and the output is shown as it arrives.
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin, "-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "1s",
+		"--tool-grace", "500ms",
+		"--tick-interval", "200ms",
+		"--log-dir", logDir,
+		"--output-format", "text",
+		"--stream-tool-output",
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=streaming_tool")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+	}
+	want := "⏳ `make`\n"
+	for i := 1; i <= 8; i++ {
+		want += fmt.Sprintf("  │ step %d\n", i)
+	}
+	want += "✓ `make` (2.5s, exit 0)\n"
+	if !strings.HasPrefix(stdout.String(), want) {
+		t.Errorf("stdout = %q, want it to start %q", stdout.String(), want)
+	}
+}
+
+func TestIntegration_PartialFinalLineLogged(t *testing.T) {
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin, "-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "1s",
+		"--tick-interval", "200ms",
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=partial_line_hang")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = io.Discard
+	_ = cmd.Run()
+	if got := cmd.ProcessState.ExitCode(); got != 2 {
+		t.Fatalf("exit code = %d, want 2 (hang)", got)
+	}
+	if strings.Contains(stdout.String(), "Runn") {
+		t.Errorf("the partial line reached stdout: %s", stdout.String())
+	}
+
+	var found bool
+	for _, line := range strings.Split(strings.TrimSpace(readLogFile(t, logDir)), "\n") {
+		var rec struct {
+			Msg   string `json:"msg"`
+			Bytes int    `json:"bytes"`
+			Text  string `json:"text"`
+		}
+		if json.Unmarshal([]byte(line), &rec) != nil || rec.Msg != "partial_final_line" {
+			continue
+		}
+		found = true
+		want := `{"type":"assistant","message":{"content":[{"type":"text","text":"Runn`
+		if rec.Text != want || rec.Bytes != len(want) {
+			t.Errorf("partial_final_line = %s, want the %d bytes written", line, len(want))
+		}
+	}
+	if !found {
+		t.Error("no partial_final_line record in the log")
+	}
+}
+
+func TestIntegration_SessionIDWithoutInit(t *testing.T) {
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin, "-p",
+		"--agent-bin", fakeAgentBin,
+		"--idle-timeout", "5s",
+		"--tick-interval", "200ms",
+		"--log-dir", logDir,
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=no_init")
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+	}
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var renamed bool
+	for _, e := range entries {
+		renamed = renamed || strings.HasSuffix(e.Name(), "-test-session-id.jsonl")
+	}
+	if !renamed {
+		t.Errorf("log files = %v, want the log renamed with the session id from the result", entries)
+	}
+}
+
+func TestIntegration_NoForceClosesStdin(t *testing.T) {
+	// The agent starts work at stdin EOF: a turn without --force must not
+	// leave stdin open.
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin, "-p",
+		"--agent-bin", fakeAgentBin,
+		"--force=false",
+		"--idle-timeout", "2s",
+		"--tick-interval", "200ms",
+		"--log-dir", logDir,
+		"test prompt",
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+	}
+	if logContent := readLogFile(t, logDir); strings.Contains(logContent, "hang detected") {
+		t.Errorf("the agent waited on its stdin:\n%s", logContent)
+	}
+}
+
+func TestIntegration_PromptFile(t *testing.T) {
+	logDir := t.TempDir()
+	promptFile := filepath.Join(t.TempDir(), "prompt.md")
+	if err := os.WriteFile(promptFile, []byte("# Plan\n\nRefactor \"the\" parser's $STATE.\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interactive: the file is the first turn's prompt, and stdin the next.
+	cmd := exec.Command(wrapperBin,
+		"--agent-bin", fakeAgentBin,
+		"--log-dir", logDir,
+		"--output-format", "stream-json",
+		"--prompt-file", promptFile,
+	)
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	cmd.Stdin = strings.NewReader("second prompt\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper failed: %v\nstderr: %s", err, stderr.String())
+	}
+
+	var prompts []string
+	for _, line := range nonEmptyLines(readLogFile(t, logDir)) {
+		var rec struct {
+			Msg    string `json:"msg"`
+			Prompt string `json:"prompt"`
+		}
+		if json.Unmarshal([]byte(line), &rec) == nil && rec.Msg == "turn_start" {
+			prompts = append(prompts, rec.Prompt)
+		}
+	}
+	want := []string{"# Plan\n\nRefactor \"the\" parser's $STATE.", "second prompt"}
+	if !reflect.DeepEqual(prompts, want) {
+		t.Errorf("turn prompts = %q, want %q", prompts, want)
+	}
+
+	// A missing file fails before any turn runs.
+	cmd = exec.Command(wrapperBin, "-p", "--agent-bin", fakeAgentBin, "--no-log-file", "--prompt-file", filepath.Join(logDir, "missing.md"))
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=normal")
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "--prompt-file") || strings.Contains(string(out), `"type":"result"`) {
+		t.Errorf("missing prompt file: err %v, output:\n%s", err, out)
+	}
+}
+
+func TestIntegration_Batch(t *testing.T) {
+	dir := t.TempDir()
+	batchFile := filepath.Join(dir, "prompts.txt")
+	if err := os.WriteFile(batchFile, []byte("alpha review\nhang here\ngamma review\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	type batchResult struct {
+		ExitCode int    `json:"exit_code"`
+		Error    string `json:"error"`
+		Batch    []struct {
+			Index     int    `json:"index"`
+			Line      int    `json:"line"`
+			Status    string `json:"status"`
+			Turns     []int  `json:"turns"`
+			SessionID string `json:"session_id"`
+			Error     string `json:"error"`
+		} `json:"batch"`
+	}
+	runBatch := func(t *testing.T, extra ...string) (stdout string, exit int, summary batchResult) {
+		t.Helper()
+		summaryFile := filepath.Join(t.TempDir(), "summary.json")
+		args := append([]string{
+			"--agent-bin", fakeAgentBin,
+			"--idle-timeout", "1s",
+			"--tool-grace", "1s",
+			"--tick-interval", "200ms",
+			"--log-dir", t.TempDir(),
+			"--summary-file", summaryFile,
+			"--batch", batchFile,
+		}, extra...)
+		cmd := exec.Command(wrapperBin, args...)
+		cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=by_prompt")
+		var out, stderr bytes.Buffer
+		cmd.Stdout = &out
+		cmd.Stderr = &stderr
+		_ = cmd.Run()
+		data, err := os.ReadFile(summaryFile)
+		if err != nil {
+			t.Fatalf("no summary: %v\nstderr: %s", err, stderr.String())
+		}
+		if err := json.Unmarshal(data, &summary); err != nil {
+			t.Fatalf("summary: %v\n%s", err, data)
+		}
+		return out.String(), cmd.ProcessState.ExitCode(), summary
+	}
+
+	t.Run("goes on past a hang", func(t *testing.T) {
+		stdout, exit, summary := runBatch(t)
+		if exit != 1 || !strings.Contains(summary.Error, "batch prompts failed: 1 of 3") {
+			t.Errorf("exit %d, error %q; want 1 for a failed prompt", exit, summary.Error)
+		}
+		// Stream-json by default, as with -p: a header before each
+		// prompt's events, the hang marked where it happened.
+		for _, want := range []string{
+			`{"type":"wrapper","subtype":"batch_prompt","index":1,"total":3,"prompt":"alpha review"}`,
+			`{"type":"wrapper","subtype":"batch_prompt","index":2,"total":3,"prompt":"hang here"}`,
+			`"subtype":"hang_detected"`,
+			`{"type":"wrapper","subtype":"batch_prompt","index":3,"total":3,"prompt":"gamma review"}`,
+		} {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("stdout lacks %s:\n%s", want, stdout)
+			}
+		}
+		if strings.Count(stdout, `"type":"result"`) != 2 {
+			t.Errorf("want results for prompts 1 and 3:\n%s", stdout)
+		}
+		var got []string
+		for _, p := range summary.Batch {
+			got = append(got, fmt.Sprintf("%d:%d:%s:%s", p.Index, p.Line, p.Status, p.SessionID))
+		}
+		want := []string{"1:1:succeeded:session-alpha", "2:2:failed:test-session-id", "3:3:succeeded:session-gamma"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("batch = %v, want %v", got, want)
+		}
+		if len(summary.Batch) == 3 && !strings.Contains(summary.Batch[1].Error, "hang detected") {
+			t.Errorf("prompt 2 error = %q", summary.Batch[1].Error)
+		}
+	})
+
+	t.Run("fail fast, one session", func(t *testing.T) {
+		_, exit, summary := runBatch(t, "--fail-fast", "--batch-session", "shared", "--output-format", "text")
+		if exit != 2 {
+			t.Errorf("exit %d, want 2: the hang's", exit)
+		}
+		var got []string
+		for _, p := range summary.Batch {
+			got = append(got, fmt.Sprintf("%d:%s:%s", p.Index, p.Status, p.SessionID))
+		}
+		// The hang resumed the first prompt's session.
+		want := []string{"1:succeeded:session-alpha", "2:failed:test-session-id", "3:skipped:"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("batch = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestIntegration_RecordCapturesRawStream(t *testing.T) {
+	// The fake agent's own output, run without the wrapper.
+	agent := exec.Command(fakeAgentBin, "-p")
+	agent.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=nonjson_notice")
+	agent.Stdin = strings.NewReader("test prompt")
+	want, err := agent.Output()
+	if err != nil {
+		t.Fatalf("fake agent: %v", err)
+	}
+
+	logDir := t.TempDir()
+	record := filepath.Join(t.TempDir(), "capture.jsonl")
+	cmd := exec.Command(wrapperBin, "-p", "--agent-bin", fakeAgentBin, "--log-dir", logDir, "--record", record, "test prompt")
+	cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=nonjson_notice")
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+	}
+
+	got, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("recording differs from the agent's output\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if log := readLogFile(t, logDir); !regexp.MustCompile(`"msg":"recording agent output".*"path":"` + regexp.QuoteMeta(record) + `"`).MatchString(log) {
+		t.Errorf("session log does not give the recording's path:\n%s", log)
+	}
+}
+
+func TestIntegration_ReplayReproducesIdleHang(t *testing.T) {
+	// What --record captured of the idle_hang scenario: it goes silent
+	// after thinking.
+	capture := filepath.Join(t.TempDir(), "idle_hang.jsonl")
+	lines := `{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}
+{"type":"user","message":{"content":[{"type":"text","text":"test prompt"}]}}
+{"type":"thinking","subtype":"delta","text":"Let me think about this."}
+{"type":"thinking","subtype":"completed"}
+`
+	if err := os.WriteFile(capture, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	logDir := t.TempDir()
+	cmd := exec.Command(wrapperBin, "-p", "--replay", capture, "--agent-bin", "/nonexistent/cursor-agent",
+		"--idle-timeout", "1s", "--tick-interval", "200ms", "--log-dir", logDir, "test prompt")
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+		t.Fatalf("err = %v, want exit code 2 for the hang\nstderr: %s", err, stderr.String())
+	}
+	log := readLogFile(t, logDir)
+	for _, want := range []string{`"msg":"hang detected"`, `"msg":"replay: not killing anything, ending the playback"`} {
+		if !strings.Contains(log, want) {
+			t.Errorf("session log has no %s record:\n%s", want, log)
+		}
+	}
+}
+
+func TestIntegration_ReplaySessionLog(t *testing.T) {
+	run := func(logDir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command(wrapperBin, append([]string{"-p", "--agent-bin", fakeAgentBin, "--log-dir", logDir, "--output-format", "stream-json"}, args...)...)
+		cmd.Env = append(os.Environ(), "FAKE_AGENT_SCENARIO=nonjson_notice")
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("wrapper exited with error: %v\nstderr: %s", err, stderr.String())
+		}
+		return stdout.String()
+	}
+
+	liveDir := t.TempDir()
+	live := run(liveDir, "--wrapper-events", "test prompt")
+	entries, err := os.ReadDir(liveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sessionLog string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "cursor-wrap-") && strings.HasSuffix(e.Name(), ".jsonl") {
+			sessionLog = filepath.Join(liveDir, e.Name())
+		}
+	}
+
+	replayed := run(t.TempDir(), "--wrapper-events", "--replay", sessionLog, "--replay-speed", "0", "test prompt")
+	if replayed != live {
+		t.Errorf("replayed output differs from the live run\nreplayed:\n%s\nlive:\n%s", replayed, live)
 	}
 }
 
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/monitor"
+)
+
+// jobControlSignals are caught for the length of a turn so Ctrl+Z and fg
+// take the agent along with the wrapper. Between turns they keep their
+// default behaviour.
+var jobControlSignals = []os.Signal{syscall.SIGTSTP, syscall.SIGCONT}
+
+// handleJobControl keeps the agent and the hang monitor in step with the
+// wrapper's own job control. On SIGTSTP it stops the agent, pauses the
+// monitor and then stops the wrapper, since catching the signal cancelled
+// its default action. On SIGCONT it continues the agent and resumes the
+// monitor, so the time spent suspended isn't counted as silence.
+func handleJobControl(sig os.Signal, sess agent, mon *monitor.Monitor, log *logger.LogSession) {
+	switch sig {
+	case syscall.SIGTSTP:
+		if err := sess.Suspend(); err != nil {
+			log.Warn("could not suspend agent", "error", err)
+		}
+		mon.Pause(mon.Now())
+		log.Info("suspended")
+		// Returns once the wrapper is continued; SIGCONT is then
+		// delivered to the turn loop like any other.
+		_ = syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+	case syscall.SIGCONT:
+		if err := sess.Resume(); err != nil {
+			log.Warn("could not resume agent", "error", err)
+		}
+		suspended := mon.Resume(mon.Now())
+		log.Info("resumed", "suspended_ms", suspended.Milliseconds())
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/logger"
+)
+
+// eventLags collects how far behind the agent a turn's events were read
+// (see events.AnnotatedEvent.Lag), for the turn's stats. Only events
+// with a timestamp of their own count. It is only used from the event
+// loop.
+type eventLags struct {
+	lags []time.Duration
+}
+
+// Add records ev's lag, if it has one.
+func (l *eventLags) Add(ev events.AnnotatedEvent) {
+	if !ev.AgentTime.IsZero() {
+		l.lags = append(l.lags, ev.Lag)
+	}
+}
+
+// Log writes the lags' minimum, median and maximum as an "event lag"
+// record. Negative lags, from clock skew, are reported as they are.
+func (l *eventLags) Log(log *logger.LogSession) {
+	if len(l.lags) == 0 {
+		return
+	}
+	sorted := slices.Clone(l.lags)
+	slices.Sort(sorted)
+	log.Info("event lag", logger.Kind(logger.KindTurnStats),
+		"events", len(sorted),
+		"min_ms", sorted[0].Milliseconds(),
+		"p50_ms", sorted[(len(sorted)-1)/2].Milliseconds(),
+		"max_ms", sorted[len(sorted)-1].Milliseconds(),
+	)
+}
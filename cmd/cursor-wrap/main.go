@@ -2,18 +2,22 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode"
 
 	"cursor-wrap/internal/events"
 	"cursor-wrap/internal/format"
@@ -25,13 +29,24 @@ import (
 var (
 	ErrHangDetected = errors.New("hang detected")
 	ErrAbnormalExit = errors.New("abnormal exit")
+	ErrFatalStderr  = errors.New("fatal error reported on agent stderr")
+	ErrOutputLimit  = errors.New("agent output limit exceeded")
+	ErrTurnTimeout  = errors.New("turn timeout")
 )
 
 // TurnResult is returned by runTurn to communicate outcome to the session loop.
 type TurnResult struct {
-	SessionID string         // from system/init event
-	Err       error          // nil on normal completion
-	Reason    monitor.Reason // populated when Err is ErrHangDetected
+	SessionID   string         // from system/init event
+	Err         error          // nil on normal completion
+	Reason      monitor.Reason // populated when Err is ErrHangDetected or ErrTurnTimeout
+	StderrMatch string         // populated when Err is ErrFatalStderr
+	KilledAt    time.Time      // when a hung or timed-out agent was reaped
+	// ResultSubtype is the subtype of the agent's result event ("success",
+	// "error", …); empty if the turn ended without one.
+	ResultSubtype string
+	// Result is the agent's result event, nil if the turn ended without
+	// one or it could not be parsed.
+	Result *events.Result
 }
 
 // isTerminal reports whether the given file descriptor is connected to a terminal.
@@ -45,30 +60,280 @@ var isTerminal = func(f *os.File) bool {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fixtures" {
+		os.Exit(runFixtures(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	cfg := parseFlags(os.Args[1:])
-	if err := run(ctx, cfg); err != nil {
-		slog.Error("fatal", "error", err)
-		if errors.Is(err, ErrHangDetected) {
-			os.Exit(2)
+	warnings, err := cfg.Validate()
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "cursor-wrap: warning: %s\n", w)
+	}
+	if cfg.ReportFD >= 3 { // anything else is invalid, and left alone
+		var ferr error
+		cfg.report, ferr = openReportFD(cfg.ReportFD)
+		err = errors.Join(err, ferr)
+	}
+	if err == nil && cfg.ResumeLast > 0 {
+		err = cfg.resumeLast(os.Stderr)
+	}
+	if err != nil {
+		// One problem per line; errors.Join separates them with newlines.
+		fmt.Fprintln(os.Stderr, "cursor-wrap: invalid configuration:")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "  %s\n", line)
+		}
+		if err := writeReport(cfg, newSessionSummary(time.Now(), cfg.Log.Tag), err); err != nil {
+			fmt.Fprintf(os.Stderr, "cursor-wrap: writing run report failed: %v\n", err)
 		}
 		os.Exit(1)
 	}
+	if cfg.Detach {
+		started, child := detachedChild()
+		if !child {
+			if err := detach(cfg); err != nil {
+				fmt.Fprintf(os.Stderr, "cursor-wrap: --detach: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+		cfg.Log.Started = started
+		cfg.DetachPidFile = newDetachedFiles(cfg.Log, started).Pid
+	}
+	cfg.status = statusRequests()
+	cfg.reload = newReloader(cfg, os.Args[1:])
+	if err := run(ctx, cfg); err != nil {
+		// A binary that can't be run is a setup problem: say so plainly
+		// rather than as a structured log record.
+		var se *process.StartError
+		if errors.As(err, &se) && se.Diagnosis != nil {
+			fmt.Fprintf(os.Stderr, "cursor-wrap: %v\n", se)
+		} else {
+			slog.Error("fatal", "error", err)
+		}
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode maps a run error to the wrapper's exit status so scripts can
+// tell hangs, crashes and agent-reported failures apart from generic
+// errors. exitCodesHelp lists them for --help.
+func exitCode(err error) int {
+	var se *process.StartError
+	var ue *startupError
+	switch {
+	case errors.Is(err, ErrHangDetected):
+		return 2
+	case errors.Is(err, ErrAbnormalExit):
+		return 3
+	case errors.As(err, &se), errors.As(err, &ue):
+		return 4
+	case errors.Is(err, ErrFatalStderr):
+		return 5
+	case errors.Is(err, ErrWrapperPanic):
+		return 6
+	case errors.Is(err, ErrTurnTimeout):
+		return 7
+	default:
+		return 1
+	}
+}
+
+// exitOutcome names what err's exit code stands for, in the run report.
+func exitOutcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	switch code := exitCode(err); {
+	case code != 1:
+		return exitOutcomes[code]
+	case errors.Is(err, context.Canceled):
+		return "interrupted"
+	case errors.Is(err, ErrBatchFailed):
+		return "batch_failed"
+	default:
+		return "error"
+	}
+}
+
+var exitOutcomes = map[int]string{
+	2: "hang",
+	3: "abnormal_exit",
+	4: "startup_failure",
+	5: "fatal_stderr",
+	6: "panic",
+	7: "turn_timeout",
 }
 
-func run(ctx context.Context, cfg Config) error {
+// exitCodesHelp ends --help's output.
+const exitCodesHelp = `
+Exit codes:
+  0  normal completion
+  1  any other error, such as bad flags, or a --batch prompt failed
+  2  hang detected
+  3  cursor-agent exited without a result
+  4  cursor-agent couldn't be started: preflight failed, or its binary or working directory is missing
+  5  cursor-agent reported a fatal error on stderr (see --fatal-stderr-pattern)
+  6  the wrapper panicked (a bug: please report it)
+  7  a turn ran past --turn-timeout
+`
+
+func run(ctx context.Context, cfg Config) (runErr error) {
+	// The run report goes out last, after the formatter's last flush and
+	// the summary, on every path out of run, even one before the log is
+	// set up or a signal's.
+	summary := newSessionSummary(time.Now(), cfg.Log.Tag)
+	defer func() {
+		if err := writeReport(cfg, summary, runErr); err != nil {
+			slog.Warn("writing run report failed", "error", err)
+		}
+	}()
+
+	// A detached wrapper has no terminal to be found from; its pidfile is
+	// how the user finds (and signals) it.
+	if cfg.DetachPidFile != "" {
+		if err := writePidfile(cfg.DetachPidFile, os.Getpid()); err != nil {
+			slog.Warn("writing detached wrapper pidfile failed", "error", err)
+		}
+		defer func() {
+			if err := os.Remove(cfg.DetachPidFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("removing pidfile failed", "pidfile", cfg.DetachPidFile, "error", err)
+			}
+		}()
+	}
+
+	// A remote agent's directory is on the remote host; a bad one shows
+	// up as a failed turn instead.
+	if cfg.Process.Cwd != "" && cfg.Process.Remote == "" {
+		if err := checkDir(cfg.Process.Cwd); err != nil {
+			return &startupError{fmt.Errorf("agent working directory: %w", err)}
+		}
+	}
+
 	log, teardown := logger.Setup(cfg.Log)
 	defer func() {
 		if err := teardown(); err != nil {
 			slog.Warn("log teardown failed", "error", err)
 		}
 	}()
+	logWrapperStart(log, cfg, os.Args)
+	pruneLogs(cfg, log)
+
+	if cfg.Record != "" {
+		rec, err := openRecorder(cfg.Record)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := rec.Close(); err != nil {
+				log.Warn("recording agent output failed", "error", err)
+			}
+		}()
+		cfg.record = rec
+		log.Info("recording agent output", "path", cfg.Record)
+	}
+	// Removed once the session's turns are over and their agents reaped.
+	cfg.prompts = &promptFiles{}
+	defer func() {
+		if err := cfg.prompts.removeAll(); err != nil {
+			log.Warn("prompt file cleanup failed", "error", err)
+		}
+	}()
+	if cfg.Replay != "" {
+		src, err := openReplaySource(cfg.Replay, cfg.ReplaySpeed)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = src.Close() }() // read-only
+		cfg.replay = src
+		log.Info("replaying recorded agent output instead of running cursor-agent", "path", cfg.Replay, "speed", cfg.ReplaySpeed)
+	}
 
-	fmtr := format.New(cfg.OutputFormat, os.Stdout)
+	// Closed before the log is torn down, so a notification still being
+	// sent can log how it went.
+	cfg.webhook = newWebhookNotifier(cfg, log)
+	defer cfg.webhook.Close()
 
+	// Written on every path out of the session, before the log is torn
+	// down, so the summary is complete by the time the process exits.
+	defer func() {
+		summary.finish(log.FilePath(), runErr)
+		summary.EventsLogFile = log.EventsFilePath()
+		path := summaryPath(cfg, log)
+		if path == "" {
+			return
+		}
+		if err := writeSummary(path, summary); err != nil {
+			log.Warn("writing session summary failed", "error", err)
+		}
+	}()
+	// Panics in a turn are recovered by runTurn, which also stops the
+	// agent; one anywhere else still makes it into the log and summary.
+	defer func() {
+		if r := recover(); r != nil {
+			p := newPanicError("main loop", r)
+			logPanic(log, p)
+			runErr = p
+		}
+	}()
+	if cfg.DetachPidFile != "" {
+		log.Info("running detached", "pid", os.Getpid(), "pidfile", cfg.DetachPidFile)
+	}
+
+	var batch []batchPrompt
+	if cfg.Batch != "" {
+		var err error
+		if batch, err = readBatch(cfg.Batch); err != nil {
+			return err
+		}
+	}
+
+	if !cfg.SkipPreflight && cfg.Replay == "" {
+		if err := preflight(ctx, cfg, log); err != nil {
+			return &startupError{err}
+		}
+	}
+
+	if cfg.PidFile != "" {
+		checkStalePidfile(cfg.PidFile, log)
+		defer func() {
+			if err := os.Remove(cfg.PidFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Warn("removing pidfile failed", "pidfile", cfg.PidFile, "error", err)
+			}
+		}()
+	}
+
+	fmtr := format.New(cfg.OutputFormat, os.Stdout, format.WithWrapperEvents(cfg.WrapperEvents), format.WithToolOutput(cfg.StreamToolOutput), format.WithThinking(cfg.ShowThinking), format.WithColor(isTerminal(os.Stdout)))
+
+	if batch != nil {
+		return runBatch(ctx, cfg, batch, fmtr, log, summary)
+	}
+
+	if !cfg.Print {
+		cfg.editor = newPromptEditor(cfg, log)
+	}
+	// The session each turn resumes: pre-seeded if --resume was passed,
+	// and changed by the agent or /resume.
+	session := &commandState{
+		SessionID: cfg.Process.SessionID,
+		next:      func() (string, error) { return nextPrompt(cfg) },
+		out:       os.Stderr,
+		log:       log,
+	}
 	prompt, err := firstPrompt(cfg)
+	var overrides turnOverrides
+	if err == nil && !cfg.Print {
+		prompt, overrides, err = session.takeCommands(prompt)
+	}
+	if errors.Is(err, errExitCommand) {
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("reading prompt: %w", err)
 	}
@@ -77,56 +342,122 @@ func run(ctx context.Context, cfg Config) error {
 		log.Warn("--prompt-after-hang has no effect in -p (print) mode")
 	}
 
-	sessionID := cfg.Process.SessionID // pre-seeded if --resume was passed
+	// Command durations span every turn of the session so a command timed
+	// in one turn tightens its deadline in the next.
+	var durations *monitor.CommandDurations
+	if cfg.EstimateFactor > 0 {
+		durations = monitor.NewCommandDurations(cfg.EstimateFactor)
+	}
 	hangRetries := 0
+	abnormalRetries := 0
+	var agentBin *process.BinaryInfo // binary that served the last turn
+	tracer := newTracer(cfg, log)
+	turn := 0
 	for {
-		// Value copy of process.Config. Safe because the loop only sets
-		// Prompt and SessionID (both strings). ExtraFlags is a shared
-		// slice but is never mutated after parseFlags returns.
-		procCfg := cfg.Process
+		// Every record about the turn carries turn=N, so a multi-turn log
+		// can be split with e.g. jq 'select(.turn == 2)'.
+		turn++
+		turnLog := log.WithTurn(turn)
+
+		// Each turn gets its own copy of the process config, so /flags
+		// overrides apply to their turn (and its retries) only.
+		procCfg := cfg.Process.Clone()
 		procCfg.Prompt = prompt
-		procCfg.SessionID = sessionID // empty on first turn
+		procCfg.SessionID = session.SessionID // empty on first turn
+		overrides.apply(&procCfg)
+
+		if cfg.Replay == "" {
+			agentBin = checkAgentBinary(cfg, agentBin, turnLog)
+		}
+		trace := startTurnTrace(tracer, turn, procCfg)
+		result := runTurn(ctx, turn, procCfg, fmtr, turnLog, cfg, durations, trace)
+		trace.End(result)
+		flushTrace(tracer, turnLog)
+		summary.addTurn(turn, result)
 
-		result := runTurn(ctx, procCfg, fmtr, log, cfg)
+		switch id := result.SessionID; {
+		case id == "" || id == session.SessionID:
+		case session.SessionID == "":
+			session.SessionID = id
+			turnLog.Info("session started", "session_id", id)
+			log.SetSessionID(id)
+		default:
+			// The agent didn't pick the session back up (it may refuse
+			// to after a hang) and started another; later turns resume
+			// the new one, and the log is named after both.
+			turnLog.Warn("agent started a new session", "session_id", id, "previous_session_id", session.SessionID)
+			session.SessionID = id
+			log.AddSessionID(id)
+		}
 
-		if result.SessionID != "" && sessionID == "" {
-			sessionID = result.SessionID
-			log.Info("session started", "session_id", sessionID)
-			log.SetSessionID(sessionID)
+		if errors.Is(result.Err, ErrAbnormalExit) && session.SessionID != "" && abnormalRetries < cfg.RetryOnAbnormalExit {
+			// A crashed agent can usually pick the session back up, so
+			// re-run the same prompt with --resume.
+			abnormalRetries++
+			wait := startBackoff(abnormalExitRetryDelay, abnormalRetries-1)
+			turnLog.Warn("agent exited abnormally, resuming turn",
+				"error", result.Err,
+				"attempt", abnormalRetries,
+				"max_attempts", cfg.RetryOnAbnormalExit,
+				"retry_in_ms", wait.Milliseconds(),
+			)
+			if !sleepCtx(ctx, wait) {
+				return result.Err
+			}
+			continue
 		}
+		abnormalRetries = 0
 
 		if result.Err != nil {
 			if cfg.Print {
 				// Non-interactive: exit on any error.
 				return result.Err
 			}
-			// Interactive: only hangs are recoverable.
-			if errors.Is(result.Err, ErrHangDetected) {
-				fmtr.WriteHangIndicator(result.Reason)
+			// Interactive: only hangs, turn timeouts, agent-reported
+			// errors and runaway output are recoverable.
+			switch {
+			case errors.Is(result.Err, ErrFatalStderr):
+				fmt.Fprintf(os.Stderr, "✗ cursor-agent: %s\n", result.StderrMatch)
+				turnLog.Warn("fatal agent error, awaiting next prompt")
+			case errors.Is(result.Err, ErrOutputLimit):
+				fmt.Fprintf(os.Stderr, "✗ %v\n", result.Err)
+				turnLog.Warn("output limit exceeded, awaiting next prompt")
+			case errors.Is(result.Err, ErrTurnTimeout):
+				fmt.Fprintf(os.Stderr, "✗ %v\n", result.Err)
+				turnLog.Warn("turn timed out, awaiting next prompt")
+			case errors.Is(result.Err, ErrHangDetected):
+				fmtr.WriteHangIndicator(result.Reason, result.KilledAt)
 				if cfg.PromptAfterHang != "" {
-					hangRetries++
-					if hangRetries > cfg.MaxHangRetries {
-						log.Error("max hang retries exceeded", "retries", hangRetries)
+					if hangRetries >= cfg.MaxHangRetries {
+						turnLog.Error("max hang retries exceeded", "retries", hangRetries, "max_retries", cfg.MaxHangRetries)
 						return result.Err
 					}
+					hangRetries++
 					prompt = cfg.PromptAfterHang
-					log.Info("using prompt-after-hang", "prompt", prompt, "retry", hangRetries)
+					turnLog.Info("using prompt-after-hang", "prompt", prompt, "retry", hangRetries, "max_retries", cfg.MaxHangRetries)
 					continue
 				}
-				log.Warn("hang detected, awaiting next prompt")
-			} else {
+				turnLog.Warn("hang detected, awaiting next prompt")
+			default:
 				return result.Err // non-recoverable errors exit even in interactive mode
 			}
 		}
 
+		if !errors.Is(result.Err, ErrHangDetected) {
+			hangRetries = 0 // the limit is on hangs in a row
+		}
+
 		if cfg.Print {
 			break // single turn in non-interactive mode
 		}
 
-		prompt, err = readPrompt(cfg.PromptReader)
+		prompt, err = nextPrompt(cfg)
+		if err == nil {
+			prompt, overrides, err = session.takeCommands(prompt)
+		}
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return nil // clean exit on stdin EOF / Ctrl+D
+			if errors.Is(err, io.EOF) || errors.Is(err, errExitCommand) {
+				return nil // clean exit on stdin EOF / Ctrl+D, or /exit
 			}
 			return fmt.Errorf("reading prompt: %w", err)
 		}
@@ -134,84 +465,366 @@ func run(ctx context.Context, cfg Config) error {
 	return nil
 }
 
-func runTurn(ctx context.Context, procCfg process.Config, fmtr format.Formatter, log *logger.LogSession, cfg Config) TurnResult {
-	sess, err := process.Start(ctx, procCfg)
+func runTurn(ctx context.Context, turn int, procCfg process.Config, fmtr format.Formatter, log *logger.LogSession, cfg Config, durations *monitor.CommandDurations, trace *turnTrace) (res TurnResult) {
+	started := time.Now()
+	cfg.reload.apply(&cfg) // what earlier turns reloaded
+	logTurnStart(log, procCfg, cfg.LogPrompt)
+	prompt := procCfg.Prompt // preparePrompt may swap it for an @file reference
+	promptBytes := len(prompt)
+	delivery, err := preparePrompt(&procCfg, cfg.PromptFileThreshold, cfg.prompts)
 	if err != nil {
 		return TurnResult{Err: err}
 	}
 
-	eventCh := make(chan events.AnnotatedEvent, 64)
+	var sess agent
+	var agentStdout, agentStderr io.Reader
+	if cfg.replay != nil {
+		sess, agentStdout, agentStderr = startReplay(cfg.replay, log)
+	} else {
+		live, err := startWithRetry(ctx, procCfg, cfg.StartRetries, cfg.StartRetryDelay, log, process.Start)
+		if err != nil {
+			return TurnResult{Err: err}
+		}
+		if live.PriorityErr != nil {
+			log.Warn("could not lower agent priority", "error", live.PriorityErr)
+		}
+		if live.Stdin != nil {
+			// Streaming stdin stays open for the turn; closing it is the
+			// agent's cue that no more input is coming.
+			defer func() { _ = live.Stdin.Close() }()
+		}
+		sess, agentStdout, agentStderr = live, live.Stdout, live.Stderr
+	}
+	log.Info("agent started", logger.Kind(logger.KindTurnStats), "pid", sess.PID(), "prompt_bytes", promptBytes, "prompt_delivery", delivery)
+	if cfg.PidFile != "" {
+		if err := writePidfile(cfg.PidFile, sess.PID()); err != nil {
+			log.Warn("writing pidfile failed", "error", err)
+		}
+	}
+
+	eventCh := make(chan events.AnnotatedEvent, cfg.EventBuffer)
+	var queue events.QueueStats
+	var lags eventLags
+	defer func() {
+		log.Info("event queue", logger.Kind(logger.KindTurnStats), "capacity", cfg.EventBuffer, "max_depth", queue.MaxDepth(), "blocked_ms", queue.Blocked().Milliseconds())
+		lags.Log(log)
+	}()
 	readerErrCh := make(chan error, 1)
-	mon := monitor.NewMonitor(cfg.IdleTimeout, cfg.ToolGrace)
+	panicCh := make(chan *panicError, 1)
+	var monOpts []monitor.Option
+	if durations != nil {
+		monOpts = append(monOpts, monitor.WithCommandDurations(durations))
+	}
+	if cfg.MaxThinking > 0 {
+		monOpts = append(monOpts, monitor.WithMaxThinking(cfg.MaxThinking))
+	}
+	if cfg.NonJSONLiveness {
+		monOpts = append(monOpts, monitor.WithNonJSONLiveness(true))
+	}
+	mon := monitor.NewMonitor(cfg.IdleTimeout, cfg.ToolGrace, monOpts...)
 
 	var wg sync.WaitGroup
 
+	stdout := agentStdout
+	if cfg.record != nil {
+		stdout = io.TeeReader(stdout, cfg.record)
+	}
+	var budget *outputBudget
+	if cfg.MaxOutputBytes > 0 {
+		budget = newOutputBudget(stdout, cfg.MaxOutputBytes)
+		stdout = budget
+	}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		events.Reader(ctx, sess.Stdout, eventCh, readerErrCh)
+		defer reportPanic("event reader", panicCh)
+		events.Reader(ctx, stdout, eventCh, readerErrCh, events.WithQueueStats(&queue), events.WithTurn(turn), events.WithMaxEventBytes(int(cfg.MaxEventBytes)))
 	}()
 
+	tail := newStderrTail(stderrTailLines)
+	fatal := newFatalStderr(cfg.FatalStderrPatterns)
+	stderr := agentStderr
+	if cfg.AgentStderrFile != "" {
+		// Closed once the turn's goroutines are done (every return
+		// below follows wg.Wait).
+		file := newStderrFile(cfg.AgentStderrFile)
+		defer func() {
+			if err := file.Close(); err != nil {
+				log.Warn("copying agent stderr failed", "error", err)
+			}
+		}()
+		stderr = io.TeeReader(agentStderr, file)
+	}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		drainStderr(ctx, sess.Stderr, log)
+		defer reportPanic("stderr reader", panicCh)
+		drainStderr(ctx, stderr, log, tail, fatal)
 	}()
 
 	ticker := time.NewTicker(cfg.TickInterval)
 	defer ticker.Stop()
+	reapWait := reapWaitFor(procCfg.KillGrace)
+
+	// --turn-timeout is a deadline of the turn's own: unlike ctx being
+	// cancelled, its expiry ends this turn and not the session.
+	turnCtx, cancelTurn := ctx, context.CancelFunc(func() {})
+	if cfg.TurnTimeout > 0 {
+		turnCtx, cancelTurn = context.WithTimeoutCause(ctx, cfg.TurnTimeout, ErrTurnTimeout)
+	}
+	defer cancelTurn()
+
+	jobCh := make(chan os.Signal, 2)
+	signal.Notify(jobCh, jobControlSignals...)
+	defer signal.Stop(jobCh)
+	reloads := cfg.reload.requests()
+
+	// A panic in the event loop (say, in the formatter or the monitor)
+	// ends the turn rather than the process, so the agent isn't left
+	// running and the session log says what happened.
+	defer func() {
+		if r := recover(); r != nil {
+			p := newPanicError("event loop", r)
+			logPanic(log, p)
+			_ = sess.Kill("wrapper panic")
+			go func() {
+				for range eventCh { // let the reader finish
+				}
+			}()
+			waitDrained(&wg, sess, log)
+			_, _ = reap(sess, log, reapWait) // status is logged; the panic is the error
+			res = TurnResult{SessionID: mon.SessionID(), Err: p}
+		}
+	}()
+
+	var resultSubtype string
+	var result *events.Result
+	recent := newRecentEvents(cfg.HangDumpEvents, recentEventsMaxBytes)
+	counts := eventCounts{}
+	handleEvent := func(ev events.AnnotatedEvent) {
+		if ev.Parsed.Type == events.TypePartialLine {
+			logPartialLine(log, ev)
+			return
+		}
+		counts[ev.Parsed.Type]++
+		verdict := mon.ProcessEvent(ev)
+		logRawEvent(log, ev, verdict, mon.OpenCallIDs())
+		lags.Add(ev)
+		if ev.Parsed.Type == "system" && ev.Parsed.Subtype == "init" {
+			logSchema(log, ev)
+		}
+		recent.Add(ev)
+		if ev.Parsed.Type == "result" {
+			resultSubtype = ev.Parsed.Subtype
+			if payload, err := events.Decode(ev); err == nil {
+				r := payload.(events.Result)
+				result = &r
+				logResult(log, r)
+			}
+		}
+		if err := fmtr.WriteEvent(ev); err != nil {
+			log.Warn("formatter write error", "error", err)
+		}
+		logVerdict(log, verdict, ev)
+		trace.Event(ev)
+	}
 
 	var runErr error
+	var stderrMatch string
 	streamDone := false
+	readerFailed := func(err error) {
+		if errors.Is(err, ErrOutputLimit) {
+			log.Error("output limit exceeded", "bytes_read", budget.BytesRead(), "limit", cfg.MaxOutputBytes, "in_flight_type", budget.InFlightType())
+			_ = sess.Kill("output limit exceeded")
+			runErr = fmt.Errorf("cursor-agent wrote more than %d bytes to stdout in one turn: %w", cfg.MaxOutputBytes, ErrOutputLimit)
+			return
+		}
+		log.Error("event reader failed", "error", err)
+		_ = sess.Kill("reader error")
+		runErr = fmt.Errorf("event reader: %w", err)
+	}
+	// streamEnded handles eventCh closing, seen by the loop or while a
+	// hang verdict is debounced. A stream cut short by the Reader is its
+	// error, not the agent closing its output.
+	streamEnded := func() {
+		if err := readerErrAtEnd(readerErrCh); err != nil {
+			readerFailed(err)
+			return
+		}
+		runErr = handleStreamEnd(sess, mon, log, reapWait)
+		streamDone = true
+	}
+	queueFullWarned := false // once per spell of eventCh being full
 	for runErr == nil && !streamDone {
 		select {
 		case ev, ok := <-eventCh:
 			if !ok {
-				runErr = handleStreamEnd(sess, mon, log)
-				streamDone = true
+				streamEnded()
 			} else {
-				logRawEvent(log, ev)
-				if err := fmtr.WriteEvent(ev); err != nil {
-					log.Warn("formatter write error", "error", err)
-				}
-				verdict := mon.ProcessEvent(ev)
-				logVerdict(log, verdict, ev)
+				handleEvent(ev)
 			}
 
 		case err := <-readerErrCh:
-			log.Error("event reader failed", "error", err)
-			_ = sess.Kill("reader error")
-			runErr = fmt.Errorf("event reader: %w", err)
+			readerFailed(err)
+
+		case p := <-panicCh:
+			logPanic(log, p)
+			_ = sess.Kill("wrapper panic")
+			runErr = p
+
+		case m := <-fatal.Matches():
+			log.Error("fatal stderr pattern matched", "line", m.Line, "pattern", m.Pattern)
+			_ = sess.Kill("fatal stderr: " + m.Line)
+			stderrMatch = m.Line
+			runErr = fmt.Errorf("cursor-agent stderr %q matched %q: %w", m.Line, m.Pattern, ErrFatalStderr)
+
+		case sig := <-jobCh:
+			handleJobControl(sig, sess, mon, log)
+
+		case <-reloads:
+			if cfg.reload.load(log) {
+				cfg.reload.apply(&cfg)
+				mon.SetIdleTimeout(cfg.IdleTimeout)
+				mon.SetToolGrace(cfg.ToolGrace)
+				ticker.Reset(cfg.TickInterval)
+				log.SetConsoleLevel(cfg.Log.ConsoleLevel)
+			}
+
+		case <-cfg.status:
+			verdict, reason := mon.Snapshot(mon.Now())
+			reason.StderrTail = tail.Lines()
+			writeStatus(os.Stderr, log, turnStatus{
+				Turn:      turn,
+				SessionID: cmp.Or(mon.SessionID(), procCfg.SessionID),
+				Elapsed:   time.Since(started),
+				Verdict:   verdict,
+				Reason:    reason,
+				Events:    counts,
+			})
 
 		case <-ticker.C:
-			verdict, reason := mon.CheckTimeout(mon.Now())
-			if verdict == monitor.VerdictHang {
-				log.Error("hang detected", reasonAttrs(reason)...)
-				_ = sess.Kill(reason.String())
-				wg.Wait()
-				fmtr.Flush()
-				return TurnResult{SessionID: mon.SessionID(), Err: ErrHangDetected, Reason: reason}
+			if since := queue.BlockedSince(); since.IsZero() {
+				queueFullWarned = false
+			} else if full := time.Since(since); full > cfg.TickInterval && !queueFullWarned {
+				log.Warn("event queue full, handling events is falling behind the agent", "full_ms", full.Milliseconds(), "capacity", cfg.EventBuffer)
+				queueFullWarned = true
 			}
+			now := mon.Now()
+			for _, c := range mon.TakeOverdue(now) {
+				log.Warn("tool_overdue", append(openCallAttrs(c), logger.Kind(logger.KindVerdict))...)
+			}
+			verdict, _ := mon.CheckTimeout(now)
+			if verdict != monitor.VerdictHang {
+				continue
+			}
+			db := debounceHang(mon, eventCh, hangDebounceWait(cfg.TickInterval), handleEvent)
+			if db.StreamEnded {
+				log.Info("hang verdict debounced: stream ended", logger.Kind(logger.KindVerdict), "drained_events", db.Drained)
+				streamEnded()
+				continue
+			}
+			if db.Verdict != monitor.VerdictHang {
+				log.Info("hang verdict debounced", logger.Kind(logger.KindVerdict), "drained_events", db.Drained, "verdict", db.Verdict.String())
+				continue
+			}
+			log.Info("hang verdict confirmed after debounce", logger.Kind(logger.KindVerdict), "drained_events", db.Drained)
+			reason := db.Reason
+			reason.StderrTail = tail.Lines()
+			log.Error("hang detected", reasonAttrs(reason)...)
+			trace.Hang(reason)
+			log.Info("verdict_history", logger.Kind(logger.KindVerdict), historyAttr(mon.History()))
+			dumpRecentEvents(os.Stderr, log, recent.Events())
+			// With --log-async the verdict may still be queued; get it
+			// on disk before killing anything.
+			log.Flush()
+			// Keep forwarding events while the agent shuts down: with
+			// --kill-signal int it flushes a final result event first.
+			if n := killAndDrain(sess, reason.String(), eventCh, handleEvent); n > 0 {
+				log.Info("events drained during kill", "drained_events", n)
+			}
+			waitDrained(&wg, sess, log)
+			_, _ = reap(sess, log, reapWait) // status is logged; the hang is the error
+			fmtr.Flush()
+			cfg.webhook.Hang(turn, cmp.Or(mon.SessionID(), procCfg.SessionID), prompt, reason.AsOf(mon.Now()))
+			return TurnResult{SessionID: mon.SessionID(), Err: ErrHangDetected, Reason: reason, KilledAt: mon.Now(), ResultSubtype: resultSubtype, Result: result}
 
-		case <-ctx.Done():
-			_ = sess.Kill("context cancelled")
-			runErr = ctx.Err()
+		case <-turnCtx.Done():
+			if ctx.Err() != nil || !errors.Is(context.Cause(turnCtx), ErrTurnTimeout) {
+				_ = sess.Kill("context cancelled")
+				runErr = ctx.Err()
+				continue
+			}
+			// Whichever comes first ends the turn: a hang verdict on a
+			// tick, or this deadline, however lively the agent is.
+			verdict, reason := mon.Snapshot(mon.Now())
+			reason.StderrTail = tail.Lines()
+			log.Error("turn_timeout", append([]any{logger.Kind(logger.KindVerdict), "timeout_ms", cfg.TurnTimeout.Milliseconds(), "verdict", verdict.String()}, reasonAttrs(reason)...)...)
+			log.Flush()
+			if n := killAndDrain(sess, "turn timeout", eventCh, handleEvent); n > 0 {
+				log.Info("events drained during kill", "drained_events", n)
+			}
+			waitDrained(&wg, sess, log)
+			_, _ = reap(sess, log, reapWait) // status is logged; the timeout is the error
+			fmtr.Flush()
+			err := fmt.Errorf("turn ran longer than --turn-timeout %v: %w", cfg.TurnTimeout, ErrTurnTimeout)
+			return TurnResult{SessionID: mon.SessionID(), Err: err, Reason: reason, KilledAt: mon.Now(), ResultSubtype: resultSubtype, Result: result}
 		}
 	}
 
-	wg.Wait()
+	if streamDone {
+		wg.Wait()
+	} else {
+		// The loop ended by killing the agent; reap it so its exit is
+		// logged and it doesn't linger as a zombie.
+		waitDrained(&wg, sess, log)
+		_, _ = reap(sess, log, reapWait) // status is logged; runErr is the error
+	}
 	fmtr.Flush()
-	return TurnResult{SessionID: mon.SessionID(), Err: runErr}
+	if errors.Is(runErr, ErrAbnormalExit) {
+		cfg.webhook.AbnormalExit(turn, cmp.Or(mon.SessionID(), procCfg.SessionID), prompt, runErr)
+	}
+	return TurnResult{SessionID: mon.SessionID(), Err: runErr, StderrMatch: stderrMatch, ResultSubtype: resultSubtype, Result: result}
+}
+
+// pruneLogs applies --log-retain-days and --log-retain-count to the log
+// directory, sparing the log just created. Failures are only warnings.
+func pruneLogs(cfg Config, log *logger.LogSession) {
+	if cfg.LogRetainDays == 0 && cfg.LogRetainCount == 0 {
+		return
+	}
+	current := log.Parts()
+	if len(current) == 0 {
+		return // console only: the directory may not even exist
+	}
+	maxAge := time.Duration(cfg.LogRetainDays) * 24 * time.Hour
+	removed, err := logger.Prune(filepath.Dir(current[0]), current, maxAge, cfg.LogRetainCount, time.Now())
+	if len(removed) > 0 {
+		log.Info("pruned old session logs", "removed", removed)
+	}
+	if err != nil {
+		log.Warn("pruning old session logs failed", "error", err)
+	}
 }
 
 // firstPrompt resolves the initial prompt from the available sources.
-// Precedence: positional arg > stdin.
+// Precedence: positional arg > --prompt-file > stdin.
 // In -p mode with no positional arg, stdin is read to EOF (pipe mode).
 // In interactive mode with no positional arg, the first stdin line is used.
 func firstPrompt(cfg Config) (string, error) {
 	if cfg.PositionalPrompt != "" {
 		return cfg.PositionalPrompt, nil
 	}
+	if cfg.PromptFile != "" {
+		data, err := os.ReadFile(cfg.PromptFile)
+		if err != nil {
+			return "", fmt.Errorf("--prompt-file: %w", err)
+		}
+		prompt := strings.TrimRightFunc(string(data), unicode.IsSpace)
+		if strings.TrimSpace(prompt) == "" {
+			return "", fmt.Errorf("--prompt-file: %s is empty", cfg.PromptFile)
+		}
+		return prompt, nil
+	}
 	if cfg.Print {
 		// Non-interactive with no positional arg: require piped stdin.
 		if isTerminal(os.Stdin) {
@@ -229,7 +842,7 @@ func firstPrompt(cfg Config) (string, error) {
 		return prompt, nil
 	}
 	// Interactive: read first line from stdin.
-	return readPrompt(cfg.PromptReader)
+	return nextPrompt(cfg)
 }
 
 // readPrompt reads the next non-empty prompt from the given reader.
@@ -255,32 +868,168 @@ func readPrompt(r *bufio.Reader) (string, error) {
 	}
 }
 
+// readerErrAtEnd returns the error the Reader stopped with, if any, once
+// the event channel has closed. The Reader sends its error before closing
+// the channel, so by then it is waiting in errCh; nil means the agent
+// closed its output.
+func readerErrAtEnd(errCh <-chan error) error {
+	select {
+	case err := <-errCh:
+		return err
+	default:
+		return nil
+	}
+}
+
 // handleStreamEnd is called when the event channel closes (stdout EOF).
 // This means cursor-agent's stdout pipe is closed — the process is exiting
 // or has exited.
-func handleStreamEnd(sess *process.Session, mon *monitor.Monitor, log *logger.LogSession) error {
-	ps, err := sess.Wait()
-	if err != nil {
+func handleStreamEnd(sess agent, mon *monitor.Monitor, log *logger.LogSession, wait time.Duration) error {
+	st, err := reap(sess, log, wait, "session_done", mon.SessionDone())
+	if err != nil && !errors.Is(err, process.ErrForcedReap) {
+		return err
+	}
+	if mon.SessionDone() {
+		return nil // a lingering agent is only worth reap's warning
+	}
+	return fmt.Errorf("cursor-agent %s without emitting a result event: %w", st, ErrAbnormalExit)
+}
+
+// killAndDrain kills the agent and, until its stdout closes, passes any
+// events it emits on the way out to handle. It returns how many events
+// were drained. Without the drain the reader could block on a full
+// eventCh for the whole grace period and the events would be lost. If
+// stdout is still open drainAfterKill after the kill, it is closed.
+func killAndDrain(sess agent, reason string, eventCh <-chan events.AnnotatedEvent, handle func(events.AnnotatedEvent)) int {
+	killed := make(chan struct{})
+	go func() {
+		defer close(killed)
+		_ = sess.Kill(reason)
+	}()
+	n := 0
+	var closeOutput <-chan time.Time
+	for eventCh != nil || killed != nil {
+		select {
+		case ev, ok := <-eventCh:
+			if !ok {
+				eventCh = nil
+				continue
+			}
+			handle(ev)
+			n++
+		case <-killed:
+			killed = nil
+			if eventCh != nil {
+				closeOutput = time.After(drainAfterKill)
+			}
+		case <-closeOutput:
+			// A grandchild still holds stdout open; give up on it.
+			_ = sess.Close()
+		}
+	}
+	return n
+}
+
+// drainAfterKill is how long the turn keeps reading the agent's output
+// once it has been killed. Output left in the pipes is read well within
+// it; a pipe still open after that is held by a grandchild that outlived
+// the agent, and is closed so the turn can end.
+const drainAfterKill = time.Second
+
+// waitDrained waits for the turn's reader goroutines once the agent has
+// been killed, closing its output after drainAfterKill if they are still
+// blocked on pipes a grandchild holds open.
+func waitDrained(wg *sync.WaitGroup, sess agent, log *logger.LogSession) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(drainAfterKill):
+	}
+	log.Warn("agent output still open after kill, closing it")
+	_ = sess.Close() // the readers stop either way
+	<-done
+}
+
+// reapWaitFor bounds how long reap waits for the agent to exit by itself
+// before killing it. An agent whose output has ended normally exits at
+// once, so one kill grace period, but at least a second, is plenty.
+func reapWaitFor(killGrace time.Duration) time.Duration {
+	return max(killGrace, time.Second)
+}
+
+// reap waits up to wait for the agent to exit, then kills it, and logs
+// how it ended. A non-zero exit or signal is reported through the
+// returned status, not as an error. The error is for a failed wait, when
+// the status is empty, or wraps process.ErrForcedReap when the agent had
+// to be killed.
+func reap(sess agent, log *logger.LogSession, wait time.Duration, attrs ...any) (process.ExitStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	ps, err := sess.WaitContext(ctx, "still running after its output ended")
+	if ps == nil {
 		log.Error("process wait failed", "error", err)
-		// ps may be nil on wait failure — log what we can and treat as abnormal.
-		return fmt.Errorf("waiting for cursor-agent: %w", err)
+		return process.ExitStatus{}, fmt.Errorf("waiting for cursor-agent: %w", err)
+	}
+	if errors.Is(err, process.ErrForcedReap) {
+		log.Warn("cursor-agent did not exit in time, killed it to reap it", "waited_ms", wait.Milliseconds())
+	} else {
+		err = nil // non-zero exits are in the status
+	}
+	st := sess.ExitStatus()
+	attrs = append([]any{"exit_code", st.Code}, attrs...)
+	if st.Signal != 0 {
+		attrs = append(attrs, "signal", process.SignalName(st.Signal))
+	}
+	if st.KilledBy != "" {
+		attrs = append(attrs, "killed_by_wrapper", st.KilledBy)
+	}
+	log.Info("cursor-agent exited", append(attrs, "status", st.String(), logger.Kind(logger.KindTurnStats))...)
+	if err != nil {
+		return st, fmt.Errorf("waiting for cursor-agent: %w", err)
 	}
-	exitCode := ps.ExitCode()
-	log.Info("cursor-agent exited", "exit_code", exitCode, "session_done", mon.SessionDone())
+	return st, nil
+}
 
-	if mon.SessionDone() {
-		return nil
+// logResult records what the agent's result event says about the turn:
+// its outcome, and with newer agent builds the model and token usage.
+func logResult(log *logger.LogSession, r events.Result) {
+	attrs := []any{logger.Kind(logger.KindTurnStats), "subtype", r.Subtype, "is_error", r.IsError, "duration_ms", r.DurationMS, "request_id", r.RequestID}
+	if r.Model != "" {
+		attrs = append(attrs, "model", r.Model)
+	}
+	if u := r.Usage; u != nil {
+		attrs = append(attrs, "input_tokens", u.InputTokens, "output_tokens", u.OutputTokens, "cache_read_tokens", u.CacheReadTokens, "cache_write_tokens", u.CacheWriteTokens)
+	}
+	if r.ErrorMessage != "" {
+		attrs = append(attrs, "error_message", r.ErrorMessage)
+	}
+	log.Info("agent result", attrs...)
+}
+
+// logSchema records the stream-json schema generation the agent's init
+// event shows (see events.DetectSchema). One the wrapper doesn't know is
+// parsed as the current one, which may miss fields, and is warned about.
+func logSchema(log *logger.LogSession, ev events.AnnotatedEvent) {
+	if _, err := events.DetectSchema(ev.Raw); err != nil {
+		log.Warn("unknown agent stream schema, parsing it as the current one", "error", err, "current", events.SchemaCurrent.String())
+		return
 	}
-	return fmt.Errorf("cursor-agent exited (code %d) without emitting a result event: %w",
-		exitCode, ErrAbnormalExit)
+	log.Info("agent stream schema", logger.Kind(logger.KindTurnStats), "schema", ev.Schema.String())
 }
 
-// drainStderr reads and discards stderr, logging each line at debug level.
-// This prevents the child process from blocking on a full stderr pipe buffer.
+// drainStderr reads stderr, logging each line at debug level, keeping
+// the most recent lines in tail for hang reports, and reporting lines
+// that match a fatal pattern. Draining also prevents the child process
+// from blocking on a full stderr pipe buffer.
 // The context check inside the loop ensures prompt exit on cancellation,
 // even if the stderr pipe hasn't closed yet (belt-and-suspenders with
 // sess.Kill closing the pipe).
-func drainStderr(ctx context.Context, r io.Reader, log *logger.LogSession) {
+func drainStderr(ctx context.Context, r io.Reader, log *logger.LogSession, tail *stderrTail, fatal *fatalStderr) {
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		select {
@@ -288,22 +1037,58 @@ func drainStderr(ctx context.Context, r io.Reader, log *logger.LogSession) {
 			return
 		default:
 		}
-		log.Debug("stderr", "line", scanner.Text())
+		line := scanner.Text()
+		log.Debug("stderr", logger.Kind(logger.KindAgentStderr), "line", line)
+		tail.Add(line)
+		fatal.Check(line)
 	}
-	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+	// os.ErrClosed means the pipe was closed under us to end the turn.
+	if err := scanner.Err(); err != nil && ctx.Err() == nil && !errors.Is(err, os.ErrClosed) {
 		log.Warn("stderr read error", "error", err)
 	}
 }
 
-// logRawEvent writes a raw event capture record to the file sink.
-// This is the forensic replay record — it writes synchronously to the
-// O_SYNC file before any further processing, ensuring the event is
-// persisted even if the wrapper crashes immediately after.
-func logRawEvent(log *logger.LogSession, ev events.AnnotatedEvent) {
-	log.Debug("raw_event",
-		"recv_ts", ev.RecvTime.UnixMilli(),
-		slog.Any("raw", json.RawMessage(ev.Raw)),
-	)
+// logRawEvent writes a raw event capture record to the file sink, with
+// the monitor's reaction to it: the verdict and the tool calls open once
+// it is processed, so one record per event tells the whole story. This is
+// the forensic replay record — it is written, and under the default
+// --log-sync=critical fsynced, right after the monitor has updated its
+// state and before anything else, ensuring the event is persisted before
+// it is forwarded, even if the wrapper crashes immediately after. Without
+// a sink at debug level (--no-log-file and a quieter console) the event
+// isn't redacted for nothing.
+func logRawEvent(log *logger.LogSession, ev events.AnnotatedEvent, v monitor.Verdict, openCallIDs []string) {
+	if !log.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := []any{
+		logger.Kind(logger.KindRawEvent),
+		"seq", ev.Seq,
+		"recv_ts", ev.RecvTime,
+		"verdict", v.String(),
+		"open_calls", len(openCallIDs),
+	}
+	if !ev.AgentTime.IsZero() {
+		attrs = append(attrs, "lag_ms", ev.Lag.Milliseconds())
+	}
+	if len(openCallIDs) > 0 {
+		attrs = append(attrs, "open_call_ids", openCallIDs)
+	}
+	attrs = append(attrs, slog.Any("raw", json.RawMessage(log.RedactRaw(ev.Raw))))
+	if ev.Head != nil {
+		// The start of the event raw stands in for, for forensics.
+		attrs = append(attrs, "truncated_head", log.RedactText(string(ev.Head)))
+	}
+	log.Debug("raw_event", attrs...)
+}
+
+// logPartialLine logs the event the agent's output ended part way
+// through, usually as it was killed. It isn't an event the agent sent,
+// so it is only logged: not monitored, formatted or passed on.
+func logPartialLine(log *logger.LogSession, ev events.AnnotatedEvent) {
+	var partial events.WrapperNonJSON
+	_ = json.Unmarshal(ev.Raw, &partial) // the Reader made it; it parses
+	log.Warn("partial_final_line", "recv_ts", ev.RecvTime, "bytes", len(partial.Text), "text", log.RedactText(partial.Text))
 }
 
 // logVerdict logs the monitor's verdict for non-OK results.
@@ -311,8 +1096,61 @@ func logRawEvent(log *logger.LogSession, ev events.AnnotatedEvent) {
 // VerdictOK is not logged (too noisy for every event).
 func logVerdict(log *logger.LogSession, v monitor.Verdict, ev events.AnnotatedEvent) {
 	if v == monitor.VerdictWaiting {
-		log.Debug("verdict_waiting", "event_type", ev.Parsed.Type)
+		log.Debug("verdict_waiting", logger.Kind(logger.KindVerdict), "event_type", ev.Parsed.Type)
+	}
+}
+
+// tickRecord is the log shape of a monitor.TickSnapshot.
+type tickRecord struct {
+	TS        int64            `json:"ts"`
+	Verdict   string           `json:"verdict"`
+	IdleMS    int64            `json:"idle_ms"`
+	OpenCalls []openCallRecord `json:"open_calls,omitempty"`
+}
+
+type openCallRecord struct {
+	CallID              string `json:"call_id"`
+	Command             string `json:"command"`
+	ElapsedMS           int64  `json:"elapsed_ms"`
+	TimeoutMS           int64  `json:"timeout_ms"`
+	EstimatedDeadlineMS int64  `json:"estimated_deadline_ms,omitempty"`
+}
+
+// historyAttr renders the monitor's verdict history as a single attribute,
+// so the lead-up to a hang can be read from one log record.
+func historyAttr(h []monitor.TickSnapshot) slog.Attr {
+	ticks := make([]tickRecord, len(h))
+	for i, s := range h {
+		ticks[i] = tickRecord{
+			TS:      s.At.UnixMilli(),
+			Verdict: s.Verdict.String(),
+			IdleMS:  s.IdleMS,
+		}
+		for _, c := range s.OpenCalls {
+			ticks[i].OpenCalls = append(ticks[i].OpenCalls, openCallRecord{
+				CallID:              c.CallID,
+				Command:             c.Command,
+				ElapsedMS:           c.ElapsedMS,
+				TimeoutMS:           c.TimeoutMS,
+				EstimatedDeadlineMS: c.EstimatedDeadlineMS,
+			})
+		}
+	}
+	return slog.Any("ticks", ticks)
+}
+
+// openCallAttrs converts a single open call into slog key-value pairs.
+func openCallAttrs(c monitor.OpenCallDetail) []any {
+	attrs := []any{
+		"call_id", c.CallID,
+		"command", c.Command,
+		"elapsed_ms", c.ElapsedMS,
+		"timeout_ms", c.TimeoutMS,
+	}
+	if c.EstimatedDeadlineMS > 0 {
+		attrs = append(attrs, "estimated_deadline_ms", c.EstimatedDeadlineMS)
 	}
+	return attrs
 }
 
 // reasonAttrs converts a Reason into slog key-value pairs for structured logging.
@@ -322,6 +1160,12 @@ func reasonAttrs(r monitor.Reason) []any {
 		"open_call_count", r.OpenCallCount,
 		"last_event_type", r.LastEventType,
 	}
+	if r.ThinkingMS > 0 {
+		attrs = append(attrs, "thinking_ms", r.ThinkingMS)
+	}
+	if r.TotalOpenElapsedMS > 0 {
+		attrs = append(attrs, "total_open_elapsed_ms", r.TotalOpenElapsedMS)
+	}
 	for i, c := range r.OpenCalls {
 		prefix := fmt.Sprintf("open_call_%d", i)
 		attrs = append(attrs,
@@ -330,6 +1174,12 @@ func reasonAttrs(r monitor.Reason) []any {
 			prefix+"_elapsed_ms", c.ElapsedMS,
 			prefix+"_timeout_ms", c.TimeoutMS,
 		)
+		if c.EstimatedDeadlineMS > 0 {
+			attrs = append(attrs, prefix+"_estimated_deadline_ms", c.EstimatedDeadlineMS)
+		}
+	}
+	for i, line := range r.StderrTail {
+		attrs = append(attrs, fmt.Sprintf("stderr_tail_%d", i), line)
 	}
 	return attrs
 }
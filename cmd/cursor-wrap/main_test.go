@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -27,7 +29,7 @@ func TestLogRawEvent_ProducesValidJSONL(t *testing.T) {
 	}
 
 	log, teardown := setupTestLogger(t)
-	logRawEvent(log, ev)
+	logRawEvent(log, ev, monitor.VerdictWaiting, []string{"call-1", "call-2"})
 	teardown()
 
 	// Read the log file and verify the JSONL record.
@@ -55,6 +57,10 @@ func TestLogRawEvent_ProducesValidJSONL(t *testing.T) {
 		}
 		found = true
 
+		if string(record["kind"]) != `"raw_event"` || string(record["schema_version"]) != "1" {
+			t.Errorf("kind = %s, schema_version = %s; want raw_event, 1", record["kind"], record["schema_version"])
+		}
+
 		// Verify recv_ts is present and is a positive integer.
 		if _, ok := record["recv_ts"]; !ok {
 			t.Error("raw_event record missing recv_ts field")
@@ -67,6 +73,12 @@ func TestLogRawEvent_ProducesValidJSONL(t *testing.T) {
 			t.Errorf("recv_ts = %d, want positive epoch millis", recvTS)
 		}
 
+		// The monitor's verdict and open calls ride along.
+		if string(record["verdict"]) != `"Waiting"` || string(record["open_calls"]) != "2" || string(record["open_call_ids"]) != `["call-1","call-2"]` {
+			t.Errorf("verdict = %s, open_calls = %s, open_call_ids = %s; want Waiting, 2, both ids",
+				record["verdict"], record["open_calls"], record["open_call_ids"])
+		}
+
 		// Verify raw field is present and is valid JSON.
 		rawField, ok := record["raw"]
 		if !ok {
@@ -141,6 +153,86 @@ func TestReasonAttrs_WithOpenCalls(t *testing.T) {
 	}
 }
 
+func TestReasonAttrs_WithStderrTail(t *testing.T) {
+	r := monitor.Reason{
+		IdleSilenceMS: 65000,
+		LastEventType: "thinking",
+		StderrTail:    []string{"retrying request", "rate limit exceeded"},
+	}
+	attrs := reasonAttrs(r)
+
+	wantLen := 6 + 2*2 // 3 base KV pairs + 2 stderr lines
+	if len(attrs) != wantLen {
+		t.Fatalf("len(attrs) = %d, want %d", len(attrs), wantLen)
+	}
+	if attrs[6] != "stderr_tail_0" || attrs[7] != "retrying request" {
+		t.Errorf("attrs[6:8] = %v, want [stderr_tail_0 retrying request]", attrs[6:8])
+	}
+	if attrs[8] != "stderr_tail_1" || attrs[9] != "rate limit exceeded" {
+		t.Errorf("attrs[8:10] = %v, want [stderr_tail_1 rate limit exceeded]", attrs[8:10])
+	}
+}
+
+func TestReasonAttrs_WithThinking(t *testing.T) {
+	attrs := reasonAttrs(monitor.Reason{LastEventType: "thinking/delta", ThinkingMS: 600000})
+	if len(attrs) != 8 {
+		t.Fatalf("len(attrs) = %d, want 8", len(attrs))
+	}
+	if attrs[6] != "thinking_ms" || attrs[7] != int64(600000) {
+		t.Errorf("attrs[6:8] = %v, want [thinking_ms 600000]", attrs[6:8])
+	}
+}
+
+func TestOpenCallAttrs(t *testing.T) {
+	tests := []struct {
+		name string
+		c    monitor.OpenCallDetail
+		want []any
+	}{
+		{
+			name: "declared deadline",
+			c:    monitor.OpenCallDetail{CallID: "call-1", Command: "sleep 60", ElapsedMS: 41000, TimeoutMS: 10000},
+			want: []any{"call_id", "call-1", "command", "sleep 60", "elapsed_ms", int64(41000), "timeout_ms", int64(10000)},
+		},
+		{
+			name: "estimated deadline",
+			c:    monitor.OpenCallDetail{CallID: "call-2", Command: "go test", ElapsedMS: 31000, TimeoutMS: 120000, EstimatedDeadlineMS: 30000},
+			want: []any{"call_id", "call-2", "command", "go test", "elapsed_ms", int64(31000), "timeout_ms", int64(120000), "estimated_deadline_ms", int64(30000)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := openCallAttrs(tt.c)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("openCallAttrs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryAttr(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := []monitor.TickSnapshot{
+		{At: at, Verdict: monitor.VerdictWaiting, IdleMS: 5000, OpenCalls: []monitor.OpenCallDetail{
+			{CallID: "call-1", Command: "sleep 60", ElapsedMS: 5000, TimeoutMS: 10000},
+		}},
+		{At: at.Add(5 * time.Second), Verdict: monitor.VerdictHang, IdleMS: 10000},
+	}
+	attr := historyAttr(h)
+	if attr.Key != "ticks" {
+		t.Fatalf("key = %q, want ticks", attr.Key)
+	}
+	data, err := json.Marshal(attr.Value.Any())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `[{"ts":1767225600000,"verdict":"Waiting","idle_ms":5000,"open_calls":[{"call_id":"call-1","command":"sleep 60","elapsed_ms":5000,"timeout_ms":10000}]},` +
+		`{"ts":1767225605000,"verdict":"Hang","idle_ms":10000}]`
+	if string(data) != want {
+		t.Errorf("ticks =\n%s\nwant\n%s", data, want)
+	}
+}
+
 // --- handleStreamEnd tests ---
 
 func TestHandleStreamEnd_SessionDone_ReturnsNil(t *testing.T) {
@@ -173,7 +265,7 @@ func TestHandleStreamEnd_SessionDone_ReturnsNil(t *testing.T) {
 	log, teardown := setupTestLogger(t)
 	defer teardown()
 
-	err = handleStreamEnd(sess, mon, log)
+	err = handleStreamEnd(sess, mon, log, time.Second)
 	if err != nil {
 		t.Fatalf("handleStreamEnd returned error: %v", err)
 	}
@@ -196,7 +288,7 @@ func TestHandleStreamEnd_NoResult_ReturnsAbnormalExit(t *testing.T) {
 	log, teardown := setupTestLogger(t)
 	defer teardown()
 
-	err = handleStreamEnd(sess, mon, log)
+	err = handleStreamEnd(sess, mon, log, time.Second)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -208,6 +300,61 @@ func TestHandleStreamEnd_NoResult_ReturnsAbnormalExit(t *testing.T) {
 	}
 }
 
+func TestHandleStreamEnd_SignaledReportsSignal(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "agent.sh")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\ncat >/dev/null\nkill -KILL $$\n"), 0o755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	sess, err := process.Start(t.Context(), process.Config{AgentBin: bin, Prompt: "test"})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	io.Copy(io.Discard, sess.Stdout)
+
+	log, teardown := setupTestLogger(t)
+	err = handleStreamEnd(sess, monitor.NewMonitor(60*time.Second, 30*time.Second), log, time.Second)
+	teardown()
+
+	if !errors.Is(err, ErrAbnormalExit) {
+		t.Fatalf("expected ErrAbnormalExit, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "killed by SIGKILL (possible OOM)") {
+		t.Errorf("error = %q, want the terminating signal", err)
+	}
+	data, _ := os.ReadFile(log.FilePath())
+	if !strings.Contains(string(data), `"signal":"SIGKILL"`) {
+		t.Errorf("expected signal in exit log record, got %s", data)
+	}
+}
+
+// --- exitCode tests ---
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "hang", err: ErrHangDetected, want: 2},
+		{name: "wrapped hang", err: fmt.Errorf("turn: %w", ErrHangDetected), want: 2},
+		{name: "fatal stderr", err: fmt.Errorf("stderr: %w", ErrFatalStderr), want: 5},
+		{name: "abnormal exit", err: fmt.Errorf("stream ended: %w", ErrAbnormalExit), want: 3},
+		{name: "agent start failure", err: fmt.Errorf("turn 2: %w", &process.StartError{Err: os.ErrNotExist}), want: 4},
+		{name: "preflight failure", err: &startupError{errors.New("cursor-agent is not authenticated")}, want: 4},
+		{name: "panic", err: newPanicError("event loop", "boom"), want: 6},
+		{name: "turn timeout", err: fmt.Errorf("turn: %w", ErrTurnTimeout), want: 7},
+		{name: "failed batch", err: fmt.Errorf("%w: 1 of 2", ErrBatchFailed), want: 1},
+		{name: "generic", err: errors.New("boom"), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
 // --- firstPrompt tests ---
 
 func TestFirstPrompt_PositionalArg(t *testing.T) {
@@ -298,6 +445,62 @@ func TestFirstPrompt_Interactive_DelegatesToReadPrompt(t *testing.T) {
 	}
 }
 
+func TestFirstPrompt_PromptFile(t *testing.T) {
+	origIsTerminal := isTerminal
+	isTerminal = func(_ *os.File) bool { return false }
+	defer func() { isTerminal = origIsTerminal }()
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	doc := write("prompt.md", "  # Plan\n\nRefactor the parser.\n\n\t \n")
+	blank := write("blank.md", " \n\t\n")
+
+	tests := []struct {
+		name       string
+		print      bool
+		positional string
+		file       string
+		want       string
+		wantErr    string
+	}{
+		{name: "print mode", print: true, file: doc, want: "  # Plan\n\nRefactor the parser."},
+		{name: "interactive", file: doc, want: "  # Plan\n\nRefactor the parser."},
+		{name: "positional wins", print: true, positional: "from argv", file: doc, want: "from argv"},
+		{name: "empty", print: true, file: blank, wantErr: "is empty"},
+		{name: "missing", file: filepath.Join(dir, "missing.md"), wantErr: "--prompt-file: open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Print:            tt.print,
+				PositionalPrompt: tt.positional,
+				PromptFile:       tt.file,
+				PromptReader:     bufio.NewReader(strings.NewReader("from stdin\n")),
+			}
+			got, err := firstPrompt(cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("firstPrompt = %q, %v; want %q", got, err, tt.want)
+			}
+			// Stdin is left for the turns after the first.
+			if rest, _ := cfg.PromptReader.ReadString('\n'); tt.positional == "" && rest != "from stdin\n" {
+				t.Errorf("stdin was read: %q left", rest)
+			}
+		})
+	}
+}
+
 // --- readPrompt tests ---
 
 func TestReadPrompt_FirstNonEmpty(t *testing.T) {
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// outputHeadBytes is how much of the line being read outputBudget keeps
+// to identify the event in flight when the budget runs out.
+const outputHeadBytes = 256
+
+// eventTypeRE extracts the type from the start of a stream-json line.
+var eventTypeRE = regexp.MustCompile(`"type"\s*:\s*"([^"]*)"`)
+
+// outputBudget caps the bytes read from the agent's stdout in one turn,
+// so a tool result with a multi-GB file dump can't be buffered and
+// logged in full. Once limit bytes have been read, Read fails with
+// ErrOutputLimit.
+//
+// Read runs on the event reader goroutine. The other methods are only
+// safe once that goroutine has reported the error on readerErrCh.
+type outputBudget struct {
+	r     io.Reader
+	limit int64
+	n     int64
+	head  []byte // start of the line currently being read
+}
+
+func newOutputBudget(r io.Reader, limit int64) *outputBudget {
+	return &outputBudget{r: r, limit: limit}
+}
+
+func (b *outputBudget) Read(p []byte) (int, error) {
+	if b.n >= b.limit {
+		return 0, ErrOutputLimit
+	}
+	if remaining := b.limit - b.n; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	b.track(p[:n])
+	return n, err
+}
+
+// track keeps the first outputHeadBytes of the current line.
+func (b *outputBudget) track(p []byte) {
+	if i := bytes.LastIndexByte(p, '\n'); i >= 0 {
+		b.head = b.head[:0]
+		p = p[i+1:]
+	}
+	if room := outputHeadBytes - len(b.head); room > 0 {
+		b.head = append(b.head, p[:min(room, len(p))]...)
+	}
+}
+
+// BytesRead returns the number of bytes read so far.
+func (b *outputBudget) BytesRead() int64 {
+	return b.n
+}
+
+// InFlightType returns the type of the event that was being read when the
+// budget ran out, or "" if it can't be told.
+func (b *outputBudget) InFlightType() string {
+	if m := eventTypeRE.FindSubmatch(b.head); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
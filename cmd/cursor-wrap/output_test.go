@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestOutputBudget(t *testing.T) {
+	const stream = `{"type":"system","subtype":"init"}` + "\n" + `{"type":"tool_call","subtype":"completed","stdout":"xxxxxxxxxxxxxxxx"}` + "\n"
+
+	tests := []struct {
+		name     string
+		limit    int64
+		wantErr  error
+		wantRead int64
+		wantType string
+	}{
+		{"under budget", 1000, nil, int64(len(stream)), ""},
+		{"cut mid event", 60, ErrOutputLimit, 60, "tool_call"},
+		{"cut before type", 40, ErrOutputLimit, 40, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// OneByteReader exercises the line tracking across reads.
+			b := newOutputBudget(iotest.OneByteReader(strings.NewReader(stream)), tt.limit)
+			_, err := io.ReadAll(b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := b.BytesRead(); got != tt.wantRead {
+				t.Errorf("BytesRead = %d, want %d", got, tt.wantRead)
+			}
+			if tt.wantErr != nil {
+				if got := b.InFlightType(); got != tt.wantType {
+					t.Errorf("InFlightType = %q, want %q", got, tt.wantType)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"cursor-wrap/internal/process"
+)
+
+// flagsCommand starts an interactive line that changes the agent's
+// arguments for the next turn only, e.g. "/flags --model gpt-5 --verbose".
+const flagsCommand = "/flags"
+
+// turnOverrides change the agent's arguments for a single turn.
+type turnOverrides struct {
+	Model      string   // replaces --model when non-empty
+	Workspace  string   // replaces --workspace when non-empty
+	ExtraFlags []string // appended to the session's extra flags
+}
+
+// parseFlagsCommand parses a /flags line. --model and --workspace, with
+// their value as the next word or after "=", override the session's
+// settings; every other word is passed to cursor-agent as an extra flag.
+// ok is false if line is not a /flags line.
+func parseFlagsCommand(line string) (ov turnOverrides, ok bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != flagsCommand {
+		return turnOverrides{}, false, nil
+	}
+	args := fields[1:]
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		var dst *string
+		switch name {
+		case "--model":
+			dst = &ov.Model
+		case "--workspace":
+			dst = &ov.Workspace
+		case "--resume":
+			return turnOverrides{}, true, fmt.Errorf("%s: --resume is managed by cursor-wrap", flagsCommand)
+		default:
+			ov.ExtraFlags = append(ov.ExtraFlags, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return turnOverrides{}, true, fmt.Errorf("%s: %s needs a value", flagsCommand, name)
+			}
+			i++
+			value = args[i]
+		}
+		*dst = value
+	}
+	return ov, true, nil
+}
+
+// apply sets the overrides on procCfg, which must be the turn's own
+// copy (see process.Config.Clone).
+func (ov turnOverrides) apply(procCfg *process.Config) {
+	if ov.Model != "" {
+		procCfg.Model = ov.Model
+	}
+	if ov.Workspace != "" {
+		procCfg.Workspace = ov.Workspace
+	}
+	procCfg.ExtraFlags = append(procCfg.ExtraFlags, ov.ExtraFlags...)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"cursor-wrap/internal/process"
+)
+
+func TestParseFlagsCommand(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    turnOverrides
+		wantOK  bool
+		wantErr bool
+	}{
+		{"fix the tests", turnOverrides{}, false, false},
+		{"/flagsmith", turnOverrides{}, false, false},
+		{"/flags", turnOverrides{}, true, false},
+		{"/flags --model gpt-5", turnOverrides{Model: "gpt-5"}, true, false},
+		{"/flags --model=gpt-5 --workspace /src", turnOverrides{Model: "gpt-5", Workspace: "/src"}, true, false},
+		{"/flags --verbose --model gpt-5 --max-turns 3", turnOverrides{Model: "gpt-5", ExtraFlags: []string{"--verbose", "--max-turns", "3"}}, true, false},
+		{"/flags --model", turnOverrides{}, true, true},
+		{"/flags --resume abc", turnOverrides{}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, ok, err := parseFlagsCommand(tt.line)
+			if ok != tt.wantOK || (err != nil) != tt.wantErr {
+				t.Fatalf("ok = %v, err = %v; want ok %v, error %v", ok, err, tt.wantOK, tt.wantErr)
+			}
+			if got.Model != tt.want.Model || got.Workspace != tt.want.Workspace || !slices.Equal(got.ExtraFlags, tt.want.ExtraFlags) {
+				t.Errorf("overrides = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTurnOverridesApply(t *testing.T) {
+	session := process.Config{Model: "base", Workspace: "/repo", ExtraFlags: make([]string, 1, 4)}
+	session.ExtraFlags[0] = "--session-flag"
+
+	turn := session.Clone()
+	turnOverrides{Model: "gpt-5", ExtraFlags: []string{"--turn-flag"}}.apply(&turn)
+	if turn.Model != "gpt-5" || turn.Workspace != "/repo" {
+		t.Errorf("turn model/workspace = %q/%q, want gpt-5//repo", turn.Model, turn.Workspace)
+	}
+	if want := []string{"--session-flag", "--turn-flag"}; !slices.Equal(turn.ExtraFlags, want) {
+		t.Errorf("turn ExtraFlags = %q, want %q", turn.ExtraFlags, want)
+	}
+
+	// The next turn starts from the session's settings again.
+	next := session.Clone()
+	turnOverrides{}.apply(&next)
+	if next.Model != "base" || !slices.Equal(next.ExtraFlags, []string{"--session-flag"}) {
+		t.Errorf("next turn = %q %q, want the session's settings", next.Model, next.ExtraFlags)
+	}
+}
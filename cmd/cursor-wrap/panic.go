@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"cursor-wrap/internal/logger"
+)
+
+// ErrWrapperPanic marks a run the wrapper abandoned because its own code
+// panicked: a bug in the wrapper, not a problem with the agent.
+var ErrWrapperPanic = errors.New("wrapper panic")
+
+// panicError is a recovered panic, with the stack of the goroutine that
+// panicked.
+type panicError struct {
+	where string // the goroutine: "event loop", "event reader", …
+	value any
+	stack []byte
+}
+
+// newPanicError must be called from the deferred function that
+// recovered value, so that the stack is still the panicking one.
+func newPanicError(where string, value any) *panicError {
+	return &panicError{where: where, value: value, stack: debug.Stack()}
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.where, e.value)
+}
+
+func (e *panicError) Unwrap() error { return ErrWrapperPanic }
+
+// logPanic writes the wrapper_panic record and makes sure it is on disk:
+// it is the one record a post-mortem of the wrapper needs.
+func logPanic(log *logger.LogSession, p *panicError) {
+	log.Error("wrapper_panic", "where", p.where, "panic", fmt.Sprint(p.value), "stack", string(p.stack))
+	log.Flush()
+}
+
+// reportPanic is deferred by the goroutines runTurn starts. It recovers
+// a panic and hands it to the event loop on ch, which logs it and ends
+// the turn; left alone it would kill the process with the agent still
+// running and the session log cut short.
+func reportPanic(where string, ch chan<- *panicError) {
+	if r := recover(); r != nil {
+		select {
+		case ch <- newPanicError(where, r):
+		default: // another goroutine's panic is already ending the turn
+		}
+	}
+}
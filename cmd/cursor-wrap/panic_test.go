@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/monitor"
+)
+
+// panickingFormatter panics on the first event, standing in for a bug in
+// a real formatter.
+type panickingFormatter struct{}
+
+func (panickingFormatter) WriteEvent(events.AnnotatedEvent) error {
+	var m map[string]int
+	m["boom"]++ // assignment to entry in nil map
+	return nil
+}
+
+func (panickingFormatter) WriteHangIndicator(monitor.Reason, time.Time) error { return nil }
+func (panickingFormatter) WriteBatchHeader(int, int, string) error            { return nil }
+func (panickingFormatter) Flush() error                                       { return nil }
+
+func TestRunTurn_RecoversFormatterPanic(t *testing.T) {
+	t.Setenv("FAKE_AGENT_SCENARIO", "idle_hang") // would run for a minute
+	cfg := parseFlags([]string{"-p", "--agent-bin", fakeAgentBin, "--kill-grace", "0", "test prompt"})
+	cfg.PidFile = filepath.Join(t.TempDir(), "agent.pid")
+	procCfg := cfg.Process.Clone()
+	procCfg.Prompt = "test prompt"
+	log, teardown := setupTestLogger(t)
+
+	res := runTurn(context.Background(), 1, procCfg, panickingFormatter{}, log, cfg, nil, nil)
+	teardown()
+
+	if !errors.Is(res.Err, ErrWrapperPanic) || exitCode(res.Err) != 6 {
+		t.Fatalf("Err = %v, want a wrapper panic (exit code 6)", res.Err)
+	}
+	data, err := os.ReadFile(log.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if !strings.Contains(content, `"msg":"wrapper_panic"`) || !strings.Contains(content, "assignment to entry in nil map") ||
+		!strings.Contains(content, "panickingFormatter.WriteEvent") {
+		t.Errorf("expected a wrapper_panic record with the panic and its stack, got:\n%s", content)
+	}
+	if !strings.Contains(content, `"killed_by_wrapper":"wrapper panic"`) {
+		t.Errorf("expected the agent to be killed and reaped, got:\n%s", content)
+	}
+
+	pidData, err := os.ReadFile(cfg.PidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(pid, 0); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("agent pid %d still exists (kill 0: %v)", pid, err)
+	}
+}
+
+func TestReportPanic_HandsPanicToEventLoop(t *testing.T) {
+	ch := make(chan *panicError, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer reportPanic("event reader", ch)
+		panic("reader bug")
+	}()
+	<-done
+	select {
+	case p := <-ch:
+		if p.where != "event reader" || p.value != "reader bug" || !strings.Contains(string(p.stack), "TestReportPanic_HandsPanicToEventLoop") {
+			t.Errorf("panicError = %v\n%s", p, p.stack)
+		}
+	default:
+		t.Fatal("no panic reported")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"cursor-wrap/internal/logger"
+)
+
+// checkStalePidfile warns about a pidfile left behind by an earlier run
+// (e.g. one that was SIGKILLed before it could clean up). It never fails:
+// the file is overwritten by the first turn either way.
+func checkStalePidfile(path string, log *logger.LogSession) {
+	pid, err := readPidfile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return
+	case err != nil:
+		log.Warn("unreadable pidfile will be overwritten", "pidfile", path, "error", err)
+	case processAlive(pid):
+		log.Warn("pidfile names a running process; another cursor-wrap may be using it", "pidfile", path, "pid", pid)
+	default:
+		log.Warn("stale pidfile will be overwritten", "pidfile", path, "pid", pid)
+	}
+}
+
+// writePidfile replaces path with pid. The write goes through a temporary
+// file and a rename so a reader never sees a partial pid.
+func writePidfile(path string, pid int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("pidfile: %w", err)
+	}
+	_, err = fmt.Fprintf(tmp, "%d\n", pid)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name()) // best effort; the write error is what matters
+		return fmt.Errorf("pidfile: %w", err)
+	}
+	return nil
+}
+
+// readPidfile parses the pid stored in path.
+func readPidfile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%s: invalid pid %q", path, strings.TrimSpace(string(data)))
+	}
+	return pid, nil
+}
+
+// processAlive reports whether a process with the given pid exists.
+// EPERM means it exists but belongs to another user.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWritePidfile_Replaces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.pid")
+
+	for _, pid := range []int{123, 4567} {
+		if err := writePidfile(path, pid); err != nil {
+			t.Fatalf("writePidfile(%d): %v", pid, err)
+		}
+		got, err := readPidfile(path)
+		if err != nil {
+			t.Fatalf("readPidfile: %v", err)
+		}
+		if got != pid {
+			t.Errorf("pidfile = %d, want %d", got, pid)
+		}
+	}
+
+	// No temporary files are left next to the pidfile.
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the pidfile in its directory, got %d entries", len(entries))
+	}
+}
+
+func TestWritePidfile_MissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "agent.pid")
+	if err := writePidfile(path, 123); err == nil {
+		t.Fatal("expected error for a missing directory")
+	}
+}
+
+func TestCheckStalePidfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string // empty means no file
+		wantLog  string
+	}{
+		{"no pidfile", "", ""},
+		{"dead process", "999999999\n", "stale pidfile will be overwritten"},
+		{"running process", "1\n", "pidfile names a running process"},
+		{"garbage", "not-a-pid\n", "unreadable pidfile will be overwritten"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.pid")
+			if tt.contents != "" {
+				if err := os.WriteFile(path, []byte(tt.contents), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			log, teardown := setupTestLogger(t)
+			checkStalePidfile(path, log)
+			teardown()
+
+			data, err := os.ReadFile(log.FilePath())
+			if err != nil {
+				t.Fatalf("reading log: %v", err)
+			}
+			if tt.wantLog == "" {
+				if strings.Contains(string(data), "pidfile") {
+					t.Errorf("expected no pidfile warning, log:\n%s", data)
+				}
+				return
+			}
+			if !strings.Contains(string(data), tt.wantLog) {
+				t.Errorf("log missing %q:\n%s", tt.wantLog, data)
+			}
+		})
+	}
+}
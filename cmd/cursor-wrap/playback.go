@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/process"
+)
+
+// agent is the cursor-agent a turn drives: a started *process.Session,
+// or with --replay a *replayAgent playing back a recording.
+type agent interface {
+	PID() int
+	Kill(reason string) error
+	Suspend() error
+	Resume() error
+	Close() error
+	WaitContext(ctx context.Context, reason string) (*os.ProcessState, error)
+	ExitStatus() process.ExitStatus
+}
+
+// replaySource is the --replay recording, shared by the session's turns:
+// each plays it from where the one before stopped. It reads either a
+// --record capture, whose lines are played as they are, or a session
+// log, whose raw_event records are played at the pace their recv_ts
+// gives, scaled by --replay-speed.
+type replaySource struct {
+	f        *os.File
+	br       *bufio.Reader
+	speed    float64   // 0 plays without pauses
+	lastRecv time.Time // recv_ts of the last line played, if it had one
+}
+
+func openReplaySource(path string, speed float64) (*replaySource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("--replay: %w", err)
+	}
+	return &replaySource{f: f, br: bufio.NewReader(f), speed: speed}, nil
+}
+
+func (s *replaySource) Close() error {
+	return s.f.Close()
+}
+
+// next returns the next line the agent wrote, without its newline, and
+// how long after the previous one it came. It returns io.EOF at the end.
+func (s *replaySource) next() (line []byte, wait time.Duration, err error) {
+	for {
+		line, err = s.br.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, 0, err
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		var rec logRecord
+		if json.Unmarshal(line, &rec) != nil || rec.Msg == "" {
+			return line, 0, nil // a captured line, not a log record
+		}
+		if rec.Msg != "raw_event" || len(rec.Raw) == 0 {
+			continue // the wrapper's own records
+		}
+		if !s.lastRecv.IsZero() && s.speed > 0 && rec.RecvTS.After(s.lastRecv) {
+			wait = time.Duration(float64(rec.RecvTS.Sub(s.lastRecv)) / s.speed)
+		}
+		s.lastRecv = rec.RecvTS.Time
+		var nonJSON events.WrapperNonJSON
+		if json.Unmarshal(rec.Raw, &nonJSON) == nil && nonJSON.Type == events.TypeWrapperNonJSON {
+			return []byte(nonJSON.Text), wait, nil // as the agent wrote it
+		}
+		return rec.Raw, wait, nil
+	}
+}
+
+// replayAgent plays one turn of a recording in place of cursor-agent.
+// The turn ends at the recording's next result event, as the agent
+// exits after one. A recording that runs out before it, because the
+// agent hung there or was killed, leaves the output open, so the turn
+// sees the same silence the agent's did and the hang reproduces.
+//
+// There is no process: Kill only logs what it would have done and ends
+// the playback, as the agent's output would end.
+type replayAgent struct {
+	w    *io.PipeWriter
+	log  *logger.LogSession
+	stop chan struct{} // closed by Kill or Close
+	done chan struct{} // closed when playback ends
+
+	mu         sync.Mutex
+	killReason string
+}
+
+// startReplay starts playing src's next turn. Its output is the agent's
+// stdout; the agent has no stderr.
+func startReplay(src *replaySource, log *logger.LogSession) (a *replayAgent, stdout, stderr io.Reader) {
+	pr, pw := io.Pipe()
+	a = &replayAgent{w: pw, log: log, stop: make(chan struct{}), done: make(chan struct{})}
+	go a.play(src)
+	return a, pr, strings.NewReader("")
+}
+
+func (a *replayAgent) play(src *replaySource) {
+	defer close(a.done)
+	defer func() { _ = a.w.Close() }() // the turn sees EOF
+	for {
+		line, wait, err := src.next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				a.log.Warn("reading the replay recording failed", "error", err)
+			}
+			a.log.Info("replay recording ended mid-turn, leaving the agent's output open")
+			<-a.stop
+			return
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-a.stop:
+				timer.Stop()
+				return
+			}
+		}
+		if _, err := a.w.Write(append(line, '\n')); err != nil {
+			return // stopped while the turn wasn't reading
+		}
+		var ev events.RawEvent
+		if json.Unmarshal(line, &ev) == nil && ev.Type == "result" {
+			return
+		}
+	}
+}
+
+// Kill logs that nothing is killed and ends the playback.
+func (a *replayAgent) Kill(reason string) error {
+	a.mu.Lock()
+	first := a.killReason == ""
+	if first {
+		a.killReason = reason
+	}
+	a.mu.Unlock()
+	if first {
+		a.log.Info("replay: not killing anything, ending the playback", "reason", reason)
+		close(a.stop)
+		_ = a.w.Close() // unblocks a write the turn is no longer reading
+	}
+	return nil
+}
+
+// Close ends the playback, like Kill but without a reason.
+func (a *replayAgent) Close() error {
+	return a.Kill("output closed")
+}
+
+func (a *replayAgent) PID() int       { return 0 }
+func (a *replayAgent) Suspend() error { return nil }
+func (a *replayAgent) Resume() error  { return nil }
+
+// WaitContext waits for the playback to end, ending it with reason if
+// ctx ends first. With no process to report on, the state is a zero
+// one.
+func (a *replayAgent) WaitContext(ctx context.Context, reason string) (*os.ProcessState, error) {
+	select {
+	case <-a.done:
+		return new(os.ProcessState), nil
+	case <-ctx.Done():
+	}
+	_ = a.Kill(reason)
+	<-a.done
+	return new(os.ProcessState), fmt.Errorf("%w: %w", process.ErrForcedReap, context.Cause(ctx))
+}
+
+// ExitStatus reports a clean exit, and Kill's reason if it was called.
+func (a *replayAgent) ExitStatus() process.ExitStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return process.ExitStatus{KilledBy: a.killReason}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplaySource_SessionLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	log := `{"time":1000,"msg":"wrapper_start","argv":["cursor-wrap"]}
+{"time":1000,"msg":"raw_event","recv_ts":1000,"raw":{"type":"system","subtype":"init"}}
+{"time":1500,"msg":"raw_event","recv_ts":"1970-01-01T00:00:01.5Z","raw":{"type":"wrapper_nonjson","text":"T: Named models unavailable"}}
+{"time":1500,"msg":"verdict_waiting","kind":"verdict"}
+{"time":3500,"msg":"raw_event","recv_ts":3500,"raw":{"type":"result","subtype":"success"}}
+`
+	if err := os.WriteFile(path, []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := openReplaySource(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = src.Close() }()
+
+	want := []struct {
+		line string
+		wait time.Duration
+	}{
+		{`{"type":"system","subtype":"init"}`, 0},
+		{"T: Named models unavailable", 250 * time.Millisecond}, // as the agent wrote it, at twice the pace
+		{`{"type":"result","subtype":"success"}`, time.Second},
+	}
+	for i, w := range want {
+		line, wait, err := src.next()
+		if err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if string(line) != w.line || wait != w.wait {
+			t.Errorf("line %d = %s after %v, want %s after %v", i, line, wait, w.line, w.wait)
+		}
+	}
+	if _, _, err := src.next(); !errors.Is(err, io.EOF) {
+		t.Errorf("after the last line, err = %v, want EOF", err)
+	}
+}
+
+func TestReplaySource_Capture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	capture := "T: Named models unavailable\n{\"type\":\"system\",\"subtype\":\"init\"}\n{\"type\":\"result\"}" // no final newline
+	if err := os.WriteFile(path, []byte(capture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := openReplaySource(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = src.Close() }()
+
+	for i, want := range []string{"T: Named models unavailable", `{"type":"system","subtype":"init"}`, `{"type":"result"}`} {
+		line, wait, err := src.next()
+		if err != nil || string(line) != want || wait != 0 {
+			t.Errorf("line %d = %q after %v, %v; want %q at once", i, line, wait, err, want)
+		}
+	}
+}
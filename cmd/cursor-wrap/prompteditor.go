@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"cursor-wrap/internal/lineedit"
+	"cursor-wrap/internal/logger"
+)
+
+// historySize is how many prompts the history file keeps.
+const historySize = 1000
+
+// promptEditor reads interactive prompts typed at a terminal through a
+// line editor, keeping them in the history file.
+type promptEditor struct {
+	editor      *lineedit.Editor
+	historyFile string // empty once writing it has failed
+	log         *logger.LogSession
+}
+
+// newPromptEditor returns the editor for cfg's interactive prompts, or
+// nil when stdin isn't a terminal: piped prompts are read as plain lines
+// by readPrompt.
+func newPromptEditor(cfg Config, log *logger.LogSession) *promptEditor {
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(os.Stdin) || !lineedit.IsTerminal(fd) {
+		return nil
+	}
+	p := &promptEditor{
+		editor:      lineedit.New(cfg.PromptReader, os.Stderr, fd),
+		historyFile: cfg.HistoryFile,
+		log:         log,
+	}
+	if p.historyFile != "" {
+		lines, err := lineedit.LoadHistory(p.historyFile, historySize)
+		if err != nil {
+			log.Warn("reading prompt history failed", "file", p.historyFile, "error", err)
+		}
+		p.editor.SetHistory(lines)
+	}
+	return p
+}
+
+// next reads the next non-empty prompt, as readPrompt does, and adds it
+// to the history. A history file that can't be written is warned about
+// once, and the session's prompts are still recalled.
+func (p *promptEditor) next() (string, error) {
+	for {
+		line, err := p.editor.ReadLine("> ")
+		if err != nil {
+			return "", err
+		}
+		prompt := strings.TrimSpace(line)
+		if prompt == "" {
+			continue
+		}
+		p.editor.AddHistory(prompt)
+		if p.historyFile != "" {
+			if err := lineedit.AppendHistory(p.historyFile, prompt); err != nil {
+				p.log.Warn("saving prompt history failed", "file", p.historyFile, "error", err)
+				p.historyFile = ""
+			}
+		}
+		return prompt, nil
+	}
+}
+
+// nextPrompt reads the next interactive prompt: through the line editor
+// when stdin is a terminal, else with readPrompt.
+func nextPrompt(cfg Config) (string, error) {
+	if cfg.editor != nil {
+		return cfg.editor.next()
+	}
+	return readPrompt(cfg.PromptReader)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"cursor-wrap/internal/lineedit"
+)
+
+func TestPromptEditor_KeepsHistory(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+	path := filepath.Join(t.TempDir(), "history")
+	keys := bufio.NewReader(strings.NewReader("  \r first prompt \r\x1b[A again\r"))
+	p := &promptEditor{editor: lineedit.New(keys, io.Discard, -1), historyFile: path, log: log}
+
+	for _, want := range []string{"first prompt", "first prompt again"} {
+		if got, err := p.next(); err != nil || got != want {
+			t.Fatalf("next = %q, %v; want %q", got, err, want)
+		}
+	}
+	if _, err := p.next(); err != io.EOF {
+		t.Errorf("at the end of input: err = %v, want io.EOF", err)
+	}
+	lines, err := lineedit.LoadHistory(path, historySize)
+	if err != nil || !slices.Equal(lines, []string{"first prompt", "first prompt again"}) {
+		t.Errorf("history file = %q, %v", lines, err)
+	}
+}
+
+func TestPromptEditor_UnwritableHistory(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys := bufio.NewReader(strings.NewReader("one\rtwo\r\x1b[A\r"))
+	p := &promptEditor{editor: lineedit.New(keys, io.Discard, -1), historyFile: filepath.Join(blocker, "history"), log: log}
+
+	for _, want := range []string{"one", "two", "two"} {
+		if got, err := p.next(); err != nil || got != want {
+			t.Fatalf("next = %q, %v; want %q", got, err, want)
+		}
+	}
+	data, err := os.ReadFile(log.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), `"msg":"saving prompt history failed"`); n != 1 {
+		t.Errorf("%d warnings about the history file, want 1", n)
+	}
+}
+
+func TestNewPromptEditor_NotATerminal(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+	if p := newPromptEditor(Config{PromptReader: bufio.NewReader(os.Stdin)}, log); p != nil {
+		t.Error("editor for a stdin that isn't a terminal")
+	}
+}
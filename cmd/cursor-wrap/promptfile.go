@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"cursor-wrap/internal/process"
+)
+
+// Prompt delivery mechanisms, as logged with each turn.
+const (
+	promptViaStdin = "stdin"
+	promptViaFile  = "file"
+)
+
+// promptFiles are the prompt files written during a session. They're
+// kept until the session ends rather than the turn, so a --resume turn
+// whose history refers back to an earlier @file can still read it.
+type promptFiles struct {
+	paths []string
+}
+
+// removeAll removes every prompt file written so far.
+func (p *promptFiles) removeAll() error {
+	var errs []error
+	for _, path := range p.paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("removing prompt file: %w", err))
+		}
+	}
+	p.paths = nil
+	return errors.Join(errs...)
+}
+
+// promptFileDir is where prompt files are written: .cursor-wrap/prompts in
+// the agent's workspace, so the agent reads the file as part of the
+// workspace rather than reaching outside it for one in $TMPDIR. Like
+// cursor-agent, it takes a relative --workspace, or none, from the
+// agent's working directory.
+func promptFileDir(procCfg *process.Config) (string, error) {
+	root := procCfg.Workspace
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(procCfg.Cwd, root)
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(abs, ".cursor-wrap", "prompts"), nil
+}
+
+// preparePrompt moves a prompt larger than threshold bytes into a private
+// file in the workspace (see promptFileDir) and replaces procCfg.Prompt
+// with an @file reference to it; cursor-agent reads referenced files
+// itself, which is much faster than pushing hundreds of KB through its
+// stdin. A threshold of 0, or a --remote agent that can't see local
+// files, keeps the prompt on stdin.
+//
+// It returns the mechanism used. The file is added to files, whose owner
+// removes it when the session ends.
+func preparePrompt(procCfg *process.Config, threshold int64, files *promptFiles) (mechanism string, err error) {
+	if threshold <= 0 || int64(len(procCfg.Prompt)) <= threshold || procCfg.Remote != "" {
+		return promptViaStdin, nil
+	}
+
+	dir, err := promptFileDir(procCfg)
+	if err != nil {
+		return "", fmt.Errorf("prompt file: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("prompt file: %w", err)
+	}
+	// CreateTemp makes the file 0600, so other users can't read the prompt.
+	f, err := os.CreateTemp(dir, "prompt-*.md")
+	if err != nil {
+		return "", fmt.Errorf("prompt file: %w", err)
+	}
+	path := f.Name()
+	_, err = f.WriteString(procCfg.Prompt)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path) // best effort; the write error is what matters
+		return "", fmt.Errorf("prompt file: %w", err)
+	}
+
+	files.paths = append(files.paths, path)
+	procCfg.Prompt = promptFileReference(path)
+	return promptViaFile, nil
+}
+
+// promptFileReference is the stdin prompt that points the agent at a
+// prompt file.
+func promptFileReference(path string) string {
+	return fmt.Sprintf("The full prompt is in @%s. Read that file and follow it exactly.", path)
+}
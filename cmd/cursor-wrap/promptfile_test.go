@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cursor-wrap/internal/process"
+)
+
+func TestPreparePrompt_Threshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		prompt    string
+		threshold int64
+		remote    string
+		want      string
+	}{
+		{"below threshold", "short", 10, "", promptViaStdin},
+		{"at threshold", "0123456789", 10, "", promptViaStdin},
+		{"above threshold", "0123456789a", 10, "", promptViaFile},
+		{"disabled", strings.Repeat("x", 1<<20), 0, "", promptViaStdin},
+		{"remote agent", "0123456789a", 10, "gpu-box", promptViaStdin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procCfg := process.Config{Prompt: tt.prompt, Remote: tt.remote, Workspace: t.TempDir()}
+			var files promptFiles
+
+			got, err := preparePrompt(&procCfg, tt.threshold, &files)
+			if err != nil {
+				t.Fatalf("preparePrompt: %v", err)
+			}
+			defer files.removeAll()
+			if got != tt.want {
+				t.Errorf("mechanism = %q, want %q", got, tt.want)
+			}
+			if got == promptViaStdin && procCfg.Prompt != tt.prompt {
+				t.Errorf("stdin prompt changed to %q", procCfg.Prompt)
+			}
+		})
+	}
+}
+
+func TestPromptFileDir(t *testing.T) {
+	ws := t.TempDir()
+	cwd, _ := os.Getwd()
+	tests := []struct {
+		name      string
+		workspace string
+		cwd       string
+		want      string
+	}{
+		{"workspace", ws, "/elsewhere", ws},
+		{"relative workspace", "sub", ws, filepath.Join(ws, "sub")},
+		{"agent working directory", "", ws, ws},
+		{"neither", "", "", cwd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := promptFileDir(&process.Config{Workspace: tt.workspace, Cwd: tt.cwd})
+			if err != nil {
+				t.Fatalf("promptFileDir: %v", err)
+			}
+			if want := filepath.Join(tt.want, ".cursor-wrap", "prompts"); got != want {
+				t.Errorf("promptFileDir = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPreparePrompt_FileContentsAndCleanup(t *testing.T) {
+	ws := t.TempDir()
+	dir := filepath.Join(ws, ".cursor-wrap", "prompts")
+	var files promptFiles
+
+	var paths []string
+	for _, prompt := range []string{strings.Repeat("dump ", 100), strings.Repeat("more ", 100)} {
+		procCfg := process.Config{Prompt: prompt, Workspace: ws}
+		if _, err := preparePrompt(&procCfg, 10, &files); err != nil {
+			t.Fatalf("preparePrompt: %v", err)
+		}
+		path := files.paths[len(files.paths)-1]
+		paths = append(paths, path)
+		if filepath.Dir(path) != dir {
+			t.Errorf("prompt file %s not in %s", path, dir)
+		}
+		if want := promptFileReference(path); procCfg.Prompt != want {
+			t.Errorf("Prompt = %q, want %q", procCfg.Prompt, want)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != prompt {
+			t.Error("prompt file does not hold the original prompt")
+		}
+		if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+			t.Errorf("prompt file mode = %v, want 0600", fi.Mode().Perm())
+		}
+	}
+
+	// An earlier turn's file outlives the next turn, for --resume.
+	if _, err := os.Stat(paths[0]); err != nil {
+		t.Errorf("first prompt file gone before the session ended: %v", err)
+	}
+	if err := files.removeAll(); err != nil {
+		t.Fatalf("removeAll: %v", err)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s removed, stat err = %v", path, err)
+		}
+	}
+	// A second removeAll is harmless.
+	if err := files.removeAll(); err != nil {
+		t.Errorf("second removeAll: %v", err)
+	}
+}
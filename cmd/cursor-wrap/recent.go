@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/logger"
+)
+
+const (
+	// recentEventBytes caps the payload kept of each recent event, so a
+	// huge tool result costs no more than a keepalive.
+	recentEventBytes = 256
+	// recentEventsMaxBytes caps the payloads kept in all, whatever
+	// --hang-dump-events asks for.
+	recentEventsMaxBytes = 16 << 10
+)
+
+// recentEvent is what a hang dump shows of an event.
+type recentEvent struct {
+	RecvTS    int64  `json:"recv_ts"`
+	Type      string `json:"type"`
+	Subtype   string `json:"subtype,omitempty"`
+	Payload   string `json:"payload"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// recentEvents retains the last few events of a turn for the hang dump,
+// bounded both in count and in payload bytes. It is only used from the
+// event loop.
+type recentEvents struct {
+	events   []recentEvent
+	max      int
+	maxBytes int
+	bytes    int
+}
+
+func newRecentEvents(max, maxBytes int) *recentEvents {
+	return &recentEvents{max: max, maxBytes: maxBytes}
+}
+
+// Add records ev, evicting the oldest events to stay within bounds.
+func (r *recentEvents) Add(ev events.AnnotatedEvent) {
+	if r.max <= 0 {
+		return
+	}
+	e := recentEvent{
+		RecvTS:  ev.RecvTime.UnixMilli(),
+		Type:    ev.Parsed.Type,
+		Subtype: ev.Parsed.Subtype,
+	}
+	// Copied, not sliced, so a retained event doesn't pin a huge line.
+	if len(ev.Raw) > recentEventBytes {
+		e.Payload, e.Truncated = string(ev.Raw[:recentEventBytes]), true
+	} else {
+		e.Payload = string(ev.Raw)
+	}
+	for len(r.events) > 0 && (len(r.events) == r.max || r.bytes+len(e.Payload) > r.maxBytes) {
+		r.bytes -= len(r.events[0].Payload)
+		r.events = r.events[1:]
+	}
+	r.events = append(r.events, e)
+	r.bytes += len(e.Payload)
+}
+
+// Events returns the retained events, oldest first.
+func (r *recentEvents) Events() []recentEvent {
+	return append([]recentEvent(nil), r.events...)
+}
+
+// dumpRecentEvents shows the events leading up to a hang on w (stderr),
+// for a CI job's output, and logs them as one record for the session
+// log. Payloads are masked like the prompt in turn_start.
+func dumpRecentEvents(w io.Writer, log *logger.LogSession, recent []recentEvent) {
+	if len(recent) == 0 {
+		return
+	}
+	for i := range recent {
+		recent[i].Payload = log.RedactText(recent[i].Payload)
+	}
+	fmt.Fprintf(w, "cursor-wrap: last %d events before the hang:\n", len(recent))
+	for _, e := range recent {
+		name := e.Type
+		if e.Subtype != "" {
+			name += "/" + e.Subtype
+		}
+		payload := e.Payload
+		if e.Truncated {
+			payload += "…"
+		}
+		fmt.Fprintf(w, "  %s %-24s %s\n", time.UnixMilli(e.RecvTS).Format("15:04:05.000"), name, payload)
+	}
+	log.Debug("recent_events", logger.Kind(logger.KindVerdict), slog.Any("events", recent))
+}
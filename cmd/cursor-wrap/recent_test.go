@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/events"
+)
+
+func recentTestEvent(typ string, size int) events.AnnotatedEvent {
+	return events.AnnotatedEvent{
+		RecvTime: time.UnixMilli(1760000000000),
+		Raw:      []byte(`{"type":"` + typ + `","x":"` + strings.Repeat("x", size) + `"}`),
+		Parsed:   events.RawEvent{Type: typ},
+	}
+}
+
+func TestRecentEvents_BoundedByCount(t *testing.T) {
+	r := newRecentEvents(3, recentEventsMaxBytes)
+	for _, typ := range []string{"a", "b", "c", "d", "e"} {
+		r.Add(recentTestEvent(typ, 1))
+	}
+	got := r.Events()
+	if len(got) != 3 || got[0].Type != "c" || got[2].Type != "e" {
+		t.Errorf("Events = %+v, want c, d, e", got)
+	}
+}
+
+func TestRecentEvents_BoundedByBytes(t *testing.T) {
+	r := newRecentEvents(1000, 4*recentEventBytes)
+	for range 100 {
+		r.Add(recentTestEvent("tool_call", 1<<20))
+	}
+	got := r.Events()
+	if len(got) != 4 {
+		t.Fatalf("kept %d events, want 4", len(got))
+	}
+	for _, e := range got {
+		if len(e.Payload) != recentEventBytes || !e.Truncated {
+			t.Errorf("payload of %d bytes (truncated %v), want %d truncated", len(e.Payload), e.Truncated, recentEventBytes)
+		}
+	}
+}
+
+func TestRecentEvents_Disabled(t *testing.T) {
+	r := newRecentEvents(0, recentEventsMaxBytes)
+	r.Add(recentTestEvent("a", 1))
+	if got := r.Events(); len(got) != 0 {
+		t.Errorf("Events = %+v, want none", got)
+	}
+}
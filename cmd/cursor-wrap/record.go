@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// recorder is the --record capture: a verbatim copy of everything the
+// agent writes to stdout, every turn in order, non-JSON lines included,
+// for use as a test fixture. It is written as the stream is read, with
+// no buffering in between, so a turn killed as hung still leaves a usable
+// capture of what came before.
+//
+// Like stderrFile, Write never fails: a broken capture must not end the
+// turn it is recording. The first error is kept for Close to report.
+type recorder struct {
+	f   *os.File
+	err error
+}
+
+// openRecorder creates the capture at path, replacing any earlier one.
+func openRecorder(path string) (*recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("--record: %w", err)
+	}
+	return &recorder{f: f}, nil
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return len(p), nil
+	}
+	if _, err := r.f.Write(p); err != nil {
+		r.err = fmt.Errorf("--record: %w", err)
+	}
+	return len(p), nil
+}
+
+// Close closes the capture and returns the first error seen writing it.
+func (r *recorder) Close() error {
+	if err := r.f.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("--record: %w", err)
+	}
+	return r.err
+}
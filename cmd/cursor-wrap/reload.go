@@ -0,0 +1,107 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"cursor-wrap/internal/logger"
+)
+
+// reloadable are the settings a reload applies to the running wrapper,
+// by flag name. A change to any other is logged and ignored: it chose
+// how the wrapper started, or what a running agent was started with.
+var reloadable = map[string]bool{
+	"idle-timeout":  true,
+	"tool-grace":    true,
+	"tick-interval": true,
+	"log-level":     true,
+}
+
+// reloader reads the configuration again on SIGHUP, so a session whose
+// timeouts turn out to be wrong can be fixed without killing it. runTurn's
+// event loop answers the signal, so the monitor and ticker are changed from
+// the goroutine that owns them; a SIGHUP between turns is answered as the
+// next turn starts.
+type reloader struct {
+	signals chan os.Signal
+	args    []string          // the wrapper's arguments, parsed again on each reload
+	flags   map[string]string // every flag's value, as of the last reload
+	latest  Config            // holds the reloadable settings in force
+}
+
+// newReloader returns a reloader for the wrapper started with args, as
+// parsed into cfg, and starts catching SIGHUP.
+func newReloader(cfg Config, args []string) *reloader {
+	r := &reloader{
+		signals: make(chan os.Signal, 1),
+		args:    args,
+		flags:   maps.Clone(cfg.flags),
+		latest:  cfg,
+	}
+	signal.Notify(r.signals, syscall.SIGHUP)
+	return r
+}
+
+// requests returns the channel SIGHUP arrives on; nil for a nil r, which
+// never delivers.
+func (r *reloader) requests() <-chan os.Signal {
+	if r == nil {
+		return nil
+	}
+	return r.signals
+}
+
+// apply sets c's reloadable settings to those in force.
+func (r *reloader) apply(c *Config) {
+	if r == nil {
+		return
+	}
+	c.IdleTimeout = r.latest.IdleTimeout
+	c.ToolGrace = r.latest.ToolGrace
+	c.TickInterval = r.latest.TickInterval
+	c.Log.ConsoleLevel = r.latest.Log.ConsoleLevel
+}
+
+// load parses the wrapper's arguments again, reading the config files and
+// CURSOR_WRAP_* variables afresh; the command line still wins over them.
+// It logs each setting that changed, with its old and new values, and
+// reports whether any of them is reloadable. A configuration that no
+// longer parses or validates is logged and leaves the settings as they
+// were.
+func (r *reloader) load(log *logger.LogSession) bool {
+	next, err := loadFlags(r.args)
+	var warnings []string
+	if err == nil {
+		warnings, err = next.Validate()
+	}
+	if err != nil {
+		log.Warn("config reload failed, keeping the current settings", "error", err)
+		return false
+	}
+	for _, w := range warnings {
+		log.Warn("config reload warning", "warning", w)
+	}
+	changed := false
+	for _, name := range slices.Sorted(maps.Keys(next.flags)) {
+		old, val := r.flags[name], next.flags[name]
+		if old == val {
+			continue
+		}
+		if !reloadable[name] {
+			log.Warn("config reload ignored a setting that can't change while running", "setting", name, "old", old, "new", val)
+			continue
+		}
+		log.Info("config reloaded", "setting", name, "old", old, "new", val)
+		r.flags[name] = val
+		changed = true
+	}
+	if !changed {
+		log.Info("config reload changed nothing that applies while running", "files", next.ConfigFiles)
+		return false
+	}
+	r.latest = next
+	return true
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloader_Load(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursor-wrap.toml")
+	writeConfigFile(t, path, `idle-timeout = "30s"
+tick-interval = "5s"
+model = "first-model"
+`)
+	args := []string{"--config", path, "--tool-grace", "7s", "-p", "a prompt"}
+	cfg, err := loadFlags(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newReloader(cfg, args)
+	t.Cleanup(func() { signal.Stop(r.signals) })
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+
+	if r.load(log) {
+		t.Error("load reported a change with the file as it was")
+	}
+
+	writeConfigFile(t, path, `idle-timeout = "2m"
+tick-interval = "5s"
+tool-grace = "1s"
+log-level = "debug"
+model = "second-model"
+`)
+	if !r.load(log) {
+		t.Fatal("load missed the new idle timeout")
+	}
+	turn := cfg
+	turn.IdleTimeout = 0
+	r.apply(&turn)
+	if turn.IdleTimeout != 2*time.Minute || turn.TickInterval != 5*time.Second || turn.Log.ConsoleLevel != slog.LevelDebug {
+		t.Errorf("idle %v, tick %v, console level %v; want the file's new settings", turn.IdleTimeout, turn.TickInterval, turn.Log.ConsoleLevel)
+	}
+	if turn.ToolGrace != 7*time.Second || turn.Process.Model != "first-model" {
+		t.Errorf("grace %v, model %q; want the command line's grace and the model the agent started with", turn.ToolGrace, turn.Process.Model)
+	}
+
+	// A file that no longer validates changes nothing.
+	writeConfigFile(t, path, `idle-timeout = "-1s"`)
+	if r.load(log) {
+		t.Error("load applied an invalid idle timeout")
+	}
+	r.apply(&turn)
+	if turn.IdleTimeout != 2*time.Minute {
+		t.Errorf("idle %v after a failed reload", turn.IdleTimeout)
+	}
+
+	data, err := os.ReadFile(log.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct{ msg, attrs string }{
+		{"config reloaded", `"setting":"idle-timeout","old":"30s","new":"2m0s"`},
+		{"config reloaded", `"setting":"log-level","old":"","new":"debug"`},
+		{"config reload ignored a setting that can't change while running", `"setting":"model","old":"first-model","new":"second-model"`},
+		{"config reload failed, keeping the current settings", `"error":"--idle-timeout must be positive, got -1s"`},
+	} {
+		found := false
+		for _, line := range strings.Split(string(data), "\n") {
+			found = found || (strings.Contains(line, `"msg":"`+want.msg+`"`) && strings.Contains(line, want.attrs))
+		}
+		if !found {
+			t.Errorf("log lacks %q with %s:\n%s", want.msg, want.attrs, data)
+		}
+	}
+	if strings.Contains(string(data), `"setting":"tool-grace"`) {
+		t.Errorf("the file's tool-grace beat the command line's:\n%s", data)
+	}
+}
+
+func TestReloader_NilDoesNothing(t *testing.T) {
+	var r *reloader
+	if r.requests() != nil {
+		t.Error("a nil reloader has a channel")
+	}
+	cfg := Config{IdleTimeout: time.Minute}
+	r.apply(&cfg)
+	if cfg.IdleTimeout != time.Minute {
+		t.Errorf("idle %v", cfg.IdleTimeout)
+	}
+}
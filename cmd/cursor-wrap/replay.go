@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/format"
+	"cursor-wrap/internal/monitor"
+)
+
+// replayConfig holds the options of the replay subcommand.
+type replayConfig struct {
+	Files        []string // session log parts, oldest first
+	OutputFormat string
+	// Speed > 0 sleeps between events, scaled from the gaps between their
+	// recv_ts (2 replays twice as fast); 0 replays as fast as possible.
+	Speed float64
+}
+
+// parseReplayFlags parses the arguments after "replay". Flags may come
+// before or after the log files.
+func parseReplayFlags(args []string, stderr io.Writer) (replayConfig, error) {
+	fs := flag.NewFlagSet("cursor-wrap replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: cursor-wrap replay [flags] <logfile>...")
+		fs.PrintDefaults()
+	}
+	outputFormat := fs.String("output-format", "text", "Output format: stream-json | text")
+	realtime := fs.Bool("realtime", false, "Pause between events as long as the agent originally did")
+	speed := fs.Float64("speed", 0, "Replay at this multiple of the original pace, e.g. 4 (implies --realtime)")
+
+	var cfg replayConfig
+	for {
+		if err := fs.Parse(args); err != nil {
+			return cfg, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		cfg.Files = append(cfg.Files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	cfg.OutputFormat = *outputFormat
+	cfg.Speed = *speed
+	if *realtime && cfg.Speed == 0 {
+		cfg.Speed = 1
+	}
+
+	var errs []error
+	if len(cfg.Files) == 0 {
+		errs = append(errs, errors.New("no log file given"))
+	}
+	switch cfg.OutputFormat {
+	case "stream-json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("--output-format %q: want stream-json or text", cfg.OutputFormat))
+	}
+	if cfg.Speed < 0 {
+		errs = append(errs, fmt.Errorf("--speed must not be negative, got %g", cfg.Speed))
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// runReplay implements "cursor-wrap replay": it renders the raw events of
+// a session log through a formatter, as the user saw them, with the
+// wrapper's hang verdicts inline. It returns the process exit code.
+func runReplay(args []string, stdout, stderr io.Writer) int {
+	cfg, err := parseReplayFlags(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "cursor-wrap replay: %v\n", err)
+		return 1
+	}
+	if err := replay(cfg, stdout, time.Sleep); err != nil {
+		fmt.Fprintf(stderr, "cursor-wrap replay: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// logRecord is the part of a session log record replay cares about.
+type logRecord struct {
+	Time   logTime         `json:"time"`
+	Msg    string          `json:"msg"`
+	Turn   int             `json:"turn"`
+	RecvTS logTime         `json:"recv_ts"`
+	Raw    json.RawMessage `json:"raw"`
+}
+
+// logTime is a time field of a session log record, in either
+// --log-time-format: Unix milliseconds or an RFC 3339 string.
+type logTime struct{ time.Time }
+
+func (t *logTime) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		t.Time = parsed
+		return nil
+	}
+	var ms int64
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return err
+	}
+	t.Time = time.UnixMilli(ms)
+	return nil
+}
+
+// replay feeds the log's events to a formatter. The session loop flushes
+// the formatter at the end of each turn and, in interactive mode, then
+// shows the hang; replay does the same whenever the turn number changes.
+func replay(cfg replayConfig, w io.Writer, sleep func(time.Duration)) error {
+	fmtr := format.New(cfg.OutputFormat, w)
+	turn := 0
+	var hang *monitor.Reason
+	var hangAt time.Time
+	var lastRecv time.Time
+	found := false // any event or hang
+
+	endTurn := func() error {
+		if err := fmtr.Flush(); err != nil {
+			return err
+		}
+		if hang != nil {
+			if err := fmtr.WriteHangIndicator(*hang, hangAt); err != nil {
+				return err
+			}
+			hang = nil
+		}
+		return nil
+	}
+
+	for _, path := range cfg.Files {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = eachLogLine(f, func(line []byte) error {
+			var rec logRecord
+			if json.Unmarshal(line, &rec) != nil {
+				return nil // a record cut short by a crash
+			}
+			if rec.Turn != 0 && rec.Turn != turn {
+				if turn != 0 {
+					if err := endTurn(); err != nil {
+						return err
+					}
+				}
+				turn = rec.Turn
+			}
+			switch rec.Msg {
+			case "raw_event":
+				ev, ok := replayEvent(rec)
+				if !ok {
+					return nil
+				}
+				if cfg.Speed > 0 && !lastRecv.IsZero() && ev.RecvTime.After(lastRecv) {
+					sleep(time.Duration(float64(ev.RecvTime.Sub(lastRecv)) / cfg.Speed))
+				}
+				lastRecv, found = ev.RecvTime, true
+				return fmtr.WriteEvent(ev)
+			case "hang detected":
+				var attrs map[string]json.RawMessage
+				if json.Unmarshal(line, &attrs) == nil {
+					r := reasonFromRecord(attrs)
+					hang, hangAt, found = &r, rec.Time.Time, true
+				}
+			}
+			return nil
+		})
+		_ = f.Close() // read-only
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if !found {
+		return errors.New("no events found in the log")
+	}
+	return endTurn()
+}
+
+// eachLogLine calls fn for each line of r. Raw events can be far longer
+// than a bufio.Scanner's default limit, so lines are not capped.
+func eachLogLine(r io.Reader, fn func([]byte) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if ferr := fn(line); ferr != nil {
+				return ferr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// replayEvent rebuilds the AnnotatedEvent a raw_event record was
+// written from.
+func replayEvent(rec logRecord) (events.AnnotatedEvent, bool) {
+	var parsed events.RawEvent
+	if json.Unmarshal(rec.Raw, &parsed) != nil {
+		return events.AnnotatedEvent{}, false
+	}
+	parsed.Line = rec.Raw
+	return events.AnnotatedEvent{
+		RecvTime: rec.RecvTS.Time,
+		Raw:      rec.Raw,
+		Parsed:   parsed,
+	}, true
+}
+
+// reasonFromRecord is the inverse of reasonAttrs.
+func reasonFromRecord(attrs map[string]json.RawMessage) monitor.Reason {
+	num := func(key string) int64 {
+		n, _ := strconv.ParseInt(string(attrs[key]), 10, 64) // absent is 0
+		return n
+	}
+	str := func(key string) string {
+		var s string
+		_ = json.Unmarshal(attrs[key], &s) // absent is ""
+		return s
+	}
+	r := monitor.Reason{
+		IdleSilenceMS:      num("idle_silence_ms"),
+		OpenCallCount:      int(num("open_call_count")),
+		LastEventType:      str("last_event_type"),
+		ThinkingMS:         num("thinking_ms"),
+		TotalOpenElapsedMS: num("total_open_elapsed_ms"),
+	}
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("open_call_%d", i)
+		if _, ok := attrs[prefix+"_id"]; !ok {
+			break
+		}
+		r.OpenCalls = append(r.OpenCalls, monitor.OpenCallDetail{
+			CallID:              str(prefix + "_id"),
+			Command:             str(prefix + "_command"),
+			ElapsedMS:           num(prefix + "_elapsed_ms"),
+			TimeoutMS:           num(prefix + "_timeout_ms"),
+			EstimatedDeadlineMS: num(prefix + "_estimated_deadline_ms"),
+		})
+	}
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("stderr_tail_%d", i)
+		if _, ok := attrs[key]; !ok {
+			break
+		}
+		r.StderrTail = append(r.StderrTail, str(key))
+	}
+	return r
+}
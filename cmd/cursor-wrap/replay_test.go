@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/monitor"
+)
+
+func TestReasonFromRecord_InvertsReasonAttrs(t *testing.T) {
+	want := monitor.Reason{
+		IdleSilenceMS:      61000,
+		OpenCallCount:      1,
+		LastEventType:      "tool_call",
+		TotalOpenElapsedMS: 95000,
+		OpenCalls: []monitor.OpenCallDetail{
+			{CallID: "c1", Command: "npm test", ElapsedMS: 95000, TimeoutMS: 60000, EstimatedDeadlineMS: 80000},
+		},
+		StderrTail: []string{"warn: slow", "still waiting"},
+	}
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("hang detected", reasonAttrs(want)...)
+
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &attrs); err != nil {
+		t.Fatal(err)
+	}
+	if got := reasonFromRecord(attrs); !reflect.DeepEqual(got, want) {
+		t.Errorf("reasonFromRecord = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseReplayFlags(t *testing.T) {
+	cfg, err := parseReplayFlags([]string{"a.jsonl", "--output-format", "stream-json", "b.jsonl", "--speed", "4"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Files, []string{"a.jsonl", "b.jsonl"}) || cfg.OutputFormat != "stream-json" || cfg.Speed != 4 {
+		t.Errorf("cfg = %+v", cfg)
+	}
+
+	if cfg, _ := parseReplayFlags([]string{"--realtime", "a.jsonl"}, &bytes.Buffer{}); cfg.Speed != 1 {
+		t.Errorf("--realtime: Speed = %g, want 1", cfg.Speed)
+	}
+	if _, err := parseReplayFlags([]string{"--output-format", "yaml"}, &bytes.Buffer{}); err == nil ||
+		!strings.Contains(err.Error(), "no log file") || !strings.Contains(err.Error(), "yaml") {
+		t.Errorf("err = %v, want both problems", err)
+	}
+}
+
+func TestReplay_TurnsHangsAndTiming(t *testing.T) {
+	const log = `{"time":1000,"level":"INFO","msg":"agent started","turn":1}
+{"time":1000,"level":"DEBUG","msg":"raw_event","turn":1,"recv_ts":1000,"raw":{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"first"}]}}}
+{"time":1500,"level":"DEBUG","msg":"raw_event","turn":1,"recv_ts":1500,"raw":{"type":"tool_call","subtype":"started","call_id":"c1","tool_call":{"shellToolCall":{"args":{"command":"make"}}}}}
+{"time":9000,"level":"ERROR","msg":"hang detected","turn":1,"idle_silence_ms":0,"open_call_count":1,"last_event_type":"tool_call","open_call_0_id":"c1","open_call_0_command":"make","open_call_0_elapsed_ms":7500,"open_call_0_timeout_ms":5000}
+{"time":9100,"level":"DEBUG","msg":"raw_eve
+{"time":10000,"level":"DEBUG","msg":"raw_event","turn":2,"recv_ts":11500,"raw":{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"second"}]}}}
+`
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	var slept []time.Duration
+	err := replay(replayConfig{Files: []string{path}, OutputFormat: "text", Speed: 2}, &out, func(d time.Duration) { slept = append(slept, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	wantOrder := []string{"first\n", "⏳ `make`\n", "\n⚠ Hang detected", "[c1 make elapsed=7500ms timeout=5000ms]", "second\n"}
+	pos := 0
+	for _, want := range wantOrder {
+		i := strings.Index(got[pos:], want)
+		if i < 0 {
+			t.Fatalf("output missing %q after offset %d:\n%s", want, pos, got)
+		}
+		pos += i + len(want)
+	}
+	if !strings.HasSuffix(got, "second\n\n") {
+		t.Errorf("last turn not flushed:\n%q", got)
+	}
+	if want := []time.Duration{250 * time.Millisecond, 5 * time.Second}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+}
+
+func TestLogTime_BothFormats(t *testing.T) {
+	var rec logRecord
+	line := `{"time":"2026-02-10T12:30:45.400Z","recv_ts":1770726645400}`
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Time.Equal(rec.RecvTS.Time) || rec.Time.UnixMilli() != 1770726645400 {
+		t.Errorf("time = %v, recv_ts = %v; want both 2026-02-10T12:30:45.400Z", rec.Time, rec.RecvTS)
+	}
+}
+
+func TestReplay_NoEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.jsonl")
+	if err := os.WriteFile(path, []byte(`{"time":1,"level":"INFO","msg":"agent started"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := replay(replayConfig{Files: []string{path}, OutputFormat: "text"}, &bytes.Buffer{}, nil); err == nil {
+		t.Error("expected an error for a log without events")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// runReport is the single JSON object --report-fd and --report-file get
+// at exit, for a program driving the wrapper: the session summary, and
+// what its exit code stands for.
+type runReport struct {
+	Outcome string `json:"outcome"` // see exitOutcome
+	*sessionSummary
+}
+
+// openReportFD returns --report-fd's descriptor as a file, or nil without
+// one. The descriptor is made close-on-exec: cursor-agent, and anything
+// it leaves running, mustn't hold it open, or the reader waiting for the
+// report's EOF would wait for them too.
+func openReportFD(fd int) (*os.File, error) {
+	if fd < 0 {
+		return nil, nil
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("report-fd-%d", fd))
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("--report-fd %d: not an open file descriptor", fd)
+	}
+	syscall.CloseOnExec(fd)
+	return f, nil
+}
+
+// writeReport writes s, finished with runErr, as one line of JSON to
+// --report-fd and --report-file. It runs on every path out of the
+// wrapper once flags are parsed, so the reader always gets a report.
+func writeReport(cfg Config, s *sessionSummary, runErr error) error {
+	if cfg.report == nil && cfg.ReportFile == "" {
+		return nil
+	}
+	s.finish(s.LogFile, runErr)
+	data, err := json.Marshal(runReport{Outcome: exitOutcome(runErr), sessionSummary: s})
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	data = append(data, '\n')
+	var errs []error
+	if f := cfg.report; f != nil {
+		_, err := f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("--report-fd: %w", err))
+		}
+	}
+	if cfg.ReportFile != "" {
+		if err := os.WriteFile(cfg.ReportFile, data, 0o644); err != nil {
+			errs = append(errs, fmt.Errorf("--report-file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"cursor-wrap/internal/logger"
+)
+
+// resumeLast points c at the session --resume-last picks, from the logs
+// in the log directory, as --resume would, and says which on w.
+func (c *Config) resumeLast(w io.Writer) error {
+	ids, err := logger.RecentSessions(c.Log.Dir, c.Log.Tag)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("--resume-last: %w", err)
+	}
+	tagged := ""
+	if c.Log.Tag != "" {
+		tagged = fmt.Sprintf(" tagged %q", c.Log.Tag)
+	}
+	switch {
+	case len(ids) == 0:
+		return fmt.Errorf("--resume-last: no earlier session%s has a log in %s", tagged, c.Log.Dir)
+	case len(ids) < c.ResumeLast:
+		return fmt.Errorf("--resume-last=%d: only %d earlier sessions%s have logs in %s", c.ResumeLast, len(ids), tagged, c.Log.Dir)
+	}
+	id := ids[c.ResumeLast-1]
+	c.Process.SessionID = id
+	c.Log.ResumeSessionID = id
+	fmt.Fprintf(w, "cursor-wrap: resuming session %s\n", id)
+	return nil
+}
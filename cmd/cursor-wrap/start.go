@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/process"
+)
+
+// preflightTimeout bounds each preflight command.
+const preflightTimeout = 10 * time.Second
+
+// preflight checks the agent binary and its login before the first turn,
+// so a bad --agent-bin or a logged-out agent fails fast with a fix-it
+// message rather than as a hang or abnormal exit mid-turn. The detected
+// version is logged as the session log's first record.
+func preflight(ctx context.Context, cfg Config, log *logger.LogSession) error {
+	bin := cfg.Process.AgentBin
+	where := strconv.Quote(bin)
+	if cfg.Process.Remote != "" {
+		where += " on " + cfg.Process.Remote
+	}
+	version, err := process.Preflight(ctx, cfg.Process, preflightTimeout)
+	switch {
+	case errors.Is(err, process.ErrAgentNotFound):
+		return fmt.Errorf("cursor-agent not found at %s: install it or point --agent-bin at it: %w", where, err)
+	case errors.Is(err, process.ErrAgentNotExecutable):
+		return fmt.Errorf("cursor-agent at %s is not executable: check its permissions or --agent-bin: %w", where, err)
+	case errors.Is(err, process.ErrNotAuthenticated):
+		return fmt.Errorf("cursor-agent is not authenticated: run 'cursor-agent login': %w", err)
+	case err != nil:
+		return fmt.Errorf("preflight (skip with --no-preflight): %w", err)
+	}
+	log.Info("preflight ok", "agent_bin", bin, "agent_version", version)
+	return nil
+}
+
+// startupError is a failure to get cursor-agent going at all: its
+// working directory missing, or preflight failing. Like a
+// *process.StartError, it exits with code 4.
+type startupError struct{ err error }
+
+func (e *startupError) Error() string { return e.err.Error() }
+func (e *startupError) Unwrap() error { return e.err }
+
+// maxStartRetryDelay caps the backoff between start attempts.
+const maxStartRetryDelay = 30 * time.Second
+
+// startFunc matches process.Start; tests substitute a fake.
+type startFunc func(context.Context, process.Config) (*process.Session, error)
+
+// startWithRetry calls start, retrying transient spawn failures (see
+// process.IsTransientStartError) up to retries times with exponential
+// backoff and jitter. Any other error is returned immediately.
+func startWithRetry(ctx context.Context, procCfg process.Config, retries int, delay time.Duration, log *logger.LogSession, start startFunc) (*process.Session, error) {
+	for attempt := 0; ; attempt++ {
+		sess, err := start(ctx, procCfg)
+		if err == nil || attempt >= retries || !process.IsTransientStartError(err) {
+			return sess, err
+		}
+		wait := startBackoff(delay, attempt)
+		log.Warn("agent start failed, retrying",
+			"error", err,
+			"attempt", attempt+1,
+			"max_attempts", retries+1,
+			"retry_in_ms", wait.Milliseconds(),
+		)
+		if !sleepCtx(ctx, wait) {
+			return nil, err
+		}
+	}
+}
+
+// abnormalExitRetryDelay is the initial backoff before resuming a turn
+// whose agent exited without a result (see --retry-on-abnormal-exit).
+const abnormalExitRetryDelay = time.Second
+
+// sleepCtx waits for d, returning false if ctx is cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// startBackoff returns the wait before retry number attempt (0-based):
+// delay doubled per attempt, capped, then jittered into [d/2, d] so
+// parallel CI jobs don't retry in lockstep.
+func startBackoff(delay time.Duration, attempt int) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	d := delay
+	for range attempt {
+		d *= 2
+		if d >= maxStartRetryDelay {
+			d = maxStartRetryDelay
+			break
+		}
+	}
+	return d/2 + rand.N(d/2+1)
+}
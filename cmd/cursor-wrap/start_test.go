@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/process"
+)
+
+// flakyStart fails with err for the first failures calls, then succeeds.
+func flakyStart(failures int, err error) (startFunc, *int) {
+	calls := 0
+	return func(context.Context, process.Config) (*process.Session, error) {
+		calls++
+		if calls <= failures {
+			return nil, err
+		}
+		return &process.Session{}, nil
+	}, &calls
+}
+
+func TestStartWithRetry(t *testing.T) {
+	transient := &process.StartError{Err: syscall.EAGAIN}
+	permanent := &process.StartError{Err: syscall.ENOENT}
+
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		retries   int
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds first time", 0, nil, 2, 1, nil},
+		{"transient then success", 2, transient, 2, 3, nil},
+		{"transient exhausts retries", 5, transient, 2, 3, transient},
+		{"no retries configured", 1, transient, 0, 1, transient},
+		{"non-transient fails immediately", 5, permanent, 2, 1, permanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, teardown := setupTestLogger(t)
+			defer teardown()
+
+			start, calls := flakyStart(tt.failures, tt.err)
+			sess, err := startWithRetry(t.Context(), process.Config{}, tt.retries, time.Millisecond, log, start)
+			if *calls != tt.wantCalls {
+				t.Errorf("start called %d times, want %d", *calls, tt.wantCalls)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && sess == nil {
+				t.Fatal("expected a session")
+			}
+		})
+	}
+}
+
+func TestStartWithRetry_LogsEachRetry(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	start, _ := flakyStart(2, &process.StartError{Err: syscall.EAGAIN})
+	if _, err := startWithRetry(t.Context(), process.Config{}, 3, time.Millisecond, log, start); err != nil {
+		t.Fatalf("startWithRetry: %v", err)
+	}
+	teardown()
+
+	data, err := os.ReadFile(log.FilePath())
+	if err != nil {
+		t.Fatalf("reading log file: %v", err)
+	}
+	if n := strings.Count(string(data), "agent start failed, retrying"); n != 2 {
+		t.Errorf("expected 2 retry log records, got %d\n%s", n, data)
+	}
+}
+
+func TestStartWithRetry_ContextCancelledDuringBackoff(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	start, calls := flakyStart(5, &process.StartError{Err: syscall.EAGAIN})
+	if _, err := startWithRetry(ctx, process.Config{}, 5, time.Hour, log, start); err == nil {
+		t.Fatal("expected error after cancellation")
+	}
+	if *calls != 1 {
+		t.Errorf("start called %d times after cancellation, want 1", *calls)
+	}
+}
+
+func TestStartBackoff(t *testing.T) {
+	tests := []struct {
+		delay   time.Duration
+		attempt int
+		lo, hi  time.Duration
+	}{
+		{0, 3, 0, 0},
+		{100 * time.Millisecond, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{100 * time.Millisecond, 2, 200 * time.Millisecond, 400 * time.Millisecond},
+		{time.Second, 20, maxStartRetryDelay / 2, maxStartRetryDelay},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if got := startBackoff(tt.delay, tt.attempt); got < tt.lo || got > tt.hi {
+				t.Fatalf("startBackoff(%v, %d) = %v, want in [%v, %v]", tt.delay, tt.attempt, got, tt.lo, tt.hi)
+			}
+		}
+	}
+}
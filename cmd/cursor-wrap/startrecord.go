@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/process"
+)
+
+// sensitiveFlag matches the names of flags whose values are credentials,
+// e.g. an --api-key passed through to cursor-agent after "--".
+var sensitiveFlag = regexp.MustCompile(`(?i)(key|token|secret|password|passwd|auth|credential)`)
+
+// redactedValue stands in for a value kept out of the log.
+const redactedValue = "[REDACTED]"
+
+// logWrapperStart writes the wrapper_start record: the build of the
+// wrapper (its version is on every record too) and the Go release, the
+// command line and the configuration it resolved to, so a post-mortem never has
+// to ask what a run was started with. It is logged at debug, which the
+// session log always captures and the console only shows on request.
+func logWrapperStart(log *logger.LogSession, cfg Config, argv []string) {
+	p := cfg.Process
+	log.Debug("wrapper_start",
+		logger.Kind(logger.KindConfig),
+		"go_version", runtime.Version(),
+		"build", readBuildInfo(),
+		"argv", redactArgs(argv),
+		"config_files", cfg.ConfigFiles,
+		"config_env", cfg.ConfigEnv,
+		slog.Group("config",
+			"print", cfg.Print,
+			"output_format", cfg.OutputFormat,
+			"wrapper_events", cfg.WrapperEvents,
+			"stream_tool_output", cfg.StreamToolOutput,
+			"show_thinking", cfg.ShowThinking,
+			"idle_timeout_ms", cfg.IdleTimeout.Milliseconds(),
+			"tool_grace_ms", cfg.ToolGrace.Milliseconds(),
+			"tick_interval_ms", cfg.TickInterval.Milliseconds(),
+			"max_thinking_ms", cfg.MaxThinking.Milliseconds(),
+			"turn_timeout_ms", cfg.TurnTimeout.Milliseconds(),
+			"nonjson_liveness", cfg.NonJSONLiveness,
+			"estimate_factor", cfg.EstimateFactor,
+			"fatal_stderr_patterns", len(cfg.FatalStderrPatterns),
+			"max_output_bytes", cfg.MaxOutputBytes,
+			"max_event_bytes", cfg.MaxEventBytes,
+			"event_buffer", cfg.EventBuffer,
+			"max_hang_retries", cfg.MaxHangRetries,
+			"retry_on_abnormal_exit", cfg.RetryOnAbnormalExit,
+			"hang_webhook", cfg.HangWebhook != "",
+			"webhook_abnormal_exit", cfg.WebhookAbnormalExit,
+			"agent_bin", p.AgentBin,
+			"remote", p.Remote,
+			"model", p.Model,
+			"workspace", p.Workspace,
+			"cwd", p.Cwd,
+			"resume", p.SessionID,
+			"resume_last", cfg.ResumeLast,
+			"force", p.Force,
+			"extra_flags", redactArgs(p.ExtraFlags),
+			"env", envKeys(p.Env),
+			"record", cfg.Record,
+			"replay", cfg.Replay,
+			"replay_speed", cfg.ReplaySpeed,
+			"history_file", cfg.HistoryFile,
+			"log_dir", cfg.Log.Dir,
+			"log_dir_mode", cfg.LogDirMode,
+			"gitignore_hint", gitignoreHint(cfg.Log.Dir, p.Workspace),
+			"no_log_file", cfg.Log.NoFile,
+			"file_log_level", cfg.Log.FileLevel.String(),
+			"no_raw_events", cfg.Log.NoRawEvents,
+			"log_time_format", cfg.Log.TimeFormat,
+			"log_sync", cfg.Log.Sync.String(),
+			"log_async", cfg.Log.Async.String(),
+			"split_logs", cfg.Log.SplitLogs,
+			"new_log_on_resume", cfg.Log.NewLogOnResume,
+			"log_http_endpoint", redactURL(cfg.Log.HTTPEndpoint),
+			"log_prompt", cfg.LogPrompt,
+		),
+	)
+}
+
+// gitignoreHint returns the .gitignore line that keeps dir, a log
+// directory inside the workspace (or the current directory without one),
+// out of version control: its top-level entry, anchored at the root.
+// It returns "" when dir is outside.
+func gitignoreHint(dir, workspace string) string {
+	if workspace == "" {
+		workspace = "."
+	}
+	root, err := filepath.Abs(workspace)
+	if err != nil {
+		return ""
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	top, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	return "/" + top + "/"
+}
+
+// Values of --log-prompt: how much of a prompt turn_start records.
+const (
+	logPromptFull = "full" // the text, with secrets masked
+	logPromptHash = "hash" // its SHA-256 only
+	logPromptNone = "none" // its size only
+)
+
+// logTurnStart writes the turn_start record: the prompt (as --log-prompt
+// allows), the session resumed if any, and the arguments the agent is
+// started with. Unlike the agent's own user event, it is there for
+// turns that never get that far.
+func logTurnStart(log *logger.LogSession, procCfg process.Config, logPrompt string) {
+	attrs := []any{logger.Kind(logger.KindTurnStats), "prompt_bytes", len(procCfg.Prompt)}
+	switch logPrompt {
+	case logPromptFull:
+		attrs = append(attrs, "prompt", log.RedactText(procCfg.Prompt))
+	case logPromptHash:
+		sum := sha256.Sum256([]byte(procCfg.Prompt))
+		attrs = append(attrs, "prompt_sha256", hex.EncodeToString(sum[:]))
+	}
+	attrs = append(attrs,
+		"resume", procCfg.SessionID != "",
+		"resume_session_id", procCfg.SessionID,
+		"agent_args", redactArgs(procCfg.Args()),
+	)
+	log.Debug("turn_start", attrs...)
+}
+
+// redactArgs returns a copy of args with the values of sensitive flags,
+// in both "--flag value" and "--flag=value" form, and of --env
+// assignments replaced by redactedValue.
+func redactArgs(args []string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		out = append(out, arg)
+		if !strings.HasPrefix(arg, "-") || arg == "--" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		var mask func(string) string
+		switch {
+		case name == "env":
+			mask = redactEnv
+		case name == "log-http-endpoint":
+			mask = redactURL
+		case name == "hang-webhook":
+			mask = redactWebhook
+		case sensitiveFlag.MatchString(name):
+			mask = func(string) string { return redactedValue }
+		default:
+			continue
+		}
+		switch {
+		case hasValue:
+			out[i] = arg[:len(arg)-len(value)] + mask(value)
+		case i+1 < len(args):
+			i++
+			out = append(out, mask(args[i]))
+		}
+	}
+	return out
+}
+
+// redactEnv masks the value of a KEY=VALUE assignment.
+func redactEnv(kv string) string {
+	key, _, ok := strings.Cut(kv, "=")
+	if !ok {
+		return kv
+	}
+	return key + "=" + redactedValue
+}
+
+// redactURL masks the password in a URL's user info, if it has one.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+	return u.Redacted()
+}
+
+// envKeys returns the keys of KEY=VALUE assignments; their values may be
+// credentials and are not logged.
+func envKeys(env []string) []string {
+	keys := make([]string, 0, len(env))
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/monitor"
+)
+
+// statusStderrLines is how many of the agent's latest stderr lines a
+// status report shows.
+const statusStderrLines = 5
+
+// statusRequests returns a channel that receives SIGUSR1, the signal
+// asking for a status report. runTurn's event loop answers it, so the
+// report reads the monitor from the goroutine that owns it; one asked for
+// between turns is answered as the next turn starts.
+func statusRequests() chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	return ch
+}
+
+// eventCounts counts a turn's events by type.
+type eventCounts map[string]int
+
+func (c eventCounts) total() int {
+	n := 0
+	for _, v := range c {
+		n += v
+	}
+	return n
+}
+
+// String lists the counts by type, in type order.
+func (c eventCounts) String() string {
+	parts := make([]string, 0, len(c))
+	for _, typ := range slices.Sorted(maps.Keys(c)) {
+		parts = append(parts, fmt.Sprintf("%s %d", typ, c[typ]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// turnStatus is what a status report says about the turn in progress.
+type turnStatus struct {
+	Turn      int
+	SessionID string        // empty until the agent reports one
+	Elapsed   time.Duration // since the turn started
+	Verdict   monitor.Verdict
+	Reason    monitor.Reason // from Monitor.Snapshot, with the stderr tail
+	Events    eventCounts
+}
+
+// writeStatus prints s to w and logs it as a "status" record.
+func writeStatus(w io.Writer, log *logger.LogSession, s turnStatus) {
+	r := s.Reason
+	r.StderrTail = r.StderrTail[max(len(r.StderrTail)-statusStderrLines, 0):]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "cursor-wrap status: turn %d, session %s, %v in\n", s.Turn, cmp.Or(s.SessionID, "(none yet)"), roundStatus(s.Elapsed))
+	fmt.Fprintf(&b, "  last event %v ago (%s), verdict %s\n", roundStatus(time.Duration(r.IdleSilenceMS)*time.Millisecond), cmp.Or(r.LastEventType, "none"), s.Verdict)
+	for _, c := range r.OpenCalls {
+		fmt.Fprintf(&b, "  open call %s (%s): %v of %v\n", c.CallID, cmp.Or(c.Command, "no command"),
+			roundStatus(time.Duration(c.ElapsedMS)*time.Millisecond), roundStatus(time.Duration(c.DeadlineMS)*time.Millisecond))
+	}
+	if len(s.Events) == 0 {
+		b.WriteString("  events: none yet\n")
+	} else {
+		fmt.Fprintf(&b, "  events: %d (%s)\n", s.Events.total(), s.Events)
+	}
+	if len(r.StderrTail) > 0 {
+		b.WriteString("  agent stderr:\n")
+		for _, line := range r.StderrTail {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+	_, _ = io.WriteString(w, b.String())
+
+	attrs := []any{
+		"session_id", s.SessionID,
+		"turn_elapsed_ms", s.Elapsed.Milliseconds(),
+		"verdict", s.Verdict.String(),
+		"events", s.Events.total(),
+		slog.Any("event_counts", map[string]int(s.Events)),
+	}
+	attrs = append(attrs, reasonAttrs(r)...)
+	for i, c := range r.OpenCalls {
+		attrs = append(attrs, fmt.Sprintf("open_call_%d_deadline_ms", i), c.DeadlineMS)
+	}
+	log.Info("status", attrs...)
+}
+
+// roundStatus rounds d to the tenth of a second a status report shows.
+func roundStatus(d time.Duration) time.Duration {
+	return d.Round(100 * time.Millisecond)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/monitor"
+)
+
+func TestWriteStatus(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+
+	var out strings.Builder
+	writeStatus(&out, log, turnStatus{
+		Turn:      2,
+		SessionID: "sess-1",
+		Elapsed:   12340 * time.Millisecond,
+		Verdict:   monitor.VerdictWaiting,
+		Reason: monitor.Reason{
+			IdleSilenceMS: 1500,
+			OpenCallCount: 1,
+			LastEventType: "tool_call/started",
+			OpenCalls:     []monitor.OpenCallDetail{{CallID: "call-1", Command: "go test ./...", ElapsedMS: 8000, TimeoutMS: 30000, DeadlineMS: 60000}},
+			StderrTail:    []string{"one", "two", "three", "four", "five", "six"},
+		},
+		Events: eventCounts{"system": 1, "assistant": 3, "tool_call": 1},
+	})
+
+	want := `cursor-wrap status: turn 2, session sess-1, 12.3s in
+  last event 1.5s ago (tool_call/started), verdict Waiting
+  open call call-1 (go test ./...): 8s of 1m0s
+  events: 5 (assistant 3, system 1, tool_call 1)
+  agent stderr:
+    two
+    three
+    four
+    five
+    six
+`
+	if out.String() != want {
+		t.Errorf("report:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	data, err := os.ReadFile(log.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`"msg":"status"`,
+		`"session_id":"sess-1"`,
+		`"turn_elapsed_ms":12340`,
+		`"verdict":"Waiting"`,
+		`"events":5`,
+		`"event_counts":{"assistant":3,"system":1,"tool_call":1}`,
+		`"open_call_0_deadline_ms":60000`,
+		`"stderr_tail_4":"six"`,
+	} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("log lacks %s:\n%s", s, data)
+		}
+	}
+}
+
+func TestWriteStatus_BeforeAnyEvent(t *testing.T) {
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+
+	var out strings.Builder
+	writeStatus(&out, log, turnStatus{Turn: 1, Elapsed: 50 * time.Millisecond, Events: eventCounts{}})
+	want := `cursor-wrap status: turn 1, session (none yet), 100ms in
+  last event 0s ago (none), verdict OK
+  events: none yet
+`
+	if out.String() != want {
+		t.Errorf("report:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+const (
+	// stderrTailLines is how many trailing stderr lines a hang report keeps.
+	stderrTailLines = 20
+	// stderrTailLineBytes caps each retained line so a single runaway
+	// line (e.g. a minified stack trace) can't inflate the buffer.
+	stderrTailLineBytes = 512
+)
+
+// stderrTail retains the last few lines the agent wrote to stderr so that
+// hang reports can show them. drainStderr writes from its own goroutine
+// while the event loop reads, hence the mutex.
+type stderrTail struct {
+	mu    sync.Mutex
+	lines []string
+	max   int
+}
+
+func newStderrTail(max int) *stderrTail {
+	return &stderrTail{max: max}
+}
+
+// Add records a line, evicting the oldest once the buffer is full.
+func (t *stderrTail) Add(line string) {
+	line = truncateUTF8(line, stderrTailLineBytes)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.max <= 0 {
+		return
+	}
+	if len(t.lines) == t.max {
+		copy(t.lines, t.lines[1:])
+		t.lines = t.lines[:t.max-1]
+	}
+	t.lines = append(t.lines, line)
+}
+
+// Lines returns a copy of the retained lines, oldest first.
+func (t *stderrTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.lines) == 0 {
+		return nil
+	}
+	out := make([]string, len(t.lines))
+	copy(out, t.lines)
+	return out
+}
+
+// fatalMatch is a stderr line that matched one of the fatal patterns.
+type fatalMatch struct {
+	Line    string
+	Pattern string
+}
+
+// fatalStderr watches stderr lines for messages after which cursor-agent
+// is known to idle forever (auth failures, rate limits). Only the first
+// match is reported: the turn is torn down as soon as it is seen.
+type fatalStderr struct {
+	patterns []*regexp.Regexp
+	ch       chan fatalMatch
+}
+
+func newFatalStderr(patterns []*regexp.Regexp) *fatalStderr {
+	return &fatalStderr{patterns: patterns, ch: make(chan fatalMatch, 1)}
+}
+
+// Check reports line to the event loop if it matches a fatal pattern.
+func (f *fatalStderr) Check(line string) {
+	for _, re := range f.patterns {
+		if re.MatchString(line) {
+			select {
+			case f.ch <- fatalMatch{Line: line, Pattern: re.String()}:
+			default: // an earlier match is already pending
+			}
+			return
+		}
+	}
+}
+
+// Matches delivers the first matching line.
+func (f *fatalStderr) Matches() <-chan fatalMatch {
+	return f.ch
+}
+
+// stderrFile copies the agent's stderr verbatim to a file, keeping the
+// colors and multi-line tracebacks that the line-per-record session log
+// splits up. The file is opened for appending on the first write, so a
+// turn with a quiet agent leaves no empty file behind.
+//
+// Write never fails: a broken copy must not stop drainStderr from
+// feeding the tail and fatal-pattern checks. The first error is kept
+// for Close to report.
+type stderrFile struct {
+	path string
+	f    *os.File
+	err  error
+}
+
+func newStderrFile(path string) *stderrFile {
+	return &stderrFile{path: path}
+}
+
+func (s *stderrFile) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return len(p), nil
+	}
+	if s.f == nil {
+		s.f, s.err = os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if s.err != nil {
+			s.err = fmt.Errorf("agent stderr file: %w", s.err)
+			return len(p), nil
+		}
+	}
+	if _, err := s.f.Write(p); err != nil {
+		s.err = fmt.Errorf("agent stderr file: %w", err)
+	}
+	return len(p), nil
+}
+
+// Close closes the file, if it was opened, and returns the first error
+// seen while writing it.
+func (s *stderrFile) Close() error {
+	if s.f != nil {
+		if err := s.f.Close(); err != nil && s.err == nil {
+			s.err = fmt.Errorf("agent stderr file: %w", err)
+		}
+		s.f = nil
+	}
+	return s.err
+}
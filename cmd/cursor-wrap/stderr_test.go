@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStderrTail_KeepsLastLines(t *testing.T) {
+	tail := newStderrTail(3)
+	for _, l := range []string{"a", "b", "c", "d", "e"} {
+		tail.Add(l)
+	}
+	got := tail.Lines()
+	want := []string{"c", "d", "e"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Lines() = %v, want %v", got, want)
+	}
+}
+
+func TestStderrTail_Empty(t *testing.T) {
+	tail := newStderrTail(3)
+	if got := tail.Lines(); got != nil {
+		t.Errorf("Lines() = %v, want nil", got)
+	}
+}
+
+func TestStderrTail_TruncatesLongLines(t *testing.T) {
+	x := strings.Repeat("x", stderrTailLineBytes)
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"short", "short", "short"},
+		{"exactly the cap", x, x},
+		{"long", strings.Repeat("x", stderrTailLineBytes*4), x + "…"},
+		// The cut never lands inside a multi-byte character.
+		{"two-byte rune at the cut", x[1:] + "é and more", x[1:] + "…"},
+		{"three-byte rune at the cut", x[2:] + "€ and more", x[2:] + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tail := newStderrTail(3)
+			tail.Add(tt.line)
+			got := tail.Lines()
+			if len(got) != 1 {
+				t.Fatalf("len(Lines()) = %d, want 1", len(got))
+			}
+			if got[0] != tt.want {
+				t.Errorf("retained line = %q (%d bytes), want %q (%d bytes)", got[0], len(got[0]), tt.want, len(tt.want))
+			}
+			if !utf8.ValidString(got[0]) {
+				t.Errorf("retained line is not valid UTF-8: %q", got[0])
+			}
+		})
+	}
+}
+
+func TestStderrTail_LinesIsCopy(t *testing.T) {
+	tail := newStderrTail(3)
+	tail.Add("original")
+	got := tail.Lines()
+	got[0] = "mutated"
+	if tail.Lines()[0] != "original" {
+		t.Error("mutating Lines() result changed the buffer")
+	}
+}
+
+func TestFatalStderr_Check(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		wantMatch bool
+	}{
+		{name: "auth failure", line: "Error: Not Authenticated", wantMatch: true},
+		{name: "rate limit", line: "rate limit exceeded, try again later", wantMatch: true},
+		{name: "benign", line: "fake-agent args: --print", wantMatch: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFatalStderr(defaultFatalStderrPatterns())
+			f.Check(tt.line)
+			select {
+			case m := <-f.Matches():
+				if !tt.wantMatch {
+					t.Fatalf("unexpected match %+v", m)
+				}
+				if m.Line != tt.line {
+					t.Errorf("Line = %q, want %q", m.Line, tt.line)
+				}
+			default:
+				if tt.wantMatch {
+					t.Fatal("expected a match")
+				}
+			}
+		})
+	}
+}
+
+func TestFatalStderr_OnlyFirstMatchReported(t *testing.T) {
+	f := newFatalStderr(defaultFatalStderrPatterns())
+	f.Check("not authenticated")
+	f.Check("rate limit exceeded") // must not block
+
+	m := <-f.Matches()
+	if m.Line != "not authenticated" {
+		t.Errorf("Line = %q, want first match", m.Line)
+	}
+}
+
+func TestStderrFile_CreatedLazilyAndAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent-stderr.txt")
+
+	quiet := newStderrFile(path)
+	if err := quiet.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no file before the first write, stat err = %v", err)
+	}
+
+	for _, chunk := range []string{"turn one\n", "turn two\n"} {
+		f := newStderrFile(path)
+		if n, err := f.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "turn one\nturn two\n"; got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+}
+
+func TestStderrFile_ErrorDoesNotFailWrite(t *testing.T) {
+	f := newStderrFile(filepath.Join(t.TempDir(), "missing", "stderr.txt"))
+	if n, err := f.Write([]byte("line\n")); n != 5 || err != nil {
+		t.Fatalf("Write = %d, %v; want 5, nil", n, err)
+	}
+	if err := f.Close(); err == nil {
+		t.Error("expected Close to report the open failure")
+	}
+}
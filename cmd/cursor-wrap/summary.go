@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"cursor-wrap/internal/logger"
+)
+
+// sessionSummary is the machine-readable record of a session written at
+// exit (--summary-file), so scripts need not scrape stderr.
+type sessionSummary struct {
+	SessionID      string        `json:"session_id,omitempty"`  // the first
+	SessionIDs     []string      `json:"session_ids,omitempty"` // all, in order
+	Tag            string        `json:"tag,omitempty"`
+	Build          buildInfo     `json:"build"`
+	ExitCode       int           `json:"exit_code"`
+	Error          string        `json:"error,omitempty"`
+	StartedAt      time.Time     `json:"started_at"`
+	WallTimeMS     int64         `json:"wall_time_ms"`
+	LogFile        string        `json:"log_file,omitempty"`
+	EventsLogFile  string        `json:"events_log_file,omitempty"` // --split-logs only
+	TurnsAttempted int           `json:"turns_attempted"`
+	TurnsSucceeded int           `json:"turns_succeeded"`
+	HangCount      int           `json:"hang_count"`
+	Hangs          []hangSummary `json:"hangs,omitempty"`
+	Turns          []turnSummary `json:"turns"`
+	// Batch is each --batch prompt's outcome, in the file's order.
+	Batch []batchPromptSummary `json:"batch,omitempty"`
+}
+
+type turnSummary struct {
+	Turn          int    `json:"turn"`
+	ResultSubtype string `json:"result_subtype,omitempty"` // empty: no result event
+	Error         string `json:"error,omitempty"`
+	// From the result event, when the agent build reports them.
+	Model        string        `json:"model,omitempty"`
+	Usage        *usageSummary `json:"usage,omitempty"`
+	ErrorMessage string        `json:"error_message,omitempty"`
+}
+
+// batchPromptSummary is the outcome of one --batch prompt. The prompt
+// itself is left out, like everywhere --log-prompt may keep it from;
+// its line in the batch file says which it was.
+type batchPromptSummary struct {
+	Index         int    `json:"index"`
+	Line          int    `json:"line"`
+	PromptBytes   int    `json:"prompt_bytes"`
+	Status        string `json:"status"`          // batchSucceeded, batchFailed or batchSkipped
+	Turns         []int  `json:"turns,omitempty"` // more than one after --retry-on-abnormal-exit
+	SessionID     string `json:"session_id,omitempty"`
+	ResultSubtype string `json:"result_subtype,omitempty"`
+	Error         string `json:"error,omitempty"`
+	WallTimeMS    int64  `json:"wall_time_ms"`
+}
+
+// Values of batchPromptSummary.Status.
+const (
+	batchSucceeded = "succeeded"
+	batchFailed    = "failed"
+	batchSkipped   = "skipped" // not run, after a failure with --fail-fast
+)
+
+type usageSummary struct {
+	InputTokens      int64 `json:"input_tokens"`
+	OutputTokens     int64 `json:"output_tokens"`
+	CacheReadTokens  int64 `json:"cache_read_tokens"`
+	CacheWriteTokens int64 `json:"cache_write_tokens"`
+}
+
+type hangSummary struct {
+	Turn          int    `json:"turn"`
+	Reason        string `json:"reason"`
+	IdleSilenceMS int64  `json:"idle_silence_ms"`
+	OpenCallCount int    `json:"open_call_count"`
+	LastEventType string `json:"last_event_type"`
+}
+
+func newSessionSummary(started time.Time, tag string) *sessionSummary {
+	return &sessionSummary{StartedAt: started, Tag: tag, Build: readBuildInfo(), Turns: []turnSummary{}}
+}
+
+// addTurn records the outcome of one turn (each retry is a turn of its
+// own).
+func (s *sessionSummary) addTurn(turn int, res TurnResult) {
+	s.TurnsAttempted++
+	t := turnSummary{Turn: turn, ResultSubtype: res.ResultSubtype}
+	if res.Err != nil {
+		t.Error = res.Err.Error()
+	} else {
+		s.TurnsSucceeded++
+	}
+	if r := res.Result; r != nil {
+		t.Model, t.ErrorMessage = r.Model, r.ErrorMessage
+		if u := r.Usage; u != nil {
+			t.Usage = &usageSummary{
+				InputTokens:      u.InputTokens,
+				OutputTokens:     u.OutputTokens,
+				CacheReadTokens:  u.CacheReadTokens,
+				CacheWriteTokens: u.CacheWriteTokens,
+			}
+		}
+	}
+	s.Turns = append(s.Turns, t)
+	if res.SessionID != "" && s.SessionID == "" {
+		s.SessionID = res.SessionID
+	}
+	if id := res.SessionID; id != "" && !slices.Contains(s.SessionIDs, id) {
+		s.SessionIDs = append(s.SessionIDs, id)
+	}
+	if errors.Is(res.Err, ErrHangDetected) {
+		reason := res.Reason.AsOf(res.KilledAt)
+		s.HangCount++
+		s.Hangs = append(s.Hangs, hangSummary{
+			Turn:          turn,
+			Reason:        reason.String(),
+			IdleSilenceMS: reason.IdleSilenceMS,
+			OpenCallCount: reason.OpenCallCount,
+			LastEventType: reason.LastEventType,
+		})
+	}
+}
+
+// summaryPath returns where the summary goes: --summary-file, or next to
+// the session log's first part, or nowhere when logging to the console
+// only.
+func summaryPath(cfg Config, log *logger.LogSession) string {
+	if cfg.SummaryFile != "" {
+		return cfg.SummaryFile
+	}
+	parts := log.Parts()
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(parts[0], ".jsonl") + ".summary.json"
+}
+
+// finish completes s with the session's outcome.
+func (s *sessionSummary) finish(logFile string, runErr error) {
+	s.WallTimeMS = time.Since(s.StartedAt).Milliseconds()
+	s.LogFile = logFile
+	if runErr != nil {
+		s.ExitCode = exitCode(runErr)
+		s.Error = runErr.Error()
+	}
+}
+
+// writeSummary writes s, finished, to path. Like the pidfile it goes
+// through a temporary file and a rename, so a reader never sees a
+// partial summary.
+func writeSummary(path string, s *sessionSummary) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("summary: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("summary: %w", err)
+	}
+	_, err = tmp.Write(append(data, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name()) // best effort; the write error is what matters
+		return fmt.Errorf("summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/monitor"
+	"cursor-wrap/internal/process"
+	"cursor-wrap/internal/telemetry"
+)
+
+// newTracer returns a tracer exporting to --otel-endpoint or the
+// endpoint in the standard OTEL_EXPORTER_OTLP_* variables, or nil when
+// neither is set. Spans join the trace in $TRACEPARENT, if any.
+func newTracer(cfg Config, log *logger.LogSession) *telemetry.Tracer {
+	otlpCfg, ok, warnings := telemetry.OTLPConfigFromEnv(os.Getenv, cfg.OTelEndpoint)
+	for _, w := range warnings {
+		log.Warn("otel: " + w)
+	}
+	if !ok {
+		return nil
+	}
+	tracer := telemetry.NewTracer(telemetry.NewOTLPExporter(otlpCfg), os.Getenv("TRACEPARENT"))
+	log.Info("exporting traces", "endpoint", otlpCfg.Endpoint, "trace_id", tracer.TraceID().String())
+	return tracer
+}
+
+// flushTrace exports the spans of the turn that just ended. A collector
+// that is down costs a warning, never the session.
+func flushTrace(tracer *telemetry.Tracer, log *logger.LogSession) {
+	if tracer == nil {
+		return
+	}
+	if err := tracer.Flush(context.Background()); err != nil {
+		log.Warn("exporting trace spans failed", "error", err)
+	}
+}
+
+// turnTrace turns one turn's events into a span with a child span per
+// tool call. A nil *turnTrace (tracing disabled) ignores every call.
+type turnTrace struct {
+	tracer *telemetry.Tracer
+	span   *telemetry.Span
+	tools  map[string]*toolSpan // open tool calls by call_id
+}
+
+type toolSpan struct {
+	span    *telemetry.Span
+	started time.Time
+}
+
+func startTurnTrace(tracer *telemetry.Tracer, turn int, procCfg process.Config) *turnTrace {
+	if tracer == nil {
+		return nil
+	}
+	span := tracer.Start("cursor-agent turn", nil, telemetry.Attrs(
+		"turn", turn,
+		"model", procCfg.Model,
+		"resume", procCfg.SessionID != "",
+	)...)
+	return &turnTrace{tracer: tracer, span: span, tools: map[string]*toolSpan{}}
+}
+
+// Event updates the spans from an agent event.
+func (t *turnTrace) Event(ev events.AnnotatedEvent) {
+	if t == nil {
+		return
+	}
+	payload, _ := events.Decode(ev) // nil if it can't be
+	switch p := payload.(type) {
+	case events.SystemInit:
+		t.span.SetAttrs(telemetry.Attrs("session_id", p.SessionID, "agent_model", p.Model)...)
+	case events.ToolCallStarted:
+		attrs := telemetry.Attrs("call_id", p.CallID)
+		info, err := events.ParseToolCallInfo(p.ToolCall)
+		name := "tool_call"
+		if err == nil {
+			name += " " + info.ToolType
+			attrs = append(attrs, telemetry.Attrs("tool_type", info.ToolType)...)
+			if info.Command != "" {
+				attrs = append(attrs, telemetry.Attrs("command", info.Command, "timeout_ms", info.TimeoutMS)...)
+			}
+		}
+		t.tools[p.CallID] = &toolSpan{span: t.tracer.Start(name, t.span, attrs...), started: ev.RecvTime}
+	case events.ToolCallCompleted:
+		ts, ok := t.tools[p.CallID]
+		if !ok {
+			return
+		}
+		delete(t.tools, p.CallID)
+		ts.span.SetAttrs(telemetry.Attrs("duration_ms", ev.RecvTime.Sub(ts.started).Milliseconds())...)
+		if res, err := events.ParseShellToolResult(p.ToolCall); err == nil {
+			ts.span.SetAttrs(telemetry.Attrs("exit_code", res.ExitCode)...)
+			if res.ExitCode != 0 {
+				ts.span.SetError(fmt.Sprintf("exit code %d", res.ExitCode))
+			}
+		}
+		ts.span.End()
+	case events.Result:
+		t.span.SetAttrs(telemetry.Attrs("result_subtype", p.Subtype, "is_error", p.IsError, "agent_duration_ms", p.DurationMS)...)
+	}
+}
+
+// Hang records a confirmed hang as an event on the turn span.
+func (t *turnTrace) Hang(r monitor.Reason) {
+	if t == nil {
+		return
+	}
+	t.span.AddEvent("hang_detected", telemetry.Attrs(reasonAttrs(r)...)...)
+}
+
+// End finishes the turn's span, and those of tool calls that never
+// completed.
+func (t *turnTrace) End(res TurnResult) {
+	if t == nil {
+		return
+	}
+	for _, ts := range t.tools {
+		ts.span.SetError("tool call did not complete")
+		ts.span.End()
+	}
+	if res.Err != nil {
+		t.span.SetError(res.Err.Error())
+	}
+	t.span.End()
+}
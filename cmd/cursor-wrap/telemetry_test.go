@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/events"
+	"cursor-wrap/internal/monitor"
+	"cursor-wrap/internal/process"
+	"cursor-wrap/internal/telemetry"
+)
+
+func traceEvent(at time.Time, typ, subtype, raw string) events.AnnotatedEvent {
+	return events.AnnotatedEvent{RecvTime: at, Raw: []byte(raw), Parsed: events.RawEvent{Type: typ, Subtype: subtype}}
+}
+
+func spanAttr(s telemetry.SpanData, key string) (any, bool) {
+	for _, a := range s.Attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return nil, false
+}
+
+func TestTurnTrace_Spans(t *testing.T) {
+	exp := &telemetry.MemoryExporter{}
+	tracer := telemetry.NewTracer(exp, "")
+	trace := startTurnTrace(tracer, 2, process.Config{Model: "gpt-5", SessionID: "sess-1"})
+
+	t0 := time.Now()
+	trace.Event(traceEvent(t0, "system", "init", `{"type":"system","subtype":"init","session_id":"sess-1","model":"GPT-5"}`))
+	trace.Event(traceEvent(t0, "tool_call", "started",
+		`{"type":"tool_call","subtype":"started","call_id":"c1","tool_call":{"shellToolCall":{"args":{"command":"make test","timeout":30000}}}}`))
+	trace.Event(traceEvent(t0.Add(1500*time.Millisecond), "tool_call", "completed",
+		`{"type":"tool_call","subtype":"completed","call_id":"c1","tool_call":{"shellToolCall":{"args":{"command":"make test"},"result":{"success":{"exitCode":2}}}}}`))
+	trace.Event(traceEvent(t0, "tool_call", "started",
+		`{"type":"tool_call","subtype":"started","call_id":"c2","tool_call":{"readToolCall":{"args":{"path":"go.mod"}}}}`))
+	trace.Hang(monitor.Reason{IdleSilenceMS: 61000, OpenCallCount: 1, LastEventType: "tool_call"})
+	trace.End(TurnResult{Err: errors.New("hang detected")})
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exp.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	shell, read, turn := spans[0], spans[1], spans[2]
+
+	if turn.Name != "cursor-agent turn" || turn.Error != "hang detected" {
+		t.Errorf("turn span = %q error %q", turn.Name, turn.Error)
+	}
+	for key, want := range map[string]any{"turn": int64(2), "model": "gpt-5", "resume": true, "session_id": "sess-1"} {
+		if got, _ := spanAttr(turn, key); got != want {
+			t.Errorf("turn %s = %#v, want %#v", key, got, want)
+		}
+	}
+	if len(turn.Events) != 1 || turn.Events[0].Name != "hang_detected" {
+		t.Fatalf("turn events = %+v", turn.Events)
+	}
+	if got := turn.Events[0].Attrs[0]; got.Key != "idle_silence_ms" || got.Value != int64(61000) {
+		t.Errorf("hang_detected first attr = %+v", got)
+	}
+
+	if shell.Name != "tool_call shellToolCall" || shell.ParentID != turn.SpanID {
+		t.Errorf("shell span = %q, parent %s, want child of %s", shell.Name, shell.ParentID, turn.SpanID)
+	}
+	for key, want := range map[string]any{"call_id": "c1", "command": "make test", "exit_code": int64(2), "duration_ms": int64(1500)} {
+		if got, _ := spanAttr(shell, key); got != want {
+			t.Errorf("shell %s = %#v, want %#v", key, got, want)
+		}
+	}
+	if shell.Error != "exit code 2" {
+		t.Errorf("shell error = %q", shell.Error)
+	}
+
+	if read.Name != "tool_call readToolCall" || read.Error == "" {
+		t.Errorf("unfinished read span = %q error %q, want it ended as failed", read.Name, read.Error)
+	}
+	if _, ok := spanAttr(read, "command"); ok {
+		t.Error("read span has a command attribute")
+	}
+}
+
+func TestTurnTrace_DisabledIsNil(t *testing.T) {
+	trace := startTurnTrace(nil, 1, process.Config{})
+	if trace != nil {
+		t.Fatal("startTurnTrace(nil) != nil")
+	}
+	trace.Event(traceEvent(time.Now(), "result", "success", `{"type":"result"}`))
+	trace.Hang(monitor.Reason{})
+	trace.End(TurnResult{})
+}
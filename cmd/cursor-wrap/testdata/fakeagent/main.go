@@ -6,31 +6,63 @@
 package main
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
+	// Preflight commands (see process.Preflight).
+	if len(os.Args) == 2 {
+		switch os.Args[1] {
+		case "--version":
+			fmt.Println("2026.01.01-fake")
+			return
+		case "status":
+			if os.Getenv("FAKE_AGENT_AUTH") == "none" {
+				fmt.Println("Not logged in")
+				os.Exit(1)
+			}
+			fmt.Println("Logged in as test@example.com")
+			return
+		}
+	}
+
 	// Read prompt from stdin (cursor-agent behavior: reads to EOF).
 	prompt, _ := io.ReadAll(os.Stdin)
 
 	// Log args to stderr for test verification.
 	fmt.Fprintf(os.Stderr, "fake-agent args: %s\n", strings.Join(os.Args[1:], " "))
 	fmt.Fprintf(os.Stderr, "fake-agent prompt: %s\n", string(prompt))
+	// Like cursor-agent, read a file the prompt @-references.
+	if _, ref, ok := strings.Cut(string(prompt), "@"); ok && len(strings.Fields(ref)) > 0 {
+		path := strings.TrimSuffix(strings.Fields(ref)[0], ".")
+		data, err := os.ReadFile(path)
+		fmt.Fprintf(os.Stderr, "fake-agent read @%s: %d bytes, sha256 %x, err %v\n", path, len(data), sha256.Sum256(data), err)
+	}
+	if path := os.Getenv("FAKE_AGENT_PIDFILE"); path != "" {
+		// Report the pidfile as seen mid-turn next to the real pid. The
+		// wrapper writes it just after spawning, so allow it a moment.
+		want := fmt.Sprint(os.Getpid())
+		var got string
+		for deadline := time.Now().Add(2 * time.Second); got != want && time.Now().Before(deadline); {
+			data, _ := os.ReadFile(path)
+			got = strings.TrimSpace(string(data))
+			time.Sleep(10 * time.Millisecond)
+		}
+		fmt.Fprintf(os.Stderr, "fake-agent pid: %s pidfile: %s\n", want, got)
+	}
 
 	scenario := os.Getenv("FAKE_AGENT_SCENARIO")
 
 	// For multi-turn scenarios, detect if this is a resumed invocation.
-	isResume := false
-	for _, arg := range os.Args[1:] {
-		if arg == "--resume" {
-			isResume = true
-			break
-		}
-	}
+	isResume := hasArg("--resume")
 
 	switch scenario {
 	case "normal":
@@ -41,6 +73,8 @@ func main() {
 		emitToolTimeoutHang()
 	case "with_tool":
 		emitWithTool()
+	case "streaming_tool":
+		emitStreamingTool()
 	case "multi_turn":
 		if isResume {
 			emitNormal() // Second turn: normal completion
@@ -53,18 +87,101 @@ func main() {
 		} else {
 			emitIdleHang() // First turn: hangs
 		}
+	case "new_session_after_hang":
+		// Like hang_then_normal, but the agent won't resume the hung
+		// session and answers in a new one, which later turns resume.
+		if isResume {
+			emitNormalAs("test-session-id-2")
+		} else {
+			emitIdleHang()
+		}
 	case "slow_normal":
 		emitSlowNormal()
+	case "partial_line_hang":
+		// Hangs part way through writing an event.
+		fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+		fmt.Print(`{"type":"assistant","message":{"content":[{"type":"text","text":"Runn`)
+		time.Sleep(10 * time.Minute)
+	case "stderr_then_hang":
+		fmt.Fprintln(os.Stderr, "warning: upstream connection reset, retrying")
+		emitIdleHang()
+	case "event_at_deadline":
+		emitEventAtDeadline()
+	case "endless_thinking":
+		emitEndlessThinking()
+	case "no_result":
+		// Exits cleanly, but without ever sending a result.
+		fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+		fmt.Println(`{"type":"assistant","message":{"content":[{"type":"text","text":"Starting on it."}]}}`)
+	case "crash_then_resume":
+		if isResume {
+			emitNormal() // Retry: the resumed session completes
+		} else {
+			// First attempt: start the session, then die without a result.
+			fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+			os.Exit(1)
+		}
+	case "hang_flush_on_sigint":
+		emitHangFlushOnSIGINT()
+	case "huge_tool_result":
+		emitHugeToolResult()
+	case "long_line":
+		emitLongLine()
+	case "no_init":
+		// The init line is lost; the session id is only on later events.
+		lines := []string{
+			`{"type":"thinking","subtype":"delta","text":"Let me think about this."}`,
+			`{"type":"assistant","message":{"content":[{"type":"text","text":"Final answer."}]}}`,
+			`{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"session_id":"test-session-id","request_id":"req_1"}`,
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	case "nonjson_notice":
+		fmt.Println("T: Named models unavailable on free plan")
+		emitNormal()
+	case "by_prompt":
+		// A prompt mentioning a hang hangs; any other completes, in the
+		// session resumed or one named after its first word.
+		session := "session-" + strings.Fields(string(prompt) + " none")[0]
+		for i, arg := range os.Args[1 : len(os.Args)-1] {
+			if arg == "--resume" {
+				session = os.Args[i+2]
+			}
+		}
+		if strings.Contains(string(prompt), "hang") {
+			emitIdleHang()
+		} else {
+			emitNormalAs(session)
+		}
+	case "fatal_stderr":
+		fmt.Fprintln(os.Stderr, "Error: not authenticated. Run cursor-agent login.")
+		emitIdleHang()
 	default:
 		fmt.Fprintf(os.Stderr, "unknown scenario: %s\n", scenario)
 		os.Exit(1)
 	}
 }
 
+// hasArg reports whether the wrapper passed arg.
+func hasArg(arg string) bool {
+	for _, a := range os.Args[1:] {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
 // emitNormal outputs a complete event sequence including a tool call and exits.
 // Matches the task spec: system/init → user → thinking → assistant →
 // tool_call/started → tool_call/completed → assistant(final) → result.
 func emitNormal() {
+	emitNormalAs("test-session-id")
+}
+
+// emitNormalAs is emitNormal in the session with the given id.
+func emitNormalAs(sessionID string) {
 	lines := []string{
 		`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`,
 		`{"type":"user","message":{"content":[{"type":"text","text":"test prompt"}]}}`,
@@ -74,10 +191,10 @@ func emitNormal() {
 		`{"type":"tool_call","subtype":"started","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1000,"tool_call":{"shellToolCall":{"args":{"command":"echo test","timeout":120000}}}}`,
 		`{"type":"tool_call","subtype":"completed","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1100,"tool_call":{"shellToolCall":{"args":{"command":"echo test","timeout":120000},"result":{"success":{"exitCode":0,"stdout":"test\n","stderr":"","executionTime":100}}}}}`,
 		`{"type":"assistant","message":{"content":[{"type":"text","text":"Final answer."}]}}`,
-		`{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"session_id":"test-session-id","request_id":"req_1"}`,
+		`{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"session_id":"test-session-id","request_id":"req_1","model":"test-model","usage":{"inputTokens":1200,"outputTokens":80,"cacheReadTokens":1000,"cacheWriteTokens":0}}`,
 	}
 	for _, line := range lines {
-		fmt.Println(line)
+		fmt.Println(strings.ReplaceAll(line, `"test-session-id"`, strconv.Quote(sessionID)))
 	}
 }
 
@@ -97,6 +214,40 @@ func emitIdleHang() {
 	time.Sleep(10 * time.Minute)
 }
 
+// emitHangFlushOnSIGINT goes silent like emitIdleHang, but answers SIGINT
+// the way cursor-agent does: it cancels the request and flushes a result
+// event before exiting. SIGTERM keeps its default, immediate exit.
+func emitHangFlushOnSIGINT() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT)
+	fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+	fmt.Println(`{"type":"thinking","subtype":"delta","text":"Let me think about this."}`)
+	select {
+	case <-sigCh:
+		fmt.Println(`{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"result":"cancelled","session_id":"test-session-id","request_id":"req_1"}`)
+	case <-time.After(10 * time.Minute):
+	}
+}
+
+// emitHugeToolResult emits a shell tool call whose result holds 8 MB of
+// stdout, like a tool that cats an enormous file, then hangs.
+func emitHugeToolResult() {
+	fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+	fmt.Println(`{"type":"tool_call","subtype":"started","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1000,"tool_call":{"shellToolCall":{"args":{"command":"cat big.log","timeout":120000}}}}`)
+	fmt.Printf(`{"type":"tool_call","subtype":"completed","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1100,"tool_call":{"shellToolCall":{"args":{"command":"cat big.log","timeout":120000},"result":{"success":{"exitCode":0,"stdout":"%s","stderr":"","executionTime":100}}}}}`+"\n", strings.Repeat("x", 8<<20))
+	time.Sleep(10 * time.Minute)
+}
+
+// emitLongLine is emitNormal with 5 MB of stdout in the tool result, far
+// past the old 1 MB line limit, and exits.
+func emitLongLine() {
+	fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+	fmt.Println(`{"type":"tool_call","subtype":"started","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1000,"tool_call":{"shellToolCall":{"args":{"command":"cat big.log","timeout":120000}}}}`)
+	fmt.Printf(`{"type":"tool_call","subtype":"completed","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1100,"tool_call":{"shellToolCall":{"args":{"command":"cat big.log","timeout":120000},"result":{"success":{"exitCode":0,"stdout":"%s","stderr":"","executionTime":100}}}}}`+"\n", strings.Repeat("x", 5<<20))
+	fmt.Println(`{"type":"assistant","message":{"content":[{"type":"text","text":"Final answer."}]}}`)
+	fmt.Println(`{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"session_id":"test-session-id","request_id":"req_1"}`)
+}
+
 // emitToolTimeoutHang emits a tool_call/started with a short timeout, then hangs.
 func emitToolTimeoutHang() {
 	lines := []string{
@@ -114,6 +265,19 @@ func emitToolTimeoutHang() {
 	time.Sleep(10 * time.Minute)
 }
 
+// emitStreamingTool runs a tool past its declared timeout (1000ms), but
+// streaming output every 300ms, so it is never silent for long.
+func emitStreamingTool() {
+	fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+	fmt.Println(`{"type":"tool_call","subtype":"started","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":1000,"tool_call":{"shellToolCall":{"args":{"command":"make","timeout":1000}}}}`)
+	for i := 1; i <= 8; i++ {
+		time.Sleep(300 * time.Millisecond)
+		fmt.Printf(`{"type":"tool_call","subtype":"update","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":%d,"stdout":"step %d\n"}`+"\n", 1000+300*i, i)
+	}
+	fmt.Println(`{"type":"tool_call","subtype":"completed","call_id":"call_1","model_call_id":"mc_1","timestamp_ms":3500,"tool_call":{"shellToolCall":{"args":{"command":"make","timeout":1000},"result":{"success":{"exitCode":0,"stdout":"","stderr":"","executionTime":2500}}}}}`)
+	fmt.Println(`{"type":"result","subtype":"success","duration_ms":2500,"is_error":false,"session_id":"test-session-id","request_id":"req_1"}`)
+}
+
 // emitWithTool outputs a sequence with a tool call for text format testing.
 func emitWithTool() {
 	lines := []string{
@@ -143,3 +307,27 @@ func emitSlowNormal() {
 	time.Sleep(30 * time.Second)
 	fmt.Println(`{"type":"result","subtype":"success","duration_ms":5000,"is_error":false,"session_id":"test-session-id","request_id":"req_1"}`)
 }
+
+// emitEventAtDeadline goes quiet for FAKE_AGENT_DELAY (default 1s) after
+// init, then completes normally. With --idle-timeout equal to the delay,
+// the first events land right as the idle deadline expires.
+func emitEventAtDeadline() {
+	delay := time.Second
+	if d, err := time.ParseDuration(os.Getenv("FAKE_AGENT_DELAY")); err == nil {
+		delay = d
+	}
+	fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+	time.Sleep(delay)
+	fmt.Println(`{"type":"assistant","message":{"content":[{"type":"text","text":"Made it."}]}}`)
+	fmt.Println(`{"type":"result","subtype":"success","duration_ms":1000,"is_error":false,"session_id":"test-session-id","request_id":"req_1"}`)
+}
+
+// emitEndlessThinking streams a thinking delta every 100ms and never
+// completes the phase, so the idle timer never fires.
+func emitEndlessThinking() {
+	fmt.Println(`{"type":"system","subtype":"init","session_id":"test-session-id","model":"test-model","cwd":"/tmp","permissionMode":"auto"}`)
+	for range 6000 {
+		fmt.Println(`{"type":"thinking","subtype":"delta","text":"Still thinking."}`)
+		time.Sleep(100 * time.Millisecond)
+	}
+}
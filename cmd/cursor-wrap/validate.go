@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"cursor-wrap/internal/logger"
+)
+
+// validTag matches the --tag values that are safe in a file name.
+var validTag = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Validate checks the parsed configuration before anything is spawned.
+// Hard errors are joined into one error so every problem is reported in
+// a single run; warnings describe settings that work but are probably
+// not what the user meant.
+func (c Config) Validate() (warnings []string, err error) {
+	var errs []error
+
+	switch c.OutputFormat {
+	case "stream-json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("--output-format %q: want stream-json or text", c.OutputFormat))
+	}
+
+	switch c.Log.ConsoleFormat {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("--console-log-format %q: want text or json", c.Log.ConsoleFormat))
+	}
+
+	switch c.Log.TimeFormat {
+	case logger.TimeMillis, logger.TimeRFC3339:
+	default:
+		errs = append(errs, fmt.Errorf("--log-time-format %q: want millis or rfc3339", c.Log.TimeFormat))
+	}
+
+	switch c.LogDirMode {
+	case logDirHome:
+	case logDirWorkspace:
+		if c.Process.Remote != "" {
+			warnings = append(warnings, "--log-dir-mode workspace: the --remote workspace is on another host, logging to ~/.cursor-wrap/logs instead")
+		}
+	default:
+		errs = append(errs, fmt.Errorf("--log-dir-mode %q: want home or workspace", c.LogDirMode))
+	}
+
+	switch c.LogPrompt {
+	case logPromptFull, logPromptHash, logPromptNone:
+	default:
+		errs = append(errs, fmt.Errorf("--log-prompt %q: want full, hash or none", c.LogPrompt))
+	}
+
+	if c.Log.Tag != "" && !validTag.MatchString(c.Log.Tag) {
+		errs = append(errs, fmt.Errorf("--tag %q: use letters, digits, '.', '_' and '-' only (it goes in file names)", c.Log.Tag))
+	}
+
+	if c.Detach && !c.Print {
+		errs = append(errs, errors.New("--detach needs -p: a detached session can't read prompts from the terminal"))
+	}
+	switch {
+	case c.ReportFD == -1:
+	case c.ReportFD < 3:
+		errs = append(errs, fmt.Errorf("--report-fd %d: want 3 or more, a descriptor of its own (or --report-file /dev/stdout)", c.ReportFD))
+	case c.Detach:
+		errs = append(errs, errors.New("--report-fd can't be used with --detach: the detached run doesn't inherit it; use --report-file"))
+	}
+
+	for _, d := range []struct {
+		flag string
+		v    time.Duration
+	}{
+		{"--idle-timeout", c.IdleTimeout},
+		{"--tick-interval", c.TickInterval},
+	} {
+		if d.v <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %v", d.flag, d.v))
+		}
+	}
+	for _, d := range []struct {
+		flag string
+		v    time.Duration
+	}{
+		{"--tool-grace", c.ToolGrace},
+		{"--max-thinking-duration", c.MaxThinking},
+		{"--turn-timeout", c.TurnTimeout},
+		{"--start-retry-delay", c.StartRetryDelay},
+		{"--prompt-write-timeout", c.Process.PromptWriteTimeout},
+	} {
+		if d.v < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %v", d.flag, d.v))
+		}
+	}
+	if c.EstimateFactor < 0 {
+		errs = append(errs, fmt.Errorf("--estimate-factor must not be negative, got %v", c.EstimateFactor))
+	}
+	if c.StartRetries < 0 {
+		errs = append(errs, fmt.Errorf("--start-retries must not be negative, got %d", c.StartRetries))
+	}
+	if c.RetryOnAbnormalExit < 0 {
+		errs = append(errs, fmt.Errorf("--retry-on-abnormal-exit must not be negative, got %d", c.RetryOnAbnormalExit))
+	}
+	if c.LogRetainDays < 0 {
+		errs = append(errs, fmt.Errorf("--log-retain-days must not be negative, got %d", c.LogRetainDays))
+	}
+	if c.LogRetainCount < 0 {
+		errs = append(errs, fmt.Errorf("--log-retain-count must not be negative, got %d", c.LogRetainCount))
+	}
+	if c.HangDumpEvents < 0 {
+		errs = append(errs, fmt.Errorf("--hang-dump-events must not be negative, got %d", c.HangDumpEvents))
+	}
+	if c.MaxHangRetries < 0 {
+		errs = append(errs, fmt.Errorf("--max-hang-retries must not be negative, got %d", c.MaxHangRetries))
+	}
+
+	if c.TickInterval > 0 && c.IdleTimeout > 0 {
+		switch {
+		case c.TickInterval > c.IdleTimeout:
+			errs = append(errs, fmt.Errorf("--tick-interval %v is longer than --idle-timeout %v: hangs would be noticed late", c.TickInterval, c.IdleTimeout))
+		case c.TickInterval > c.IdleTimeout/2:
+			warnings = append(warnings, fmt.Sprintf("--tick-interval %v is more than half of --idle-timeout %v; a hang may run up to %v before it is noticed", c.TickInterval, c.IdleTimeout, c.IdleTimeout+c.TickInterval))
+		}
+	}
+	if c.ToolGrace == 0 {
+		warnings = append(warnings, "--tool-grace 0: a tool call is declared hung the moment its timeout passes, and one with no declared timeout the moment --idle-timeout passes")
+	}
+	if c.MaxThinking > 0 && c.MaxThinking < c.TickInterval {
+		warnings = append(warnings, fmt.Sprintf("--max-thinking-duration %v is shorter than --tick-interval %v and is only checked once per tick", c.MaxThinking, c.TickInterval))
+	}
+	if c.ReplaySpeed < 0 {
+		errs = append(errs, fmt.Errorf("--replay-speed must not be negative, got %g", c.ReplaySpeed))
+	}
+	if c.EventBuffer <= 0 {
+		errs = append(errs, fmt.Errorf("--event-buffer must be positive, got %d", c.EventBuffer))
+	}
+	if c.Log.QueueSize <= 0 {
+		errs = append(errs, fmt.Errorf("--log-queue-size must be positive, got %d", c.Log.QueueSize))
+	}
+	if c.HangWebhook != "" {
+		if u, err := url.Parse(c.HangWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("--hang-webhook %s: want an http:// or https:// URL", redactWebhook(c.HangWebhook)))
+		}
+	} else if c.WebhookAbnormalExit {
+		warnings = append(warnings, "--webhook-abnormal-exit has no effect without --hang-webhook")
+	}
+	if c.Log.HTTPEndpoint != "" {
+		if u, err := url.Parse(c.Log.HTTPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("--log-http-endpoint %q: want an http:// or https:// URL", c.Log.HTTPEndpoint))
+		}
+		if c.Log.HTTPMaxBuffer <= 0 {
+			errs = append(errs, fmt.Errorf("--log-http-max-buffer must be positive, got %d", c.Log.HTTPMaxBuffer))
+		}
+	}
+	if c.ResumeLast > 0 && c.Process.SessionID != "" {
+		errs = append(errs, errors.New("--resume and --resume-last both pick the session to resume: give one"))
+	}
+	switch c.BatchSession {
+	case batchSessionFresh:
+		if c.Batch != "" && c.Process.SessionID != "" {
+			errs = append(errs, errors.New("--resume with --batch: use --batch-session shared to run the batch in the resumed session"))
+		}
+		if c.Batch != "" && c.ResumeLast > 0 {
+			errs = append(errs, errors.New("--resume-last with --batch: use --batch-session shared to run the batch in the resumed session"))
+		}
+	case batchSessionShared:
+	default:
+		errs = append(errs, fmt.Errorf("--batch-session %q: want fresh or shared", c.BatchSession))
+	}
+	if c.Batch != "" && (c.PositionalPrompt != "" || c.PromptFile != "") {
+		errs = append(errs, errors.New("--batch takes its prompts from the batch file: drop the positional prompt and --prompt-file"))
+	}
+	if c.PromptFile != "" && c.PositionalPrompt != "" {
+		warnings = append(warnings, "--prompt-file is ignored: a positional prompt was given, and takes precedence")
+	}
+	if c.Log.NoFile && (c.Log.MaxSize > 0 || c.LogRetainDays > 0 || c.LogRetainCount > 0) {
+		warnings = append(warnings, "--no-log-file: --log-max-size and --log-retain-* have no session log to act on")
+	}
+
+	if err := c.Process.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return warnings, errors.Join(errs...)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		modify   func(*Config)
+		wantErr  []string // substrings of the joined error
+		wantWarn string   // substring of a warning; empty means none
+	}{
+		{name: "defaults are valid", args: []string{"-p"}},
+		{
+			name:    "unknown output format",
+			args:    []string{"--output-format", "xml"},
+			wantErr: []string{`--output-format "xml"`},
+		},
+		{
+			name:    "unknown console log format",
+			args:    []string{"--console-log-format", "logfmt"},
+			wantErr: []string{`--console-log-format "logfmt"`},
+		},
+		{
+			name:    "unknown prompt logging",
+			args:    []string{"--log-prompt", "some"},
+			wantErr: []string{`--log-prompt "some"`},
+		},
+		{
+			name:    "unknown log time format",
+			args:    []string{"--log-time-format", "iso"},
+			wantErr: []string{`--log-time-format "iso"`},
+		},
+		{
+			name:    "unknown log dir mode",
+			args:    []string{"--log-dir-mode", "repo"},
+			wantErr: []string{`--log-dir-mode "repo"`},
+		},
+		{
+			name:     "workspace log dir with a remote agent",
+			args:     []string{"-p", "--log-dir-mode", "workspace", "--remote", "devbox"},
+			wantWarn: "--log-dir-mode workspace",
+		},
+		{
+			name:    "tag with a path separator",
+			args:    []string{"--tag", "ci/runner-3"},
+			wantErr: []string{`--tag "ci/runner-3"`},
+		},
+		{
+			name:    "detach without print",
+			args:    []string{"--detach"},
+			wantErr: []string{"--detach needs -p"},
+		},
+		{
+			name:    "non-positive durations",
+			args:    []string{"--idle-timeout", "0", "--tick-interval", "-1s"},
+			wantErr: []string{"--idle-timeout must be positive", "--tick-interval must be positive"},
+		},
+		{
+			name:    "negative durations",
+			args:    []string{"--tool-grace", "-1s", "--max-thinking-duration", "-1s", "--start-retry-delay", "-1s", "--turn-timeout", "-1s"},
+			wantErr: []string{"--tool-grace must not be negative", "--max-thinking-duration must not be negative", "--start-retry-delay must not be negative", "--turn-timeout must not be negative"},
+		},
+		{
+			name:    "negative counts",
+			args:    []string{"--estimate-factor", "-2", "--start-retries", "-1", "--max-hang-retries", "-1", "--hang-dump-events", "-1"},
+			wantErr: []string{"--estimate-factor", "--start-retries", "--max-hang-retries", "--hang-dump-events"},
+		},
+		{
+			name:    "negative log retention",
+			args:    []string{"--log-retain-days", "-1", "--log-retain-count", "-1"},
+			wantErr: []string{"--log-retain-days", "--log-retain-count"},
+		},
+		{
+			name:    "negative replay speed",
+			args:    []string{"--replay", "capture.jsonl", "--replay-speed", "-1"},
+			wantErr: []string{"--replay-speed must not be negative"},
+		},
+		{
+			name:    "empty event buffer",
+			args:    []string{"--event-buffer", "0"},
+			wantErr: []string{"--event-buffer must be positive"},
+		},
+		{
+			name:    "empty log queue",
+			args:    []string{"--log-async", "drop", "--log-queue-size", "0"},
+			wantErr: []string{"--log-queue-size must be positive"},
+		},
+		{
+			name:     "log options without a log file",
+			args:     []string{"--no-log-file", "--log-retain-count", "5"},
+			wantWarn: "--no-log-file",
+		},
+		{
+			name:    "tick longer than idle timeout",
+			args:    []string{"--idle-timeout", "10s", "--tick-interval", "20s"},
+			wantErr: []string{"longer than --idle-timeout"},
+		},
+		{
+			name:     "tick more than half the idle timeout",
+			args:     []string{"--idle-timeout", "10s", "--tick-interval", "6s"},
+			wantWarn: "more than half of --idle-timeout",
+		},
+		{
+			name:     "zero tool grace",
+			args:     []string{"--tool-grace", "0"},
+			wantWarn: "--tool-grace 0",
+		},
+		{
+			name:     "thinking cap below tick",
+			args:     []string{"--max-thinking-duration", "1s"},
+			wantWarn: "checked once per tick",
+		},
+		{
+			name:    "report on stdout",
+			args:    []string{"-p", "--report-fd", "1"},
+			wantErr: []string{"--report-fd 1: want 3 or more"},
+		},
+		{
+			name:    "resume and resume-last",
+			args:    []string{"-p", "--resume", "sess-1", "--resume-last"},
+			wantErr: []string{"--resume and --resume-last both pick the session"},
+		},
+		{
+			name:    "resume-last with a fresh batch",
+			args:    []string{"--batch", "prompts.txt", "--resume-last=2"},
+			wantErr: []string{"--resume-last with --batch"},
+		},
+		{name: "resume-last with a shared batch", args: []string{"--batch", "prompts.txt", "--batch-session", "shared", "--resume-last"}},
+		{
+			name:    "report fd when detached",
+			args:    []string{"-p", "--detach", "--report-fd", "3"},
+			wantErr: []string{"--report-fd can't be used with --detach"},
+		},
+		{
+			name:    "hang webhook not a URL",
+			args:    []string{"--hang-webhook", "hooks.slack.com/services/T0/secret"},
+			wantErr: []string{"--hang-webhook [REDACTED]: want an http:// or https:// URL"},
+		},
+		{
+			name:     "abnormal exit webhook without a webhook",
+			args:     []string{"--webhook-abnormal-exit"},
+			wantWarn: "--webhook-abnormal-exit has no effect without --hang-webhook",
+		},
+		{
+			name:    "batch session",
+			args:    []string{"--batch", "prompts.txt", "--batch-session", "forked"},
+			wantErr: []string{`--batch-session "forked"`},
+		},
+		{
+			name:    "batch resuming a session it doesn't share",
+			args:    []string{"--batch", "prompts.txt", "--resume", "abc"},
+			wantErr: []string{"--batch-session shared"},
+		},
+		{
+			name:    "batch and a positional prompt",
+			args:    []string{"--batch", "prompts.txt", "fix the tests"},
+			wantErr: []string{"--batch takes its prompts from the batch file"},
+		},
+		{
+			name:     "prompt file and a positional prompt",
+			args:     []string{"--prompt-file", "prompt.md", "fix the tests"},
+			wantWarn: "--prompt-file is ignored",
+		},
+		{
+			name:    "flag swallowed by --resume",
+			args:    []string{"--resume", "--model"},
+			wantErr: []string{`session id "--model"`},
+		},
+		{
+			name:    "negative resource limit",
+			modify:  func(c *Config) { c.Process.MaxOpenFiles = -1 },
+			wantErr: []string{"open file limit must not be negative"},
+		},
+		{
+			name:    "empty agent binary",
+			modify:  func(c *Config) { c.Process.AgentBin = "" },
+			wantErr: []string{"agent binary path is empty"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseFlags(tt.args)
+			if tt.modify != nil {
+				tt.modify(&cfg)
+			}
+			warnings, err := cfg.Validate()
+
+			if len(tt.wantErr) == 0 && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(tt.wantErr) > 0 && err == nil {
+				t.Fatalf("expected error containing %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q missing %q", err, want)
+				}
+			}
+
+			joined := strings.Join(warnings, "\n")
+			if tt.wantWarn == "" && len(warnings) > 0 {
+				t.Errorf("unexpected warnings: %q", warnings)
+			}
+			if tt.wantWarn != "" && !strings.Contains(joined, tt.wantWarn) {
+				t.Errorf("warnings %q missing %q", warnings, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestConfigValidate_ReportsEveryError(t *testing.T) {
+	cfg := parseFlags([]string{"--idle-timeout", "0", "--output-format", "xml"})
+	cfg.TickInterval = time.Second
+	_, err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 2 {
+		t.Errorf("expected 2 problems, got %d: %q", len(lines), lines)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version, commit and buildDate describe the build, stamped with
+//
+//	-ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// Unstamped builds fall back to the module's build info.
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// buildInfo is what --version prints, and the wrapper_start record and
+// the session summary hold.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// readBuildInfo returns the stamped build info, filling in what wasn't
+// stamped from the VCS settings go build records, or "unknown".
+func readBuildInfo() buildInfo {
+	b := buildInfo{Version: wrapperVersion(), Commit: commit, Date: buildDate, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && b.Commit == "":
+				b.Commit = s.Value
+			case s.Key == "vcs.time" && b.Date == "":
+				b.Date = s.Value
+			}
+		}
+	}
+	if b.Commit == "" {
+		b.Commit = "unknown"
+	}
+	if b.Date == "" {
+		b.Date = "unknown"
+	}
+	return b
+}
+
+func (b buildInfo) String() string {
+	return fmt.Sprintf("cursor-wrap %s\ncommit: %s\nbuilt:  %s\ngo:     %s", b.Version, b.Commit, b.Date, b.GoVersion)
+}
+
+// wrapperVersion returns version or, for an unstamped build, the module
+// version ("(devel)" for a local build) and its VCS revision.
+func wrapperVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	var rev, dirty string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				dirty = "-dirty"
+			}
+		}
+	}
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	if rev != "" && (v == "" || v == "(devel)") {
+		return "devel+" + rev + dirty
+	}
+	if v == "" || v == "(devel)" {
+		return "devel"
+	}
+	return v
+}
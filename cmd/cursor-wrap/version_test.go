@@ -0,0 +1,34 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestReadBuildInfo_Stamped(t *testing.T) {
+	saved := [3]string{version, commit, buildDate}
+	t.Cleanup(func() { version, commit, buildDate = saved[0], saved[1], saved[2] })
+	version, commit, buildDate = "v1.2.3", "0123456789abcdef", "2026-01-02T03:04:05Z"
+
+	b := readBuildInfo()
+	want := buildInfo{Version: "v1.2.3", Commit: "0123456789abcdef", Date: "2026-01-02T03:04:05Z", GoVersion: runtime.Version()}
+	if b != want {
+		t.Errorf("readBuildInfo() = %+v, want %+v", b, want)
+	}
+	wantText := "cursor-wrap v1.2.3\ncommit: 0123456789abcdef\nbuilt:  2026-01-02T03:04:05Z\ngo:     " + runtime.Version()
+	if got := b.String(); got != wantText {
+		t.Errorf("String() = %q, want %q", got, wantText)
+	}
+}
+
+func TestReadBuildInfo_Unstamped(t *testing.T) {
+	saved := [3]string{version, commit, buildDate}
+	t.Cleanup(func() { version, commit, buildDate = saved[0], saved[1], saved[2] })
+	version, commit, buildDate = "", "", ""
+
+	// A test binary has no VCS settings.
+	b := readBuildInfo()
+	if b.Version == "" || b.Commit != "unknown" || b.Date != "unknown" || b.GoVersion != runtime.Version() {
+		t.Errorf("readBuildInfo() = %+v, want the fallbacks", b)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"cursor-wrap/internal/logger"
+	"cursor-wrap/internal/monitor"
+)
+
+// --hang-webhook delivery: each POST gets webhookPostTimeout, a failed one
+// is retried once after webhookRetryDelay, and at exit notifications still
+// on their way get webhookCloseTimeout before they are given up on.
+var (
+	webhookPostTimeout  = 5 * time.Second
+	webhookRetryDelay   = time.Second
+	webhookCloseTimeout = 5 * time.Second
+)
+
+// webhookPromptMax is how much of the prompt, in bytes, a notification
+// carries.
+const webhookPromptMax = 500
+
+// Webhook notification events.
+const (
+	webhookHang         = "hang"
+	webhookAbnormalExit = "abnormal_exit"
+)
+
+// webhookPayload is the JSON body POSTed to --hang-webhook. Text is a
+// one-line summary, the field Slack and Teams incoming webhooks display;
+// the rest is for anything that parses it.
+type webhookPayload struct {
+	Text        string         `json:"text"`
+	Event       string         `json:"event"`
+	Time        string         `json:"time"`
+	Host        string         `json:"host"`
+	Remote      string         `json:"remote,omitempty"`
+	SessionID   string         `json:"session_id,omitempty"`
+	Turn        int            `json:"turn"`
+	Prompt      string         `json:"prompt,omitempty"` // per --log-prompt, truncated
+	PromptBytes int            `json:"prompt_bytes"`
+	Error       string         `json:"error,omitempty"`
+	Reason      *webhookReason `json:"reason,omitempty"` // hangs only
+	LogFile     string         `json:"log_file,omitempty"`
+}
+
+// webhookReason is a monitor.Reason as a notification carries it.
+type webhookReason struct {
+	Summary            string           `json:"summary"`
+	IdleSilenceMS      int64            `json:"idle_silence_ms"`
+	OpenCallCount      int              `json:"open_call_count"`
+	LastEventType      string           `json:"last_event_type"`
+	OpenCalls          []openCallRecord `json:"open_calls,omitempty"`
+	TotalOpenElapsedMS int64            `json:"total_open_elapsed_ms,omitempty"`
+	ThinkingMS         int64            `json:"thinking_ms,omitempty"`
+	StderrTail         []string         `json:"stderr_tail,omitempty"`
+}
+
+// webhookNotifier POSTs --hang-webhook notifications in the background,
+// so a slow or unreachable endpoint never holds up killing the agent or
+// the next turn. A nil *webhookNotifier (no --hang-webhook) ignores
+// every call.
+type webhookNotifier struct {
+	url          string
+	abnormalExit bool
+	logPrompt    string
+	remote       string
+	host         string
+	client       *http.Client
+	log          *logger.LogSession
+
+	ctx    context.Context // cancelled webhookCloseTimeout into Close
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWebhookNotifier(cfg Config, log *logger.LogSession) *webhookNotifier {
+	if cfg.HangWebhook == "" {
+		return nil
+	}
+	host, _ := os.Hostname() // empty if unknown
+	ctx, cancel := context.WithCancel(context.Background())
+	return &webhookNotifier{
+		url:          cfg.HangWebhook,
+		abnormalExit: cfg.WebhookAbnormalExit,
+		logPrompt:    cfg.LogPrompt,
+		remote:       cfg.Process.Remote,
+		host:         host,
+		client:       &http.Client{Timeout: webhookPostTimeout},
+		log:          log,
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Hang notifies of a hang detected in turn, once the agent has been
+// killed.
+func (w *webhookNotifier) Hang(turn int, sessionID, prompt string, reason monitor.Reason) {
+	if w == nil {
+		return
+	}
+	p := w.payload(webhookHang, turn, sessionID, prompt)
+	p.Reason = &webhookReason{
+		Summary:            reason.String(),
+		IdleSilenceMS:      reason.IdleSilenceMS,
+		OpenCallCount:      reason.OpenCallCount,
+		LastEventType:      reason.LastEventType,
+		TotalOpenElapsedMS: reason.TotalOpenElapsedMS,
+		ThinkingMS:         reason.ThinkingMS,
+		StderrTail:         reason.StderrTail,
+	}
+	for _, c := range reason.OpenCalls {
+		p.Reason.OpenCalls = append(p.Reason.OpenCalls, openCallRecord{
+			CallID:              c.CallID,
+			Command:             c.Command,
+			ElapsedMS:           c.ElapsedMS,
+			TimeoutMS:           c.TimeoutMS,
+			EstimatedDeadlineMS: c.EstimatedDeadlineMS,
+		})
+	}
+	p.Text = fmt.Sprintf("cursor-wrap on %s: cursor-agent hung in turn %d (%s)", w.where(), turn, p.Reason.Summary)
+	w.send(p)
+}
+
+// AbnormalExit notifies of an agent that exited without a result, with
+// --webhook-abnormal-exit.
+func (w *webhookNotifier) AbnormalExit(turn int, sessionID, prompt string, err error) {
+	if w == nil || !w.abnormalExit {
+		return
+	}
+	p := w.payload(webhookAbnormalExit, turn, sessionID, prompt)
+	p.Error = err.Error()
+	p.Text = fmt.Sprintf("cursor-wrap on %s: cursor-agent exited without a result in turn %d (%v)", w.where(), turn, err)
+	w.send(p)
+}
+
+func (w *webhookNotifier) payload(event string, turn int, sessionID, prompt string) webhookPayload {
+	p := webhookPayload{
+		Event:       event,
+		Time:        time.Now().UTC().Format(time.RFC3339),
+		Host:        w.host,
+		Remote:      w.remote,
+		SessionID:   sessionID,
+		Turn:        turn,
+		PromptBytes: len(prompt),
+		LogFile:     w.log.FilePath(),
+	}
+	if w.logPrompt == logPromptFull {
+		p.Prompt = truncateUTF8(w.log.RedactText(prompt), webhookPromptMax)
+	}
+	return p
+}
+
+// where names the machine the agent ran on, for Text.
+func (w *webhookNotifier) where() string {
+	if w.remote != "" {
+		return w.remote
+	}
+	return w.host
+}
+
+func (w *webhookNotifier) send(p webhookPayload) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		w.log.Warn("hang webhook: encoding notification failed", "error", err)
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if err := w.post(body); err != nil {
+			w.log.Warn("hang webhook failed", "event", p.Event, "error", err)
+			return
+		}
+		w.log.Info("hang webhook sent", "event", p.Event)
+	}()
+}
+
+// post sends body, retrying once.
+func (w *webhookNotifier) post(body []byte) error {
+	err := w.postOnce(body)
+	if err == nil {
+		return nil
+	}
+	select {
+	case <-w.ctx.Done():
+		return err
+	case <-time.After(webhookRetryDelay):
+	}
+	if retryErr := w.postOnce(body); retryErr != nil {
+		return errors.Join(err, retryErr)
+	}
+	return nil
+}
+
+func (w *webhookNotifier) postOnce(body []byte) error {
+	req, err := http.NewRequestWithContext(w.ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The URL is often a credential (a Slack webhook's path is); keep
+		// it out of the log.
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return ue.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", redactWebhook(w.url), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body) // let the connection be reused
+	return nil
+}
+
+// Close waits for notifications still being sent, giving up on them
+// after webhookCloseTimeout.
+func (w *webhookNotifier) Close() {
+	if w == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(webhookCloseTimeout):
+		w.cancel()
+		<-done
+	}
+	w.cancel()
+}
+
+// redactWebhook returns the scheme and host of a webhook URL: its path
+// and query are often the credential.
+func redactWebhook(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+	return u.Scheme + "://" + u.Host + "/" + redactedValue
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune,
+// marking the cut with "…".
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cursor-wrap/internal/monitor"
+)
+
+// webhookServer records the payloads POSTed to it, failing the first
+// failFirst requests with a 503.
+func webhookServer(t *testing.T, failFirst int32) (*httptest.Server, <-chan map[string]any) {
+	t.Helper()
+	got := make(chan map[string]any, 4)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failFirst {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); r.Method != http.MethodPost || ct != "application/json" {
+			t.Errorf("%s with Content-Type %q", r.Method, ct)
+		}
+		var p map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+		got <- p
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+func TestWebhookNotifier_Payloads(t *testing.T) {
+	srv, got := webhookServer(t, 0)
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+	w := newWebhookNotifier(Config{HangWebhook: srv.URL + "/hooks/T0/B0/secret", WebhookAbnormalExit: true, LogPrompt: logPromptFull}, log)
+
+	prompt := strings.Repeat("é", webhookPromptMax) // 2 bytes each
+	w.Hang(3, "sess-1", prompt, monitor.Reason{
+		IdleSilenceMS: 61000,
+		OpenCallCount: 1,
+		LastEventType: "tool_call/started",
+		OpenCalls:     []monitor.OpenCallDetail{{CallID: "call-1", Command: "sleep 999", ElapsedMS: 61000, TimeoutMS: 30000}},
+		StderrTail:    []string{"warning: retrying"},
+	})
+	w.AbnormalExit(4, "sess-1", "go on", errors.New("stream ended without a result: abnormal exit"))
+	w.Close()
+
+	hang, exit := <-got, <-got
+	if hang["event"] != webhookHang {
+		hang, exit = exit, hang
+	}
+	host, _ := os.Hostname()
+	for k, want := range map[string]any{
+		"event":        "hang",
+		"session_id":   "sess-1",
+		"turn":         3.0,
+		"prompt_bytes": float64(len(prompt)),
+		"host":         host,
+		"log_file":     log.FilePath(),
+	} {
+		if hang[k] != want {
+			t.Errorf("hang %s = %v, want %v", k, hang[k], want)
+		}
+	}
+	if p, _ := hang["prompt"].(string); len(p) > webhookPromptMax+len("…") || !strings.HasSuffix(p, "é…") {
+		t.Errorf("prompt not truncated at a rune: %d bytes, ends %q", len(p), p[max(len(p)-8, 0):])
+	}
+	if text, _ := hang["text"].(string); !strings.Contains(text, "hung in turn 3") || !strings.Contains(text, "idle 61000ms") {
+		t.Errorf("text = %q", text)
+	}
+	reason, _ := hang["reason"].(map[string]any)
+	calls, _ := reason["open_calls"].([]any)
+	if reason["idle_silence_ms"] != 61000.0 || reason["last_event_type"] != "tool_call/started" || len(calls) != 1 ||
+		calls[0].(map[string]any)["command"] != "sleep 999" || reason["stderr_tail"] == nil {
+		t.Errorf("reason = %v", reason)
+	}
+
+	if exit["event"] != "abnormal_exit" || exit["turn"] != 4.0 || exit["prompt"] != "go on" ||
+		!strings.Contains(exit["error"].(string), "abnormal exit") || exit["reason"] != nil {
+		t.Errorf("abnormal exit payload = %v", exit)
+	}
+}
+
+func TestWebhookNotifier_PromptPerLogPrompt(t *testing.T) {
+	srv, got := webhookServer(t, 0)
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+	w := newWebhookNotifier(Config{HangWebhook: srv.URL, LogPrompt: logPromptHash}, log)
+	w.Hang(1, "", "a secret plan", monitor.Reason{})
+	w.AbnormalExit(1, "", "a secret plan", ErrAbnormalExit) // not without --webhook-abnormal-exit
+	w.Close()
+	if p := <-got; p["prompt"] != nil || p["prompt_bytes"] != 13.0 {
+		t.Errorf("payload = %v; want the prompt's size but not the prompt", p)
+	}
+	if len(got) != 0 {
+		t.Errorf("abnormal exit notified without --webhook-abnormal-exit: %v", <-got)
+	}
+}
+
+func TestWebhookNotifier_RetriesOnce(t *testing.T) {
+	defer func(d time.Duration) { webhookRetryDelay = d }(webhookRetryDelay)
+	webhookRetryDelay = 10 * time.Millisecond
+
+	srv, got := webhookServer(t, 1)
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+	w := newWebhookNotifier(Config{HangWebhook: srv.URL}, log)
+	w.Hang(1, "sess-1", "", monitor.Reason{})
+	w.Close()
+	if len(got) != 1 {
+		t.Fatal("not delivered on the retry")
+	}
+
+	srv, got = webhookServer(t, 2)
+	secret := srv.URL + "/hooks/secret-token"
+	w = newWebhookNotifier(Config{HangWebhook: secret}, log)
+	w.Hang(1, "sess-1", "", monitor.Reason{})
+	w.Close()
+	if len(got) != 0 {
+		t.Fatal("delivered after two failures")
+	}
+	data, err := os.ReadFile(log.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"hang webhook failed"`) || strings.Contains(string(data), "secret-token") {
+		t.Errorf("want a warning without the URL's path:\n%s", data)
+	}
+}
+
+func TestWebhookNotifier_CloseGivesUp(t *testing.T) {
+	defer func(d time.Duration) { webhookCloseTimeout = d }(webhookCloseTimeout)
+	webhookCloseTimeout = 100 * time.Millisecond
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	log, teardown := setupTestLogger(t)
+	defer teardown()
+
+	w := newWebhookNotifier(Config{HangWebhook: srv.URL}, log)
+	start := time.Now()
+	w.Hang(1, "sess-1", "", monitor.Reason{})
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Hang blocked for %v", elapsed)
+	}
+	w.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close waited %v on an endpoint that never answers", elapsed)
+	}
+}
+
+func TestWebhookNotifier_NilIsNoop(t *testing.T) {
+	var w *webhookNotifier
+	w.Hang(1, "", "", monitor.Reason{})
+	w.AbnormalExit(1, "", "", ErrAbnormalExit)
+	w.Close()
+	if newWebhookNotifier(Config{}, nil) != nil {
+		t.Error("notifier without --hang-webhook")
+	}
+}
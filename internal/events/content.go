@@ -1,15 +1,41 @@
 package events
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
 )
 
 // AssistantMessage extracts the text content from an "assistant" event.
 type AssistantMessage struct {
-	Text        string // extracted from message.content[0].text
-	ModelCallID string // present for mid-turn, absent for final
-	IsFinal     bool   // true when model_call_id is absent (final response)
+	Text        string         // the message's text blocks, joined by newlines
+	Blocks      []ContentBlock // every block, text or not, in order
+	ModelCallID string         // present for mid-turn, absent for final
+	IsFinal     bool           // true when model_call_id is absent (final response)
+	// TimestampMS is when the agent sent the message, if it says; mid-turn
+	// messages do, final ones don't. An agent configured to stream its response sends it as many
+	// small messages sharing a ModelCallID, each a fragment to append to
+	// the last, and this is the only marker of their order: nothing in a
+	// fragment says it is one.
+	TimestampMS int64
+}
+
+// UserMessage extracts the prompt from a "user" event.
+type UserMessage struct {
+	Text   string         // the message's text blocks, joined by newlines
+	Blocks []ContentBlock // every block, text or not, in order
+}
+
+// ContentBlock is one entry of an assistant message's content: text, or
+// something newer agent builds send alongside it, such as an image or a
+// structured tool_use block.
+type ContentBlock struct {
+	Type    string // "text", "image", "tool_use", ...
+	Text    string // text blocks only
+	URI     string // where an image is, if it says
+	Summary string // a short label for a non-text block, e.g. a tool's or file's name
 }
 
 // ThinkingDelta extracts the token text from a "thinking"/"delta" event.
@@ -18,14 +44,27 @@ type ThinkingDelta struct {
 }
 
 // ToolCallInfo extracts tool type and key arguments for display.
-// Parsed from the tool_call field of started/completed events.
+// Parsed from the tool_call field of started/completed events. Tools
+// not listed below yield just their ToolType.
 type ToolCallInfo struct {
 	ToolType string // key name: "shellToolCall", "lsToolCall", etc.
 	// Shell-specific fields (populated when ToolType == "shellToolCall"):
 	Command   string
 	TimeoutMS int64
-	// LS-specific fields (populated when ToolType == "lsToolCall"):
+	// The file or directory a tool works on (populated for lsToolCall,
+	// readToolCall, writeToolCall, editToolCall and deleteToolCall, and
+	// for grepToolCall when it searches somewhere in particular):
 	Path string
+	// Read-specific fields: the lines read, when not the whole file.
+	Offset int64
+	Limit  int64
+	// Write-specific fields: the size of the new content.
+	FileBytes int
+	// Grep-specific fields:
+	Pattern string
+	Globs   []string // the glob and ripgrep type filters, if any
+	// Edit-specific fields: what the edit does, e.g. "3 lines → 5 lines".
+	EditSummary string
 }
 
 // ShellToolResult extracts result fields from a completed shellToolCall.
@@ -36,17 +75,42 @@ type ShellToolResult struct {
 	ExecutionTime int64  `json:"executionTime"` // ms
 }
 
+// ReadToolResult extracts result fields from a completed readToolCall.
+type ReadToolResult struct {
+	TotalLines    int   `json:"totalLines"`
+	TotalChars    int64 `json:"totalChars"`
+	IsEmpty       bool  `json:"isEmpty"`
+	ExceededLimit bool  `json:"exceededLimit"` // the content was cut short
+}
+
+// WriteToolResult extracts result fields from a completed writeToolCall.
+type WriteToolResult struct {
+	Path         string `json:"path"` // absolute
+	LinesCreated int    `json:"linesCreated"`
+	FileSize     int64  `json:"fileSize"` // bytes
+}
+
+// EditToolResult extracts result fields from a completed editToolCall.
+type EditToolResult struct {
+	Path         string `json:"path"` // absolute
+	LinesAdded   int    `json:"linesAdded"`
+	LinesRemoved int    `json:"linesRemoved"`
+	DiffString   string `json:"diffString"` // unified diff of the change
+}
+
 // ParseAssistantMessage extracts text from an assistant event's raw JSON.
+// A message may be split into several content blocks, around tool use
+// for one; the text ones are joined, in order, by newlines. All of them,
+// text or not, are in Blocks, and a message with no text at all is not an
+// error.
 func ParseAssistantMessage(raw []byte) (AssistantMessage, error) {
 	// Intermediate structs to navigate the nested JSON.
 	var envelope struct {
 		Message struct {
-			Content []struct {
-				Type string `json:"type"`
-				Text string `json:"text"`
-			} `json:"content"`
+			Content []contentJSON `json:"content"`
 		} `json:"message"`
 		ModelCallID json.RawMessage `json:"model_call_id,omitempty"`
+		TimestampMS int64           `json:"timestamp_ms"`
 	}
 	if err := json.Unmarshal(raw, &envelope); err != nil {
 		return AssistantMessage{}, fmt.Errorf("unmarshal assistant event: %w", err)
@@ -54,6 +118,7 @@ func ParseAssistantMessage(raw []byte) (AssistantMessage, error) {
 	if len(envelope.Message.Content) == 0 {
 		return AssistantMessage{}, fmt.Errorf("assistant event has no content")
 	}
+	text, blocks := contentBlocks(envelope.Message.Content)
 
 	var modelCallID string
 	// model_call_id is absent (null or missing) for final assistant messages.
@@ -64,12 +129,150 @@ func ParseAssistantMessage(raw []byte) (AssistantMessage, error) {
 	}
 
 	return AssistantMessage{
-		Text:        envelope.Message.Content[0].Text,
+		Text:        text,
+		Blocks:      blocks,
 		ModelCallID: modelCallID,
 		IsFinal:     modelCallID == "",
+		TimestampMS: envelope.TimestampMS,
 	}, nil
 }
 
+// ParseUserMessage extracts the prompt from a user event's raw JSON, the
+// agent's echo of what it was asked. Its content blocks are read as an
+// assistant message's are; content given as a plain string is one text
+// block, and a message with no content at all is empty rather than an
+// error.
+func ParseUserMessage(raw []byte) (UserMessage, error) {
+	var envelope struct {
+		Message struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return UserMessage{}, fmt.Errorf("unmarshal user event: %w", err)
+	}
+	content := envelope.Message.Content
+	if len(content) == 0 || string(content) == "null" {
+		return UserMessage{}, nil
+	}
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return UserMessage{Text: text, Blocks: []ContentBlock{{Type: "text", Text: text}}}, nil
+	}
+	var blocks []contentJSON
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return UserMessage{}, fmt.Errorf("unmarshal user event content: %w", err)
+	}
+	text, parsed := contentBlocks(blocks)
+	return UserMessage{Text: text, Blocks: parsed}, nil
+}
+
+// contentJSON is a message content block as the agent sends it.
+type contentJSON struct {
+	Type   string `json:"type"`
+	Text   string `json:"text"`
+	URI    string `json:"uri"`
+	URL    string `json:"url"`
+	Name   string `json:"name"`
+	Source struct {
+		URL       string `json:"url"`
+		MediaType string `json:"media_type"`
+	} `json:"source"`
+}
+
+// contentBlocks returns a message's content blocks, and the text ones
+// joined, in order, by newlines.
+func contentBlocks(content []contentJSON) (string, []ContentBlock) {
+	var texts []string
+	blocks := make([]ContentBlock, 0, len(content))
+	for _, c := range content {
+		block := ContentBlock{Type: c.Type}
+		switch c.Type {
+		case "text":
+			block.Text = c.Text
+			texts = append(texts, c.Text)
+		case "image":
+			uri := cmp.Or(c.URI, c.URL, c.Source.URL)
+			if data, ok := strings.CutPrefix(uri, "data:"); ok {
+				// An inline image has no name worth showing, only its type.
+				block.Summary, _, _ = strings.Cut(data, ";")
+			} else if uri != "" {
+				block.URI, block.Summary = uri, path.Base(uri)
+			} else {
+				block.Summary = c.Source.MediaType
+			}
+		default:
+			block.Summary = c.Name
+		}
+		blocks = append(blocks, block)
+	}
+	return strings.Join(texts, "\n"), blocks
+}
+
+// ParseResult extracts a result event's fields from its raw JSON,
+// written in schema s. Older agent builds send no model, usage or error,
+// and those are left zero.
+func ParseResult(raw []byte, s Schema) (Result, error) {
+	var envelope struct {
+		Result
+		Error json.RawMessage `json:"error"`
+	}
+	if err := unmarshalAs(raw, s, &envelope); err != nil {
+		return Result{}, fmt.Errorf("unmarshal result event: %w", err)
+	}
+	r := envelope.Result
+	var text string
+	var obj struct {
+		Message string `json:"message"`
+	}
+	switch {
+	case json.Unmarshal(envelope.Error, &text) == nil:
+		r.ErrorMessage = text
+	case json.Unmarshal(envelope.Error, &obj) == nil:
+		r.ErrorMessage = obj.Message
+	}
+	return r, nil
+}
+
+// ExtractSessionID returns the session id an event carries, from any
+// event type that has one (system/init, result, tool_call and others),
+// in either generation's spelling; "" if it has none.
+func ExtractSessionID(raw []byte) string {
+	var ids struct {
+		SessionID string `json:"session_id"`
+		LegacyID  string `json:"sessionId"`
+	}
+	if json.Unmarshal(raw, &ids) != nil {
+		return ""
+	}
+	return cmp.Or(ids.SessionID, ids.LegacyID)
+}
+
+// ParseToolCallUpdate extracts a tool_call/update event's output chunk
+// from its raw JSON. The chunk is either at the top level or, as some
+// agent builds send it, in an "output" object.
+func ParseToolCallUpdate(raw []byte) (ToolCallUpdate, error) {
+	var envelope struct {
+		ToolCallUpdate
+		Output *struct {
+			Stdout string `json:"stdout"`
+			Stderr string `json:"stderr"`
+		} `json:"output"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return ToolCallUpdate{}, fmt.Errorf("unmarshal tool_call update: %w", err)
+	}
+	u := envelope.ToolCallUpdate
+	if u.CallID == "" {
+		return ToolCallUpdate{}, fmt.Errorf("tool_call update has no call_id")
+	}
+	if o := envelope.Output; o != nil {
+		u.Stdout += o.Stdout
+		u.Stderr += o.Stderr
+	}
+	return u, nil
+}
+
 // ParseToolCallInfo extracts tool type and display-relevant args from
 // the tool_call field of a started or completed event.
 func ParseToolCallInfo(toolCallJSON json.RawMessage) (ToolCallInfo, error) {
@@ -114,31 +317,139 @@ func ParseToolCallInfo(toolCallJSON json.RawMessage) (ToolCallInfo, error) {
 			return info, fmt.Errorf("unmarshal lsToolCall: %w", err)
 		}
 		info.Path = ls.Args.Path
+	case "readToolCall":
+		var read struct {
+			Args ReadToolArgs `json:"args"`
+		}
+		if err := json.Unmarshal(toolData, &read); err != nil {
+			return info, fmt.Errorf("unmarshal readToolCall: %w", err)
+		}
+		info.Path = read.Args.Path
+		info.Offset = read.Args.Offset
+		info.Limit = read.Args.Limit
+	case "writeToolCall":
+		var write struct {
+			Args WriteToolArgs `json:"args"`
+		}
+		if err := json.Unmarshal(toolData, &write); err != nil {
+			return info, fmt.Errorf("unmarshal writeToolCall: %w", err)
+		}
+		info.Path = write.Args.Path
+		info.FileBytes = len(write.Args.FileText)
+	case "grepToolCall":
+		var grep struct {
+			Args GrepToolArgs `json:"args"`
+		}
+		if err := json.Unmarshal(toolData, &grep); err != nil {
+			return info, fmt.Errorf("unmarshal grepToolCall: %w", err)
+		}
+		info.Pattern = grep.Args.Pattern
+		info.Path = grep.Args.Path
+		if grep.Args.Glob != "" {
+			info.Globs = append(info.Globs, grep.Args.Glob)
+		}
+		if grep.Args.Type != "" {
+			info.Globs = append(info.Globs, "type:"+grep.Args.Type)
+		}
+	case "editToolCall":
+		var edit struct {
+			Args EditToolArgs `json:"args"`
+		}
+		if err := json.Unmarshal(toolData, &edit); err != nil {
+			return info, fmt.Errorf("unmarshal editToolCall: %w", err)
+		}
+		info.Path = edit.Args.Path
+		info.EditSummary = editSummary(edit.Args)
+	case "deleteToolCall":
+		var del struct {
+			Args DeleteToolArgs `json:"args"`
+		}
+		if err := json.Unmarshal(toolData, &del); err != nil {
+			return info, fmt.Errorf("unmarshal deleteToolCall: %w", err)
+		}
+		info.Path = del.Args.Path
 	}
 
 	return info, nil
 }
 
+// editSummary describes a search and replace by the lines it swaps.
+func editSummary(args EditToolArgs) string {
+	if args.OldString == "" && args.NewString == "" {
+		return ""
+	}
+	summary := fmt.Sprintf("%s → %s", lineCount(args.OldString), lineCount(args.NewString))
+	if args.ReplaceAll {
+		summary += ", every occurrence"
+	}
+	return summary
+}
+
+func lineCount(s string) string {
+	n := strings.Count(strings.TrimSuffix(s, "\n"), "\n") + 1
+	if s == "" {
+		n = 0
+	}
+	if n == 1 {
+		return "1 line"
+	}
+	return fmt.Sprintf("%d lines", n)
+}
+
 // ParseShellToolResult extracts the result from a completed shellToolCall.
 func ParseShellToolResult(toolCallJSON json.RawMessage) (ShellToolResult, error) {
+	var result ShellToolResult
+	err := parseToolResult(toolCallJSON, "shellToolCall", &result)
+	return result, err
+}
+
+// ParseReadToolResult extracts the result from a completed readToolCall.
+func ParseReadToolResult(toolCallJSON json.RawMessage) (ReadToolResult, error) {
+	var result ReadToolResult
+	err := parseToolResult(toolCallJSON, "readToolCall", &result)
+	return result, err
+}
+
+// ParseWriteToolResult extracts the result from a completed writeToolCall.
+func ParseWriteToolResult(toolCallJSON json.RawMessage) (WriteToolResult, error) {
+	var result WriteToolResult
+	err := parseToolResult(toolCallJSON, "writeToolCall", &result)
+	return result, err
+}
+
+// ParseEditToolResult extracts the result from a completed editToolCall.
+func ParseEditToolResult(toolCallJSON json.RawMessage) (EditToolResult, error) {
+	var result EditToolResult
+	err := parseToolResult(toolCallJSON, "editToolCall", &result)
+	return result, err
+}
+
+// parseToolResult decodes result.success of a completed tool call of
+// type toolType into success.
+func parseToolResult(toolCallJSON json.RawMessage, toolType string, success any) error {
 	var toolCallMap map[string]json.RawMessage
 	if err := json.Unmarshal(toolCallJSON, &toolCallMap); err != nil {
-		return ShellToolResult{}, fmt.Errorf("unmarshal tool_call object: %w", err)
+		return fmt.Errorf("unmarshal tool_call object: %w", err)
 	}
 
-	shellData, ok := toolCallMap["shellToolCall"]
+	toolData, ok := toolCallMap[toolType]
 	if !ok {
-		return ShellToolResult{}, fmt.Errorf("tool_call is not a shellToolCall")
+		return fmt.Errorf("tool_call is not a %s", toolType)
 	}
 
-	var shell struct {
+	var tool struct {
 		Result struct {
-			Success ShellToolResult `json:"success"`
+			Success json.RawMessage `json:"success"`
 		} `json:"result"`
 	}
-	if err := json.Unmarshal(shellData, &shell); err != nil {
-		return ShellToolResult{}, fmt.Errorf("unmarshal shellToolCall result: %w", err)
+	if err := json.Unmarshal(toolData, &tool); err != nil {
+		return fmt.Errorf("unmarshal %s result: %w", toolType, err)
 	}
-
-	return shell.Result.Success, nil
+	if len(tool.Result.Success) == 0 {
+		return nil // no result yet, or a failed call: the zero value
+	}
+	if err := json.Unmarshal(tool.Result.Success, success); err != nil {
+		return fmt.Errorf("unmarshal %s result: %w", toolType, err)
+	}
+	return nil
 }
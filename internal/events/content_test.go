@@ -1,7 +1,10 @@
 package events
 
 import (
+	"bytes"
 	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -23,6 +26,89 @@ func TestParseAssistantMessage_MidTurn(t *testing.T) {
 	}
 }
 
+func TestParseAssistantMessage_StreamedFragments(t *testing.T) {
+	lines := bytes.Split(loadFixture(t, "assistant_streaming.jsonl"), []byte("\n"))
+	first, err := ParseAssistantMessage(lines[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ParseAssistantMessage(lines[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Text != "I'll run the" || second.Text != " tests first, then" {
+		t.Errorf("fragments = %q, %q; want them as sent, spaces and all", first.Text, second.Text)
+	}
+	if first.ModelCallID == "" || first.ModelCallID != second.ModelCallID || first.IsFinal {
+		t.Errorf("fragments don't share a model call: %+v, %+v", first, second)
+	}
+	if first.TimestampMS != 1770823845101 || second.TimestampMS <= first.TimestampMS {
+		t.Errorf("TimestampMS = %d, %d; want them in order", first.TimestampMS, second.TimestampMS)
+	}
+
+	final, err := ParseAssistantMessage(loadFixture(t, "assistant_final.json"))
+	if err != nil || final.TimestampMS != 0 {
+		t.Errorf("final message: TimestampMS = %d, %v; want 0", final.TimestampMS, err)
+	}
+}
+
+func TestParseUserMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        []byte
+		wantText   string
+		wantBlocks []ContentBlock
+	}{
+		{
+			name:       "fixture",
+			raw:        loadFixture(t, "user.json"),
+			wantText:   "Run `sleep 5` using bash, then run `sleep 3` using bash. Do nothing else.",
+			wantBlocks: []ContentBlock{{Type: "text", Text: "Run `sleep 5` using bash, then run `sleep 3` using bash. Do nothing else."}},
+		},
+		{
+			name:     "several blocks",
+			raw:      loadFixture(t, "user_multi_block.json"),
+			wantText: "Why does this render wrong?\nIt started after the last merge.",
+			wantBlocks: []ContentBlock{
+				{Type: "text", Text: "Why does this render wrong?"},
+				{Type: "image", URI: "file:///workspace/shots/broken.png", Summary: "broken.png"},
+				{Type: "text", Text: "It started after the last merge."},
+			},
+		},
+		{
+			name:       "string content",
+			raw:        []byte(`{"type":"user","message":{"role":"user","content":"fix the build"}}`),
+			wantText:   "fix the build",
+			wantBlocks: []ContentBlock{{Type: "text", Text: "fix the build"}},
+		},
+		{
+			name:       "no text",
+			raw:        []byte(`{"type":"user","message":{"content":[{"type":"image","source":{"media_type":"image/png"}}]}}`),
+			wantBlocks: []ContentBlock{{Type: "image", Summary: "image/png"}},
+		},
+		{name: "empty content", raw: []byte(`{"type":"user","message":{"content":[]}}`), wantBlocks: []ContentBlock{}},
+		{name: "no message", raw: []byte(`{"type":"user"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseUserMessage(tt.raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msg.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", msg.Text, tt.wantText)
+			}
+			if !reflect.DeepEqual(msg.Blocks, tt.wantBlocks) {
+				t.Errorf("Blocks = %+v, want %+v", msg.Blocks, tt.wantBlocks)
+			}
+		})
+	}
+
+	if _, err := ParseUserMessage([]byte(`{"type":"user","message":{"content":42}}`)); err == nil {
+		t.Error("content of the wrong type: no error")
+	}
+}
+
 func TestParseAssistantMessage_Final(t *testing.T) {
 	data := loadFixture(t, "assistant_final.json")
 	msg, err := ParseAssistantMessage(data)
@@ -41,6 +127,167 @@ func TestParseAssistantMessage_Final(t *testing.T) {
 	}
 }
 
+func TestParseAssistantMessage_MultipleBlocks(t *testing.T) {
+	data := loadFixture(t, "assistant_multi_block.json")
+	msg, err := ParseAssistantMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantText := "Both commands have finished running.\nThe first took 5 seconds and the second 3."
+	if msg.Text != wantText {
+		t.Errorf("text = %q, want %q", msg.Text, wantText)
+	}
+	if !msg.IsFinal || msg.ModelCallID != "" {
+		t.Errorf("IsFinal = %v, ModelCallID = %q; want a final message", msg.IsFinal, msg.ModelCallID)
+	}
+}
+
+func TestParseAssistantMessage_ImageOnly(t *testing.T) {
+	data := loadFixture(t, "assistant_image_only.json")
+	msg, err := ParseAssistantMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.Text != "" || msg.IsFinal {
+		t.Errorf("Text = %q, IsFinal = %v; want no text, mid-turn", msg.Text, msg.IsFinal)
+	}
+	want := []ContentBlock{{Type: "image", URI: "file:///workspace/docs/diagram.png", Summary: "diagram.png"}}
+	if !reflect.DeepEqual(msg.Blocks, want) {
+		t.Errorf("Blocks = %+v, want %+v", msg.Blocks, want)
+	}
+}
+
+func TestParseAssistantMessage_MixedContent(t *testing.T) {
+	data := loadFixture(t, "assistant_mixed_content.json")
+	msg, err := ParseAssistantMessage(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "Here is the architecture:\nThe reader feeds the monitor."; msg.Text != want {
+		t.Errorf("text = %q, want %q", msg.Text, want)
+	}
+	want := []ContentBlock{
+		{Type: "text", Text: "Here is the architecture:"},
+		{Type: "image", Summary: "image/png"}, // inline: no URI
+		{Type: "tool_use", Summary: "render_chart"},
+		{Type: "text", Text: "The reader feeds the monitor."},
+	}
+	if !reflect.DeepEqual(msg.Blocks, want) {
+		t.Errorf("Blocks = %+v, want %+v", msg.Blocks, want)
+	}
+}
+
+func TestParseResult_Shapes(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want Result
+	}{
+		{
+			name: "older build",
+			raw:  loadFixture(t, "result.json"),
+			want: Result{Subtype: "success", DurationMS: 21509, SessionID: "d43015b9-0707-43f4-b2df-0bcea7891654", RequestID: "5bf03e32-be64-48d4-a5ed-c4a0a939b88c"},
+		},
+		{
+			name: "usage and model",
+			raw:  loadFixture(t, "result_with_usage.json"),
+			want: Result{
+				Subtype: "success", DurationMS: 8120, SessionID: "d43015b9-0707-43f4-b2df-0bcea7891654", RequestID: "5bf03e32-be64-48d4-a5ed-c4a0a939b88c",
+				Model: "claude-4-sonnet",
+				Usage: &Usage{InputTokens: 12034, OutputTokens: 981, CacheReadTokens: 10240, CacheWriteTokens: 1536},
+			},
+		},
+		{
+			name: "error object",
+			raw:  loadFixture(t, "result_error.json"),
+			want: Result{
+				Subtype: "error", DurationMS: 1402, IsError: true, SessionID: "d43015b9-0707-43f4-b2df-0bcea7891654", RequestID: "9a1c77e0-5d2b-4a51-8f0e-2b4e6c1f7d33",
+				Model: "gpt-5", ErrorMessage: "Rate limit exceeded, try again in 30s",
+			},
+		},
+		{
+			name: "error string",
+			raw:  []byte(`{"type":"result","subtype":"error","is_error":true,"error":"model not available"}`),
+			want: Result{Subtype: "error", IsError: true, ErrorMessage: "model not available"},
+		},
+		{
+			name: "error of another shape",
+			raw:  []byte(`{"type":"result","subtype":"error","is_error":true,"error":42}`),
+			want: Result{Subtype: "error", IsError: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResult(tt.raw, SchemaCurrent)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v (usage %+v), want %+v (usage %+v)", got, got.Usage, tt.want, tt.want.Usage)
+			}
+		})
+	}
+	if _, err := ParseResult([]byte(`{not json`), SchemaCurrent); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestExtractSessionID(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{"init", loadFixture(t, "system_init.json"), "d43015b9-0707-43f4-b2df-0bcea7891654"},
+		{"result", loadFixture(t, "result.json"), "d43015b9-0707-43f4-b2df-0bcea7891654"},
+		{"tool call", loadFixture(t, "tool_call_started.json"), "d43015b9-0707-43f4-b2df-0bcea7891654"},
+		{"camelCase", []byte(`{"type":"result","subtype":"success","sessionId":"s-legacy"}`), "s-legacy"},
+		{"none", []byte(`{"type":"thinking","subtype":"completed"}`), ""},
+		{"not json", []byte(`T: Named models unavailable`), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractSessionID(tt.raw); got != tt.want {
+				t.Errorf("ExtractSessionID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseToolCallUpdate_InterleavedCalls(t *testing.T) {
+	output := map[string]string{} // call_id to its stdout and stderr, in order
+	for i, line := range bytes.Split(loadFixture(t, "tool_call_streaming.jsonl"), []byte("\n")) {
+		var ev RawEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if !IsToolCallUpdate(ev.Subtype) {
+			continue
+		}
+		u, err := ParseToolCallUpdate(line)
+		if err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if u.ModelCallID != "mc_7" || u.TimestampMS == 0 {
+			t.Errorf("line %d: model_call_id %q, timestamp_ms %d", i, u.ModelCallID, u.TimestampMS)
+		}
+		output[u.CallID] += u.Stdout + u.Stderr
+	}
+	want := map[string]string{
+		"toolu_01\nbuild": "# cursor-wrap/cmd/cursor-wrap\n",
+		"toolu_02\ntest":  "ok  \tcursor-wrap/internal/events\t0.412s\nok  \tcursor-wrap/internal/format\t0.019s\nok  \tcursor-wrap/internal/monitor\t8.950s\n",
+	}
+	if !reflect.DeepEqual(output, want) {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+}
+
+func TestParseToolCallUpdate_NoCallID(t *testing.T) {
+	if _, err := ParseToolCallUpdate([]byte(`{"type":"tool_call","subtype":"update","stdout":"x"}`)); err == nil {
+		t.Error("expected an error for an update without call_id")
+	}
+}
+
 func TestParseAssistantMessage_InvalidJSON(t *testing.T) {
 	_, err := ParseAssistantMessage([]byte(`{not json`))
 	if err == nil {
@@ -93,13 +340,116 @@ func TestParseToolCallInfo_LsTool(t *testing.T) {
 }
 
 func TestParseToolCallInfo_UnknownTool(t *testing.T) {
-	toolCall := json.RawMessage(`{"grepToolCall":{"args":{"pattern":"foo"}}}`)
+	toolCall := json.RawMessage(`{"updateTodosToolCall":{"args":{"todos":[{"content":"foo"}]}}}`)
 	info, err := ParseToolCallInfo(toolCall)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if info.ToolType != "grepToolCall" {
-		t.Errorf("tool type = %q, want grepToolCall", info.ToolType)
+	if !reflect.DeepEqual(info, ToolCallInfo{ToolType: "updateTodosToolCall"}) {
+		t.Errorf("info = %+v, want just the tool type", info)
+	}
+}
+
+// fixtureToolCall returns the tool_call field of a tool_call event fixture.
+func fixtureToolCall(t *testing.T, name string) json.RawMessage {
+	t.Helper()
+	var ev ToolCallStarted
+	if err := json.Unmarshal(loadFixture(t, name), &ev); err != nil {
+		t.Fatalf("unmarshal %s: %v", name, err)
+	}
+	return ev.ToolCall
+}
+
+func TestParseToolCallInfo_FileTools(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    ToolCallInfo
+	}{
+		{"read_tool_call_completed.json", ToolCallInfo{ToolType: "readToolCall", Path: "/home/dev/project/go.mod", Offset: 1, Limit: 40}},
+		{"write_tool_call_completed.json", ToolCallInfo{ToolType: "writeToolCall", Path: "/home/dev/project/NOTES.md", FileBytes: 31}},
+		{"grep_tool_call_started.json", ToolCallInfo{ToolType: "grepToolCall", Path: "internal/events", Pattern: `func Parse\w+`, Globs: []string{"*.go"}}},
+		{"edit_tool_call_completed.json", ToolCallInfo{ToolType: "editToolCall", Path: "/home/dev/project/main.go", EditSummary: "1 line → 2 lines"}},
+		{"delete_tool_call_started.json", ToolCallInfo{ToolType: "deleteToolCall", Path: "/home/dev/project/NOTES.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.ToolType, func(t *testing.T) {
+			info, err := ParseToolCallInfo(fixtureToolCall(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(info, tt.want) {
+				t.Errorf("info = %+v\nwant   %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseToolCallInfo_GrepTypeFilter(t *testing.T) {
+	toolCall := json.RawMessage(`{"grepToolCall":{"args":{"pattern":"TODO","type":"go"}}}`)
+	info, err := ParseToolCallInfo(toolCall)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Pattern != "TODO" || info.Path != "" || !reflect.DeepEqual(info.Globs, []string{"type:go"}) {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestEditSummary(t *testing.T) {
+	tests := []struct {
+		args EditToolArgs
+		want string
+	}{
+		{EditToolArgs{OldString: "a", NewString: "b"}, "1 line → 1 line"},
+		{EditToolArgs{OldString: "a\nb\nc\n", NewString: ""}, "3 lines → 0 lines"},
+		{EditToolArgs{OldString: "x", NewString: "y\nz", ReplaceAll: true}, "1 line → 2 lines, every occurrence"},
+		{EditToolArgs{}, ""},
+	}
+	for _, tt := range tests {
+		if got := editSummary(tt.args); got != tt.want {
+			t.Errorf("editSummary(%+v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseReadToolResult(t *testing.T) {
+	result, err := ParseReadToolResult(fixtureToolCall(t, "read_tool_call_completed.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (ReadToolResult{TotalLines: 3, TotalChars: 29}); result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+}
+
+func TestParseWriteToolResult(t *testing.T) {
+	result, err := ParseWriteToolResult(fixtureToolCall(t, "write_tool_call_completed.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (WriteToolResult{Path: "/home/dev/project/NOTES.md", LinesCreated: 3, FileSize: 31}); result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+}
+
+func TestParseEditToolResult(t *testing.T) {
+	result, err := ParseEditToolResult(fixtureToolCall(t, "edit_tool_call_completed.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Path != "/home/dev/project/main.go" || result.LinesAdded != 2 || result.LinesRemoved != 1 ||
+		!strings.HasPrefix(result.DiffString, "@@ -4,1 +4,2 @@") {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestParseToolResult_WrongToolOrNoResult(t *testing.T) {
+	if _, err := ParseReadToolResult(fixtureToolCall(t, "write_tool_call_completed.json")); err == nil {
+		t.Error("expected error reading a writeToolCall as a readToolCall")
+	}
+	result, err := ParseEditToolResult(json.RawMessage(`{"editToolCall":{"args":{"path":"main.go"}}}`))
+	if err != nil || result != (EditToolResult{}) {
+		t.Errorf("started edit: result = %+v, err = %v; want the zero value", result, err)
 	}
 }
 
@@ -0,0 +1,98 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// decoding is an event's Decode result, shared by the copies of the
+// event so that the monitor, the formatter and the rest each don't
+// unmarshal it again.
+type decoding struct {
+	once sync.Once
+	v    any
+	err  error
+}
+
+// Decode returns ev's payload as the struct for its type and subtype:
+//
+//	system/init                            SystemInit
+//	thinking/delta                         ThinkingDelta
+//	user                                   UserMessage
+//	assistant                              AssistantMessage
+//	tool_call/started                      ToolCallStarted
+//	tool_call/update, tool_call/delta      ToolCallUpdate
+//	tool_call/completed                    ToolCallCompleted
+//	result                                 Result
+//	wrapper_nonjson, wrapper_partial_line  WrapperNonJSON
+//	wrapper/truncated_event                TruncatedEvent
+//
+// Events with nothing more to them than their type, such as
+// thinking/completed, and types this package doesn't know decode to nil,
+// without an error; an event that can't be decoded, to nil and the
+// error. Events from a Reader are decoded once, however many
+// times, and from however many copies, Decode is called.
+func Decode(ev AnnotatedEvent) (any, error) {
+	d := ev.decoded
+	if d == nil {
+		return decode(ev)
+	}
+	d.once.Do(func() { d.v, d.err = decode(ev) })
+	return d.v, d.err
+}
+
+func decode(ev AnnotatedEvent) (any, error) {
+	v, err := decodePayload(ev)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func decodePayload(ev AnnotatedEvent) (any, error) {
+	switch ev.Parsed.Type {
+	case "system":
+		if ev.Parsed.Subtype == "init" {
+			return ParseSystemInit(ev.Raw, ev.Schema)
+		}
+	case "thinking":
+		if ev.Parsed.Subtype == "delta" {
+			var delta ThinkingDelta
+			if err := json.Unmarshal(ev.Raw, &delta); err != nil {
+				return nil, fmt.Errorf("unmarshal thinking delta: %w", err)
+			}
+			return delta, nil
+		}
+	case "user":
+		return ParseUserMessage(ev.Raw)
+	case "assistant":
+		return ParseAssistantMessage(ev.Raw)
+	case "tool_call":
+		switch sub := ev.Parsed.Subtype; {
+		case sub == "started":
+			return ParseToolCallStarted(ev.Raw, ev.Schema)
+		case sub == "completed":
+			return ParseToolCallCompleted(ev.Raw, ev.Schema)
+		case IsToolCallUpdate(sub):
+			return ParseToolCallUpdate(ev.Raw)
+		}
+	case "result":
+		return ParseResult(ev.Raw, ev.Schema)
+	case TypeWrapper:
+		if ev.Parsed.Subtype == SubtypeTruncatedEvent {
+			var truncated TruncatedEvent
+			if err := json.Unmarshal(ev.Raw, &truncated); err != nil {
+				return nil, fmt.Errorf("unmarshal truncated event: %w", err)
+			}
+			return truncated, nil
+		}
+	case TypeWrapperNonJSON, TypePartialLine:
+		var line WrapperNonJSON
+		if err := json.Unmarshal(ev.Raw, &line); err != nil {
+			return nil, fmt.Errorf("unmarshal %s event: %w", ev.Parsed.Type, err)
+		}
+		return line, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode_KnownTypes(t *testing.T) {
+	streamed := bytes.Split(loadFixture(t, "tool_call_streaming.jsonl"), []byte("\n"))
+	tests := []struct {
+		name string
+		raw  []byte
+		want string // the payload's type
+	}{
+		{"system/init", loadFixture(t, "system_init.json"), "events.SystemInit"},
+		{"thinking/delta", loadFixture(t, "thinking_delta.json"), "events.ThinkingDelta"},
+		{"user", loadFixture(t, "user.json"), "events.UserMessage"},
+		{"assistant", loadFixture(t, "assistant_final.json"), "events.AssistantMessage"},
+		{"tool_call/started", loadFixture(t, "tool_call_started.json"), "events.ToolCallStarted"},
+		{"tool_call/update", streamed[2], "events.ToolCallUpdate"},
+		{"tool_call/delta", []byte(`{"type":"tool_call","subtype":"delta","call_id":"c1","stdout":"x"}`), "events.ToolCallUpdate"},
+		{"tool_call/completed", loadFixture(t, "tool_call_completed.json"), "events.ToolCallCompleted"},
+		{"result", loadFixture(t, "result.json"), "events.Result"},
+		{"wrapper_nonjson", NonJSONEvent(time.Now(), []byte("T: Named models unavailable")).Raw, "events.WrapperNonJSON"},
+		{"wrapper_partial_line", PartialLineEvent(time.Now(), []byte(`{"type":"res`)).Raw, "events.WrapperNonJSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := readOne(t, tt.raw)
+			v, err := Decode(ev)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got := fmt.Sprintf("%T", v); got != tt.want {
+				t.Errorf("Decode = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode_Fields(t *testing.T) {
+	v, err := Decode(readOne(t, loadFixture(t, "tool_call_started.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started := v.(ToolCallStarted); started.TimestampMS != 1770823845357 || !strings.HasPrefix(started.CallID, "call_PFAupCWibROFKbdASSdiHCoi") {
+		t.Errorf("ToolCallStarted = %+v", started)
+	}
+	// Decoding follows the stream's schema.
+	lines := bytes.Split(loadFixture(t, "schema_v1.jsonl"), []byte("\n"))
+	out := make(chan AnnotatedEvent, len(lines))
+	Reader(context.Background(), bytes.NewReader(bytes.Join(lines, []byte("\n"))), out, make(chan error, 1))
+	var results []Result
+	for ev := range out {
+		if v, err := Decode(ev); err == nil {
+			if r, ok := v.(Result); ok {
+				results = append(results, r)
+			}
+		}
+	}
+	if len(results) != 1 || results[0].DurationMS != 9800 {
+		t.Errorf("v1 results = %+v", results)
+	}
+}
+
+func TestDecode_Unknown(t *testing.T) {
+	for _, raw := range []string{
+		`{"type":"thinking","subtype":"completed"}`,
+		`{"type":"system","subtype":"compact"}`,
+		`{"type":"tool_call","subtype":"cancelled","call_id":"c1"}`,
+		`{"type":"hologram","subtype":"projected"}`,
+	} {
+		v, err := Decode(readOne(t, []byte(raw)))
+		if v != nil || err != nil {
+			t.Errorf("Decode(%s) = %v, %v; want nil, nil", raw, v, err)
+		}
+	}
+}
+
+func TestDecode_Error(t *testing.T) {
+	// The assistant event has no content: an error, and no payload.
+	v, err := Decode(readOne(t, []byte(`{"type":"assistant","message":{}}`)))
+	if v != nil || err == nil {
+		t.Errorf("Decode = %v, %v; want nil and an error", v, err)
+	}
+}
+
+func TestDecode_SharedBetweenCopies(t *testing.T) {
+	ev := readOne(t, loadFixture(t, "result.json"))
+	cp := ev
+	first, _ := Decode(cp)
+	if ev.decoded == nil || ev.decoded.v == nil {
+		t.Fatal("decoding a copy didn't fill the event's shared decoding")
+	}
+	// Spoil the raw JSON: a second Decode must not look at it.
+	ev.Raw = []byte(`{}`)
+	if second, err := Decode(ev); err != nil || second.(Result) != first.(Result) {
+		t.Errorf("second Decode = %+v, %v; want the first's %+v", second, err, first)
+	}
+	// Events not from a Reader are decoded every time.
+	if v, err := Decode(AnnotatedEvent{Raw: []byte(`{"type":"result","duration_ms":5}`), Parsed: RawEvent{Type: "result"}}); err != nil || v.(Result).DurationMS != 5 {
+		t.Errorf("Decode of a built event = %+v, %v", v, err)
+	}
+}
+
+// readOne passes raw through a Reader and returns its one event.
+func readOne(t *testing.T, raw []byte) AnnotatedEvent {
+	t.Helper()
+	out := make(chan AnnotatedEvent, 2)
+	Reader(context.Background(), bytes.NewReader(append(bytes.Clone(raw), '\n')), out, make(chan error, 1))
+	var got []AnnotatedEvent
+	for ev := range out {
+		got = append(got, ev)
+	}
+	if len(got) != 1 {
+		t.Fatalf("read %d events from %s, want 1", len(got), raw)
+	}
+	return got[0]
+}
@@ -0,0 +1,179 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxStagedBytes caps the events Reader holds while its out channel is
+// full. Past it, Reader stops reading and the agent's stdout pipe backs
+// up, as it would with no staging at all. A variable so tests can lower
+// it.
+var maxStagedBytes = 64 << 20
+
+// ReaderOption configures Reader.
+type ReaderOption func(*readerOptions)
+
+type readerOptions struct {
+	stats         *QueueStats
+	turn          int
+	now           func() time.Time
+	maxEventBytes int
+}
+
+// WithQueueStats makes Reader record in s how its consumer keeps up.
+func WithQueueStats(s *QueueStats) ReaderOption {
+	return func(o *readerOptions) {
+		o.stats = s
+	}
+}
+
+// QueueStats measures the backlog between Reader and the consumer of its
+// out channel. Reader updates it while the consumer reads it, so it is
+// safe for concurrent use. The zero value is ready to use.
+type QueueStats struct {
+	mu           sync.Mutex
+	maxDepth     int
+	blocked      time.Duration
+	blockedSince time.Time // zero unless a send on out is waiting now
+}
+
+// MaxDepth returns the most events that were waiting for the consumer at
+// once: those in the out channel and those staged behind it.
+func (s *QueueStats) MaxDepth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxDepth
+}
+
+// Blocked returns how long, in total, an event has waited on a full out
+// channel, including a wait still going on.
+func (s *QueueStats) Blocked() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.blockedSince.IsZero() {
+		return s.blocked
+	}
+	return s.blocked + time.Since(s.blockedSince)
+}
+
+// BlockedSince returns when the out channel became full, if an event is
+// waiting on it now, and the zero time otherwise.
+func (s *QueueStats) BlockedSince() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blockedSince
+}
+
+func (s *QueueStats) depth(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxDepth = max(s.maxDepth, n)
+}
+
+func (s *QueueStats) block(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blockedSince = now
+}
+
+func (s *QueueStats) unblock(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blocked += now.Sub(s.blockedSince)
+	s.blockedSince = time.Time{}
+}
+
+// stage is the unbounded-by-count, bounded-by-bytes FIFO between Reader's
+// read loop and its sends on out. Events are stamped as they are read and
+// wait here, so a slow consumer delays their delivery but not their
+// RecvTime, and the agent isn't stalled on a full pipe.
+type stage struct {
+	mu       sync.Mutex
+	queue    []AnnotatedEvent
+	bytes    int
+	maxBytes int
+	done     bool
+	err      error         // the read error that ended the stream, if any
+	ready    chan struct{} // signalled when an event is queued or done is set
+	space    chan struct{} // signalled when an event is taken
+}
+
+func newStage(maxBytes int) *stage {
+	return &stage{
+		maxBytes: maxBytes,
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+	}
+}
+
+// push queues ev, waiting for room if the stage is full. An event larger
+// than the whole stage is let in once the stage is empty. It reports
+// false if ctx was cancelled first.
+func (s *stage) push(ctx context.Context, ev AnnotatedEvent) bool {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 || s.bytes+len(ev.Raw) <= s.maxBytes {
+			s.queue = append(s.queue, ev)
+			s.bytes += len(ev.Raw)
+			s.mu.Unlock()
+			signal(s.ready)
+			return true
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.space:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// finish marks the end of the stream, err being the read error that
+// ended it, if any.
+func (s *stage) finish(err error) {
+	s.mu.Lock()
+	s.done, s.err = true, err
+	s.mu.Unlock()
+	signal(s.ready)
+}
+
+// pop takes the oldest event, waiting for one. With the stream finished
+// and nothing left, it returns ok false and the read error, if any; it
+// also returns ok false if ctx is cancelled. n is how many events are
+// still staged.
+func (s *stage) pop(ctx context.Context) (ev AnnotatedEvent, n int, ok bool, err error) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			ev = s.queue[0]
+			s.queue[0] = AnnotatedEvent{}
+			s.queue = s.queue[1:]
+			s.bytes -= len(ev.Raw)
+			n = len(s.queue)
+			s.mu.Unlock()
+			signal(s.space)
+			return ev, n, true, nil
+		}
+		done, err := s.done, s.err
+		s.mu.Unlock()
+		if done {
+			return AnnotatedEvent{}, 0, false, err
+		}
+		select {
+		case <-s.ready:
+		case <-ctx.Done():
+			return AnnotatedEvent{}, 0, false, nil
+		}
+	}
+}
+
+// signal wakes whoever waits on ch, if anyone; a wake-up already pending
+// covers this one.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
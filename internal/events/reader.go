@@ -2,66 +2,328 @@ package events
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
+	"os"
 	"time"
 )
 
-// Reader reads from an io.Reader and emits AnnotatedEvents on a channel.
-// It closes the out channel when the reader hits EOF or the context is
-// cancelled, signaling downstream that the stream is done. Any fatal
-// read error (not EOF, not context cancellation) is sent on errCh
-// before closing out.
-func Reader(ctx context.Context, r io.Reader, out chan<- AnnotatedEvent, errCh chan<- error) {
+// maxLineBytes caps a single line of the agent's output. Far above any
+// real event (a tool result holding a whole file runs to megabytes), it
+// only stops a runaway line from taking all memory: the rest of a longer
+// line is discarded, and the line dropped with a warning. A variable so
+// tests can lower it.
+var maxLineBytes = 256 << 20
+
+// Reader reads from an io.Reader and emits AnnotatedEvents on a channel,
+// a wrapper_nonjson event for each line that isn't JSON (see NonJSONEvent),
+// and a wrapper_partial_line event for an event the output ends part way
+// through (see PartialLineEvent).
+// Lines may be of any length up to maxLineBytes, or WithMaxEventBytes'
+// limit, past which they are cut short; memory use follows the longest.
+// Events are stamped as they are read and staged, up to maxStagedBytes,
+// while out is full, so a slow consumer doesn't skew RecvTime or stall
+// the agent. It closes the out channel when the reader hits EOF or the
+// context is cancelled, signaling downstream that the stream is done.
+// Any fatal read error (not EOF, not context cancellation) is sent on
+// errCh, after the events read before it, and before closing out.
+func Reader(ctx context.Context, r io.Reader, out chan<- AnnotatedEvent, errCh chan<- error, opts ...ReaderOption) {
 	defer close(out)
+	o := readerOptions{stats: &QueueStats{}, now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	scanner := bufio.NewScanner(r)
-	// Increase max line size to handle large JSON events (e.g. tool results).
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	st := newStage(maxStagedBytes)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		st.finish(readLines(ctx, r, st, o))
+	}()
+	defer func() { <-readDone }()
 
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
+	for {
+		ev, staged, ok, err := st.pop(ctx)
+		if !ok {
+			// Fatal read error (e.g. broken pipe). Not EOF, not context
+			// cancellation, and not the caller closing r to stop reading.
+			if err != nil && ctx.Err() == nil && !errors.Is(err, os.ErrClosed) {
+				select {
+				case errCh <- err:
+				default:
+				}
+			}
+			return
+		}
+		if !send(ctx, out, ev, staged, o.stats) {
 			return
-		default:
 		}
+	}
+}
 
-		now := time.Now()
+// WithTurn makes Reader mark its events as the given turn's.
+func WithTurn(turn int) ReaderOption {
+	return func(o *readerOptions) {
+		o.turn = turn
+	}
+}
 
-		// Copy the raw bytes — scanner reuses its buffer.
-		line := make([]byte, len(scanner.Bytes()))
-		copy(line, scanner.Bytes())
+// WithMaxEventBytes makes Reader put a wrapper/truncated_event event (see
+// TruncatedEvent) in place of each line over n bytes, so one enormous
+// tool result doesn't have to be held, logged and passed on whole. Only
+// the line's first n bytes are kept while it is read, and the first
+// truncatedHeadBytes of those are in the event's Head. 0 disables the
+// limit, leaving maxLineBytes, past which lines are dropped.
+func WithMaxEventBytes(n int) ReaderOption {
+	return func(o *readerOptions) {
+		o.maxEventBytes = n
+	}
+}
 
-		var parsed RawEvent
-		if err := json.Unmarshal(line, &parsed); err != nil {
-			// Non-JSON line (e.g. "T: Named models unavailable") — skip gracefully.
-			slog.Warn("skipping non-JSON line", "line", string(line), "error", err)
-			continue
+// truncatedHeadBytes is how much of an event cut short for its size is
+// kept in its stand-in's Head.
+const truncatedHeadBytes = 64 << 10
+
+// WithClock makes Reader stamp events with the time now returns instead
+// of the wall clock's.
+func WithClock(now func() time.Time) ReaderOption {
+	return func(o *readerOptions) {
+		o.now = now
+	}
+}
+
+// readLines reads r line by line into st, numbering the events, marking
+// them as o.turn's and with the schema of the stream, until EOF, a read
+// error, which it returns, or ctx is cancelled.
+func readLines(ctx context.Context, r io.Reader, st *stage, o readerOptions) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var seq int64
+	var schema Schema
+	push := func(ev AnnotatedEvent) bool {
+		seq++
+		if ev.Parsed.Type == "system" && ev.Parsed.Subtype == "init" {
+			schema, _ = DetectSchema(ev.Raw) // SchemaUnknown on error; the caller logs it
+		}
+		ev.Seq, ev.Turn, ev.Schema = seq, o.turn, schema
+		ev.decoded = &decoding{}
+		return st.push(ctx, ev)
+	}
+	limit := maxLineBytes
+	if o.maxEventBytes > 0 {
+		limit = min(limit, o.maxEventBytes)
+	}
+	for {
+		line, size, err := readLine(br, limit)
+		if err != nil && !errors.Is(err, io.EOF) {
+			// The unterminated rest of the line is an event cut short,
+			// not one the agent finished writing: it is passed on as
+			// such, for the log.
+			if len(line) > 0 && size == len(line) && ctx.Err() == nil {
+				push(PartialLineEvent(o.now(), line))
+			}
+			return err
 		}
-		parsed.Line = line
 
-		ev := AnnotatedEvent{
-			RecvTime: now,
-			Raw:      line,
-			Parsed:   parsed,
+		if size > 0 {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if size > len(line) && o.maxEventBytes > 0 {
+				if !push(truncatedEvent(o.now(), line, size)) {
+					return nil
+				}
+			} else if size > len(line) {
+				slog.Warn("dropping an agent output line over the size limit", "bytes", size, "limit", maxLineBytes, "start", string(line[:min(len(line), 256)]))
+			} else if ev, ok := annotate(line, o.now()); ok {
+				if ev.Parsed.Type == TypeWrapperNonJSON && bytes.HasPrefix(line, []byte("{")) {
+					// Events run together on one line, as some agent builds
+					// write them under load, are passed on one by one.
+					objs, rest := splitObjects(line)
+					for _, obj := range objs {
+						objEv, _ := annotate(obj, ev.RecvTime)
+						if !push(objEv) {
+							return nil
+						}
+					}
+					if len(objs) > 0 {
+						slog.Debug("split a line of events run together", "events", len(objs), "rest_bytes", len(rest))
+						line = rest
+						ev, ok = annotate(rest, ev.RecvTime)
+					}
+				}
+				if ok && err != nil && ev.Parsed.Type == TypeWrapperNonJSON && bytes.HasPrefix(line, []byte("{")) {
+					// The output ended part way through an event.
+					ev = PartialLineEvent(ev.RecvTime, line)
+				}
+				if ok && !push(ev) {
+					return nil
+				}
+			}
 		}
 
-		select {
-		case out <- ev:
-		case <-ctx.Done():
-			return
+		if err != nil { // EOF, after a last line without a newline
+			return nil
 		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		// Fatal read error (e.g. broken pipe). Not EOF, not context cancellation.
-		if ctx.Err() == nil {
-			select {
-			case errCh <- err:
-			default:
-			}
+// send delivers ev on out, recording in stats how many events were
+// waiting, staged ones included, and how long out was full. It reports
+// whether ev was sent before ctx was cancelled.
+func send(ctx context.Context, out chan<- AnnotatedEvent, ev AnnotatedEvent, staged int, stats *QueueStats) bool {
+	stats.depth(len(out) + staged + 1)
+	select {
+	case out <- ev:
+		return true
+	default:
+	}
+	stats.block(time.Now())
+	defer func() { stats.unblock(time.Now()) }()
+	select {
+	case out <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// readLine reads the next line, without its line ending. It returns at
+// most limit bytes of it, and the line's full size in bytes; the line is
+// a new slice, not the reader's buffer. As with ReadBytes, a line is
+// returned with the error that ended it short.
+func readLine(br *bufio.Reader, limit int) (line []byte, size int, err error) {
+	var last [2]byte // the line's last two bytes, kept or not
+	for {
+		var chunk []byte
+		chunk, err = br.ReadSlice('\n')
+		size += len(chunk)
+		if room := limit - len(line); room > 0 {
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+		for _, b := range chunk[max(len(chunk)-2, 0):] {
+			last = [2]byte{last[1], b}
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			break
+		}
+	}
+	end := last[1]
+	if err == nil {
+		size--
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		end = last[0]
+	}
+	if end == '\r' && size > 0 {
+		size--
+		line = line[:min(len(line), size)]
+	}
+	return line, size, err
+}
+
+// truncatedEvent makes the wrapper/truncated_event event standing for
+// the size-byte line that starts with head.
+func truncatedEvent(recv time.Time, head []byte, size int) AnnotatedEvent {
+	head = head[:min(len(head), truncatedHeadBytes)]
+	t := TruncatedEvent{Type: TypeWrapper, Subtype: SubtypeTruncatedEvent, OriginalBytes: size}
+	t.OriginalType, t.OriginalSubtype, t.CallID = headFields(head)
+	slog.Warn("truncating an agent event over the size limit", "bytes", size, "original_type", t.OriginalType, "original_subtype", t.OriginalSubtype)
+	raw, _ := json.Marshal(t) // strings and an int can't fail
+	ev, _ := annotate(raw, recv)
+	ev.Head = head
+	return ev
+}
+
+// headFields reads the type, subtype and call id off the start of an
+// event cut short, from those of its top-level fields that come before
+// the cut. The agent writes these first.
+func headFields(head []byte) (typ, subtype, callID string) {
+	dec := json.NewDecoder(bytes.NewReader(head))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return "", "", ""
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			break
+		}
+		var v json.RawMessage
+		if dec.Decode(&v) != nil {
+			break
+		}
+		var s string
+		if json.Unmarshal(v, &s) != nil {
+			continue
+		}
+		switch key {
+		case "type":
+			typ = s
+		case "subtype":
+			subtype = s
+		case "call_id", "callId":
+			callID = s
+		}
+	}
+	return typ, subtype, callID
+}
+
+// splitObjects splits a line that starts with JSON objects run together
+// into each object's exact bytes, and rest, what follows the last one
+// that is complete (with leading space trimmed). A line that doesn't
+// start with a complete object yields none.
+func splitObjects(line []byte) (objs [][]byte, rest []byte) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	var end int64
+	for {
+		var v json.RawMessage
+		if dec.Decode(&v) != nil || v[0] != '{' {
+			break
+		}
+		start := end + int64(len(line[end:])-len(bytes.TrimLeft(line[end:], " \t")))
+		end = dec.InputOffset()
+		objs = append(objs, line[start:end])
+	}
+	return objs, bytes.TrimLeft(line[end:], " \t")
+}
+
+// annotate stamps the event on line as received at now, and with the
+// agent's own timestamp and the lag behind it, if it has one. Empty lines
+// carry nothing, and ok is false for them.
+func annotate(line []byte, now time.Time) (ev AnnotatedEvent, ok bool) {
+	if len(line) == 0 {
+		return AnnotatedEvent{}, false
+	}
+
+	var parsed struct {
+		RawEvent
+		// Raw, so a timestamp of an unexpected type doesn't fail the
+		// event; spelled as each schema generation does.
+		TimestampMS       json.RawMessage `json:"timestamp_ms"`
+		LegacyTimestampMS json.RawMessage `json:"timestampMs"`
+		TSMS              json.RawMessage `json:"ts_ms"`
+	}
+	if err := json.Unmarshal(line, &parsed); err != nil {
+		// Non-JSON line (e.g. "T: Named models unavailable"): often
+		// the explanation for what follows, so it is passed on.
+		slog.Debug("non-JSON line", "line", string(line), "error", err)
+		return NonJSONEvent(now, line), true
+	}
+	parsed.Line = line
+	ev = AnnotatedEvent{
+		RecvTime: now,
+		Raw:      line,
+		Parsed:   parsed.RawEvent,
+	}
+	for _, ts := range []json.RawMessage{parsed.TimestampMS, parsed.LegacyTimestampMS, parsed.TSMS} {
+		var ms int64
+		if ts != nil && json.Unmarshal(ts, &ms) == nil && ms > 0 {
+			ev.AgentTime = time.UnixMilli(ms)
+			ev.Lag = now.Sub(ev.AgentTime)
+			break
 		}
 	}
+	return ev, true
 }
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -272,10 +275,10 @@ func TestReader_ClosesOnEOF(t *testing.T) {
 	}
 }
 
-func TestReader_SkipsNonJSONLines(t *testing.T) {
+func TestReader_WrapsNonJSONLines(t *testing.T) {
 	input := "T: Named models unavailable on free plan\n" +
 		`{"type":"system","subtype":"init"}` + "\n" +
-		"another non-json line\n" +
+		"another <non-json> line\n" +
 		`{"type":"result","subtype":"success"}` + "\n"
 
 	r := strings.NewReader(input)
@@ -290,19 +293,34 @@ func TestReader_SkipsNonJSONLines(t *testing.T) {
 		events = append(events, ev)
 	}
 
-	// Only the 2 valid JSON lines should come through
-	if len(events) != 2 {
-		t.Fatalf("got %d events, want 2", len(events))
+	// Every line comes through, the non-JSON ones as wrapper_nonjson.
+	wantTypes := []string{TypeWrapperNonJSON, "system", TypeWrapperNonJSON, "result"}
+	if len(events) != len(wantTypes) {
+		t.Fatalf("got %d events, want %d", len(events), len(wantTypes))
 	}
-	if events[0].Parsed.Type != "system" {
-		t.Errorf("first event type = %q, want system", events[0].Parsed.Type)
+	for i, want := range wantTypes {
+		if events[i].Parsed.Type != want {
+			t.Errorf("event %d type = %q, want %q", i, events[i].Parsed.Type, want)
+		}
 	}
-	if events[1].Parsed.Type != "result" {
-		t.Errorf("second event type = %q, want result", events[1].Parsed.Type)
+	for i, wantText := range map[int]string{0: "T: Named models unavailable on free plan", 2: "another <non-json> line"} {
+		var w WrapperNonJSON
+		if err := json.Unmarshal(events[i].Raw, &w); err != nil {
+			t.Fatalf("event %d Raw is not JSON: %s", i, events[i].Raw)
+		}
+		if w.Type != TypeWrapperNonJSON || w.Text != wantText {
+			t.Errorf("event %d = %+v, want text %q", i, w, wantText)
+		}
+		if events[i].RecvTime.IsZero() || string(events[i].Parsed.Line) != string(events[i].Raw) {
+			t.Errorf("event %d = %+v", i, events[i])
+		}
+	}
+	if !strings.Contains(string(events[2].Raw), "<non-json>") {
+		t.Errorf("Raw = %s, want the text unescaped", events[2].Raw)
 	}
 }
 
-func TestReader_SkipsMalformedJSON(t *testing.T) {
+func TestReader_WrapsMalformedJSON(t *testing.T) {
 	input := `{not valid json}` + "\n" +
 		`{"type":"user"}` + "\n"
 
@@ -318,11 +336,14 @@ func TestReader_SkipsMalformedJSON(t *testing.T) {
 		events = append(events, ev)
 	}
 
-	if len(events) != 1 {
-		t.Fatalf("got %d events, want 1", len(events))
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
 	}
-	if events[0].Parsed.Type != "user" {
-		t.Errorf("event type = %q, want user", events[0].Parsed.Type)
+	if events[0].Parsed.Type != TypeWrapperNonJSON {
+		t.Errorf("event type = %q, want %s", events[0].Parsed.Type, TypeWrapperNonJSON)
+	}
+	if events[1].Parsed.Type != "user" {
+		t.Errorf("event type = %q, want user", events[1].Parsed.Type)
 	}
 }
 
@@ -425,8 +446,7 @@ func TestReader_PreservesRawBytes(t *testing.T) {
 }
 
 func TestReader_RawBytesPreservedForParseFailures(t *testing.T) {
-	// Non-JSON lines are skipped, so raw bytes aren't emitted for them.
-	// But for valid JSON with unknown types, raw bytes should be preserved.
+	// Valid JSON with unknown types keeps its raw bytes too.
 	line := `{"type":"new_event_type","data":"something"}`
 	input := line + "\n"
 
@@ -445,3 +465,435 @@ func TestReader_RawBytesPreservedForParseFailures(t *testing.T) {
 		t.Errorf("parsed type = %q, want new_event_type", ev.Parsed.Type)
 	}
 }
+
+func TestReader_LongLine(t *testing.T) {
+	// A tool result far beyond bufio.Scanner's limits.
+	stdout := strings.Repeat("x", 5<<20)
+	line := `{"type":"tool_call","subtype":"completed","call_id":"call_1","tool_call":{"shellToolCall":{"result":{"success":{"exitCode":0,"stdout":"` + stdout + `"}}}}}`
+	input := line + "\n" + `{"type":"result","subtype":"success"}` + "\n"
+
+	out := make(chan AnnotatedEvent, 64)
+	errCh := make(chan error, 1)
+	go Reader(context.Background(), strings.NewReader(input), out, errCh)
+
+	var events []AnnotatedEvent
+	for ev := range out {
+		events = append(events, ev)
+	}
+	if len(events) != 2 || events[1].Parsed.Type != "result" {
+		t.Fatalf("got %d events, want the long one and the result", len(events))
+	}
+	if string(events[0].Raw) != line || events[0].Parsed.Subtype != "completed" {
+		t.Errorf("long event: %d raw bytes, subtype %q; want %d bytes, completed", len(events[0].Raw), events[0].Parsed.Subtype, len(line))
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected reader error: %v", err)
+	default:
+	}
+}
+
+func TestReader_DropsLineOverLimit(t *testing.T) {
+	defer func(n int) { maxLineBytes = n }(maxLineBytes)
+	maxLineBytes = 100
+	input := `{"type":"system","subtype":"init"}` + "\n" +
+		`{"type":"assistant","text":"` + strings.Repeat("y", 200) + `"}` + "\n" +
+		`{"type":"result","subtype":"success"}` + "\n"
+
+	out := make(chan AnnotatedEvent, 64)
+	errCh := make(chan error, 1)
+	go Reader(context.Background(), strings.NewReader(input), out, errCh)
+
+	var types []string
+	for ev := range out {
+		types = append(types, ev.Parsed.Type)
+	}
+	if strings.Join(types, ",") != "system,result" {
+		t.Errorf("event types = %v, want the oversized line dropped and the stream carried on", types)
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected reader error: %v", err)
+	default:
+	}
+}
+
+func TestReader_MaxEventBytes(t *testing.T) {
+	big := `{"type":"tool_call","subtype":"completed","call_id":"call_1","tool_call":{"shellToolCall":{"result":{"success":{"stdout":"` + strings.Repeat("z", 500) + `"}}}}}`
+	const limit = 256
+	prefix, suffix := `{"type":"assistant","message":{"content":[{"type":"text","text":"`, `"}]}}`
+	atLimit := prefix + strings.Repeat("a", limit-len(prefix)-len(suffix)) + suffix
+	lateType := `{"padding":"` + strings.Repeat("p", 300) + `","type":"result"}`
+	input := atLimit + "\r\n" + // a CRLF doesn't count against the limit
+		big + "\r\n" +
+		lateType + "\n" +
+		strings.Repeat("n", 300) + "\n" +
+		`{"type":"result","subtype":"success"}` + "\n"
+
+	out := make(chan AnnotatedEvent, 64)
+	errCh := make(chan error, 1)
+	go Reader(context.Background(), strings.NewReader(input), out, errCh, WithMaxEventBytes(limit))
+	var got []AnnotatedEvent
+	for ev := range out {
+		got = append(got, ev)
+	}
+	if len(got) != 5 {
+		t.Fatalf("got %d events, want 5", len(got))
+	}
+
+	// A line of exactly the limit is passed on whole.
+	if len(atLimit) != limit || string(got[0].Raw) != atLimit || got[0].Head != nil {
+		t.Errorf("event at the limit (%d bytes) = %.60s..., Head %d bytes", len(atLimit), got[0].Raw, len(got[0].Head))
+	}
+
+	// One over is replaced, with what its start says.
+	tests := []struct {
+		ev   AnnotatedEvent
+		want TruncatedEvent
+	}{
+		{got[1], TruncatedEvent{Type: TypeWrapper, Subtype: SubtypeTruncatedEvent, OriginalType: "tool_call", OriginalSubtype: "completed", CallID: "call_1", OriginalBytes: len(big)}},
+		// The type came after the cut.
+		{got[2], TruncatedEvent{Type: TypeWrapper, Subtype: SubtypeTruncatedEvent, OriginalBytes: len(lateType)}},
+		{got[3], TruncatedEvent{Type: TypeWrapper, Subtype: SubtypeTruncatedEvent, OriginalBytes: 300}},
+	}
+	for i, tt := range tests {
+		if tt.ev.Parsed.Type != TypeWrapper || tt.ev.Parsed.Subtype != SubtypeTruncatedEvent {
+			t.Errorf("event %d: %s/%s, want a truncated event", i+2, tt.ev.Parsed.Type, tt.ev.Parsed.Subtype)
+			continue
+		}
+		v, err := Decode(tt.ev)
+		if err != nil || v != tt.want {
+			t.Errorf("event %d = %+v, %v; want %+v", i+2, v, err, tt.want)
+		}
+		if len(tt.ev.Head) != limit {
+			t.Errorf("event %d: Head %d bytes, want the first %d", i+2, len(tt.ev.Head), limit)
+		}
+	}
+	if !strings.HasPrefix(big, string(got[1].Head)) {
+		t.Errorf("Head = %s, want the start of the event", got[1].Head)
+	}
+	if got[4].Parsed.Type != "result" {
+		t.Errorf("last event = %s, want the stream carried on", got[4].Raw)
+	}
+}
+
+func TestReader_MaxEventBytesKeepsHeadBounded(t *testing.T) {
+	huge := `{"type":"tool_call","subtype":"completed","call_id":"c9","pad":"` + strings.Repeat("q", truncatedHeadBytes*2) + `"}`
+	out := make(chan AnnotatedEvent, 4)
+	go Reader(context.Background(), strings.NewReader(huge+"\n"), out, make(chan error, 1), WithMaxEventBytes(truncatedHeadBytes+100))
+	ev := <-out
+	if len(ev.Head) != truncatedHeadBytes {
+		t.Errorf("Head = %d bytes, want %d", len(ev.Head), truncatedHeadBytes)
+	}
+	if v, _ := Decode(ev); v.(TruncatedEvent).OriginalBytes != len(huge) || v.(TruncatedEvent).CallID != "c9" {
+		t.Errorf("truncated event = %+v", v)
+	}
+	for range out {
+	}
+}
+
+func TestReader_LineEndings(t *testing.T) {
+	input := `{"type":"system","subtype":"init"}` + "\r\n\n" + `{"type":"result","subtype":"success"}` // no final newline
+
+	out := make(chan AnnotatedEvent, 64)
+	errCh := make(chan error, 1)
+	go Reader(context.Background(), strings.NewReader(input), out, errCh)
+
+	var raws []string
+	for ev := range out {
+		raws = append(raws, string(ev.Raw))
+	}
+	want := []string{`{"type":"system","subtype":"init"}`, `{"type":"result","subtype":"success"}`}
+	if strings.Join(raws, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events = %q, want %q (CR dropped, blank line skipped, last line kept)", raws, want)
+	}
+}
+
+func TestReader_ReadErrorKeepsPartialLine(t *testing.T) {
+	pr, pw := io.Pipe()
+
+	out := make(chan AnnotatedEvent, 64)
+	errCh := make(chan error, 1)
+	go Reader(context.Background(), pr, out, errCh)
+
+	go func() {
+		_, _ = pw.Write([]byte(`{"type":"system","subtype":"init"}` + "\n" + `{"type":"tool_call","subtype":"comp`))
+		pw.CloseWithError(io.ErrUnexpectedEOF)
+	}()
+
+	var got []string
+	for ev := range out {
+		got = append(got, string(ev.Raw))
+	}
+	want := []string{`{"type":"system","subtype":"init"}`, `{"type":"wrapper_partial_line","text":"{\"type\":\"tool_call\",\"subtype\":\"comp"}`}
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %q, want the init line and the partial one", got)
+	}
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("err = %v, want io.ErrUnexpectedEOF", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for error on errCh")
+	}
+}
+
+func TestReader_SeqAndTurn(t *testing.T) {
+	// Blank and oversized lines aren't events and take no number; the
+	// wrapper's own events do.
+	defer func(n int) { maxLineBytes = n }(maxLineBytes)
+	maxLineBytes = 64
+	input := `{"type":"system","subtype":"init"}` + "\n\n" +
+		"T: Named models unavailable\n" +
+		`{"type":"tool_call","subtype":"completed","padding":"` + strings.Repeat("x", 100) + `"}` + "\n" +
+		`{"type":"result","subtype":"success"}` + "\n" +
+		`{"type":"assis`
+
+	out := make(chan AnnotatedEvent, 64)
+	errCh := make(chan error, 1)
+	go Reader(context.Background(), strings.NewReader(input), out, errCh, WithTurn(3))
+
+	var got []string
+	for ev := range out {
+		if ev.Turn != 3 {
+			t.Errorf("event %d: Turn = %d, want 3", ev.Seq, ev.Turn)
+		}
+		got = append(got, fmt.Sprintf("%d %s", ev.Seq, ev.Parsed.Type))
+	}
+	want := []string{"1 system", "2 wrapper_nonjson", "3 result", "4 wrapper_partial_line"}
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestReader_Lag(t *testing.T) {
+	// The wrapper's clock reads 12:00:00.000 whenever it reads a line.
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ms := now.UnixMilli()
+	input := strings.Join([]string{
+		fmt.Sprintf(`{"type":"tool_call","subtype":"started","call_id":"c1","timestamp_ms":%d}`, ms-250),
+		fmt.Sprintf(`{"type":"tool_call","subtype":"completed","call_id":"c1","timestampMs":%d}`, ms-1500),
+		fmt.Sprintf(`{"type":"thinking","subtype":"delta","text":"x","timestamp_ms":%d}`, ms+100), // the agent's clock is ahead
+		`{"type":"assistant","message":{"content":[]}}`,
+		`{"type":"result","subtype":"success","timestamp_ms":"soon"}`,
+	}, "\n") + "\n"
+	out := make(chan AnnotatedEvent, 10)
+	Reader(context.Background(), strings.NewReader(input), out, make(chan error, 1), WithClock(func() time.Time { return now }))
+
+	want := []struct {
+		hasLag bool
+		lag    time.Duration
+	}{
+		{true, 250 * time.Millisecond},
+		{true, 1500 * time.Millisecond},
+		{true, -100 * time.Millisecond},
+		{false, 0},
+		{false, 0},
+	}
+	var i int
+	for ev := range out {
+		if i >= len(want) {
+			t.Fatalf("unexpected event %s", ev.Raw)
+		}
+		if !ev.RecvTime.Equal(now) {
+			t.Errorf("event %d: RecvTime = %v, want the injected clock's %v", i+1, ev.RecvTime, now)
+		}
+		if hasLag := !ev.AgentTime.IsZero(); hasLag != want[i].hasLag || ev.Lag != want[i].lag {
+			t.Errorf("event %d (%s): AgentTime %v, Lag %v; want lag %v (%t)", i+1, ev.Parsed.Type, ev.AgentTime, ev.Lag, want[i].lag, want[i].hasLag)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Errorf("got %d events, want %d", i, len(want))
+	}
+}
+
+func TestReader_ConcatenatedObjects(t *testing.T) {
+	// wantRaw is each event's Raw, as "type:raw"; the wrapper's events by
+	// their text.
+	tests := []struct {
+		name    string
+		input   string
+		wantRaw []string
+	}{
+		{
+			name:  "two",
+			input: `{"type":"assistant","message":{"content":[]}}{"type":"result","subtype":"success"}` + "\n",
+			wantRaw: []string{
+				`assistant:{"type":"assistant","message":{"content":[]}}`,
+				`result:{"type":"result","subtype":"success"}`,
+			},
+		},
+		{
+			name:  "three, spaced",
+			input: `{"type":"thinking","subtype":"delta","text":"a}{"} {"type":"thinking","subtype":"completed"}{"type":"result","subtype":"success"}` + "\n",
+			wantRaw: []string{
+				`thinking:{"type":"thinking","subtype":"delta","text":"a}{"}`,
+				`thinking:{"type":"thinking","subtype":"completed"}`,
+				`result:{"type":"result","subtype":"success"}`,
+			},
+		},
+		{
+			name:  "trailing partial at the end of output",
+			input: `{"type":"thinking","subtype":"completed"}{"type":"result","subtype":"succ`,
+			wantRaw: []string{
+				`thinking:{"type":"thinking","subtype":"completed"}`,
+				TypePartialLine + `:{"type":"result","subtype":"succ`,
+			},
+		},
+		{
+			name:  "trailing garbage mid-stream",
+			input: `{"type":"thinking","subtype":"completed"}{"type":"res` + "\n" + `{"type":"result","subtype":"success"}` + "\n",
+			wantRaw: []string{
+				`thinking:{"type":"thinking","subtype":"completed"}`,
+				TypeWrapperNonJSON + `:{"type":"res`,
+				`result:{"type":"result","subtype":"success"}`,
+			},
+		},
+		{
+			name:    "garbage",
+			input:   `{not json at all}` + "\n",
+			wantRaw: []string{TypeWrapperNonJSON + `:{not json at all}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := make(chan AnnotatedEvent, 64)
+			go Reader(context.Background(), strings.NewReader(tt.input), out, make(chan error, 1))
+			var got []string
+			for ev := range out {
+				raw := string(ev.Raw)
+				var wrapped WrapperNonJSON
+				if ev.Parsed.Type == TypeWrapperNonJSON || ev.Parsed.Type == TypePartialLine {
+					if err := json.Unmarshal(ev.Raw, &wrapped); err != nil {
+						t.Fatal(err)
+					}
+					raw = wrapped.Text
+				}
+				got = append(got, ev.Parsed.Type+":"+raw)
+			}
+			if !slices.Equal(got, tt.wantRaw) {
+				t.Errorf("events:\n got  %q\n want %q", got, tt.wantRaw)
+			}
+		})
+	}
+}
+
+func TestReader_PartialFinalLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		last     string // the output's unterminated end
+		wantType string
+	}{
+		{"mid-object", `{"type":"assistant","message":{"content":[{"type":"text","text":"Runn`, TypePartialLine},
+		{"complete event", `{"type":"result","subtype":"success"}`, "result"},
+		{"notice", "T: Named models unavailable", TypeWrapperNonJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := `{"type":"system","subtype":"init"}` + "\n" + tt.last
+			out := make(chan AnnotatedEvent, 64)
+			errCh := make(chan error, 1)
+			go Reader(context.Background(), strings.NewReader(input), out, errCh)
+
+			var got []AnnotatedEvent
+			for ev := range out {
+				got = append(got, ev)
+			}
+			if len(got) != 2 {
+				t.Fatalf("got %d events, want 2", len(got))
+			}
+			last := got[1]
+			if last.Parsed.Type != tt.wantType {
+				t.Errorf("last event type = %q, want %q", last.Parsed.Type, tt.wantType)
+			}
+			if tt.wantType == TypePartialLine {
+				var partial WrapperNonJSON
+				if err := json.Unmarshal(last.Raw, &partial); err != nil || partial.Text != tt.last {
+					t.Errorf("partial line event %s (%v), want the bytes written", last.Raw, err)
+				}
+			}
+			select {
+			case err := <-errCh:
+				t.Errorf("unexpected error at EOF: %v", err)
+			default:
+			}
+		})
+	}
+}
+
+func TestReader_SlowConsumerKeepsRecvTime(t *testing.T) {
+	pr, pw := io.Pipe()
+	const n = 5
+	written := make(chan time.Time, n)
+	go func() {
+		for range n {
+			time.Sleep(20 * time.Millisecond)
+			written <- time.Now()
+			_, _ = pw.Write([]byte(`{"type":"assistant"}` + "\n"))
+		}
+		_ = pw.Close()
+	}()
+
+	var stats QueueStats
+	out := make(chan AnnotatedEvent) // no room at all: every send waits
+	go Reader(context.Background(), pr, out, make(chan error, 1), WithQueueStats(&stats))
+
+	// A consumer far slower than the agent: without staging, each event
+	// would be read, and stamped, only once the one before was taken.
+	var got []AnnotatedEvent
+	for ev := range out {
+		time.Sleep(100 * time.Millisecond)
+		got = append(got, ev)
+	}
+	if len(got) != n {
+		t.Fatalf("got %d events, want %d", len(got), n)
+	}
+	for i, ev := range got {
+		if lag := ev.RecvTime.Sub(<-written); lag > 50*time.Millisecond {
+			t.Errorf("event %d stamped %v after it was written", i, lag)
+		}
+	}
+	if stats.MaxDepth() < 3 {
+		t.Errorf("MaxDepth = %d, want the events to have piled up", stats.MaxDepth())
+	}
+	if stats.Blocked() < 300*time.Millisecond {
+		t.Errorf("Blocked = %v, want most of the consumer's delay", stats.Blocked())
+	}
+	if !stats.BlockedSince().IsZero() {
+		t.Errorf("BlockedSince = %v after the stream ended", stats.BlockedSince())
+	}
+}
+
+func TestReader_StagingIsBounded(t *testing.T) {
+	defer func(n int) { maxStagedBytes = n }(maxStagedBytes)
+	maxStagedBytes = 1 // one event at a time
+
+	pr, pw := io.Pipe()
+	out := make(chan AnnotatedEvent)
+	go Reader(context.Background(), pr, out, make(chan error, 1))
+
+	// Nothing is received: one event waits on out, one is staged, and the
+	// read loop holds a third, so the fourth write can't go through.
+	wrote := make(chan int, 8)
+	go func() {
+		for i := range 6 {
+			_, _ = pw.Write([]byte(`{"type":"assistant"}` + "\n"))
+			wrote <- i
+		}
+		_ = pw.Close()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if len(wrote) > 3 {
+		t.Fatalf("%d writes went through with the consumer stalled, want the pipe to back up", len(wrote))
+	}
+
+	count := 0
+	for range out {
+		count++
+	}
+	if count != 6 {
+		t.Errorf("got %d events, want 6", count)
+	}
+}
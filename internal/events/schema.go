@@ -0,0 +1,155 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Schema is a generation of cursor-agent's stream-json output. Agent
+// builds in use at the same time differ in small ways, mostly field
+// names; the Parse functions taking a Schema normalize each generation's
+// events to the structs in this package, which have the current shape.
+type Schema int
+
+const (
+	// SchemaUnknown is an unrecognized generation, or a stream whose
+	// system/init event hasn't been seen. Its events are parsed as
+	// SchemaCurrent's, best effort.
+	SchemaUnknown Schema = iota
+	// SchemaV1 is the first generation: camelCase field names (callId,
+	// timestampMs, sessionId, durationMs, ...) and no version field.
+	SchemaV1
+	// SchemaV2 is the shape docs/stream-json-events.md describes:
+	// snake_case field names and no version field.
+	SchemaV2
+	// SchemaV3 announces itself with "schema_version": 3 in system/init.
+	// A tool call's object is in "tool" rather than "tool_call", and its
+	// timestamp in "ts_ms".
+	SchemaV3
+)
+
+// SchemaCurrent is the generation this package's structs follow.
+const SchemaCurrent = SchemaV2
+
+func (s Schema) String() string {
+	if s == SchemaUnknown {
+		return "unknown"
+	}
+	return "v" + strconv.Itoa(int(s))
+}
+
+// shims rename the top-level fields of a generation's events to their
+// current names. A generation without one needs none.
+var shims = map[Schema]map[string]string{
+	SchemaV1: {
+		"sessionId":   "session_id",
+		"callId":      "call_id",
+		"modelCallId": "model_call_id",
+		"timestampMs": "timestamp_ms",
+		"toolCall":    "tool_call",
+		"durationMs":  "duration_ms",
+		"isError":     "is_error",
+		"requestId":   "request_id",
+	},
+	SchemaV3: {
+		"tool":  "tool_call",
+		"ts_ms": "timestamp_ms",
+	},
+}
+
+// DetectSchema works out the generation of a stream from its
+// system/init event: from the version it states, if it does, or else
+// from its field names. A stated version this package doesn't know is
+// SchemaUnknown, with an error saying which it was.
+func DetectSchema(initRaw []byte) (Schema, error) {
+	var init struct {
+		SchemaVersion *int            `json:"schema_version"`
+		SessionID     json.RawMessage `json:"session_id"`
+		LegacyID      json.RawMessage `json:"sessionId"`
+	}
+	if err := json.Unmarshal(initRaw, &init); err != nil {
+		return SchemaUnknown, fmt.Errorf("unmarshal init event: %w", err)
+	}
+	switch {
+	case init.SchemaVersion != nil:
+		s := Schema(*init.SchemaVersion)
+		if s < SchemaV1 || s > SchemaV3 {
+			return SchemaUnknown, fmt.Errorf("unknown stream-json schema_version %d", *init.SchemaVersion)
+		}
+		return s, nil
+	case init.SessionID == nil && init.LegacyID != nil:
+		return SchemaV1, nil
+	default:
+		return SchemaV2, nil
+	}
+}
+
+// normalize returns raw with the fields s names differently renamed to
+// their current names. A field whose current name is also present is
+// dropped in favour of it.
+func normalize(raw []byte, s Schema) ([]byte, error) {
+	renames := shims[s]
+	if len(renames) == 0 {
+		return raw, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	changed := false
+	for old, current := range renames {
+		v, ok := fields[old]
+		if !ok {
+			continue
+		}
+		delete(fields, old)
+		if _, ok := fields[current]; !ok {
+			fields[current] = v
+		}
+		changed = true
+	}
+	if !changed {
+		return raw, nil
+	}
+	return json.Marshal(fields)
+}
+
+// ParseSystemInit extracts a system/init event's fields from its raw
+// JSON, written in schema s.
+func ParseSystemInit(raw []byte, s Schema) (SystemInit, error) {
+	var init SystemInit
+	if err := unmarshalAs(raw, s, &init); err != nil {
+		return SystemInit{}, fmt.Errorf("unmarshal init event: %w", err)
+	}
+	return init, nil
+}
+
+// ParseToolCallStarted extracts a tool_call/started event's fields from
+// its raw JSON, written in schema s.
+func ParseToolCallStarted(raw []byte, s Schema) (ToolCallStarted, error) {
+	var started ToolCallStarted
+	if err := unmarshalAs(raw, s, &started); err != nil {
+		return ToolCallStarted{}, fmt.Errorf("unmarshal tool_call started: %w", err)
+	}
+	return started, nil
+}
+
+// ParseToolCallCompleted extracts a tool_call/completed event's fields
+// from its raw JSON, written in schema s.
+func ParseToolCallCompleted(raw []byte, s Schema) (ToolCallCompleted, error) {
+	var completed ToolCallCompleted
+	if err := unmarshalAs(raw, s, &completed); err != nil {
+		return ToolCallCompleted{}, fmt.Errorf("unmarshal tool_call completed: %w", err)
+	}
+	return completed, nil
+}
+
+// unmarshalAs decodes raw, written in schema s, into v.
+func unmarshalAs(raw []byte, s Schema, v any) error {
+	raw, err := normalize(raw, s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSchemas_NormalizeToCurrentStructs(t *testing.T) {
+	// Each fixture is the same turn as one generation of agent wrote it:
+	// init, a shell call started and completed, and the result.
+	tests := []struct {
+		fixture string
+		want    Schema
+	}{
+		{"schema_v1.jsonl", SchemaV1},
+		{"schema_v2.jsonl", SchemaV2},
+		{"schema_v3.jsonl", SchemaV3},
+	}
+	const sessionID = "7c1e0f42-5a9d-4b7e-9f1a-2d6c8e3b4a10"
+	for _, tt := range tests {
+		t.Run(tt.want.String(), func(t *testing.T) {
+			lines := bytes.Split(loadFixture(t, tt.fixture), []byte("\n"))
+			if len(lines) != 4 {
+				t.Fatalf("fixture has %d lines, want 4", len(lines))
+			}
+			s, err := DetectSchema(lines[0])
+			if err != nil || s != tt.want {
+				t.Fatalf("DetectSchema = %v, %v; want %v", s, err, tt.want)
+			}
+
+			init, err := ParseSystemInit(lines[0], s)
+			if err != nil || init.SessionID != sessionID || init.Model != "Auto" {
+				t.Errorf("ParseSystemInit = %+v, %v", init, err)
+			}
+
+			started, err := ParseToolCallStarted(lines[1], s)
+			if err != nil {
+				t.Fatalf("ParseToolCallStarted: %v", err)
+			}
+			if started.CallID != "call_8Hq2" || started.ModelCallID != "b3f1c7d2-0-k2x9" || started.TimestampMS != 1761312001200 {
+				t.Errorf("ParseToolCallStarted = %+v", started)
+			}
+			info, err := ParseToolCallInfo(started.ToolCall)
+			if err != nil || info.Command != "go test ./..." || info.TimeoutMS != 120000 {
+				t.Errorf("started tool call = %+v, %v", info, err)
+			}
+
+			completed, err := ParseToolCallCompleted(lines[2], s)
+			if err != nil {
+				t.Fatalf("ParseToolCallCompleted: %v", err)
+			}
+			if completed.CallID != "call_8Hq2" || completed.TimestampMS != 1761312005300 {
+				t.Errorf("ParseToolCallCompleted = %+v", completed)
+			}
+			shell, err := ParseShellToolResult(completed.ToolCall)
+			if err != nil || shell.ExitCode != 1 || shell.ExecutionTime != 4100 {
+				t.Errorf("completed shell result = %+v, %v", shell, err)
+			}
+
+			result, err := ParseResult(lines[3], s)
+			if err != nil {
+				t.Fatalf("ParseResult: %v", err)
+			}
+			if result.Subtype != "success" || result.DurationMS != 9800 || result.IsError || result.SessionID != sessionID || result.RequestID != "b3f1c7d2" {
+				t.Errorf("ParseResult = %+v", result)
+			}
+		})
+	}
+}
+
+func TestDetectSchema_UnknownVersion(t *testing.T) {
+	init := []byte(`{"type":"system","subtype":"init","schema_version":9,"session_id":"s1","model":"Auto"}`)
+	s, err := DetectSchema(init)
+	if s != SchemaUnknown || err == nil || !strings.Contains(err.Error(), "schema_version 9") {
+		t.Fatalf("DetectSchema = %v, %v; want SchemaUnknown and an error naming the version", s, err)
+	}
+	// Parsed best effort, as the current schema.
+	if got, err := ParseSystemInit(init, s); err != nil || got.SessionID != "s1" {
+		t.Errorf("ParseSystemInit = %+v, %v", got, err)
+	}
+}
+
+func TestReader_StampsSchema(t *testing.T) {
+	stream := append([]byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}]}}`+"\n"), loadFixture(t, "schema_v1.jsonl")...)
+	out := make(chan AnnotatedEvent, 10)
+	Reader(context.Background(), bytes.NewReader(stream), out, make(chan error, 1))
+	var got []Schema
+	for ev := range out {
+		got = append(got, ev.Schema)
+	}
+	want := []Schema{SchemaUnknown, SchemaV1, SchemaV1, SchemaV1, SchemaV1}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: Schema = %v, want %v", i+1, got[i], want[i])
+		}
+	}
+}
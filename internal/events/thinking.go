@@ -0,0 +1,92 @@
+package events
+
+import (
+	"strings"
+	"time"
+)
+
+// ThinkingPhase is one run of the agent's thinking, from its first
+// thinking/delta to the event that ended it.
+type ThinkingPhase struct {
+	Index int       // 1 for the first phase seen, 2 for the next, ...
+	Text  string    // the deltas' text, concatenated
+	Start time.Time // RecvTime of the first delta
+	End   time.Time // RecvTime of the event that ended it; zero while in progress
+}
+
+// Duration returns how long the phase ran, or, while it is in progress,
+// how long it has run as of now.
+func (p ThinkingPhase) Duration(now time.Time) time.Duration {
+	if !p.End.IsZero() {
+		now = p.End
+	}
+	return now.Sub(p.Start)
+}
+
+// ThinkingAccumulator follows the agent's thinking phases so callers
+// need not each collect deltas themselves. Feed it every event with Add.
+// A phase runs from its first thinking/delta to thinking/completed; any
+// other event ends it too, since the agent has moved on, and the next
+// delta starts a new phase. The zero value is ready to use.
+type ThinkingAccumulator struct {
+	// TimingOnly skips collecting the deltas' text, for callers that only
+	// need a phase's timing: phases then have no Text, and memory stays
+	// the same however long the agent thinks.
+	TimingOnly bool
+
+	phase  ThinkingPhase
+	text   strings.Builder
+	active bool
+}
+
+// Add feeds ev to the accumulator. If ev ended a phase, that phase is
+// returned, with ok true. A delta whose text can't be read still starts
+// or continues a phase, but adds no text.
+func (a *ThinkingAccumulator) Add(ev AnnotatedEvent) (ended ThinkingPhase, ok bool) {
+	if ev.Parsed.Type == "thinking" && ev.Parsed.Subtype == "delta" {
+		if !a.active {
+			a.active = true
+			a.phase = ThinkingPhase{Index: a.phase.Index + 1, Start: ev.RecvTime}
+			a.text.Reset()
+		}
+		if a.TimingOnly {
+			return ThinkingPhase{}, false
+		}
+		if v, _ := Decode(ev); v != nil {
+			a.text.WriteString(v.(ThinkingDelta).Text)
+		}
+		return ThinkingPhase{}, false
+	}
+	if !a.active {
+		return ThinkingPhase{}, false
+	}
+	ended = a.Current()
+	ended.End = ev.RecvTime
+	a.active = false
+	a.text.Reset()
+	return ended, true
+}
+
+// Active reports whether a thinking phase is in progress.
+func (a *ThinkingAccumulator) Active() bool {
+	return a.active
+}
+
+// Current returns the phase in progress, with the text so far unless
+// TimingOnly, or the zero ThinkingPhase if there is none.
+func (a *ThinkingAccumulator) Current() ThinkingPhase {
+	if !a.active {
+		return ThinkingPhase{}
+	}
+	p := a.phase
+	p.Text = a.text.String()
+	return p
+}
+
+// Shift moves the start of the phase in progress forward by d, so time
+// the caller spent paused doesn't count toward it.
+func (a *ThinkingAccumulator) Shift(d time.Duration) {
+	if a.active {
+		a.phase.Start = a.phase.Start.Add(d)
+	}
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// at makes the event on raw, received ms milliseconds after t0.
+func at(t0 time.Time, ms int, raw string) AnnotatedEvent {
+	var parsed RawEvent
+	_ = json.Unmarshal([]byte(raw), &parsed) // malformed lines keep a zero parse
+	return AnnotatedEvent{RecvTime: t0.Add(time.Duration(ms) * time.Millisecond), Raw: []byte(raw), Parsed: parsed}
+}
+
+func TestThinkingAccumulator_Phases(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	var acc ThinkingAccumulator
+	if acc.Active() || acc.Current() != (ThinkingPhase{}) {
+		t.Fatal("zero accumulator has a phase in progress")
+	}
+
+	type want struct {
+		index int
+		text  string
+		dur   time.Duration
+	}
+	steps := []struct {
+		ev     AnnotatedEvent
+		ended  *want // the phase ev ends, if any
+		active bool
+	}{
+		{at(t0, 0, `{"type":"system","subtype":"init"}`), nil, false},
+		{at(t0, 100, `{"type":"thinking","subtype":"delta","text":"**Planning"}`), nil, true},
+		{at(t0, 150, `{"type":"thinking","subtype":"delta","text":" the fix**"}`), nil, true},
+		{at(t0, 400, `{"type":"thinking","subtype":"completed"}`), &want{1, "**Planning the fix**", 300 * time.Millisecond}, false},
+		{at(t0, 500, `{"type":"assistant","message":{"content":[]}}`), nil, false},
+		// A phase the agent leaves without thinking/completed.
+		{at(t0, 600, `{"type":"thinking","subtype":"delta","text":"Check"}`), nil, true},
+		{at(t0, 700, `{"type":"tool_call","subtype":"started"}`), &want{2, "Check", 100 * time.Millisecond}, false},
+		// A delta without readable text still starts a phase.
+		{at(t0, 800, `{"type":"thinking","subtype":"delta","text":42}`), nil, true},
+		{at(t0, 850, `{"type":"thinking","subtype":"delta","text":"ing"}`), nil, true},
+		{at(t0, 900, `{"type":"thinking","subtype":"completed"}`), &want{3, "ing", 100 * time.Millisecond}, false},
+		{at(t0, 950, `{"type":"thinking","subtype":"completed"}`), nil, false},
+	}
+	for i, s := range steps {
+		ended, ok := acc.Add(s.ev)
+		switch {
+		case s.ended == nil && ok:
+			t.Errorf("step %d: ended phase %+v, want none", i, ended)
+		case s.ended != nil && !ok:
+			t.Errorf("step %d: ended no phase, want phase %d", i, s.ended.index)
+		case s.ended != nil:
+			if ended.Index != s.ended.index || ended.Text != s.ended.text || ended.Duration(time.Time{}) != s.ended.dur {
+				t.Errorf("step %d: ended phase %d %q after %v, want %+v", i, ended.Index, ended.Text, ended.Duration(time.Time{}), *s.ended)
+			}
+		}
+		if acc.Active() != s.active {
+			t.Errorf("step %d: Active = %v, want %v", i, acc.Active(), s.active)
+		}
+	}
+}
+
+func TestThinkingAccumulator_CurrentAndShift(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	var acc ThinkingAccumulator
+	acc.Add(at(t0, 0, `{"type":"thinking","subtype":"delta","text":"a"}`))
+	acc.Add(at(t0, 10, `{"type":"thinking","subtype":"delta","text":"b"}`))
+
+	cur := acc.Current()
+	if cur.Index != 1 || cur.Text != "ab" || !cur.End.IsZero() {
+		t.Errorf("Current = %+v", cur)
+	}
+	if got := cur.Duration(t0.Add(time.Second)); got != time.Second {
+		t.Errorf("Duration = %v, want 1s as of now", got)
+	}
+	acc.Shift(200 * time.Millisecond)
+	if got := acc.Current().Start; !got.Equal(t0.Add(200 * time.Millisecond)) {
+		t.Errorf("after Shift, Start = %v", got)
+	}
+}
+
+func TestThinkingAccumulator_TimingOnly(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	acc := ThinkingAccumulator{TimingOnly: true}
+	acc.Add(at(t0, 0, `{"type":"thinking","subtype":"delta","text":"a"}`))
+	acc.Add(at(t0, 10, `{"type":"thinking","subtype":"delta","text":"b"}`))
+	if cur := acc.Current(); !acc.Active() || cur.Index != 1 || !cur.Start.Equal(t0) || cur.Text != "" {
+		t.Errorf("Current = %+v, active %v; want the phase's timing without its text", cur, acc.Active())
+	}
+	ended, ok := acc.Add(at(t0, 30, `{"type":"thinking","subtype":"completed"}`))
+	if !ok || ended.Duration(time.Time{}) != 30*time.Millisecond || ended.Text != "" {
+		t.Errorf("ended = %+v, %v", ended, ok)
+	}
+	if acc.text.Cap() != 0 {
+		t.Errorf("kept %d bytes of text", acc.text.Cap())
+	}
+}
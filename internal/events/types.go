@@ -1,6 +1,7 @@
 package events
 
 import (
+	"bytes"
 	"encoding/json"
 	"time"
 )
@@ -18,6 +19,94 @@ type AnnotatedEvent struct {
 	RecvTime time.Time
 	Raw      []byte   // verbatim JSON line
 	Parsed   RawEvent // first-pass parse (type + subtype)
+	// Seq numbers a Reader's events from 1, in the order read, the
+	// wrapper's own included. With Turn it identifies an event where
+	// RecvTimes collide.
+	Seq int64
+	// Turn is the session turn the event belongs to, as the Reader's
+	// caller gave it (see WithTurn); 0 if it didn't.
+	Turn int
+	// Schema is the generation of the stream the event is from, as its
+	// system/init event showed (see DetectSchema); SchemaUnknown before
+	// that. Pass it to the Parse functions that take one.
+	Schema Schema
+	// AgentTime is when the agent says it made the event, from its
+	// timestamp_ms; zero for events without one.
+	AgentTime time.Time
+	// Lag is RecvTime - AgentTime, how far behind the agent the event
+	// was read, when AgentTime is set. The clocks are the agent's and
+	// the wrapper's, so skew can make it negative.
+	Lag time.Duration
+	// Head is the start of an event the Reader cut short for its size
+	// (see WithMaxEventBytes), kept for the log; nil for any other event.
+	Head []byte
+
+	decoded *decoding // see Decode; nil for events not from a Reader
+}
+
+// TypeWrapperNonJSON is the type of the events the Reader makes of the
+// lines on the agent's stdout that aren't JSON, such as "T: Named models
+// unavailable on free plan". The wrapper, not cursor-agent, writes them.
+const TypeWrapperNonJSON = "wrapper_nonjson"
+
+// WrapperNonJSON is a wrapper_nonjson event: Text is the line as the
+// agent wrote it.
+type WrapperNonJSON struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// TypeWrapper and SubtypeTruncatedEvent are the type and subtype of the
+// event the Reader puts in place of an event over its size limit (see
+// WithMaxEventBytes).
+const (
+	TypeWrapper           = "wrapper"
+	SubtypeTruncatedEvent = "truncated_event"
+)
+
+// TruncatedEvent is a wrapper/truncated_event event: what could be read
+// off the start of the event it stands for, and that event's size. The
+// fields of the original are empty if they weren't in its first bytes.
+type TruncatedEvent struct {
+	Type            string `json:"type"`
+	Subtype         string `json:"subtype"`
+	OriginalType    string `json:"original_type"`
+	OriginalSubtype string `json:"original_subtype,omitempty"`
+	CallID          string `json:"call_id,omitempty"` // a tool call's
+	OriginalBytes   int    `json:"original_bytes"`
+}
+
+// TypePartialLine is the type of the event the Reader makes of a last
+// line the agent didn't finish: output that ends, or fails, part way
+// through an event, as when a hung agent is killed mid-write. It has the
+// same shape as a wrapper_nonjson event, Text holding the bytes written.
+const TypePartialLine = "wrapper_partial_line"
+
+// NonJSONEvent wraps a line that isn't JSON in a wrapper_nonjson event,
+// whose Raw is valid JSON like any other event's.
+func NonJSONEvent(recv time.Time, line []byte) AnnotatedEvent {
+	return wrapperLineEvent(TypeWrapperNonJSON, recv, line)
+}
+
+// PartialLineEvent wraps an unfinished last line in a
+// wrapper_partial_line event.
+func PartialLineEvent(recv time.Time, line []byte) AnnotatedEvent {
+	return wrapperLineEvent(TypePartialLine, recv, line)
+}
+
+func wrapperLineEvent(typ string, recv time.Time, line []byte) AnnotatedEvent {
+	// HTML escaping is disabled so the text stays readable in the raw
+	// stream. Encoding a string can't fail.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(WrapperNonJSON{Type: typ, Text: string(line)})
+	raw := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	return AnnotatedEvent{
+		RecvTime: recv,
+		Raw:      raw,
+		Parsed:   RawEvent{Type: typ, Line: raw},
+	}
 }
 
 // SystemInit is the "system"/"init" event.
@@ -43,6 +132,61 @@ type ShellToolArgs struct {
 	IsBackground bool   `json:"isBackground"`
 }
 
+// ReadToolArgs holds the fields we need from readToolCall.args. Offset
+// and Limit, in lines, are set when only part of the file is read.
+type ReadToolArgs struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Limit  int64  `json:"limit"`
+}
+
+// WriteToolArgs holds the fields we need from writeToolCall.args.
+type WriteToolArgs struct {
+	Path     string `json:"path"`
+	FileText string `json:"fileText"`
+}
+
+// GrepToolArgs holds the fields we need from grepToolCall.args. Path is
+// where the search runs; empty means the workspace.
+type GrepToolArgs struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path"`
+	Glob    string `json:"glob"`
+	Type    string `json:"type"` // ripgrep file type, e.g. "go"
+}
+
+// EditToolArgs holds the fields we need from editToolCall.args: a search
+// and replace in one file.
+type EditToolArgs struct {
+	Path       string `json:"path"`
+	OldString  string `json:"oldString"`
+	NewString  string `json:"newString"`
+	ReplaceAll bool   `json:"replaceAll"`
+}
+
+// DeleteToolArgs holds the fields we need from deleteToolCall.args.
+type DeleteToolArgs struct {
+	Path string `json:"path"`
+}
+
+// ToolCallUpdate is emitted while a tool runs, with output it has
+// produced since the last one: long-running shells stream their stdout
+// and stderr this way. Agent builds send it as tool_call/update or
+// tool_call/delta; see ParseToolCallUpdate.
+type ToolCallUpdate struct {
+	CallID      string `json:"call_id"`
+	ModelCallID string `json:"model_call_id"`
+	TimestampMS int64  `json:"timestamp_ms"`
+	Stdout      string `json:"stdout"` // appended since the last update
+	Stderr      string `json:"stderr"`
+}
+
+// IsToolCallUpdate reports whether subtype is one a tool_call event
+// streaming a running tool's output has.
+func IsToolCallUpdate(subtype string) bool {
+	return subtype == "update" || subtype == "delta"
+}
+
 // ToolCallCompleted is emitted when a tool finishes.
 type ToolCallCompleted struct {
 	CallID      string          `json:"call_id"`
@@ -51,11 +195,25 @@ type ToolCallCompleted struct {
 	ToolCall    json.RawMessage `json:"tool_call"`
 }
 
-// Result is the terminal event.
+// Result is the terminal event. Model, Usage and ErrorMessage are only
+// sent by newer agent builds, and are zero when absent; see ParseResult.
 type Result struct {
 	Subtype    string `json:"subtype"`
 	DurationMS int64  `json:"duration_ms"`
 	IsError    bool   `json:"is_error"`
 	SessionID  string `json:"session_id"`
 	RequestID  string `json:"request_id"`
+	Model      string `json:"model"`
+	Usage      *Usage `json:"usage"`
+	// ErrorMessage is the "error" field, a string or an object with a
+	// message, when the agent explains a failed turn.
+	ErrorMessage string `json:"-"`
+}
+
+// Usage is a result event's token counts for the turn.
+type Usage struct {
+	InputTokens      int64 `json:"inputTokens"`
+	OutputTokens     int64 `json:"outputTokens"`
+	CacheReadTokens  int64 `json:"cacheReadTokens"`
+	CacheWriteTokens int64 `json:"cacheWriteTokens"`
 }
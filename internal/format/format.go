@@ -2,6 +2,7 @@ package format
 
 import (
 	"io"
+	"time"
 
 	"cursor-wrap/internal/events"
 	"cursor-wrap/internal/monitor"
@@ -15,7 +16,13 @@ type Formatter interface {
 
 	// WriteHangIndicator renders a hang detection message inline.
 	// Called by the session loop when a hang is detected in interactive mode.
-	WriteHangIndicator(reason monitor.Reason) error
+	// now is the time the agent was killed; elapsed figures in reason are
+	// advanced to it (see monitor.Reason.AsOf).
+	WriteHangIndicator(reason monitor.Reason, now time.Time) error
+
+	// WriteBatchHeader marks the start of the index'th of total prompts
+	// of a --batch run, before its turn's events.
+	WriteBatchHeader(index, total int, prompt string) error
 
 	// Flush is called after each turn completes (result event received
 	// or stream ended). The formatter can write separators or finalize
@@ -23,15 +30,58 @@ type Formatter interface {
 	Flush() error
 }
 
+// Option configures a formatter.
+type Option func(*options)
+
+type options struct {
+	wrapperEvents bool
+	color         bool
+	toolOutput    bool
+	thinking      bool
+}
+
+// WithWrapperEvents makes stream-json pass on the events the wrapper
+// makes itself, such as wrapper_nonjson. By default it drops them, and
+// its output is cursor-agent's.
+func WithWrapperEvents(on bool) Option {
+	return func(o *options) { o.wrapperEvents = on }
+}
+
+// WithColor lets text use terminal escapes: it dims the agent's non-JSON
+// lines and the footer after a turn.
+func WithColor(on bool) Option {
+	return func(o *options) { o.color = on }
+}
+
+// WithToolOutput makes text show the output running tools stream
+// (tool_call update events), under the tool's ⏳ line.
+func WithToolOutput(on bool) Option {
+	return func(o *options) { o.toolOutput = on }
+}
+
+// WithThinking makes text show the agent's thinking: each phase, once
+// it ends, as its text under a 💭 line giving how long it took.
+func WithThinking(on bool) Option {
+	return func(o *options) { o.thinking = on }
+}
+
 // New creates a formatter for the given format name.
 // Supported formats: "stream-json", "text".
 // Panics on unknown format name (caller validates before calling).
-func New(format string, w io.Writer) Formatter {
+func New(format string, w io.Writer, opts ...Option) Formatter {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	switch format {
 	case "stream-json":
-		return &streamJSON{w: w}
+		return &streamJSON{w: w, wrapperEvents: o.wrapperEvents}
 	case "text":
-		return &text{w: w}
+		t := &text{w: w, color: o.color, toolOutput: o.toolOutput}
+		if o.thinking {
+			t.thinking = &events.ThinkingAccumulator{}
+		}
+		return t
 	default:
 		panic("unknown format: " + format)
 	}
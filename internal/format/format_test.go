@@ -3,6 +3,7 @@ package format
 import (
 	"bytes"
 	"encoding/json"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -84,6 +85,23 @@ func TestStreamJSON_WriteEvent_PreservesRawBytes(t *testing.T) {
 	}
 }
 
+func TestStreamJSON_WrapperNonJSON(t *testing.T) {
+	ev := events.NonJSONEvent(time.Now(), []byte("T: Named models unavailable on free plan"))
+	for _, on := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := New("stream-json", &buf, WithWrapperEvents(on)).WriteEvent(ev); err != nil {
+			t.Fatalf("WriteEvent: %v", err)
+		}
+		want := ""
+		if on {
+			want = `{"type":"wrapper_nonjson","text":"T: Named models unavailable on free plan"}` + "\n"
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("wrapper events %v: got %q, want %q", on, got, want)
+		}
+	}
+}
+
 func TestStreamJSON_WriteHangIndicator_ValidJSON(t *testing.T) {
 	var buf bytes.Buffer
 	f := New("stream-json", &buf)
@@ -93,7 +111,7 @@ func TestStreamJSON_WriteHangIndicator_ValidJSON(t *testing.T) {
 		OpenCallCount: 0,
 		LastEventType: "thinking",
 	}
-	if err := f.WriteHangIndicator(reason); err != nil {
+	if err := f.WriteHangIndicator(reason, time.Time{}); err != nil {
 		t.Fatalf("WriteHangIndicator: %v", err)
 	}
 
@@ -120,7 +138,7 @@ func TestStreamJSON_WriteHangIndicator_EndsWithNewline(t *testing.T) {
 	f := New("stream-json", &buf)
 
 	reason := monitor.Reason{IdleSilenceMS: 1000}
-	if err := f.WriteHangIndicator(reason); err != nil {
+	if err := f.WriteHangIndicator(reason, time.Time{}); err != nil {
 		t.Fatalf("WriteHangIndicator: %v", err)
 	}
 
@@ -129,6 +147,78 @@ func TestStreamJSON_WriteHangIndicator_EndsWithNewline(t *testing.T) {
 	}
 }
 
+func TestStreamJSON_WriteHangIndicator_StderrTail(t *testing.T) {
+	var buf bytes.Buffer
+	f := New("stream-json", &buf)
+
+	reason := monitor.Reason{
+		IdleSilenceMS: 65000,
+		StderrTail:    []string{"Error: rate limit exceeded", "retry in <30s>"},
+	}
+	if err := f.WriteHangIndicator(reason, time.Time{}); err != nil {
+		t.Fatalf("WriteHangIndicator: %v", err)
+	}
+
+	var parsed struct {
+		StderrTail []string `json:"stderr_tail"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v\noutput: %s", err, buf.String())
+	}
+	if len(parsed.StderrTail) != 2 || parsed.StderrTail[1] != "retry in <30s>" {
+		t.Fatalf("stderr_tail = %v, want both lines", parsed.StderrTail)
+	}
+	if strings.Contains(buf.String(), `\u003c`) {
+		t.Errorf("expected unescaped HTML characters, got %s", buf.String())
+	}
+}
+
+func TestStreamJSON_WriteHangIndicator_NoStderrTailOmitsField(t *testing.T) {
+	var buf bytes.Buffer
+	f := New("stream-json", &buf)
+
+	if err := f.WriteHangIndicator(monitor.Reason{IdleSilenceMS: 1000}, time.Time{}); err != nil {
+		t.Fatalf("WriteHangIndicator: %v", err)
+	}
+	if strings.Contains(buf.String(), "stderr_tail") {
+		t.Errorf("expected no stderr_tail field, got %s", buf.String())
+	}
+}
+
+func TestStreamJSON_WriteHangIndicator_ElapsedAsOfKillTime(t *testing.T) {
+	var buf bytes.Buffer
+	f := New("stream-json", &buf)
+
+	checked := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	reason := monitor.Reason{CheckedAt: checked, IdleSilenceMS: 65000, LastEventType: "thinking"}
+	if err := f.WriteHangIndicator(reason, checked.Add(1500*time.Millisecond)); err != nil {
+		t.Fatalf("WriteHangIndicator: %v", err)
+	}
+
+	var parsed struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v\noutput: %s", err, buf.String())
+	}
+	if !strings.Contains(parsed.Message, "idle 66500ms") {
+		t.Errorf("message = %q, want idle advanced to kill time", parsed.Message)
+	}
+}
+
+func TestStreamJSON_WriteBatchHeader(t *testing.T) {
+	var buf bytes.Buffer
+	f := New("stream-json", &buf)
+
+	if err := f.WriteBatchHeader(2, 3, "Review <auth> & tests"); err != nil {
+		t.Fatalf("WriteBatchHeader: %v", err)
+	}
+	want := `{"type":"wrapper","subtype":"batch_prompt","index":2,"total":3,"prompt":"Review <auth> & tests"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 func TestStreamJSON_Flush_NoOp(t *testing.T) {
 	var buf bytes.Buffer
 	f := New("stream-json", &buf)
@@ -167,7 +257,44 @@ func TestText_AssistantEvent_MidTurn(t *testing.T) {
 		t.Fatalf("WriteEvent: %v", err)
 	}
 
-	want := "thinking out loud\n"
+	// The line is left open for more of the same model call.
+	want := "thinking out loud"
+	if got := buf.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got, want := buf.String(), "thinking out loud\n\n"; got != want {
+		t.Fatalf("after Flush: got %q, want %q", got, want)
+	}
+}
+
+func TestText_AssistantEvent_MultipleBlocks(t *testing.T) {
+	raw := `{"type":"assistant","message":{"content":[{"type":"text","text":"First paragraph."},{"type":"tool_use","id":"toolu_1"},{"type":"text","text":"Second paragraph."}]}}`
+	var buf bytes.Buffer
+	f := New("text", &buf)
+
+	if err := f.WriteEvent(annotated(raw)); err != nil {
+		t.Fatalf("WriteEvent: %v", err)
+	}
+
+	want := "First paragraph.\n[tool_use]\nSecond paragraph.\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestText_AssistantEvent_ImageOnly(t *testing.T) {
+	raw := `{"type":"assistant","model_call_id":"mc_7","message":{"content":[{"type":"image","uri":"file:///workspace/docs/diagram.png"}]}}`
+	var buf bytes.Buffer
+	f := New("text", &buf)
+
+	if err := f.WriteEvent(annotated(raw)); err != nil {
+		t.Fatalf("WriteEvent: %v", err)
+	}
+
+	want := "[image: diagram.png]"
 	if got := buf.String(); got != want {
 		t.Fatalf("got %q, want %q", got, want)
 	}
@@ -203,6 +330,49 @@ func TestText_ToolCallStarted_NonShell(t *testing.T) {
 	}
 }
 
+func TestText_ToolCallStarted_FileTools(t *testing.T) {
+	tests := []struct {
+		toolCall, want string
+	}{
+		{`{"readToolCall":{"args":{"path":"go.mod"}}}`, "⏳ readToolCall: go.mod\n"},
+		{`{"readToolCall":{"args":{"path":"main.go","offset":100,"limit":50}}}`, "⏳ readToolCall: main.go (lines 100-149)\n"},
+		{`{"writeToolCall":{"args":{"path":"NOTES.md","fileText":"hi"}}}`, "⏳ writeToolCall: NOTES.md\n"},
+		{`{"deleteToolCall":{"args":{"path":"NOTES.md"}}}`, "⏳ deleteToolCall: NOTES.md\n"},
+		{`{"grepToolCall":{"args":{"pattern":"TODO","path":"cmd","glob":"*.go"}}}`, "⏳ grepToolCall: TODO in cmd (*.go)\n"},
+		{`{"editToolCall":{"args":{"path":"main.go","oldString":"a","newString":"b\nc"}}}`, "⏳ editToolCall: main.go (1 line → 2 lines)\n"},
+	}
+	for _, tt := range tests {
+		raw := `{"type":"tool_call","subtype":"started","call_id":"call_4","model_call_id":"mc_4","timestamp_ms":4000,"tool_call":` + tt.toolCall + `}`
+		var buf bytes.Buffer
+		if err := New("text", &buf).WriteEvent(annotated(raw)); err != nil {
+			t.Fatalf("WriteEvent: %v", err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.toolCall, got, tt.want)
+		}
+	}
+}
+
+func TestText_WrapperNonJSON(t *testing.T) {
+	ev := events.NonJSONEvent(time.Now(), []byte("T: Named models unavailable on free plan"))
+	tests := []struct {
+		color bool
+		want  string
+	}{
+		{false, "T: Named models unavailable on free plan\n"},
+		{true, "\x1b[2mT: Named models unavailable on free plan\x1b[0m\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := New("text", &buf, WithColor(tt.color)).WriteEvent(ev); err != nil {
+			t.Fatalf("WriteEvent: %v", err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("color %v: got %q, want %q", tt.color, got, tt.want)
+		}
+	}
+}
+
 func TestText_ToolCallStarted_NonShell_NoArgs(t *testing.T) {
 	// Unknown tool type with no args extracted by toolCallArgs — should not show trailing ": ".
 	raw := `{"type":"tool_call","subtype":"started","call_id":"call_3","model_call_id":"mc_3","timestamp_ms":3000,"tool_call":{"readToolCall":{"args":{"file":"/etc/hosts"}}}}`
@@ -290,6 +460,55 @@ func TestText_ThinkingCompleted_Silent(t *testing.T) {
 	}
 }
 
+func TestText_Thinking(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration, raw string) events.AnnotatedEvent {
+		ev := annotated(raw)
+		ev.RecvTime = t0.Add(d)
+		return ev
+	}
+	stream := []events.AnnotatedEvent{
+		at(0, `{"type":"thinking","subtype":"delta","text":"Let me look at "}`),
+		at(time.Second, `{"type":"thinking","subtype":"delta","text":"the tests.\nThen the docs.\n"}`),
+		at(1500*time.Millisecond, `{"type":"thinking","subtype":"completed"}`),
+		at(2*time.Second, `{"type":"assistant","message":{"content":[{"type":"text","text":"Done."}]}}`),
+		at(3*time.Second, `{"type":"thinking","subtype":"delta","text":"One more thing"}`),
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"off by default", nil, "Done.\n\n"},
+		{"on", []Option{WithThinking(true)},
+			"💭 thought for 1.5s\n  ┆ Let me look at the tests.\n  ┆ Then the docs.\n" +
+				"Done.\n" +
+				"💭 thinking, cut off\n  ┆ One more thing\n\n"},
+		{"dimmed", []Option{WithThinking(true), WithColor(true)},
+			"\x1b[2m💭 thought for 1.5s\x1b[0m\n\x1b[2m  ┆ Let me look at the tests.\x1b[0m\n\x1b[2m  ┆ Then the docs.\x1b[0m\n" +
+				"Done.\n" +
+				"\x1b[2m💭 thinking, cut off\x1b[0m\n\x1b[2m  ┆ One more thing\x1b[0m\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			f := New("text", &buf, tt.opts...)
+			for _, ev := range stream {
+				if err := f.WriteEvent(ev); err != nil {
+					t.Fatalf("WriteEvent: %v", err)
+				}
+			}
+			// The turn ends mid-thought.
+			if err := f.Flush(); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
 func TestText_SystemInit_Silent(t *testing.T) {
 	raw := `{"type":"system","subtype":"init","session_id":"sess_1","model":"claude","cwd":"/tmp"}`
 	var buf bytes.Buffer
@@ -329,6 +548,199 @@ func TestText_ResultEvent_Silent(t *testing.T) {
 	}
 }
 
+func TestText_ResultEvent_Footer(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			name: "usage",
+			raw:  `{"type":"result","subtype":"success","is_error":false,"model":"claude-4-sonnet","usage":{"inputTokens":12034,"outputTokens":981,"cacheReadTokens":10240,"cacheWriteTokens":1536}}`,
+			want: "· claude-4-sonnet · 12034 in / 981 out tokens (10240 cache read, 1536 cache write)\n",
+		},
+		{
+			name: "error",
+			raw:  `{"type":"result","subtype":"error","is_error":true,"model":"gpt-5","error":{"message":"Rate limit exceeded"}}`,
+			want: "✗ agent error: Rate limit exceeded\n· gpt-5\n",
+		},
+		{
+			name: "error without model",
+			raw:  `{"type":"result","subtype":"error","is_error":true,"error":"model not available"}`,
+			want: "✗ agent error: model not available\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			f := New("text", &buf)
+			if err := f.WriteEvent(annotated(tt.raw)); err != nil {
+				t.Fatalf("WriteEvent: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestText_AssistantEvent_StreamedFragments(t *testing.T) {
+	data, err := os.ReadFile("../events/testdata/assistant_streaming.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	f := New("text", &buf)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if err := f.WriteEvent(annotated(line)); err != nil {
+			t.Fatalf("WriteEvent: %v", err)
+		}
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	// Fragments of a model call are one paragraph, thinking between them
+	// notwithstanding; a tool call or another model call starts a line.
+	want := "I'll run the tests first, then fix what fails.\n" +
+		"⏳ `go test ./...`\n" +
+		"✓ `go test ./...` (2.1s, exit 0)\n" +
+		"They all pass.\nNothing to fix.\n" +
+		"Done\n" +
+		"\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestText_AssistantEvent_FragmentLineEndedBy(t *testing.T) {
+	fragment := `{"type":"assistant","model_call_id":"mc_1","message":{"content":[{"type":"text","text":"partial"}]}}`
+	tests := []struct {
+		name  string
+		event string
+		want  string
+	}{
+		{"final message", `{"type":"assistant","message":{"content":[{"type":"text","text":"All done."}]}}`, "partial\nAll done.\n"},
+		{"non-JSON line", `{"type":"wrapper_nonjson","text":"T: notice"}`, "partial\nT: notice\n"},
+		{"result", `{"type":"result","subtype":"success","model":"gpt-5"}`, "partial\n· gpt-5\n"},
+		{"silent event", `{"type":"thinking","subtype":"completed"}`, "partial"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			f := New("text", &buf)
+			for _, raw := range []string{fragment, tt.event} {
+				if err := f.WriteEvent(annotated(raw)); err != nil {
+					t.Fatalf("WriteEvent: %v", err)
+				}
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	f := New("text", &buf)
+	if err := f.WriteEvent(annotated(fragment)); err != nil {
+		t.Fatalf("WriteEvent: %v", err)
+	}
+	if err := f.WriteHangIndicator(monitor.Reason{IdleSilenceMS: 61000}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "partial\n⚠ Hang detected") {
+		t.Errorf("hang indicator: got %q", buf.String())
+	}
+}
+
+func TestText_ToolOutput_InterleavedCalls(t *testing.T) {
+	data, err := os.ReadFile("../events/testdata/tool_call_streaming.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	f := New("text", &buf, WithToolOutput(true))
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if err := f.WriteEvent(annotated(line)); err != nil {
+			t.Fatalf("WriteEvent: %v", err)
+		}
+	}
+	want := "⏳ `go build ./...`\n" +
+		"⏳ `go test ./internal/...`\n" +
+		"  │ `go test ./internal/...`: ok  \tcursor-wrap/internal/events\t0.412s\n" +
+		"  │ `go build ./...`: # cursor-wrap/cmd/cursor-wrap\n" +
+		"  │ `go test ./internal/...`: ok  \tcursor-wrap/internal/format\t0.019s\n" +
+		"✓ `go build ./...` (3.3s, exit 0)\n" +
+		"  │ ok  \tcursor-wrap/internal/monitor\t8.950s\n" +
+		"✓ `go test ./internal/...` (11.9s, exit 0)\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestText_ToolOutput(t *testing.T) {
+	stream := []string{
+		`{"type":"tool_call","subtype":"started","call_id":"call_1","tool_call":{"shellToolCall":{"args":{"command":"make","timeout":120000}}}}`,
+		`{"type":"tool_call","subtype":"update","call_id":"call_1","stdout":"cc -o a a.c\nlinking"}`,
+		`{"type":"tool_call","subtype":"update","call_id":"call_2","stdout":"not started\n"}`,
+		`{"type":"tool_call","subtype":"completed","call_id":"call_1","tool_call":{"shellToolCall":{"args":{"command":"make"},"result":{"success":{"exitCode":0,"executionTime":2000}}}}}`,
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"off by default", nil, "⏳ `make`\n✓ `make` (2.0s, exit 0)\n"},
+		{"on", []Option{WithToolOutput(true)}, "⏳ `make`\n  │ cc -o a a.c\n  │ linking\n✓ `make` (2.0s, exit 0)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			f := New("text", &buf, tt.opts...)
+			for _, raw := range stream {
+				if err := f.WriteEvent(annotated(raw)); err != nil {
+					t.Fatalf("WriteEvent: %v", err)
+				}
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestText_TruncatedEvent(t *testing.T) {
+	stream := []string{
+		`{"type":"tool_call","subtype":"started","call_id":"call_1","tool_call":{"shellToolCall":{"args":{"command":"cat big.log","timeout":120000}}}}`,
+		`{"type":"tool_call","subtype":"update","call_id":"call_1","stdout":"first line\nsecond"}`,
+		`{"type":"wrapper","subtype":"truncated_event","original_type":"tool_call","original_subtype":"completed","call_id":"call_1","original_bytes":52428800}`,
+		`{"type":"wrapper","subtype":"truncated_event","original_type":"","original_bytes":70000000}`,
+	}
+	var buf bytes.Buffer
+	f := New("text", &buf, WithToolOutput(true))
+	for _, raw := range stream {
+		if err := f.WriteEvent(annotated(raw)); err != nil {
+			t.Fatalf("WriteEvent: %v", err)
+		}
+	}
+	want := "⏳ `cat big.log`\n" +
+		"  │ first line\n" +
+		"  │ second\n" +
+		"⚠ tool_call/completed event not shown: 52428800 bytes, over the size limit\n" +
+		"⚠ unknown event not shown: 70000000 bytes, over the size limit\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// stream-json passes the stand-in on.
+	buf.Reset()
+	if err := New("stream-json", &buf).WriteEvent(annotated(stream[2])); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != stream[2]+"\n" {
+		t.Errorf("stream-json: got %q", buf.String())
+	}
+}
+
 func TestText_UnknownEvent_Silent(t *testing.T) {
 	raw := `{"type":"future_type","subtype":"new_subtype","data":"value"}`
 	var buf bytes.Buffer
@@ -393,7 +805,7 @@ func TestText_WriteHangIndicator(t *testing.T) {
 		OpenCallCount: 0,
 		LastEventType: "thinking",
 	}
-	if err := f.WriteHangIndicator(reason); err != nil {
+	if err := f.WriteHangIndicator(reason, time.Time{}); err != nil {
 		t.Fatalf("WriteHangIndicator: %v", err)
 	}
 
@@ -422,7 +834,7 @@ func TestText_WriteHangIndicator_WithOpenCalls(t *testing.T) {
 			{CallID: "call_1", Command: "npm install", ElapsedMS: 150000, TimeoutMS: 120000},
 		},
 	}
-	if err := f.WriteHangIndicator(reason); err != nil {
+	if err := f.WriteHangIndicator(reason, time.Time{}); err != nil {
 		t.Fatalf("WriteHangIndicator: %v", err)
 	}
 
@@ -432,6 +844,76 @@ func TestText_WriteHangIndicator_WithOpenCalls(t *testing.T) {
 	}
 }
 
+func TestText_WriteHangIndicator_StderrTailIndented(t *testing.T) {
+	var buf bytes.Buffer
+	f := New("text", &buf)
+
+	reason := monitor.Reason{
+		IdleSilenceMS: 65000,
+		StderrTail:    []string{"Error: rate limit exceeded"},
+	}
+	if err := f.WriteHangIndicator(reason, time.Time{}); err != nil {
+		t.Fatalf("WriteHangIndicator: %v", err)
+	}
+
+	got := buf.String()
+	if !strings.Contains(got, "\n    Error: rate limit exceeded\n") {
+		t.Fatalf("expected indented stderr line in output, got %q", got)
+	}
+}
+
+func TestText_WriteHangIndicator_ElapsedAsOfKillTime(t *testing.T) {
+	var buf bytes.Buffer
+	f := New("text", &buf)
+
+	checked := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	reason := monitor.Reason{
+		CheckedAt:     checked,
+		IdleSilenceMS: 150000,
+		OpenCallCount: 2,
+		LastEventType: "tool_call/started",
+		OpenCalls: []monitor.OpenCallDetail{
+			{CallID: "call_2", Command: "sleep 999", ElapsedMS: 150000, TimeoutMS: 10000},
+			{CallID: "call_1", Command: "npm install", ElapsedMS: 150000, TimeoutMS: 120000},
+		},
+		TotalOpenElapsedMS: 300000,
+	}
+	if err := f.WriteHangIndicator(reason, checked.Add(5*time.Second)); err != nil {
+		t.Fatalf("WriteHangIndicator: %v", err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{"idle 155000ms", "elapsed=155000ms", "310000ms open in total"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output, got %q", want, got)
+		}
+	}
+	// Longest overrun is printed first.
+	if strings.Index(got, "call_2") > strings.Index(got, "call_1") {
+		t.Errorf("expected call_2 before call_1, got %q", got)
+	}
+}
+
+func TestText_WriteBatchHeader(t *testing.T) {
+	tests := []struct {
+		prompt string
+		want   string
+	}{
+		{"Review the auth module", "━━━ Prompt 1/30: Review the auth module ━━━\n"},
+		{"# Plan\n\nRefactor the parser.", "━━━ Prompt 1/30: # Plan… ━━━\n"},
+		{strings.Repeat("é", 70), "━━━ Prompt 1/30: " + strings.Repeat("é", 60) + "… ━━━\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := New("text", &buf).WriteBatchHeader(1, 30, tt.prompt); err != nil {
+			t.Fatalf("WriteBatchHeader: %v", err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("WriteBatchHeader(%q) = %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+}
+
 func TestText_Flush_WritesBlankLine(t *testing.T) {
 	var buf bytes.Buffer
 	f := New("text", &buf)
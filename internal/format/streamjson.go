@@ -1,8 +1,9 @@
 package format
 
 import (
-	"fmt"
+	"encoding/json"
 	"io"
+	"time"
 
 	"cursor-wrap/internal/events"
 	"cursor-wrap/internal/monitor"
@@ -10,12 +11,19 @@ import (
 
 // streamJSON is a transparent passthrough formatter — writes the raw JSON
 // line plus a newline. With this formatter, cursor-agent events on the
-// wrapper's stdout are byte-identical to cursor-agent's stdout.
+// wrapper's stdout are byte-identical to cursor-agent's stdout. The
+// wrapper's own events (wrapper_nonjson) are dropped unless wrapperEvents;
+// a wrapper/truncated_event standing in for an event over the size limit
+// is passed on like the event would have been.
 type streamJSON struct {
-	w io.Writer
+	w             io.Writer
+	wrapperEvents bool
 }
 
 func (f *streamJSON) WriteEvent(ev events.AnnotatedEvent) error {
+	if ev.Parsed.Type == events.TypeWrapperNonJSON && !f.wrapperEvents {
+		return nil
+	}
 	if _, err := f.w.Write(ev.Raw); err != nil {
 		return err
 	}
@@ -23,11 +31,47 @@ func (f *streamJSON) WriteEvent(ev events.AnnotatedEvent) error {
 	return err
 }
 
-func (f *streamJSON) WriteHangIndicator(reason monitor.Reason) error {
-	msg := fmt.Sprintf(`{"type":"wrapper","subtype":"hang_detected","message":%q}`+"\n",
-		reason.String())
-	_, err := io.WriteString(f.w, msg)
-	return err
+// hangIndicator is the synthetic wrapper/hang_detected event.
+type hangIndicator struct {
+	Type       string   `json:"type"`
+	Subtype    string   `json:"subtype"`
+	Message    string   `json:"message"`
+	StderrTail []string `json:"stderr_tail,omitempty"`
+}
+
+func (f *streamJSON) WriteHangIndicator(reason monitor.Reason, now time.Time) error {
+	reason = reason.AsOf(now)
+	// Encoder appends the trailing newline; HTML escaping is disabled so
+	// commands containing <, > or & stay readable.
+	enc := json.NewEncoder(f.w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(hangIndicator{
+		Type:       "wrapper",
+		Subtype:    "hang_detected",
+		Message:    reason.String(),
+		StderrTail: reason.StderrTail,
+	})
+}
+
+// batchHeader is the synthetic wrapper/batch_prompt event.
+type batchHeader struct {
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+	Index   int    `json:"index"`
+	Total   int    `json:"total"`
+	Prompt  string `json:"prompt"`
+}
+
+func (f *streamJSON) WriteBatchHeader(index, total int, prompt string) error {
+	enc := json.NewEncoder(f.w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(batchHeader{
+		Type:    "wrapper",
+		Subtype: "batch_prompt",
+		Index:   index,
+		Total:   total,
+		Prompt:  prompt,
+	})
 }
 
 func (f *streamJSON) Flush() error { return nil }
@@ -1,10 +1,12 @@
 package format
 
 import (
-	"encoding/json"
+	"cmp"
 	"fmt"
 	"io"
 	"log/slog"
+	"strings"
+	"time"
 
 	"cursor-wrap/internal/events"
 	"cursor-wrap/internal/monitor"
@@ -13,48 +15,226 @@ import (
 // text renders a human-readable view of the agent's activity.
 // This is the default format for interactive mode.
 type text struct {
-	w io.Writer
+	w     io.Writer
+	color bool // dim the agent's non-JSON lines and the result footer
+	// toolOutput shows the output running tools stream, under their ⏳
+	// lines. calls holds the calls open this turn, by call_id.
+	toolOutput bool
+	calls      map[string]*streamedCall
+	// thinking collects the agent's thinking phases to show; nil unless
+	// WithThinking.
+	thinking *events.ThinkingAccumulator
+	// openLine is the model call of the assistant text ending the output
+	// so far, whose line is left open for the next fragment of the same
+	// call; "" when the last line is ended.
+	openLine string
+}
+
+// streamedCall is a running tool whose streamed output text shows.
+type streamedCall struct {
+	label   string    // the tool as its ⏳ line names it
+	pending [2]string // stdout, stderr: a last line not yet ended
 }
 
 func (f *text) WriteEvent(ev events.AnnotatedEvent) error {
-	switch ev.Parsed.Type {
-	case "assistant":
-		return f.writeAssistant(ev)
-	case "tool_call":
-		switch ev.Parsed.Subtype {
-		case "started":
-			return f.writeToolCallStarted(ev)
-		case "completed":
-			return f.writeToolCallCompleted(ev)
-		}
-	}
-	// Silent: system/init, user, thinking/delta, thinking/completed,
-	// result, and unknown event types.
+	if f.thinking != nil {
+		if phase, ok := f.thinking.Add(ev); ok {
+			if err := f.writeThinking(phase); err != nil {
+				return err
+			}
+		}
+	}
+	payload, err := events.Decode(ev)
+	if err != nil {
+		slog.Debug("text formatter: skipping event", "type", ev.Parsed.Type, "subtype", ev.Parsed.Subtype, "error", err)
+		return nil
+	}
+	switch p := payload.(type) {
+	case events.AssistantMessage:
+		return f.writeAssistant(p)
+	case events.ToolCallStarted:
+		return f.writeToolCallStarted(p)
+	case events.ToolCallCompleted:
+		return f.writeToolCallCompleted(p)
+	case events.ToolCallUpdate:
+		if f.toolOutput {
+			return f.writeToolCallUpdate(p)
+		}
+	case events.Result:
+		return f.writeResult(p)
+	case events.WrapperNonJSON:
+		if ev.Parsed.Type == events.TypeWrapperNonJSON {
+			return f.writeNonJSON(p)
+		}
+	case events.TruncatedEvent:
+		return f.writeTruncated(p)
+	}
+	// Silent: system/init, user (the user's own prompt, echoed),
+	// thinking/delta and thinking/completed (shown above with
+	// WithThinking), tool_call updates without WithToolOutput, the partial
+	// last line (which the wrapper only logs), and unknown event types.
 	return nil
 }
 
-func (f *text) writeAssistant(ev events.AnnotatedEvent) error {
-	msg, err := events.ParseAssistantMessage(ev.Raw)
-	if err != nil {
-		slog.Debug("text formatter: skipping assistant event", "error", err)
+// writeAssistant shows an assistant message. A mid-turn message's line
+// is left open: an agent streaming its response sends it in fragments,
+// consecutive messages of the same model call, which are appended to
+// one another as they come. The line is ended by a message of another
+// call, or anything else being shown.
+func (f *text) writeAssistant(msg events.AssistantMessage) error {
+	lines := make([]string, len(msg.Blocks))
+	for i, block := range msg.Blocks {
+		lines[i] = blockText(block)
+	}
+	text := strings.Join(lines, "\n")
+	if msg.ModelCallID != "" && msg.ModelCallID == f.openLine {
+		_, err := io.WriteString(f.w, text)
+		return err
+	}
+	if err := f.endLine(); err != nil {
+		return err
+	}
+	if msg.IsFinal {
+		_, err := fmt.Fprintf(f.w, "%s\n", text)
+		return err
+	}
+	f.openLine = msg.ModelCallID
+	_, err := io.WriteString(f.w, text)
+	return err
+}
+
+// endLine ends the assistant text's line, if it is left open.
+func (f *text) endLine() error {
+	if f.openLine == "" {
 		return nil
 	}
-	_, err = fmt.Fprintf(f.w, "%s\n", msg.Text)
+	f.openLine = ""
+	_, err := io.WriteString(f.w, "\n")
 	return err
 }
 
-func (f *text) writeToolCallStarted(ev events.AnnotatedEvent) error {
-	var started events.ToolCallStarted
-	if err := json.Unmarshal(ev.Raw, &started); err != nil {
-		slog.Debug("text formatter: skipping tool_call/started event", "error", err)
+// blockText returns how an assistant content block reads: its text, or
+// a placeholder such as "[image: diagram.png]" for anything else.
+func blockText(block events.ContentBlock) string {
+	switch {
+	case block.Type == "text":
+		return block.Text
+	case block.Summary != "":
+		return "[" + block.Type + ": " + block.Summary + "]"
+	default:
+		return "[" + block.Type + "]"
+	}
+}
+
+// writeResult shows a footer with the turn's model and token usage, and
+// the agent's explanation of a failed turn. Older agent builds report
+// neither, and their result events stay silent.
+func (f *text) writeResult(result events.Result) error {
+	if err := f.endLine(); err != nil {
+		return err
+	}
+	if result.IsError && result.ErrorMessage != "" {
+		if _, err := fmt.Fprintf(f.w, "✗ agent error: %s\n", result.ErrorMessage); err != nil {
+			return err
+		}
+	}
+	var parts []string
+	if result.Model != "" {
+		parts = append(parts, result.Model)
+	}
+	if u := result.Usage; u != nil {
+		parts = append(parts, fmt.Sprintf("%d in / %d out tokens (%d cache read, %d cache write)", u.InputTokens, u.OutputTokens, u.CacheReadTokens, u.CacheWriteTokens))
+	}
+	if len(parts) == 0 {
 		return nil
 	}
+	footer := "· " + strings.Join(parts, " · ")
+	if f.color {
+		footer = "\x1b[2m" + footer + "\x1b[0m"
+	}
+	_, err := fmt.Fprintf(f.w, "%s\n", footer)
+	return err
+}
+
+// writeThinking shows a thinking phase: how long it took, then its text,
+// dimmed. A phase the turn ended in the middle of has no length.
+func (f *text) writeThinking(phase events.ThinkingPhase) error {
+	if err := f.endLine(); err != nil {
+		return err
+	}
+	lines := []string{"💭 thinking, cut off"}
+	if !phase.End.IsZero() {
+		lines[0] = fmt.Sprintf("💭 thought for %.1fs", phase.Duration(phase.End).Seconds())
+	}
+	if text := strings.TrimSpace(phase.Text); text != "" {
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, "  ┆ "+line)
+		}
+	}
+	for _, line := range lines {
+		if f.color {
+			line = "\x1b[2m" + line + "\x1b[0m"
+		}
+		if _, err := fmt.Fprintf(f.w, "%s\n", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeNonJSON shows a line the agent wrote that isn't an event, such as
+// "T: Named models unavailable on free plan": often the explanation for
+// what happens next.
+func (f *text) writeNonJSON(line events.WrapperNonJSON) error {
+	if err := f.endLine(); err != nil {
+		return err
+	}
+	if f.color {
+		_, err := fmt.Fprintf(f.w, "\x1b[2m%s\x1b[0m\n", line.Text)
+		return err
+	}
+	_, err := fmt.Fprintf(f.w, "%s\n", line.Text)
+	return err
+}
+
+// writeTruncated notes an event too big to show, in place of it.
+func (f *text) writeTruncated(t events.TruncatedEvent) error {
+	if t.OriginalType == "tool_call" && t.OriginalSubtype == "completed" {
+		if err := f.finishToolOutput(t.CallID); err != nil {
+			return err
+		}
+	}
+	if err := f.endLine(); err != nil {
+		return err
+	}
+	what := cmp.Or(t.OriginalType, "unknown")
+	if t.OriginalSubtype != "" {
+		what += "/" + t.OriginalSubtype
+	}
+	_, err := fmt.Fprintf(f.w, "⚠ %s event not shown: %d bytes, over the size limit\n", what, t.OriginalBytes)
+	return err
+}
 
+func (f *text) writeToolCallStarted(started events.ToolCallStarted) error {
 	info, err := events.ParseToolCallInfo(started.ToolCall)
 	if err != nil {
 		slog.Debug("text formatter: skipping tool_call/started event", "error", err)
 		return nil
 	}
+	if err := f.endLine(); err != nil {
+		return err
+	}
+
+	if f.toolOutput {
+		label := info.ToolType
+		if info.ToolType == "shellToolCall" {
+			label = "`" + info.Command + "`"
+		}
+		if f.calls == nil {
+			f.calls = make(map[string]*streamedCall)
+		}
+		f.calls[started.CallID] = &streamedCall{label: label}
+	}
 
 	if info.ToolType == "shellToolCall" {
 		_, err = fmt.Fprintf(f.w, "⏳ `%s`\n", info.Command)
@@ -66,18 +246,76 @@ func (f *text) writeToolCallStarted(ev events.AnnotatedEvent) error {
 	return err
 }
 
-func (f *text) writeToolCallCompleted(ev events.AnnotatedEvent) error {
-	var completed events.ToolCallCompleted
-	if err := json.Unmarshal(ev.Raw, &completed); err != nil {
-		slog.Debug("text formatter: skipping tool_call/completed event", "error", err)
+// writeToolCallUpdate shows the lines a running tool has streamed, each
+// under its ⏳ line. A line is shown once it ends, or the tool does; with
+// several tools running, each line is labelled with its tool.
+func (f *text) writeToolCallUpdate(update events.ToolCallUpdate) error {
+	call, ok := f.calls[update.CallID]
+	if !ok {
+		return nil // started before the formatter was told, or not a call
+	}
+	for i, chunk := range [2]string{update.Stdout, update.Stderr} {
+		text := call.pending[i] + chunk
+		end := strings.LastIndexByte(text, '\n')
+		call.pending[i] = text[end+1:]
+		if end < 0 {
+			continue
+		}
+		if err := f.writeToolOutput(call, strings.Split(text[:end], "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishToolOutput shows what is left of a finished tool's output, and
+// forgets the call.
+func (f *text) finishToolOutput(callID string) error {
+	call, ok := f.calls[callID]
+	if !ok {
 		return nil
 	}
+	defer delete(f.calls, callID)
+	for _, rest := range call.pending {
+		if rest == "" {
+			continue
+		}
+		if err := f.writeToolOutput(call, []string{rest}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *text) writeToolOutput(call *streamedCall, lines []string) error {
+	if err := f.endLine(); err != nil {
+		return err
+	}
+	prefix := "  │ "
+	if len(f.calls) > 1 {
+		prefix += call.label + ": "
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(f.w, "%s%s\n", prefix, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *text) writeToolCallCompleted(completed events.ToolCallCompleted) error {
+	if err := f.finishToolOutput(completed.CallID); err != nil {
+		return err
+	}
 
 	info, err := events.ParseToolCallInfo(completed.ToolCall)
 	if err != nil {
 		slog.Debug("text formatter: skipping tool_call/completed event", "error", err)
 		return nil
 	}
+	if err := f.endLine(); err != nil {
+		return err
+	}
 
 	if info.ToolType == "shellToolCall" {
 		result, err := events.ParseShellToolResult(completed.ToolCall)
@@ -101,19 +339,84 @@ func (f *text) writeToolCallCompleted(ev events.AnnotatedEvent) error {
 // toolCallArgs returns a display-friendly summary of non-shell tool args.
 func toolCallArgs(info events.ToolCallInfo) string {
 	switch info.ToolType {
-	case "lsToolCall":
+	case "lsToolCall", "writeToolCall", "deleteToolCall":
+		return info.Path
+	case "readToolCall":
+		if info.Path != "" && info.Limit > 0 {
+			return fmt.Sprintf("%s (lines %d-%d)", info.Path, max(info.Offset, 1), max(info.Offset, 1)+info.Limit-1)
+		}
+		return info.Path
+	case "grepToolCall":
+		args := info.Pattern
+		if info.Path != "" {
+			args += " in " + info.Path
+		}
+		if len(info.Globs) > 0 {
+			args += " (" + strings.Join(info.Globs, ", ") + ")"
+		}
+		return args
+	case "editToolCall":
+		if info.Path != "" && info.EditSummary != "" {
+			return info.Path + " (" + info.EditSummary + ")"
+		}
 		return info.Path
 	default:
 		return ""
 	}
 }
 
-func (f *text) WriteHangIndicator(reason monitor.Reason) error {
-	_, err := fmt.Fprintf(f.w, "⚠ Hang detected — killed cursor-agent (%s)\n", reason.String())
+func (f *text) WriteHangIndicator(reason monitor.Reason, now time.Time) error {
+	reason = reason.AsOf(now)
+	if err := f.endLine(); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f.w, "⚠ Hang detected — killed cursor-agent (%s)\n", reason.String()); err != nil {
+		return err
+	}
+	if len(reason.StderrTail) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(f.w, "  last stderr from cursor-agent:"); err != nil {
+		return err
+	}
+	for _, line := range reason.StderrTail {
+		if _, err := fmt.Fprintf(f.w, "    %s\n", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchHeaderWidth bounds the prompt shown in a batch header.
+const batchHeaderWidth = 60
+
+func (f *text) WriteBatchHeader(index, total int, prompt string) error {
+	if err := f.endLine(); err != nil {
+		return err
+	}
+	first, _, more := strings.Cut(prompt, "\n")
+	if r := []rune(first); len(r) > batchHeaderWidth {
+		first, more = string(r[:batchHeaderWidth]), true
+	}
+	if more {
+		first += "…"
+	}
+	_, err := fmt.Fprintf(f.w, "━━━ Prompt %d/%d: %s ━━━\n", index, total, first)
 	return err
 }
 
 func (f *text) Flush() error {
+	clear(f.calls) // calls left open end with the turn
+	if f.thinking != nil && f.thinking.Active() {
+		// Shown as far as it got; the next turn's thinking starts afresh.
+		if err := f.writeThinking(f.thinking.Current()); err != nil {
+			return err
+		}
+		*f.thinking = events.ThinkingAccumulator{}
+	}
+	if err := f.endLine(); err != nil {
+		return err
+	}
 	// Write a blank line to visually separate turns in interactive mode.
 	_, err := f.w.Write([]byte("\n"))
 	return err
@@ -0,0 +1,332 @@
+// Package lineedit reads lines typed at a terminal with the editing keys
+// of a shell: the cursor moves with the arrow keys, text is inserted and
+// deleted at it, and the up and down arrows step through earlier lines.
+// It is a minimal editor: every character is taken to be one column wide.
+package lineedit
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Keys, as read in raw mode.
+const (
+	keyCtrlA     = 0x01
+	keyCtrlB     = 0x02
+	keyCtrlC     = 0x03
+	keyCtrlD     = 0x04
+	keyCtrlE     = 0x05
+	keyCtrlF     = 0x06
+	keyCtrlH     = 0x08
+	keyTab       = 0x09
+	keyLF        = 0x0a
+	keyCtrlK     = 0x0b
+	keyCtrlL     = 0x0c
+	keyCR        = 0x0d
+	keyCtrlN     = 0x0e
+	keyCtrlP     = 0x10
+	keyCtrlU     = 0x15
+	keyCtrlW     = 0x17
+	keyCtrlZ     = 0x1a
+	keyEscape    = 0x1b
+	keyBackspace = 0x7f
+)
+
+// defaultWidth is the width lines are wrapped at when the terminal's is
+// unknown.
+const defaultWidth = 80
+
+// Editor reads lines from a terminal. Between calls to ReadLine the
+// terminal is left as it was found, so output and other reads of in
+// carry on as without an editor.
+type Editor struct {
+	in      io.RuneReader
+	out     io.Writer
+	fd      int      // the terminal, in raw mode during ReadLine; < 0 for none
+	history []string // oldest first
+	width   int      // overrides the terminal's width, for tests
+}
+
+// New returns an editor reading keys from in, which should be the only
+// reader of the terminal fd, and echoing to out.
+func New(in io.RuneReader, out io.Writer, fd int) *Editor {
+	return &Editor{in: in, out: out, fd: fd}
+}
+
+// SetHistory replaces the lines the up arrow steps back through, oldest
+// first.
+func (e *Editor) SetHistory(lines []string) {
+	e.history = slices.Clone(lines)
+}
+
+// AddHistory adds line to the end of the history, unless it is empty or
+// repeats the line before it.
+func (e *Editor) AddHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+// ReadLine writes prompt and returns the line typed after it, without
+// its newline. Ctrl+C discards the line typed so far and starts another;
+// Ctrl+D on an empty line returns io.EOF.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	restore := func() {}
+	if e.fd >= 0 {
+		var err error
+		if restore, err = makeRaw(e.fd); err != nil {
+			return "", fmt.Errorf("line editor: %w", err)
+		}
+	}
+	defer func() { restore() }() // the latest, after a Ctrl+Z
+	l := &line{e: e, prompt: prompt, hist: len(e.history)}
+	l.refresh()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(l.buf) > 0 {
+				return l.accept(), nil // a last line without a newline
+			}
+			l.write("\r\n")
+			return "", err
+		}
+		switch r {
+		case keyCR, keyLF:
+			return l.accept(), nil
+		case keyCtrlC:
+			l.pos = len(l.buf)
+			l.refresh()
+			l.write("^C\r\n")
+			l.buf, l.pos, l.rows, l.hist = nil, 0, 0, len(e.history)
+		case keyCtrlD:
+			if len(l.buf) == 0 {
+				l.write("\r\n")
+				return "", io.EOF
+			}
+			l.deleteAt(l.pos)
+		case keyBackspace, keyCtrlH:
+			if l.pos > 0 {
+				l.pos--
+				l.deleteAt(l.pos)
+			}
+		case keyCtrlA:
+			l.pos = 0
+		case keyCtrlE:
+			l.pos = len(l.buf)
+		case keyCtrlB:
+			l.left()
+		case keyCtrlF:
+			l.right()
+		case keyCtrlK:
+			l.buf = l.buf[:l.pos]
+		case keyCtrlU:
+			l.buf = slices.Delete(l.buf, 0, l.pos)
+			l.pos = 0
+		case keyCtrlW:
+			l.deleteWordBack()
+		case keyCtrlP:
+			l.historyStep(-1)
+		case keyCtrlN:
+			l.historyStep(1)
+		case keyCtrlL:
+			l.write("\x1b[H\x1b[2J")
+			l.rows = 0
+		case keyCtrlZ:
+			if e.fd >= 0 {
+				l.write("\r\n")
+				restore()
+				suspend()
+				var err error
+				if restore, err = makeRaw(e.fd); err != nil {
+					restore = func() {}
+					return "", fmt.Errorf("line editor: %w", err)
+				}
+				l.rows = 0
+			}
+		case keyEscape:
+			if err := l.escape(); err != nil {
+				return "", err
+			}
+		case keyTab:
+			l.insert(' ') // a tab's width depends on its column
+		default:
+			if unicode.IsPrint(r) {
+				l.insert(r)
+			}
+		}
+		l.refresh()
+	}
+}
+
+// line is a line being edited.
+type line struct {
+	e      *Editor
+	prompt string
+	buf    []rune
+	pos    int    // the cursor, an index into buf
+	rows   int    // rows from the prompt's down to the cursor's, as last drawn
+	hist   int    // index into e.history of the line shown; len for a new one
+	draft  []rune // the new line, while an earlier one is shown
+}
+
+func (l *line) write(s string) {
+	_, _ = io.WriteString(l.e.out, s) // nothing to do about a terminal that can't be written
+}
+
+func (l *line) insert(r rune) {
+	l.buf = slices.Insert(l.buf, l.pos, r)
+	l.pos++
+}
+
+func (l *line) deleteAt(i int) {
+	if i < len(l.buf) {
+		l.buf = slices.Delete(l.buf, i, i+1)
+	}
+}
+
+func (l *line) left() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *line) right() {
+	if l.pos < len(l.buf) {
+		l.pos++
+	}
+}
+
+// deleteWordBack deletes the word before the cursor and the spaces after
+// it.
+func (l *line) deleteWordBack() {
+	start := l.pos
+	for start > 0 && unicode.IsSpace(l.buf[start-1]) {
+		start--
+	}
+	for start > 0 && !unicode.IsSpace(l.buf[start-1]) {
+		start--
+	}
+	l.buf = slices.Delete(l.buf, start, l.pos)
+	l.pos = start
+}
+
+// historyStep shows the history line step lines from the one shown,
+// keeping the new line's text to come back to.
+func (l *line) historyStep(step int) {
+	next := l.hist + step
+	if next < 0 || next > len(l.e.history) {
+		return
+	}
+	if l.hist == len(l.e.history) {
+		l.draft = slices.Clone(l.buf)
+	}
+	l.hist = next
+	if next == len(l.e.history) {
+		l.buf = l.draft
+	} else {
+		l.buf = []rune(l.e.history[next])
+	}
+	l.pos = len(l.buf)
+}
+
+// escape handles the rest of an escape sequence: the arrow, Home, End
+// and Delete keys, in either of the forms terminals send them.
+func (l *line) escape() error {
+	r, _, err := l.e.in.ReadRune()
+	if err != nil {
+		return err
+	}
+	var params strings.Builder
+	switch r {
+	case '[':
+		// CSI: parameters, then a final byte in @ to ~.
+		for {
+			if r, _, err = l.e.in.ReadRune(); err != nil {
+				return err
+			}
+			if r >= '@' && r <= '~' {
+				break
+			}
+			params.WriteRune(r)
+		}
+	case 'O':
+		if r, _, err = l.e.in.ReadRune(); err != nil {
+			return err
+		}
+	default:
+		return nil // Alt with a key; not bound
+	}
+	switch r {
+	case 'A':
+		l.historyStep(-1)
+	case 'B':
+		l.historyStep(1)
+	case 'C':
+		l.right()
+	case 'D':
+		l.left()
+	case 'H':
+		l.pos = 0
+	case 'F':
+		l.pos = len(l.buf)
+	case '~':
+		switch params.String() {
+		case "1", "7":
+			l.pos = 0
+		case "4", "8":
+			l.pos = len(l.buf)
+		case "3":
+			l.deleteAt(l.pos)
+		}
+	}
+	return nil
+}
+
+// accept ends the line, leaving the cursor on the next one, and returns
+// it.
+func (l *line) accept() string {
+	l.pos = len(l.buf)
+	l.refresh()
+	l.write("\r\n")
+	return string(l.buf)
+}
+
+// refresh redraws the prompt and the line, which may wrap over several
+// rows, and puts the cursor in place.
+func (l *line) refresh() {
+	width := l.e.width
+	if width <= 0 {
+		width = termWidth(l.e.fd)
+	}
+	if width <= 0 {
+		width = defaultWidth
+	}
+	var b strings.Builder
+	if l.rows > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", l.rows)
+	}
+	b.WriteString("\r\x1b[J")
+	b.WriteString(l.prompt)
+	b.WriteString(string(l.buf))
+	promptCols := len([]rune(l.prompt))
+	end := promptCols + len(l.buf)
+	if end > 0 && end%width == 0 {
+		// The terminal holds the cursor at the end of a full row until
+		// the next character; move it to the next row, where it belongs.
+		b.WriteString("\r\n")
+	}
+	cur := promptCols + l.pos
+	if up := end/width - cur/width; up > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", up)
+	}
+	b.WriteString("\r")
+	if col := cur % width; col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	l.rows = cur / width
+	l.write(b.String())
+}
@@ -0,0 +1,134 @@
+package lineedit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// editor returns an editor reading keys from input, with no terminal.
+func editor(input string) (*Editor, *bytes.Buffer) {
+	var out bytes.Buffer
+	return New(bufio.NewReader(strings.NewReader(input)), &out, -1), &out
+}
+
+func TestReadLine_Editing(t *testing.T) {
+	tests := []struct {
+		name, keys, want string
+	}{
+		{"plain", "hello\r", "hello"},
+		{"newline ends it too", "hello\n", "hello"},
+		{"insert after moving left", "helo\x1b[D\x1b[Dl\r", "hello"},
+		{"ss3 arrows", "helo\x1bOD\x1bODl\r", "hello"},
+		{"backspace", "hellp\x7fo\r", "hello"},
+		{"delete under the cursor", "hxello\x1b[H\x1b[C\x1b[3~\r", "hello"},
+		{"ctrl+d deletes in a line", "hxello\x01\x06\x04\r", "hello"},
+		{"home and end", "ello\x1b[1~h\x1b[4~!\r", "hello!"},
+		{"ctrl+a and ctrl+e", "ello\x01h\x05!\r", "hello!"},
+		{"kill to the end", "hello world\x01\x06\x06\x06\x06\x06\x0b\r", "hello"},
+		{"kill to the start", "oops hello\x01\x1b[C\x1b[C\x1b[C\x1b[C\x1b[C\x15\r", "hello"},
+		{"delete a word", "hello wrold  \x17world\r", "hello world"},
+		{"right stops at the end", "hell\x1b[C\x1b[Co\r", "hello"},
+		{"tab is a space", "a\tb\r", "a b"},
+		{"other control keys ignored", "he\x07llo\r", "hello"},
+		{"ctrl+c starts over", "not this\x03hello\r", "hello"},
+		{"utf-8", "héllo wörld\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x7f\r", "héllowörld"},
+		{"alt-key ignored", "hel\x1bblo\r", "hello"},
+		{"last line without a newline", "hello", "hello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := editor(tt.keys)
+			got, err := e.ReadLine("> ")
+			if err != nil || got != tt.want {
+				t.Errorf("ReadLine = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLine_EOF(t *testing.T) {
+	for _, keys := range []string{"\x04", ""} {
+		e, _ := editor(keys)
+		if got, err := e.ReadLine("> "); !errors.Is(err, io.EOF) {
+			t.Errorf("keys %q: ReadLine = %q, %v; want io.EOF", keys, got, err)
+		}
+	}
+}
+
+func TestReadLine_History(t *testing.T) {
+	e, _ := editor("\x1b[A\x1b[A\r" + // the second to last
+		"\x1b[A\x1b[A\x1b[A\x1b[A\r" + // stops at the oldest
+		"new\x1b[A\x1b[B!\r" + // down comes back to the line being typed
+		"\x10\x10\x10\x0e\r") // ctrl+p and ctrl+n, through the lines just added
+	e.SetHistory([]string{"first", "second", "third"})
+
+	for _, want := range []string{"second", "first", "new!", "first"} {
+		got, err := e.ReadLine("> ")
+		if err != nil || got != want {
+			t.Fatalf("ReadLine = %q, %v; want %q", got, err, want)
+		}
+		e.AddHistory(got)
+	}
+	if got := e.history; len(got) != 7 || got[3] != "second" || got[5] != "new!" || got[6] != "first" {
+		t.Errorf("history = %q", got)
+	}
+	e.AddHistory("first")
+	e.AddHistory("")
+	if len(e.history) != 7 {
+		t.Errorf("history = %q; want repeats and empty lines left out", e.history)
+	}
+}
+
+func TestReadLine_Redraw(t *testing.T) {
+	e, out := editor("abc\x1b[D\r")
+	if _, err := e.ReadLine("> "); err != nil {
+		t.Fatal(err)
+	}
+	// Each key redraws the line from the prompt and puts the cursor back
+	// where it is in the line.
+	if want := "\r\x1b[J> ab\r\x1b[4C"; !strings.Contains(out.String(), want) {
+		t.Errorf("output lacks %q:\n%q", want, out.String())
+	}
+	if want := "\r\x1b[J> abc\r\x1b[4C"; !strings.Contains(out.String(), want) {
+		t.Errorf("cursor not moved left of c:\n%q", out.String())
+	}
+	if !strings.HasSuffix(out.String(), "\r\x1b[J> abc\r\x1b[5C\r\n") {
+		t.Errorf("line not finished at its end:\n%q", out.String())
+	}
+}
+
+func TestReadLine_RedrawWrapped(t *testing.T) {
+	// "> " and 8 characters fill two rows of 5 columns exactly.
+	e, out := editor("abcdefgh\x01\r")
+	e.width = 5
+	if _, err := e.ReadLine("> "); err != nil {
+		t.Fatal(err)
+	}
+	s := out.String()
+	// Full rows: the cursor goes on to a new row, then back up to the
+	// start of the line after ctrl+a...
+	if want := "\r\x1b[J> abcdefgh\r\n\x1b[2A\r\x1b[2C"; !strings.Contains(s, want) {
+		t.Errorf("output lacks %q:\n%q", want, s)
+	}
+	// ...and the next redraw starts from the prompt's row.
+	if want := "\r\x1b[J> abcdefgh\r\n\x1b[2A\r\x1b[2C\r\x1b[J> abcdefgh\r\n\r\r\n"; !strings.HasSuffix(s, want) {
+		t.Errorf("output doesn't end %q:\n%q", want, s)
+	}
+}
+
+func TestIsTerminal_Pipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if IsTerminal(int(r.Fd())) {
+		t.Error("a pipe is not a terminal")
+	}
+}
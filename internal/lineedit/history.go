@@ -0,0 +1,80 @@
+package lineedit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadHistory reads the history file at path, one line per entry, and
+// returns its last limit entries, oldest first. A missing file is an empty
+// history. A file grown past limit entries is cut back to them.
+func LoadHistory(path string, limit int) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		if line := sc.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) <= limit {
+		return lines, nil
+	}
+	lines = lines[len(lines)-limit:]
+	if err := writeHistory(path, lines); err != nil {
+		return lines, err
+	}
+	return lines, nil
+}
+
+// AppendHistory adds line to the history file at path, creating it, and
+// its directory, readable only by the user: prompts may hold secrets.
+func AppendHistory(path, line string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(line + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeHistory replaces the history file at path with lines, by way of a
+// temporary file so a failure leaves the old one.
+func writeHistory(path string, lines []string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(strings.Join(lines, "\n") + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name()) // may not exist after a failed rename
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "history")
+	if lines, err := LoadHistory(path, 10); err != nil || lines != nil {
+		t.Fatalf("missing file: LoadHistory = %q, %v", lines, err)
+	}
+	for _, line := range []string{"first", "second"} {
+		if err := AppendHistory(path, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("history file mode = %o, want 600", perm)
+	}
+	lines, err := LoadHistory(path, 10)
+	if err != nil || !slices.Equal(lines, []string{"first", "second"}) {
+		t.Errorf("LoadHistory = %q, %v", lines, err)
+	}
+}
+
+func TestLoadHistory_Trims(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	for i := range 5 {
+		if err := AppendHistory(path, "line "+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"line 2", "line 3", "line 4"}
+	lines, err := LoadHistory(path, 3)
+	if err != nil || !slices.Equal(lines, want) {
+		t.Fatalf("LoadHistory = %q, %v; want %q", lines, err, want)
+	}
+	// The file was cut back too.
+	if lines, err = LoadHistory(path, 10); err != nil || !slices.Equal(lines, want) {
+		t.Errorf("after trimming, LoadHistory = %q, %v; want %q", lines, err, want)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp-*"); len(matches) != 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package lineedit
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// IsTerminal reports whether fd is a terminal the editor can drive.
+func IsTerminal(fd int) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// makeRaw puts the terminal fd in raw mode, keys arriving one at a time
+// without echo and Ctrl+C, Ctrl+Z and Ctrl+D as plain bytes, and returns
+// a func restoring its previous mode. Output processing is left on.
+func makeRaw(fd int) (restore func(), err error) {
+	old, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+	raw := *old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := setTermios(fd, &raw); err != nil {
+		return nil, err
+	}
+	return func() { _ = setTermios(fd, old) }, nil // nothing to do if it fails
+}
+
+// termWidth returns the terminal's width in columns, or 0 if unknown.
+func termWidth(fd int) int {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 {
+		return 0
+	}
+	return int(ws.Col)
+}
+
+// suspend stops the wrapper as Ctrl+Z would outside raw mode, returning
+// once it is continued.
+func suspend() {
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGTSTP)
+}
+
+func getTermios(fd int) (*syscall.Termios, error) {
+	var t syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCGETS, uintptr(unsafe.Pointer(&t))); errno != 0 {
+		return nil, errno
+	}
+	return &t, nil
+}
+
+func setTermios(fd int, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TCSETS, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package lineedit
+
+import "errors"
+
+// IsTerminal reports whether fd is a terminal the editor can drive: none
+// outside Linux, where prompts are read as plain lines.
+func IsTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("line editing is only supported on Linux")
+}
+
+func termWidth(fd int) int { return 0 }
+
+func suspend() {}
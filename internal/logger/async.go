@@ -0,0 +1,192 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncMode says whether file records are written on the logging
+// goroutine or queued for a writer goroutine, and what happens when the
+// queue is full.
+type AsyncMode int
+
+const (
+	AsyncOff   AsyncMode = iota // write before the log call returns
+	AsyncBlock                  // queue; a full queue makes the caller wait
+	AsyncDrop                   // queue; a full queue drops the record, counted
+)
+
+// DefaultQueueSize is the async queue length used when LogConfig.QueueSize
+// is 0.
+const DefaultQueueSize = 4096
+
+func (m AsyncMode) String() string {
+	switch m {
+	case AsyncOff:
+		return "off"
+	case AsyncBlock:
+		return "block"
+	case AsyncDrop:
+		return "drop"
+	default:
+		return fmt.Sprintf("AsyncMode(%d)", int(m))
+	}
+}
+
+// ParseAsyncMode parses the names printed by AsyncMode.String.
+func ParseAsyncMode(s string) (AsyncMode, error) {
+	switch s {
+	case "off":
+		return AsyncOff, nil
+	case "block":
+		return AsyncBlock, nil
+	case "drop":
+		return AsyncDrop, nil
+	default:
+		return AsyncOff, fmt.Errorf("unsupported log async mode %q: want off, block or drop", s)
+	}
+}
+
+// queued is a record waiting for the writer goroutine, with the handler
+// (carrying its WithAttrs/WithGroup) it is for. done, if set, is closed
+// once it is written; a queued with no handler is a flush marker.
+type queued struct {
+	h    slog.Handler
+	r    slog.Record
+	done chan struct{}
+}
+
+// logQueue is the writer goroutine of an async file sink and the bounded
+// queue feeding it. Records are written in the order they were queued.
+type logQueue struct {
+	mode      AsyncMode
+	rawEvents bool // queue raw events too, instead of waiting for them
+	guard     *writeGuard
+
+	mu       sync.RWMutex // held for reading while queueing, for writing by close
+	closed   bool
+	ch       chan queued
+	stopped  chan struct{}
+	overflow atomic.Int64 // records dropped because the queue was full
+}
+
+func newLogQueue(mode AsyncMode, size int, rawEvents bool, guard *writeGuard) *logQueue {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	q := &logQueue{
+		mode:      mode,
+		rawEvents: rawEvents,
+		guard:     guard,
+		ch:        make(chan queued, size),
+		stopped:   make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *logQueue) run() {
+	defer close(q.stopped)
+	for item := range q.ch {
+		if item.h != nil {
+			_ = item.h.Handle(context.Background(), item.r) // the guard sees failures
+		}
+		if item.done != nil {
+			close(item.done)
+		}
+	}
+}
+
+// put queues r for h. Raw events, unless rawEvents is set, are waited
+// for: they are the forensic record and keep their guarantee of being
+// written before the log call returns. After close, records are written
+// directly.
+func (q *logQueue) put(ctx context.Context, h slog.Handler, r slog.Record) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return h.Handle(ctx, r)
+	}
+	item := queued{h: h, r: r.Clone()}
+	if r.Message == "raw_event" && !q.rawEvents {
+		item.done = make(chan struct{})
+		q.ch <- item
+		<-item.done
+		return nil
+	}
+	if q.mode == AsyncDrop {
+		select {
+		case q.ch <- item:
+		default:
+			q.overflow.Add(1)
+		}
+		return nil
+	}
+	q.ch <- item
+	return nil
+}
+
+// flush waits until every record queued so far is written.
+func (q *logQueue) flush() {
+	if q == nil {
+		return
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return
+	}
+	done := make(chan struct{})
+	q.ch <- queued{done: done}
+	<-done
+}
+
+// close writes out the queue and stops the writer goroutine.
+func (q *logQueue) close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+	q.mu.Unlock()
+	<-q.stopped
+}
+
+// Overflow returns how many records AsyncDrop dropped; 0 for a nil
+// queue (a synchronous sink).
+func (q *logQueue) Overflow() int64 {
+	if q == nil {
+		return 0
+	}
+	return q.overflow.Load()
+}
+
+// asyncHandler hands the file sink's records to a logQueue.
+type asyncHandler struct {
+	slog.Handler
+	q *logQueue
+}
+
+func (h *asyncHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.q.guard.failed.Load() {
+		// Queueing would be pointless, and may be what the writer
+		// goroutine itself is doing to warn about the failure.
+		h.q.guard.dropped.Add(1)
+		return nil
+	}
+	return h.q.put(ctx, h.Handler, r)
+}
+
+func (h *asyncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &asyncHandler{Handler: h.Handler.WithAttrs(attrs), q: h.q}
+}
+
+func (h *asyncHandler) WithGroup(name string) slog.Handler {
+	return &asyncHandler{Handler: h.Handler.WithGroup(name), q: h.q}
+}
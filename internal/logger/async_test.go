@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAsync_WritesEverythingInOrder(t *testing.T) {
+	for _, mode := range []AsyncMode{AsyncBlock, AsyncDrop} {
+		t.Run(mode.String(), func(t *testing.T) {
+			ls, teardown := Setup(LogConfig{Dir: t.TempDir(), ConsoleLevel: slog.LevelError + 1, FileLevel: slog.LevelDebug, Async: mode, QueueSize: 1 << 16})
+			var wg sync.WaitGroup
+			for g := range 4 {
+				wg.Go(func() {
+					log := ls.WithTurn(g)
+					for i := range 500 {
+						log.Debug("record", "i", i)
+					}
+				})
+			}
+			wg.Wait()
+			ls.Flush()
+			if err := teardown(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(ls.FilePath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := map[string]int{} // turn -> next i
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				var turn, i int
+				if _, err := fmt.Sscanf(line[strings.Index(line, `"turn":`):], `"turn":%d,"kind":"decision","i":%d}`, &turn, &i); err != nil {
+					t.Fatalf("%v: %s", err, line)
+				}
+				key := fmt.Sprint(turn)
+				if i != next[key] {
+					t.Fatalf("turn %d: record %d follows %d", turn, i, next[key]-1)
+				}
+				next[key]++
+			}
+			if len(next) != 4 {
+				t.Errorf("records from %d goroutines, want 4", len(next))
+			}
+			for turn, n := range next {
+				if n != 500 {
+					t.Errorf("turn %s: %d records, want 500", turn, n)
+				}
+			}
+		})
+	}
+}
+
+func TestAsync_RawEventsWrittenBeforeReturn(t *testing.T) {
+	ls, teardown := Setup(LogConfig{Dir: t.TempDir(), ConsoleLevel: slog.LevelError + 1, FileLevel: slog.LevelDebug, Async: AsyncBlock})
+	defer teardown()
+
+	ls.Debug("queued")
+	ls.Debug("raw_event", "raw", `{"type":"thinking"}`)
+	data, err := os.ReadFile(ls.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Written in order, so the record queued ahead of it is there too.
+	if !strings.Contains(string(data), `"msg":"queued"`) || !strings.Contains(string(data), `"msg":"raw_event"`) {
+		t.Errorf("log after raw_event returned:\n%s", data)
+	}
+}
+
+// stallingHandler holds up the writer goroutine until released.
+type stallingHandler struct {
+	slog.Handler
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (h *stallingHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.once.Do(func() { close(h.started) })
+	<-h.release
+	return h.Handler.Handle(ctx, r)
+}
+
+func TestAsync_DropCountsOverflow(t *testing.T) {
+	var out strings.Builder
+	var mu sync.Mutex
+	stall := &stallingHandler{
+		Handler: slog.NewTextHandler(writerFunc(func(p []byte) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return out.Write(p)
+		}), nil),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	q := newLogQueue(AsyncDrop, 1, false, &writeGuard{})
+	log := slog.New(&asyncHandler{Handler: stall, q: q})
+
+	log.Info("taken")
+	<-stall.started // the writer is stuck on the first record
+	log.Info("queued")
+	for range 3 {
+		log.Info("dropped")
+	}
+	close(stall.release)
+	q.close()
+
+	if q.Overflow() != 3 {
+		t.Errorf("Overflow = %d, want 3", q.Overflow())
+	}
+	if got := out.String(); !strings.Contains(got, "msg=taken") || !strings.Contains(got, "msg=queued") || strings.Contains(got, "msg=dropped") {
+		t.Errorf("written:\n%s", got)
+	}
+	log.Info("after close") // written directly
+	if !strings.Contains(out.String(), "after close") {
+		t.Error("record logged after close was lost")
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestParseAsyncMode(t *testing.T) {
+	for _, m := range []AsyncMode{AsyncOff, AsyncBlock, AsyncDrop} {
+		if got, err := ParseAsyncMode(m.String()); err != nil || got != m {
+			t.Errorf("ParseAsyncMode(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseAsyncMode("sometimes"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// BenchmarkFileSinkAsync is BenchmarkFileSink's record mix with the file
+// written synchronously and through the queue.
+func BenchmarkFileSinkAsync(b *testing.B) {
+	for _, mode := range []AsyncMode{AsyncOff, AsyncBlock, AsyncDrop} {
+		b.Run(mode.String(), func(b *testing.B) {
+			ls, teardown := Setup(LogConfig{Dir: b.TempDir(), ConsoleLevel: slog.LevelError + 1, FileLevel: slog.LevelDebug, Async: mode, AsyncRawEvents: true})
+			defer teardown()
+			b.ResetTimer()
+			for i := range b.N {
+				if i%4 == 0 {
+					ls.Debug("raw_event", "raw", `{"type":"thinking","subtype":"delta"}`)
+				} else {
+					ls.Debug("agent stderr", "line", "warning: something chatty")
+				}
+			}
+			ls.Flush()
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+)
+
+// writeGuard tracks the health of the file sink. A log that can't be
+// written (typically a full disk) must not take the session down with
+// it, nor fill the console with one error per record.
+type writeGuard struct {
+	failed  atomic.Bool
+	dropped atomic.Int64 // records not written to the file
+	onFail  func(error)  // called once, on the first failed write
+}
+
+// Dropped returns how many records never made it to the file; 0 for a
+// nil guard (no file sink).
+func (g *writeGuard) Dropped() int64 {
+	if g == nil {
+		return 0
+	}
+	return g.dropped.Load()
+}
+
+// guardedHandler wraps the file sink's handler. After the first record
+// it fails to write, it takes the sink out of the fan-out: later records
+// are counted as dropped instead of being attempted, and no error ever
+// reaches multiHandler, so the other sinks carry on.
+type guardedHandler struct {
+	slog.Handler
+	guard *writeGuard
+}
+
+func (h *guardedHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.guard.failed.Load() {
+		h.guard.dropped.Add(1)
+		return nil
+	}
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		h.guard.dropped.Add(1)
+		if h.guard.failed.CompareAndSwap(false, true) && h.guard.onFail != nil {
+			h.guard.onFail(err)
+		}
+	}
+	return nil
+}
+
+func (h *guardedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &guardedHandler{Handler: h.Handler.WithAttrs(attrs), guard: h.guard}
+}
+
+func (h *guardedHandler) WithGroup(name string) slog.Handler {
+	return &guardedHandler{Handler: h.Handler.WithGroup(name), guard: h.guard}
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fullDisk accepts writes until it has taken limit bytes, then fails
+// them all like a full disk.
+type fullDisk struct {
+	written, limit int
+	ok, failed     int // writes
+}
+
+func (d *fullDisk) Write(p []byte) (int, error) {
+	if d.written+len(p) > d.limit {
+		d.failed++
+		return 0, syscall.ENOSPC
+	}
+	d.written += len(p)
+	d.ok++
+	return len(p), nil
+}
+
+func TestGuardedHandler_DisablesFileOnWriteFailure(t *testing.T) {
+	console := captureConsole(t)
+	cfg := LogConfig{ConsoleLevel: slog.LevelInfo}
+	disk := &fullDisk{limit: 300}
+	guard := &writeGuard{}
+	file := &guardedHandler{Handler: slog.NewJSONHandler(disk, nil), guard: guard}
+	ls, teardown := newSession(cfg, []slog.Handler{file, newConsoleHandler(cfg, cfg.ConsoleLevel)}, nil, &fileSink{guard: guard}, remoteSinks{})
+
+	for i := range 10 {
+		ls.WithTurn(1).Info("record", "i", i)
+	}
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := console.String()
+	if n := strings.Count(out, "file logging disabled"); n != 1 {
+		t.Errorf("warned %d times about the failed sink, want once:\n%s", n, out)
+	}
+	if !strings.Contains(out, syscall.ENOSPC.Error()) {
+		t.Errorf("warning does not say why:\n%s", out)
+	}
+	if n := strings.Count(out, "msg=record"); n != 10 {
+		t.Errorf("console got %d records, want all 10:\n%s", n, out)
+	}
+	// The records that fit, one that failed, and nothing after.
+	if disk.ok == 0 || disk.failed != 1 {
+		t.Errorf("file sink took %d writes and failed %d, want some and 1", disk.ok, disk.failed)
+	}
+	// 10 records and the two warnings, less those written.
+	if want := int64(12 - disk.ok); guard.Dropped() != want {
+		t.Errorf("Dropped = %d, want %d", guard.Dropped(), want)
+	}
+	if !strings.Contains(out, "dropped_records=") {
+		t.Errorf("teardown did not report the dropped records:\n%s", out)
+	}
+}
+
+func TestMultiHandler_FailingHandlerDoesNotStopOthers(t *testing.T) {
+	var console bytes.Buffer
+	h := &multiHandler{handlers: []slog.Handler{
+		slog.NewJSONHandler(&fullDisk{}, nil),
+		slog.NewTextHandler(&console, nil),
+	}}
+	err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "hello", 0))
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("err = %v, want ENOSPC", err)
+	}
+	if !strings.Contains(console.String(), "msg=hello") {
+		t.Errorf("second handler missed the record: %q", console.String())
+	}
+}
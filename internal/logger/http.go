@@ -0,0 +1,249 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHTTPBufferBytes caps the records an HTTP sink holds while its
+// collector is slow or unreachable, when LogConfig.HTTPMaxBuffer is 0.
+const DefaultHTTPBufferBytes = 8 << 20
+
+// Tuning of the HTTP sink. Pending records are sent every
+// httpFlushInterval, or as soon as they fill a batch of httpBatchRecords
+// or httpBatchBytes. A failed POST is tried httpMaxAttempts times in
+// all, backing off from httpRetryBackoff up to httpMaxBackoff; then the
+// batch is dropped. Teardown gives the last batches httpCloseTimeout.
+// Variables so tests can shorten them.
+var (
+	httpBatchRecords  = 500
+	httpBatchBytes    = 1 << 20
+	httpFlushInterval = time.Second
+	httpMaxAttempts   = 5
+	httpRetryBackoff  = 500 * time.Millisecond
+	httpMaxBackoff    = 10 * time.Second
+	httpPostTimeout   = 10 * time.Second
+	httpCloseTimeout  = 5 * time.Second
+)
+
+// httpSink ships the session's records to a collector as gzipped NDJSON
+// POSTs. It is an io.Writer taking one record per Write, fed through a
+// logQueue so that the event loop never waits on it, and it sends from a
+// goroutine of its own so that the queue never waits on the network.
+// Records beyond maxBytes, and batches the collector still refuses after
+// the retries, are dropped and counted.
+type httpSink struct {
+	endpoint string
+	client   *http.Client
+	maxBytes int
+
+	mu      sync.Mutex
+	pending [][]byte // encoded records, oldest first
+	size    int      // bytes in pending
+	closed  bool
+
+	ctx     context.Context // cancelled httpCloseTimeout into close
+	cancel  context.CancelFunc
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+
+	dropped atomic.Int64
+	failed  atomic.Bool
+	onError func(error) // called once, on the first dropped batch
+}
+
+func newHTTPSink(endpoint string, maxBytes int) *httpSink {
+	if maxBytes <= 0 {
+		maxBytes = DefaultHTTPBufferBytes
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &httpSink{
+		ctx:      ctx,
+		cancel:   cancel,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: httpPostTimeout},
+		maxBytes: maxBytes,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write buffers one record. It never fails: a record that doesn't fit is
+// counted as dropped.
+func (s *httpSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	if s.closed || s.size+len(p) > s.maxBytes {
+		s.mu.Unlock()
+		s.dropped.Add(1)
+		return len(p), nil
+	}
+	s.pending = append(s.pending, bytes.Clone(p))
+	s.size += len(p)
+	full := len(s.pending) >= httpBatchRecords || s.size >= httpBatchBytes
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+func (s *httpSink) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(httpFlushInterval)
+	defer ticker.Stop()
+	for {
+		closing := false
+		select {
+		case <-s.wake:
+		case <-ticker.C:
+		case <-s.done:
+			closing = true
+		}
+		for s.sendBatch(s.ctx) {
+		}
+		if closing {
+			return
+		}
+	}
+}
+
+// sendBatch sends the oldest pending records, up to a batch, and reports
+// whether there were any.
+func (s *httpSink) sendBatch(ctx context.Context) bool {
+	s.mu.Lock()
+	n, size := 0, 0
+	for n < len(s.pending) && n < httpBatchRecords && (n == 0 || size+len(s.pending[n]) <= httpBatchBytes) {
+		size += len(s.pending[n])
+		n++
+	}
+	batch := s.pending[:n:n]
+	s.pending = s.pending[n:]
+	s.size -= size
+	s.mu.Unlock()
+	if n == 0 {
+		return false
+	}
+	if err := s.post(ctx, batch); err != nil {
+		s.dropped.Add(int64(n))
+		if s.failed.CompareAndSwap(false, true) && s.onError != nil {
+			s.onError(err)
+		}
+	}
+	return true
+}
+
+// post sends batch, retrying with backoff on network errors, 5xx and 429.
+func (s *httpSink) post(ctx context.Context, batch [][]byte) error {
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	for _, rec := range batch {
+		_, _ = zw.Write(rec) // writes to a bytes.Buffer don't fail
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("log shipping: compressing: %w", err)
+	}
+
+	backoff := httpRetryBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = s.postOnce(ctx, body.Bytes())
+		if err == nil || !retry || attempt == httpMaxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, httpMaxBackoff)
+	}
+}
+
+func (s *httpSink) postOnce(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("log shipping: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("log shipping: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("log shipping: %s returned %s: %s", s.endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body) // let the connection be reused
+	return false, nil
+}
+
+// close sends what is buffered and stops the sink, giving up on (and
+// counting) whatever is not sent within httpCloseTimeout. Later writes
+// are dropped.
+func (s *httpSink) close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+	close(s.done)
+	select {
+	case <-s.stopped:
+	case <-time.After(httpCloseTimeout):
+		s.cancel()
+		<-s.stopped
+	}
+	s.cancel()
+}
+
+// Dropped returns how many records never reached the collector; 0 for a
+// nil sink.
+func (s *httpSink) Dropped() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.dropped.Load()
+}
+
+// newHTTPHandler builds the handler feeding s: the file sink's JSON
+// records, without raw events unless LogConfig.HTTPRawEvents, queued on
+// q so that logging never waits for the encoding either.
+func newHTTPHandler(cfg LogConfig, s *httpSink, q *logQueue) slog.Handler {
+	jsonHandler := slog.NewJSONHandler(s, &slog.HandlerOptions{
+		Level:       cfg.FileLevel,
+		ReplaceAttr: replaceTimeAttrs(cfg.TimeFormat),
+	}).WithAttrs(append([]slog.Attr{
+		slog.Int("schema_version", SchemaVersion),
+		slog.String("wrapper_version", cfg.WrapperVersion),
+	}, originAttrs(cfg.Tag)...))
+	var h slog.Handler = &asyncHandler{Handler: &kindHandler{jsonHandler}, q: q}
+	if !cfg.HTTPRawEvents {
+		h = &skipKindHandler{Handler: h, kind: KindRawEvent}
+	}
+	return h
+}
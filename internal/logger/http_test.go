@@ -0,0 +1,170 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is an httptest log collector. It answers the first fail
+// requests with 503 and records the batches it accepts.
+type collector struct {
+	mu       sync.Mutex
+	fail     int
+	requests int
+	batches  [][]map[string]any
+}
+
+func newCollector(t *testing.T, fail int) (*collector, string) {
+	t.Helper()
+	c := &collector{fail: fail}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.requests++
+		if c.requests <= c.fail {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "gzip" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("headers = %v", r.Header)
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("body is not gzip: %v", err)
+			return
+		}
+		var batch []map[string]any
+		sc := bufio.NewScanner(zr)
+		for sc.Scan() {
+			var rec map[string]any
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				t.Errorf("invalid record %q: %v", sc.Text(), err)
+			}
+			batch = append(batch, rec)
+		}
+		c.batches = append(c.batches, batch)
+	}))
+	t.Cleanup(srv.Close)
+	return c, srv.URL
+}
+
+func (c *collector) snapshot() (requests int, batches [][]map[string]any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, append([][]map[string]any(nil), c.batches...)
+}
+
+// shortenHTTPTimings makes the HTTP sink batch by 3 records and retry
+// quickly for the length of a test.
+func shortenHTTPTimings(t *testing.T) {
+	records, interval, backoff := httpBatchRecords, httpFlushInterval, httpRetryBackoff
+	httpBatchRecords, httpFlushInterval, httpRetryBackoff = 3, time.Hour, time.Millisecond
+	t.Cleanup(func() {
+		httpBatchRecords, httpFlushInterval, httpRetryBackoff = records, interval, backoff
+	})
+}
+
+func TestHTTPSink_BatchesRecords(t *testing.T) {
+	shortenHTTPTimings(t)
+	c, url := newCollector(t, 0)
+	ls, teardown := Setup(LogConfig{
+		NoFile:         true,
+		ConsoleLevel:   slog.LevelError + 1,
+		FileLevel:      slog.LevelDebug,
+		WrapperVersion: "test",
+		HTTPEndpoint:   url,
+	})
+
+	for i := range 7 {
+		ls.WithTurn(1).Info("record", "i", i)
+		ls.Debug("raw_event", Kind(KindRawEvent), "raw", json.RawMessage(`{}`))
+	}
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two full batches, and the rest sent at teardown.
+	_, batches := c.snapshot()
+	var sizes []int
+	i := 0
+	for _, b := range batches {
+		sizes = append(sizes, len(b))
+		for _, rec := range b {
+			if rec["msg"] != "record" || rec["i"] != float64(i) || rec["kind"] != KindDecision || rec["schema_version"] != float64(SchemaVersion) || rec["turn"] != float64(1) {
+				t.Errorf("record %d = %v", i, rec)
+			}
+			i++
+		}
+	}
+	if fmt.Sprint(sizes) != "[3 3 1]" {
+		t.Errorf("batch sizes = %v, want [3 3 1] (raw events left out)", sizes)
+	}
+}
+
+func TestHTTPSink_RetriesFailedPosts(t *testing.T) {
+	shortenHTTPTimings(t)
+	c, url := newCollector(t, 2)
+	s := newHTTPSink(url, 0)
+	for i := range 3 {
+		fmt.Fprintf(s, "{\"i\":%d}\n", i)
+	}
+	s.close()
+
+	requests, batches := c.snapshot()
+	if requests != 3 || len(batches) != 1 || len(batches[0]) != 3 {
+		t.Errorf("requests = %d, batches = %v; want two 503s, then the batch", requests, batches)
+	}
+	if n := s.Dropped(); n != 0 {
+		t.Errorf("Dropped = %d, want 0", n)
+	}
+}
+
+func TestHTTPSink_DropsBatchAfterLastAttempt(t *testing.T) {
+	shortenHTTPTimings(t)
+	c, url := newCollector(t, 1000)
+	s := newHTTPSink(url, 0)
+	var warned []error
+	s.onError = func(err error) { warned = append(warned, err) }
+	for i := range 4 {
+		fmt.Fprintf(s, "{\"i\":%d}\n", i)
+	}
+	s.close()
+
+	if requests, _ := c.snapshot(); requests != 2*httpMaxAttempts {
+		t.Errorf("requests = %d, want %d attempts at each of 2 batches", requests, httpMaxAttempts)
+	}
+	if n := s.Dropped(); n != 4 {
+		t.Errorf("Dropped = %d, want 4", n)
+	}
+	if len(warned) != 1 {
+		t.Errorf("onError called %d times, want once", len(warned))
+	}
+}
+
+func TestHTTPSink_BufferIsCapped(t *testing.T) {
+	shortenHTTPTimings(t)
+	httpBatchRecords = 1000 // nothing is sent before close
+	c, url := newCollector(t, 0)
+	record := []byte(`{"msg":"0123456789"}` + "\n") // 21 bytes
+	s := newHTTPSink(url, 100)
+	for range 10 {
+		_, _ = s.Write(record)
+	}
+	s.close()
+
+	_, batches := c.snapshot()
+	if len(batches) != 1 || len(batches[0]) != 4 {
+		t.Errorf("batches = %v, want the 4 records that fit in 100 bytes", batches)
+	}
+	if n := s.Dropped(); n != 6 {
+		t.Errorf("Dropped = %d, want 6", n)
+	}
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Names of the pointer to the active log file kept in the log directory:
+// a symlink where the filesystem supports one, a text file holding the
+// path where it doesn't.
+const (
+	latestLink     = "latest.jsonl"
+	latestPathFile = "latest.path"
+)
+
+// symlink is os.Symlink; tests replace it to exercise the fallback.
+var symlink = os.Symlink
+
+// updateLatest points the log directory's latest.jsonl at path, falling
+// back to writing path into latest.path if symlinks aren't available.
+// Both are replaced with a rename, so a reader never sees them missing.
+func updateLatest(path string) error {
+	dir := filepath.Dir(path)
+	tmp := filepath.Join(dir, fmt.Sprintf(".%s.%d", latestLink, os.Getpid()))
+	_ = os.Remove(tmp) // left behind by a crash, if anything
+	err := symlink(filepath.Base(path), tmp)
+	if err == nil {
+		if err = os.Rename(tmp, filepath.Join(dir, latestLink)); err == nil {
+			// A stale fallback would point somewhere else.
+			if rmErr := os.Remove(filepath.Join(dir, latestPathFile)); rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) {
+				return rmErr
+			}
+			return nil
+		}
+		_ = os.Remove(tmp)
+	}
+
+	abs, absErr := filepath.Abs(path)
+	if absErr != nil {
+		abs = path
+	}
+	if ferr := os.WriteFile(tmp, []byte(abs+"\n"), 0o644); ferr != nil {
+		return errors.Join(err, ferr)
+	}
+	if ferr := os.Rename(tmp, filepath.Join(dir, latestPathFile)); ferr != nil {
+		_ = os.Remove(tmp)
+		return errors.Join(err, ferr)
+	}
+	_ = os.Remove(filepath.Join(dir, latestLink)) // a stale link from before; usually absent
+	return nil
+}
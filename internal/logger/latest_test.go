@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLatest_SymlinkFollowsRename(t *testing.T) {
+	dir := t.TempDir()
+	ls, teardown := Setup(LogConfig{Dir: dir, ConsoleLevel: slog.LevelError})
+	defer teardown()
+
+	link := filepath.Join(dir, latestLink)
+	if got, err := os.Readlink(link); err != nil || got != filepath.Base(ls.FilePath()) {
+		t.Fatalf("after Setup: readlink = %q, %v; want %q", got, err, filepath.Base(ls.FilePath()))
+	}
+
+	ls.SetSessionID("sess-1")
+	if got, err := os.Readlink(link); err != nil || got != filepath.Base(ls.FilePath()) {
+		t.Fatalf("after SetSessionID: readlink = %q, %v; want %q", got, err, filepath.Base(ls.FilePath()))
+	}
+	ls.Info("through the link")
+	data, err := os.ReadFile(link)
+	if err != nil || !strings.Contains(string(data), "through the link") {
+		t.Errorf("reading through %s: %q, %v", latestLink, data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, latestPathFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no %s when symlinks work, stat err = %v", latestPathFile, err)
+	}
+}
+
+func TestLatest_FallsBackToPathFile(t *testing.T) {
+	orig := symlink
+	symlink = func(string, string) error { return errors.ErrUnsupported }
+	t.Cleanup(func() { symlink = orig })
+
+	dir := t.TempDir()
+	ls, teardown := Setup(LogConfig{Dir: dir, ConsoleLevel: slog.LevelError})
+	defer teardown()
+
+	readPath := func() string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, latestPathFile))
+		if err != nil {
+			t.Fatalf("reading %s: %v", latestPathFile, err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+	if got := readPath(); got != ls.FilePath() {
+		t.Errorf("after Setup: %s = %q, want %q", latestPathFile, got, ls.FilePath())
+	}
+	ls.SetSessionID("sess-2")
+	if got := readPath(); got != ls.FilePath() {
+		t.Errorf("after SetSessionID: %s = %q, want %q", latestPathFile, got, ls.FilePath())
+	}
+	if _, err := os.Lstat(filepath.Join(dir, latestLink)); !os.IsNotExist(err) {
+		t.Errorf("expected no %s without symlinks, lstat err = %v", latestLink, err)
+	}
+}
@@ -2,36 +2,141 @@ package logger
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
-	"strings"
-	"sync"
 	"time"
 )
 
 // LogConfig holds configuration for the dual-sink logger.
 type LogConfig struct {
 	Dir          string     // directory for log files
+	NoFile       bool       // log to the console (and syslog) only
 	ConsoleLevel slog.Level // minimum level for console output
 	FileLevel    slog.Level // minimum level for file output (typically debug)
+	// NoRawEvents keeps raw_event records out of the file, which then
+	// holds only the wrapper's own records.
+	NoRawEvents bool
+	Started     time.Time // names the log file; zero means now
+	// ConsoleFormat is "text" (the default) or "json".
+	ConsoleFormat string
+	// TimeFormat is how JSON records write their time and recv_ts:
+	// TimeMillis (also "") or TimeRFC3339.
+	TimeFormat string
+	// WrapperVersion is recorded in every file record (see SchemaVersion).
+	WrapperVersion string
+	// Tag labels the session, say with the runner's name: it is recorded
+	// in every file record and goes in the log file's name.
+	Tag string
+	// MaxSize rotates the log file into numbered parts once the active
+	// part reaches this many bytes; 0 disables rotation.
+	MaxSize int64
+	// Sync chooses which records are fsynced to disk.
+	Sync SyncMode
+	// SplitLogs writes raw events and agent stderr to one file
+	// (…-events.jsonl) and every other record to another
+	// (…-decisions.jsonl), instead of both to one file.
+	SplitLogs bool
+	// Async moves writing the file off the logging goroutine, through a
+	// queue of QueueSize records (0 means DefaultQueueSize). Raw events
+	// are still written before the log call returns unless
+	// AsyncRawEvents is set.
+	Async          AsyncMode
+	QueueSize      int
+	AsyncRawEvents bool
+	// Redact masks secrets in raw event records (see RedactRaw).
+	Redact []RedactRule
+	// Syslog also sends info-and-above records, except raw events, to
+	// the local syslog daemon (journald on systemd hosts) at SyslogAddr,
+	// or the platform's default socket if that is empty.
+	Syslog     bool
+	SyslogAddr string
+	// HTTPEndpoint, if set, also POSTs the file sink's records, except raw
+	// events unless HTTPRawEvents, to a collector at this URL, in gzipped
+	// NDJSON batches. At most HTTPMaxBuffer bytes of records (0 means
+	// DefaultHTTPBufferBytes) wait for a slow or unreachable collector;
+	// more are dropped, and counted at teardown.
+	HTTPEndpoint  string
+	HTTPRawEvents bool
+	HTTPMaxBuffer int
+	// ResumeSessionID is the session the run resumes. Unless
+	// NewLogOnResume, Setup appends to the newest log in Dir already
+	// named after it, if any, under a "session log resumed" record,
+	// instead of starting a new file: one session, one log.
+	ResumeSessionID string
+	NewLogOnResume  bool
+}
+
+// FileName returns the name Setup gives the log file of a session that
+// started at started, and was tagged tag if that is not empty, before
+// the session_id is known.
+func FileName(started time.Time, tag string) string {
+	if tag != "" {
+		return fmt.Sprintf("cursor-wrap-%d-%s-unknown.jsonl", started.UnixMilli(), tag)
+	}
+	return fmt.Sprintf("cursor-wrap-%d-unknown.jsonl", started.UnixMilli())
 }
 
 // LogSession wraps *slog.Logger and holds a reference to the file sink,
 // enabling the log file to be renamed once the session_id is known.
 type LogSession struct {
 	*slog.Logger
-	filePath   string
-	sessionSet bool
-	mu         sync.Mutex // protects filePath and sessionSet
+	file     *rotatingFile // nil when logging to the console only; shared by WithTurn
+	events   *rotatingFile // nil unless LogConfig.SplitLogs; file is then the decisions file
+	queue    *logQueue     // nil unless LogConfig.Async
+	redactor *Redactor
+	console  *slog.LevelVar // the console sink's level; nil outside Setup
+}
+
+// fileSink is the file side of a session: the file (or, split, the
+// decisions and events files), the guard on writing them and, for an
+// async sink, the queue in front of them.
+type fileSink struct {
+	file   *rotatingFile
+	events *rotatingFile
+	guard  *writeGuard
+	queue  *logQueue
+}
+
+// remoteSinks are the optional sinks off the machine, or at least off the
+// session log: syslog, which may have failed to connect (sysErr), and
+// the HTTP sink with the queue in front of it.
+type remoteSinks struct {
+	sys       *syslogConn
+	sysErr    error
+	http      *httpSink
+	httpQueue *logQueue
 }
 
 // Setup initializes the dual-sink logger and returns a LogSession.
 // The teardown function flushes and closes the file sink.
 // If setup fails to create the log directory or file, it falls back
-// to console-only logging and logs a warning.
+// to console-only logging and logs a warning; with NoFile it logs to the
+// console only by design, and quietly.
 func Setup(cfg LogConfig) (*LogSession, func() error) {
+	console := new(slog.LevelVar)
+	console.Set(cfg.ConsoleLevel)
+	handlers := []slog.Handler{newConsoleHandler(cfg, console)}
+
+	var remote remoteSinks
+	if cfg.Syslog {
+		if remote.sys, remote.sysErr = dialSyslog(cfg.SyslogAddr); remote.sysErr == nil {
+			handlers = append(handlers, &syslogHandler{conn: remote.sys})
+		}
+	}
+	if cfg.HTTPEndpoint != "" {
+		remote.http = newHTTPSink(cfg.HTTPEndpoint, cfg.HTTPMaxBuffer)
+		remote.httpQueue = newLogQueue(AsyncDrop, DefaultQueueSize, true, &writeGuard{})
+		handlers = append(handlers, newHTTPHandler(cfg, remote.http, remote.httpQueue))
+	}
+
+	if cfg.NoFile {
+		return newSession(cfg, handlers, console, nil, remote)
+	}
+
 	dir := cfg.Dir
 	if dir == "" {
 		home, err := os.UserHomeDir()
@@ -43,103 +148,317 @@ func Setup(cfg LogConfig) (*LogSession, func() error) {
 
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		// Fall back to console-only if we can't create the directory.
-		slog.Warn("failed to create log directory, using console only", "dir", dir, "error", err)
-		ls := &LogSession{
-			Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-				Level: cfg.ConsoleLevel,
-			})),
-		}
-		return ls, func() error { return nil }
+		ls, teardown := newSession(cfg, handlers, console, nil, remote)
+		ls.Warn("failed to create log directory, using console only", "dir", dir, "error", err)
+		return ls, teardown
 	}
 
-	startTS := time.Now().UnixMilli()
-	filename := fmt.Sprintf("cursor-wrap-%d-unknown.jsonl", startTS)
-	filePath := filepath.Join(dir, filename)
+	started := cfg.Started
+	if started.IsZero() {
+		started = time.Now()
+	}
+	filePath := filepath.Join(dir, FileName(started, cfg.Tag))
+	var eventsPath, suffix string
+	if cfg.SplitLogs {
+		eventsName, decisionsName := SplitFileNames(started, cfg.Tag)
+		filePath, eventsPath = filepath.Join(dir, decisionsName), filepath.Join(dir, eventsName)
+		suffix = decisionsSuffix
+	}
+
+	// A resumed session's log is appended to; one that can't be is
+	// warned about once logging works, and a new file started.
+	cfg.Dir = dir
+	var resumeErrs []error
+	open := func(path, suffix string, latest bool) (f *rotatingFile, resumed bool, err error) {
+		f, err = resumeRotating(cfg, suffix, latest)
+		if f != nil {
+			return f, true, nil
+		}
+		if err != nil {
+			resumeErrs = append(resumeErrs, err)
+		}
+		f, err = openRotating(path, suffix, cfg.MaxSize, latest)
+		return f, false, err
+	}
 
-	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0o644)
+	f, resumed, err := open(filePath, suffix, true)
 	if err != nil {
-		slog.Warn("failed to open log file, using console only", "path", filePath, "error", err)
-		ls := &LogSession{
-			Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-				Level: cfg.ConsoleLevel,
-			})),
+		ls, teardown := newSession(cfg, handlers, console, nil, remote)
+		ls.Warn("failed to open log file, using console only", "path", filePath, "error", err)
+		return ls, teardown
+	}
+	sink := &fileSink{file: f, guard: &writeGuard{}}
+	writeHandler := newFileHandler(cfg, f)
+	if cfg.SplitLogs {
+		events, _, err := open(eventsPath, eventsSuffix, false)
+		if err != nil {
+			_ = f.Close() // nothing was written to it
+			if !resumed {
+				_ = os.Remove(filePath)
+			}
+			ls, teardown := newSession(cfg, handlers, console, nil, remote)
+			ls.Warn("failed to open log file, using console only", "path", eventsPath, "error", err)
+			return ls, teardown
 		}
-		return ls, func() error { return nil }
+		sink.events = events
+		writeHandler = &splitHandler{events: newFileHandler(cfg, events), decisions: writeHandler}
 	}
+	var fileHandler slog.Handler = &guardedHandler{Handler: writeHandler, guard: sink.guard}
+	if cfg.NoRawEvents {
+		fileHandler = &skipKindHandler{Handler: fileHandler, kind: KindRawEvent}
+	}
+	if cfg.Async != AsyncOff {
+		sink.queue = newLogQueue(cfg.Async, cfg.QueueSize, cfg.AsyncRawEvents, sink.guard)
+		fileHandler = &asyncHandler{Handler: fileHandler, q: sink.queue}
+	}
+	handlers = append([]slog.Handler{fileHandler}, handlers...)
+
+	ls, teardown := newSession(cfg, handlers, console, sink, remote)
+	for _, err := range resumeErrs {
+		ls.Warn("could not append to the resumed session's log, starting a new one", "session_id", cfg.ResumeSessionID, "error", err)
+	}
+	if resumed {
+		ls.Info("session log resumed", "session_id", cfg.ResumeSessionID, "path", f.path())
+	}
+	if err := updateLatest(f.path()); err != nil {
+		ls.Warn("failed to update the latest log pointer", "error", err)
+	}
+	return ls, teardown
+}
 
-	fileHandler := slog.NewJSONHandler(f, &slog.HandlerOptions{
+// newFileHandler writes records to f as JSON, stamped with the fields
+// every file record carries, synced to disk per cfg.Sync.
+func newFileHandler(cfg LogConfig, f *rotatingFile) slog.Handler {
+	jsonHandler := slog.NewJSONHandler(f, &slog.HandlerOptions{
 		Level:       cfg.FileLevel,
-		ReplaceAttr: replaceTimeAttr,
-	})
+		ReplaceAttr: replaceTimeAttrs(cfg.TimeFormat),
+	}).WithAttrs(append([]slog.Attr{
+		slog.Int("schema_version", SchemaVersion),
+		slog.String("wrapper_version", cfg.WrapperVersion),
+	}, originAttrs(cfg.Tag)...))
+	return &syncHandler{
+		Handler: &kindHandler{jsonHandler},
+		file:    f,
+		mode:    cfg.Sync,
+	}
+}
 
-	consoleHandler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-		Level: cfg.ConsoleLevel,
-	})
+// consoleOut is where the console sink writes; tests capture it.
+var consoleOut io.Writer = os.Stderr
 
-	multi := &multiHandler{
-		handlers: []slog.Handler{fileHandler, consoleHandler},
+// newConsoleHandler builds the console sink at level: text for people,
+// or JSON for log collectors, with the file sink's time format and kinds.
+func newConsoleHandler(cfg LogConfig, level slog.Leveler) slog.Handler {
+	if cfg.ConsoleFormat == "json" {
+		return &kindHandler{slog.NewJSONHandler(consoleOut, &slog.HandlerOptions{
+			Level:       level,
+			ReplaceAttr: replaceTimeAttrs(cfg.TimeFormat),
+		})}
 	}
+	return slog.NewTextHandler(consoleOut, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: dropKind,
+	})
+}
 
+// newSession assembles a LogSession over handlers. The remote sinks, when
+// requested, are optional: failing to reach syslog, now or later, or to
+// ship a batch of records over HTTP, is warned about once and logging
+// carries on. So is the file sink once it has been opened: the first
+// failed write (say, the disk is full) is warned about, the sink is
+// dropped, and teardown reports how many records it missed. sink is nil
+// when logging to the console only.
+func newSession(cfg LogConfig, handlers []slog.Handler, console *slog.LevelVar, sink *fileSink, remote remoteSinks) (*LogSession, func() error) {
+	if sink == nil {
+		sink = &fileSink{}
+	}
 	ls := &LogSession{
-		Logger:   slog.New(multi),
-		filePath: filePath,
+		Logger:   slog.New(&multiHandler{handlers: handlers}),
+		file:     sink.file,
+		events:   sink.events,
+		queue:    sink.queue,
+		redactor: NewRedactor(cfg.Redact),
+		console:  console,
+	}
+	if remote.sysErr != nil {
+		ls.Warn("syslog unavailable, logging to the session log only", "error", remote.sysErr)
+	}
+	if remote.sys != nil {
+		remote.sys.onError = func(err error) {
+			ls.Warn("writing to syslog failed, no longer sending records there", "error", err)
+		}
+	}
+	if remote.http != nil {
+		remote.http.onError = func(err error) {
+			ls.Warn("shipping log records failed, dropping them", "endpoint", cfg.HTTPEndpoint, "error", err)
+		}
 	}
 
-	teardown := func() error {
-		return f.Close()
+	if sink.guard != nil {
+		sink.guard.onFail = func(err error) {
+			ls.Warn("file logging disabled: writing the session log failed, continuing on the console only",
+				"path", ls.FilePath(), "error", err)
+		}
 	}
 
+	teardown := func() error {
+		sink.queue.close()
+		if n := sink.queue.Overflow(); n > 0 {
+			ls.Warn("session log is incomplete: the log queue overflowed", "path", ls.FilePath(), "dropped_records", n)
+		}
+		if n := sink.guard.Dropped(); n > 0 {
+			ls.Warn("session log is incomplete", "path", ls.FilePath(), "dropped_records", n)
+		}
+		remote.httpQueue.close()
+		remote.http.close()
+		if n := remote.http.Dropped() + remote.httpQueue.Overflow(); n > 0 {
+			ls.Warn("log shipping is incomplete", "endpoint", cfg.HTTPEndpoint, "dropped_records", n)
+		}
+		var errs []error
+		if sink.file != nil {
+			errs = append(errs, sink.file.Close())
+		}
+		if sink.events != nil {
+			errs = append(errs, sink.events.Close())
+		}
+		if remote.sys != nil {
+			errs = append(errs, remote.sys.Close())
+		}
+		return errors.Join(errs...)
+	}
 	return ls, teardown
 }
 
-// SetSessionID renames the log file to incorporate the session_id.
-// Called once after the first system/init event is received.
-// No-op if session_id was already set or if the rename fails (logged at warn).
+// SetSessionID renames the log file, and any parts it was rotated into
+// (for a split log, both files), to incorporate the session_id. Called
+// once after the first system/init event is received. No-op if
+// session_id was already set; rename failures are logged at warn.
 func (ls *LogSession) SetSessionID(id string) {
-	ls.mu.Lock()
-	defer ls.mu.Unlock()
+	ls.nameAfter(id, true)
+}
 
-	if ls.sessionSet || ls.filePath == "" {
+// AddSessionID adds another session_id to the log file's name, for when
+// the agent starts a new session part way through the wrapper's run
+// (say, it would not resume one after a hang): the log stays one file,
+// named after every session it covers, in order. No-op if the name
+// already includes id; without an id yet it is SetSessionID.
+func (ls *LogSession) AddSessionID(id string) {
+	ls.nameAfter(id, false)
+}
+
+func (ls *LogSession) nameAfter(id string, first bool) {
+	if ls.file == nil {
 		return
 	}
+	renamed, errs := ls.file.addSessionID(id, first)
+	if ls.events != nil {
+		_, eventsErrs := ls.events.addSessionID(id, first)
+		errs = append(errs, eventsErrs...)
+	}
+	for _, err := range errs {
+		ls.Logger.Warn("failed to rename log file", "error", err)
+	}
+	if renamed {
+		if err := updateLatest(ls.file.path()); err != nil {
+			ls.Logger.Warn("failed to update the latest log pointer", "error", err)
+		}
+	}
+}
 
-	dir := filepath.Dir(ls.filePath)
-	base := filepath.Base(ls.filePath)
+// WithTurn returns a LogSession whose records carry turn=n, sharing the
+// file sink (and its renaming) with ls.
+func (ls *LogSession) WithTurn(n int) *LogSession {
+	return &LogSession{Logger: ls.Logger.With("turn", n), file: ls.file, events: ls.events, queue: ls.queue, redactor: ls.redactor, console: ls.console}
+}
 
-	// Replace "unknown" with the session_id in the filename.
-	newBase := strings.Replace(base, "-unknown.jsonl", "-"+id+".jsonl", 1)
-	if newBase == base {
-		// Replacement didn't happen — unexpected filename format.
-		return
+// SetConsoleLevel changes the level of the console sink, in ls and every
+// LogSession sharing its sinks; the file and remote sinks keep theirs.
+func (ls *LogSession) SetConsoleLevel(level slog.Level) {
+	if ls.console != nil {
+		ls.console.Set(level)
 	}
+}
 
-	newPath := filepath.Join(dir, newBase)
-	if err := os.Rename(ls.filePath, newPath); err != nil {
-		ls.Logger.Warn("failed to rename log file", "old", ls.filePath, "new", newPath, "error", err)
-		return
-	}
+// Flush waits until the file sink has written every record logged so
+// far. Only an async sink (LogConfig.Async) can be behind; for others it
+// returns at once.
+func (ls *LogSession) Flush() {
+	ls.queue.flush()
+}
 
-	ls.filePath = newPath
-	ls.sessionSet = true
+// RedactRaw masks secrets in an event's raw JSON before it is logged,
+// using LogConfig.Redact. The result is still valid JSON. It is for log
+// records only: what the agent wrote is forwarded to stdout untouched.
+func (ls *LogSession) RedactRaw(raw []byte) []byte {
+	return ls.redactor.RedactJSON(raw)
 }
 
-// FilePath returns the current path of the log file.
-// Returns an empty string if no file sink is configured.
+// RedactText masks secrets in text the wrapper logs itself, such as a
+// prompt, with the same rules as RedactRaw.
+func (ls *LogSession) RedactText(s string) string {
+	return ls.redactor.RedactText(s)
+}
+
+// FilePath returns the current path of the log file: the active part,
+// if the log has been rotated. For a split log it is the decisions file;
+// see EventsFilePath. Returns an empty string if no file sink is
+// configured.
 func (ls *LogSession) FilePath() string {
-	ls.mu.Lock()
-	defer ls.mu.Unlock()
-	return ls.filePath
+	if ls.file == nil {
+		return ""
+	}
+	return ls.file.path()
+}
+
+// EventsFilePath returns the current path of a split log's events file,
+// like FilePath. Returns an empty string unless LogConfig.SplitLogs.
+func (ls *LogSession) EventsFilePath() string {
+	if ls.events == nil {
+		return ""
+	}
+	return ls.events.path()
 }
 
-// replaceTimeAttr serializes the time field as Unix milliseconds
-// to match cursor-agent's timestamp_ms convention.
-func replaceTimeAttr(groups []string, a slog.Attr) slog.Attr {
-	if a.Key == slog.TimeKey {
+// Parts returns the paths of every part of the log file, oldest first.
+// There is one part unless LogConfig.MaxSize rotated the log. A split
+// log's decisions parts come first, then its events parts.
+func (ls *LogSession) Parts() []string {
+	if ls.file == nil {
+		return nil
+	}
+	if ls.events != nil {
+		return append(ls.file.parts(), ls.events.parts()...)
+	}
+	return ls.file.parts()
+}
+
+// Values of LogConfig.TimeFormat: how JSON records write their time and
+// a raw event's recv_ts.
+const (
+	TimeMillis  = "millis"  // Unix milliseconds, cursor-agent's timestamp_ms convention
+	TimeRFC3339 = "rfc3339" // RFC 3339 in UTC, to the millisecond
+)
+
+// rfc3339Millis is RFC 3339 with a fixed three-digit fraction, so that
+// timestamps sort as strings and line up in a pager.
+const rfc3339Millis = "2006-01-02T15:04:05.000Z07:00"
+
+// replaceTimeAttrs returns a ReplaceAttr serializing the time field and
+// recv_ts in format: Unix milliseconds unless it is TimeRFC3339.
+func replaceTimeAttrs(format string) func(groups []string, a slog.Attr) slog.Attr {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) > 0 || (a.Key != slog.TimeKey && a.Key != "recv_ts") {
+			return a
+		}
 		if t, ok := a.Value.Any().(time.Time); ok {
-			a.Value = slog.Int64Value(t.UnixMilli())
+			if format == TimeRFC3339 {
+				a.Value = slog.StringValue(t.UTC().Format(rfc3339Millis))
+			} else {
+				a.Value = slog.Int64Value(t.UnixMilli())
+			}
 		}
+		return a
 	}
-	return a
 }
 
 // multiHandler fans out log records to multiple slog.Handlers.
@@ -156,15 +475,16 @@ func (h *multiHandler) Enabled(_ context.Context, level slog.Level) bool {
 	return false
 }
 
+// Handle passes r to every handler that wants it, even if an earlier
+// one fails, and returns their errors joined.
 func (h *multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
 	for _, handler := range h.handlers {
 		if handler.Enabled(ctx, r.Level) {
-			if err := handler.Handle(ctx, r); err != nil {
-				return err
-			}
+			errs = append(errs, handler.Handle(ctx, r))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 func (h *multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
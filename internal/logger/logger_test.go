@@ -1,12 +1,17 @@
 package logger
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestSetup_CreatesLogFile(t *testing.T) {
@@ -40,6 +45,22 @@ func TestSetup_CreatesLogFile(t *testing.T) {
 	}
 }
 
+func TestSetup_StartedNamesFile(t *testing.T) {
+	dir := t.TempDir()
+	started := time.UnixMilli(1760000000123)
+	ls, teardown := Setup(LogConfig{Dir: dir, ConsoleLevel: slog.LevelWarn, Started: started})
+	defer teardown()
+
+	want := filepath.Join(dir, "cursor-wrap-1760000000123-unknown.jsonl")
+	if got := ls.FilePath(); got != want {
+		t.Errorf("FilePath() = %q, want %q", got, want)
+	}
+
+	if got := FileName(started, "runner-3"); got != "cursor-wrap-1760000000123-runner-3-unknown.jsonl" {
+		t.Errorf("FileName with a tag = %q", got)
+	}
+}
+
 func TestSetup_CreatesDirectoryIfMissing(t *testing.T) {
 	dir := filepath.Join(t.TempDir(), "subdir", "logs")
 	cfg := LogConfig{
@@ -123,6 +144,135 @@ func TestSetSessionID_Idempotent(t *testing.T) {
 	}
 }
 
+func TestAddSessionID_NamesEverySession(t *testing.T) {
+	dir := t.TempDir()
+	ls, teardown := Setup(LogConfig{Dir: dir, ConsoleLevel: slog.LevelError, Started: time.UnixMilli(1760000000123)})
+	defer teardown()
+
+	ls.AddSessionID("first-id") // no id yet: same as SetSessionID
+	ls.SetSessionID("ignored")
+	ls.WithTurn(2).AddSessionID("second-id")
+	ls.AddSessionID("second-id")
+	ls.Info("after rename")
+
+	want := filepath.Join(dir, "cursor-wrap-1760000000123-first-id+second-id.jsonl")
+	if got := ls.FilePath(); got != want {
+		t.Fatalf("FilePath = %q, want %q", got, want)
+	}
+	data, err := os.ReadFile(want)
+	if err != nil || !strings.Contains(string(data), "after rename") {
+		t.Errorf("renamed file: %v\n%s", err, data)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "latest.jsonl")); err == nil && target != filepath.Base(want) {
+		t.Errorf("latest.jsonl -> %q, want %q", target, filepath.Base(want))
+	}
+}
+
+func TestSetup_RotatesPastMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	ls, teardown := Setup(LogConfig{
+		Dir:          dir,
+		ConsoleLevel: slog.LevelError,
+		FileLevel:    slog.LevelDebug,
+		MaxSize:      1024,
+	})
+
+	payload := strings.Repeat("x", 300)
+	for i := range 20 {
+		ls.Info("record", "i", i, "payload", payload)
+	}
+	ls.SetSessionID("rotated-session")
+	ls.Info("after rename")
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := ls.Parts()
+	if len(parts) < 3 {
+		t.Fatalf("expected the log to rotate into several parts, got %v", parts)
+	}
+	if got := ls.FilePath(); got != parts[len(parts)-1] {
+		t.Errorf("FilePath() = %q, want the last part %q", got, parts[len(parts)-1])
+	}
+	for i, p := range parts {
+		want := "-rotated-session.jsonl"
+		if i > 0 {
+			want = fmt.Sprintf("-rotated-session.%d.jsonl", i)
+		}
+		if !strings.HasSuffix(p, want) {
+			t.Errorf("part %d = %q, want suffix %q", i, p, want)
+		}
+	}
+	onDisk, err := filepath.Glob(filepath.Join(dir, logFilePattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onDisk) != len(parts) {
+		t.Errorf("log dir holds %d log files, want %d (no unrenamed parts left behind)", len(onDisk), len(parts))
+	}
+
+	// Every part parses line by line and, together, they hold every record.
+	records := 0
+	for _, p := range parts {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) == 0 && p == parts[len(parts)-1] {
+			continue // the last record filled the previous part
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("%s: line is not valid JSON: %v\nline: %s", p, err, line)
+			}
+			records++
+		}
+		// A part is only rotated once it is full; it never ends early.
+		if p != parts[len(parts)-1] && len(data) < 1024 {
+			t.Errorf("%s: rotated at %d bytes, before reaching MaxSize", p, len(data))
+		}
+	}
+	if records != 21 {
+		t.Errorf("parts hold %d records, want 21", records)
+	}
+}
+
+func TestWithTurn(t *testing.T) {
+	dir := t.TempDir()
+	ls, teardown := Setup(LogConfig{Dir: dir, ConsoleLevel: slog.LevelError, FileLevel: slog.LevelDebug})
+
+	ls.Info("before")
+	turn := ls.WithTurn(2)
+	turn.Info("during")
+	// The derived logger shares the file, so renaming through it moves
+	// the parent's records too.
+	turn.SetSessionID("turn-session")
+	ls.SetSessionID("ignored")
+	teardown()
+
+	if ls.FilePath() != turn.FilePath() || !strings.Contains(ls.FilePath(), "turn-session") {
+		t.Fatalf("paths = %q, %q; want both renamed to turn-session", ls.FilePath(), turn.FilePath())
+	}
+	data, err := os.ReadFile(ls.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %d:\n%s", len(lines), data)
+	}
+	for i, want := range []any{nil, float64(2)} {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec["turn"] != want {
+			t.Errorf("record %d: turn = %v, want %v", i, rec["turn"], want)
+		}
+	}
+}
+
 func TestSetup_WritesValidJSONL(t *testing.T) {
 	dir := t.TempDir()
 	cfg := LogConfig{
@@ -208,6 +358,55 @@ func TestSetup_TimestampIsEpochMillis(t *testing.T) {
 	}
 }
 
+func TestSetup_TimeFormat(t *testing.T) {
+	recv := time.Date(2026, 2, 10, 12, 30, 45, 400_000_000, time.UTC)
+	tests := []struct {
+		format     string
+		wantRecvTS any
+	}{
+		{"", float64(1770726645400)},
+		{TimeMillis, float64(1770726645400)},
+		{TimeRFC3339, "2026-02-10T12:30:45.400Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			ls, teardown := Setup(LogConfig{
+				Dir:          t.TempDir(),
+				ConsoleLevel: slog.LevelError,
+				FileLevel:    slog.LevelDebug,
+				TimeFormat:   tt.format,
+			})
+			ls.Debug("raw_event", Kind(KindRawEvent), "recv_ts", recv, "raw", json.RawMessage(`{}`))
+			teardown()
+
+			data, err := os.ReadFile(ls.FilePath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var record map[string]any
+			if err := json.Unmarshal(data, &record); err != nil {
+				t.Fatal(err)
+			}
+			if record["recv_ts"] != tt.wantRecvTS {
+				t.Errorf("recv_ts = %#v, want %#v", record["recv_ts"], tt.wantRecvTS)
+			}
+			switch ts := record["time"].(type) {
+			case float64:
+				if tt.format == TimeRFC3339 || ts < 1577836800000 {
+					t.Errorf("time = %v", ts)
+				}
+			case string:
+				parsed, err := time.Parse(time.RFC3339, ts)
+				if tt.format != TimeRFC3339 || err != nil || !strings.HasSuffix(ts, "Z") || time.Since(parsed) > time.Minute {
+					t.Errorf("time = %q (%v)", ts, err)
+				}
+			default:
+				t.Errorf("time = %#v", record["time"])
+			}
+		})
+	}
+}
+
 func TestSetup_RawEventRecord(t *testing.T) {
 	dir := t.TempDir()
 	cfg := LogConfig{
@@ -397,3 +596,187 @@ func TestSetup_TeardownClosesFile(t *testing.T) {
 		t.Fatalf("invalid JSONL after teardown: %v", err)
 	}
 }
+
+func TestSetup_SchemaFields(t *testing.T) {
+	ls, teardown := Setup(LogConfig{
+		Dir:            t.TempDir(),
+		ConsoleLevel:   slog.LevelError + 1,
+		FileLevel:      slog.LevelDebug,
+		WrapperVersion: "v1.2.3",
+	})
+	ls.WithTurn(1).Info("agent started", Kind(KindTurnStats), "pid", 42)
+	ls.Warn("retrying")
+	teardown()
+
+	data, err := os.ReadFile(ls.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	host, _ := os.Hostname()
+	origin := fmt.Sprintf(`"host":%q,"wrapper_pid":%d,"user":%q`, host, os.Getpid(), userName())
+	want := []string{
+		`"msg":"agent started","schema_version":1,"wrapper_version":"v1.2.3",` + origin + `,"turn":1,"kind":"turn_stats","pid":42}`,
+		`"msg":"retrying","schema_version":1,"wrapper_version":"v1.2.3",` + origin + `,"kind":"decision"}`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d records, want %d:\n%s", len(lines), len(want), data)
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(lines[i], w) {
+			t.Errorf("record %d = %s\nwant suffix %s", i, lines[i], w)
+		}
+	}
+}
+
+func TestSetup_OriginFields(t *testing.T) {
+	ls, teardown := Setup(LogConfig{Dir: t.TempDir(), ConsoleLevel: slog.LevelError + 1, FileLevel: slog.LevelDebug, Tag: "runner-3"})
+	log := ls.WithTurn(1)
+	log.Debug("raw_event", Kind(KindRawEvent), "raw", json.RawMessage(`{"type":"thinking"}`))
+	log.Warn("hang detected")
+	teardown()
+
+	if !strings.Contains(filepath.Base(ls.FilePath()), "-runner-3-") {
+		t.Errorf("log file %s is not named after the tag", ls.FilePath())
+	}
+	data, err := os.ReadFile(ls.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec["host"] != host || rec["wrapper_pid"] != float64(os.Getpid()) || rec["user"] == "" || rec["tag"] != "runner-3" {
+			t.Errorf("%s record missing origin fields: %s", rec["kind"], line)
+		}
+	}
+}
+
+// captureConsole redirects the console sink for the test.
+func captureConsole(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := consoleOut
+	consoleOut = &buf
+	t.Cleanup(func() { consoleOut = orig })
+	return &buf
+}
+
+func TestSetup_ConsoleFormat(t *testing.T) {
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, format, dir string
+	}{
+		{"text", "text", t.TempDir()},
+		{"json", "json", t.TempDir()},
+		{"json console only", "json", filepath.Join(blocked, "logs")}, // can't be created
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			console := captureConsole(t)
+			ls, teardown := Setup(LogConfig{Dir: tt.dir, ConsoleLevel: slog.LevelInfo, ConsoleFormat: tt.format})
+			ls.WithTurn(3).Info("hang detected", "idle_silence_ms", 61000)
+			teardown()
+
+			lines := strings.Split(strings.TrimSpace(console.String()), "\n")
+			line := lines[len(lines)-1]
+			if tt.format == "text" {
+				if !strings.Contains(line, `msg="hang detected" turn=3 idle_silence_ms=61000`) || strings.Contains(line, "kind=") {
+					t.Errorf("console = %q", line)
+				}
+				return
+			}
+			// Including the warning about the fallback, if any.
+			for _, l := range lines {
+				if !json.Valid([]byte(l)) {
+					t.Errorf("console line is not JSON: %s", l)
+				}
+			}
+			var rec map[string]any
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatal(err)
+			}
+			ts, ok := rec["time"].(float64)
+			if !ok || ts < 1e12 || ts > 1e14 {
+				t.Errorf("time = %v, want epoch millis", rec["time"])
+			}
+			if rec["msg"] != "hang detected" || rec["turn"] != float64(3) || rec["kind"] != "decision" {
+				t.Errorf("console record = %v", rec)
+			}
+		})
+	}
+}
+
+func TestSetConsoleLevel(t *testing.T) {
+	console := captureConsole(t)
+	ls, teardown := Setup(LogConfig{Dir: t.TempDir(), ConsoleLevel: slog.LevelWarn, FileLevel: slog.LevelInfo})
+	path := ls.FilePath()
+	turn := ls.WithTurn(1)
+	turn.Info("before")
+	ls.SetConsoleLevel(slog.LevelInfo)
+	turn.Info("after")
+	teardown()
+
+	if out := console.String(); strings.Contains(out, "before") || !strings.Contains(out, "msg=after") {
+		t.Errorf("console = %q; want only the record logged after lowering the level", out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"before"`) {
+		t.Errorf("file sink lost a record below the old console level:\n%s", data)
+	}
+}
+
+func TestSetup_NoFile(t *testing.T) {
+	console := captureConsole(t)
+	dir := filepath.Join(t.TempDir(), "logs")
+	ls, teardown := Setup(LogConfig{Dir: dir, NoFile: true, ConsoleLevel: slog.LevelInfo, FileLevel: slog.LevelDebug})
+	ls.SetSessionID("abc-123") // no file to rename
+	ls.WithTurn(1).Info("agent started")
+	if err := teardown(); err != nil {
+		t.Fatalf("teardown: %v", err)
+	}
+
+	if ls.FilePath() != "" || ls.Parts() != nil {
+		t.Errorf("FilePath = %q, Parts = %v; want no file", ls.FilePath(), ls.Parts())
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("log directory was created (stat: %v)", err)
+	}
+	if got := strings.TrimSpace(console.String()); !strings.Contains(got, "agent started") || strings.Contains(got, "WARN") {
+		t.Errorf("console = %q, want the record and no warning", got)
+	}
+}
+
+func TestSetup_NoRawEvents(t *testing.T) {
+	dir := t.TempDir()
+	ls, teardown := Setup(LogConfig{Dir: dir, ConsoleLevel: slog.LevelError, FileLevel: slog.LevelDebug, NoRawEvents: true})
+	ls.Debug("raw_event", Kind(KindRawEvent), "raw", json.RawMessage(`{"type":"system"}`))
+	ls.Debug("verdict_waiting", Kind(KindVerdict))
+	ls.Error("hang detected")
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(ls.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if strings.Contains(got, `"msg":"raw_event"`) {
+		t.Errorf("raw event written despite NoRawEvents:\n%s", got)
+	}
+	for _, msg := range []string{"verdict_waiting", "hang detected"} {
+		if !strings.Contains(got, msg) {
+			t.Errorf("missing %q:\n%s", msg, got)
+		}
+	}
+}
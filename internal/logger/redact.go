@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// RedactRule replaces matches of Pattern with "[REDACTED:<Name>]".
+type RedactRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRedactRules cover the token shapes tools most often echo to
+// stdout: AWS keys, GitHub tokens and HTTP bearer credentials.
+func DefaultRedactRules() []RedactRule {
+	return []RedactRule{
+		{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{"aws-secret-key", regexp.MustCompile(`(?i)aws_secret_access_key\s*[=:]\s*[A-Za-z0-9/+=]{40}`)},
+		{"github-token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+		{"github-token", regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}`)},
+		{"bearer", regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{8,}=*`)},
+	}
+}
+
+// Redactor masks secrets in the raw JSON of logged events. It only ever
+// rewrites the contents of JSON strings, so its output is valid JSON
+// whenever its input is.
+type Redactor struct {
+	rules []RedactRule
+}
+
+// NewRedactor returns a Redactor applying rules in order, or nil (which
+// redacts nothing) if there are none.
+func NewRedactor(rules []RedactRule) *Redactor {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &Redactor{rules: rules}
+}
+
+// RedactJSON returns raw with every rule applied to the value of each
+// string in it (keys included). raw is returned as is when nothing
+// matches; otherwise the result is a new slice.
+func (r *Redactor) RedactJSON(raw []byte) []byte {
+	if r == nil {
+		return raw
+	}
+	var out []byte
+	copied := 0 // raw[:copied] is already in out
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '"' {
+			continue
+		}
+		end := stringEnd(raw, i)
+		if end < 0 {
+			break // malformed: leave the rest alone
+		}
+		if repl, ok := r.redactString(raw[i:end]); ok {
+			out = append(out, raw[copied:i]...)
+			out = append(out, repl...)
+			copied = end
+		}
+		i = end - 1
+	}
+	if out == nil {
+		return raw
+	}
+	return append(out, raw[copied:]...)
+}
+
+// RedactText returns s with every rule applied.
+func (r *Redactor) RedactText(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, rule := range r.rules {
+		s = rule.Pattern.ReplaceAllLiteralString(s, "[REDACTED:"+rule.Name+"]")
+	}
+	return s
+}
+
+// redactString applies the rules to a quoted JSON string and returns its
+// re-encoded replacement if any rule matched. Working on the decoded
+// value means a match can never leave a broken escape sequence behind.
+func (r *Redactor) redactString(quoted []byte) ([]byte, bool) {
+	var s string
+	if err := json.Unmarshal(quoted, &s); err != nil {
+		return nil, false
+	}
+	changed := false
+	for _, rule := range r.rules {
+		if rule.Pattern.MatchString(s) {
+			s = rule.Pattern.ReplaceAllLiteralString(s, "[REDACTED:"+rule.Name+"]")
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, false
+	}
+	repl, err := json.Marshal(s)
+	if err != nil {
+		return nil, false
+	}
+	return repl, true
+}
+
+// stringEnd returns the index just past the closing quote of the JSON
+// string whose opening quote is at raw[start], or -1 if it is unclosed.
+func stringEnd(raw []byte, start int) int {
+	for i := start + 1; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
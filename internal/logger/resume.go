@@ -0,0 +1,220 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResumedLog returns the log a session resuming cfg.ResumeSessionID
+// appends to (its decisions file with SplitLogs), and whether there is
+// one; see LogConfig.ResumeSessionID.
+func ResumedLog(cfg LogConfig) (string, bool) {
+	suffix := ""
+	if cfg.SplitLogs {
+		suffix = decisionsSuffix
+	}
+	path, _, ok := findSessionLog(cfg, suffix)
+	return path, ok
+}
+
+// findSessionLog returns the first part of the newest log in cfg.Dir
+// named after cfg.ResumeSessionID, with suffix before ".jsonl", and the
+// session ids in its name.
+func findSessionLog(cfg LogConfig, suffix string) (path string, ids []string, ok bool) {
+	if cfg.ResumeSessionID == "" || cfg.NewLogOnResume {
+		return "", nil, false
+	}
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return "", nil, false
+	}
+	var newest int64
+	for _, e := range entries {
+		started, names, match := parseLogName(e.Name(), cfg.Tag, suffix)
+		if !match || !slices.Contains(names, cfg.ResumeSessionID) || (ok && started < newest) {
+			continue
+		}
+		path, ids, newest, ok = e.Name(), names, started, true
+	}
+	if !ok {
+		return "", nil, false
+	}
+	return filepath.Join(cfg.Dir, path), ids, true
+}
+
+// RecentSessions returns the ids of the sessions logged in dir, most
+// recently active first, for picking one to resume: each log counts as
+// the last session it ran, as of when any of its parts was last written.
+// With tag, only logs tagged tag count. An id is listed once, however
+// many logs it has.
+func RecentSessions(dir, tag string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	type sessionLog struct {
+		first string
+		ids   []string
+		mod   time.Time
+	}
+	logs := map[string]*sessionLog{}
+	for _, e := range entries {
+		first := firstPart(e.Name(), names)
+		if strings.HasSuffix(first, eventsSuffix+".jsonl") {
+			continue // its decisions file stands for the session
+		}
+		suffix := ""
+		if strings.HasSuffix(first, decisionsSuffix+".jsonl") {
+			suffix = decisionsSuffix
+		}
+		_, ids, ok := parseLogName(first, tag, suffix)
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // gone since ReadDir
+		}
+		l := logs[first]
+		if l == nil {
+			l = &sessionLog{first: first, ids: ids}
+			logs[first] = l
+		}
+		if info.ModTime().After(l.mod) {
+			l.mod = info.ModTime()
+		}
+	}
+	sorted := make([]*sessionLog, 0, len(logs))
+	for _, l := range logs {
+		sorted = append(sorted, l)
+	}
+	slices.SortFunc(sorted, func(a, b *sessionLog) int { return b.mod.Compare(a.mod) })
+
+	var ids []string
+	for _, l := range sorted {
+		if tag == "" && len(l.ids) == 1 {
+			// Untagged, a tagged log's tag reads as part of its first id.
+			if t := logTag(filepath.Join(dir, l.first)); t != "" {
+				l.ids[0] = strings.TrimPrefix(l.ids[0], t+"-")
+			}
+		}
+		if id := l.ids[len(l.ids)-1]; !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// firstPart returns the first part of the log whose part name is, among
+// the files in names; see partPath.
+func firstPart(name string, names map[string]bool) string {
+	base, ok := strings.CutSuffix(name, ".jsonl")
+	if !ok {
+		return name
+	}
+	dot := strings.LastIndexByte(base, '.')
+	if dot < 0 {
+		return name
+	}
+	if _, err := strconv.Atoi(base[dot+1:]); err != nil {
+		return name
+	}
+	if first := base[:dot] + ".jsonl"; names[first] {
+		return first
+	}
+	return name
+}
+
+// logTag returns the tag on the first record of the log at path, if any.
+func logTag(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }() // read-only
+	line, _ := bufio.NewReader(f).ReadBytes('\n')
+	var rec struct {
+		Tag string `json:"tag"`
+	}
+	_ = json.Unmarshal(line, &rec) // no tag then
+	return rec.Tag
+}
+
+// parseLogName returns the start time and the session ids in the name of
+// the first part of a log tagged tag, as addSessionID writes them. Session
+// ids may hold dashes, so without a tag a tagged log's tag reads as part
+// of its first id; renaming it still works, the old ids being matched as
+// a substring.
+func parseLogName(name, tag, suffix string) (started int64, ids []string, ok bool) {
+	rest, ok := strings.CutPrefix(name, "cursor-wrap-")
+	if !ok {
+		return 0, nil, false
+	}
+	if rest, ok = strings.CutSuffix(rest, suffix+".jsonl"); !ok {
+		return 0, nil, false
+	}
+	ms, rest, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, nil, false
+	}
+	started, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return 0, nil, false
+	}
+	if tag != "" {
+		if rest, ok = strings.CutPrefix(rest, tag+"-"); !ok {
+			return 0, nil, false
+		}
+	}
+	if rest == "unknown" {
+		return 0, nil, false
+	}
+	return started, strings.Split(rest, "+"), true
+}
+
+// resumeRotating reopens, for appending, the log of the session cfg
+// resumes and the parts it was rotated into, carrying on in the last
+// one. It returns nil and no error if there is no such log, and an error
+// if there is one but it can't be opened.
+func resumeRotating(cfg LogConfig, suffix string, latest bool) (*rotatingFile, error) {
+	first, ids, ok := findSessionLog(cfg, suffix)
+	if !ok {
+		return nil, nil
+	}
+	paths := []string{first}
+	for n := 1; ; n++ {
+		part := partPath(first, n)
+		if _, err := os.Stat(part); err != nil {
+			break
+		}
+		paths = append(paths, part)
+	}
+	f, err := openLogFile(paths[len(paths)-1])
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close() // nothing was written to it
+		return nil, err
+	}
+	return &rotatingFile{
+		f:       f,
+		size:    info.Size(),
+		maxSize: cfg.MaxSize,
+		paths:   paths,
+		ids:     ids,
+		suffix:  suffix,
+		latest:  latest,
+	}, nil
+}
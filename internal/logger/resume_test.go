@@ -0,0 +1,232 @@
+package logger
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runSession logs one record in a session started at ms, named after
+// the session id it resumes or, failing that, after id.
+func runSession(t *testing.T, cfg LogConfig, ms int64, id string) *LogSession {
+	t.Helper()
+	cfg.ConsoleLevel, cfg.FileLevel = slog.LevelWarn, slog.LevelDebug
+	cfg.Started = time.UnixMilli(ms)
+	ls, teardown := Setup(cfg)
+	ls.SetSessionID(id)
+	ls.Info("turn", "started", ms)
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+	return ls
+}
+
+// logFiles returns the session logs in dir.
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "cursor-wrap-") {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestSetup_ResumeAppendsToSessionLog(t *testing.T) {
+	dir := t.TempDir()
+	runSession(t, LogConfig{Dir: dir}, 1000, "sess-1")
+	runSession(t, LogConfig{Dir: dir}, 2000, "sess-2")
+	ls := runSession(t, LogConfig{Dir: dir, ResumeSessionID: "sess-1"}, 3000, "sess-1")
+
+	want := filepath.Join(dir, "cursor-wrap-1000-sess-1.jsonl")
+	if ls.FilePath() != want {
+		t.Errorf("FilePath = %q, want %q", ls.FilePath(), want)
+	}
+	if names := logFiles(t, dir); len(names) != 2 {
+		t.Errorf("log files = %v, want no new one", names)
+	}
+	got := readKinds(t, want)
+	msgs := make([]string, len(got))
+	for i, r := range got {
+		msgs[i] = r[0]
+	}
+	if !slices.Equal(msgs, []string{"turn", "session log resumed", "turn"}) {
+		t.Errorf("records = %v", msgs)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "latest.jsonl")); err != nil || target != filepath.Base(want) {
+		t.Errorf("latest.jsonl -> %q, want the resumed log", target)
+	}
+}
+
+func TestSetup_ResumeContinuesInLastPart(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "cursor-wrap-1000-a+sess-1.jsonl")
+	for _, p := range []string{first, partPath(first, 1)} {
+		if err := os.WriteFile(p, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ls := runSession(t, LogConfig{Dir: dir, ResumeSessionID: "sess-1"}, 2000, "sess-1")
+	if got := ls.Parts(); !slices.Equal(got, []string{first, partPath(first, 1)}) {
+		t.Errorf("Parts = %v", got)
+	}
+	if names := logFiles(t, dir); len(names) != 2 {
+		t.Errorf("log files = %v, want no new one", names)
+	}
+
+	// A new session id is added to the name after the ones already there.
+	ls2, teardown := Setup(LogConfig{Dir: dir, ConsoleLevel: slog.LevelWarn, FileLevel: slog.LevelDebug, ResumeSessionID: "sess-1"})
+	ls2.AddSessionID("sess-2")
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := filepath.Base(ls2.FilePath()), "cursor-wrap-1000-a+sess-1+sess-2.1.jsonl"; got != want {
+		t.Errorf("after AddSessionID, FilePath = %q, want %q", got, want)
+	}
+}
+
+func TestSetup_ResumeWithoutSessionLog(t *testing.T) {
+	dir := t.TempDir()
+	runSession(t, LogConfig{Dir: dir}, 1000, "sess-1")
+	ls := runSession(t, LogConfig{Dir: dir, ResumeSessionID: "sess-2"}, 2000, "sess-2")
+	if got, want := ls.FilePath(), filepath.Join(dir, "cursor-wrap-2000-sess-2.jsonl"); got != want {
+		t.Errorf("FilePath = %q, want %q", got, want)
+	}
+}
+
+func TestSetup_NewLogOnResume(t *testing.T) {
+	dir := t.TempDir()
+	runSession(t, LogConfig{Dir: dir}, 1000, "sess-1")
+	ls := runSession(t, LogConfig{Dir: dir, ResumeSessionID: "sess-1", NewLogOnResume: true}, 2000, "sess-1")
+	if got, want := ls.FilePath(), filepath.Join(dir, "cursor-wrap-2000-sess-1.jsonl"); got != want {
+		t.Errorf("FilePath = %q, want %q", got, want)
+	}
+}
+
+func TestSetup_ResumeSplitLogs(t *testing.T) {
+	dir := t.TempDir()
+	runSession(t, LogConfig{Dir: dir, SplitLogs: true}, 1000, "sess-1")
+	cfg := LogConfig{Dir: dir, SplitLogs: true, ResumeSessionID: "sess-1"}
+	if path, ok := ResumedLog(cfg); !ok || path != filepath.Join(dir, "cursor-wrap-1000-sess-1-decisions.jsonl") {
+		t.Errorf("ResumedLog = %q, %v", path, ok)
+	}
+	ls := runSession(t, cfg, 2000, "sess-1")
+	if got := filepath.Base(ls.EventsFilePath()); got != "cursor-wrap-1000-sess-1-events.jsonl" {
+		t.Errorf("EventsFilePath = %q", got)
+	}
+	if names := logFiles(t, dir); len(names) != 2 {
+		t.Errorf("log files = %v, want no new ones", names)
+	}
+}
+
+func TestSetup_ResumeUnwritableSessionLog(t *testing.T) {
+	console := captureConsole(t)
+	dir := t.TempDir()
+	// Unwritable even for root: a directory where the log should be.
+	if err := os.Mkdir(filepath.Join(dir, "cursor-wrap-1000-sess-1.jsonl"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ls := runSession(t, LogConfig{Dir: dir, ResumeSessionID: "sess-1"}, 2000, "sess-1")
+	if got, want := ls.FilePath(), filepath.Join(dir, "cursor-wrap-2000-sess-1.jsonl"); got != want {
+		t.Errorf("FilePath = %q, want %q", got, want)
+	}
+	if !strings.Contains(console.String(), "could not append to the resumed session's log") {
+		t.Errorf("console = %q, want a warning", console.String())
+	}
+}
+
+func TestParseLogName(t *testing.T) {
+	tests := []struct {
+		name, tag, suffix string
+		started           int64
+		ids               []string
+	}{
+		{"cursor-wrap-1000-sess-1.jsonl", "", "", 1000, []string{"sess-1"}},
+		{"cursor-wrap-1000-a+b.jsonl", "", "", 1000, []string{"a", "b"}},
+		{"cursor-wrap-1000-ci-sess-1.jsonl", "ci", "", 1000, []string{"sess-1"}},
+		{"cursor-wrap-1000-sess-1-events.jsonl", "", eventsSuffix, 1000, []string{"sess-1"}},
+		{"cursor-wrap-1000-sess-1.jsonl", "ci", "", 0, nil},
+		{"cursor-wrap-1000-unknown.jsonl", "", "", 0, nil},
+		{"cursor-wrap-1000-sess-1.1.jsonl", "", "", 1000, []string{"sess-1.1"}},
+		{"latest.jsonl", "", "", 0, nil},
+	}
+	for _, tt := range tests {
+		started, ids, _ := parseLogName(tt.name, tt.tag, tt.suffix)
+		if started != tt.started || !slices.Equal(ids, tt.ids) {
+			t.Errorf("parseLogName(%q, %q, %q) = %d, %v; want %d, %v", tt.name, tt.tag, tt.suffix, started, ids, tt.started, tt.ids)
+		}
+	}
+}
+
+func TestRecentSessions(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	write := func(name, content string, age time.Duration) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mod := base.Add(-age)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("cursor-wrap-1000-old.jsonl", "{}\n", 5*time.Minute)
+	// Resumed into a new session since; the second part was written last.
+	write("cursor-wrap-2000-a+b.jsonl", "{}\n", 4*time.Minute)
+	write("cursor-wrap-2000-a+b.1.jsonl", "{}\n", time.Minute)
+	write("cursor-wrap-3000-split-events.jsonl", "{}\n", 0)
+	write("cursor-wrap-3000-split-decisions.jsonl", "{}\n", 2*time.Minute)
+	write("cursor-wrap-4000-ci-tagged.jsonl", `{"msg":"wrapper started","tag":"ci"}`+"\n", 3*time.Minute)
+	write("cursor-wrap-5000-unknown.jsonl", "{}\n", 0)
+	write("cursor-wrap-6000-old.jsonl", "{}\n", 6*time.Minute) // a second log of "old"
+	write("cursor-wrap-1000-old.summary.json", "{}", 0)
+
+	tests := []struct {
+		tag  string
+		want []string
+	}{
+		{"", []string{"b", "split", "tagged", "old"}},
+		{"ci", []string{"tagged"}},
+		{"nightly", nil},
+	}
+	for _, tt := range tests {
+		got, err := RecentSessions(dir, tt.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RecentSessions(tag %q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+	if _, err := RecentSessions(filepath.Join(dir, "missing"), ""); !os.IsNotExist(err) {
+		t.Errorf("missing dir: err = %v, want not-exist", err)
+	}
+}
+
+func TestRecentSessions_FromSetup(t *testing.T) {
+	dir := t.TempDir()
+	runSession(t, LogConfig{Dir: dir, Tag: "ci"}, 1000, "sess-1")
+	time.Sleep(10 * time.Millisecond) // distinct mod times
+	runSession(t, LogConfig{Dir: dir}, 2000, "sess-2")
+	time.Sleep(10 * time.Millisecond)
+	runSession(t, LogConfig{Dir: dir, Tag: "ci", ResumeSessionID: "sess-1"}, 3000, "sess-1")
+
+	got, err := RecentSessions(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"sess-1", "sess-2"}; !slices.Equal(got, want) {
+		t.Errorf("RecentSessions = %v, want %v", got, want)
+	}
+}
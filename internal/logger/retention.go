@@ -0,0 +1,115 @@
+package logger
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logFilePattern matches the session logs (and rotated parts) Setup
+// creates; nothing else in the log directory is ever pruned.
+const logFilePattern = "cursor-wrap-*.jsonl"
+
+// Prune removes session logs from dir that are older than maxAge or
+// beyond the newest maxCount, oldest first, and returns the paths it
+// removed. A zero maxAge or maxCount disables that limit. A session's
+// files, its rotated parts and --split-logs halves, count and go
+// together, dated by the newest of them. The session with the files in
+// keep (the current one) is never removed, and counts toward maxCount.
+// Errors from individual removals are joined; the remaining files are
+// still pruned.
+func Prune(dir string, keep []string, maxAge time.Duration, maxCount int, now time.Time) ([]string, error) {
+	if maxAge <= 0 && maxCount <= 0 {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	type session struct {
+		files   []logFile
+		modTime time.Time // of the newest file
+	}
+	kept := make(map[string]bool, len(keep))
+	for _, path := range keep {
+		kept[sessionKey(filepath.Base(path))] = true
+	}
+	byKey := map[string]*session{}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if ok, _ := filepath.Match(logFilePattern, e.Name()); !ok {
+			continue
+		}
+		key := sessionKey(e.Name())
+		if kept[key] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed since ReadDir
+		}
+		s := byKey[key]
+		if s == nil {
+			s = &session{}
+			byKey[key] = s
+		}
+		s.files = append(s.files, logFile{filepath.Join(dir, e.Name()), info.ModTime()})
+		if info.ModTime().After(s.modTime) {
+			s.modTime = info.ModTime()
+		}
+	}
+	sessions := slices.Collect(maps.Values(byKey))
+	// Newest first, so everything past the count limit is at the end.
+	slices.SortFunc(sessions, func(a, b *session) int { return b.modTime.Compare(a.modTime) })
+
+	var doomed []logFile
+	for i, s := range sessions {
+		tooMany := maxCount > 0 && i+len(kept) >= maxCount
+		tooOld := maxAge > 0 && now.Sub(s.modTime) > maxAge
+		if tooMany || tooOld {
+			slices.SortFunc(s.files, func(a, b logFile) int { return b.modTime.Compare(a.modTime) })
+			doomed = append(doomed, s.files...)
+		}
+	}
+	// Oldest first, a session's parts together.
+	slices.Reverse(doomed)
+
+	var removed []string
+	var errs []error
+	for _, f := range doomed {
+		if err := os.Remove(f.path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, f.path)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// sessionKey returns what the name of a session log's file has in common
+// with the session's other files: the name without its part number
+// (…-<id>.2.jsonl) and --split-logs suffix (…-<id>-events.jsonl).
+func sessionKey(name string) string {
+	base := strings.TrimSuffix(name, ".jsonl")
+	if dot := strings.LastIndexByte(base, '.'); dot >= 0 {
+		if _, err := strconv.Atoi(base[dot+1:]); err == nil {
+			base = base[:dot]
+		}
+	}
+	for _, suffix := range []string{eventsSuffix, decisionsSuffix} {
+		base = strings.TrimSuffix(base, suffix)
+	}
+	return base
+}
@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// name → age; "current" is the session's own log. Session c was
+	// rotated with --log-max-size, and session d split with --split-logs
+	// and rotated too: their files go together.
+	files := []struct {
+		name string
+		age  time.Duration
+	}{
+		{"cursor-wrap-5-current.jsonl", 0},
+		{"cursor-wrap-4-b.jsonl", 1 * day},
+		{"cursor-wrap-3-c.jsonl", 2 * day},
+		{"cursor-wrap-3-c.1.jsonl", 2*day + time.Hour},
+		{"cursor-wrap-2-d-events.jsonl", 10 * day},
+		{"cursor-wrap-2-d-events.1.jsonl", 10*day + time.Hour},
+		{"cursor-wrap-2-d-decisions.jsonl", 10 * day},
+		{"cursor-wrap-1-e.jsonl", 40 * day},
+		{"notes.jsonl", 90 * day},            // not a session log
+		{"cursor-wrap-1.stdout", 90 * day},   // --detach output
+		{"cursor-wrap-0-old.json", 90 * day}, // wrong extension
+	}
+	c := []string{"cursor-wrap-3-c.jsonl", "cursor-wrap-3-c.1.jsonl"}
+	d := []string{"cursor-wrap-2-d-events.jsonl", "cursor-wrap-2-d-events.1.jsonl", "cursor-wrap-2-d-decisions.jsonl"}
+	cur := []string{"cursor-wrap-5-current.jsonl"}
+	b := []string{"cursor-wrap-4-b.jsonl"}
+	e := []string{"cursor-wrap-1-e.jsonl"}
+
+	tests := []struct {
+		name     string
+		maxAge   time.Duration
+		maxCount int
+		want     []string // surviving session logs, newest first
+	}{
+		{
+			name: "no limits",
+			want: slices.Concat(cur, b, c, d, e),
+		},
+		{
+			name:   "by age",
+			maxAge: 7 * day,
+			want:   slices.Concat(cur, b, c),
+		},
+		{
+			name:   "by age, dated by a session's newest file",
+			maxAge: 10*day + time.Minute,
+			want:   slices.Concat(cur, b, c, d),
+		},
+		{
+			name:     "by count, including the current log",
+			maxCount: 3,
+			want:     slices.Concat(cur, b, c),
+		},
+		{
+			name:     "by count, a split session counting once",
+			maxCount: 4,
+			want:     slices.Concat(cur, b, c, d),
+		},
+		{
+			name:     "either limit",
+			maxAge:   30 * day,
+			maxCount: 5,
+			want:     slices.Concat(cur, b, c, d),
+		},
+		{
+			name:     "current log is never removed",
+			maxAge:   time.Nanosecond,
+			maxCount: 1,
+			want:     cur,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, f := range files {
+				path := filepath.Join(dir, f.name)
+				if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				mtime := now.Add(-f.age)
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			current := filepath.Join(dir, "cursor-wrap-5-current.jsonl")
+			// The current log is backdated too, to show keep wins over age.
+			if err := os.Chtimes(current, now.Add(-time.Hour), now.Add(-time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+
+			removed, err := Prune(dir, []string{current}, tt.maxAge, tt.maxCount, now)
+			if err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+
+			var survivors []string
+			for _, f := range files {
+				if _, err := os.Stat(filepath.Join(dir, f.name)); err == nil {
+					survivors = append(survivors, f.name)
+				}
+			}
+			want := append(slices.Clone(tt.want), "notes.jsonl", "cursor-wrap-1.stdout", "cursor-wrap-0-old.json")
+			if !slices.Equal(survivors, want) {
+				t.Errorf("survivors = %v, want %v", survivors, want)
+			}
+			if got, wantRemoved := len(removed), len(files)-len(want); got != wantRemoved {
+				t.Errorf("removed %d files (%v), want %d", got, removed, wantRemoved)
+			}
+			// Oldest first.
+			age := map[string]time.Duration{}
+			for _, f := range files {
+				age[filepath.Join(dir, f.name)] = f.age
+			}
+			for i := 1; i < len(removed); i++ {
+				if age[removed[i]] > age[removed[i-1]] {
+					t.Errorf("removed %s before the older %s", removed[i-1], removed[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// rotatingFile is the file sink's writer. With a positive maxSize it
+// moves on to a numbered sibling (…-<id>.1.jsonl, .2.jsonl, …) once the
+// active part reaches maxSize bytes. slog handlers write each record
+// with a single Write, so rotating between writes never splits a line.
+type rotatingFile struct {
+	mu      sync.Mutex // protects everything below
+	f       *os.File
+	size    int64
+	maxSize int64
+	paths   []string // every part, oldest first; the last one is active
+	ids     []string // session ids in the parts' names, in order
+	suffix  string   // follows the ids in the name, e.g. "-events"
+	latest  bool     // latest.jsonl follows this file's active part
+}
+
+// openLogFile opens path for appending. Durability is up to the sink's
+// SyncMode (see syncHandler); each record is one write(2), so nothing
+// sits in a user-space buffer.
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// openRotating opens the first part of a log file named
+// FileName(…) with suffix inserted before ".jsonl".
+func openRotating(path, suffix string, maxSize int64, latest bool) (*rotatingFile, error) {
+	f, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rotatingFile{f: f, maxSize: maxSize, paths: []string{path}, suffix: suffix, latest: latest}, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	if err == nil && r.maxSize > 0 && r.size >= r.maxSize {
+		r.rotate()
+	}
+	return n, err
+}
+
+// rotate closes the active part and opens the next one. If the next part
+// can't be opened, rotation is turned off and writing carries on in the
+// current part: an oversized log beats a lost one.
+func (r *rotatingFile) rotate() {
+	next := partPath(r.paths[0], len(r.paths))
+	f, err := openLogFile(next)
+	if err != nil {
+		slog.Warn("log rotation failed, continuing in the current file", "path", next, "error", err)
+		r.maxSize = 0
+		return
+	}
+	_ = fsync(r.f) // best effort: the part is complete either way
+	_ = r.f.Close()
+	r.f = f
+	r.size = 0
+	r.paths = append(r.paths, next)
+	if !r.latest {
+		return
+	}
+	if err := updateLatest(next); err != nil {
+		slog.Warn("failed to update the latest log pointer", "error", err)
+	}
+}
+
+// partPath returns the name of part n (n ≥ 1) of the log whose first
+// part is first.
+func partPath(first string, n int) string {
+	return fmt.Sprintf("%s.%d.jsonl", strings.TrimSuffix(first, ".jsonl"), n)
+}
+
+// addSessionID renames every part to include id: the first id replaces
+// "unknown", later ones are appended with a "+" (…-<id1>+<id2>.jsonl).
+// With first set, it does nothing once any id is in the name; otherwise
+// it does nothing if id already is. Parts that fail to rename keep their
+// old path; their errors are returned, and logging them is left to the
+// caller since the logger writes through r. The id counts as added if
+// any part was renamed.
+func (r *rotatingFile) addSessionID(id string, first bool) (renamed bool, errs []error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if (first && len(r.ids) > 0) || slices.Contains(r.ids, id) {
+		return false, nil
+	}
+	old := "-unknown" + r.suffix + "."
+	if len(r.ids) > 0 {
+		old = "-" + strings.Join(r.ids, "+") + r.suffix + "."
+	}
+	new := "-" + strings.Join(append(slices.Clone(r.ids), id), "+") + r.suffix + "."
+	for i, p := range r.paths {
+		base := filepath.Base(p)
+		newBase := strings.Replace(base, old, new, 1)
+		if newBase == base {
+			continue
+		}
+		newPath := filepath.Join(filepath.Dir(p), newBase)
+		if err := os.Rename(p, newPath); err != nil {
+			errs = append(errs, fmt.Errorf("renaming %s to %s: %w", p, newPath, err))
+			continue
+		}
+		r.paths[i] = newPath
+		renamed = true
+	}
+	if renamed {
+		r.ids = append(r.ids, id)
+	}
+	return renamed, errs
+}
+
+func (r *rotatingFile) path() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paths[len(r.paths)-1]
+}
+
+func (r *rotatingFile) parts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.paths...)
+}
+
+// Sync commits the active part to disk.
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fsync(r.f)
+}
+
+// Close syncs and closes the active part.
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return errors.Join(fsync(r.f), r.f.Close())
+}
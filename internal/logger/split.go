@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Suffixes of the two files of a split session log (LogConfig.SplitLogs).
+const (
+	eventsSuffix    = "-events"
+	decisionsSuffix = "-decisions"
+)
+
+// SplitFileNames returns the names Setup gives the events and decisions
+// files of a split log (LogConfig.SplitLogs), like FileName.
+func SplitFileNames(started time.Time, tag string) (events, decisions string) {
+	base := strings.TrimSuffix(FileName(started, tag), ".jsonl")
+	return base + eventsSuffix + ".jsonl", base + decisionsSuffix + ".jsonl"
+}
+
+// isEventKind reports whether records of kind go to the events file of a
+// split log: what the agent said, as opposed to what the wrapper made of
+// it.
+func isEventKind(kind string) bool {
+	return kind == KindRawEvent || kind == KindAgentStderr
+}
+
+// splitHandler routes the file sink's records by kind between the events
+// and decisions files.
+type splitHandler struct {
+	events, decisions slog.Handler
+}
+
+func (h *splitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.events.Enabled(ctx, level) || h.decisions.Enabled(ctx, level)
+}
+
+func (h *splitHandler) Handle(ctx context.Context, r slog.Record) error {
+	if isEventKind(recordKind(r)) {
+		return h.events.Handle(ctx, r)
+	}
+	return h.decisions.Handle(ctx, r)
+}
+
+func (h *splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &splitHandler{events: h.events.WithAttrs(attrs), decisions: h.decisions.WithAttrs(attrs)}
+}
+
+func (h *splitHandler) WithGroup(name string) slog.Handler {
+	return &splitHandler{events: h.events.WithGroup(name), decisions: h.decisions.WithGroup(name)}
+}
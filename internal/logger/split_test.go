@@ -0,0 +1,119 @@
+package logger
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readKinds returns the msg and kind of every record in a log file.
+func readKinds(t *testing.T, path string) [][2]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out [][2]string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec struct{ Msg, Kind string }
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		out = append(out, [2]string{rec.Msg, rec.Kind})
+	}
+	return out
+}
+
+func TestSetup_SplitLogsRoutesByKind(t *testing.T) {
+	for _, async := range []AsyncMode{AsyncOff, AsyncBlock} {
+		t.Run(async.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			ls, teardown := Setup(LogConfig{
+				Dir:          dir,
+				ConsoleLevel: slog.LevelError,
+				FileLevel:    slog.LevelDebug,
+				Started:      time.UnixMilli(1760000000123),
+				SplitLogs:    true,
+				Async:        async,
+			})
+
+			ls.Debug("raw_event", Kind(KindRawEvent), "raw", json.RawMessage(`{"type":"system"}`))
+			ls.Info("hang detected", "reason", "idle")
+			ls.Debug("agent stderr", Kind(KindAgentStderr), "line", "boom")
+			ls.WithTurn(1).Debug("tick", Kind(KindVerdict))
+			if err := teardown(); err != nil {
+				t.Fatal(err)
+			}
+
+			events := filepath.Join(dir, "cursor-wrap-1760000000123-unknown-events.jsonl")
+			decisions := filepath.Join(dir, "cursor-wrap-1760000000123-unknown-decisions.jsonl")
+			if got := ls.FilePath(); got != decisions {
+				t.Errorf("FilePath = %q, want %q", got, decisions)
+			}
+			if got := ls.EventsFilePath(); got != events {
+				t.Errorf("EventsFilePath = %q, want %q", got, events)
+			}
+			if got := readKinds(t, events); len(got) != 2 || got[0] != [2]string{"raw_event", KindRawEvent} || got[1] != [2]string{"agent stderr", KindAgentStderr} {
+				t.Errorf("events file = %v", got)
+			}
+			if got := readKinds(t, decisions); len(got) != 2 || got[0] != [2]string{"hang detected", KindDecision} || got[1] != [2]string{"tick", KindVerdict} {
+				t.Errorf("decisions file = %v", got)
+			}
+			if parts := ls.Parts(); len(parts) != 2 || parts[0] != decisions || parts[1] != events {
+				t.Errorf("Parts = %v, want the decisions then the events file", parts)
+			}
+		})
+	}
+}
+
+func TestSetup_SplitLogsRenamesBoth(t *testing.T) {
+	dir := t.TempDir()
+	ls, teardown := Setup(LogConfig{
+		Dir:          dir,
+		ConsoleLevel: slog.LevelError,
+		FileLevel:    slog.LevelDebug,
+		Started:      time.UnixMilli(1760000000123),
+		SplitLogs:    true,
+	})
+	defer teardown()
+
+	ls.SetSessionID("first-id")
+	ls.AddSessionID("second-id")
+	ls.Debug("raw_event", Kind(KindRawEvent))
+	ls.Info("after rename")
+
+	events := filepath.Join(dir, "cursor-wrap-1760000000123-first-id+second-id-events.jsonl")
+	decisions := filepath.Join(dir, "cursor-wrap-1760000000123-first-id+second-id-decisions.jsonl")
+	if got := ls.FilePath(); got != decisions {
+		t.Errorf("FilePath = %q, want %q", got, decisions)
+	}
+	if got := ls.EventsFilePath(); got != events {
+		t.Errorf("EventsFilePath = %q, want %q", got, events)
+	}
+	if got := readKinds(t, events); len(got) != 1 || got[0][0] != "raw_event" {
+		t.Errorf("events file = %v", got)
+	}
+	if got := readKinds(t, decisions); len(got) != 1 || got[0][0] != "after rename" {
+		t.Errorf("decisions file = %v", got)
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "latest.jsonl")); err == nil && target != filepath.Base(decisions) {
+		t.Errorf("latest.jsonl -> %q, want %q", target, filepath.Base(decisions))
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), "unknown") {
+			t.Errorf("left behind %s", e.Name())
+		}
+	}
+}
+
+func TestSplitFileNames(t *testing.T) {
+	events, decisions := SplitFileNames(time.UnixMilli(1760000000123), "ci-7")
+	if events != "cursor-wrap-1760000000123-ci-7-unknown-events.jsonl" || decisions != "cursor-wrap-1760000000123-ci-7-unknown-decisions.jsonl" {
+		t.Errorf("SplitFileNames = %q, %q", events, decisions)
+	}
+}
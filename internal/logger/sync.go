@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// SyncMode says which records the file sink fsyncs. Writes always reach
+// the kernel before the log call returns, so a crash of the wrapper loses
+// nothing; syncing additionally guards against losing the page cache
+// (power loss, kernel panic) at the cost of a disk round trip.
+type SyncMode int
+
+const (
+	// SyncCritical fsyncs after raw events and records at info and
+	// above (turns, hangs, retries), but not after debug chatter like
+	// ticks and agent stderr.
+	SyncCritical SyncMode = iota
+	SyncAlways            // fsync after every record
+	SyncNever             // fsync only at rotation and teardown
+)
+
+func (m SyncMode) String() string {
+	switch m {
+	case SyncCritical:
+		return "critical"
+	case SyncAlways:
+		return "always"
+	case SyncNever:
+		return "never"
+	default:
+		return fmt.Sprintf("SyncMode(%d)", int(m))
+	}
+}
+
+// ParseSyncMode parses the names printed by SyncMode.String.
+func ParseSyncMode(s string) (SyncMode, error) {
+	switch s {
+	case "critical":
+		return SyncCritical, nil
+	case "always":
+		return SyncAlways, nil
+	case "never":
+		return SyncNever, nil
+	default:
+		return SyncCritical, fmt.Errorf("unsupported log sync mode %q: want always, critical or never", s)
+	}
+}
+
+// fsync is a variable so tests can observe when the file sink syncs.
+var fsync = (*os.File).Sync
+
+// syncHandler wraps the file sink's handler, syncing the file after the
+// records its mode asks for.
+type syncHandler struct {
+	slog.Handler
+	file *rotatingFile
+	mode SyncMode
+}
+
+func (h *syncHandler) Handle(ctx context.Context, r slog.Record) error {
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	if h.mode == SyncAlways || h.mode == SyncCritical && isCritical(r) {
+		// The record is in the page cache either way; a failed fsync
+		// must not keep it from the console.
+		_ = h.file.Sync()
+	}
+	return nil
+}
+
+func (h *syncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syncHandler{Handler: h.Handler.WithAttrs(attrs), file: h.file, mode: h.mode}
+}
+
+func (h *syncHandler) WithGroup(name string) slog.Handler {
+	return &syncHandler{Handler: h.Handler.WithGroup(name), file: h.file, mode: h.mode}
+}
+
+// isCritical reports whether SyncCritical syncs after r: raw events are
+// the forensic replay record, and info and above are the wrapper's
+// decisions.
+func isCritical(r slog.Record) bool {
+	return r.Level >= slog.LevelInfo || r.Message == "raw_event"
+}
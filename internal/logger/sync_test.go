@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+)
+
+// fakeFsync replaces fsync for the test, recording the file's size at
+// each call: a record is durable if it was written before the sync.
+func fakeFsync(t *testing.T) *[]int64 {
+	t.Helper()
+	var sizes []int64
+	orig := fsync
+	fsync = func(f *os.File) error {
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		sizes = append(sizes, fi.Size())
+		return nil
+	}
+	t.Cleanup(func() { fsync = orig })
+	return &sizes
+}
+
+func TestSync_Modes(t *testing.T) {
+	tests := []struct {
+		mode SyncMode
+		want []bool // synced after: raw_event, debug, info, error
+	}{
+		{SyncCritical, []bool{true, false, true, true}},
+		{SyncAlways, []bool{true, true, true, true}},
+		{SyncNever, []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			syncs := fakeFsync(t)
+			ls, teardown := Setup(LogConfig{Dir: t.TempDir(), ConsoleLevel: slog.LevelError + 1, FileLevel: slog.LevelDebug, Sync: tt.mode})
+
+			logs := []func(){
+				func() { ls.Debug("raw_event", "raw", "{}") },
+				func() { ls.Debug("tick") },
+				func() { ls.Info("turn complete") },
+				func() { ls.Error("hang detected") },
+			}
+			for i, log := range logs {
+				before := len(*syncs)
+				log()
+				synced := len(*syncs) > before
+				if synced != tt.want[i] {
+					t.Fatalf("record %d: synced = %v, want %v", i, synced, tt.want[i])
+				}
+				if synced {
+					fi, err := os.Stat(ls.FilePath())
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got := (*syncs)[len(*syncs)-1]; got != fi.Size() {
+						t.Errorf("record %d: synced at %d bytes, file has %d", i, got, fi.Size())
+					}
+				}
+			}
+
+			before := len(*syncs)
+			if err := teardown(); err != nil {
+				t.Fatal(err)
+			}
+			if len(*syncs) != before+1 {
+				t.Error("teardown did not sync")
+			}
+		})
+	}
+}
+
+func TestParseSyncMode(t *testing.T) {
+	for _, m := range []SyncMode{SyncCritical, SyncAlways, SyncNever} {
+		got, err := ParseSyncMode(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseSyncMode(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseSyncMode("fast"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+// BenchmarkFileSink compares the modes on the record mix of a busy turn:
+// mostly debug ticks and stderr lines between raw events. SyncAlways
+// matches the old O_SYNC file.
+func BenchmarkFileSink(b *testing.B) {
+	for _, mode := range []SyncMode{SyncAlways, SyncCritical, SyncNever} {
+		b.Run(mode.String(), func(b *testing.B) {
+			ls, teardown := Setup(LogConfig{Dir: b.TempDir(), ConsoleLevel: slog.LevelError + 1, FileLevel: slog.LevelDebug, Sync: mode})
+			defer teardown()
+			b.ResetTimer()
+			for i := range b.N {
+				if i%4 == 0 {
+					ls.Debug("raw_event", "raw", `{"type":"thinking","subtype":"delta"}`)
+				} else {
+					ls.Debug("agent stderr", "line", "warning: something chatty")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// syslogSockets are the local syslog sockets tried, in order, when
+// LogConfig.SyslogAddr is empty. On systemd hosts /dev/log is journald.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogWriteTimeout bounds each datagram write, so a wedged syslog
+// daemon with a full socket buffer cannot stall the wrapper.
+const syslogWriteTimeout = 50 * time.Millisecond
+
+// Syslog priorities (RFC 5424 severities in the user facility).
+const (
+	facilityUser = 1 << 3
+	severityErr  = 3
+	severityWarn = 4
+	severityInfo = 6
+)
+
+// syslogConn is a datagram connection to the local syslog daemon. After
+// the first failed write it is disabled for the rest of the session and
+// onError is called once; records are then simply not sent.
+type syslogConn struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	tag      string
+	disabled atomic.Bool
+	onError  func(error) // set by Setup once the logger exists
+}
+
+// dialSyslog connects to addr or, if addr is empty, the first of
+// syslogSockets that accepts a connection.
+func dialSyslog(addr string) (*syslogConn, error) {
+	addrs := syslogSockets
+	if addr != "" {
+		addrs = []string{addr}
+	}
+	var errs []error
+	for _, a := range addrs {
+		conn, err := net.Dial("unixgram", a)
+		if err == nil {
+			return &syslogConn{conn: conn, tag: fmt.Sprintf("cursor-wrap[%d]", os.Getpid())}, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("connecting to syslog: %w", errs[len(errs)-1])
+}
+
+func (c *syslogConn) send(severity int, msg []byte) {
+	if c.disabled.Load() {
+		return
+	}
+	// The daemon stamps the time; RFC 3164 senders on the local socket
+	// send just "<PRI>TAG: MSG".
+	line := fmt.Appendf(nil, "<%d>%s: %s", facilityUser|severity, c.tag, msg)
+	c.mu.Lock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout)) // unixgram supports deadlines
+	_, err := c.conn.Write(line)
+	c.mu.Unlock()
+	if err != nil && c.disabled.CompareAndSwap(false, true) && c.onError != nil {
+		c.onError(err)
+	}
+}
+
+func (c *syslogConn) Close() error {
+	return c.conn.Close()
+}
+
+// syslogHandler sends wrapper decision records (info and above) to
+// syslog as text. Raw events stay in the session log file.
+type syslogHandler struct {
+	conn *syslogConn
+	// with replays WithAttrs/WithGroup calls onto the per-record text
+	// handler, in order.
+	with []func(slog.Handler) slog.Handler
+}
+
+func (h *syslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo && !h.conn.disabled.Load()
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Message == "raw_event" {
+		return nil
+	}
+	var buf bytes.Buffer
+	var th slog.Handler = slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	for _, w := range h.with {
+		th = w(th)
+	}
+	if err := th.Handle(ctx, r); err != nil {
+		return err
+	}
+	h.conn.send(syslogSeverity(r.Level), bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return nil
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.withFunc(func(th slog.Handler) slog.Handler { return th.WithAttrs(attrs) })
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return h.withFunc(func(th slog.Handler) slog.Handler { return th.WithGroup(name) })
+}
+
+func (h *syslogHandler) withFunc(f func(slog.Handler) slog.Handler) *syslogHandler {
+	with := append(h.with[:len(h.with):len(h.with)], f)
+	return &syslogHandler{conn: h.conn, with: with}
+}
+
+// syslogSeverity maps a record level to a syslog severity. Hangs are
+// logged at error, so they arrive as err.
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return severityErr
+	case level >= slog.LevelWarn:
+		return severityWarn
+	default:
+		return severityInfo
+	}
+}
@@ -0,0 +1,138 @@
+package logger
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenSyslog starts a unix datagram socket standing in for /dev/log.
+// It lives under a short temp dir: socket paths are limited to ~100 bytes.
+func listenSyslog(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "sl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	addr := filepath.Join(dir, "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, addr
+}
+
+// readDatagrams returns the datagrams waiting on conn.
+func readDatagrams(t *testing.T, conn *net.UnixConn) []string {
+	t.Helper()
+	var msgs []string
+	buf := make([]byte, 64<<10)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return msgs
+		}
+		msgs = append(msgs, string(buf[:n]))
+	}
+}
+
+func TestSyslog_SendsDecisionRecords(t *testing.T) {
+	srv, addr := listenSyslog(t)
+	ls, teardown := Setup(LogConfig{
+		Dir:          t.TempDir(),
+		ConsoleLevel: slog.LevelError + 1,
+		FileLevel:    slog.LevelDebug,
+		Syslog:       true,
+		SyslogAddr:   addr,
+	})
+	defer teardown()
+
+	turn := ls.WithTurn(2)
+	turn.Debug("agent stderr", "line", "noise")
+	turn.Info("raw_event", "raw", "{}")
+	turn.Info("cursor-agent exited", "exit_code", 0)
+	turn.Warn("retrying turn")
+	turn.Error("hang detected", "idle_silence_ms", 61000)
+
+	msgs := readDatagrams(t, srv)
+	if len(msgs) != 3 {
+		t.Fatalf("got %d datagrams, want 3:\n%s", len(msgs), strings.Join(msgs, "\n"))
+	}
+	wants := []struct{ prefix, contains string }{
+		{"<14>cursor-wrap[", `msg="cursor-agent exited" turn=2 exit_code=0`},
+		{"<12>cursor-wrap[", `msg="retrying turn" turn=2`},
+		{"<11>cursor-wrap[", `msg="hang detected" turn=2 idle_silence_ms=61000`},
+	}
+	for i, w := range wants {
+		if !strings.HasPrefix(msgs[i], w.prefix) || !strings.Contains(msgs[i], w.contains) {
+			t.Errorf("datagram %d = %q, want prefix %q containing %q", i, msgs[i], w.prefix, w.contains)
+		}
+		if strings.Contains(msgs[i], "time=") {
+			t.Errorf("datagram %d carries a timestamp: %q", i, msgs[i])
+		}
+	}
+
+	// Raw events and debug records still reach the file.
+	data, err := os.ReadFile(ls.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"msg":"raw_event"`) || !strings.Contains(string(data), `"msg":"agent stderr"`) {
+		t.Errorf("file missing file-only records:\n%s", data)
+	}
+}
+
+func TestSyslog_UnavailableFallsBack(t *testing.T) {
+	ls, teardown := Setup(LogConfig{
+		Dir:          t.TempDir(),
+		ConsoleLevel: slog.LevelError + 1,
+		FileLevel:    slog.LevelDebug,
+		Syslog:       true,
+		SyslogAddr:   filepath.Join(t.TempDir(), "missing"),
+	})
+	defer teardown()
+	ls.Info("turn complete")
+
+	data, err := os.ReadFile(ls.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "syslog unavailable") || !strings.Contains(string(data), "turn complete") {
+		t.Errorf("want a warning and normal logging:\n%s", data)
+	}
+}
+
+func TestSyslog_WriteFailureWarnsOnce(t *testing.T) {
+	srv, addr := listenSyslog(t)
+	ls, teardown := Setup(LogConfig{
+		Dir:          t.TempDir(),
+		ConsoleLevel: slog.LevelError + 1,
+		FileLevel:    slog.LevelDebug,
+		Syslog:       true,
+		SyslogAddr:   addr,
+	})
+	defer teardown()
+
+	srv.Close() // the daemon goes away mid-session
+	for range 3 {
+		ls.Info("turn complete")
+	}
+
+	data, err := os.ReadFile(ls.FilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "writing to syslog failed"); n != 1 {
+		t.Errorf("got %d syslog failure warnings, want 1:\n%s", n, data)
+	}
+	if n := strings.Count(string(data), `"msg":"turn complete"`); n != 3 {
+		t.Errorf("got %d records in the file, want 3", n)
+	}
+}
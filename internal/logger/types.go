@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// The session log is read by tools as well as people, so its records
+// follow a versioned contract. Every file record carries:
+//
+//   - time, level, msg: as written by slog, time in Unix milliseconds
+//     (or RFC 3339, see LogConfig.TimeFormat)
+//   - schema_version: SchemaVersion
+//   - wrapper_version: the cursor-wrap build that wrote it
+//   - host, wrapper_pid, user: the machine, wrapper process and OS user
+//   - tag: LogConfig.Tag, if set
+//   - turn: the turn number, on records about a turn
+//   - kind: the record's family, one of the Kind* constants
+//
+// Removing or renaming a field of an existing kind, or changing what a
+// field means, bumps SchemaVersion; adding a field, a new kind or a new
+// record within a kind does not. Readers should ignore fields and kinds
+// they don't know.
+const SchemaVersion = 1
+
+// Record kinds.
+const (
+	// KindRawEvent records carry an agent event verbatim: seq, its place
+	// in the turn's output (from 1; with turn, a stable identity),
+	// recv_ts, in the format of time, lag_ms, recv_ts less the event's
+	// own timestamp_ms when it has one (negative under clock skew), and
+	// raw; for a wrapper/truncated_event standing in for an event over
+	// the size limit, truncated_head, the start of the original.
+	KindRawEvent = "raw_event"
+	// KindAgentStderr records carry a line the agent wrote to stderr.
+	KindAgentStderr = "agent_stderr"
+	// KindVerdict records trace the hang monitor's reasoning: waiting
+	// verdicts, overdue tools, debounced and confirmed hang verdicts, and
+	// the verdict history leading up to a hang.
+	KindVerdict = "verdict"
+	// KindTurnStats records describe a turn and its agent process: the
+	// prompt and arguments it was started with, the binary, its start,
+	// its exit status and timings, how its event queue kept up and how far
+	// behind the agent its events were read (event lag), the
+	// schema generation of its output (agent stream schema), and what its
+	// result event reported (agent result).
+	KindTurnStats = "turn_stats"
+	// KindConfig records describe how the wrapper was built, invoked and
+	// configured: the wrapper_start record.
+	KindConfig = "config"
+	// KindDecision records are everything the wrapper decided or noticed:
+	// hangs, retries, session changes, warnings and errors. Records
+	// logged without a kind get this one.
+	KindDecision = "decision"
+)
+
+// Kind returns the attribute that puts a record in a family; pass it
+// among the record's attributes. Records without one are KindDecision.
+func Kind(kind string) slog.Attr {
+	return slog.String("kind", kind)
+}
+
+// originAttrs are the fields saying where a record comes from, for logs
+// gathered from many machines into one place.
+func originAttrs(tag string) []slog.Attr {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	attrs := []slog.Attr{
+		slog.String("host", host),
+		slog.Int("wrapper_pid", os.Getpid()),
+		slog.String("user", userName()),
+	}
+	if tag != "" {
+		attrs = append(attrs, slog.String("tag", tag))
+	}
+	return attrs
+}
+
+// userName returns the name of the user the wrapper runs as.
+func userName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return strconv.Itoa(os.Getuid())
+}
+
+// kindHandler makes sure every file record has exactly one kind, placed
+// ahead of the record's own attributes.
+type kindHandler struct {
+	slog.Handler
+}
+
+func (h *kindHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	out.AddAttrs(Kind(recordKind(r)))
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != "kind" {
+			out.AddAttrs(a)
+		}
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+// recordKind returns the kind r was logged with, or KindDecision.
+func recordKind(r slog.Record) string {
+	kind := KindDecision
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "kind" {
+			kind = a.Value.String()
+			return false
+		}
+		return true
+	})
+	return kind
+}
+
+func (h *kindHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &kindHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *kindHandler) WithGroup(name string) slog.Handler {
+	return &kindHandler{h.Handler.WithGroup(name)}
+}
+
+// skipKindHandler leaves out the records of one kind.
+type skipKindHandler struct {
+	slog.Handler
+	kind string
+}
+
+func (h *skipKindHandler) Handle(ctx context.Context, r slog.Record) error {
+	if recordKind(r) == h.kind {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *skipKindHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &skipKindHandler{Handler: h.Handler.WithAttrs(attrs), kind: h.kind}
+}
+
+func (h *skipKindHandler) WithGroup(name string) slog.Handler {
+	return &skipKindHandler{Handler: h.Handler.WithGroup(name), kind: h.kind}
+}
+
+// dropKind keeps the kind attribute off the console, which is for people.
+func dropKind(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == "kind" {
+		return slog.Attr{}
+	}
+	return a
+}
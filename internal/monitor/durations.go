@@ -0,0 +1,56 @@
+package monitor
+
+import "time"
+
+// maxTrackedCommands bounds the number of distinct commands a
+// CommandDurations remembers. The oldest command is forgotten first.
+const maxTrackedCommands = 100
+
+// CommandDurations remembers how long each shell command took to complete
+// within a session. Agents re-run the same commands (e.g. `go test ./...`)
+// many times, so the observed duration is a far better deadline basis than
+// the timeout the agent declares. It is not safe for concurrent use; like
+// the Monitor it is driven from the single event loop.
+type CommandDurations struct {
+	factor float64
+	max    map[string]time.Duration // longest observed run per command
+	order  []string                 // insertion order, for eviction
+}
+
+// NewCommandDurations creates an empty history. A command seen before gets
+// a deadline of factor × its longest observed run (but never less than the
+// tool grace period).
+func NewCommandDurations(factor float64) *CommandDurations {
+	return &CommandDurations{
+		factor: factor,
+		max:    make(map[string]time.Duration),
+	}
+}
+
+// Observe records a completed run of command.
+func (d *CommandDurations) Observe(command string, elapsed time.Duration) {
+	if command == "" {
+		return
+	}
+	prev, ok := d.max[command]
+	if !ok {
+		if len(d.order) == maxTrackedCommands {
+			delete(d.max, d.order[0])
+			d.order = d.order[1:]
+		}
+		d.order = append(d.order, command)
+	}
+	if elapsed > prev {
+		d.max[command] = elapsed
+	}
+}
+
+// Estimate returns the estimated deadline for command, or false if the
+// command has not completed before in this session.
+func (d *CommandDurations) Estimate(command string, grace time.Duration) (time.Duration, bool) {
+	longest, ok := d.max[command]
+	if !ok {
+		return 0, false
+	}
+	return max(grace, time.Duration(d.factor*float64(longest))), true
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCommandDurationsEstimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		observed []time.Duration
+		want     time.Duration
+		wantOK   bool
+	}{
+		{name: "unseen", wantOK: false},
+		{name: "single run", observed: []time.Duration{10 * time.Second}, want: 30 * time.Second, wantOK: true},
+		{name: "longest run wins", observed: []time.Duration{10 * time.Second, 20 * time.Second, 5 * time.Second}, want: 60 * time.Second, wantOK: true},
+		{name: "grace floor", observed: []time.Duration{time.Second}, want: 5 * time.Second, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewCommandDurations(3)
+			for _, e := range tt.observed {
+				d.Observe("go test ./...", e)
+			}
+			got, ok := d.Estimate("go test ./...", 5*time.Second)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Estimate = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCommandDurationsIgnoresEmptyCommand(t *testing.T) {
+	d := NewCommandDurations(3)
+	d.Observe("", time.Second)
+	if len(d.max) != 0 {
+		t.Fatalf("expected empty command to be ignored, got %v", d.max)
+	}
+}
+
+func TestCommandDurationsBounded(t *testing.T) {
+	d := NewCommandDurations(3)
+	for i := 0; i < maxTrackedCommands+1; i++ {
+		d.Observe(fmt.Sprintf("cmd-%d", i), time.Second)
+	}
+	if len(d.max) != maxTrackedCommands {
+		t.Fatalf("tracked %d commands, want %d", len(d.max), maxTrackedCommands)
+	}
+	if _, ok := d.Estimate("cmd-0", 0); ok {
+		t.Error("expected oldest command to be evicted")
+	}
+	if _, ok := d.Estimate(fmt.Sprintf("cmd-%d", maxTrackedCommands), 0); !ok {
+		t.Error("expected newest command to be retained")
+	}
+}
@@ -1,8 +1,9 @@
 package monitor
 
 import (
-	"encoding/json"
+	"cmp"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -38,6 +39,16 @@ type OpenToolCall struct {
 	StartedAt   time.Time
 	TimeoutMS   int64  // from tool args; 0 if unknown
 	Command     string // shell command, empty for non-shell tools
+	// EstimatedDeadline replaces the declared deadline when a previous
+	// run of the same command supports a tighter one. Zero if unused.
+	EstimatedDeadline time.Duration
+	// OverdueReported is set once TakeOverdue has returned this call.
+	OverdueReported bool
+	// LastOutputAt is when the tool last streamed output (a tool_call
+	// update event); zero if it hasn't. Output slides the call's
+	// deadline: it doesn't expire within an idle timeout of its last
+	// output.
+	LastOutputAt time.Time
 }
 
 // OpenCallDetail is a snapshot of an open tool call for diagnostic output.
@@ -46,30 +57,94 @@ type OpenCallDetail struct {
 	Command   string
 	ElapsedMS int64
 	TimeoutMS int64
+	// EstimatedDeadlineMS is non-zero when the call's deadline was derived
+	// from earlier runs of the same command rather than TimeoutMS.
+	EstimatedDeadlineMS int64
+	// DeadlineMS is how long the call may run before it counts as
+	// expired: the estimate, else TimeoutMS plus the tool grace, else the
+	// idle timeout.
+	DeadlineMS int64
 }
 
 // Reason provides diagnostic context for a verdict.
 type Reason struct {
+	CheckedAt     time.Time // when CheckTimeout computed the figures below
 	IdleSilenceMS int64
 	OpenCallCount int
 	LastEventType string
-	OpenCalls     []OpenCallDetail
+	OpenCalls     []OpenCallDetail // longest overrun first
+	// TotalOpenElapsedMS sums ElapsedMS across OpenCalls.
+	TotalOpenElapsedMS int64
+	// ThinkingMS is non-zero when the verdict was caused by a thinking
+	// phase outlasting the configured cap; it is the phase's length so far.
+	ThinkingMS int64
+	// StderrTail holds the agent's most recent stderr lines. The monitor
+	// never sees stderr; the orchestrator fills this in before reporting.
+	StderrTail []string
 }
 
 // String formats a one-line human-readable summary.
 func (r Reason) String() string {
 	var b strings.Builder
 	fmt.Fprintf(&b, "idle %dms, %d open calls, last event: %s", r.IdleSilenceMS, r.OpenCallCount, r.LastEventType)
+	if r.ThinkingMS > 0 {
+		fmt.Fprintf(&b, ", thinking for %dms", r.ThinkingMS)
+	}
+	if r.TotalOpenElapsedMS > 0 {
+		fmt.Fprintf(&b, ", %dms open in total", r.TotalOpenElapsedMS)
+	}
 	for _, oc := range r.OpenCalls {
 		cmd := oc.Command
 		if cmd == "" {
 			cmd = "(non-shell)"
 		}
+		if oc.EstimatedDeadlineMS > 0 {
+			fmt.Fprintf(&b, " [%s %s elapsed=%dms timeout=%dms estimated_deadline=%dms]", oc.CallID, cmd, oc.ElapsedMS, oc.TimeoutMS, oc.EstimatedDeadlineMS)
+			continue
+		}
 		fmt.Fprintf(&b, " [%s %s elapsed=%dms timeout=%dms]", oc.CallID, cmd, oc.ElapsedMS, oc.TimeoutMS)
 	}
 	return b.String()
 }
 
+// AsOf returns a copy of r with its elapsed figures advanced from CheckedAt
+// to now. The hang report is printed after the agent has been killed and
+// reaped, so this keeps the numbers in step with the kill time. A zero now
+// or CheckedAt, or a now before CheckedAt, returns r unchanged.
+func (r Reason) AsOf(now time.Time) Reason {
+	if now.IsZero() || r.CheckedAt.IsZero() || !now.After(r.CheckedAt) {
+		return r
+	}
+	delta := now.Sub(r.CheckedAt).Milliseconds()
+	r.CheckedAt = now
+	r.IdleSilenceMS += delta
+	if r.ThinkingMS > 0 {
+		r.ThinkingMS += delta
+	}
+	calls := make([]OpenCallDetail, len(r.OpenCalls))
+	for i, c := range r.OpenCalls {
+		c.ElapsedMS += delta
+		calls[i] = c
+	}
+	if len(calls) > 0 {
+		r.OpenCalls = calls
+		r.TotalOpenElapsedMS += delta * int64(len(calls))
+	}
+	return r
+}
+
+// historySize bounds the number of CheckTimeout evaluations retained.
+// At the default 5s tick this covers the last ~4 minutes before a hang.
+const historySize = 50
+
+// TickSnapshot records a single CheckTimeout evaluation.
+type TickSnapshot struct {
+	At        time.Time
+	Verdict   Verdict
+	IdleMS    int64
+	OpenCalls []OpenCallDetail
+}
+
 // Clock abstracts time for testing.
 type Clock interface {
 	Now() time.Time
@@ -90,6 +165,33 @@ func WithClock(c Clock) Option {
 	}
 }
 
+// WithCommandDurations enables per-command deadline estimates. The history
+// is owned by the caller so it can outlive a single turn of the session.
+func WithCommandDurations(d *CommandDurations) Option {
+	return func(m *Monitor) {
+		m.durations = d
+	}
+}
+
+// WithMaxThinking caps how long a single thinking phase may run. Thinking
+// deltas reset the idle timer, so without a cap an agent that thinks
+// forever is never reported. Zero disables the cap.
+func WithMaxThinking(d time.Duration) Option {
+	return func(m *Monitor) {
+		m.maxThinking = d
+	}
+}
+
+// WithNonJSONLiveness makes the lines the agent writes to stdout that
+// aren't JSON (wrapper_nonjson events) count as signs of life. By default
+// they don't: they are notices like "T: Named models unavailable", and
+// often the agent's last words before a hang.
+func WithNonJSONLiveness(on bool) Option {
+	return func(m *Monitor) {
+		m.nonJSONLiveness = on
+	}
+}
+
 // State is the hang monitor's internal state.
 type State struct {
 	OpenCalls   map[string]*OpenToolCall // keyed by call_id
@@ -97,6 +199,9 @@ type State struct {
 	LastEvType  string                   // "type" or "type/subtype"
 	SessionDone bool                     // true after result event
 	SessionID   string                   // from system/init
+	// ThinkingSince is when the current thinking phase's first delta
+	// arrived; zero when no phase is in progress.
+	ThinkingSince time.Time
 }
 
 // Monitor is the hang detection state machine. It consumes annotated events,
@@ -105,7 +210,15 @@ type Monitor struct {
 	clock       Clock
 	idleTimeout time.Duration
 	toolGrace   time.Duration
-	state       State
+	maxThinking time.Duration // 0 disables the thinking-phase cap
+	thinking    events.ThinkingAccumulator
+	// nonJSONLiveness lets wrapper_nonjson events reset the idle timer.
+	nonJSONLiveness bool
+	state           State
+	durations       *CommandDurations // nil unless estimates are enabled
+	history         []TickSnapshot    // ring buffer, oldest first once full
+	historyNext     int               // index of the next slot to overwrite when full
+	pausedAt        time.Time         // non-zero while paused
 }
 
 // NewMonitor creates a Monitor with the given thresholds.
@@ -114,6 +227,9 @@ func NewMonitor(idleTimeout, toolGrace time.Duration, opts ...Option) *Monitor {
 		clock:       realClock{},
 		idleTimeout: idleTimeout,
 		toolGrace:   toolGrace,
+		// Only a phase's start matters here; its text would grow with
+		// every delta of a long reasoning run.
+		thinking: events.ThinkingAccumulator{TimingOnly: true},
 		state: State{
 			OpenCalls: make(map[string]*OpenToolCall),
 		},
@@ -129,6 +245,9 @@ func NewMonitor(idleTimeout, toolGrace time.Duration, opts ...Option) *Monitor {
 // Returns VerdictOK or VerdictWaiting. Never returns VerdictHang
 // synchronously — hangs are detected by CheckTimeout.
 func (m *Monitor) ProcessEvent(ev events.AnnotatedEvent) Verdict {
+	if ev.Parsed.Type == events.TypeWrapperNonJSON && !m.nonJSONLiveness {
+		return m.openCallsVerdict()
+	}
 	m.state.LastEventAt = ev.RecvTime
 
 	evType := ev.Parsed.Type
@@ -137,42 +256,73 @@ func (m *Monitor) ProcessEvent(ev events.AnnotatedEvent) Verdict {
 	}
 	m.state.LastEvType = evType
 
-	switch ev.Parsed.Type {
-	case "system":
-		if ev.Parsed.Subtype == "init" {
-			var init events.SystemInit
-			if err := json.Unmarshal(ev.Raw, &init); err == nil {
-				m.state.SessionID = init.SessionID
-			}
+	// A thinking phase runs from its first delta to thinking/completed.
+	// Any other event also ends it: the agent has moved on, and a later
+	// phase must not inherit this one's start time.
+	m.thinking.Add(ev)
+	m.state.ThinkingSince = m.thinking.Current().Start
+
+	if ev.Parsed.Type == "result" {
+		m.state.SessionDone = true
+	}
+	// An event that can't be decoded decodes to nil: only its arrival
+	// counts.
+	payload, _ := events.Decode(ev)
+	switch p := payload.(type) {
+	case events.SystemInit:
+		m.state.SessionID = p.SessionID
+	case events.ToolCallStarted:
+		oc := &OpenToolCall{
+			CallID:      p.CallID,
+			ModelCallID: p.ModelCallID,
+			StartedAt:   ev.RecvTime,
 		}
-	case "tool_call":
-		switch ev.Parsed.Subtype {
-		case "started":
-			var started events.ToolCallStarted
-			if err := json.Unmarshal(ev.Raw, &started); err == nil {
-				oc := &OpenToolCall{
-					CallID:      started.CallID,
-					ModelCallID: started.ModelCallID,
-					StartedAt:   ev.RecvTime,
-				}
-				// Try to extract shell tool args for timeout and command.
-				info, err := events.ParseToolCallInfo(started.ToolCall)
-				if err == nil && info.ToolType == "shellToolCall" {
-					oc.TimeoutMS = info.TimeoutMS
-					oc.Command = info.Command
-				}
-				m.state.OpenCalls[started.CallID] = oc
-			}
-		case "completed":
-			var completed events.ToolCallCompleted
-			if err := json.Unmarshal(ev.Raw, &completed); err == nil {
-				delete(m.state.OpenCalls, completed.CallID)
-			}
+		// Try to extract shell tool args for timeout and command.
+		info, err := events.ParseToolCallInfo(p.ToolCall)
+		if err == nil && info.ToolType == "shellToolCall" {
+			oc.TimeoutMS = info.TimeoutMS
+			oc.Command = info.Command
+			oc.EstimatedDeadline = m.estimateDeadline(oc)
 		}
-	case "result":
-		m.state.SessionDone = true
+		m.state.OpenCalls[p.CallID] = oc
+	case events.ToolCallCompleted:
+		m.completeCall(p.CallID, ev.RecvTime)
+	case events.ToolCallUpdate:
+		if oc, ok := m.state.OpenCalls[p.CallID]; ok {
+			oc.LastOutputAt = ev.RecvTime
+		}
+	case events.TruncatedEvent:
+		// An event too big to read whole: what its start says still
+		// counts, so a call doesn't stay open after its huge result.
+		switch {
+		case p.OriginalType == "result":
+			m.state.SessionDone = true
+		case p.OriginalType == "tool_call" && p.OriginalSubtype == "started" && p.CallID != "":
+			m.state.OpenCalls[p.CallID] = &OpenToolCall{CallID: p.CallID, StartedAt: ev.RecvTime}
+		case p.OriginalType == "tool_call" && p.OriginalSubtype == "completed":
+			m.completeCall(p.CallID, ev.RecvTime)
+		}
+	}
+	if m.state.SessionID == "" {
+		// The init event was missed (a resumed stream, a dropped line):
+		// learn the id from the first event that carries one.
+		m.state.SessionID = events.ExtractSessionID(ev.Raw)
 	}
 
+	return m.openCallsVerdict()
+}
+
+// completeCall closes the open call with the given id, completed at at.
+func (m *Monitor) completeCall(callID string, at time.Time) {
+	if oc, ok := m.state.OpenCalls[callID]; ok && m.durations != nil {
+		m.durations.Observe(oc.Command, at.Sub(oc.StartedAt))
+	}
+	delete(m.state.OpenCalls, callID)
+}
+
+// openCallsVerdict is ProcessEvent's verdict: waiting on open tool calls,
+// or OK.
+func (m *Monitor) openCallsVerdict() Verdict {
 	if len(m.state.OpenCalls) > 0 {
 		return VerdictWaiting
 	}
@@ -180,12 +330,29 @@ func (m *Monitor) ProcessEvent(ev events.AnnotatedEvent) Verdict {
 }
 
 // CheckTimeout evaluates the current state and returns a verdict with reason.
-// Called periodically by the orchestrator on a timer tick.
+// Called periodically by the orchestrator on a timer tick. Every evaluation
+// is recorded in the bounded history for post-mortems.
 func (m *Monitor) CheckTimeout(now time.Time) (Verdict, Reason) {
+	now = m.frozen(now)
+	v, r := m.evaluate(now)
+	m.record(now, v, r)
+	return v, r
+}
+
+// Snapshot evaluates the current state as CheckTimeout does, without
+// recording it in the history: what the monitor made of the agent when
+// something other than a verdict, such as a turn timeout, ends the turn.
+func (m *Monitor) Snapshot(now time.Time) (Verdict, Reason) {
+	return m.evaluate(m.frozen(now))
+}
+
+// evaluate computes the verdict without side effects.
+func (m *Monitor) evaluate(now time.Time) (Verdict, Reason) {
 	idleElapsed := now.Sub(m.state.LastEventAt)
 	idleMS := idleElapsed.Milliseconds()
 
 	reason := Reason{
+		CheckedAt:     now,
 		IdleSilenceMS: idleMS,
 		OpenCallCount: len(m.state.OpenCalls),
 		LastEventType: m.state.LastEvType,
@@ -195,6 +362,13 @@ func (m *Monitor) CheckTimeout(now time.Time) (Verdict, Reason) {
 		return VerdictOK, reason
 	}
 
+	if m.maxThinking > 0 && !m.state.ThinkingSince.IsZero() {
+		if thinking := now.Sub(m.state.ThinkingSince); thinking > m.maxThinking {
+			reason.ThinkingMS = thinking.Milliseconds()
+			return VerdictHang, reason
+		}
+	}
+
 	if len(m.state.OpenCalls) == 0 {
 		if idleElapsed > m.idleTimeout {
 			return VerdictHang, reason
@@ -204,24 +378,24 @@ func (m *Monitor) CheckTimeout(now time.Time) (Verdict, Reason) {
 
 	// Tools running — check each against its own deadline.
 	allExpired := true
+	overdue := make(map[string]time.Duration, len(m.state.OpenCalls))
 	for _, tool := range m.state.OpenCalls {
 		toolElapsed := now.Sub(tool.StartedAt)
-		toolDeadline := time.Duration(tool.TimeoutMS)*time.Millisecond + m.toolGrace
-		if tool.TimeoutMS == 0 {
-			toolDeadline = m.idleTimeout
-		}
-		detail := OpenCallDetail{
-			CallID:    tool.CallID,
-			Command:   tool.Command,
-			ElapsedMS: toolElapsed.Milliseconds(),
-			TimeoutMS: tool.TimeoutMS,
-		}
-		reason.OpenCalls = append(reason.OpenCalls, detail)
+		reason.OpenCalls = append(reason.OpenCalls, m.openCallDetail(tool, toolElapsed))
+		reason.TotalOpenElapsedMS += toolElapsed.Milliseconds()
+		overdue[tool.CallID] = m.overrun(tool, now)
 
-		if toolElapsed <= toolDeadline {
+		if overdue[tool.CallID] <= 0 {
 			allExpired = false
 		}
 	}
+	// Longest overrun first: that call is usually the one that is stuck.
+	slices.SortFunc(reason.OpenCalls, func(a, b OpenCallDetail) int {
+		if c := cmp.Compare(overdue[b.CallID], overdue[a.CallID]); c != 0 {
+			return c
+		}
+		return strings.Compare(a.CallID, b.CallID)
+	})
 
 	if allExpired {
 		return VerdictHang, reason
@@ -229,6 +403,148 @@ func (m *Monitor) CheckTimeout(now time.Time) (Verdict, Reason) {
 	return VerdictWaiting, reason
 }
 
+// TakeOverdue returns the open calls that have run past their own deadline
+// and have not been returned before, ordered by call ID. Unlike
+// CheckTimeout's verdict, which waits for every call to expire, this
+// surfaces the first stuck call as soon as it is late.
+func (m *Monitor) TakeOverdue(now time.Time) []OpenCallDetail {
+	now = m.frozen(now)
+	var out []OpenCallDetail
+	for _, tool := range m.state.OpenCalls {
+		if tool.OverdueReported {
+			continue
+		}
+		if m.overrun(tool, now) <= 0 {
+			continue
+		}
+		tool.OverdueReported = true
+		out = append(out, m.openCallDetail(tool, now.Sub(tool.StartedAt)))
+	}
+	slices.SortFunc(out, func(a, b OpenCallDetail) int { return strings.Compare(a.CallID, b.CallID) })
+	return out
+}
+
+func (m *Monitor) openCallDetail(tool *OpenToolCall, elapsed time.Duration) OpenCallDetail {
+	return OpenCallDetail{
+		CallID:              tool.CallID,
+		Command:             tool.Command,
+		ElapsedMS:           elapsed.Milliseconds(),
+		TimeoutMS:           tool.TimeoutMS,
+		EstimatedDeadlineMS: tool.EstimatedDeadline.Milliseconds(),
+		DeadlineMS:          m.deadline(tool).Milliseconds(),
+	}
+}
+
+// overrun is how far past its deadline tool is at now; zero or less if
+// it hasn't expired. Output the tool streams slides the deadline to an
+// idle timeout after the last of it.
+func (m *Monitor) overrun(tool *OpenToolCall, now time.Time) time.Duration {
+	over := now.Sub(tool.StartedAt) - m.deadline(tool)
+	if !tool.LastOutputAt.IsZero() {
+		over = min(over, now.Sub(tool.LastOutputAt)-m.idleTimeout)
+	}
+	return over
+}
+
+// deadline is the time a tool call may run before it counts as expired.
+func (m *Monitor) deadline(tool *OpenToolCall) time.Duration {
+	if tool.EstimatedDeadline > 0 {
+		return tool.EstimatedDeadline
+	}
+	return m.declaredDeadline(tool)
+}
+
+// declaredDeadline is the deadline implied by the tool's own timeout.
+func (m *Monitor) declaredDeadline(tool *OpenToolCall) time.Duration {
+	if tool.TimeoutMS == 0 {
+		return m.idleTimeout
+	}
+	return time.Duration(tool.TimeoutMS)*time.Millisecond + m.toolGrace
+}
+
+// estimateDeadline returns a deadline derived from earlier runs of the same
+// command, or zero if there are none or the estimate would not tighten the
+// declared deadline.
+func (m *Monitor) estimateDeadline(tool *OpenToolCall) time.Duration {
+	if m.durations == nil || tool.Command == "" {
+		return 0
+	}
+	est, ok := m.durations.Estimate(tool.Command, m.toolGrace)
+	if !ok || est >= m.declaredDeadline(tool) {
+		return 0
+	}
+	return est
+}
+
+// Pause freezes the monitor's clock while the agent is suspended: until
+// Resume, CheckTimeout and TakeOverdue evaluate as of now. Pausing a
+// paused monitor does nothing.
+func (m *Monitor) Pause(now time.Time) {
+	if m.pausedAt.IsZero() {
+		m.pausedAt = now
+	}
+}
+
+// Resume unfreezes the clock and moves the last event time, the thinking
+// phase start and every open call's start forward by the time spent
+// paused, so a suspension counts as neither silence nor tool run time.
+// It returns the time spent paused, zero if the monitor wasn't paused.
+//
+// Nothing is moved past now: an event processed after the agent was
+// resumed but before Resume was called is already later than the pause,
+// and the whole shift would put it in the future.
+func (m *Monitor) Resume(now time.Time) time.Duration {
+	if m.pausedAt.IsZero() {
+		return 0
+	}
+	d := now.Sub(m.pausedAt)
+	m.pausedAt = time.Time{}
+	if d <= 0 {
+		return 0
+	}
+	shift := func(t time.Time) time.Time {
+		if s := t.Add(d); s.Before(now) {
+			return s
+		}
+		return now
+	}
+	m.state.LastEventAt = shift(m.state.LastEventAt)
+	if m.thinking.Active() {
+		m.thinking.Shift(shift(m.state.ThinkingSince).Sub(m.state.ThinkingSince))
+	}
+	m.state.ThinkingSince = m.thinking.Current().Start
+	for _, tool := range m.state.OpenCalls {
+		tool.StartedAt = shift(tool.StartedAt)
+		if !tool.LastOutputAt.IsZero() {
+			tool.LastOutputAt = shift(tool.LastOutputAt)
+		}
+	}
+	return d
+}
+
+// frozen returns the time to evaluate at: now, or the pause time while
+// paused.
+func (m *Monitor) frozen(now time.Time) time.Time {
+	if !m.pausedAt.IsZero() {
+		return m.pausedAt
+	}
+	return now
+}
+
+// SetIdleTimeout changes the idle timeout from the next check on, as a
+// configuration reload does. Open calls without a declared timeout take
+// it up too.
+func (m *Monitor) SetIdleTimeout(d time.Duration) {
+	m.idleTimeout = d
+}
+
+// SetToolGrace changes the grace added to a tool's declared timeout, for
+// the calls already open as well as later ones. An estimated deadline
+// keeps the grace it was worked out with when its call started.
+func (m *Monitor) SetToolGrace(d time.Duration) {
+	m.toolGrace = d
+}
+
 // Now returns the current time from the monitor's clock.
 func (m *Monitor) Now() time.Time {
 	return m.clock.Now()
@@ -243,3 +559,36 @@ func (m *Monitor) SessionDone() bool {
 func (m *Monitor) SessionID() string {
 	return m.state.SessionID
 }
+
+// OpenCallIDs returns the call_ids of the tool calls still open, sorted.
+func (m *Monitor) OpenCallIDs() []string {
+	ids := make([]string, 0, len(m.state.OpenCalls))
+	for id := range m.state.OpenCalls {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// History returns the retained CheckTimeout evaluations, oldest first.
+func (m *Monitor) History() []TickSnapshot {
+	out := make([]TickSnapshot, 0, len(m.history))
+	out = append(out, m.history[m.historyNext:]...)
+	out = append(out, m.history[:m.historyNext]...)
+	return out
+}
+
+func (m *Monitor) record(now time.Time, v Verdict, r Reason) {
+	snap := TickSnapshot{
+		At:        now,
+		Verdict:   v,
+		IdleMS:    r.IdleSilenceMS,
+		OpenCalls: r.OpenCalls,
+	}
+	if len(m.history) < historySize {
+		m.history = append(m.history, snap)
+		return
+	}
+	m.history[m.historyNext] = snap
+	m.historyNext = (m.historyNext + 1) % historySize
+}
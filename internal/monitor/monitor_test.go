@@ -3,6 +3,7 @@ package monitor
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -58,6 +59,19 @@ func thinkingCompletedEvent(recvTime time.Time) events.AnnotatedEvent {
 	}
 }
 
+func thinkingDeltaEvent(recvTime time.Time) events.AnnotatedEvent {
+	raw, _ := json.Marshal(map[string]string{
+		"type":    "thinking",
+		"subtype": "delta",
+		"text":    "hmm",
+	})
+	return events.AnnotatedEvent{
+		RecvTime: recvTime,
+		Raw:      raw,
+		Parsed:   events.RawEvent{Type: "thinking", Subtype: "delta"},
+	}
+}
+
 func assistantEvent(recvTime time.Time) events.AnnotatedEvent {
 	raw, _ := json.Marshal(map[string]any{
 		"type": "assistant",
@@ -147,6 +161,22 @@ func toolCallCompletedEvent(recvTime time.Time, callID string) events.AnnotatedE
 	}
 }
 
+func toolCallUpdateEvent(recvTime time.Time, callID, stdout string) events.AnnotatedEvent {
+	raw, _ := json.Marshal(map[string]any{
+		"type":          "tool_call",
+		"subtype":       "update",
+		"call_id":       callID,
+		"model_call_id": "mc-1",
+		"timestamp_ms":  recvTime.UnixMilli(),
+		"stdout":        stdout,
+	})
+	return events.AnnotatedEvent{
+		RecvTime: recvTime,
+		Raw:      raw,
+		Parsed:   events.RawEvent{Type: "tool_call", Subtype: "update"},
+	}
+}
+
 func resultEvent(recvTime time.Time) events.AnnotatedEvent {
 	raw, _ := json.Marshal(map[string]any{
 		"type":        "result",
@@ -240,6 +270,22 @@ func TestParallelToolCalls(t *testing.T) {
 	}
 }
 
+func TestOpenCallIDs(t *testing.T) {
+	m := newTestMonitor(newFakeClock(t0))
+	if ids := m.OpenCallIDs(); len(ids) != 0 {
+		t.Fatalf("OpenCallIDs = %v before any tool call", ids)
+	}
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-b", 10000))
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-a", 10000))
+	if ids := m.OpenCallIDs(); !slices.Equal(ids, []string{"call-a", "call-b"}) {
+		t.Errorf("OpenCallIDs = %v, want [call-a call-b]", ids)
+	}
+	m.ProcessEvent(toolCallCompletedEvent(t0, "call-a"))
+	if ids := m.OpenCallIDs(); !slices.Equal(ids, []string{"call-b"}) {
+		t.Errorf("OpenCallIDs = %v, want [call-b]", ids)
+	}
+}
+
 func TestIdleHang(t *testing.T) {
 	// thinking/completed → long silence with no open tools → VerdictHang
 	clk := newFakeClock(t0)
@@ -294,6 +340,60 @@ func TestToolTimeoutHang(t *testing.T) {
 	}
 }
 
+func TestToolOutputSlidesDeadline(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+
+	// Deadline 10s + 30s grace = 40s, but the tool keeps writing output.
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+	for _, at := range []time.Duration{20 * time.Second, 50 * time.Second, 90 * time.Second} {
+		if v := m.ProcessEvent(toolCallUpdateEvent(t0.Add(at), "call-1", "building...\n")); v != VerdictWaiting {
+			t.Fatalf("update at %v: verdict %v, want Waiting", at, v)
+		}
+	}
+	clk.Advance(140 * time.Second)
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictWaiting {
+		t.Fatalf("tool wrote output 50s ago, well past its deadline: got %v, want Waiting", v)
+	}
+	if overdue := m.TakeOverdue(clk.Now()); len(overdue) != 0 {
+		t.Fatalf("TakeOverdue = %+v while the tool writes output", overdue)
+	}
+
+	// Silent for longer than the idle timeout since its last output.
+	clk.Advance(11 * time.Second)
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictHang {
+		t.Fatalf("61s after the tool's last output: got %v, want Hang", v)
+	}
+	if overdue := m.TakeOverdue(clk.Now()); len(overdue) != 1 || overdue[0].ElapsedMS != 151000 {
+		t.Fatalf("TakeOverdue = %+v, want call-1 151s in", overdue)
+	}
+}
+
+func TestToolOutputSlidesOnlyItsOwnCall(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+
+	// Interleaved parallel calls; only call-b writes output.
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-a", 10000))
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-b", 10000))
+	m.ProcessEvent(toolCallUpdateEvent(t0.Add(30*time.Second), "call-b", "a"))
+	m.ProcessEvent(toolCallUpdateEvent(t0.Add(35*time.Second), "call-unknown", "b"))
+	m.ProcessEvent(toolCallUpdateEvent(t0.Add(39*time.Second), "call-b", "c"))
+
+	clk.Advance(45 * time.Second)
+	overdue := m.TakeOverdue(clk.Now())
+	if len(overdue) != 1 || overdue[0].CallID != "call-a" {
+		t.Fatalf("TakeOverdue = %+v, want only call-a", overdue)
+	}
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictWaiting {
+		t.Fatalf("got %v, want Waiting on call-b", v)
+	}
+	clk.Advance(55 * time.Second) // 61s after call-b's last output
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictHang {
+		t.Fatalf("got %v, want Hang", v)
+	}
+}
+
 func TestPartialExpiry(t *testing.T) {
 	// tool A expired, tool B still within deadline → VerdictWaiting
 	clk := newFakeClock(t0)
@@ -390,6 +490,70 @@ func TestUnmatchedToolCallCompleted(t *testing.T) {
 	}
 }
 
+func truncatedEvent(recvTime time.Time, origType, origSubtype, callID string) events.AnnotatedEvent {
+	raw, _ := json.Marshal(events.TruncatedEvent{
+		Type:            events.TypeWrapper,
+		Subtype:         events.SubtypeTruncatedEvent,
+		OriginalType:    origType,
+		OriginalSubtype: origSubtype,
+		CallID:          callID,
+		OriginalBytes:   50 << 20,
+	})
+	return events.AnnotatedEvent{
+		RecvTime: recvTime,
+		Raw:      raw,
+		Parsed:   events.RawEvent{Type: events.TypeWrapper, Subtype: events.SubtypeTruncatedEvent},
+	}
+}
+
+func TestTruncatedToolCallCompletedClosesCall(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-2", 10000))
+
+	// call-1's 50 MB result is cut short: the call still completes, and
+	// only it.
+	clk.Advance(5 * time.Second)
+	if v := m.ProcessEvent(truncatedEvent(clk.Now(), "tool_call", "completed", "call-1")); v != VerdictWaiting {
+		t.Fatalf("ProcessEvent = %v, want VerdictWaiting on call-2", v)
+	}
+	if got := m.OpenCallIDs(); !slices.Equal(got, []string{"call-2"}) {
+		t.Fatalf("open calls = %v, want [call-2]", got)
+	}
+	if m.state.LastEventAt != clk.Now() {
+		t.Error("truncated event didn't count as activity")
+	}
+
+	m.ProcessEvent(toolCallCompletedEvent(clk.Now(), "call-2"))
+	clk.Advance(time.Second)
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictOK {
+		t.Fatalf("after both calls completed: %v, want VerdictOK", v)
+	}
+}
+
+func TestTruncatedEvents(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+
+	// A started event cut short opens its call, with no known timeout.
+	m.ProcessEvent(truncatedEvent(t0, "tool_call", "started", "call-9"))
+	if got := m.OpenCallIDs(); !slices.Equal(got, []string{"call-9"}) {
+		t.Fatalf("open calls = %v, want [call-9]", got)
+	}
+	// One whose start says nothing useful is only activity.
+	m.ProcessEvent(truncatedEvent(t0, "", "", ""))
+	m.ProcessEvent(truncatedEvent(t0, "assistant", "", ""))
+	if len(m.OpenCallIDs()) != 1 || m.SessionDone() {
+		t.Fatalf("open calls = %v, done = %v", m.OpenCallIDs(), m.SessionDone())
+	}
+	// A result cut short still ends the session.
+	m.ProcessEvent(truncatedEvent(t0, "result", "success", ""))
+	if !m.SessionDone() {
+		t.Error("truncated result didn't end the session")
+	}
+}
+
 func TestSessionID(t *testing.T) {
 	clk := newFakeClock(t0)
 	m := newTestMonitor(clk)
@@ -405,6 +569,21 @@ func TestSessionID(t *testing.T) {
 	}
 }
 
+func TestSessionID_WithoutInit(t *testing.T) {
+	// The init line was lost; the result still says which session it was.
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+
+	m.ProcessEvent(thinkingDeltaEvent(t0))
+	if m.SessionID() != "" {
+		t.Fatalf("SessionID = %q before any event carrying one", m.SessionID())
+	}
+	m.ProcessEvent(resultEvent(t0.Add(time.Second)))
+	if m.SessionID() != "sess-result" {
+		t.Fatalf("SessionID = %q, want it from the result event", m.SessionID())
+	}
+}
+
 func TestReasonString(t *testing.T) {
 	r := Reason{
 		IdleSilenceMS: 65000,
@@ -441,6 +620,81 @@ func TestReasonStringWithOpenCalls(t *testing.T) {
 	}
 }
 
+func TestReasonStringTotalOpenElapsed(t *testing.T) {
+	r := Reason{OpenCallCount: 2, TotalOpenElapsedMS: 90000}
+	if s := r.String(); !strings.Contains(s, "90000ms open in total") {
+		t.Fatalf("expected total open elapsed in reason string, got %q", s)
+	}
+	if s := (Reason{}).String(); strings.Contains(s, "open in total") {
+		t.Fatalf("expected no total without open calls, got %q", s)
+	}
+}
+
+func TestReasonAsOf(t *testing.T) {
+	base := Reason{
+		CheckedAt:     t0,
+		IdleSilenceMS: 45000,
+		OpenCallCount: 2,
+		OpenCalls: []OpenCallDetail{
+			{CallID: "call-1", ElapsedMS: 45000, TimeoutMS: 10000},
+			{CallID: "call-2", ElapsedMS: 20000, TimeoutMS: 10000},
+		},
+		TotalOpenElapsedMS: 65000,
+	}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantIdle  int64
+		wantCall  int64
+		wantTotal int64
+	}{
+		{"advanced", t0.Add(3 * time.Second), 48000, 48000, 71000},
+		{"zero now", time.Time{}, 45000, 45000, 65000},
+		{"now before check", t0.Add(-time.Second), 45000, 45000, 65000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := base.AsOf(tt.now)
+			if got.IdleSilenceMS != tt.wantIdle || got.OpenCalls[0].ElapsedMS != tt.wantCall || got.TotalOpenElapsedMS != tt.wantTotal {
+				t.Errorf("AsOf = idle %d, call %d, total %d; want %d, %d, %d",
+					got.IdleSilenceMS, got.OpenCalls[0].ElapsedMS, got.TotalOpenElapsedMS,
+					tt.wantIdle, tt.wantCall, tt.wantTotal)
+			}
+		})
+	}
+	if base.OpenCalls[0].ElapsedMS != 45000 {
+		t.Errorf("AsOf mutated the receiver's OpenCalls: %+v", base.OpenCalls)
+	}
+}
+
+func TestCheckTimeoutSortsOpenCallsByOverdue(t *testing.T) {
+	// At 100s: call-a (10s) is 60s over, call-b (60s) 10s over,
+	// call-c (100s) 30s under. Started in an order unrelated to overrun.
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-c", 100000))
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-b", 60000))
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-a", 10000))
+
+	clk.Advance(100 * time.Second)
+	_, reason := m.CheckTimeout(clk.Now())
+
+	var got []string
+	for _, c := range reason.OpenCalls {
+		got = append(got, c.CallID)
+	}
+	if want := "call-a call-b call-c"; strings.Join(got, " ") != want {
+		t.Errorf("OpenCalls order = %v, want %s", got, want)
+	}
+	if reason.TotalOpenElapsedMS != 300000 {
+		t.Errorf("TotalOpenElapsedMS = %d, want 300000", reason.TotalOpenElapsedMS)
+	}
+	if !reason.CheckedAt.Equal(clk.Now()) {
+		t.Errorf("CheckedAt = %v, want %v", reason.CheckedAt, clk.Now())
+	}
+}
+
 func TestVerdictString(t *testing.T) {
 	tests := []struct {
 		v    Verdict
@@ -593,3 +847,440 @@ func TestProcessEventReturnValue(t *testing.T) {
 		t.Fatalf("expected VerdictOK after all tools completed, got %v", v)
 	}
 }
+
+func TestHistoryRecordsProgression(t *testing.T) {
+	// tool started → waiting → tool deadline exceeded → hang
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+
+	if got := m.History(); len(got) != 0 {
+		t.Fatalf("expected empty history, got %d entries", len(got))
+	}
+
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+	clk.Advance(20 * time.Second)
+	m.CheckTimeout(clk.Now())
+	clk.Advance(21 * time.Second)
+	m.CheckTimeout(clk.Now())
+
+	h := m.History()
+	if len(h) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(h))
+	}
+	want := []struct {
+		at      time.Time
+		verdict Verdict
+		idleMS  int64
+	}{
+		{t0.Add(20 * time.Second), VerdictWaiting, 20000},
+		{t0.Add(41 * time.Second), VerdictHang, 41000},
+	}
+	for i, w := range want {
+		if !h[i].At.Equal(w.at) || h[i].Verdict != w.verdict || h[i].IdleMS != w.idleMS {
+			t.Errorf("snapshot %d = {%v %v %d}, want {%v %v %d}",
+				i, h[i].At, h[i].Verdict, h[i].IdleMS, w.at, w.verdict, w.idleMS)
+		}
+		if len(h[i].OpenCalls) != 1 || h[i].OpenCalls[0].CallID != "call-1" {
+			t.Errorf("snapshot %d open calls = %+v, want call-1", i, h[i].OpenCalls)
+		}
+	}
+}
+
+func TestSnapshotLeavesHistoryAlone(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+	clk.Advance(20 * time.Second)
+
+	v, r := m.Snapshot(clk.Now())
+	if cv, cr := m.CheckTimeout(clk.Now()); v != cv || r.IdleSilenceMS != cr.IdleSilenceMS || len(r.OpenCalls) != len(cr.OpenCalls) {
+		t.Errorf("Snapshot = %v %+v, CheckTimeout = %v %+v", v, r, cv, cr)
+	}
+	if h := m.History(); len(h) != 1 {
+		t.Errorf("history has %d entries, want only CheckTimeout's", len(h))
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(thinkingCompletedEvent(t0))
+
+	total := historySize + 7
+	for i := 0; i < total; i++ {
+		clk.Advance(2 * time.Second)
+		m.CheckTimeout(clk.Now())
+	}
+
+	h := m.History()
+	if len(h) != historySize {
+		t.Fatalf("expected %d snapshots, got %d", historySize, len(h))
+	}
+	// Oldest retained tick is the 8th; ticks are in order.
+	if want := t0.Add(16 * time.Second); !h[0].At.Equal(want) {
+		t.Errorf("oldest snapshot at %v, want %v", h[0].At, want)
+	}
+	for i := 1; i < len(h); i++ {
+		if !h[i].At.After(h[i-1].At) {
+			t.Fatalf("snapshots out of order at %d: %v then %v", i, h[i-1].At, h[i].At)
+		}
+	}
+	if last := h[len(h)-1]; last.Verdict != VerdictHang {
+		t.Errorf("latest verdict = %v, want hang", last.Verdict)
+	}
+}
+
+func TestEstimatedDeadlineTightensRepeatedCommand(t *testing.T) {
+	// First run of cmd-call-1 takes 10s; a second run of the same command
+	// is bounded by 3 × 10s = 30s instead of its declared 120s + 30s grace.
+	clk := newFakeClock(t0)
+	d := NewCommandDurations(3)
+	m := NewMonitor(idleTimeout, toolGrace, WithClock(clk), WithCommandDurations(d))
+
+	m.ProcessEvent(toolCallStartedEvent(clk.Now(), "call-1", 120000))
+	clk.Advance(10 * time.Second)
+	m.ProcessEvent(toolCallCompletedEvent(clk.Now(), "call-1"))
+
+	// Second run of the same command (same call ID → same command).
+	m.ProcessEvent(toolCallStartedEvent(clk.Now(), "call-1", 120000))
+	start := clk.Now()
+
+	clk.Advance(29 * time.Second)
+	v, reason := m.CheckTimeout(clk.Now())
+	if v != VerdictWaiting {
+		t.Fatalf("expected VerdictWaiting within estimated deadline, got %v", v)
+	}
+	if got := reason.OpenCalls[0].EstimatedDeadlineMS; got != 30000 {
+		t.Fatalf("EstimatedDeadlineMS = %d, want 30000", got)
+	}
+	if !strings.Contains(reason.String(), "estimated_deadline=30000ms") {
+		t.Errorf("Reason.String() = %q, want estimated deadline", reason.String())
+	}
+
+	clk.Advance(2 * time.Second)
+	v, _ = m.CheckTimeout(clk.Now())
+	if v != VerdictHang {
+		t.Fatalf("expected VerdictHang %v after start, got %v", clk.Now().Sub(start), v)
+	}
+}
+
+func TestEstimatedDeadlineUnusedWithoutHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "estimates disabled"},
+		{name: "first run", opts: []Option{WithCommandDurations(NewCommandDurations(3))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clk := newFakeClock(t0)
+			m := NewMonitor(idleTimeout, toolGrace, append([]Option{WithClock(clk)}, tt.opts...)...)
+
+			m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+			clk.Advance(39 * time.Second)
+			v, reason := m.CheckTimeout(clk.Now())
+			if v != VerdictWaiting {
+				t.Fatalf("expected VerdictWaiting within declared deadline, got %v", v)
+			}
+			if got := reason.OpenCalls[0].EstimatedDeadlineMS; got != 0 {
+				t.Errorf("EstimatedDeadlineMS = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestEstimatedDeadlineNeverLoosens(t *testing.T) {
+	// 10 × 10s = 100s is looser than the declared 100ms + 30s grace, so
+	// the declared deadline stays in force.
+	clk := newFakeClock(t0)
+	m := NewMonitor(idleTimeout, toolGrace, WithClock(clk), WithCommandDurations(NewCommandDurations(10)))
+
+	m.ProcessEvent(toolCallStartedEvent(clk.Now(), "call-1", 100))
+	clk.Advance(10 * time.Second)
+	m.ProcessEvent(toolCallCompletedEvent(clk.Now(), "call-1"))
+
+	m.ProcessEvent(toolCallStartedEvent(clk.Now(), "call-1", 100))
+	clk.Advance(31 * time.Second)
+	v, reason := m.CheckTimeout(clk.Now())
+	if v != VerdictHang {
+		t.Fatalf("expected declared deadline to apply, got %v", v)
+	}
+	if got := reason.OpenCalls[0].EstimatedDeadlineMS; got != 0 {
+		t.Errorf("EstimatedDeadlineMS = %d, want 0", got)
+	}
+}
+
+// streamDeltas feeds one thinking delta per second for d, checking for a
+// verdict after each, and returns the first non-OK verdict seen.
+func streamDeltas(m *Monitor, clk *fakeClock, d time.Duration) (Verdict, Reason) {
+	for end := clk.Now().Add(d); clk.Now().Before(end); {
+		clk.Advance(time.Second)
+		m.ProcessEvent(thinkingDeltaEvent(clk.Now()))
+		if v, r := m.CheckTimeout(clk.Now()); v != VerdictOK {
+			return v, r
+		}
+	}
+	return VerdictOK, Reason{}
+}
+
+func TestMaxThinkingEndlessDeltas(t *testing.T) {
+	// Deltas every second keep the idle timer fresh; only the phase cap fires.
+	clk := newFakeClock(t0)
+	m := NewMonitor(idleTimeout, toolGrace, WithClock(clk), WithMaxThinking(5*time.Minute))
+
+	v, reason := streamDeltas(m, clk, 20*time.Minute)
+	if v != VerdictHang {
+		t.Fatalf("expected VerdictHang from endless thinking, got %v", v)
+	}
+	// First delta at t0+1s, so the cap is exceeded at t0+1s+5m+1s.
+	if reason.ThinkingMS != (5*time.Minute + time.Second).Milliseconds() {
+		t.Errorf("ThinkingMS = %d, want %d", reason.ThinkingMS, (5*time.Minute + time.Second).Milliseconds())
+	}
+	if reason.IdleSilenceMS != 0 {
+		t.Errorf("IdleSilenceMS = %d, want 0 (events still flowing)", reason.IdleSilenceMS)
+	}
+	if !strings.Contains(reason.String(), "thinking for 301000ms") {
+		t.Errorf("Reason.String() = %q, want thinking duration", reason.String())
+	}
+}
+
+func TestMaxThinkingPhasesResetOnCompleted(t *testing.T) {
+	// Three 4-minute phases (12 minutes total) under a 5-minute cap.
+	clk := newFakeClock(t0)
+	m := NewMonitor(idleTimeout, toolGrace, WithClock(clk), WithMaxThinking(5*time.Minute))
+
+	for i := 0; i < 3; i++ {
+		if v, r := streamDeltas(m, clk, 4*time.Minute); v != VerdictOK {
+			t.Fatalf("phase %d: expected VerdictOK, got %v (%s)", i, v, r)
+		}
+		m.ProcessEvent(thinkingCompletedEvent(clk.Now()))
+	}
+}
+
+func TestMaxThinkingPhaseEndsOnOtherEvent(t *testing.T) {
+	// An assistant message between deltas starts a new phase.
+	clk := newFakeClock(t0)
+	m := NewMonitor(idleTimeout, toolGrace, WithClock(clk), WithMaxThinking(5*time.Minute))
+
+	streamDeltas(m, clk, 4*time.Minute)
+	m.ProcessEvent(assistantEvent(clk.Now()))
+	if v, r := streamDeltas(m, clk, 4*time.Minute); v != VerdictOK {
+		t.Fatalf("expected VerdictOK after phase reset, got %v (%s)", v, r)
+	}
+}
+
+func TestMaxThinkingDisabledByDefault(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+
+	if v, r := streamDeltas(m, clk, 20*time.Minute); v != VerdictOK {
+		t.Fatalf("expected VerdictOK without a thinking cap, got %v (%s)", v, r)
+	}
+}
+
+func TestTakeOverdueReportsEachCallOnce(t *testing.T) {
+	// call-1 (10s) expires at 40s, call-2 (60s) at 90s. Ticks every 5s.
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-2", 60000))
+
+	reported := map[string]int{}
+	firstAt := map[string]time.Duration{}
+	for tick := 5 * time.Second; tick <= 2*time.Minute; tick += 5 * time.Second {
+		clk.Advance(5 * time.Second)
+		for _, c := range m.TakeOverdue(clk.Now()) {
+			reported[c.CallID]++
+			if _, ok := firstAt[c.CallID]; !ok {
+				firstAt[c.CallID] = tick
+			}
+		}
+		m.CheckTimeout(clk.Now())
+	}
+
+	want := map[string]time.Duration{"call-1": 45 * time.Second, "call-2": 95 * time.Second}
+	for id, at := range want {
+		if reported[id] != 1 {
+			t.Errorf("%s reported %d times, want 1", id, reported[id])
+		}
+		if firstAt[id] != at {
+			t.Errorf("%s first reported at %v, want %v", id, firstAt[id], at)
+		}
+	}
+}
+
+func TestTakeOverdueDetail(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+
+	clk.Advance(41 * time.Second)
+	got := m.TakeOverdue(clk.Now())
+	if len(got) != 1 {
+		t.Fatalf("expected 1 overdue call, got %d", len(got))
+	}
+	want := OpenCallDetail{CallID: "call-1", Command: "cmd-call-1", ElapsedMS: 41000, TimeoutMS: 10000, DeadlineMS: 40000}
+	if got[0] != want {
+		t.Errorf("detail = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestTakeOverdueSortedAndSkipsCompleted(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	for _, id := range []string{"call-c", "call-a", "call-b"} {
+		m.ProcessEvent(toolCallStartedEvent(t0, id, 1000))
+	}
+	m.ProcessEvent(toolCallCompletedEvent(t0, "call-b"))
+
+	clk.Advance(time.Minute)
+	got := m.TakeOverdue(clk.Now())
+	if len(got) != 2 || got[0].CallID != "call-a" || got[1].CallID != "call-c" {
+		t.Fatalf("overdue = %+v, want call-a, call-c", got)
+	}
+}
+
+func TestPauseExcludesSuspendedTime(t *testing.T) {
+	// idle 60s, grace 30s. The agent goes quiet 10s into a 10s tool call
+	// (deadline 40s) and is suspended for an hour.
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+
+	clk.Advance(10 * time.Second)
+	m.Pause(clk.Now())
+	clk.Advance(time.Hour)
+	if v, r := m.CheckTimeout(clk.Now()); v != VerdictWaiting || r.OpenCalls[0].ElapsedMS != 10000 {
+		t.Fatalf("while paused: verdict %v, elapsed %dms; want Waiting as of the pause", v, r.OpenCalls[0].ElapsedMS)
+	}
+	if got := m.TakeOverdue(clk.Now()); len(got) != 0 {
+		t.Fatalf("while paused: overdue = %+v", got)
+	}
+
+	if d := m.Resume(clk.Now()); d != time.Hour {
+		t.Errorf("Resume = %v, want 1h", d)
+	}
+	if d := m.Resume(clk.Now()); d != 0 {
+		t.Errorf("second Resume = %v, want 0", d)
+	}
+
+	// The call has run 10s; 30s more brings it to its 40s deadline.
+	clk.Advance(30 * time.Second)
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictWaiting {
+		t.Fatalf("at the deadline: verdict %v, want Waiting", v)
+	}
+	clk.Advance(time.Second)
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictHang {
+		t.Fatalf("past the deadline: verdict %v, want Hang", v)
+	}
+}
+
+func TestPauseExcludesSuspendedIdleTime(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(assistantEvent(t0))
+
+	clk.Advance(50 * time.Second)
+	m.Pause(clk.Now())
+	clk.Advance(10 * time.Minute)
+	m.Pause(clk.Now()) // no-op: already paused
+	m.Resume(clk.Now())
+
+	clk.Advance(5 * time.Second)
+	if v, r := m.CheckTimeout(clk.Now()); v != VerdictOK || r.IdleSilenceMS != 55000 {
+		t.Fatalf("verdict %v, idle %dms; want OK after 55s of unsuspended silence", v, r.IdleSilenceMS)
+	}
+}
+
+func TestResumeKeepsEventsAfterThePauseInThePast(t *testing.T) {
+	// The agent is stopped 10s into call-1 and continued an hour later,
+	// but its next events are handled a second before the wrapper's
+	// SIGCONT is, while the monitor still counts as paused.
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(toolCallStartedEvent(t0, "call-1", 10000))
+
+	clk.Advance(10 * time.Second)
+	m.Pause(clk.Now())
+	clk.Advance(time.Hour)
+	m.ProcessEvent(toolCallStartedEvent(clk.Now(), "call-2", 10000))
+	m.ProcessEvent(thinkingDeltaEvent(clk.Now()))
+	clk.Advance(time.Second)
+	now := clk.Now()
+	m.Resume(now)
+
+	tests := []struct {
+		name string
+		got  time.Time
+		want time.Time
+	}{
+		{"call-1 start", m.state.OpenCalls["call-1"].StartedAt, now.Add(-10 * time.Second)},
+		{"call-2 start", m.state.OpenCalls["call-2"].StartedAt, now},
+		{"last event", m.state.LastEventAt, now},
+		{"thinking start", m.state.ThinkingSince, now},
+		{"thinking phase start", m.thinking.Current().Start, now},
+	}
+	for _, tt := range tests {
+		if !tt.got.Equal(tt.want) {
+			t.Errorf("%s = %v after Resume, want %v", tt.name, tt.got.Sub(now), tt.want.Sub(now))
+		}
+	}
+
+	v, r := m.CheckTimeout(now)
+	if v != VerdictWaiting || r.IdleSilenceMS != 0 {
+		t.Fatalf("verdict %v, idle %dms; want Waiting with no silence", v, r.IdleSilenceMS)
+	}
+	for _, c := range r.OpenCalls {
+		if c.ElapsedMS < 0 {
+			t.Errorf("%s elapsed %dms, want none negative", c.CallID, c.ElapsedMS)
+		}
+	}
+}
+
+func TestSetTimeouts(t *testing.T) {
+	clk := newFakeClock(t0)
+	m := newTestMonitor(clk)
+	m.ProcessEvent(assistantEvent(t0))
+
+	clk.Advance(50 * time.Second)
+	m.SetIdleTimeout(40 * time.Second)
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictHang {
+		t.Fatalf("verdict %v after 50s of silence; want a hang once the idle timeout is 40s", v)
+	}
+	m.SetIdleTimeout(2 * time.Minute)
+	if v, _ := m.CheckTimeout(clk.Now()); v != VerdictOK {
+		t.Fatalf("verdict %v; want OK once the idle timeout is back up", v)
+	}
+
+	m.ProcessEvent(toolCallStartedEvent(clk.Now(), "call-1", 10000))
+	clk.Advance(30 * time.Second)
+	m.SetToolGrace(5 * time.Second)
+	v, r := m.CheckTimeout(clk.Now())
+	if v != VerdictHang || r.OpenCalls[0].DeadlineMS != 15000 {
+		t.Fatalf("verdict %v, deadline %dms; want the open call to expire with the new grace", v, r.OpenCalls[0].DeadlineMS)
+	}
+}
+
+func TestNonJSONLinesAreNotLiveness(t *testing.T) {
+	for _, liveness := range []bool{false, true} {
+		t.Run(fmt.Sprint(liveness), func(t *testing.T) {
+			clk := newFakeClock(t0)
+			m := NewMonitor(idleTimeout, toolGrace, WithClock(clk), WithNonJSONLiveness(liveness))
+			m.ProcessEvent(assistantEvent(t0))
+
+			clk.Advance(50 * time.Second)
+			if v := m.ProcessEvent(events.NonJSONEvent(clk.Now(), []byte("T: Named models unavailable on free plan"))); v != VerdictOK {
+				t.Errorf("ProcessEvent = %v, want OK", v)
+			}
+			clk.Advance(20 * time.Second)
+			v, r := m.CheckTimeout(clk.Now())
+			if liveness && (v != VerdictOK || r.LastEventType != events.TypeWrapperNonJSON) {
+				t.Errorf("verdict %v, last event %q; want OK after the line", v, r.LastEventType)
+			}
+			if !liveness && (v != VerdictHang || r.IdleSilenceMS != 70000 || r.LastEventType != "assistant") {
+				t.Errorf("verdict %v, idle %dms, last event %q; want a hang 70s after the assistant event", v, r.IdleSilenceMS, r.LastEventType)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// BinaryInfo identifies the agent binary that serves a turn, so a
+// self-update between turns shows up in the logs.
+type BinaryInfo struct {
+	Path    string // resolved through PATH and symlinks
+	Size    int64
+	ModTime time.Time
+	SHA256  string // hex; empty unless requested
+}
+
+// StatBinary resolves bin the way Start does and describes the file it
+// names. Symlinks are followed because cursor-agent installs as a link
+// into a versioned directory that updates repoint. With hash set it also
+// checksums the file, which reads all of it.
+func StatBinary(bin string, hash bool) (BinaryInfo, error) {
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		return BinaryInfo{}, err
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return BinaryInfo{}, err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return BinaryInfo{}, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return BinaryInfo{}, err
+	}
+	info := BinaryInfo{Path: path, Size: fi.Size(), ModTime: fi.ModTime()}
+	if hash {
+		if info.SHA256, err = fileSHA256(path); err != nil {
+			return BinaryInfo{}, fmt.Errorf("hashing %s: %w", path, err)
+		}
+	}
+	return info, nil
+}
+
+// Changed reports whether b describes a different binary than prev.
+// Checksums are only compared when both have one.
+func (b BinaryInfo) Changed(prev BinaryInfo) bool {
+	if b.Path != prev.Path || b.Size != prev.Size || !b.ModTime.Equal(prev.ModTime) {
+		return true
+	}
+	return b.SHA256 != "" && prev.SHA256 != "" && b.SHA256 != prev.SHA256
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,93 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatBinary(t *testing.T) {
+	dir := t.TempDir()
+	v1 := filepath.Join(dir, "agent-v1")
+	v2 := filepath.Join(dir, "agent-v2")
+	if err := os.WriteFile(v1, []byte("#!/bin/sh\necho v1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(v2, []byte("#!/bin/sh\necho v2\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "cursor-agent")
+	if err := os.Symlink(v1, link); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := StatBinary(link, true)
+	if err != nil {
+		t.Fatalf("StatBinary: %v", err)
+	}
+	// EvalSymlinks too: the temp dir may itself sit behind a link.
+	if want, _ := filepath.EvalSymlinks(v1); first.Path != want {
+		t.Errorf("Path = %q, want symlink target %q", first.Path, want)
+	}
+	if first.Size != 18 {
+		t.Errorf("Size = %d, want 18", first.Size)
+	}
+	if len(first.SHA256) != 64 {
+		t.Errorf("SHA256 = %q, want 64 hex digits", first.SHA256)
+	}
+
+	same, err := StatBinary(link, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Changed(first) {
+		t.Errorf("unchanged binary reported as changed: %+v vs %+v", same, first)
+	}
+
+	// An update repoints the link at a new version.
+	if err := os.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(v2, link); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := StatBinary(link, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.Changed(first) {
+		t.Errorf("repointed binary not reported as changed")
+	}
+	if updated.SHA256 != "" {
+		t.Errorf("SHA256 = %q without hash", updated.SHA256)
+	}
+
+	if _, err := StatBinary(filepath.Join(dir, "missing"), false); err == nil {
+		t.Error("expected error for a missing binary")
+	}
+}
+
+func TestBinaryInfoChanged(t *testing.T) {
+	mtime := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := BinaryInfo{Path: "/opt/agent", Size: 100, ModTime: mtime, SHA256: "aa"}
+	tests := []struct {
+		name string
+		b    BinaryInfo
+		want bool
+	}{
+		{"identical", base, false},
+		{"path", BinaryInfo{Path: "/opt/other", Size: 100, ModTime: mtime, SHA256: "aa"}, true},
+		{"size", BinaryInfo{Path: "/opt/agent", Size: 101, ModTime: mtime, SHA256: "aa"}, true},
+		{"mtime", BinaryInfo{Path: "/opt/agent", Size: 100, ModTime: mtime.Add(time.Second), SHA256: "aa"}, true},
+		{"checksum", BinaryInfo{Path: "/opt/agent", Size: 100, ModTime: mtime, SHA256: "bb"}, true},
+		{"no checksum", BinaryInfo{Path: "/opt/agent", Size: 100, ModTime: mtime}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.b.Changed(base); got != tt.want {
+				t.Errorf("Changed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
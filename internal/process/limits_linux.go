@@ -0,0 +1,38 @@
+//go:build linux
+
+package process
+
+import (
+	"fmt"
+	"strings"
+)
+
+// withLimits wraps the agent command in a /bin/sh shim that applies the
+// configured resource limits with ulimit and then execs the agent, so the
+// limits are in place before the agent runs its first instruction and the
+// agent keeps the shim's PID. Without limits the command is unchanged.
+func withLimits(cfg Config, bin string, args []string) (string, []string, error) {
+	var ulimits []string
+	if cfg.MaxMemoryBytes > 0 {
+		// RLIMIT_DATA rather than RLIMIT_AS: V8 reserves gigabytes of
+		// address space up front without using it, so an address-space
+		// limit of a realistic size stops Node before it starts. The data
+		// limit counts the private writable memory actually mapped.
+		// ulimit -d takes KiB; round up so the limit is never tighter than
+		// requested.
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -d %d", (cfg.MaxMemoryBytes+1023)/1024))
+	}
+	if cfg.MaxCPUSeconds > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -t %d", cfg.MaxCPUSeconds))
+	}
+	if cfg.MaxOpenFiles > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -n %d", cfg.MaxOpenFiles))
+	}
+	if len(ulimits) == 0 {
+		return bin, args, nil
+	}
+	// "$0" is the agent binary and "$@" its arguments, passed as separate
+	// argv entries so nothing is re-parsed by the shell.
+	script := strings.Join(ulimits, " && ") + ` && exec "$0" "$@"`
+	return "/bin/sh", append([]string{"-c", script, bin}, args...), nil
+}
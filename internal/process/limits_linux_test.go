@@ -0,0 +1,58 @@
+//go:build linux
+
+package process
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStart_ResourceLimitsApplied(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `cat >/dev/null
+echo "$(ulimit -n) $(ulimit -d) $(ulimit -t) $*"`)
+
+	sess, err := Start(context.Background(), Config{
+		AgentBin:       bin,
+		Prompt:         "",
+		Model:          "m 1", // an argument with a space survives the shim
+		MaxOpenFiles:   64,
+		MaxMemoryBytes: 1<<30 + 1, // rounds up to the next KiB
+		MaxCPUSeconds:  30,
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	output, _ := io.ReadAll(sess.Stdout)
+	want := "64 1048577 30 --print --output-format stream-json --model m 1"
+	if got := strings.TrimSpace(string(output)); got != want {
+		t.Errorf("limits = %q, want %q", got, want)
+	}
+	if _, err := sess.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+}
+
+func TestExitStatus_AbortUnderMemoryLimit(t *testing.T) {
+	bin := writeScript(t, t.TempDir(), "agent.sh", "cat >/dev/null\nkill -ABRT $$")
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", MaxMemoryBytes: 1 << 30})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	_, _ = io.ReadAll(sess.Stdout)
+	_, _ = sess.Wait()
+	want := "killed by SIGABRT (memory limit likely exceeded: an allocation failed)"
+	if got := sess.ExitStatus().String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestWithLimits_NoLimitsUnchanged(t *testing.T) {
+	bin, args, err := withLimits(Config{}, "cursor-agent", []string{"--print"})
+	if err != nil || bin != "cursor-agent" || len(args) != 1 || args[0] != "--print" {
+		t.Errorf("withLimits = (%q, %q, %v), want command unchanged", bin, args, err)
+	}
+}
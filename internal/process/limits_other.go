@@ -0,0 +1,14 @@
+//go:build !linux
+
+package process
+
+import "errors"
+
+// withLimits rejects resource limits outside Linux, where RLIMIT_DATA is
+// not reliably enforced.
+func withLimits(cfg Config, bin string, args []string) (string, []string, error) {
+	if cfg.MaxMemoryBytes > 0 || cfg.MaxCPUSeconds > 0 || cfg.MaxOpenFiles > 0 {
+		return "", nil, errors.New("resource limits are only supported on Linux")
+	}
+	return bin, args, nil
+}
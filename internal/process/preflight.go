@@ -0,0 +1,79 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Preflight failures, distinguished so the caller can print an
+// actionable message instead of a generic exec error.
+var (
+	ErrAgentNotFound      = errors.New("agent binary not found")
+	ErrAgentNotExecutable = errors.New("agent binary not executable")
+	ErrNotAuthenticated   = errors.New("agent not authenticated")
+)
+
+// notAuthenticatedRE matches cursor-agent's status output when logged out.
+var notAuthenticatedRE = regexp.MustCompile(`(?i)not (logged in|authenticated)`)
+
+// Preflight checks that the agent can run before the first turn by
+// running `AgentBin --version` and then `AgentBin status`, each bounded
+// by timeout. It returns the reported version. A status command that
+// fails without saying the user is logged out is not treated as an
+// error, since older agents may not support it.
+func Preflight(ctx context.Context, cfg Config, timeout time.Duration) (string, error) {
+	stdout, _, err := runPreflight(ctx, cfg, timeout, "--version")
+	if err != nil {
+		return "", err
+	}
+	// stdout only: ssh may print warnings on stderr for --remote.
+	version, _, _ := strings.Cut(strings.TrimSpace(stdout), "\n")
+
+	_, out, _ := runPreflight(ctx, cfg, timeout, "status")
+	if notAuthenticatedRE.MatchString(out) {
+		return version, fmt.Errorf("%s status: %s: %w", cfg.AgentBin, strings.TrimSpace(out), ErrNotAuthenticated)
+	}
+	return version, nil
+}
+
+// runPreflight runs the agent with a single argument and returns its
+// stdout and all of its output (stdout, then stderr).
+func runPreflight(ctx context.Context, cfg Config, timeout time.Duration, arg string) (stdout, combined string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := command(ctx, cfg, cfg.AgentBin, []string{arg})
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	// Don't let a grandchild holding the output pipe outlive the timeout.
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	out := outBuf.String() + errBuf.String()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return outBuf.String(), out, nil
+	case cfg.Remote != "" && errors.As(err, &exitErr) && exitErr.ExitCode() == 127:
+		// The remote shell's "command not found".
+		return "", out, fmt.Errorf("%s on %s: %w", cfg.AgentBin, cfg.Remote, ErrAgentNotFound)
+	case cfg.Remote != "" && errors.As(err, &exitErr) && exitErr.ExitCode() == 126:
+		return "", out, fmt.Errorf("%s on %s: %w", cfg.AgentBin, cfg.Remote, ErrAgentNotExecutable)
+	case cfg.Remote == "" && (errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist)):
+		return "", out, fmt.Errorf("%s: %w", cfg.AgentBin, ErrAgentNotFound)
+	case cfg.Remote == "" && errors.Is(err, fs.ErrPermission):
+		return "", out, fmt.Errorf("%s: %w", cfg.AgentBin, ErrAgentNotExecutable)
+	case ctx.Err() == context.DeadlineExceeded:
+		return "", out, fmt.Errorf("%s %s did not finish within %v", cfg.AgentBin, arg, timeout)
+	default:
+		return "", out, fmt.Errorf("%s %s: %w: %s", cfg.AgentBin, arg, err, strings.TrimSpace(out))
+	}
+}
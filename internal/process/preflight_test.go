@@ -0,0 +1,127 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreflight(t *testing.T) {
+	dir := t.TempDir()
+	notExec := filepath.Join(dir, "not-exec.sh")
+	if err := os.WriteFile(notExec, []byte("#!/bin/sh\necho 1.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		bin         string
+		wantVersion string
+		wantErr     error  // sentinel, if any
+		wantErrText string // substring, if any
+	}{
+		{
+			name: "ok",
+			bin: writeScript(t, dir, "ok.sh", `case "$1" in
+--version) echo "2026.01.01-abc"; echo "extra line";;
+status) echo "Logged in as dev@example.com";;
+esac`),
+			wantVersion: "2026.01.01-abc",
+		},
+		{
+			name:    "not found",
+			bin:     filepath.Join(dir, "missing"),
+			wantErr: ErrAgentNotFound,
+		},
+		{
+			name:    "not found on PATH",
+			bin:     "cursor-agent-does-not-exist",
+			wantErr: ErrAgentNotFound,
+		},
+		{
+			name:    "not executable",
+			bin:     notExec,
+			wantErr: ErrAgentNotExecutable,
+		},
+		{
+			name: "not authenticated",
+			bin: writeScript(t, dir, "logged-out.sh", `case "$1" in
+--version) echo "2026.01.01-abc";;
+status) echo "Not logged in. Run cursor-agent login."; exit 1;;
+esac`),
+			wantVersion: "2026.01.01-abc",
+			wantErr:     ErrNotAuthenticated,
+		},
+		{
+			name: "status unsupported",
+			bin: writeScript(t, dir, "old.sh", `case "$1" in
+--version) echo "2025.01.01";;
+*) echo "unknown command: $1" >&2; exit 2;;
+esac`),
+			wantVersion: "2025.01.01",
+		},
+		{
+			name:        "version fails",
+			bin:         writeScript(t, dir, "broken.sh", `echo "boom" >&2; exit 3`),
+			wantErrText: "--version: exit status 3: boom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := Preflight(context.Background(), Config{AgentBin: tt.bin}, 5*time.Second)
+			if version != tt.wantVersion {
+				t.Errorf("version = %q, want %q", version, tt.wantVersion)
+			}
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantErrText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+					t.Errorf("err = %v, want containing %q", err, tt.wantErrText)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPreflight_Timeout(t *testing.T) {
+	bin := writeScript(t, t.TempDir(), "slow.sh", `exec sleep 10`)
+
+	start := time.Now()
+	_, err := Preflight(context.Background(), Config{AgentBin: bin}, 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not finish within 100ms") {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("preflight took %v despite a 100ms timeout", elapsed)
+	}
+}
+
+func TestPreflight_UsesEnvAndCwd(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `echo "$PREFLIGHT_TEST_VAR $(pwd -P)"`)
+	cwd, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := Preflight(context.Background(), Config{
+		AgentBin: bin,
+		Env:      []string{"PREFLIGHT_TEST_VAR=set"},
+		Cwd:      cwd,
+	}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Preflight: %v", err)
+	}
+	if want := "set " + cwd; version != want {
+		t.Errorf("version = %q, want %q", version, want)
+	}
+}
@@ -0,0 +1,50 @@
+package process
+
+import "fmt"
+
+// IOClass is a Linux I/O scheduling class for the agent (see ionice(1)).
+// Only classes that lower priority are offered; the realtime class needs
+// privileges and would starve other users, the opposite of the intent.
+type IOClass int
+
+const (
+	IOClassNone       IOClass = 0 // leave the inherited class
+	IOClassBestEffort IOClass = 2
+	IOClassIdle       IOClass = 3 // only gets disk time when nobody else wants it
+)
+
+func (c IOClass) String() string {
+	switch c {
+	case IOClassNone:
+		return "none"
+	case IOClassBestEffort:
+		return "best-effort"
+	case IOClassIdle:
+		return "idle"
+	default:
+		return fmt.Sprintf("IOClass(%d)", int(c))
+	}
+}
+
+// ParseIOClass parses the names accepted by ionice -c, by name or number.
+func ParseIOClass(s string) (IOClass, error) {
+	switch s {
+	case "", "none", "0":
+		return IOClassNone, nil
+	case "best-effort", "2":
+		return IOClassBestEffort, nil
+	case "idle", "3":
+		return IOClassIdle, nil
+	default:
+		return IOClassNone, fmt.Errorf("unsupported I/O class %q: want best-effort or idle", s)
+	}
+}
+
+// ioPriorityLevel is the level within the class. The kernel ignores it
+// for the idle class; best-effort gets the lowest level.
+func ioPriorityLevel(c IOClass) int {
+	if c == IOClassBestEffort {
+		return 7
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// ioprio_set "which" and the shift of the class within an I/O priority
+// value, from linux/ioprio.h.
+const (
+	ioprioWhoPgrp   = 2
+	ioprioClassShft = 13
+)
+
+// applyPriority lowers the CPU and I/O priority of the agent's process
+// group, so tools the agent spawns later inherit it. It reports every
+// setting that could not be applied; the agent keeps running either way.
+func applyPriority(pgid int, cfg Config) error {
+	var errs []error
+	if cfg.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PGRP, pgid, cfg.Nice); err != nil {
+			errs = append(errs, fmt.Errorf("setting nice %d: %w", cfg.Nice, err))
+		}
+	}
+	if cfg.IOClass != IOClassNone {
+		prio := int(cfg.IOClass)<<ioprioClassShft | ioPriorityLevel(cfg.IOClass)
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoPgrp, uintptr(pgid), uintptr(prio)); errno != 0 {
+			errs = append(errs, fmt.Errorf("setting I/O class %s: %w", cfg.IOClass, errno))
+		}
+	}
+	return errors.Join(errs...)
+}
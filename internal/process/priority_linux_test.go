@@ -0,0 +1,78 @@
+//go:build linux
+
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestStart_PriorityApplied(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `exec sleep 60`)
+
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", Nice: 5, IOClass: IOClassIdle})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() {
+		_ = sess.Kill("test cleanup")
+		_, _ = sess.Wait()
+	}()
+	if sess.PriorityErr != nil {
+		t.Fatalf("PriorityErr: %v", sess.PriorityErr)
+	}
+
+	if got := procNice(t, sess.PID()); got != 5 {
+		t.Errorf("nice = %d, want 5", got)
+	}
+	const ioprioWhoProcess = 1
+	prio, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(sess.PID()), 0)
+	if errno != 0 {
+		t.Fatalf("ioprio_get: %v", errno)
+	}
+	if class := IOClass(prio >> ioprioClassShft); class != IOClassIdle {
+		t.Errorf("I/O class = %v, want idle", class)
+	}
+}
+
+func TestStart_PriorityFailureIsNotFatal(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `cat >/dev/null`)
+
+	// The kernel rejects an unknown I/O class with EINVAL.
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", IOClass: IOClass(7)})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := sess.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if !errors.Is(sess.PriorityErr, syscall.EINVAL) {
+		t.Errorf("PriorityErr = %v, want EINVAL", sess.PriorityErr)
+	}
+}
+
+// procNice reads a process's nice value from /proc/<pid>/stat.
+func procNice(t *testing.T, pid int) int {
+	t.Helper()
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		t.Fatalf("reading stat: %v", err)
+	}
+	// Fields after the parenthesised command name start at field 3
+	// (state); nice is field 19.
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	nice, err := strconv.Atoi(fields[19-3])
+	if err != nil {
+		t.Fatalf("parsing nice: %v", err)
+	}
+	return nice
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// applyPriority lowers the CPU priority of the agent's process group.
+// I/O scheduling classes are Linux-only.
+func applyPriority(pgid int, cfg Config) error {
+	var errs []error
+	if cfg.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PGRP, pgid, cfg.Nice); err != nil {
+			errs = append(errs, fmt.Errorf("setting nice %d: %w", cfg.Nice, err))
+		}
+	}
+	if cfg.IOClass != IOClassNone {
+		errs = append(errs, errors.New("I/O scheduling classes are only supported on Linux"))
+	}
+	return errors.Join(errs...)
+}
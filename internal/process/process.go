@@ -2,10 +2,15 @@ package process
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -16,23 +21,127 @@ type Config struct {
 	Prompt     string   // the user prompt
 	Model      string   // model flag value
 	Workspace  string   // --workspace path
+	Cwd        string   // working directory for the agent process; empty inherits the wrapper's
 	ExtraFlags []string // any additional flags to pass through
 	Force      bool     // --force flag
 	SessionID  string   // non-empty to resume a previous session via --resume
+	// StreamingStdin keeps stdin open after the prompt is written, for
+	// flows that read follow-up input (permission prompts, tool
+	// confirmation). The caller must close Session.Stdin at turn end;
+	// cursor-agent reads the prompt to EOF, so it will not start work
+	// until then unless it is in such a flow.
+	StreamingStdin bool
+	// Env holds extra KEY=VALUE entries for the agent, applied over the
+	// wrapper's own environment. Later entries win.
+	Env []string
+	// Resource limits for the agent and everything it spawns. Zero
+	// leaves the wrapper's own limit in place. Linux only.
+	MaxMemoryBytes int64 // data segment and heap (RLIMIT_DATA); allocations fail when exceeded
+	MaxCPUSeconds  int64 // CPU time (RLIMIT_CPU); SIGXCPU when exceeded
+	MaxOpenFiles   int64 // open file descriptors (RLIMIT_NOFILE)
+	// PromptWriteTimeout bounds writing the prompt to the agent's stdin.
+	// Zero or negative waits indefinitely.
+	PromptWriteTimeout time.Duration
+	// Remote, if set, runs the agent on that host (user@host) over ssh.
+	// AgentBin, Workspace, Cwd and Env then refer to the remote host.
+	// SSHBin overrides the ssh client (default DefaultSSHBin).
+	Remote string
+	SSHBin string
+	// Nice and IOClass lower the agent's CPU and I/O priority. Zero
+	// values leave the priority inherited from the wrapper.
+	Nice    int
+	IOClass IOClass
+	// KillSignal is the first signal Kill sends. Zero means SIGTERM.
+	// cursor-agent answers SIGINT by cancelling the in-flight request and
+	// flushing a result event, where SIGTERM drops it cold.
+	KillSignal syscall.Signal
+	// KillGrace is how long Kill waits after KillSignal before sending
+	// SIGKILL. Zero or negative sends SIGKILL immediately.
+	KillGrace time.Duration
+	// NoPdeathsig leaves the agent running if the wrapper dies. By default
+	// the agent gets SIGTERM when the wrapper exits (Linux only).
+	NoPdeathsig bool
+}
+
+// DefaultKillGrace is the KillSignal→SIGKILL grace period used when the
+// caller does not configure one.
+const DefaultKillGrace = 5 * time.Second
+
+// Validate reports every problem with cfg that would make Start fail or
+// misbehave, joined into one error.
+func (cfg Config) Validate() error {
+	var errs []error
+	if cfg.AgentBin == "" {
+		errs = append(errs, errors.New("agent binary path is empty"))
+	}
+	if cfg.SessionID != "" && (strings.HasPrefix(cfg.SessionID, "-") || strings.ContainsAny(cfg.SessionID, " \t\n")) {
+		errs = append(errs, fmt.Errorf("session id %q does not look like a session id (was a flag swallowed as its value?)", cfg.SessionID))
+	}
+	for _, l := range []struct {
+		name string
+		v    int64
+	}{
+		{"memory", cfg.MaxMemoryBytes},
+		{"CPU", cfg.MaxCPUSeconds},
+		{"open file", cfg.MaxOpenFiles},
+	} {
+		if l.v < 0 {
+			errs = append(errs, fmt.Errorf("%s limit must not be negative, got %d", l.name, l.v))
+		}
+	}
+	if strings.HasPrefix(cfg.Remote, "-") {
+		errs = append(errs, fmt.Errorf("remote host %q must not start with '-'", cfg.Remote))
+	}
+	if cfg.Nice < -20 || cfg.Nice > 19 {
+		errs = append(errs, fmt.Errorf("nice value %d is outside -20..19", cfg.Nice))
+	}
+	if runtime.GOOS != "linux" && (cfg.MaxMemoryBytes > 0 || cfg.MaxCPUSeconds > 0 || cfg.MaxOpenFiles > 0) {
+		errs = append(errs, errors.New("resource limits are only supported on Linux"))
+	}
+	return errors.Join(errs...)
+}
+
+// Clone returns a copy of cfg that shares no slices with it, so one turn
+// can change its copy without affecting the next.
+func (cfg Config) Clone() Config {
+	cfg.ExtraFlags = slices.Clone(cfg.ExtraFlags)
+	cfg.Env = slices.Clone(cfg.Env)
+	return cfg
 }
 
 // Session represents a running cursor-agent process.
-// Stdin is not exposed — it is written and closed during Start().
 type Session struct {
+	// Stdin is the agent's stdin, with the prompt already written, when
+	// Config.StreamingStdin is set. Otherwise it is nil: stdin is written
+	// and closed during Start().
+	Stdin  io.WriteCloser
 	Stdout io.ReadCloser
 	Stderr io.ReadCloser
 	Cmd    *exec.Cmd
+
+	// PriorityErr is set when Config.Nice or Config.IOClass could not be
+	// applied. The agent still runs, at its inherited priority.
+	PriorityErr error
+
+	killSignal    syscall.Signal
+	killGrace     time.Duration
+	memoryLimited bool // Config.MaxMemoryBytes was set
+
+	mu         sync.Mutex
+	killReason string // first reason passed to Kill; empty if never killed
+	suspended  bool   // between Suspend and Resume
 }
 
 // Start spawns cursor-agent and returns handles to its I/O and process.
-// The prompt is written to stdin and stdin is closed before returning.
+// The prompt is written to stdin and, unless cfg.StreamingStdin is set,
+// stdin is closed before returning.
 func Start(ctx context.Context, cfg Config) (*Session, error) {
-	cmd := exec.CommandContext(ctx, cfg.AgentBin, buildArgs(cfg)...)
+	bin, args, err := withLimits(cfg, cfg.AgentBin, buildArgs(cfg))
+	if err != nil {
+		return nil, err
+	}
+	cmd := command(ctx, cfg, bin, args)
+	cmd.SysProcAttr = sysProcAttr(cfg)
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -50,31 +159,133 @@ func Start(ctx context.Context, cfg Config) (*Session, error) {
 	}
 
 	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("starting cursor-agent: %w", err)
+		se := &StartError{Err: err}
+		if cfg.Remote == "" && bin == cfg.AgentBin {
+			se.Diagnosis = diagnoseStartError(bin, err)
+		}
+		return nil, se
 	}
+	// The agent leads its own process group (see sysProcAttr), so its
+	// pid is also the group id.
+	prioErr := applyPriority(cmd.Process.Pid, cfg)
 
 	// Write prompt and close stdin. cursor-agent reads stdin to EOF
 	// to capture the prompt. If stdin is not closed, the agent hangs
 	// waiting for more input — which would look like an agent hang
 	// to the monitor.
-	if _, err := io.WriteString(stdin, cfg.Prompt); err != nil {
+	err = writePrompt(stdin, cfg.Prompt, cfg.PromptWriteTimeout)
+	switch {
+	case errors.Is(err, syscall.EPIPE):
+		// The agent exited without reading the whole prompt. Carry on so
+		// the caller reports its exit status like any other early exit.
+	case err != nil:
 		// Best-effort kill; process may not have read anything yet.
 		_ = cmd.Process.Kill()
-		return nil, fmt.Errorf("writing prompt to stdin: %w", err)
+		_ = cmd.Wait() // reap it; the write error is what matters
+		return nil, err
+	}
+	if cfg.StreamingStdin {
+		return &Session{Stdin: stdin, Stdout: stdout, Stderr: stderr, Cmd: cmd, PriorityErr: prioErr, killSignal: cfg.KillSignal, killGrace: cfg.KillGrace, memoryLimited: cfg.MaxMemoryBytes > 0}, nil
 	}
 	if err := stdin.Close(); err != nil {
 		_ = cmd.Process.Kill()
 		return nil, fmt.Errorf("closing stdin: %w", err)
 	}
 
-	return &Session{Stdout: stdout, Stderr: stderr, Cmd: cmd}, nil
+	return &Session{Stdout: stdout, Stderr: stderr, Cmd: cmd, PriorityErr: prioErr, killSignal: cfg.KillSignal, killGrace: cfg.KillGrace, memoryLimited: cfg.MaxMemoryBytes > 0}, nil
+}
+
+// command builds the exec.Cmd that runs bin with args as the agent:
+// locally in cfg.Cwd with cfg.Env added, or on cfg.Remote over ssh.
+func command(ctx context.Context, cfg Config, bin string, args []string) *exec.Cmd {
+	if cfg.Remote != "" {
+		bin, args = remoteCommand(cfg, bin, args)
+		return exec.CommandContext(ctx, bin, args...)
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = cfg.Cwd
+	if len(cfg.Env) > 0 {
+		// exec keeps the last value for duplicate keys.
+		cmd.Env = append(os.Environ(), cfg.Env...)
+	}
+	return cmd
 }
 
-// killGrace is the time to wait after SIGTERM before sending SIGKILL.
-const killGrace = 5 * time.Second
+// ErrPromptNotConsumed is returned by Start when the agent does not read
+// the whole prompt within Config.PromptWriteTimeout.
+var ErrPromptNotConsumed = errors.New("agent did not consume prompt")
 
-// Kill sends SIGTERM to the process, waits briefly, then sends SIGKILL
-// if the process has not exited. The reason is for logging only.
+// writePrompt writes prompt to stdin, giving up after timeout (if
+// positive) so an agent wedged at startup cannot block Start forever.
+func writePrompt(stdin io.WriteCloser, prompt string, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.WriteString(stdin, prompt)
+		done <- err
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("writing prompt to stdin: %w", err)
+		}
+		return nil
+	case <-expired:
+		// Closing stdin unblocks the writer goroutine.
+		_ = stdin.Close()
+		return fmt.Errorf("%w (%d bytes) within %v", ErrPromptNotConsumed, len(prompt), timeout)
+	}
+}
+
+// StartError is returned by Start when cmd.Start itself fails, as opposed
+// to a failure after the process was running (e.g. writing the prompt).
+type StartError struct {
+	Err error
+	// Diagnosis explains Err in terms of the agent binary (missing, not
+	// executable, wrong architecture) and wraps ErrAgentNotFound,
+	// ErrAgentNotExecutable or ErrAgentWrongFormat. It is nil when Err
+	// isn't about the binary, and for remote agents.
+	Diagnosis error
+}
+
+func (e *StartError) Error() string {
+	if e.Diagnosis != nil {
+		return "starting cursor-agent: " + e.Diagnosis.Error()
+	}
+	return "starting cursor-agent: " + e.Err.Error()
+}
+
+func (e *StartError) Unwrap() []error {
+	if e.Diagnosis != nil {
+		return []error{e.Err, e.Diagnosis}
+	}
+	return []error{e.Err}
+}
+
+// IsTransientStartError reports whether err is a spawn failure that may
+// succeed on retry: the host was briefly out of processes or memory, or
+// the binary was being replaced. Missing or non-executable binaries are
+// not transient.
+func IsTransientStartError(err error) bool {
+	var se *StartError
+	if !errors.As(err, &se) {
+		return false
+	}
+	return errors.Is(se.Err, syscall.EAGAIN) ||
+		errors.Is(se.Err, syscall.ENOMEM) ||
+		errors.Is(se.Err, syscall.ETXTBSY)
+}
+
+// Kill sends the configured kill signal (SIGTERM by default) to the
+// process, waits up to the configured grace period, then sends SIGKILL
+// if the process has not exited. With no grace period it sends SIGKILL
+// straight away. The reason is for logging only.
 //
 // Kill only sends signals — it does not wait for the process to exit.
 // The caller must still call Wait() to collect the process state.
@@ -83,18 +294,41 @@ func (s *Session) Kill(reason string) error {
 		return nil
 	}
 
-	// Send SIGTERM for graceful shutdown.
-	if err := s.Cmd.Process.Signal(syscall.SIGTERM); err != nil {
+	s.mu.Lock()
+	if s.killReason == "" {
+		s.killReason = reason
+	}
+	s.mu.Unlock()
+
+	if s.killGrace <= 0 {
+		// Process may already be dead — not an error.
+		_ = s.Cmd.Process.Kill()
+		return nil
+	}
+
+	// Send the kill signal for graceful shutdown.
+	sig := s.killSignal
+	if sig == 0 {
+		sig = syscall.SIGTERM
+	}
+	if err := s.Cmd.Process.Signal(sig); err != nil {
 		// Process may already be dead — not an error.
 		return nil
 	}
+	// A stopped agent can't act on the signal until it is continued.
+	s.mu.Lock()
+	suspended := s.suspended
+	s.mu.Unlock()
+	if suspended {
+		_ = s.Resume() // the SIGKILL fallback still applies if this fails
+	}
 
-	// Poll briefly to see if SIGTERM was enough. We use a goroutine
+	// Poll briefly to see if the signal was enough. We use a goroutine
 	// with Process.Signal(0) to probe liveness, avoiding a race with
 	// cmd.Wait() which the caller uses to collect the process state.
 	done := make(chan struct{})
 	go func() {
-		deadline := time.After(killGrace)
+		deadline := time.After(s.killGrace)
 		ticker := time.NewTicker(50 * time.Millisecond)
 		defer ticker.Stop()
 		for {
@@ -115,11 +349,11 @@ func (s *Session) Kill(reason string) error {
 
 	// Check if process is still alive after the grace period.
 	if err := s.Cmd.Process.Signal(syscall.Signal(0)); err != nil {
-		// Process has exited — SIGTERM was sufficient.
+		// Process has exited — the signal was sufficient.
 		return nil
 	}
 
-	// Process did not exit after SIGTERM — escalate to SIGKILL.
+	// Process did not exit after the signal — escalate to SIGKILL.
 	if err := s.Cmd.Process.Kill(); err != nil {
 		// Process may have exited between the check and the kill.
 		return nil
@@ -127,12 +361,168 @@ func (s *Session) Kill(reason string) error {
 	return nil
 }
 
+// Suspend stops the agent's process group with SIGSTOP, for when the
+// wrapper itself is suspended from the terminal. The agent runs in its
+// own process group, so the terminal's SIGTSTP never reaches it, and
+// SIGSTOP can't be ignored the way a forwarded SIGTSTP can.
+func (s *Session) Suspend() error {
+	return s.signalGroup(syscall.SIGSTOP, true)
+}
+
+// Resume continues the agent's process group with SIGCONT. It is
+// harmless when the agent isn't stopped.
+func (s *Session) Resume() error {
+	return s.signalGroup(syscall.SIGCONT, false)
+}
+
+func (s *Session) signalGroup(sig syscall.Signal, suspended bool) error {
+	if s.Cmd.Process == nil {
+		return nil
+	}
+	// Start puts the agent in a process group whose ID is its pid.
+	if err := syscall.Kill(-s.Cmd.Process.Pid, sig); err != nil {
+		return fmt.Errorf("sending %v to agent process group: %w", sig, err)
+	}
+	s.mu.Lock()
+	s.suspended = suspended
+	s.mu.Unlock()
+	return nil
+}
+
+// Close closes the agent's stdout and stderr, ending any read blocked on
+// them. Killing the agent alone may not: a grandchild that inherited the
+// pipes keeps them open after the agent is gone. Anything still buffered
+// in the pipes is lost, so call it only once the output is no longer
+// wanted. Reads then fail with an error matching os.ErrClosed.
+func (s *Session) Close() error {
+	return errors.Join(s.Stdout.Close(), s.Stderr.Close())
+}
+
+// PID returns the agent's process ID, or 0 if it was never started.
+func (s *Session) PID() int {
+	if s.Cmd.Process == nil {
+		return 0
+	}
+	return s.Cmd.Process.Pid
+}
+
 // Wait blocks until the process exits and returns its status.
 func (s *Session) Wait() (*os.ProcessState, error) {
 	err := s.Cmd.Wait()
 	return s.Cmd.ProcessState, err
 }
 
+// ErrForcedReap is returned by WaitContext when the agent outlived the
+// context and had to be killed before it could be reaped.
+var ErrForcedReap = errors.New("agent killed to reap it")
+
+// WaitContext is Wait bounded by ctx. An agent can close stdout and then
+// keep running, for instance when a forked grandchild keeps it busy, and
+// a plain Wait would block on it forever. If ctx ends first, WaitContext
+// kills the agent with reason, reaps it and returns its state with an
+// error wrapping ErrForcedReap.
+func (s *Session) WaitContext(ctx context.Context, reason string) (*os.ProcessState, error) {
+	done := make(chan error, 1)
+	go func() { done <- s.Cmd.Wait() }()
+	select {
+	case err := <-done:
+		return s.Cmd.ProcessState, err
+	case <-ctx.Done():
+	}
+	_ = s.Kill(reason) // escalates to SIGKILL, so the wait below ends
+	if err := <-done; s.Cmd.ProcessState == nil {
+		return nil, err
+	}
+	return s.Cmd.ProcessState, fmt.Errorf("%w: %w", ErrForcedReap, context.Cause(ctx))
+}
+
+// ExitStatus describes how the agent process ended.
+type ExitStatus struct {
+	Code   int            // exit code; -1 if the process was signaled
+	Signal syscall.Signal // terminating signal; 0 if the process exited
+	// KilledBy is the reason passed to Kill if the wrapper signaled the
+	// process, so a SIGKILL from hang handling isn't mistaken for an OOM.
+	KilledBy string
+	// MemoryLimited is set when the agent ran under Config.MaxMemoryBytes.
+	// Going over it fails an allocation rather than raising a signal of
+	// its own, and Node aborts on a failed allocation, so an abort is then
+	// most likely the limit.
+	MemoryLimited bool
+}
+
+// String describes the exit for log and error messages, e.g.
+// "exited (code 1)" or "killed by SIGKILL (possible OOM)".
+func (e ExitStatus) String() string {
+	switch {
+	case e.Signal == 0:
+		return fmt.Sprintf("exited (code %d)", e.Code)
+	case e.KilledBy != "":
+		return fmt.Sprintf("killed by %s (wrapper: %s)", SignalName(e.Signal), e.KilledBy)
+	case e.Signal == syscall.SIGXCPU:
+		return "killed by SIGXCPU (CPU limit exceeded)"
+	case e.MemoryLimited && (e.Signal == syscall.SIGABRT || e.Signal == syscall.SIGSEGV || e.Signal == syscall.SIGBUS):
+		return fmt.Sprintf("killed by %s (memory limit likely exceeded: an allocation failed)", SignalName(e.Signal))
+	case e.Signal == syscall.SIGKILL:
+		// Nobody but the kernel's OOM killer (or an operator) sends an
+		// unannounced SIGKILL.
+		return "killed by SIGKILL (possible OOM)"
+	default:
+		return "killed by " + SignalName(e.Signal)
+	}
+}
+
+// ExitStatus reports how the process ended. Only meaningful after Wait.
+func (s *Session) ExitStatus() ExitStatus {
+	s.mu.Lock()
+	st := ExitStatus{Code: -1, KilledBy: s.killReason, MemoryLimited: s.memoryLimited}
+	s.mu.Unlock()
+
+	ps := s.Cmd.ProcessState
+	if ps == nil {
+		return st
+	}
+	st.Code = ps.ExitCode()
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		st.Signal = ws.Signal()
+	}
+	return st
+}
+
+// signalNames covers the signals an agent is plausibly terminated by.
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGUSR2: "SIGUSR2",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGXCPU: "SIGXCPU",
+	syscall.SIGXFSZ: "SIGXFSZ",
+}
+
+// SignalName returns the conventional name of sig, e.g. "SIGSEGV".
+func SignalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	return fmt.Sprintf("signal %d", int(sig))
+}
+
+// Args returns the arguments Start passes to cursor-agent, before any
+// wrapping for resource limits or --remote.
+func (cfg Config) Args() []string {
+	return buildArgs(cfg)
+}
+
 // buildArgs constructs the cursor-agent argument list from the config.
 func buildArgs(cfg Config) []string {
 	args := []string{"--print", "--output-format", "stream-json"}
@@ -1,11 +1,17 @@
 package process
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -184,6 +190,157 @@ func TestStart_WritesPromptToStdin(t *testing.T) {
 	}
 }
 
+func TestStart_EnvPropagation(t *testing.T) {
+	t.Setenv("WRAP_TEST_INHERITED", "from-wrapper")
+	t.Setenv("WRAP_TEST_OVERRIDDEN", "from-wrapper")
+
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `cat >/dev/null
+echo "$WRAP_TEST_INHERITED $WRAP_TEST_OVERRIDDEN $WRAP_TEST_ADDED"`)
+
+	sess, err := Start(context.Background(), Config{
+		AgentBin: bin,
+		Prompt:   "",
+		Env:      []string{"WRAP_TEST_OVERRIDDEN=first", "WRAP_TEST_ADDED=added", "WRAP_TEST_OVERRIDDEN=second"},
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	output, _ := io.ReadAll(sess.Stdout)
+	if got, want := strings.TrimSpace(string(output)), "from-wrapper second added"; got != want {
+		t.Errorf("env = %q, want %q", got, want)
+	}
+	if _, err := sess.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if got := os.Getenv("WRAP_TEST_ADDED"); got != "" {
+		t.Errorf("wrapper environment polluted: WRAP_TEST_ADDED=%q", got)
+	}
+}
+
+func TestStart_Cwd(t *testing.T) {
+	scriptDir := t.TempDir()
+	bin := writeScript(t, scriptDir, "agent.sh", `cat >/dev/null
+pwd -P`)
+
+	cwd, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("EvalSymlinks: %v", err)
+	}
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", Cwd: cwd})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	output, _ := io.ReadAll(sess.Stdout)
+	if got := strings.TrimSpace(string(output)); got != cwd {
+		t.Errorf("pwd = %q, want %q", got, cwd)
+	}
+	if _, err := sess.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+}
+
+func TestSession_PID(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `cat >/dev/null
+echo $$`)
+
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: ""})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	output, _ := io.ReadAll(sess.Stdout)
+	if got, want := strings.TrimSpace(string(output)), strconv.Itoa(sess.PID()); got != want {
+		t.Errorf("script pid = %s, PID() = %s", got, want)
+	}
+	if _, err := sess.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+}
+
+func TestStart_OwnProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `cat >/dev/null
+sleep 5`)
+
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", KillGrace: 0})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer func() {
+		_ = sess.Kill("test cleanup")
+		_, _ = sess.Wait()
+	}()
+
+	pgid, err := syscall.Getpgid(sess.PID())
+	if err != nil {
+		t.Fatalf("Getpgid: %v", err)
+	}
+	if pgid != sess.PID() {
+		t.Errorf("pgid = %d, want agent's own pid %d", pgid, sess.PID())
+	}
+}
+
+func TestStart_StreamingStdinDeliversLateWrites(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `cat`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sess, err := Start(ctx, Config{AgentBin: bin, Prompt: "prompt\n", StreamingStdin: true})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if sess.Stdin == nil {
+		t.Fatal("expected Session.Stdin with StreamingStdin")
+	}
+
+	// The prompt arrives before the late write is even made.
+	r := bufio.NewReader(sess.Stdout)
+	if line, _ := r.ReadString('\n'); line != "prompt\n" {
+		t.Fatalf("first line = %q, want prompt", line)
+	}
+
+	if _, err := io.WriteString(sess.Stdin, "y\n"); err != nil {
+		t.Fatalf("late write: %v", err)
+	}
+	if line, _ := r.ReadString('\n'); line != "y\n" {
+		t.Fatalf("second line = %q, want late write", line)
+	}
+
+	// cat exits only once the caller closes stdin.
+	if err := sess.Stdin.Close(); err != nil {
+		t.Fatalf("closing stdin: %v", err)
+	}
+	io.ReadAll(r)
+	ps, err := sess.Wait()
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if ps.ExitCode() != 0 {
+		t.Errorf("exit code = %d, want 0", ps.ExitCode())
+	}
+}
+
+func TestStart_DefaultStdinNotExposed(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `cat`)
+
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "test"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if sess.Stdin != nil {
+		t.Error("expected nil Session.Stdin without StreamingStdin")
+	}
+	io.ReadAll(sess.Stdout)
+	sess.Wait()
+}
+
 func TestStart_ClosesStdinAfterWrite(t *testing.T) {
 	dir := t.TempDir()
 	// cat will exit once it reads EOF from stdin. If stdin is not closed,
@@ -209,6 +366,47 @@ func TestStart_ClosesStdinAfterWrite(t *testing.T) {
 	}
 }
 
+func TestStart_PromptWriteTimeout(t *testing.T) {
+	dir := t.TempDir()
+	// Never reads stdin, like an agent wedged at startup.
+	bin := writeScript(t, dir, "agent.sh", `exec sleep 60`)
+
+	// Larger than any pipe buffer, so the write cannot complete.
+	prompt := strings.Repeat("x", 4<<20)
+	start := time.Now()
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: prompt, PromptWriteTimeout: 200 * time.Millisecond})
+	if err == nil {
+		_ = sess.Kill("test cleanup")
+		_, _ = sess.Wait()
+		t.Fatal("expected error, got nil")
+	}
+	if !errors.Is(err, ErrPromptNotConsumed) {
+		t.Errorf("expected ErrPromptNotConsumed, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "within 200ms") {
+		t.Errorf("expected timeout in message, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Start took %v with a 200ms prompt timeout", elapsed)
+	}
+}
+
+func TestStart_AgentExitsWithoutReadingPrompt(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `exit 7`)
+
+	// Big enough that the write is still in progress when the agent exits.
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: strings.Repeat("x", 4<<20)})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, sess.Stdout)
+	_, _ = sess.Wait()
+	if got := sess.ExitStatus().Code; got != 7 {
+		t.Errorf("exit code = %d, want 7", got)
+	}
+}
+
 func TestStart_ErrorForNonExistentBinary(t *testing.T) {
 	_, err := Start(context.Background(), Config{
 		AgentBin: "/nonexistent/binary/that/does/not/exist",
@@ -222,6 +420,132 @@ func TestStart_ErrorForNonExistentBinary(t *testing.T) {
 	}
 }
 
+func TestStart_DiagnosesUnrunnableBinary(t *testing.T) {
+	dir := t.TempDir()
+	noExec := filepath.Join(dir, "no-exec")
+	if err := os.WriteFile(noExec, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	badInterp := filepath.Join(dir, "bad-interp")
+	if err := os.WriteFile(badInterp, []byte("#!/nonexistent/interp\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wrongFormat := filepath.Join(dir, "wrong-format")
+	if err := os.WriteFile(wrongFormat, []byte{0x7f, 'E', 'L', 'F', 0, 0, 0, 0}, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		bin      string
+		sentinel error
+		want     string
+	}{
+		{"missing path", filepath.Join(dir, "missing"), ErrAgentNotFound, "not found at " + filepath.Join(dir, "missing")},
+		{"not on PATH", "cursor-agent-that-does-not-exist", ErrAgentNotFound, "not found on PATH"},
+		{"not executable", noExec, ErrAgentNotExecutable, "chmod +x"},
+		{"directory", dir, ErrAgentNotExecutable, "is a directory"},
+		{"missing interpreter", badInterp, ErrAgentNotFound, "/nonexistent/interp"},
+		{"wrong format", wrongFormat, ErrAgentWrongFormat, "another architecture"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Start(context.Background(), Config{AgentBin: tt.bin, Prompt: "test"})
+			if !errors.Is(err, tt.sentinel) {
+				t.Fatalf("error = %v, want %v", err, tt.sentinel)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+			if IsTransientStartError(err) {
+				t.Error("IsTransientStartError = true, want false")
+			}
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		kill       string // if set, Kill the process with this reason
+		wantCode   int
+		wantSignal syscall.Signal
+		wantString string
+	}{
+		{"normal exit", "cat >/dev/null\nexit 3", "", 3, 0, "exited (code 3)"},
+		{"segfault", "cat >/dev/null\nkill -SEGV $$", "", -1, syscall.SIGSEGV, "killed by SIGSEGV"},
+		{"CPU limit", "cat >/dev/null\nkill -XCPU $$", "", -1, syscall.SIGXCPU, "killed by SIGXCPU (CPU limit exceeded)"},
+		{"unannounced SIGKILL", "cat >/dev/null\nkill -KILL $$", "", -1, syscall.SIGKILL, "killed by SIGKILL (possible OOM)"},
+		{"abort without a memory limit", "cat >/dev/null\nkill -ABRT $$", "", -1, syscall.SIGABRT, "killed by SIGABRT"},
+		{"killed by wrapper", "exec sleep 60", "hang detected", -1, syscall.SIGTERM, "killed by SIGTERM (wrapper: hang detected)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := writeScript(t, t.TempDir(), "agent.sh", tt.body)
+			sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", KillGrace: time.Second})
+			if err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			if tt.kill != "" {
+				time.Sleep(50 * time.Millisecond)
+				// The grace poll can't see the exit until Wait reaps the
+				// process, so Kill runs alongside Wait as in the wrapper.
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					_ = sess.Kill(tt.kill)
+				}()
+				defer func() { <-done }()
+			}
+			io.ReadAll(sess.Stdout)
+			sess.Wait()
+
+			st := sess.ExitStatus()
+			if st.Code != tt.wantCode || st.Signal != tt.wantSignal {
+				t.Errorf("ExitStatus = {code %d signal %v}, want {code %d signal %v}", st.Code, st.Signal, tt.wantCode, tt.wantSignal)
+			}
+			if got := st.String(); got != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
+
+func TestSignalName(t *testing.T) {
+	if got := SignalName(syscall.SIGSEGV); got != "SIGSEGV" {
+		t.Errorf("SignalName(SIGSEGV) = %q", got)
+	}
+	if got := SignalName(syscall.Signal(63)); got != "signal 63" {
+		t.Errorf("SignalName(63) = %q", got)
+	}
+}
+
+func TestIsTransientStartError(t *testing.T) {
+	_, enoent := Start(context.Background(), Config{AgentBin: "/nonexistent/binary", Prompt: "test"})
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"EAGAIN from start", &StartError{Err: &os.PathError{Op: "fork/exec", Path: "agent", Err: syscall.EAGAIN}}, true},
+		{"ENOMEM from start", &StartError{Err: syscall.ENOMEM}, true},
+		{"ETXTBSY from start", &StartError{Err: syscall.ETXTBSY}, true},
+		{"wrapped start error", fmt.Errorf("turn 2: %w", &StartError{Err: syscall.EAGAIN}), true},
+		{"ENOENT from start", enoent, false},
+		{"EAGAIN outside start", fmt.Errorf("writing prompt to stdin: %w", syscall.EAGAIN), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientStartError(tt.err); got != tt.want {
+				t.Errorf("IsTransientStartError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestWait_ReturnsCorrectExitCode(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -254,7 +578,7 @@ func TestKill_SendsSIGTERM(t *testing.T) {
 	dir := t.TempDir()
 	bin := writeScript(t, dir, "agent.sh", `sleep 60`)
 
-	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: ""})
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", KillGrace: 500 * time.Millisecond})
 	if err != nil {
 		t.Fatalf("Start failed: %v", err)
 	}
@@ -271,38 +595,283 @@ func TestKill_SendsSIGTERM(t *testing.T) {
 	}
 }
 
-func TestKill_EscalatesToSIGKILL(t *testing.T) {
+func TestSession_SuspendResume(t *testing.T) {
 	dir := t.TempDir()
-	// Script that traps SIGTERM and ignores it — requires SIGKILL.
 	bin := writeScript(t, dir, "agent.sh", `
-trap '' TERM
-sleep 60
+sleep 60 &
+child=$!
+trap 'kill $child; exit 4' TERM
+wait
 `)
 
-	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: ""})
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", KillGrace: 500 * time.Millisecond})
 	if err != nil {
 		t.Fatalf("Start failed: %v", err)
 	}
-
 	time.Sleep(100 * time.Millisecond)
 
-	done := make(chan error, 1)
-	go func() {
-		done <- sess.Kill("test escalation")
-	}()
+	if err := sess.Suspend(); err != nil {
+		t.Fatalf("Suspend: %v", err)
+	}
+	if st := procState(t, sess.PID()); !strings.HasPrefix(st, "T") {
+		t.Errorf("state after Suspend = %q, want stopped", st)
+	}
+	if err := sess.Resume(); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if st := procState(t, sess.PID()); strings.HasPrefix(st, "T") {
+		t.Errorf("state after Resume = %q, want running", st)
+	}
 
+	// Killing a suspended agent continues it so it can act on SIGTERM
+	// before the grace period runs out and SIGKILL follows.
+	if err := sess.Suspend(); err != nil {
+		t.Fatalf("Suspend: %v", err)
+	}
+	_ = sess.Kill("test")
+	ps, _ := sess.Wait()
+	if got := ps.ExitCode(); got != 4 {
+		t.Errorf("exit code = %d, want 4 from the TERM trap", got)
+	}
+}
+
+func TestSession_WaitContext(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		body       string
+		wantForced bool
+	}{
+		{"exits on its own", `cat >/dev/null; exit 3`, false},
+		{"lingers after closing stdout", `cat >/dev/null
+exec 1>&-
+exec sleep 60`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := writeScript(t, dir, "agent.sh", tt.body)
+			sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", KillGrace: 500 * time.Millisecond})
+			if err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			// The agent's stdout closes either way.
+			_, _ = io.Copy(io.Discard, sess.Stdout)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			ps, err := sess.WaitContext(ctx, "test deadline")
+			if ps == nil {
+				t.Fatalf("nil ProcessState, err = %v", err)
+			}
+			if got := errors.Is(err, ErrForcedReap); got != tt.wantForced {
+				t.Errorf("forced reap = %v (err %v), want %v", got, err, tt.wantForced)
+			}
+			if tt.wantForced {
+				if st := sess.ExitStatus(); st.KilledBy != "test deadline" {
+					t.Errorf("KilledBy = %q, want %q", st.KilledBy, "test deadline")
+				}
+				if elapsed := time.Since(start); elapsed > 3*time.Second {
+					t.Errorf("WaitContext took %v", elapsed)
+				}
+			} else if ps.ExitCode() != 3 {
+				t.Errorf("exit code = %d, want 3", ps.ExitCode())
+			}
+		})
+	}
+}
+
+func TestSession_CloseUnblocksReaders(t *testing.T) {
+	dir := t.TempDir()
+	// The background sleep inherits stdout and stderr and outlives the
+	// agent, so the pipes stay open after it is killed.
+	bin := writeScript(t, dir, "agent.sh", `cat >/dev/null
+sleep 60 &
+echo $! >`+filepath.Join(dir, "child.pid")+`
+exec sleep 60`)
+
+	sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", KillGrace: 0})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if data, err := os.ReadFile(filepath.Join(dir, "child.pid")); err == nil {
+			if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+				_ = syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+	})
+
+	readErrs := make(chan error, 2)
+	for _, r := range []io.Reader{sess.Stdout, sess.Stderr} {
+		go func() {
+			_, err := io.Copy(io.Discard, r)
+			readErrs <- err
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	_ = sess.Kill("test")
 	select {
-	case err := <-done:
-		if err != nil {
-			t.Fatalf("Kill failed: %v", err)
+	case err := <-readErrs:
+		t.Fatalf("read ended after Kill alone (err %v); the grandchild should hold the pipes open", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := sess.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for range 2 {
+		select {
+		case err := <-readErrs:
+			if !errors.Is(err, os.ErrClosed) {
+				t.Errorf("read error = %v, want os.ErrClosed", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("read still blocked after Close")
 		}
-	case <-time.After(15 * time.Second):
-		t.Fatal("Kill did not return within 15s")
 	}
+	_, _ = sess.Wait()
+}
+
+// procState returns a process's state as reported by ps, e.g. "S" or "T".
+func procState(t *testing.T, pid int) string {
+	t.Helper()
+	out, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		t.Fatalf("ps: %v", err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestKill_KillSignal(t *testing.T) {
+	tests := []struct {
+		name     string
+		signal   syscall.Signal
+		wantCode int // -1 when the process should be SIGKILLed
+	}{
+		{"default is SIGTERM", 0, 4},
+		{"SIGTERM", syscall.SIGTERM, 4},
+		{"SIGINT", syscall.SIGINT, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// Exit with a code identifying whichever signal arrives first.
+			bin := writeScript(t, dir, "agent.sh", `
+sleep 60 &
+child=$!
+trap 'kill $child; exit 3' INT
+trap 'kill $child; exit 4' TERM
+wait
+`)
 
+			sess, err := Start(context.Background(), Config{
+				AgentBin:   bin,
+				Prompt:     "",
+				KillSignal: tt.signal,
+				KillGrace:  500 * time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+
+			time.Sleep(100 * time.Millisecond)
+
+			if err := sess.Kill("test"); err != nil {
+				t.Fatalf("Kill failed: %v", err)
+			}
+			ps, _ := sess.Wait()
+			if ps == nil {
+				t.Fatal("ProcessState is nil after Kill + Wait")
+			}
+			if got := ps.ExitCode(); got != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestKill_SIGINTIgnoredEscalates(t *testing.T) {
+	dir := t.TempDir()
+	bin := writeScript(t, dir, "agent.sh", `
+trap '' INT
+sleep 60
+`)
+
+	sess, err := Start(context.Background(), Config{
+		AgentBin:   bin,
+		Prompt:     "",
+		KillSignal: syscall.SIGINT,
+		KillGrace:  200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := sess.Kill("test escalation"); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
 	ps, _ := sess.Wait()
-	if ps == nil {
-		t.Fatal("ProcessState is nil after escalated Kill + Wait")
+	if ws, ok := ps.Sys().(syscall.WaitStatus); !ok || ws.Signal() != syscall.SIGKILL {
+		t.Errorf("expected process to die from SIGKILL, got %v", ps)
+	}
+}
+
+func TestKill_EscalatesToSIGKILL(t *testing.T) {
+	tests := []struct {
+		name  string
+		grace time.Duration
+	}{
+		{"short grace", 200 * time.Millisecond},
+		{"zero grace", 0},
+		{"negative grace", -time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// Script that traps SIGTERM and ignores it — requires SIGKILL.
+			bin := writeScript(t, dir, "agent.sh", `
+trap '' TERM
+sleep 60
+`)
+
+			sess, err := Start(context.Background(), Config{AgentBin: bin, Prompt: "", KillGrace: tt.grace})
+			if err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+
+			time.Sleep(100 * time.Millisecond)
+
+			start := time.Now()
+			done := make(chan error, 1)
+			go func() {
+				done <- sess.Kill("test escalation")
+			}()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Kill failed: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Kill did not return within 5s")
+			}
+
+			ps, _ := sess.Wait()
+			if ps == nil {
+				t.Fatal("ProcessState is nil after escalated Kill + Wait")
+			}
+			// Kill returns once the grace period lapses (or at once with none).
+			if elapsed := time.Since(start); elapsed > max(tt.grace, 0)+2*time.Second {
+				t.Errorf("Kill took %v with grace %v", elapsed, tt.grace)
+			}
+			if ws, ok := ps.Sys().(syscall.WaitStatus); !ok || ws.Signal() != syscall.SIGKILL {
+				t.Errorf("expected process to die from SIGKILL, got %v", ps)
+			}
+		})
 	}
 }
 
@@ -362,3 +931,59 @@ func TestStart_ContextCancellation(t *testing.T) {
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
+
+func TestConfigClone(t *testing.T) {
+	orig := Config{
+		AgentBin:   "cursor-agent",
+		ExtraFlags: make([]string, 1, 4), // spare capacity, as append leaves
+		Env:        []string{"A=1"},
+	}
+	orig.ExtraFlags[0] = "--verbose"
+
+	c := orig.Clone()
+	c.ExtraFlags = append(c.ExtraFlags, "--turn-only")
+	c.ExtraFlags[0] = "--quiet"
+	c.Env[0] = "A=2"
+
+	// Neither the element nor the spare capacity was written through.
+	if got := orig.ExtraFlags[:2]; got[0] != "--verbose" || got[1] != "" {
+		t.Errorf("original ExtraFlags backing array changed: %q", got)
+	}
+	if orig.Env[0] != "A=1" {
+		t.Errorf("original Env changed: %q", orig.Env)
+	}
+	if c.AgentBin != "cursor-agent" {
+		t.Errorf("clone AgentBin = %q", c.AgentBin)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string // empty means valid
+	}{
+		{"minimal", Config{AgentBin: "cursor-agent"}, ""},
+		{"resume id", Config{AgentBin: "cursor-agent", SessionID: "abc-123"}, ""},
+		{"no agent bin", Config{}, "agent binary path is empty"},
+		{"flag as session id", Config{AgentBin: "a", SessionID: "--model"}, "does not look like a session id"},
+		{"whitespace in session id", Config{AgentBin: "a", SessionID: "abc 123"}, "does not look like a session id"},
+		{"negative memory limit", Config{AgentBin: "a", MaxMemoryBytes: -1}, "memory limit must not be negative"},
+		{"negative CPU limit", Config{AgentBin: "a", MaxCPUSeconds: -1}, "CPU limit must not be negative"},
+		{"nice out of range", Config{AgentBin: "a", Nice: 20}, "outside -20..19"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package process
+
+import "strings"
+
+// DefaultSSHBin is the ssh client used for Config.Remote when
+// Config.SSHBin is empty.
+const DefaultSSHBin = "ssh"
+
+// remoteCommand rewrites bin and args to run on cfg.Remote over ssh. The
+// remote shell sees a single command string, so every word is quoted;
+// cfg.Cwd and cfg.Env are applied on the remote side, where they refer
+// to the remote filesystem and environment.
+//
+// ssh does not forward signals without a tty, and a tty would mangle the
+// stream. The remote command is exec'd instead, so when Kill stops the
+// local ssh the connection closes and the agent gets EOF on stdin and
+// SIGPIPE on its next write.
+func remoteCommand(cfg Config, bin string, args []string) (string, []string) {
+	var b strings.Builder
+	if cfg.Cwd != "" {
+		b.WriteString("cd " + shellQuote(cfg.Cwd) + " && ")
+	}
+	b.WriteString("exec ")
+	if len(cfg.Env) > 0 {
+		b.WriteString("env ")
+		for _, e := range cfg.Env {
+			b.WriteString(shellQuote(e) + " ")
+		}
+	}
+	b.WriteString(shellQuote(bin))
+	for _, a := range args {
+		b.WriteString(" " + shellQuote(a))
+	}
+
+	ssh := cfg.SSHBin
+	if ssh == "" {
+		ssh = DefaultSSHBin
+	}
+	// BatchMode fails instead of prompting for a password on the
+	// terminal, which the wrapper doesn't own.
+	return ssh, []string{"-o", "BatchMode=yes", "--", cfg.Remote, b.String()}
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
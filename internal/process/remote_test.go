@@ -0,0 +1,103 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSSH is an ssh stand-in that reports the host on stderr and runs the
+// remote command string with a local shell.
+const fakeSSH = `
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -o) shift 2 ;;
+    --) shift; break ;;
+    *) break ;;
+  esac
+done
+echo "host=$1" >&2
+exec /bin/sh -c "$2"
+`
+
+func TestStart_Remote(t *testing.T) {
+	dir := t.TempDir()
+	ssh := writeScript(t, dir, "ssh", fakeSSH)
+	// The "remote" agent prints its cwd, one env var and each argument.
+	agent := writeScript(t, dir, "agent.sh", `cat
+echo
+pwd -P
+echo "$REMOTE_VAR"
+for a in "$@"; do echo "arg=$a"; done`)
+	cwd, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := Start(context.Background(), Config{
+		AgentBin:   agent,
+		Prompt:     "the prompt",
+		Remote:     "user@gpu-box",
+		SSHBin:     ssh,
+		Cwd:        cwd,
+		Env:        []string{"REMOTE_VAR=it's remote"},
+		ExtraFlags: []string{"--flag", "with space", `$HOME "quoted"`},
+	})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	stdout, _ := io.ReadAll(sess.Stdout)
+	stderr, _ := io.ReadAll(sess.Stderr)
+	if _, err := sess.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	if got := strings.TrimSpace(string(stderr)); got != "host=user@gpu-box" {
+		t.Errorf("ssh stderr = %q, want host=user@gpu-box", got)
+	}
+	want := strings.Join([]string{
+		"the prompt",
+		cwd,
+		"it's remote",
+		"arg=--print", "arg=--output-format", "arg=stream-json",
+		"arg=--flag", "arg=with space", `arg=$HOME "quoted"`,
+	}, "\n")
+	if got := strings.TrimSpace(string(stdout)); got != want {
+		t.Errorf("remote output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPreflight_RemoteAgentNotFound(t *testing.T) {
+	dir := t.TempDir()
+	ssh := writeScript(t, dir, "ssh", fakeSSH)
+
+	_, err := Preflight(context.Background(), Config{
+		AgentBin: filepath.Join(dir, "missing"),
+		Remote:   "gpu-box",
+		SSHBin:   ssh,
+	}, 5*time.Second)
+	if !errors.Is(err, ErrAgentNotFound) {
+		t.Fatalf("expected ErrAgentNotFound, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "on gpu-box") {
+		t.Errorf("expected the host in the error, got: %v", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", `'plain'`},
+		{"", `''`},
+		{"it's", `'it'\''s'`},
+		{"$HOME `x`", "'$HOME `x`'"},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
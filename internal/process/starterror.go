@@ -0,0 +1,70 @@
+package process
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// ErrAgentWrongFormat is returned (wrapped in a StartError) when the
+// kernel refuses to execute the agent binary: it was built for another
+// architecture or isn't a program at all.
+var ErrAgentWrongFormat = errors.New("agent binary has the wrong format")
+
+// diagnoseStartError explains why a local agent binary failed to spawn,
+// looking at both the error and what is actually at the path, so the
+// user sees "not found at /path" rather than a bare fork/exec errno. It
+// returns nil for errors that aren't about the binary itself (e.g.
+// EAGAIN), leaving them to the generic message.
+func diagnoseStartError(bin string, err error) error {
+	where := bin
+	if abs, absErr := filepath.Abs(bin); absErr == nil && strings.ContainsRune(bin, filepath.Separator) {
+		where = abs
+	}
+	fi, statErr := os.Stat(bin)
+
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return fmt.Errorf("%s not found on PATH: install cursor-agent or pass --agent-bin: %w", bin, ErrAgentNotFound)
+	case errors.Is(err, syscall.ENOEXEC):
+		return fmt.Errorf("%s is not a program this machine can run (built for another architecture?): reinstall cursor-agent or pass --agent-bin: %w", where, ErrAgentWrongFormat)
+	case errors.Is(err, syscall.EISDIR), statErr == nil && fi.IsDir() && errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%s is a directory, not the cursor-agent binary: pass the binary itself to --agent-bin: %w", where, ErrAgentNotExecutable)
+	case errors.Is(err, fs.ErrNotExist) && statErr == nil:
+		// The file is there, so what's missing is its #! interpreter.
+		if interp := shebang(bin); interp != "" {
+			return fmt.Errorf("%s could not be run: its interpreter %s was not found: %w", where, interp, ErrAgentNotFound)
+		}
+		return fmt.Errorf("%s could not be run: a file it needs (e.g. its interpreter) was not found: %w", where, ErrAgentNotFound)
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("cursor-agent not found at %s: install it or pass --agent-bin: %w", where, ErrAgentNotFound)
+	case errors.Is(err, fs.ErrPermission) && statErr == nil && fi.Mode()&0o111 == 0:
+		return fmt.Errorf("%s is not executable: chmod +x it or pass --agent-bin: %w", where, ErrAgentNotExecutable)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("permission denied running %s (is its filesystem mounted noexec, or a parent directory unreadable?): %w", where, ErrAgentNotExecutable)
+	}
+	return nil
+}
+
+// shebang returns the interpreter named on the first line of a script,
+// or "" if path doesn't start with "#!".
+func shebang(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return ""
+	}
+	interp, _, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	return interp
+}
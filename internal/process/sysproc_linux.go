@@ -0,0 +1,24 @@
+//go:build linux
+
+package process
+
+import "syscall"
+
+// sysProcAttr puts the agent in its own process group and, unless
+// cfg.NoPdeathsig is set, asks the kernel to send it SIGTERM when the
+// wrapper dies, so a crashed or SIGKILLed wrapper doesn't leave a
+// headless agent spending API credits.
+//
+// Pdeathsig is tied to the thread that forked the child, not the process:
+// it also fires if that OS thread exits while the wrapper lives on. The Go
+// runtime only retires a thread when a goroutine exits while locked to it
+// with runtime.LockOSThread, which nothing in the wrapper does. The
+// separate process group keeps a terminal Ctrl-C from reaching the agent
+// directly, so the wrapper alone decides how the agent is stopped.
+func sysProcAttr(cfg Config) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if !cfg.NoPdeathsig {
+		attr.Pdeathsig = syscall.SIGTERM
+	}
+	return attr
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package process
+
+import "syscall"
+
+// sysProcAttr puts the agent in its own process group. Parent-death
+// signals are Linux-only, so cfg.NoPdeathsig has no effect here.
+func sysProcAttr(cfg Config) *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
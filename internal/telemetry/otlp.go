@@ -0,0 +1,269 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultOTLPTimeout bounds each export when OTEL_EXPORTER_OTLP_TIMEOUT
+// is unset.
+const DefaultOTLPTimeout = 10 * time.Second
+
+// OTLPConfig configures an OTLPExporter.
+type OTLPConfig struct {
+	Endpoint    string // full URL spans are POSTed to, e.g. http://localhost:4318/v1/traces
+	Headers     map[string]string
+	Timeout     time.Duration
+	ServiceName string
+	// ResourceAttrs are extra resource attributes, from
+	// OTEL_RESOURCE_ATTRIBUTES.
+	ResourceAttrs []Attr
+}
+
+// OTLPConfigFromEnv reads the standard OTEL_* variables through getenv.
+// endpoint, if non-empty, overrides them (like OTEL_EXPORTER_OTLP_ENDPOINT,
+// "/v1/traces" is appended unless it is already there). ok is false when
+// no endpoint is configured or the SDK is disabled, in which case nothing
+// should be exported. Warnings describe settings that are ignored.
+func OTLPConfigFromEnv(getenv func(string) string, endpoint string) (cfg OTLPConfig, ok bool, warnings []string) {
+	if strings.EqualFold(getenv("OTEL_SDK_DISABLED"), "true") || getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return cfg, false, nil
+	}
+	switch {
+	case endpoint != "":
+		cfg.Endpoint = tracesURL(endpoint)
+	case getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "":
+		cfg.Endpoint = getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	case getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "":
+		cfg.Endpoint = tracesURL(getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	default:
+		return cfg, false, nil
+	}
+
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"} {
+		if p := getenv(name); p != "" {
+			if p != "http/json" {
+				warnings = append(warnings, fmt.Sprintf("%s=%s: only http/json is supported, exporting JSON", name, p))
+			}
+			break
+		}
+	}
+
+	cfg.Headers = map[string]string{}
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS"} {
+		for k, v := range parseKeyValues(getenv(name)) {
+			cfg.Headers[k] = v
+		}
+	}
+
+	cfg.Timeout = DefaultOTLPTimeout
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", "OTEL_EXPORTER_OTLP_TIMEOUT"} {
+		if v := getenv(name); v != "" {
+			ms, err := strconv.Atoi(v)
+			if err != nil || ms <= 0 {
+				warnings = append(warnings, fmt.Sprintf("%s=%s: want a positive number of milliseconds, using %v", name, v, cfg.Timeout))
+				break
+			}
+			cfg.Timeout = time.Duration(ms) * time.Millisecond
+			break
+		}
+	}
+
+	cfg.ServiceName = getenv("OTEL_SERVICE_NAME")
+	for k, v := range parseKeyValues(getenv("OTEL_RESOURCE_ATTRIBUTES")) {
+		if k == "service.name" && cfg.ServiceName == "" {
+			cfg.ServiceName = v
+			continue
+		}
+		cfg.ResourceAttrs = append(cfg.ResourceAttrs, Attr{Key: k, Value: v})
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "cursor-wrap"
+	}
+	return cfg, true, warnings
+}
+
+// tracesURL appends the OTLP/HTTP traces path to a base endpoint.
+func tracesURL(base string) string {
+	if strings.HasSuffix(base, "/v1/traces") {
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/v1/traces"
+}
+
+// parseKeyValues parses the comma-separated key=value lists used by
+// OTEL_EXPORTER_OTLP_HEADERS and OTEL_RESOURCE_ATTRIBUTES. Values are
+// percent-decoded; malformed entries are skipped.
+func parseKeyValues(s string) map[string]string {
+	m := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		if dec, err := url.PathUnescape(strings.TrimSpace(v)); err == nil {
+			m[k] = dec
+		}
+	}
+	return m
+}
+
+// OTLPExporter POSTs spans as OTLP/HTTP JSON.
+type OTLPExporter struct {
+	cfg    OTLPConfig
+	client *http.Client
+}
+
+func NewOTLPExporter(cfg OTLPConfig) *OTLPExporter {
+	return &OTLPExporter{cfg: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return fmt.Errorf("otlp: encoding spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: %s returned %s: %s", e.cfg.Endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body) // let the connection be reused
+	return nil
+}
+
+// The OTLP JSON encoding (opentelemetry-proto, JSON mapping): ids are
+// hex, 64-bit integers and nanosecond timestamps are decimal strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+const (
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+func (e *OTLPExporter) request(spans []SpanData) otlpRequest {
+	resource := append([]Attr{{Key: "service.name", Value: e.cfg.ServiceName}}, e.cfg.ResourceAttrs...)
+	out := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		o := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(s.Start),
+			EndTimeUnixNano:   unixNano(s.End),
+			Attributes:        otlpAttrs(s.Attrs),
+		}
+		if !s.ParentID.IsZero() {
+			o.ParentSpanID = s.ParentID.String()
+		}
+		for _, ev := range s.Events {
+			o.Events = append(o.Events, otlpEvent{TimeUnixNano: unixNano(ev.Time), Name: ev.Name, Attributes: otlpAttrs(ev.Attrs)})
+		}
+		if s.Error != "" {
+			o.Status = otlpStatus{Code: statusCodeError, Message: s.Error}
+		}
+		out[i] = o
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttrs(resource)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "cursor-wrap"}, Spans: out}},
+	}}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttrs(attrs []Attr) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	kvs := make([]otlpKeyValue, len(attrs))
+	for i, a := range attrs {
+		var v otlpValue
+		switch x := normalize(a.Value).(type) {
+		case bool:
+			v.BoolValue = &x
+		case int64:
+			s := strconv.FormatInt(x, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &x
+		case string:
+			v.StringValue = &x
+		}
+		kvs[i] = otlpKeyValue{Key: a.Key, Value: v}
+	}
+	return kvs
+}
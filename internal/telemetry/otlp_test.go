@@ -0,0 +1,181 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOTLPConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		flag     string
+		wantOK   bool
+		want     OTLPConfig
+		wantWarn string
+	}{
+		{name: "nothing configured"},
+		{
+			name:   "base endpoint",
+			env:    map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318/"},
+			wantOK: true,
+			want:   OTLPConfig{Endpoint: "http://collector:4318/v1/traces", Timeout: DefaultOTLPTimeout, ServiceName: "cursor-wrap"},
+		},
+		{
+			name: "traces endpoint used as is and wins over base",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://base:4318",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://traces:4318/custom",
+			},
+			wantOK: true,
+			want:   OTLPConfig{Endpoint: "http://traces:4318/custom", Timeout: DefaultOTLPTimeout, ServiceName: "cursor-wrap"},
+		},
+		{
+			name:   "flag wins over env",
+			env:    map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://traces:4318/v1/traces"},
+			flag:   "http://flag:4318",
+			wantOK: true,
+			want:   OTLPConfig{Endpoint: "http://flag:4318/v1/traces", Timeout: DefaultOTLPTimeout, ServiceName: "cursor-wrap"},
+		},
+		{
+			name: "headers, timeout and resource",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":       "http://c:4318",
+				"OTEL_EXPORTER_OTLP_HEADERS":        "Authorization=Basic%20abc, x-team=ci",
+				"OTEL_EXPORTER_OTLP_TRACES_HEADERS": "x-team=agents",
+				"OTEL_EXPORTER_OTLP_TIMEOUT":        "2500",
+				"OTEL_RESOURCE_ATTRIBUTES":          "service.name=ci-agent,deployment.environment=ci",
+			},
+			wantOK: true,
+			want: OTLPConfig{
+				Endpoint:      "http://c:4318/v1/traces",
+				Headers:       map[string]string{"Authorization": "Basic abc", "x-team": "agents"},
+				Timeout:       2500 * time.Millisecond,
+				ServiceName:   "ci-agent",
+				ResourceAttrs: []Attr{{"deployment.environment", "ci"}},
+			},
+		},
+		{
+			name:     "unsupported protocol warns",
+			env:      map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://c:4317", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			wantOK:   true,
+			want:     OTLPConfig{Endpoint: "http://c:4317/v1/traces", Timeout: DefaultOTLPTimeout, ServiceName: "cursor-wrap"},
+			wantWarn: "only http/json",
+		},
+		{
+			name: "sdk disabled",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://c:4318", "OTEL_SDK_DISABLED": "true"},
+			flag: "http://flag:4318",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, ok, warnings := OTLPConfigFromEnv(func(k string) string { return tt.env[k] }, tt.flag)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if cfg.Endpoint != tt.want.Endpoint || cfg.Timeout != tt.want.Timeout || cfg.ServiceName != tt.want.ServiceName {
+				t.Errorf("cfg = %+v, want %+v", cfg, tt.want)
+			}
+			for k, v := range tt.want.Headers {
+				if cfg.Headers[k] != v {
+					t.Errorf("header %s = %q, want %q", k, cfg.Headers[k], v)
+				}
+			}
+			if len(cfg.ResourceAttrs) != len(tt.want.ResourceAttrs) {
+				t.Errorf("resource attrs = %v, want %v", cfg.ResourceAttrs, tt.want.ResourceAttrs)
+			}
+			joined := strings.Join(warnings, "\n")
+			if tt.wantWarn == "" && joined != "" || !strings.Contains(joined, tt.wantWarn) {
+				t.Errorf("warnings = %q, want %q", joined, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestOTLPExporter_Export(t *testing.T) {
+	var got otlpRequest
+	var auth, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.Error(w, "wrong path "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		auth, contentType = r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	cfg, _, _ := OTLPConfigFromEnv(func(k string) string {
+		return map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "Authorization=Bearer%20t"}[k]
+	}, srv.URL)
+	tr := NewTracer(NewOTLPExporter(cfg), "")
+	turn := tr.Start("cursor-agent turn", nil, Attrs("turn", 2, "resume", true)...)
+	tool := tr.Start("tool_call shellToolCall", turn, Attrs("exit_code", 1)...)
+	tool.SetError("exit code 1")
+	tool.End()
+	turn.AddEvent("hang_detected", Attrs("idle_silence_ms", int64(61000))...)
+	turn.End()
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if auth != "Bearer t" || contentType != "application/json" {
+		t.Errorf("headers: Authorization %q, Content-Type %q", auth, contentType)
+	}
+	rs := got.ResourceSpans
+	if len(rs) != 1 || len(rs[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request shape: %+v", got)
+	}
+	if sn := rs[0].Resource.Attributes[0]; sn.Key != "service.name" || *sn.Value.StringValue != "cursor-wrap" {
+		t.Errorf("resource service.name = %+v", sn)
+	}
+	spans := rs[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	toolSpan, turnSpan := spans[0], spans[1]
+	if toolSpan.ParentSpanID != turnSpan.SpanID || turnSpan.ParentSpanID != "" {
+		t.Errorf("tool parent %q, turn span %q (parent %q)", toolSpan.ParentSpanID, turnSpan.SpanID, turnSpan.ParentSpanID)
+	}
+	if len(turnSpan.TraceID) != 32 || len(turnSpan.SpanID) != 16 {
+		t.Errorf("ids not hex-encoded: trace %q span %q", turnSpan.TraceID, turnSpan.SpanID)
+	}
+	if toolSpan.Status.Code != statusCodeError || toolSpan.Status.Message != "exit code 1" {
+		t.Errorf("tool status = %+v", toolSpan.Status)
+	}
+	if v := toolSpan.Attributes[0].Value.IntValue; v == nil || *v != "1" {
+		t.Errorf("exit_code = %+v, want intValue \"1\"", toolSpan.Attributes[0].Value)
+	}
+	if v := turnSpan.Attributes[1].Value.BoolValue; v == nil || !*v {
+		t.Errorf("resume = %+v, want boolValue true", turnSpan.Attributes[1].Value)
+	}
+	if len(turnSpan.Events) != 1 || turnSpan.Events[0].Name != "hang_detected" {
+		t.Errorf("turn events = %+v", turnSpan.Events)
+	}
+}
+
+func TestOTLPExporter_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPExporter(OTLPConfig{Endpoint: srv.URL + "/v1/traces", Timeout: time.Second})
+	err := exp.Export(context.Background(), []SpanData{{Name: "turn"}})
+	if err == nil || !strings.Contains(err.Error(), "429") || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("error = %v, want the status and body", err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package telemetry exports the wrapper's turns and tool calls as
+// OpenTelemetry spans. It implements just enough of the trace model for
+// that — spans, span events and attributes — and an OTLP/HTTP JSON
+// exporter, to keep the wrapper free of the OpenTelemetry SDK.
+//
+// A nil *Tracer and a nil *Span are valid and do nothing, so callers can
+// trace unconditionally at no cost when export is disabled.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID and SpanID are W3C trace-context identifiers.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsZero reports whether id is unset.
+func (id SpanID) IsZero() bool { return id == SpanID{} }
+
+// Attr is a span or event attribute. Value is a string, bool, int64 or
+// float64; other types are exported as their fmt.Sprint form.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// Attrs converts alternating keys and values, as passed to slog, into
+// attributes. Ints of any size become int64.
+func Attrs(kv ...any) []Attr {
+	attrs := make([]Attr, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			continue
+		}
+		attrs = append(attrs, Attr{Key: key, Value: normalize(kv[i+1])})
+	}
+	return attrs
+}
+
+func normalize(v any) any {
+	switch v := v.(type) {
+	case string, bool, int64, float64:
+		return v
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case time.Duration:
+		return v.Milliseconds()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Event is a timestamped annotation on a span, e.g. a hang.
+type Event struct {
+	Name  string
+	Time  time.Time
+	Attrs []Attr
+}
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID // zero for a root span
+	Name     string
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	Events   []Event
+	// Error, when set, marks the span as failed with this message.
+	Error string
+}
+
+// Exporter sends finished spans somewhere.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Tracer creates spans in one trace and hands them to its exporter when
+// Flush is called.
+type Tracer struct {
+	exp     Exporter
+	traceID TraceID
+	parent  SpanID // of an enclosing span from TRACEPARENT, if any
+
+	mu    sync.Mutex
+	ended []SpanData // waiting for Flush
+}
+
+// NewTracer returns a Tracer that exports to exp. Its spans join the
+// trace named by traceparent (a W3C traceparent header, as CI systems
+// pass in $TRACEPARENT) or, if that is empty or malformed, a new trace.
+func NewTracer(exp Exporter, traceparent string) *Tracer {
+	t := &Tracer{exp: exp}
+	if tid, sid, ok := parseTraceparent(traceparent); ok {
+		t.traceID, t.parent = tid, sid
+	} else {
+		_, _ = rand.Read(t.traceID[:]) // never fails
+	}
+	return t
+}
+
+// TraceID returns the tracer's trace id.
+func (t *Tracer) TraceID() TraceID {
+	if t == nil {
+		return TraceID{}
+	}
+	return t.traceID
+}
+
+// Start begins a span. Its parent is parent if non-nil, otherwise the
+// span from TRACEPARENT, if any.
+func (t *Tracer) Start(name string, parent *Span, attrs ...Attr) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{tracer: t, data: SpanData{
+		TraceID:  t.traceID,
+		ParentID: t.parent,
+		Name:     name,
+		Start:    time.Now(),
+		Attrs:    attrs,
+	}}
+	_, _ = rand.Read(s.data.SpanID[:]) // never fails
+	if parent != nil {
+		s.data.ParentID = parent.data.SpanID
+	}
+	return s
+}
+
+// Flush exports the spans that have ended since the last Flush.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	spans := t.ended
+	t.ended = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.exp.Export(ctx, spans)
+}
+
+// Span is a span in progress. Its methods are not safe for concurrent
+// use; the wrapper only touches a turn's spans from the turn's loop.
+type Span struct {
+	tracer *Tracer
+	data   SpanData
+	ended  bool
+}
+
+// SetAttrs adds attributes to the span.
+func (s *Span) SetAttrs(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.data.Attrs = append(s.data.Attrs, attrs...)
+}
+
+// AddEvent records an event on the span.
+func (s *Span) AddEvent(name string, attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attrs: attrs})
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(msg string) {
+	if s == nil {
+		return
+	}
+	s.data.Error = msg
+}
+
+// End finishes the span and queues it for the next Flush. Only the first
+// call has any effect.
+func (s *Span) End() {
+	if s == nil || s.ended {
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	s.tracer.mu.Lock()
+	s.tracer.ended = append(s.tracer.ended, s.data)
+	s.tracer.mu.Unlock()
+}
+
+// parseTraceparent parses a version-00 W3C traceparent header:
+// 00-<32 hex trace id>-<16 hex span id>-<2 hex flags>.
+func parseTraceparent(h string) (TraceID, SpanID, bool) {
+	var tid TraceID
+	var sid SpanID
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return tid, sid, false
+	}
+	if _, err := hex.Decode(tid[:], []byte(parts[1])); err != nil || tid == (TraceID{}) {
+		return tid, sid, false
+	}
+	if _, err := hex.Decode(sid[:], []byte(parts[2])); err != nil || sid.IsZero() {
+		return tid, sid, false
+	}
+	return tid, sid, true
+}
+
+// MemoryExporter keeps exported spans in memory, for tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *MemoryExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans returns every span exported so far, in the order they ended.
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
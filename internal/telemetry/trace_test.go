@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTracer_SpanTree(t *testing.T) {
+	exp := &MemoryExporter{}
+	tr := NewTracer(exp, "")
+
+	turn := tr.Start("turn", nil, Attrs("turn", 1)...)
+	tool := tr.Start("tool", turn, Attrs("command", "ls", "timeout_ms", int64(500), "slow", time.Second)...)
+	tool.SetError("exit code 1")
+	tool.End()
+	tool.End() // no-op
+	turn.AddEvent("hang_detected", Attrs("idle_silence_ms", 61000)...)
+	turn.End()
+
+	if got := exp.Spans(); len(got) != 0 {
+		t.Fatalf("spans exported before Flush: %d", len(got))
+	}
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := exp.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	toolData, turnData := spans[0], spans[1]
+	if toolData.ParentID != turnData.SpanID || !turnData.ParentID.IsZero() {
+		t.Errorf("tool parent = %s, turn = %s (parent %s)", toolData.ParentID, turnData.SpanID, turnData.ParentID)
+	}
+	if toolData.TraceID != tr.TraceID() || turnData.TraceID != tr.TraceID() {
+		t.Error("spans are not in the tracer's trace")
+	}
+	if toolData.Error != "exit code 1" {
+		t.Errorf("tool error = %q", toolData.Error)
+	}
+	wantAttrs := map[string]any{"command": "ls", "timeout_ms": int64(500), "slow": int64(1000)}
+	for _, a := range toolData.Attrs {
+		if want, ok := wantAttrs[a.Key]; ok && a.Value != want {
+			t.Errorf("attr %s = %#v, want %#v", a.Key, a.Value, want)
+		}
+	}
+	if len(turnData.Events) != 1 || turnData.Events[0].Name != "hang_detected" {
+		t.Errorf("turn events = %+v", turnData.Events)
+	}
+	if turnData.End.Before(turnData.Start) {
+		t.Error("turn ended before it started")
+	}
+
+	// Flushed spans are not exported again.
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(exp.Spans()); n != 2 {
+		t.Errorf("second Flush exported again: %d spans", n)
+	}
+}
+
+func TestTracer_Traceparent(t *testing.T) {
+	exp := &MemoryExporter{}
+	tr := NewTracer(exp, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	tr.Start("turn", nil).End()
+	_ = tr.Flush(context.Background())
+
+	s := exp.Spans()[0]
+	if s.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || s.ParentID.String() != "00f067aa0ba902b7" {
+		t.Errorf("span trace/parent = %s/%s, want the traceparent's", s.TraceID, s.ParentID)
+	}
+
+	for _, bad := range []string{"garbage", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		if tr := NewTracer(exp, bad); tr.parent != (SpanID{}) || tr.TraceID() == (TraceID{}) {
+			t.Errorf("traceparent %q: parent %s, trace %s; want a fresh root trace", bad, tr.parent, tr.TraceID())
+		}
+	}
+}
+
+func TestTracer_NilIsNoop(t *testing.T) {
+	var tr *Tracer
+	s := tr.Start("turn", nil)
+	s.SetAttrs(Attrs("k", "v")...)
+	s.AddEvent("e")
+	s.SetError("x")
+	s.End()
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}